@@ -1,6 +1,7 @@
 package blockchain
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
@@ -21,6 +22,9 @@ import (
 const (
 	pricingOP = "pricingOP"
 	depositOP = "depositOP"
+	// alerterOP is the alerter of pricing contract, which is allowed to
+	// disable the trade of tokens.
+	alerterOP = "alerterOP"
 
 	// feeToWalletEvent is the topic of event AssignFeeToWallet(address reserve, address wallet, uint walletFee).
 	feeToWalletEvent = "0x366bc34352215bf0bd3b527cfd6718605e1f5938777e42bcd8ed92f578368f52"
@@ -35,7 +39,22 @@ const (
 	userCatEvent = "0x0aeb0f7989a09b8cccf58cea1aefa196ccf738cb14781d6910448dd5649d0e6e"
 )
 
+// Commands of pricing contract getStepFunctionData reading the length of x of
+// each step function, the following commands read x values, y length and y
+// values.
+// https://github.com/KyberNetwork/smart-contracts/blob/fed8e09dc6e4365e1597474d9b3f53634eb405d2/contracts/ConversionRates.sol#L158
+const (
+	qtyBuyStepCommand        = 0
+	qtySellStepCommand       = 4
+	imbalanceBuyStepCommand  = 8
+	imbalanceSellStepCommand = 12
+)
+
 var (
+	// errNoAlerter is returned when disabling token trade without an
+	// alerter operator registered.
+	errNoAlerter = errors.New("No alerter operator is configured to disable token trade")
+
 	Big0   = big.NewInt(0)
 	BigMax = big.NewInt(10).Exp(big.NewInt(10), big.NewInt(33), nil)
 )
@@ -72,11 +91,15 @@ type Blockchain struct {
 }
 
 func (self *Blockchain) StandardGasPrice() float64 {
-	// we use node's recommended gas price because gas station is not returning
-	// correct gas price now
-	price, err := self.RecommendedGasPriceFromNode()
+	// we estimate the gas price from the fee history of recent blocks, the
+	// node's recommended gas price is used for nodes not supporting
+	// eth_feeHistory because gas station is not returning correct gas price
+	price, err := self.RecommendedGasPriceFromFeeHistory()
 	if err != nil {
-		return 0
+		log.Printf("Cannot estimate gas price from fee history, using node's gas price: %s", err)
+		if price, err = self.RecommendedGasPriceFromNode(); err != nil {
+			return 0
+		}
 	}
 	return common.BigToFloat(price, 9)
 }
@@ -139,6 +162,14 @@ func (self *Blockchain) RegisterDepositOperator(signer blockchain.Signer, nonceC
 	self.MustRegisterOperator(depositOP, blockchain.NewOperator(signer, nonceCorpus))
 }
 
+// RegisterAlerterOperator registers the alerter of pricing contract, the
+// trade of tokens is disabled by the pricing operator if it is not
+// registered.
+func (self *Blockchain) RegisterAlerterOperator(signer blockchain.Signer, nonceCorpus blockchain.NonceCorpus) {
+	log.Printf("reserve alerter address: %s", signer.GetAddress().Hex())
+	self.MustRegisterOperator(alerterOP, blockchain.NewOperator(signer, nonceCorpus))
+}
+
 func readablePrint(data map[ethereum.Address]byte) string {
 	result := ""
 	for addr, b := range data {
@@ -297,7 +328,197 @@ func (self *Blockchain) SetQtyStepFunction(token ethereum.Address, xBuy []*big.I
 	return self.SignAndBroadcast(tx, pricingOP)
 }
 
+// SetTokenControlInfo sets the imbalance control of token, the pricing
+// contract only allows its admin to do it.
+func (self *Blockchain) SetTokenControlInfo(token ethereum.Address, info common.TokenControlInfo) (*types.Transaction, error) {
+	opts, err := self.GetTxOpts(pricingOP, nil, nil, nil)
+	if err != nil {
+		log.Printf("Getting transaction opts failed, err: %s", err)
+		return nil, err
+	}
+	tx, err := self.GeneratedSetTokenControlInfo(opts, token, info.MinimalRecordResolution, info.MaxPerBlockImbalance, info.MaxTotalImbalance)
+	if err != nil {
+		return nil, err
+	}
+	return self.SignAndBroadcast(tx, pricingOP)
+}
+
+// AddToken lists token in pricing contract, the pricing contract only allows
+// its admin to do it.
+func (self *Blockchain) AddToken(token ethereum.Address) (*types.Transaction, error) {
+	opts, err := self.GetTxOpts(pricingOP, nil, nil, nil)
+	if err != nil {
+		log.Printf("Getting transaction opts failed, err: %s", err)
+		return nil, err
+	}
+	tx, err := self.GeneratedAddToken(opts, token)
+	if err != nil {
+		return nil, err
+	}
+	return self.SignAndBroadcast(tx, pricingOP)
+}
+
+// EnableTokenTrade enables trading of a listed token in pricing contract,
+// the pricing contract only allows its admin to do it.
+func (self *Blockchain) EnableTokenTrade(token ethereum.Address) (*types.Transaction, error) {
+	opts, err := self.GetTxOpts(pricingOP, nil, nil, nil)
+	if err != nil {
+		log.Printf("Getting transaction opts failed, err: %s", err)
+		return nil, err
+	}
+	tx, err := self.GeneratedEnableTokenTrade(opts, token)
+	if err != nil {
+		return nil, err
+	}
+	return self.SignAndBroadcast(tx, pricingOP)
+}
+
+// SetBaseRate sets the base rates of token in pricing contract. No compact
+// data is sent so the compact rates and rate update block of other tokens
+// sharing its bulk are kept.
+func (self *Blockchain) SetBaseRate(token ethereum.Address, buy, sell *big.Int) (*types.Transaction, error) {
+	opts, err := self.GetTxOpts(pricingOP, nil, nil, nil)
+	if err != nil {
+		log.Printf("Getting transaction opts failed, err: %s", err)
+		return nil, err
+	}
+	tx, err := self.GeneratedSetBaseRate(opts, []ethereum.Address{token}, []*big.Int{buy}, []*big.Int{sell},
+		[][14]byte{}, [][14]byte{}, Big0, []*big.Int{})
+	if err != nil {
+		return nil, err
+	}
+	return self.SignAndBroadcast(tx, pricingOP)
+}
+
+// DisableTokenTrade disables trading of token in pricing contract by the
+// alerter operator, the pricing contract only allows its alerters to do it.
+func (self *Blockchain) DisableTokenTrade(token ethereum.Address) (*types.Transaction, error) {
+	if _, ok := self.OperatorAddresses()[alerterOP]; !ok {
+		return nil, errNoAlerter
+	}
+	opts, err := self.GetTxOpts(alerterOP, nil, nil, nil)
+	if err != nil {
+		log.Printf("Getting transaction opts failed, err: %s", err)
+		return nil, err
+	}
+	tx, err := self.GeneratedDisableTokenTrade(opts, token)
+	if err != nil {
+		return nil, err
+	}
+	return self.SignAndBroadcast(tx, alerterOP)
+}
+
 //====================== Readonly calls ============================
+
+// GetTokenBasicData returns whether token is listed and its trade is enabled
+// in pricing contract.
+func (self *Blockchain) GetTokenBasicData(token ethereum.Address) (bool, bool, error) {
+	return self.GeneratedGetTokenBasicData(self.GetCallOpts(0), token)
+}
+
+// GetRateUpdateBlock returns the block of the last rates of token set in
+// pricing contract.
+func (self *Blockchain) GetRateUpdateBlock(token ethereum.Address) (uint64, error) {
+	block, err := self.GeneratedGetRateUpdateBlock(self.GetCallOpts(0), token)
+	if err != nil {
+		return 0, err
+	}
+	return block.Uint64(), nil
+}
+
+// ValidRateDurationInBlocks returns the number of blocks the rates set in
+// pricing contract are valid for.
+func (self *Blockchain) ValidRateDurationInBlocks() (uint64, error) {
+	duration, err := self.GeneratedValidRateDurationInBlocks(self.GetCallOpts(0))
+	if err != nil {
+		return 0, err
+	}
+	return duration.Uint64(), nil
+}
+
+// getStepFunction reads x and y of the step function of token starting at
+// command.
+func (self *Blockchain) getStepFunction(opts blockchain.CallOpts, token ethereum.Address, command int64) ([]*big.Int, []*big.Int, error) {
+	// x and y are read by the same commands, shifted by 2 for y
+	var result [2][]*big.Int
+	for i := range result {
+		lengthCommand := big.NewInt(command + 2*int64(i))
+		length, err := self.GeneratedGetStepFunctionData(opts, token, lengthCommand, Big0)
+		if err != nil {
+			return nil, nil, err
+		}
+		result[i] = []*big.Int{}
+		for j := int64(0); j < length.Int64(); j++ {
+			value, vErr := self.GeneratedGetStepFunctionData(opts, token, big.NewInt(command+2*int64(i)+1), big.NewInt(j))
+			if vErr != nil {
+				return nil, nil, vErr
+			}
+			result[i] = append(result[i], value)
+		}
+	}
+	return result[0], result[1], nil
+}
+
+// GetStepFunctions returns the quantity and imbalance step functions of token
+// at block atBlock, 0 is the pending state.
+func (self *Blockchain) GetStepFunctions(token ethereum.Address, atBlock uint64) (common.StepFunctionResponse, error) {
+	var (
+		result = common.StepFunctionResponse{}
+		opts   = self.GetCallOpts(atBlock)
+		err    error
+	)
+	qty := &result.QuantityStepResponse
+	if qty.XBuy, qty.YBuy, err = self.getStepFunction(opts, token, qtyBuyStepCommand); err != nil {
+		return result, err
+	}
+	if qty.XSell, qty.YSell, err = self.getStepFunction(opts, token, qtySellStepCommand); err != nil {
+		return result, err
+	}
+	imbalance := &result.ImbalanceStepResponse
+	if imbalance.XBuy, imbalance.YBuy, err = self.getStepFunction(opts, token, imbalanceBuyStepCommand); err != nil {
+		return result, err
+	}
+	imbalance.XSell, imbalance.YSell, err = self.getStepFunction(opts, token, imbalanceSellStepCommand)
+	return result, err
+}
+
+// GetTokenControlInfo returns the imbalance control of token at block
+// atBlock, 0 is the pending state.
+func (self *Blockchain) GetTokenControlInfo(token ethereum.Address, atBlock uint64) (common.TokenControlInfo, error) {
+	resolution, maxPerBlock, maxTotal, err := self.GeneratedGetTokenControlInfo(self.GetCallOpts(atBlock), token)
+	if err != nil {
+		return common.TokenControlInfo{}, err
+	}
+	return common.TokenControlInfo{
+		MinimalRecordResolution: resolution,
+		MaxPerBlockImbalance:    maxPerBlock,
+		MaxTotalImbalance:       maxTotal,
+	}, nil
+}
+
+// CheckAlerter returns error if there is no alerter operator registered or
+// it is not an alerter of pricing contract.
+func (self *Blockchain) CheckAlerter() error {
+	alerter, ok := self.OperatorAddresses()[alerterOP]
+	if !ok {
+		return errNoAlerter
+	}
+	alerters, err := self.GeneratedGetAlerters(self.GetCallOpts(0))
+	if err != nil {
+		return err
+	}
+	for _, addr := range alerters {
+		if addr == alerter {
+			return nil
+		}
+	}
+	return fmt.Errorf("Alerter operator %s is not an alerter of pricing contract", alerter.Hex())
+}
+
+// GetPricingAdmin returns the admin of pricing contract.
+func (self *Blockchain) GetPricingAdmin() (ethereum.Address, error) {
+	return self.GeneratedAdmin(self.GetCallOpts(0))
+}
 func (self *Blockchain) FetchBalanceData(reserve ethereum.Address, atBlock uint64) (map[string]common.BalanceEntry, error) {
 	result := map[string]common.BalanceEntry{}
 	tokens := []ethereum.Address{}
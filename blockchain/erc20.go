@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/blockchain"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// erc20MetadataABI is the ERC20 optional metadata functions.
+	erc20MetadataABI = `[
+		{"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"payable":false,"stateMutability":"view","type":"function"},
+		{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"payable":false,"stateMutability":"view","type":"function"}
+	]`
+	// erc20Bytes32SymbolABI is the symbol function of the tokens returning
+	// bytes32 instead of string, eg: MKR.
+	erc20Bytes32SymbolABI = `[
+		{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"bytes32"}],"payable":false,"stateMutability":"view","type":"function"}
+	]`
+)
+
+var (
+	erc20ABI         = mustParseABI(erc20MetadataABI)
+	erc20Bytes32ABI  = mustParseABI(erc20Bytes32SymbolABI)
+	erc20CallTimeout = 2 * time.Second
+)
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// getERC20Symbol returns the symbol of token contract, returned as string or
+// bytes32.
+func (self *Blockchain) getERC20Symbol(address ethereum.Address) (string, error) {
+	opts := self.GetCallOpts(0)
+	var symbol string
+	err := self.Call(erc20CallTimeout, opts, &blockchain.Contract{Address: address, ABI: erc20ABI}, &symbol, "symbol")
+	if err == nil {
+		return symbol, nil
+	}
+	var symbol32 [32]byte
+	if bErr := self.Call(erc20CallTimeout, opts, &blockchain.Contract{Address: address, ABI: erc20Bytes32ABI}, &symbol32, "symbol"); bErr != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(symbol32[:], "\x00")), nil
+}
+
+// CheckTokenContract returns error if the ERC20 contract at the address of
+// token doesn't have the decimals and the symbol of token.
+func (self *Blockchain) CheckTokenContract(token common.Token) error {
+	address := ethereum.HexToAddress(token.Address)
+	var decimals uint8
+	if err := self.Call(erc20CallTimeout, self.GetCallOpts(0), &blockchain.Contract{Address: address, ABI: erc20ABI}, &decimals, "decimals"); err != nil {
+		return fmt.Errorf("Getting decimals of %s (%s) failed: %s", token.ID, token.Address, err.Error())
+	}
+	if int64(decimals) != token.Decimals {
+		return fmt.Errorf("Decimals of %s contract is %d, not %d", token.ID, decimals, token.Decimals)
+	}
+	symbol, err := self.getERC20Symbol(address)
+	if err != nil {
+		return fmt.Errorf("Getting symbol of %s (%s) failed: %s", token.ID, token.Address, err.Error())
+	}
+	if !strings.EqualFold(symbol, token.ID) {
+		return fmt.Errorf("Symbol of %s contract is %s", token.ID, symbol)
+	}
+	return nil
+}
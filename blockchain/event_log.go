@@ -58,6 +58,7 @@ func updateTradeLogs(allLogs []common.KNLog, logItem types.Log, ts uint64) ([]co
 	if !updateLastLog {
 		tradeLog = common.TradeLog{
 			BlockNumber:     logItem.BlockNumber,
+			BlockHash:       logItem.BlockHash,
 			TransactionHash: logItem.TxHash,
 			Index:           logItem.Index,
 			Timestamp:       ts,
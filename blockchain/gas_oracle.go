@@ -2,10 +2,13 @@ package blockchain
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
 )
 
 const gasStationURL = "https://ethgasstation.info/json/ethgasAPI.json"
@@ -18,7 +21,7 @@ type gasStationResponse struct {
 
 // GasOracle is an ETH Gas Station client.
 type GasOracle struct {
-	client *http.Client
+	client *httpclient.Client
 	m      sync.RWMutex
 
 	standard float64
@@ -28,7 +31,7 @@ type GasOracle struct {
 
 // NewGasOracle create new GasOracle instance.
 func NewGasOracle() *GasOracle {
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := httpclient.NewClient("gas_oracle", httpclient.DefaultConfig(10*time.Second))
 	return &GasOracle{client: client}
 }
 
@@ -72,24 +75,21 @@ func (gso *GasOracle) Fetch() {
 }
 
 func (gso *GasOracle) runGasPricing() error {
-	gso.m.Lock()
-	defer gso.m.Unlock()
-
 	r, err := gso.client.Get(gasStationURL)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if cErr := r.Body.Close(); cErr != nil {
-			log.Printf("Response close error: %s", cErr.Error())
-		}
-	}()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("Gas station returned with code: %d", r.StatusCode)
+	}
 
 	rsp := &gasStationResponse{}
-	if err = json.NewDecoder(r.Body).Decode(rsp); err != nil {
+	if err = json.Unmarshal(r.Body, rsp); err != nil {
 		return err
 	}
 
+	gso.m.Lock()
+	defer gso.m.Unlock()
 	gso.standard = rsp.Standard
 	gso.safeLow = rsp.SafeLow
 	gso.fast = rsp.Fast
@@ -51,3 +51,85 @@ func (bc *Blockchain) GeneratedGetStepFunctionData(opts blockchain.CallOpts, tok
 	err := bc.Call(timeOut, opts, bc.pricing, out, "getStepFunctionData", token, command, param)
 	return *ret0, err
 }
+
+func (self *Blockchain) GeneratedSetTokenControlInfo(opts blockchain.TxOpts, token ethereum.Address, minimalRecordResolution *big.Int, maxPerBlockImbalance *big.Int, maxTotalImbalance *big.Int) (*types.Transaction, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return self.BuildTx(timeout, opts, self.pricing, "setTokenControlInfo", token, minimalRecordResolution, maxPerBlockImbalance, maxTotalImbalance)
+}
+
+func (self *Blockchain) GeneratedGetTokenControlInfo(opts blockchain.CallOpts, token ethereum.Address) (*big.Int, *big.Int, *big.Int, error) {
+	var (
+		ret0 = new(*big.Int)
+		ret1 = new(*big.Int)
+		ret2 = new(*big.Int)
+	)
+	out := &[]interface{}{
+		ret0,
+		ret1,
+		ret2,
+	}
+	timeOut := 2 * time.Second
+	err := self.Call(timeOut, opts, self.pricing, out, "getTokenControlInfo", token)
+	return *ret0, *ret1, *ret2, err
+}
+
+func (self *Blockchain) GeneratedAdmin(opts blockchain.CallOpts) (ethereum.Address, error) {
+	out := new(ethereum.Address)
+	timeOut := 2 * time.Second
+	err := self.Call(timeOut, opts, self.pricing, out, "admin")
+	return *out, err
+}
+
+func (self *Blockchain) GeneratedGetRateUpdateBlock(opts blockchain.CallOpts, token ethereum.Address) (*big.Int, error) {
+	out := new(*big.Int)
+	timeOut := 2 * time.Second
+	err := self.Call(timeOut, opts, self.pricing, out, "getRateUpdateBlock", token)
+	return *out, err
+}
+
+func (self *Blockchain) GeneratedValidRateDurationInBlocks(opts blockchain.CallOpts) (*big.Int, error) {
+	out := new(*big.Int)
+	timeOut := 2 * time.Second
+	err := self.Call(timeOut, opts, self.pricing, out, "validRateDurationInBlocks")
+	return *out, err
+}
+
+func (self *Blockchain) GeneratedGetAlerters(opts blockchain.CallOpts) ([]ethereum.Address, error) {
+	out := new([]ethereum.Address)
+	timeOut := 2 * time.Second
+	err := self.Call(timeOut, opts, self.pricing, out, "getAlerters")
+	return *out, err
+}
+
+func (self *Blockchain) GeneratedAddToken(opts blockchain.TxOpts, token ethereum.Address) (*types.Transaction, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return self.BuildTx(timeout, opts, self.pricing, "addToken", token)
+}
+
+func (self *Blockchain) GeneratedEnableTokenTrade(opts blockchain.TxOpts, token ethereum.Address) (*types.Transaction, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return self.BuildTx(timeout, opts, self.pricing, "enableTokenTrade", token)
+}
+
+func (self *Blockchain) GeneratedDisableTokenTrade(opts blockchain.TxOpts, token ethereum.Address) (*types.Transaction, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return self.BuildTx(timeout, opts, self.pricing, "disableTokenTrade", token)
+}
+
+func (self *Blockchain) GeneratedGetTokenBasicData(opts blockchain.CallOpts, token ethereum.Address) (bool, bool, error) {
+	var (
+		ret0 = new(bool)
+		ret1 = new(bool)
+	)
+	out := &[]interface{}{
+		ret0,
+		ret1,
+	}
+	timeOut := 2 * time.Second
+	err := self.Call(timeOut, opts, self.pricing, out, "getTokenBasicData", token)
+	return *ret0, *ret1, err
+}
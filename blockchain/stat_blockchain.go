@@ -172,6 +172,7 @@ func (sb *StatBlockchain) GetLogs(fromBlock uint64, toBlock uint64) ([]common.KN
 			result = append(result, common.SetCatLog{
 				Timestamp:       ts,
 				BlockNumber:     logItem.BlockNumber,
+				BlockHash:       logItem.BlockHash,
 				TransactionHash: logItem.TxHash,
 				Index:           logItem.Index,
 				Address:         addr,
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/cmd/configuration"
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/http"
+	"github.com/KyberNetwork/reserve-data/settings"
+	"github.com/KyberNetwork/reserve-data/stat"
+	statstorage "github.com/KyberNetwork/reserve-data/stat/storage"
+	"github.com/boltdb/bolt"
+	"github.com/spf13/cobra"
+)
+
+const boltLockTimeout = time.Second
+
+var (
+	reaggregateFrom         uint64
+	reaggregateTo           uint64
+	reaggregateAggregations []string
+	reaggregateStatDB       string
+	reaggregateLogDB        string
+	reaggregateUserDB       string
+	reaggregateCoreURL      string
+)
+
+// checkBoltUnlocked fails if the bolt database at path is opened by another
+// process, eg: the running server, instead of waiting for it forever.
+func checkBoltUnlocked(path string) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltLockTimeout, ReadOnly: true})
+	if err != nil {
+		log.Fatalf("cannot open %s (%s), if the server is running, use a copy of the database", path, err.Error())
+	}
+	if err = db.Close(); err != nil {
+		log.Fatalf("failed to close %s: %s", path, err.Error())
+	}
+}
+
+func reaggregateStat(_ *cobra.Command, _ []string) {
+	kyberENV := common.RunningMode()
+	setPath := configuration.GetConfigPaths(kyberENV)
+	if reaggregateStatDB == "" {
+		reaggregateStatDB = setPath.StatStoragePath()
+	}
+	if reaggregateLogDB == "" {
+		reaggregateLogDB = setPath.LogStoragePath()
+	}
+	if reaggregateUserDB == "" {
+		reaggregateUserDB = setPath.UserStoragePath()
+	}
+	if reaggregateTo == 0 {
+		reaggregateTo = common.GetTimepoint()
+	}
+	if reaggregateFrom >= reaggregateTo {
+		log.Fatalf("from %d must be before to %d", reaggregateFrom, reaggregateTo)
+	}
+	known := map[string]bool{}
+	for _, aggregation := range stat.TradeLogAggregations {
+		known[aggregation] = true
+	}
+	for _, aggregation := range reaggregateAggregations {
+		if !known[aggregation] {
+			log.Fatalf("unknown aggregation %s, expected one of %s", aggregation, strings.Join(stat.TradeLogAggregations, ", "))
+		}
+	}
+
+	for _, path := range []string{reaggregateStatDB, reaggregateLogDB, reaggregateUserDB} {
+		checkBoltUnlocked(path)
+	}
+	statStorage, err := statstorage.NewBoltStatStorage(reaggregateStatDB)
+	if err != nil {
+		log.Fatalf("failed to open stat storage %s: %s", reaggregateStatDB, err.Error())
+	}
+	logStorage, err := statstorage.NewBoltLogStorage(reaggregateLogDB)
+	if err != nil {
+		log.Fatalf("failed to open log storage %s: %s", reaggregateLogDB, err.Error())
+	}
+	userStorage, err := statstorage.NewBoltUserStorage(reaggregateUserDB)
+	if err != nil {
+		log.Fatalf("failed to open user storage %s: %s", reaggregateUserDB, err.Error())
+	}
+	settingClient := settings.NewSettingClient(http.NewKNAuthenticationFromFile(setPath.SecretPath()), defaultTimeOut, reaggregateCoreURL)
+
+	log.Printf("re-aggregating %s from %d to %d into %s",
+		strings.Join(reaggregateAggregations, ", "), reaggregateFrom, reaggregateTo, reaggregateStatDB)
+	reaggregator := stat.NewReaggregator(statStorage, logStorage, userStorage, settingClient)
+	// trade logs and stats are in nanoseconds
+	if err = reaggregator.Reaggregate(reaggregateAggregations, reaggregateFrom*1000000, reaggregateTo*1000000); err != nil {
+		log.Fatalf("re-aggregation failed: %s", err.Error())
+	}
+	log.Printf("re-aggregation finished")
+}
+
+var reaggregateStatCmd = &cobra.Command{
+	Use:   "reaggregate-stat",
+	Short: "rebuild aggregated stats of a time range from stored trade logs",
+	Long: `Delete the selected aggregated stats (burn fee, volume, trade summary,
+wallet, country and user info) of the time buckets starting in the time range
+and aggregate them again from the stored trade logs, eg: after fixing trade
+info computation or adding a token. Only trade logs already processed by the
+server are aggregated. Tokens are looked up from core at --core-url.
+
+Bolt databases are locked while the server is running, to rebuild stats of a
+live server, run the command on copies of the stat, log and user databases
+then replace the stat database while the server is stopped, the trade logs
+processed after the copy are aggregated again on restart.`,
+	Example: "KYBER_ENV=dev ./cmd reaggregate-stat --from 1539216000000 --to 1539302400000 --aggregations volume_stat_aggregation --stat-db /tmp/stats.db --log-db /tmp/logs.db --user-db /tmp/users.db",
+	Run:     reaggregateStat,
+}
+
+func init() {
+	reaggregateStatCmd.Flags().Uint64Var(&reaggregateFrom, "from", 0, "start of the time range in milliseconds, align it to days to rebuild daily stats entirely")
+	reaggregateStatCmd.Flags().Uint64Var(&reaggregateTo, "to", 0, "end of the time range in milliseconds (exclusive), default to now")
+	reaggregateStatCmd.Flags().StringSliceVar(&reaggregateAggregations, "aggregations", stat.TradeLogAggregations, "aggregations to rebuild")
+	reaggregateStatCmd.Flags().StringVar(&reaggregateStatDB, "stat-db", "", "path of stat bolt database file, default to configuration of KYBER_ENV")
+	reaggregateStatCmd.Flags().StringVar(&reaggregateLogDB, "log-db", "", "path of log bolt database file, default to configuration of KYBER_ENV")
+	reaggregateStatCmd.Flags().StringVar(&reaggregateUserDB, "user-db", "", "path of user bolt database file, default to configuration of KYBER_ENV")
+	reaggregateStatCmd.Flags().StringVar(&reaggregateCoreURL, "core-url", coreDefaultURL, "core url from which tokens setting is requested")
+	RootCmd.AddCommand(reaggregateStatCmd)
+}
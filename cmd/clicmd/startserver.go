@@ -5,11 +5,15 @@ import (
 	"log"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/KyberNetwork/reserve-data"
 	"github.com/KyberNetwork/reserve-data/blockchain"
 	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/core"
 	"github.com/KyberNetwork/reserve-data/http"
+	"github.com/KyberNetwork/reserve-data/rateengine"
+	"github.com/KyberNetwork/reserve-data/watchdog"
 	"github.com/spf13/cobra"
 )
 
@@ -30,6 +34,18 @@ var noCore bool
 var stdoutLog bool
 var dryrun bool
 var coreURL string
+var enableRateEngine bool
+var rateEngineInterval time.Duration
+var rateEngineMaxDataAge time.Duration
+var maxGasPrice float64
+var enableRebalancer bool
+var rebalancerInterval time.Duration
+var rebalancerDryRun bool
+var rebalancerMaxDataAge time.Duration
+var enableRateWatchdog bool
+var rateWatchdogInterval time.Duration
+var rateWatchdogAlertBlocks uint64
+var rateWatchdogMaxSetRateAge time.Duration
 
 func serverStart(_ *cobra.Command, _ []string) {
 	numCPU := runtime.NumCPU()
@@ -49,6 +65,8 @@ func serverStart(_ *cobra.Command, _ []string) {
 		rCore reserve.ReserveCore
 		rStat reserve.ReserveStats
 		bc    *blockchain.Blockchain
+		rEng  *rateengine.Engine
+		wd    *watchdog.Watchdog
 	)
 	//Create Data and Core, run if not in dry mode
 	if !noCore {
@@ -69,6 +87,30 @@ func serverStart(_ *cobra.Command, _ []string) {
 				log.Panic(err)
 			}
 		}
+		if enableRateEngine {
+			rEng = CreateRateEngine(config, rCore)
+			if !dryrun && rateEngineInterval > 0 {
+				if err = rEng.Run(); err != nil {
+					log.Panic(err)
+				}
+			}
+		}
+		if enableRebalancer {
+			rBal := CreateRebalancer(config, rCore)
+			if !dryrun {
+				if err = rBal.Run(); err != nil {
+					log.Panic(err)
+				}
+			}
+		}
+		if enableRateWatchdog {
+			wd = CreateRateWatchdog(config, bc)
+			if !dryrun {
+				if err = wd.Run(); err != nil {
+					log.Panic(err)
+				}
+			}
+		}
 		//set static field supportExchange from common...
 		for _, ex := range config.Exchanges {
 			common.SupportedExchanges[ex.ID()] = ex
@@ -105,6 +147,22 @@ func serverStart(_ *cobra.Command, _ []string) {
 		kyberENV,
 		bc, config.Setting,
 	)
+	if rEng != nil {
+		server.SetRateEngine(rEng)
+	}
+	if wd != nil {
+		server.SetRateWatchdog(wd)
+	}
+	if rCore != nil {
+		limiters := []http.RateLimiter{}
+		for _, limiter := range config.RateLimiters {
+			limiters = append(limiters, limiter)
+		}
+		server.SetRateLimiters(limiters)
+	}
+	if rStat != nil {
+		server.SetPnL(CreatePnL(config, rData))
+	}
 
 	if !dryrun {
 		server.Run()
@@ -133,5 +191,17 @@ func init() {
 	startServer.Flags().BoolVarP(&stdoutLog, "log-to-stdout", "", false, "send log to both log file and stdout terminal")
 	startServer.Flags().BoolVarP(&dryrun, "dryrun", "", false, "only test if all the configs are set correctly, will not actually run core")
 	startServer.Flags().StringVar(&coreURL, "core-url", coreDefaultURL, "core url from which stat can call for setting APis")
+	startServer.Flags().BoolVarP(&enableRateEngine, "enable-rate-engine", "", false, "enable rate engine API computing rates from PWI equations and order books")
+	startServer.Flags().DurationVar(&rateEngineInterval, "rate-engine-interval", 0, "interval to set rates computed by rate engine, 0 to only compute rates via API")
+	startServer.Flags().DurationVar(&rateEngineMaxDataAge, "rate-engine-max-data-age", time.Minute, "max age of order books and balances rate engine computes rates from")
+	startServer.Flags().Float64Var(&maxGasPrice, "max-gas-price", core.DefaultMaxGasPrice, "max gas price in gwei of set rate txs, pending set rate txs are replaced with higher gas price up to it")
+	startServer.Flags().BoolVarP(&enableRebalancer, "enable-rebalancer", "", false, "enable rebalancer moving balances between reserve and exchanges toward target quantities")
+	startServer.Flags().DurationVar(&rebalancerInterval, "rebalancer-interval", 5*time.Minute, "interval to rebalance")
+	startServer.Flags().BoolVarP(&rebalancerDryRun, "rebalancer-dry-run", "", false, "only log the rebalance plan, will not deposit, withdraw or trade")
+	startServer.Flags().DurationVar(&rebalancerMaxDataAge, "rebalancer-max-data-age", time.Minute, "max age of order books and balances rebalancer plans from")
+	startServer.Flags().BoolVarP(&enableRateWatchdog, "enable-rate-watchdog", "", false, "enable watchdog alerting before the rates in pricing contract expire")
+	startServer.Flags().DurationVar(&rateWatchdogInterval, "rate-watchdog-interval", time.Minute, "interval to check the rates in pricing contract")
+	startServer.Flags().Uint64Var(&rateWatchdogAlertBlocks, "rate-watchdog-alert-blocks", 20, "number of blocks before the rates expire to alert")
+	startServer.Flags().DurationVar(&rateWatchdogMaxSetRateAge, "rate-watchdog-max-setrate-age", 10*time.Minute, "longest time since the last mined set rates before alerting, at most 24h")
 	RootCmd.AddCommand(startServer)
 }
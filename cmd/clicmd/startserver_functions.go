@@ -18,8 +18,12 @@ import (
 	"github.com/KyberNetwork/reserve-data/core"
 	"github.com/KyberNetwork/reserve-data/data"
 	"github.com/KyberNetwork/reserve-data/data/fetcher"
+	"github.com/KyberNetwork/reserve-data/pnl"
+	"github.com/KyberNetwork/reserve-data/rateengine"
+	"github.com/KyberNetwork/reserve-data/rebalancer"
 	"github.com/KyberNetwork/reserve-data/settings"
 	"github.com/KyberNetwork/reserve-data/stat"
+	"github.com/KyberNetwork/reserve-data/watchdog"
 	ethereum "github.com/ethereum/go-ethereum/common"
 	"github.com/robfig/cron"
 	lumberjack "gopkg.in/natefinch/lumberjack.v2"
@@ -199,6 +203,9 @@ func CreateDataCore(config *configuration.Config, kyberENV string, bc *blockchai
 	nonceDeposit := nonce.NewTimeWindow(config.DepositSigner.GetAddress(), 10000)
 	bc.RegisterPricingOperator(config.BlockchainSigner, nonceCorpus)
 	bc.RegisterDepositOperator(config.DepositSigner, nonceDeposit)
+	if config.AlerterSigner != nil {
+		bc.RegisterAlerterOperator(config.AlerterSigner, nonce.NewTimeWindow(config.AlerterSigner.GetAddress(), 2000))
+	}
 	dataFetcher.SetBlockchain(bc)
 	rData := data.NewReserveData(
 		config.DataStorage,
@@ -211,6 +218,11 @@ func CreateDataCore(config *configuration.Config, kyberENV string, bc *blockchai
 	)
 
 	rCore := core.NewReserveCore(bc, config.ActivityStorage, config.Setting)
+	if maxGasPrice <= 0 {
+		log.Panicf("Max gas price %f must be positive", maxGasPrice)
+	}
+	rCore.SetMaxGasPrice(maxGasPrice)
+	rCore.EnableRateGuard(config.DataStorage, config.MetricStorage)
 	return rData, rCore
 }
 
@@ -238,6 +250,12 @@ func CreateStat(config *configuration.Config, kyberENV string, bc *blockchain.St
 		config.IPlocator,
 	)
 	statFetcher.SetBlockchain(bc)
+	statFetcher.SetGeoSource(config.GeoSource)
+	for _, sink := range config.LogSinks {
+		if err := statFetcher.AddLogSink(sink); err != nil {
+			log.Panicf("Failed to add log sink %s: %s", sink.Name(), err.Error())
+		}
+	}
 	rStat := stat.NewReserveStats(
 		config.AnalyticStorage,
 		config.StatStorage,
@@ -253,3 +271,56 @@ func CreateStat(config *configuration.Config, kyberENV string, bc *blockchain.St
 	)
 	return rStat
 }
+
+// CreateRateEngine creates the rate engine, it ticks every rateEngineInterval
+// if the interval is set and rejects data older than rateEngineMaxDataAge.
+func CreateRateEngine(config *configuration.Config, rCore rateengine.RateSetter) *rateengine.Engine {
+	var runner rateengine.Runner
+	if rateEngineInterval > 0 {
+		runner = rateengine.NewTickerRunner(rateEngineInterval)
+	}
+	if rateEngineMaxDataAge <= 0 {
+		log.Panicf("Rate engine max data age %s must be positive", rateEngineMaxDataAge)
+	}
+	return rateengine.NewEngine(config.DataStorage, config.MetricStorage, config.Setting, rCore, runner, rateEngineMaxDataAge)
+}
+
+// CreateRebalancer creates the rebalancer, it ticks every rebalancerInterval,
+// only logs the plan if rebalancerDryRun is set and rejects data older than
+// rebalancerMaxDataAge.
+func CreateRebalancer(config *configuration.Config, rCore rebalancer.Core) *rebalancer.Rebalancer {
+	runner := rebalancer.NewTickerRunner(rebalancerInterval)
+	if rebalancerMaxDataAge <= 0 {
+		log.Panicf("Rebalancer max data age %s must be positive", rebalancerMaxDataAge)
+	}
+	return rebalancer.NewRebalancer(config.DataStorage, config.MetricStorage, config.Setting, config.FetcherStorage, rCore,
+		config.Exchanges, runner, rebalancerDryRun, rebalancerMaxDataAge)
+}
+
+// CreateRateWatchdog creates the watchdog of the rates in pricing contract,
+// it checks every rateWatchdogInterval.
+func CreateRateWatchdog(config *configuration.Config, bc *blockchain.Blockchain) *watchdog.Watchdog {
+	runner := watchdog.NewTickerRunner(rateWatchdogInterval)
+	wd, err := watchdog.NewWatchdog(bc, config.DataStorage, config.Setting, config.WatchdogAlerters, runner,
+		watchdog.Config{AlertBlocks: rateWatchdogAlertBlocks, MaxSetRateAge: rateWatchdogMaxSetRateAge})
+	if err != nil {
+		log.Panicf("Can not create rate watchdog: %s", err)
+	}
+	return wd
+}
+
+// CreatePnL creates the P&L calculator of the reserve from the stat logs and
+// the core activities, it requires stat. Without core (rData is nil), the
+// activities, exchange trades, tokens and exchange fees are requested from
+// core at coreURL.
+func CreatePnL(config *configuration.Config, rData pnl.ReserveData) *pnl.Calculator {
+	reserveAddr, err := config.Setting.GetAddress(settings.Reserve)
+	if err != nil {
+		log.Panicf("Can not get reserve address: %s", err)
+	}
+	if rData == nil {
+		client := pnl.NewCoreClient(settings.NewSettingClient(config.AuthEngine, defaultTimeOut, coreURL))
+		return pnl.NewCalculator(config.LogStorage, config.FeeSetRateStorage, client, client, reserveAddr)
+	}
+	return pnl.NewCalculator(config.LogStorage, config.FeeSetRateStorage, rData, config.Setting, reserveAddr)
+}
@@ -2,6 +2,7 @@ package configuration
 
 import (
 	"log"
+	"math/big"
 	"path/filepath"
 	"time"
 
@@ -13,10 +14,11 @@ import (
 	"github.com/KyberNetwork/reserve-data/data/datapruner"
 	"github.com/KyberNetwork/reserve-data/data/fetcher"
 	"github.com/KyberNetwork/reserve-data/data/fetcher/http_runner"
-	"github.com/KyberNetwork/reserve-data/data/storage"
 	"github.com/KyberNetwork/reserve-data/exchange/binance"
 	"github.com/KyberNetwork/reserve-data/exchange/bittrex"
 	"github.com/KyberNetwork/reserve-data/exchange/huobi"
+	"github.com/KyberNetwork/reserve-data/exchange/kucoin"
+	"github.com/KyberNetwork/reserve-data/exchange/ratelimit"
 	"github.com/KyberNetwork/reserve-data/http"
 	"github.com/KyberNetwork/reserve-data/metric"
 	"github.com/KyberNetwork/reserve-data/settings"
@@ -24,6 +26,7 @@ import (
 	"github.com/KyberNetwork/reserve-data/stat/statpruner"
 	statstorage "github.com/KyberNetwork/reserve-data/stat/storage"
 	statutil "github.com/KyberNetwork/reserve-data/stat/util"
+	"github.com/KyberNetwork/reserve-data/watchdog"
 	"github.com/KyberNetwork/reserve-data/world"
 )
 
@@ -100,12 +103,15 @@ type Config struct {
 	StatControllerRunner statpruner.ControllerRunner
 	FetcherExchanges     []fetcher.Exchange
 	Exchanges            []common.Exchange
+	RateLimiters         []*ratelimit.Limiter
 	BlockchainSigner     blockchain.Signer
 	DepositSigner        blockchain.Signer
+	AlerterSigner        blockchain.Signer
 	//IntermediatorSigner blockchain.Signer
 
 	EnableAuthentication bool
 	AuthEngine           http.Authentication
+	APIKeyStorage        http.APIKeyStorage
 
 	EthereumEndpoint        string
 	BackupEthereumEndpoints []string
@@ -115,9 +121,14 @@ type Config struct {
 	EtherscanApiKey string
 
 	ChainType      string
+	ChainID        *big.Int
 	Setting        *settings.Settings
 	IPlocator      *statutil.IPLocator
 	AddressSetting *settings.AddressSetting
+	LogSinks       []stat.LogSink
+	GeoSource      stat.TxGeoSource
+	// WatchdogAlerters receive the alerts of the rate watchdog.
+	WatchdogAlerters []watchdog.Alerter
 }
 
 // GetStatConfig: load config to run stat server only
@@ -174,6 +185,14 @@ func (self *Config) AddStatConfig(settingPath SettingPaths) {
 	}
 
 	apiKey := GetEtherscanAPIKey(settingPath.secretPath)
+	logSinks, err := GetLogSinks(settingPath.secretPath)
+	if err != nil {
+		log.Panicf("Failed to create log sinks: %s", err.Error())
+	}
+	geoSource, err := GetGeoSource(settingPath.secretPath)
+	if err != nil {
+		log.Panicf("Failed to create geo source: %s", err.Error())
+	}
 
 	self.StatStorage = statStorage
 	self.AnalyticStorage = analyticStorage
@@ -185,15 +204,21 @@ func (self *Config) AddStatConfig(settingPath SettingPaths) {
 	self.StatFetcherRunner = statFetcherRunner
 	self.EtherscanApiKey = apiKey
 	self.IPlocator = ipLocator
+	self.LogSinks = logSinks
+	self.GeoSource = geoSource
 }
 
 func (self *Config) AddCoreConfig(settingPath SettingPaths, kyberENV string) {
+	genericExchanges, err := RegisterGenericExchanges(settingPath.secretPath)
+	if err != nil {
+		log.Panicf("Failed to register generic exchanges: %s", err.Error())
+	}
 	setting, err := GetSetting(settingPath, kyberENV, self.AddressSetting)
 	if err != nil {
 		log.Panicf("Failed to create setting: %s", err.Error())
 	}
 	self.Setting = setting
-	dataStorage, err := storage.NewBoltStorage(settingPath.dataStoragePath)
+	dataStorage, err := NewDataStorageFromConfigFile(settingPath)
 	if err != nil {
 		panic(err)
 	}
@@ -215,8 +240,13 @@ func (self *Config) AddCoreConfig(settingPath SettingPaths, kyberENV string) {
 		dataControllerRunner = datapruner.NewStorageControllerTickerRunner(24 * time.Hour)
 	}
 
-	pricingSigner := PricingSignerFromConfigFile(settingPath.secretPath)
-	depositSigner := DepositSignerFromConfigFile(settingPath.secretPath)
+	pricingSigner := PricingSignerFromConfigFile(settingPath.secretPath, self.ChainID)
+	depositSigner := DepositSignerFromConfigFile(settingPath.secretPath, self.ChainID)
+	alerterSigner := AlerterSignerFromConfigFile(settingPath.secretPath, self.ChainID)
+	watchdogAlerters, err := GetWatchdogAlerters(settingPath.secretPath)
+	if err != nil {
+		log.Panicf("Failed to create watchdog alerters: %s", err.Error())
+	}
 
 	self.ActivityStorage = dataStorage
 	self.DataStorage = dataStorage
@@ -224,11 +254,14 @@ func (self *Config) AddCoreConfig(settingPath SettingPaths, kyberENV string) {
 	self.FetcherStorage = dataStorage
 	self.FetcherGlobalStorage = dataStorage
 	self.MetricStorage = dataStorage
+	self.APIKeyStorage = dataStorage
 	self.FetcherRunner = fetcherRunner
 	self.DataControllerRunner = dataControllerRunner
 	self.BlockchainSigner = pricingSigner
 	//self.IntermediatorSigner = huoBiintermediatorSigner
 	self.DepositSigner = depositSigner
+	self.AlerterSigner = alerterSigner
+	self.WatchdogAlerters = watchdogAlerters
 	//self.ExchangeStorage = exsStorage
 	// var huobiConfig common.HuobiConfig
 	// exchangesIDs := os.Getenv("KYBER_EXCHANGES")
@@ -242,6 +275,7 @@ func (self *Config) AddCoreConfig(settingPath SettingPaths, kyberENV string) {
 		self.Blockchain,
 		kyberENV,
 		self.Setting,
+		genericExchanges,
 	)
 	if err != nil {
 		log.Panicf("Can not create exchangePool: %s", err.Error())
@@ -256,6 +290,7 @@ func (self *Config) AddCoreConfig(settingPath SettingPaths, kyberENV string) {
 		log.Panicf("cannot Create core exchanges : (%s)", err.Error())
 	}
 	self.Exchanges = coreExchanges
+	self.RateLimiters = exchangePool.RateLimiters
 }
 
 var ConfigPaths = map[string]SettingPaths{
@@ -404,6 +439,7 @@ var ConfigPaths = map[string]SettingPaths{
 var BinanceInterfaces = make(map[string]binance.Interface)
 var HuobiInterfaces = make(map[string]huobi.Interface)
 var BittrexInterfaces = make(map[string]bittrex.Interface)
+var KucoinInterfaces = make(map[string]kucoin.Interface)
 
 func SetInterface(base_url string) {
 	BittrexInterfaces[common.DevMode] = bittrex.NewDevInterface()
@@ -429,4 +465,12 @@ func SetInterface(base_url string) {
 	BinanceInterfaces[common.SimulationMode] = binance.NewSimulatedInterface(base_url)
 	BinanceInterfaces[common.RopstenMode] = binance.NewRopstenInterface(base_url)
 	BinanceInterfaces[common.AnalyticDevMode] = binance.NewRopstenInterface(base_url)
+
+	KucoinInterfaces[common.DevMode] = kucoin.NewDevInterface()
+	KucoinInterfaces[common.KovanMode] = kucoin.NewKovanInterface(base_url)
+	KucoinInterfaces[common.MainnetMode] = kucoin.NewRealInterface()
+	KucoinInterfaces[common.StagingMode] = kucoin.NewRealInterface()
+	KucoinInterfaces[common.SimulationMode] = kucoin.NewSimulatedInterface(base_url)
+	KucoinInterfaces[common.RopstenMode] = kucoin.NewRopstenInterface(base_url)
+	KucoinInterfaces[common.AnalyticDevMode] = kucoin.NewRopstenInterface(base_url)
 }
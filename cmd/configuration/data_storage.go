@@ -0,0 +1,109 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/core"
+	"github.com/KyberNetwork/reserve-data/data"
+	"github.com/KyberNetwork/reserve-data/data/fetcher"
+	"github.com/KyberNetwork/reserve-data/data/storage"
+	"github.com/KyberNetwork/reserve-data/http"
+	"github.com/KyberNetwork/reserve-data/metric"
+)
+
+const (
+	// boltDataStorageDriver stores core data in a bolt database file, it is
+	// the default driver.
+	boltDataStorageDriver = "bolt"
+	// postgresDataStorageDriver stores core data in a PostgreSQL database.
+	postgresDataStorageDriver = "postgres"
+)
+
+// DataStorage is the storage of all core data, it is implemented by every
+// data storage driver.
+type DataStorage interface {
+	core.ActivityStorage
+	data.Storage
+	data.GlobalStorage
+	fetcher.GlobalStorage
+	metric.MetricStorage
+	http.APIKeyStorage
+
+	StorePrice(data common.AllPriceEntry, timepoint uint64) error
+	StoreRate(data common.AllRateEntry, timepoint uint64) error
+	StoreAuthSnapshot(data *common.AuthDataSnapshot, timepoint uint64) error
+	UpdateActivity(id common.ActivityID, act common.ActivityRecord) error
+}
+
+type jsonDataStorageDetail struct {
+	Driver      string `json:"data_storage_driver"`
+	PostgresDSN string `json:"postgres_dsn"`
+}
+
+// GetPostgresDSN returns the data source name of core PostgreSQL database in
+// the secret config file.
+func GetPostgresDSN(secretPath string) (string, error) {
+	detail, err := dataStorageDetailFromFile(secretPath)
+	if err != nil {
+		return "", err
+	}
+	return detail.PostgresDSN, nil
+}
+
+func dataStorageDetailFromFile(secretPath string) (jsonDataStorageDetail, error) {
+	detail := jsonDataStorageDetail{}
+	raw, err := ioutil.ReadFile(secretPath)
+	if err != nil {
+		return detail, err
+	}
+	err = json.Unmarshal(raw, &detail)
+	return detail, err
+}
+
+// NewDataStorageFromConfigFile creates the core data storage with driver
+// configured in secret config file, bolt storage at dataStoragePath is used
+// if no driver is configured.
+func NewDataStorageFromConfigFile(settingPath SettingPaths) (DataStorage, error) {
+	detail, err := dataStorageDetailFromFile(settingPath.secretPath)
+	if err != nil {
+		return nil, err
+	}
+	switch detail.Driver {
+	case "", boltDataStorageDriver:
+		return storage.NewBoltStorage(settingPath.dataStoragePath)
+	case postgresDataStorageDriver:
+		log.Printf("using postgres as data storage")
+		return storage.NewPostgresStorage(detail.PostgresDSN)
+	default:
+		return nil, fmt.Errorf("unknown data storage driver: %s", detail.Driver)
+	}
+}
+
+// DataStoragePath returns the path of core bolt database file.
+func (self SettingPaths) DataStoragePath() string {
+	return self.dataStoragePath
+}
+
+// StatStoragePath returns the path of stat bolt database file.
+func (self SettingPaths) StatStoragePath() string {
+	return self.statStoragePath
+}
+
+// LogStoragePath returns the path of log bolt database file.
+func (self SettingPaths) LogStoragePath() string {
+	return self.logStoragePath
+}
+
+// UserStoragePath returns the path of user bolt database file.
+func (self SettingPaths) UserStoragePath() string {
+	return self.userStoragePath
+}
+
+// SecretPath returns the path of secret config file.
+func (self SettingPaths) SecretPath() string {
+	return self.secretPath
+}
@@ -0,0 +1,48 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/KyberNetwork/reserve-data/exchange/generic"
+	"github.com/KyberNetwork/reserve-data/settings"
+)
+
+// GenericExchangesConfig is the config driven exchanges in secret config
+// file, see generic.Config for the description of an exchange.
+type GenericExchangesConfig struct {
+	GenericExchanges []generic.Config `json:"generic_exchanges"`
+}
+
+// GenericExchange is a config driven exchange registered in settings.
+type GenericExchange struct {
+	Config generic.Config
+	Name   settings.ExchangeName
+}
+
+// RegisterGenericExchanges reads the config driven exchanges in secret config
+// file at path and registers them in settings, it must be called before
+// settings are loaded. The exchanges are returned by name.
+func RegisterGenericExchanges(path string) (map[string]GenericExchange, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := GenericExchangesConfig{}
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	exchanges := map[string]GenericExchange{}
+	for _, config := range result.GenericExchanges {
+		if err = config.Validate(); err != nil {
+			return nil, err
+		}
+		name, rErr := settings.RegisterExchange(config.Name)
+		if rErr != nil {
+			return nil, fmt.Errorf("Can not register exchange %s: %s", config.Name, rErr)
+		}
+		exchanges[config.Name] = GenericExchange{Config: config, Name: name}
+	}
+	return exchanges, nil
+}
@@ -0,0 +1,59 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/stat"
+	"github.com/KyberNetwork/reserve-data/stat/geo"
+)
+
+const geoSourceTimeout = 5 * time.Second
+
+// GeoSourceConfig is the source of trade logs geo in secret config file, eg:
+//
+//	{"type": "broadcast", "url": "https://broadcast.kyber.network"}
+//	{"type": "file", "path": "/data/tx_ips.json"}
+//	{"type": "none"}
+type GeoSourceConfig struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	Path string `json:"path"`
+}
+
+type GeoSourceConfigFile struct {
+	GeoSource *GeoSourceConfig `json:"geo_source"`
+}
+
+// GetGeoSource returns the source of trade logs geo configured in secret
+// config file at path, the broadcast API by default. It returns nil if trade
+// logs are not enriched.
+func GetGeoSource(path string) (stat.TxGeoSource, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := GeoSourceConfigFile{}
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	config := GeoSourceConfig{Type: "broadcast"}
+	if result.GeoSource != nil {
+		config = *result.GeoSource
+	}
+	switch config.Type {
+	case "broadcast":
+		if config.URL == "" {
+			config.URL = geo.DefaultBroadcastEndpoint
+		}
+		return geo.NewBroadcastSource(config.URL, geoSourceTimeout), nil
+	case "file":
+		return geo.NewFileSource(config.Path)
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Unknown geo source type %s", config.Type)
+	}
+}
@@ -2,6 +2,7 @@ package configuration
 
 import (
 	"log"
+	"math/big"
 	"path/filepath"
 
 	"github.com/KyberNetwork/reserve-data/common"
@@ -53,6 +54,21 @@ func GetChainType(kyberENV string) string {
 	}
 }
 
+// GetChainID returns the chain ID of the network of kyberENV, nil if the
+// chain ID of the node should be used.
+func GetChainID(kyberENV string) *big.Int {
+	switch kyberENV {
+	case common.MainnetMode, common.ProductionMode, common.StagingMode:
+		return big.NewInt(1)
+	case common.RopstenMode:
+		return big.NewInt(3)
+	case common.KovanMode:
+		return big.NewInt(42)
+	default:
+		return nil
+	}
+}
+
 func GetConfigPaths(kyberENV string) SettingPaths {
 	// common.ProductionMode and common.MainnetMode are same thing.
 	if kyberENV == common.ProductionMode {
@@ -134,13 +150,17 @@ func GetConfig(kyberENV string, authEnbl bool, endpointOW string, noCore, enable
 		}
 	}
 
-	blockchain := blockchain.NewBaseBlockchain(
+	blockchain, err := blockchain.NewBaseBlockchain(
 		client, infura, map[string]*blockchain.Operator{},
 		blockchain.NewBroadcaster(bkclients),
 		blockchain.NewCMCEthUSDRate(),
 		chainType,
+		GetChainID(kyberENV),
 		blockchain.NewContractCaller(callClients, setPath.bkendpoints),
 	)
+	if err != nil {
+		log.Panicf("cannot init blockchain: %s", err)
+	}
 
 	if !authEnbl {
 		log.Printf("\nWARNING: No authentication mode\n")
@@ -155,6 +175,7 @@ func GetConfig(kyberENV string, authEnbl bool, endpointOW string, noCore, enable
 		EthereumEndpoint:        endpoint,
 		BackupEthereumEndpoints: bkendpoints,
 		ChainType:               chainType,
+		ChainID:                 blockchain.ChainID(),
 		AuthEngine:              hmac512auth,
 		EnableAuthentication:    authEnbl,
 		Archive:                 s3archive,
@@ -167,6 +188,9 @@ func GetConfig(kyberENV string, authEnbl bool, endpointOW string, noCore, enable
 	}
 	if !noCore {
 		config.AddCoreConfig(setPath, kyberENV)
+		if err = hmac512auth.SetAPIKeyStorage(config.APIKeyStorage); err != nil {
+			log.Panicf("failed to load API keys: %s", err)
+		}
 	}
 	return config
 }
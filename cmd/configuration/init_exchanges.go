@@ -6,6 +6,7 @@ import (
 	"log"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/common/blockchain"
@@ -14,12 +15,22 @@ import (
 	"github.com/KyberNetwork/reserve-data/exchange"
 	"github.com/KyberNetwork/reserve-data/exchange/binance"
 	"github.com/KyberNetwork/reserve-data/exchange/bittrex"
+	"github.com/KyberNetwork/reserve-data/exchange/generic"
 	"github.com/KyberNetwork/reserve-data/exchange/huobi"
+	"github.com/KyberNetwork/reserve-data/exchange/kucoin"
+	"github.com/KyberNetwork/reserve-data/exchange/ratelimit"
 	"github.com/KyberNetwork/reserve-data/settings"
 )
 
+// binanceDepthMaxAge is the longest time a Binance local order book is valid
+// without updates.
+const binanceDepthMaxAge = 2 * time.Minute
+
 type ExchangePool struct {
 	Exchanges map[common.ExchangeID]interface{}
+	// RateLimiters are the request limiters of the exchanges, they are shared
+	// by the fetchers and core.
+	RateLimiters []*ratelimit.Limiter
 }
 
 func AsyncUpdateDepositAddress(ex common.Exchange, tokenID, addr string, wait *sync.WaitGroup, setting *settings.Settings) {
@@ -57,11 +68,21 @@ func getHuobiInterface(kyberENV string) huobi.Interface {
 	return envInterface
 }
 
+func getKucoinInterface(kyberENV string) kucoin.Interface {
+	envInterface, ok := KucoinInterfaces[kyberENV]
+	if !ok {
+		envInterface = KucoinInterfaces[common.DevMode]
+	}
+	return envInterface
+}
+
 func NewExchangePool(
 	settingPaths SettingPaths,
 	blockchain *blockchain.BaseBlockchain,
-	kyberENV string, setting *settings.Settings) (*ExchangePool, error) {
+	kyberENV string, setting *settings.Settings,
+	genericExchanges map[string]GenericExchange) (*ExchangePool, error) {
 	exchanges := map[common.ExchangeID]interface{}{}
+	limiters := []*ratelimit.Limiter{}
 	exparams := settings.RunningExchanges()
 	for _, exparam := range exparams {
 		switch exparam {
@@ -75,7 +96,9 @@ func NewExchangePool(
 			exchanges[stableEx.ID()] = stableEx
 		case "bittrex":
 			bittrexSigner := bittrex.NewSignerFromFile(settingPaths.secretPath)
-			endpoint := bittrex.NewBittrexEndpoint(bittrexSigner, getBittrexInterface(kyberENV))
+			limiter := bittrex.NewRateLimiter()
+			limiters = append(limiters, limiter)
+			endpoint := bittrex.NewBittrexEndpoint(bittrexSigner, getBittrexInterface(kyberENV), limiter)
 			bittrexStorage, err := bittrex.NewBoltStorage(filepath.Join(common.CmdDirLocation(), "bittrex.db"))
 			if err != nil {
 				return nil, fmt.Errorf("Can not create Bittrex storage: (%s)", err.Error())
@@ -104,7 +127,9 @@ func NewExchangePool(
 			exchanges[bit.ID()] = bit
 		case "binance":
 			binanceSigner := binance.NewSignerFromFile(settingPaths.secretPath)
-			endpoint := binance.NewBinanceEndpoint(binanceSigner, getBinanceInterface(kyberENV))
+			limiter := binance.NewRateLimiter()
+			limiters = append(limiters, limiter)
+			endpoint := binance.NewBinanceEndpoint(binanceSigner, getBinanceInterface(kyberENV), limiter)
 			storage, err := binance.NewBoltStorage(filepath.Join(common.CmdDirLocation(), "binance.db"))
 			if err != nil {
 				return nil, fmt.Errorf("Can not create Binance storage: (%s)", err.Error())
@@ -116,6 +141,9 @@ func NewExchangePool(
 			if err != nil {
 				return nil, fmt.Errorf("Can not create exchange Binance: (%s)", err.Error())
 			}
+			if streamURL := getBinanceInterface(kyberENV).StreamEndpoint(); streamURL != "" {
+				bin.SetDepthStream(binance.NewDepthStream(streamURL, endpoint.GetDepthSnapshot, binanceDepthMaxAge))
+			}
 			addrs, err := setting.GetDepositAddresses(settings.Binance)
 			if err != nil {
 				log.Printf("INFO: Can't get Binance Deposit Addresses from Storage (%s)", err.Error())
@@ -133,12 +161,14 @@ func NewExchangePool(
 			exchanges[bin.ID()] = bin
 		case "huobi":
 			huobiSigner := huobi.NewSignerFromFile(settingPaths.secretPath)
-			endpoint := huobi.NewHuobiEndpoint(huobiSigner, getHuobiInterface(kyberENV))
+			limiter := huobi.NewRateLimiter()
+			limiters = append(limiters, limiter)
+			endpoint := huobi.NewHuobiEndpoint(huobiSigner, getHuobiInterface(kyberENV), limiter)
 			storage, err := huobi.NewBoltStorage(filepath.Join(common.CmdDirLocation(), "huobi.db"))
 			if err != nil {
 				return nil, fmt.Errorf("Can not create Huobi storage: (%s)", err.Error())
 			}
-			intermediatorSigner := HuobiIntermediatorSignerFromFile(settingPaths.secretPath)
+			intermediatorSigner := HuobiIntermediatorSignerFromFile(settingPaths.secretPath, blockchain.ChainID())
 			intermediatorNonce := nonce.NewTimeWindow(intermediatorSigner.GetAddress(), 10000)
 			huobi, err := exchange.NewHuobi(
 				endpoint,
@@ -166,9 +196,70 @@ func NewExchangePool(
 				return nil, fmt.Errorf("Can not Update Huobi Pairs Precision: (%s)", err.Error())
 			}
 			exchanges[huobi.ID()] = huobi
+		case "kucoin":
+			kucoinSigner := kucoin.NewSignerFromFile(settingPaths.secretPath)
+			endpoint := kucoin.NewKucoinEndpoint(kucoinSigner, getKucoinInterface(kyberENV))
+			storage, err := kucoin.NewBoltStorage(filepath.Join(common.CmdDirLocation(), "kucoin.db"))
+			if err != nil {
+				return nil, fmt.Errorf("Can not create KuCoin storage: (%s)", err.Error())
+			}
+			kuc, err := exchange.NewKucoin(
+				endpoint,
+				storage,
+				setting)
+			if err != nil {
+				return nil, fmt.Errorf("Can not create exchange KuCoin: (%s)", err.Error())
+			}
+			addrs, err := setting.GetDepositAddresses(settings.Kucoin)
+			if err != nil {
+				log.Printf("INFO: Can't get KuCoin Deposit Addresses from Storage (%s)", err.Error())
+				addrs = make(common.ExchangeAddresses)
+			}
+			wait := sync.WaitGroup{}
+			for tokenID, addr := range addrs {
+				wait.Add(1)
+				go AsyncUpdateDepositAddress(kuc, tokenID, addr.Hex(), &wait, setting)
+			}
+			wait.Wait()
+			if err = kuc.UpdatePairsPrecision(); err != nil {
+				return nil, fmt.Errorf("Can not Update KuCoin Pairs Precision: (%s)", err.Error())
+			}
+			exchanges[kuc.ID()] = kuc
+		default:
+			genericEx, ok := genericExchanges[exparam]
+			if !ok {
+				return nil, fmt.Errorf("Exchange %s is in KYBER_EXCHANGES, but not avail in current deployment", exparam)
+			}
+			endpoint, err := generic.NewGenericEndpoint(genericEx.Config)
+			if err != nil {
+				return nil, fmt.Errorf("Can not create %s endpoint: (%s)", exparam, err.Error())
+			}
+			storage, err := generic.NewBoltStorage(filepath.Join(common.CmdDirLocation(), exparam+".db"))
+			if err != nil {
+				return nil, fmt.Errorf("Can not create %s storage: (%s)", exparam, err.Error())
+			}
+			gen, err := exchange.NewGeneric(exparam, genericEx.Name, endpoint, storage, setting)
+			if err != nil {
+				return nil, fmt.Errorf("Can not create exchange %s: (%s)", exparam, err.Error())
+			}
+			addrs, err := setting.GetDepositAddresses(genericEx.Name)
+			if err != nil {
+				log.Printf("INFO: Can't get %s Deposit Addresses from Storage (%s)", exparam, err.Error())
+				addrs = make(common.ExchangeAddresses)
+			}
+			wait := sync.WaitGroup{}
+			for tokenID, addr := range addrs {
+				wait.Add(1)
+				go AsyncUpdateDepositAddress(gen, tokenID, addr.Hex(), &wait, setting)
+			}
+			wait.Wait()
+			if err = gen.UpdatePairsPrecision(); err != nil {
+				return nil, fmt.Errorf("Can not Update %s Pairs Precision: (%s)", exparam, err.Error())
+			}
+			exchanges[gen.ID()] = gen
 		}
 	}
-	return &ExchangePool{exchanges}, nil
+	return &ExchangePool{exchanges, limiters}, nil
 }
 
 func (self *ExchangePool) FetcherExchanges() ([]fetcher.Exchange, error) {
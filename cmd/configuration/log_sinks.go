@@ -0,0 +1,81 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/stat"
+	"github.com/KyberNetwork/reserve-data/stat/logsink"
+)
+
+const logSinkTimeout = 10 * time.Second
+
+// LogSinkConfig is a sink of stat logs in secret config file, eg:
+//
+//	{"type": "webhook", "name": "analytics", "url": "https://...", "secret": "..."}
+//	{"type": "nats", "name": "broker", "url": "nats://localhost:4222", "subject": "reserve.stat"}
+//
+// NATS sinks authenticate by the user of the url, token or nkey_seed, and use
+// TLS with a tls:// url or the TLS files.
+type LogSinkConfig struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Secret  string `json:"secret"`
+	Subject string `json:"subject"`
+
+	Token    string `json:"token"`
+	NKeySeed string `json:"nkey_seed"`
+	CAFile   string `json:"ca_file"`
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+type LogSinksConfig struct {
+	LogSinks []LogSinkConfig `json:"log_sinks"`
+}
+
+func newLogSink(config LogSinkConfig) (stat.LogSink, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("Name of %s log sink is empty", config.Type)
+	}
+	switch config.Type {
+	case "webhook":
+		return logsink.NewWebhookSink(config.Name, config.URL, config.Secret, logSinkTimeout), nil
+	case "nats":
+		options := logsink.NATSOptions{
+			Token:    config.Token,
+			NKeySeed: config.NKeySeed,
+			CAFile:   config.CAFile,
+			CertFile: config.CertFile,
+			KeyFile:  config.KeyFile,
+		}
+		return logsink.NewNATSSink(config.Name, config.URL, config.Subject, options, logSinkTimeout)
+	default:
+		return nil, fmt.Errorf("Unknown type %s of log sink %s", config.Type, config.Name)
+	}
+}
+
+// GetLogSinks returns the sinks of stat logs configured in secret config file
+// at path.
+func GetLogSinks(path string) ([]stat.LogSink, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := LogSinksConfig{}
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	var sinks []stat.LogSink
+	for _, config := range result.LogSinks {
+		sink, sErr := newLogSink(config)
+		if sErr != nil {
+			return nil, sErr
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
@@ -56,3 +56,54 @@ func TestBoltLogForStatStorage(t *testing.T) {
 		}
 	}, t)
 }
+
+// TestBoltLogStorageLastBlock checks the last block is kept in the database
+// when it is updated or rolled back.
+func TestBoltLogStorageLastBlock(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test_stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if rErr := os.RemoveAll(tmpDir); rErr != nil {
+			t.Error(rErr)
+		}
+	}()
+	path := filepath.Join(tmpDir, "boltlogstoragetest.db")
+	storage, err := statstorage.NewBoltLogStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reopened reopens a copy of the database as the storage keeps it locked
+	reopened := func(name string) *statstorage.BoltLogStorage {
+		data, rErr := ioutil.ReadFile(path)
+		if rErr != nil {
+			t.Fatal(rErr)
+		}
+		copyPath := filepath.Join(tmpDir, name)
+		if rErr = ioutil.WriteFile(copyPath, data, 0600); rErr != nil {
+			t.Fatal(rErr)
+		}
+		result, rErr := statstorage.NewBoltLogStorage(copyPath)
+		if rErr != nil {
+			t.Fatal(rErr)
+		}
+		return result
+	}
+
+	if err = storage.UpdateLogBlock(333, 112); err != nil {
+		t.Fatal(err)
+	}
+	if lastBlock, _ := reopened("updated.db").LastBlock(); lastBlock != 333 {
+		t.Errorf("expected last block 333 after reopening, got %d", lastBlock)
+	}
+	if _, err = storage.DeleteLogsFromBlock(300); err != nil {
+		t.Fatal(err)
+	}
+	if lastBlock, _ := storage.LastBlock(); lastBlock != 299 {
+		t.Errorf("expected last block 299 after rolling back, got %d", lastBlock)
+	}
+	if lastBlock, _ := reopened("rolledback.db").LastBlock(); lastBlock != 299 {
+		t.Errorf("expected last block 299 after rolling back and reopening, got %d", lastBlock)
+	}
+}
@@ -3,16 +3,32 @@ package configuration
 import (
 	"encoding/json"
 	"io/ioutil"
+	"math/big"
 
 	"github.com/KyberNetwork/reserve-data/common/blockchain"
+	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
+// newSigner returns a remote signer if remoteURL is set, a keystore signer
+// otherwise.
+func newSigner(keystore, passphrase, remoteURL, remoteAddress string, chainID *big.Int) blockchain.Signer {
+	if remoteURL != "" {
+		if !ethereum.IsHexAddress(remoteAddress) {
+			panic("a valid address is required to use remote signer " + remoteURL)
+		}
+		return blockchain.NewRemoteSigner(remoteURL, ethereum.HexToAddress(remoteAddress), chainID)
+	}
+	return blockchain.NewEthereumSigner(keystore, passphrase, chainID)
+}
+
 type jsonPricingDetail struct {
-	Keystore   string `json:"keystore_path"`
-	Passphrase string `json:"passphrase"`
+	Keystore      string `json:"keystore_path"`
+	Passphrase    string `json:"passphrase"`
+	RemoteURL     string `json:"remote_signer_url"`
+	RemoteAddress string `json:"remote_signer_address"`
 }
 
-func PricingSignerFromConfigFile(secretPath string) *blockchain.EthereumSigner {
+func PricingSignerFromConfigFile(secretPath string, chainID *big.Int) blockchain.Signer {
 	raw, err := ioutil.ReadFile(secretPath)
 	if err != nil {
 		panic(err)
@@ -22,15 +38,17 @@ func PricingSignerFromConfigFile(secretPath string) *blockchain.EthereumSigner {
 	if err != nil {
 		panic(err)
 	}
-	return blockchain.NewEthereumSigner(detail.Keystore, detail.Passphrase)
+	return newSigner(detail.Keystore, detail.Passphrase, detail.RemoteURL, detail.RemoteAddress, chainID)
 }
 
 type jsonDepositDetail struct {
-	Keystore   string `json:"keystore_deposit_path"`
-	Passphrase string `json:"passphrase_deposit"`
+	Keystore      string `json:"keystore_deposit_path"`
+	Passphrase    string `json:"passphrase_deposit"`
+	RemoteURL     string `json:"remote_signer_deposit_url"`
+	RemoteAddress string `json:"remote_signer_deposit_address"`
 }
 
-func DepositSignerFromConfigFile(secretPath string) *blockchain.EthereumSigner {
+func DepositSignerFromConfigFile(secretPath string, chainID *big.Int) blockchain.Signer {
 	raw, err := ioutil.ReadFile(secretPath)
 	if err != nil {
 		panic(err)
@@ -40,15 +58,42 @@ func DepositSignerFromConfigFile(secretPath string) *blockchain.EthereumSigner {
 	if err != nil {
 		panic(err)
 	}
-	return blockchain.NewEthereumSigner(detail.Keystore, detail.Passphrase)
+	return newSigner(detail.Keystore, detail.Passphrase, detail.RemoteURL, detail.RemoteAddress, chainID)
+}
+
+type jsonAlerterDetail struct {
+	Keystore      string `json:"keystore_alerter_path"`
+	Passphrase    string `json:"passphrase_alerter"`
+	RemoteURL     string `json:"remote_signer_alerter_url"`
+	RemoteAddress string `json:"remote_signer_alerter_address"`
+}
+
+// AlerterSignerFromConfigFile returns the signer of the alerter of pricing
+// contract, nil if it is not configured.
+func AlerterSignerFromConfigFile(secretPath string, chainID *big.Int) blockchain.Signer {
+	raw, err := ioutil.ReadFile(secretPath)
+	if err != nil {
+		panic(err)
+	}
+	detail := jsonAlerterDetail{}
+	err = json.Unmarshal(raw, &detail)
+	if err != nil {
+		panic(err)
+	}
+	if detail.Keystore == "" && detail.RemoteURL == "" {
+		return nil
+	}
+	return newSigner(detail.Keystore, detail.Passphrase, detail.RemoteURL, detail.RemoteAddress, chainID)
 }
 
 type jsonHuobiIntermediatorDetail struct {
-	Keystore   string `json:"keystore_intermediator_path"`
-	Passphrase string `json:"passphrase_intermediate_account"`
+	Keystore      string `json:"keystore_intermediator_path"`
+	Passphrase    string `json:"passphrase_intermediate_account"`
+	RemoteURL     string `json:"remote_signer_intermediator_url"`
+	RemoteAddress string `json:"remote_signer_intermediator_address"`
 }
 
-func HuobiIntermediatorSignerFromFile(secretPath string) *blockchain.EthereumSigner {
+func HuobiIntermediatorSignerFromFile(secretPath string, chainID *big.Int) blockchain.Signer {
 	raw, err := ioutil.ReadFile(secretPath)
 	if err != nil {
 		panic(err)
@@ -58,5 +103,5 @@ func HuobiIntermediatorSignerFromFile(secretPath string) *blockchain.EthereumSig
 	if err != nil {
 		panic(err)
 	}
-	return blockchain.NewEthereumSigner(detail.Keystore, detail.Passphrase)
+	return newSigner(detail.Keystore, detail.Passphrase, detail.RemoteURL, detail.RemoteAddress, chainID)
 }
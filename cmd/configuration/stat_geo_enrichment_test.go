@@ -0,0 +1,46 @@
+package configuration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/stat"
+	statstorage "github.com/KyberNetwork/reserve-data/stat/storage"
+)
+
+func SetupGeoEnrichmentTester(tmpDir string) (*stat.GeoEnrichmentTest, error) {
+	statStorage, err := statstorage.NewBoltStatStorage(filepath.Join(tmpDir, "stat.db"))
+	if err != nil {
+		return nil, err
+	}
+	logStorage, err := statstorage.NewBoltLogStorage(filepath.Join(tmpDir, "log.db"))
+	if err != nil {
+		return nil, err
+	}
+	userStorage, err := statstorage.NewBoltUserStorage(filepath.Join(tmpDir, "user.db"))
+	if err != nil {
+		return nil, err
+	}
+	return stat.NewGeoEnrichmentTest(statStorage, logStorage, userStorage), nil
+}
+
+func TestStatGeoEnrichment(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test_stat_geo_enrichment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Error(err)
+		}
+	}()
+	tester, err := SetupGeoEnrichmentTester(tmpDir)
+	if err != nil {
+		t.Fatalf("Testing geo enrichment: init failed (%s)", err)
+	}
+	if err := tester.TestEnrichment(); err != nil {
+		t.Fatalf("Testing geo enrichment failed (%s)", err)
+	}
+}
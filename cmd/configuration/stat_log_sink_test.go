@@ -0,0 +1,30 @@
+package configuration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/stat"
+	statstorage "github.com/KyberNetwork/reserve-data/stat/storage"
+)
+
+func TestStatLogSink(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test_stat_log_sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Error(err)
+		}
+	}()
+	logStorage, err := statstorage.NewBoltLogStorage(filepath.Join(tmpDir, "log.db"))
+	if err != nil {
+		t.Fatalf("Testing log sinks: init failed (%s)", err)
+	}
+	if err := stat.NewLogSinkTest(logStorage).TestDelivery(); err != nil {
+		t.Fatalf("Testing log sink delivery failed (%s)", err)
+	}
+}
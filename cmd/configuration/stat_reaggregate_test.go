@@ -0,0 +1,46 @@
+package configuration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/stat"
+	statstorage "github.com/KyberNetwork/reserve-data/stat/storage"
+)
+
+func SetupReaggregateTester(tmpDir string) (*stat.ReaggregateTest, error) {
+	statStorage, err := statstorage.NewBoltStatStorage(filepath.Join(tmpDir, "stat.db"))
+	if err != nil {
+		return nil, err
+	}
+	logStorage, err := statstorage.NewBoltLogStorage(filepath.Join(tmpDir, "log.db"))
+	if err != nil {
+		return nil, err
+	}
+	userStorage, err := statstorage.NewBoltUserStorage(filepath.Join(tmpDir, "user.db"))
+	if err != nil {
+		return nil, err
+	}
+	return stat.NewReaggregateTest(statStorage, logStorage, userStorage), nil
+}
+
+func TestStatReaggregate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test_stat_reaggregate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Error(err)
+		}
+	}()
+	tester, err := SetupReaggregateTester(tmpDir)
+	if err != nil {
+		t.Fatalf("Testing stat re-aggregation: init failed (%s)", err)
+	}
+	if err := tester.TestReaggregate(); err != nil {
+		t.Fatalf("Testing stat re-aggregation failed (%s)", err)
+	}
+}
@@ -0,0 +1,57 @@
+package configuration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/stat"
+	statstorage "github.com/KyberNetwork/reserve-data/stat/storage"
+)
+
+func SetupReorgTester(tmpDir string) (*stat.ReorgTest, error) {
+	statStorage, err := statstorage.NewBoltStatStorage(filepath.Join(tmpDir, "stat.db"))
+	if err != nil {
+		return nil, err
+	}
+	logStorage, err := statstorage.NewBoltLogStorage(filepath.Join(tmpDir, "log.db"))
+	if err != nil {
+		return nil, err
+	}
+	rateStorage, err := statstorage.NewBoltRateStorage(filepath.Join(tmpDir, "rate.db"))
+	if err != nil {
+		return nil, err
+	}
+	userStorage, err := statstorage.NewBoltUserStorage(filepath.Join(tmpDir, "user.db"))
+	if err != nil {
+		return nil, err
+	}
+	feeSetRateStorage, err := statstorage.NewBoltFeeSetRateStorage(filepath.Join(tmpDir, "fee_setrate.db"))
+	if err != nil {
+		return nil, err
+	}
+	return stat.NewReorgTest(statStorage, logStorage, rateStorage, userStorage, feeSetRateStorage), nil
+}
+
+func TestStatReorg(t *testing.T) {
+	for name, test := range map[string]func(*stat.ReorgTest) error{
+		"reorg":                  (*stat.ReorgTest).TestReorg,
+		"reorg rollback failure": (*stat.ReorgTest).TestReorgRollbackFailure,
+	} {
+		tmpDir, err := ioutil.TempDir("", "test_stat_reorg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tester, err := SetupReorgTester(tmpDir)
+		if err != nil {
+			t.Fatalf("Testing stat %s: init failed (%s)", name, err)
+		}
+		if err = test(tester); err != nil {
+			t.Errorf("Testing stat %s failed (%s)", name, err)
+		}
+		if err = os.RemoveAll(tmpDir); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/watchdog"
+)
+
+const watchdogAlerterTimeout = 10 * time.Second
+
+// WatchdogAlerterConfig is a webhook receiving the alerts of the rate
+// watchdog in secret config file, eg:
+//
+//	{"name": "ops", "url": "https://...", "secret": "..."}
+type WatchdogAlerterConfig struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+type WatchdogAlertersConfig struct {
+	WatchdogAlerters []WatchdogAlerterConfig `json:"watchdog_alerters"`
+}
+
+// GetWatchdogAlerters returns the alerters of the rate watchdog configured in
+// secret config file at path, the alerts are always logged.
+func GetWatchdogAlerters(path string) ([]watchdog.Alerter, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := WatchdogAlertersConfig{}
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	alerters := []watchdog.Alerter{watchdog.LogAlerter{}}
+	for _, config := range result.WatchdogAlerters {
+		if config.Name == "" || config.URL == "" {
+			return nil, fmt.Errorf("Name and url of watchdog alerter are required")
+		}
+		alerters = append(alerters, watchdog.NewWebhookAlerter(config.Name, config.URL, config.Secret, watchdogAlerterTimeout))
+	}
+	return alerters, nil
+}
@@ -0,0 +1,23 @@
+package common
+
+// APIKey is a named key to sign requests to core APIs.
+type APIKey struct {
+	// ID is the name of the key, it is recorded on activities created by
+	// requests signed with this key.
+	ID     string `json:"key_id"`
+	Secret string `json:"secret,omitempty"`
+	// Permissions is the list of permission names granted to the key:
+	// read_only, rebalance, configure, confirm_configuration,
+	// force_set_rates, emergency, admin.
+	Permissions []string `json:"permissions"`
+	// Endpoints restricts the key to the given path prefixes, for example
+	// "/withdraw" and "/deposit". Empty means every endpoint is allowed.
+	Endpoints []string `json:"endpoints,omitempty"`
+	// Exchanges restricts the key to requests on the given exchanges.
+	Exchanges []string `json:"exchanges,omitempty"`
+	// Tokens restricts the key to requests on the given tokens.
+	Tokens []string `json:"tokens,omitempty"`
+	// IPAllowlist restricts the key to requests from the given IP
+	// addresses or CIDR ranges.
+	IPAllowlist []string `json:"ip_allowlist,omitempty"`
+}
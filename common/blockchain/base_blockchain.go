@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
@@ -41,6 +42,7 @@ type BaseBlockchain struct {
 	broadcaster    *Broadcaster
 	ethRate        EthUSDRate
 	chainType      string
+	chainID        *big.Int
 	contractCaller *ContractCaller
 	erc20abi       abi.ABI
 }
@@ -259,6 +261,22 @@ func (self *BaseBlockchain) CurrentBlock() (uint64, error) {
 	return result, err
 }
 
+// GetBlockHash returns the hash of the block at number on the chain of the
+// node.
+func (self *BaseBlockchain) GetBlockHash(number uint64) (ethereum.Hash, error) {
+	var block struct {
+		Hash ethereum.Hash `json:"hash"`
+	}
+	err := self.rpcClient.Call(&block, "eth_getBlockByNumber", fmt.Sprintf("0x%x", number), false)
+	if err != nil {
+		return ethereum.Hash{}, err
+	}
+	if block.Hash == (ethereum.Hash{}) {
+		return ethereum.Hash{}, fmt.Errorf("Block %d is not found", number)
+	}
+	return block.Hash, nil
+}
+
 func (self *BaseBlockchain) PackERC20Data(method string, params ...interface{}) ([]byte, error) {
 	return self.erc20abi.Pack(method, params...)
 }
@@ -382,8 +400,14 @@ func (self *BaseBlockchain) GetEthRate(timepoint uint64) float64 {
 	return rate
 }
 
+// ChainID returns the chain ID of the node, transactions must be signed for
+// this chain ID.
+func (self *BaseBlockchain) ChainID() *big.Int {
+	return self.chainID
+}
+
 func NewMinimalBaseBlockchain(
-	endpoints []string, operators map[string]*Operator, chainType string) (*BaseBlockchain, error) {
+	endpoints []string, operators map[string]*Operator, chainType string, chainID *big.Int) (*BaseBlockchain, error) {
 
 	if len(endpoints) == 0 {
 		return nil, errors.New("At least one endpoint is required to init a blockchain")
@@ -410,10 +434,42 @@ func NewMinimalBaseBlockchain(
 		NewBroadcaster(bkclients),
 		NewCMCEthUSDRate(),
 		chainType,
+		chainID,
 		NewContractCaller(callClients, endpoints),
-	), nil
+	)
+}
+
+// nodeChainID returns the chain ID of the node, the network ID is used for
+// nodes not supporting eth_chainId.
+func nodeChainID(rpcClient *rpc.Client, client *ethclient.Client) (*big.Int, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var hex string
+	if err := rpcClient.CallContext(timeout, &hex, "eth_chainId"); err == nil {
+		chainID, ok := new(big.Int).SetString(strings.TrimPrefix(hex, "0x"), 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid eth_chainId result %q", hex)
+		}
+		return chainID, nil
+	}
+	return client.NetworkID(timeout)
+}
+
+// checkChainID returns the chain ID of the node. If chainID is set, it must
+// be the chain ID of the node.
+func checkChainID(rpcClient *rpc.Client, client *ethclient.Client, chainID *big.Int) (*big.Int, error) {
+	nodeID, err := nodeChainID(rpcClient, client)
+	if err != nil {
+		return nil, fmt.Errorf("Can not get chain ID from node: %s", err)
+	}
+	if chainID != nil && chainID.Cmp(nodeID) != 0 {
+		return nil, fmt.Errorf("Node is on chain %s, expected chain %s", nodeID, chainID)
+	}
+	return nodeID, nil
 }
 
+// NewBaseBlockchain creates a BaseBlockchain. The chain ID of the node is
+// checked against chainID, if chainID is nil the chain ID of the node is used.
 func NewBaseBlockchain(
 	rpcClient *rpc.Client,
 	client *ethclient.Client,
@@ -421,7 +477,12 @@ func NewBaseBlockchain(
 	broadcaster *Broadcaster,
 	ethRate EthUSDRate,
 	chainType string,
-	contractcaller *ContractCaller) *BaseBlockchain {
+	chainID *big.Int,
+	contractcaller *ContractCaller) (*BaseBlockchain, error) {
+	chainID, err := checkChainID(rpcClient, client, chainID)
+	if err != nil {
+		return nil, err
+	}
 
 	file, err := os.Open(
 		filepath.Join(common.CurrentDir(), "ERC20.abi"))
@@ -440,7 +501,8 @@ func NewBaseBlockchain(
 		broadcaster:    broadcaster,
 		ethRate:        ethRate,
 		chainType:      chainType,
+		chainID:        chainID,
 		erc20abi:       packabi,
 		contractCaller: contractcaller,
-	}
+	}, nil
 }
@@ -0,0 +1,80 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// newTestNode starts a JSON-RPC server answering net_version with networkID
+// and eth_chainId with chainID if it is not empty, the returned func closes
+// it.
+func newTestNode(t *testing.T, networkID, chainID string) (*rpc.Client, *ethclient.Client, func()) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case req.Method == "net_version":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"%s"}`, req.ID, networkID)
+		case req.Method == "eth_chainId" && chainID != "":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"%s"}`, req.ID, chainID)
+		default:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"method not found"}}`, req.ID)
+		}
+	}))
+	client, err := rpc.Dial(server.URL)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return client, ethclient.NewClient(client), server.Close
+}
+
+func TestCheckChainID(t *testing.T) {
+	var tests = []struct {
+		msg       string
+		networkID string
+		chainID   string
+		expected  *big.Int
+		result    int64
+		fail      bool
+	}{
+		{msg: "eth_chainId matches", networkID: "1", chainID: "0x3", expected: big.NewInt(3), result: 3},
+		{msg: "eth_chainId mismatches", networkID: "3", chainID: "0x3", expected: big.NewInt(1), fail: true},
+		{msg: "fallback to net_version", networkID: "42", expected: big.NewInt(42), result: 42},
+		{msg: "net_version mismatches", networkID: "3", expected: big.NewInt(1), fail: true},
+		{msg: "chain ID from node", networkID: "17", result: 17},
+	}
+	for _, tc := range tests {
+		rpcClient, client, closeNode := newTestNode(t, tc.networkID, tc.chainID)
+		chainID, err := checkChainID(rpcClient, client, tc.expected)
+		closeNode()
+		if tc.fail {
+			if err == nil {
+				t.Errorf("%s: expected error, got chain %s", tc.msg, chainID)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tc.msg, err)
+			continue
+		}
+		if chainID.Int64() != tc.result {
+			t.Errorf("%s: expected chain %d, got %s", tc.msg, tc.result, chainID)
+		}
+	}
+}
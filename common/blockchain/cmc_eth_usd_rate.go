@@ -3,19 +3,19 @@ package blockchain
 import (
 	"encoding/json"
 	"errors"
-	"io/ioutil"
 	"log"
-	"net/http"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
 )
 
 const (
 	cmcEthereumPricingAPIEndpoint = "https://graphs2.coinmarketcap.com/currencies/ethereum/"
 	cmcTopUSDPricingAPIEndpoint   = "https://api.coinmarketcap.com/v1/ticker/?convert=USD&limit=10"
+	cmcTimeout                    = 30 * time.Second
 )
 
 type CoinCapRateResponse []struct {
@@ -28,6 +28,7 @@ type CoinCapRateResponse []struct {
 
 type CMCEthUSDRate struct {
 	mu                *sync.RWMutex
+	client            *httpclient.Client
 	cachedRates       [][]float64
 	currentCacheMonth uint64
 	realtimeTimepoint uint64
@@ -72,7 +73,7 @@ func (self *CMCEthUSDRate) rateFromCache(timepoint uint64) float64 {
 	defer self.mu.Unlock()
 	monthTimeStamp := GetMonthTimeStamp(timepoint)
 	if monthTimeStamp != self.currentCacheMonth {
-		ethRates, err := fetchRate(timepoint)
+		ethRates, err := fetchRate(self.client, timepoint)
 		if err != nil {
 			log.Println("Cannot get rate from coinmarketcap")
 			return self.realtimeRate
@@ -96,28 +97,19 @@ func (self *CMCEthUSDRate) rateFromCache(timepoint uint64) float64 {
 	}
 }
 
-func fetchRate(timepoint uint64) ([][]float64, error) {
+func fetchRate(client *httpclient.Client, timepoint uint64) ([][]float64, error) {
 	t := time.Unix(int64(timepoint/1000), 0).UTC()
 	month, year := t.Month(), t.Year()
 	fromTime := GetTimeStamp(year, month, 1, 0, 0, 0, 0, time.UTC)
 	toMonth, toYear := GetNextMonth(int(month), year)
 	toTime := GetTimeStamp(toYear, time.Month(toMonth), 1, 0, 0, 0, 0, time.UTC)
 	api := cmcEthereumPricingAPIEndpoint + strconv.FormatInt(int64(fromTime), 10) + "/" + strconv.FormatInt(int64(toTime), 10) + "/"
-	resp, err := http.Get(api)
-	if err != nil {
-		return [][]float64{}, err
-	}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("Response body close error: %s", cErr.Error())
-		}
-	}()
-	body, err := ioutil.ReadAll(resp.Body)
+	resp, err := client.Get(api)
 	if err != nil {
 		return [][]float64{}, err
 	}
 	rateResponse := RateLogResponse{}
-	err = json.Unmarshal(body, &rateResponse)
+	err = json.Unmarshal(resp.Body, &rateResponse)
 	if err != nil {
 		return [][]float64{}, err
 	}
@@ -150,18 +142,12 @@ func (self *CMCEthUSDRate) RunGetEthRate() {
 }
 
 func (self *CMCEthUSDRate) FetchEthRate() (err error) {
-	resp, err := http.Get(cmcTopUSDPricingAPIEndpoint)
+	resp, err := self.client.Get(cmcTopUSDPricingAPIEndpoint)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("Response body close error: %s", cErr.Error())
-		}
-	}()
-	body, err := ioutil.ReadAll(resp.Body)
 	rateResponse := CoinCapRateResponse{}
-	err = json.Unmarshal(body, &rateResponse)
+	err = json.Unmarshal(resp.Body, &rateResponse)
 	if err != nil {
 		log.Printf("Getting eth-usd rate failed: %+v", err)
 	} else {
@@ -193,7 +179,8 @@ func (self *CMCEthUSDRate) Run() {
 
 func NewCMCEthUSDRate() *CMCEthUSDRate {
 	result := &CMCEthUSDRate{
-		mu: &sync.RWMutex{},
+		mu:     &sync.RWMutex{},
+		client: httpclient.NewClient("cmc", httpclient.DefaultConfig(cmcTimeout)),
 	}
 	result.Run()
 	return result
@@ -0,0 +1,109 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// feeHistoryBlocks is the number of recent blocks the gas price is
+	// estimated from.
+	feeHistoryBlocks = 10
+	// feeHistoryPercentile is the percentile of the priority fees paid in
+	// each block used as the priority fee of the estimation.
+	feeHistoryPercentile = 50
+)
+
+// FeeHistory is the fee market history of a range of blocks as returned by
+// eth_feeHistory.
+type FeeHistory struct {
+	OldestBlock *big.Int
+	// BaseFeePerGas has one more element than the number of blocks, the last
+	// one is the base fee of the next block.
+	BaseFeePerGas []*big.Int
+	GasUsedRatio  []float64
+	// Reward is the priority fees at the requested percentiles of each
+	// block.
+	Reward [][]*big.Int
+}
+
+type rpcFeeHistory struct {
+	OldestBlock   *hexutil.Big     `json:"oldestBlock"`
+	BaseFeePerGas []*hexutil.Big   `json:"baseFeePerGas"`
+	GasUsedRatio  []float64        `json:"gasUsedRatio"`
+	Reward        [][]*hexutil.Big `json:"reward"`
+}
+
+// FeeHistory returns the fee market history of blockCount blocks up to the
+// latest block with the priority fees at the given percentiles.
+func (self *BaseBlockchain) FeeHistory(ctx context.Context, blockCount uint64, rewardPercentiles []float64) (FeeHistory, error) {
+	var result rpcFeeHistory
+	if err := self.rpcClient.CallContext(ctx, &result, "eth_feeHistory", hexutil.Uint64(blockCount), "latest", rewardPercentiles); err != nil {
+		return FeeHistory{}, err
+	}
+	history := FeeHistory{GasUsedRatio: result.GasUsedRatio}
+	if result.OldestBlock != nil {
+		history.OldestBlock = result.OldestBlock.ToInt()
+	}
+	for _, fee := range result.BaseFeePerGas {
+		history.BaseFeePerGas = append(history.BaseFeePerGas, fee.ToInt())
+	}
+	for _, rewards := range result.Reward {
+		var blockRewards []*big.Int
+		for _, reward := range rewards {
+			blockRewards = append(blockRewards, reward.ToInt())
+		}
+		history.Reward = append(history.Reward, blockRewards)
+	}
+	return history, nil
+}
+
+// estimateGasPrice returns the legacy gas price paying the median of the
+// priority fees of the history on top of the next base fee. The base fee can
+// rise by 1/8 per block so this headroom is added to get the tx mined in the
+// next block.
+func estimateGasPrice(history FeeHistory) (*big.Int, error) {
+	if len(history.BaseFeePerGas) == 0 {
+		return nil, errors.New("Fee history has no base fee")
+	}
+	nextBaseFee := history.BaseFeePerGas[len(history.BaseFeePerGas)-1]
+	if nextBaseFee == nil || nextBaseFee.Sign() == 0 {
+		return nil, errors.New("Fee history has no base fee, the chain doesn't support EIP-1559")
+	}
+	var tips []*big.Int
+	for i, rewards := range history.Reward {
+		// empty blocks report zero rewards, they say nothing about the
+		// priority fee needed to be mined
+		if i < len(history.GasUsedRatio) && history.GasUsedRatio[i] == 0 {
+			continue
+		}
+		if len(rewards) > 0 && rewards[0] != nil {
+			tips = append(tips, rewards[0])
+		}
+	}
+	tip := big.NewInt(0)
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+		tip = tips[len(tips)/2]
+	}
+	price := new(big.Int).Mul(nextBaseFee, big.NewInt(9))
+	price.Div(price, big.NewInt(8))
+	return price.Add(price, tip), nil
+}
+
+// RecommendedGasPriceFromFeeHistory returns the gas price estimated from the
+// eth_feeHistory of the recent blocks.
+func (self *BaseBlockchain) RecommendedGasPriceFromFeeHistory() (*big.Int, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 7*time.Second)
+	defer cancel()
+	history, err := self.FeeHistory(timeout, feeHistoryBlocks, []float64{feeHistoryPercentile})
+	if err != nil {
+		return nil, err
+	}
+	return estimateGasPrice(history)
+}
@@ -0,0 +1,118 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// SimulatedFeeNode is the eth namespace of a simulated node serving fee
+// history of its blocks, the rpc server only exposes exported types.
+type SimulatedFeeNode struct {
+	baseFees     []int64
+	gasUsedRatio []float64
+	rewards      []int64
+}
+
+type SimulatedFeeHistory rpcFeeHistory
+
+func (self *SimulatedFeeNode) FeeHistory(blockCount hexutil.Uint64, newest string, percentiles []float64) (*SimulatedFeeHistory, error) {
+	result := &SimulatedFeeHistory{
+		OldestBlock:  (*hexutil.Big)(big.NewInt(100)),
+		GasUsedRatio: self.gasUsedRatio,
+	}
+	for _, fee := range self.baseFees {
+		result.BaseFeePerGas = append(result.BaseFeePerGas, (*hexutil.Big)(big.NewInt(fee)))
+	}
+	for _, reward := range self.rewards {
+		result.Reward = append(result.Reward, []*hexutil.Big{(*hexutil.Big)(big.NewInt(reward))})
+	}
+	return result, nil
+}
+
+func newTestFeeBlockchain(t *testing.T, node *SimulatedFeeNode) *BaseBlockchain {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", node); err != nil {
+		t.Fatal(err)
+	}
+	return &BaseBlockchain{rpcClient: rpc.DialInProc(server)}
+}
+
+func TestFeeHistory(t *testing.T) {
+	bc := newTestFeeBlockchain(t, &SimulatedFeeNode{
+		baseFees:     []int64{10, 11, 12},
+		gasUsedRatio: []float64{0.9, 0.8},
+		rewards:      []int64{1, 2},
+	})
+	history, err := bc.FeeHistory(context.Background(), 2, []float64{50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history.OldestBlock.Int64() != 100 {
+		t.Errorf("expected oldest block 100, got %s", history.OldestBlock)
+	}
+	if len(history.BaseFeePerGas) != 3 || history.BaseFeePerGas[2].Int64() != 12 {
+		t.Errorf("unexpected base fees %v", history.BaseFeePerGas)
+	}
+	if len(history.Reward) != 2 || history.Reward[1][0].Int64() != 2 {
+		t.Errorf("unexpected rewards %v", history.Reward)
+	}
+}
+
+func TestRecommendedGasPriceFromFeeHistory(t *testing.T) {
+	var tests = []struct {
+		msg      string
+		node     *SimulatedFeeNode
+		expected int64
+		fail     bool
+	}{
+		{
+			msg: "median priority fee on top of next base fee",
+			node: &SimulatedFeeNode{
+				baseFees:     []int64{800, 800, 800, 1600},
+				gasUsedRatio: []float64{0.5, 0.6, 0.7},
+				rewards:      []int64{30, 10, 20},
+			},
+			// 1600 * 9 / 8 + 20
+			expected: 1820,
+		},
+		{
+			msg: "empty blocks are ignored",
+			node: &SimulatedFeeNode{
+				baseFees:     []int64{800, 800, 800, 800},
+				gasUsedRatio: []float64{0, 0.6, 0},
+				rewards:      []int64{0, 50, 0},
+			},
+			expected: 950,
+		},
+		{
+			msg: "pre-London chain has no base fee",
+			node: &SimulatedFeeNode{
+				baseFees:     []int64{0, 0},
+				gasUsedRatio: []float64{0.5},
+				rewards:      []int64{0},
+			},
+			fail: true,
+		},
+	}
+	for _, tc := range tests {
+		bc := newTestFeeBlockchain(t, tc.node)
+		price, err := bc.RecommendedGasPriceFromFeeHistory()
+		if tc.fail {
+			if err == nil {
+				t.Errorf("%s: expected error, got price %s", tc.msg, price)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tc.msg, err)
+			continue
+		}
+		if price.Int64() != tc.expected {
+			t.Errorf("%s: expected price %d, got %s", tc.msg, tc.expected, price)
+		}
+	}
+}
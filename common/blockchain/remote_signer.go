@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const remoteSignTimeout = 60 * time.Second
+
+// RemoteSignTxArgs is the transaction to sign sent to account_signTransaction.
+type RemoteSignTxArgs struct {
+	From     ethereum.Address  `json:"from"`
+	To       *ethereum.Address `json:"to"`
+	Gas      hexutil.Uint64    `json:"gas"`
+	GasPrice *hexutil.Big      `json:"gasPrice"`
+	Value    *hexutil.Big      `json:"value"`
+	Nonce    hexutil.Uint64    `json:"nonce"`
+	Data     hexutil.Bytes     `json:"data"`
+	ChainID  *hexutil.Big      `json:"chainId"`
+}
+
+// RemoteSignTxResult is the result of account_signTransaction, Raw is the
+// RLP encoded signed transaction.
+type RemoteSignTxResult struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+// RemoteSigner delegates signing of transactions of an account to an external
+// signer (eg: clef) over JSON-RPC, so the private key is not held by this
+// process. The signed transaction is checked to be the requested one, signed
+// by the account for the chain ID.
+type RemoteSigner struct {
+	client  *rpc.Client
+	address ethereum.Address
+	chainID *big.Int
+}
+
+func (self RemoteSigner) GetAddress() ethereum.Address {
+	return self.address
+}
+
+func (self RemoteSigner) Sign(tx *types.Transaction) (*types.Transaction, error) {
+	args := RemoteSignTxArgs{
+		From:     self.address,
+		To:       tx.To(),
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: (*hexutil.Big)(tx.GasPrice()),
+		Value:    (*hexutil.Big)(tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     tx.Data(),
+		ChainID:  (*hexutil.Big)(self.chainID),
+	}
+	timeout, cancel := context.WithTimeout(context.Background(), remoteSignTimeout)
+	defer cancel()
+	var result RemoteSignTxResult
+	if err := self.client.CallContext(timeout, &result, "account_signTransaction", args); err != nil {
+		return nil, fmt.Errorf("Remote signer failed to sign transaction: %s", err)
+	}
+	signed := new(types.Transaction)
+	if err := rlp.DecodeBytes(result.Raw, signed); err != nil {
+		return nil, fmt.Errorf("Remote signer returned invalid transaction: %s", err)
+	}
+	if err := self.verify(tx, signed); err != nil {
+		return nil, fmt.Errorf("Remote signer returned invalid transaction: %s", err)
+	}
+	return signed, nil
+}
+
+// verify checks that signed is tx signed by the account for the chain ID.
+func (self RemoteSigner) verify(tx, signed *types.Transaction) error {
+	if signed.Nonce() != tx.Nonce() || signed.Gas() != tx.Gas() ||
+		signed.GasPrice().Cmp(tx.GasPrice()) != 0 || signed.Value().Cmp(tx.Value()) != 0 ||
+		!bytes.Equal(signed.Data(), tx.Data()) {
+		return fmt.Errorf("transaction %s differs from the requested one", signed.Hash().Hex())
+	}
+	if (signed.To() == nil) != (tx.To() == nil) || (tx.To() != nil && *signed.To() != *tx.To()) {
+		return fmt.Errorf("transaction %s has a different recipient", signed.Hash().Hex())
+	}
+	if !signed.Protected() || signed.ChainId().Cmp(self.chainID) != 0 {
+		return fmt.Errorf("transaction %s is not signed for chain %s", signed.Hash().Hex(), self.chainID)
+	}
+	sender, err := types.Sender(types.NewEIP155Signer(self.chainID), signed)
+	if err != nil {
+		return err
+	}
+	if sender != self.address {
+		return fmt.Errorf("transaction %s is signed by %s, expected %s", signed.Hash().Hex(), sender.Hex(), self.address.Hex())
+	}
+	return nil
+}
+
+// NewRemoteSigner creates a signer of address using the external signer at
+// endpoint (HTTP URL or IPC socket path), it panics if chainID is not set or
+// the endpoint is invalid.
+func NewRemoteSigner(endpoint string, address ethereum.Address, chainID *big.Int) *RemoteSigner {
+	if chainID == nil || chainID.Sign() <= 0 {
+		panic("a positive chain ID is required to sign transactions")
+	}
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		panic(err)
+	}
+	return NewRemoteSignerWithClient(client, address, chainID)
+}
+
+// NewRemoteSignerWithClient creates a signer of address using the external
+// signer connected by client.
+func NewRemoteSignerWithClient(client *rpc.Client, address ethereum.Address, chainID *big.Int) *RemoteSigner {
+	return &RemoteSigner{client: client, address: address, chainID: chainID}
+}
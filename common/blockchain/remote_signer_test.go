@@ -0,0 +1,80 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// FakeAccountAPI implements account_signTransaction like clef, it is exported
+// to be registered to the RPC server. If nonceOffset is set, it signs a
+// transaction with a different nonce than requested.
+type FakeAccountAPI struct {
+	key         *ecdsa.PrivateKey
+	nonceOffset uint64
+	args        *RemoteSignTxArgs
+}
+
+func (self *FakeAccountAPI) SignTransaction(args RemoteSignTxArgs) (RemoteSignTxResult, error) {
+	self.args = &args
+	tx := types.NewTransaction(uint64(args.Nonce)+self.nonceOffset, *args.To, args.Value.ToInt(),
+		uint64(args.Gas), args.GasPrice.ToInt(), args.Data)
+	signed, err := types.SignTx(tx, types.NewEIP155Signer(args.ChainID.ToInt()), self.key)
+	if err != nil {
+		return RemoteSignTxResult{}, err
+	}
+	raw, err := rlp.EncodeToBytes(signed)
+	return RemoteSignTxResult{Raw: raw}, err
+}
+
+func newFakeSigner(t *testing.T) (*FakeAccountAPI, *rpc.Client, func()) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &FakeAccountAPI{key: key}
+	server := rpc.NewServer()
+	if err = server.RegisterName("account", fake); err != nil {
+		t.Fatal(err)
+	}
+	return fake, rpc.DialInProc(server), server.Stop
+}
+
+func TestRemoteSigner(t *testing.T) {
+	fake, client, stop := newFakeSigner(t)
+	defer stop()
+	address := crypto.PubkeyToAddress(fake.key.PublicKey)
+	to := ethereum.HexToAddress("0x63825c174ab367968EC60f061753D3bbD36A0D8F")
+	tx := types.NewTransaction(7, to, big.NewInt(1000), 21000, big.NewInt(20000000000), []byte{1, 2, 3})
+
+	signer := NewRemoteSignerWithClient(client, address, big.NewInt(3))
+	signed, err := signer.Sign(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.args.From != address || fake.args.ChainID.ToInt().Int64() != 3 || uint64(fake.args.Nonce) != 7 {
+		t.Errorf("unexpected sign request %+v", fake.args)
+	}
+	if signed.Hash() == tx.Hash() || signed.Nonce() != 7 || signed.ChainId().Int64() != 3 {
+		t.Errorf("unexpected signed transaction %+v", signed)
+	}
+
+	// signed by another account
+	other := NewRemoteSignerWithClient(client, ethereum.HexToAddress("0x2262d4f6312805851e3b27c40db2c7282e6e4a42"), big.NewInt(3))
+	if _, err = other.Sign(tx); err == nil {
+		t.Error("expected error when transaction is signed by another account")
+	}
+
+	// different transaction returned
+	fake.nonceOffset = 1
+	if _, err = signer.Sign(tx); err == nil {
+		t.Error("expected error when signer returns a different transaction")
+	}
+}
@@ -1,6 +1,7 @@
 package blockchain
 
 import (
+	"math/big"
 	"os"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -14,8 +15,12 @@ type Signer interface {
 	Sign(*types.Transaction) (*types.Transaction, error)
 }
 
+// EthereumSigner signs transactions with a keystore key. Transactions are
+// signed with EIP155 replay protection for the chain ID so they can't be
+// broadcasted to other chains.
 type EthereumSigner struct {
-	opts *bind.TransactOpts
+	opts    *bind.TransactOpts
+	chainID *big.Int
 }
 
 func (self EthereumSigner) GetAddress() ethereum.Address {
@@ -23,10 +28,20 @@ func (self EthereumSigner) GetAddress() ethereum.Address {
 }
 
 func (self EthereumSigner) Sign(tx *types.Transaction) (*types.Transaction, error) {
-	return self.opts.Signer(types.HomesteadSigner{}, self.GetAddress(), tx)
+	return self.opts.Signer(types.NewEIP155Signer(self.chainID), self.GetAddress(), tx)
 }
 
-func NewEthereumSigner(keyPath string, passphrase string) *EthereumSigner {
+// ChainID returns the chain ID the transactions are signed for.
+func (self EthereumSigner) ChainID() *big.Int {
+	return self.chainID
+}
+
+// NewEthereumSigner creates a signer from keystore file at keyPath, it panics
+// if chainID is not set or the key can't be decrypted.
+func NewEthereumSigner(keyPath string, passphrase string, chainID *big.Int) *EthereumSigner {
+	if chainID == nil || chainID.Sign() <= 0 {
+		panic("a positive chain ID is required to sign transactions")
+	}
 	key, err := os.Open(keyPath)
 	if err != nil {
 		panic(err)
@@ -35,5 +50,5 @@ func NewEthereumSigner(keyPath string, passphrase string) *EthereumSigner {
 	if err != nil {
 		panic(err)
 	}
-	return &EthereumSigner{opts: auth}
+	return &EthereumSigner{opts: auth, chainID: chainID}
 }
@@ -0,0 +1,56 @@
+package blockchain
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func newTestSigner(t *testing.T, chainID int64) *EthereumSigner {
+	t.Helper()
+	key, err := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &EthereumSigner{opts: bind.NewKeyedTransactor(key), chainID: big.NewInt(chainID)}
+}
+
+func TestEthereumSignerEIP155(t *testing.T) {
+	tx := types.NewTransaction(1, ethereum.HexToAddress("0x63825c174ab367968EC60f061753D3bbD36A0D8F"),
+		big.NewInt(1000), 21000, big.NewInt(20000000000), nil)
+
+	var signatures [][]byte
+	for _, chainID := range []int64{1, 3} {
+		signer := newTestSigner(t, chainID)
+		signed, err := signer.Sign(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !signed.Protected() || signed.ChainId().Int64() != chainID {
+			t.Errorf("expected transaction protected for chain %d, got chain %s", chainID, signed.ChainId())
+		}
+		sender, err := types.Sender(types.NewEIP155Signer(big.NewInt(chainID)), signed)
+		if err != nil || sender != signer.GetAddress() {
+			t.Errorf("expected sender %s on chain %d, got %s (%v)", signer.GetAddress().Hex(), chainID, sender.Hex(), err)
+		}
+		v, r, s := signed.RawSignatureValues()
+		signatures = append(signatures, append(append(v.Bytes(), r.Bytes()...), s.Bytes()...))
+	}
+	if bytes.Equal(signatures[0], signatures[1]) {
+		t.Error("expected different signatures on different chains")
+	}
+
+	// a transaction signed for chain 1 can't be replayed on chain 3
+	signed, err := newTestSigner(t, 1).Sign(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = types.Sender(types.NewEIP155Signer(big.NewInt(3)), signed); err == nil {
+		t.Error("expected error recovering sender on other chain")
+	}
+}
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Types of configurations changed by the pending then confirm flows.
+const (
+	ConfigTargetQtyV2        = "target_qty_v2"
+	ConfigPWIEquationV2      = "pwi_equation_v2"
+	ConfigRebalanceQuadratic = "rebalance_quadratic"
+	ConfigStableTokenParams  = "stable_token_params"
+	ConfigTokenUpdate        = "token_update"
+	ConfigTokenPricing       = "token_pricing"
+	ConfigTokenDelisting     = "token_delisting"
+	ConfigSetRateGuard       = "set_rate_guard"
+)
+
+// Statuses of a configuration proposal.
+const (
+	ConfigProposalPending    = "pending"
+	ConfigProposalConfirmed  = "confirmed"
+	ConfigProposalRejected   = "rejected"
+	ConfigProposalSuperseded = "superseded"
+)
+
+// ConfigChange is a changed value between the active and proposed
+// configuration. Path is the dot separated keys to the value.
+type ConfigChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// ConfigProposal is a proposed configuration change waiting for or resolved
+// by another key.
+type ConfigProposal struct {
+	ID        uint64          `json:"id"`
+	Type      string          `json:"type"`
+	Proposer  string          `json:"proposer"`
+	Timestamp uint64          `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+	Diff      []ConfigChange  `json:"diff"`
+	Status    string          `json:"status"`
+	// Resolver is the key which confirmed or rejected the proposal.
+	Resolver   string `json:"resolver,omitempty"`
+	ResolvedAt uint64 `json:"resolved_at,omitempty"`
+}
+
+// NewConfigProposal creates a pending proposal with the changes from active to
+// proposed configuration.
+func NewConfigProposal(configType, proposer string, data []byte, active, proposed interface{}) (ConfigProposal, error) {
+	diff, err := DiffConfig(active, proposed)
+	if err != nil {
+		return ConfigProposal{}, err
+	}
+	return ConfigProposal{
+		ID:        GetTimepointInMicrosecond(),
+		Type:      configType,
+		Proposer:  proposer,
+		Timestamp: GetTimepoint(),
+		Data:      json.RawMessage(data),
+		Diff:      diff,
+		Status:    ConfigProposalPending,
+	}, nil
+}
+
+// DiffConfig returns the changed values between the JSON representations of
+// two configurations.
+func DiffConfig(active, proposed interface{}) ([]ConfigChange, error) {
+	var oldValue, newValue interface{}
+	if err := toJSONValue(active, &oldValue); err != nil {
+		return nil, err
+	}
+	if err := toJSONValue(proposed, &newValue); err != nil {
+		return nil, err
+	}
+	changes := []ConfigChange{}
+	diffJSONValue("", oldValue, newValue, &changes)
+	return changes, nil
+}
+
+func toJSONValue(v interface{}, result *interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+func diffJSONValue(path string, oldValue, newValue interface{}, changes *[]ConfigChange) {
+	oldMap, oldIsMap := oldValue.(map[string]interface{})
+	newMap, newIsMap := newValue.(map[string]interface{})
+	// compare a missing object as an empty one to list the changes by key
+	if oldValue == nil && newIsMap {
+		oldMap, oldIsMap = map[string]interface{}{}, true
+	}
+	if newValue == nil && oldIsMap {
+		newMap, newIsMap = map[string]interface{}{}, true
+	}
+	if !oldIsMap || !newIsMap {
+		if !reflect.DeepEqual(oldValue, newValue) {
+			*changes = append(*changes, ConfigChange{Path: path, Old: oldValue, New: newValue})
+		}
+		return
+	}
+	keys := make(map[string]struct{})
+	for k := range oldMap {
+		keys[k] = struct{}{}
+	}
+	for k := range newMap {
+		keys[k] = struct{}{}
+	}
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		subPath := k
+		if path != "" {
+			subPath = path + "." + k
+		}
+		diffJSONValue(subPath, oldMap[k], newMap[k], changes)
+	}
+}
+
+// ConfigVersion is a confirmed version of a configuration. Versions of each
+// configuration type are numbered from 1 in confirming order.
+type ConfigVersion struct {
+	Version   uint64          `json:"version"`
+	Timestamp uint64          `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
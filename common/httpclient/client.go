@@ -0,0 +1,223 @@
+// Package httpclient is the HTTP client of outbound requests to exchanges,
+// feeds and APIs. It retries idempotent requests with backoff, stops sending
+// requests to a failing host for a while so one flapping upstream can't
+// stall the fetchers, limits the request and response sizes and records the
+// latency and errors of each host.
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config is the configuration of a Client.
+type Config struct {
+	// Timeout is the timeout of each attempt of a request.
+	Timeout time.Duration
+	// MaxRetries is the number of retries of an idempotent request failed by
+	// a network error, a 5xx or 429 response. Other requests are not retried.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, it is doubled for
+	// each following retry up to MaxRetryBackoff.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// MaxRequestSize and MaxResponseSize are the largest sizes in bytes of a
+	// request and response body.
+	MaxRequestSize  int64
+	MaxResponseSize int64
+	// BreakerThreshold is the number of consecutive failed attempts to a host
+	// which open its circuit, requests to the host fail without being sent
+	// for BreakerCooldown, then a single request is sent to probe it.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultConfig returns the default configuration, requests time out after
+// timeout.
+func DefaultConfig(timeout time.Duration) Config {
+	return Config{
+		Timeout:          timeout,
+		MaxRetries:       2,
+		RetryBackoff:     500 * time.Millisecond,
+		MaxRetryBackoff:  5 * time.Second,
+		MaxRequestSize:   1 << 20,
+		MaxResponseSize:  16 << 20,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// NoRetryConfig returns the default configuration without retries, for the
+// requests which can't be resent such as signed requests with nonces.
+func NoRetryConfig(timeout time.Duration) Config {
+	config := DefaultConfig(timeout)
+	config.MaxRetries = 0
+	return config
+}
+
+// Response is a response read by Client.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Client is a HTTP client with retries, circuit breakers and metrics by
+// host, it is safe for concurrent use.
+type Client struct {
+	name   string
+	config Config
+	client *http.Client
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*Client
+)
+
+// NewClient returns a client of config, name identifies the client in the
+// metrics.
+func NewClient(name string, config Config) *Client {
+	client := &Client{
+		name:   name,
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		hosts:  map[string]*hostState{},
+	}
+	registryMu.Lock()
+	registry = append(registry, client)
+	registryMu.Unlock()
+	return client
+}
+
+func (self *Client) host(host string) *hostState {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	state, ok := self.hosts[host]
+	if !ok {
+		state = &hostState{}
+		self.hosts[host] = state
+	}
+	return state
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func retryable(resp *Response, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// Do sends req and reads its response. A 5xx response of the last attempt is
+// returned without error, callers check the status code.
+func (self *Client) Do(req *http.Request) (*Response, error) {
+	if req.ContentLength > self.config.MaxRequestSize {
+		return nil, fmt.Errorf("Request to %s is %d bytes, larger than limit %d bytes", req.URL.Host, req.ContentLength, self.config.MaxRequestSize)
+	}
+	state := self.host(req.URL.Host)
+	retries := 0
+	if isIdempotent(req.Method) && (req.Body == nil || req.GetBody != nil) {
+		retries = self.config.MaxRetries
+	}
+	backoff := self.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		if err := state.allow(); err != nil {
+			return nil, fmt.Errorf("Request to %s is rejected: %s", req.URL.Host, err.Error())
+		}
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		start := time.Now()
+		resp, err := self.send(req)
+		failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		state.record(time.Since(start), failed, self.config.BreakerThreshold, self.config.BreakerCooldown)
+		if attempt >= retries || !retryable(resp, err) {
+			return resp, err
+		}
+		state.retried()
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > self.config.MaxRetryBackoff {
+			backoff = self.config.MaxRetryBackoff
+		}
+	}
+}
+
+func (self *Client) send(req *http.Request) (*Response, error) {
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cErr := resp.Body.Close(); cErr != nil {
+			log.Printf("Response body close error: %s", cErr.Error())
+		}
+	}()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, self.config.MaxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > self.config.MaxResponseSize {
+		return nil, fmt.Errorf("Response from %s is larger than limit %d bytes", req.URL.Host, self.config.MaxResponseSize)
+	}
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
+
+// Get sends a GET request to url.
+func (self *Client) Get(url string) (*Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return self.Do(req)
+}
+
+// Stats returns the metrics of the hosts requested by the client.
+func (self *Client) Stats() []HostStats {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := []HostStats{}
+	for host, state := range self.hosts {
+		stats := state.stats()
+		stats.Client = self.name
+		stats.Host = host
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Host < result[j].Host
+	})
+	return result
+}
+
+// Stats returns the metrics of the hosts requested by all clients.
+func Stats() []HostStats {
+	registryMu.Lock()
+	clients := append([]*Client{}, registry...)
+	registryMu.Unlock()
+	result := []HostStats{}
+	for _, client := range clients {
+		result = append(result, client.Stats()...)
+	}
+	return result
+}
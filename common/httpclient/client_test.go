@@ -0,0 +1,123 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	config := DefaultConfig(time.Second)
+	config.RetryBackoff = time.Millisecond
+	config.MaxRetryBackoff = time.Millisecond
+	config.BreakerThreshold = 3
+	config.BreakerCooldown = 100 * time.Millisecond
+	return config
+}
+
+// newFlappingServer returns a server which fails the first failures
+// requests with 503.
+func newFlappingServer(failures int32) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	return server, &requests
+}
+
+func TestClientRetry(t *testing.T) {
+	server, requests := newFlappingServer(2)
+	defer server.Close()
+	client := NewClient("test", testConfig())
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || string(resp.Body) != `{"ok":true}` {
+		t.Fatalf("Unexpected response %d %s", resp.StatusCode, resp.Body)
+	}
+	if *requests != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", *requests)
+	}
+	stats := client.Stats()
+	if len(stats) != 1 || stats[0].Requests != 3 || stats[0].Errors != 2 || stats[0].Retries != 2 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestClientNoRetryForPost(t *testing.T) {
+	server, requests := newFlappingServer(1)
+	defer server.Close()
+	client := NewClient("test", testConfig())
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("order"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || *requests != 1 {
+		t.Fatalf("Expected a single failed attempt, got %d after %d attempts", resp.StatusCode, *requests)
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	server, requests := newFlappingServer(3)
+	defer server.Close()
+	config := testConfig()
+	config.MaxRetries = 0
+	client := NewClient("test", config)
+	for i := 0; i < 3; i++ {
+		if _, err := client.Get(server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the circuit is open after 3 consecutive failures
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), "circuit is open") {
+		t.Fatalf("Expected open circuit error, got %v", err)
+	}
+	if *requests != 3 {
+		t.Fatalf("Expected requests not sent while the circuit is open, got %d", *requests)
+	}
+	if stats := client.Stats(); stats[0].Rejected != 1 || stats[0].OpenUntil == 0 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	time.Sleep(100 * time.Millisecond)
+	resp, err := client.Get(server.URL)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the probe request succeeded, got %v", err)
+	}
+	if stats := client.Stats(); stats[0].OpenUntil != 0 {
+		t.Fatalf("Expected closed circuit, got %+v", stats)
+	}
+}
+
+func TestClientSizeLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("x", 100))
+	}))
+	defer server.Close()
+	config := testConfig()
+	config.MaxRequestSize = 10
+	config.MaxResponseSize = 50
+	client := NewClient("test", config)
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), "larger than limit") {
+		t.Fatalf("Expected response size error, got %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(strings.Repeat("x", 20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Do(req); err == nil || !strings.Contains(err.Error(), "larger than limit") {
+		t.Fatalf("Expected request size error, got %v", err)
+	}
+}
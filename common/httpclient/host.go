@@ -0,0 +1,106 @@
+package httpclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// HostStats is the metrics of requests from a client to a host.
+type HostStats struct {
+	Client string `json:"client"`
+	Host   string `json:"host"`
+	// Requests and Errors are the numbers of sent attempts and the failed
+	// ones by network errors or 5xx responses.
+	Requests uint64 `json:"requests"`
+	Errors   uint64 `json:"errors"`
+	Retries  uint64 `json:"retries"`
+	// Rejected is the number of requests failed without being sent as the
+	// circuit was open.
+	Rejected uint64 `json:"rejected"`
+	// AvgLatency and LastLatency are in millisecond.
+	AvgLatency  float64 `json:"avg_latency"`
+	LastLatency float64 `json:"last_latency"`
+	// OpenUntil is the time in millisecond until the circuit is open, 0 if
+	// it is closed.
+	OpenUntil uint64 `json:"open_until"`
+}
+
+// hostState is the circuit breaker and metrics of a host.
+type hostState struct {
+	mu sync.Mutex
+	// failures is the number of consecutive failed attempts
+	failures  int
+	openUntil time.Time
+	// probing is true while the single request after the cooldown is sent
+	probing bool
+
+	requests     uint64
+	errors       uint64
+	retries      uint64
+	rejected     uint64
+	totalLatency time.Duration
+	lastLatency  time.Duration
+}
+
+// allow returns error if the circuit is open, it lets a single request probe
+// the host after the circuit was open for the cooldown.
+func (self *hostState) allow() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.openUntil.IsZero() {
+		return nil
+	}
+	if time.Now().Before(self.openUntil) || self.probing {
+		self.rejected++
+		return fmt.Errorf("circuit is open after %d consecutive failures", self.failures)
+	}
+	self.probing = true
+	return nil
+}
+
+func (self *hostState) record(latency time.Duration, failed bool, threshold int, cooldown time.Duration) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.requests++
+	self.totalLatency += latency
+	self.lastLatency = latency
+	self.probing = false
+	if !failed {
+		self.failures = 0
+		self.openUntil = time.Time{}
+		return
+	}
+	self.errors++
+	self.failures++
+	if self.failures >= threshold {
+		self.openUntil = time.Now().Add(cooldown)
+	}
+}
+
+func (self *hostState) retried() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.retries++
+}
+
+func (self *hostState) stats() HostStats {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	stats := HostStats{
+		Requests:    self.requests,
+		Errors:      self.errors,
+		Retries:     self.retries,
+		Rejected:    self.rejected,
+		LastLatency: float64(self.lastLatency) / float64(time.Millisecond),
+	}
+	if self.requests > 0 {
+		stats.AvgLatency = float64(self.totalLatency) / float64(self.requests) / float64(time.Millisecond)
+	}
+	if time.Now().Before(self.openUntil) {
+		stats.OpenUntil = common.TimeToTimepoint(self.openUntil)
+	}
+	return stats
+}
@@ -53,6 +53,31 @@ type SetrateControl struct {
 	Status bool `json:"status"`
 }
 
+// SetRateGuard is the configuration of checking the set rates against the
+// mid prices of the exchange order books and the afp mid prices. The
+// deviations are fractions of the mid price, 0.1 is 10%.
+type SetRateGuard struct {
+	// MaxDeviation is the max deviation of the tokens not in
+	// TokenMaxDeviation, 0 disables the guard for them.
+	MaxDeviation      float64            `json:"max_deviation"`
+	TokenMaxDeviation map[string]float64 `json:"token_max_deviation,omitempty"`
+	// MaxPriceAge is the max age in millisecond of an order book to be used.
+	MaxPriceAge uint64 `json:"max_price_age"`
+	// Clamp clamps the rates to the max deviation instead of rejecting them.
+	Clamp bool `json:"clamp"`
+	// AllowMissingPrice allows setting rates of a token without fresh order
+	// book, they are checked against the afp mid price only.
+	AllowMissingPrice bool `json:"allow_missing_price"`
+}
+
+// MaxDeviationOf returns the max deviation of token, 0 means not guarded.
+func (self SetRateGuard) MaxDeviationOf(tokenID string) float64 {
+	if deviation, ok := self.TokenMaxDeviation[tokenID]; ok {
+		return deviation
+	}
+	return self.MaxDeviation
+}
+
 //TargetQtySet represent a set of target quantity
 type TargetQtySet struct {
 	TotalTarget        float64 `json:"total_target"`
@@ -16,6 +16,61 @@ type TokenUpdate struct {
 	PWIEq       PWIEquationTokenV2              `json:"pwis_equation"`
 	TargetQty   TargetQtyV2                     `json:"target_qty"`
 	QuadraticEq RebalanceQuadraticEquation      `json:"rebalance_quadratic"`
+	// ListOnChain lists the token in pricing contract with Pricing
+	// configuration when the update is confirmed.
+	ListOnChain bool                `json:"list_on_chain,omitempty"`
+	Pricing     *TokenPricingConfig `json:"pricing,omitempty"`
+}
+
+// TokenListingStep is a pricing contract transaction of listing a token.
+type TokenListingStep struct {
+	Action     string     `json:"action"`
+	ActivityID ActivityID `json:"activity_id"`
+	Tx         string     `json:"tx"`
+	// MiningStatus is empty if the transaction is not mined in time.
+	MiningStatus string `json:"mining_status"`
+	Error        string `json:"error,omitempty"`
+}
+
+// TokenListing is the outcome of listing a token in pricing contract, it is
+// complete when all steps are mined.
+type TokenListing struct {
+	Token    string             `json:"token"`
+	Steps    []TokenListingStep `json:"steps"`
+	Complete bool               `json:"complete"`
+}
+
+// TokenListingJob is the progress of listing the tokens of a confirmed token
+// update in pricing contract, it is complete when all tokens are listed and
+// the token update is applied. Rollbacks are the transactions disabling the
+// trade of listed tokens when the token update couldn't be applied.
+type TokenListingJob struct {
+	Tokens    []string           `json:"tokens"`
+	Listings  []TokenListing     `json:"listings"`
+	Rollbacks []TokenListingStep `json:"rollbacks,omitempty"`
+	Error     string             `json:"error,omitempty"`
+	Complete  bool               `json:"complete"`
+}
+
+// TokenDelistingStep is an action of delisting a token, ActivityID is the
+// activity of the action if there is one.
+type TokenDelistingStep struct {
+	Action     string     `json:"action"`
+	Exchange   string     `json:"exchange,omitempty"`
+	ActivityID ActivityID `json:"activity_id"`
+	Amount     float64    `json:"amount,omitempty"`
+	// MiningStatus is the status of the transaction of the step waited to
+	// be mined.
+	MiningStatus string `json:"mining_status,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// TokenDelisting is the progress of delisting a token, it is complete when all
+// steps succeeded.
+type TokenDelisting struct {
+	Token    string               `json:"token"`
+	Steps    []TokenDelistingStep `json:"steps"`
+	Complete bool                 `json:"complete"`
 }
 
 type TokenFee struct {
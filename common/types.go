@@ -235,10 +235,12 @@ type ActivityRecord struct {
 	ExchangeStatus string
 	MiningStatus   string
 	Timestamp      Timestamp
+	// KeyID is the ID of the API key which requested the activity.
+	KeyID string `json:",omitempty"`
 }
 
 //New ActivityRecord return an activity record with params["token"] only as token.ID
-func NewActivityRecord(action string, id ActivityID, destination string, params, result map[string]interface{}, exStatus, miStatus string, timestamp Timestamp) ActivityRecord {
+func NewActivityRecord(action string, id ActivityID, destination string, params, result map[string]interface{}, exStatus, miStatus string, timestamp Timestamp, keyID string) ActivityRecord {
 	//if any params is a token, save it as tokenID
 	for k, v := range params {
 		if tok, ok := v.(Token); ok {
@@ -262,6 +264,7 @@ func NewActivityRecord(action string, id ActivityID, destination string, params,
 		ExchangeStatus: exStatus,
 		MiningStatus:   miStatus,
 		Timestamp:      timestamp,
+		KeyID:          keyID,
 	}
 }
 
@@ -279,9 +282,23 @@ func (self ActivityRecord) IsExchangePending() bool {
 	return true
 }
 
+// IsPricingAction returns true if the action is a transaction of the pricing
+// operator to pricing contract.
+func IsPricingAction(action string) bool {
+	switch action {
+	case ActionSetrate, ActionSetQtyStepFunction, ActionSetImbalanceStepFunction, ActionSetTokenControlInfo,
+		ActionAddToken, ActionEnableTokenTrade, ActionDisableTokenTrade, ActionSetBaseRate:
+		return true
+	}
+	return false
+}
+
 func (self ActivityRecord) IsBlockchainPending() bool {
+	if IsPricingAction(self.Action) {
+		return (self.MiningStatus == "" || self.MiningStatus == MiningStatusSubmitted) && self.ExchangeStatus != ExchangeStatusFailed
+	}
 	switch self.Action {
-	case ActionWithdraw, ActionDeposit, ActionSetrate:
+	case ActionWithdraw, ActionDeposit:
 		return (self.MiningStatus == "" || self.MiningStatus == MiningStatusSubmitted) && self.ExchangeStatus != ExchangeStatusFailed
 	}
 	return true
@@ -300,7 +317,11 @@ func (self ActivityRecord) IsPending() bool {
 	case ActionTrade:
 		return (self.ExchangeStatus == "" || self.ExchangeStatus == ExchangeStatusSubmitted) &&
 			self.ExchangeStatus != ExchangeStatusFailed
-	case ActionSetrate:
+	case ActionDelistToken, ActionListToken:
+		// the activities of its steps are followed instead
+		return false
+	}
+	if IsPricingAction(self.Action) {
 		return (self.MiningStatus == "" || self.MiningStatus == MiningStatusSubmitted) &&
 			self.ExchangeStatus != ExchangeStatusFailed
 	}
@@ -598,6 +619,7 @@ type KNLog interface {
 type SetCatLog struct {
 	Timestamp       uint64
 	BlockNumber     uint64
+	BlockHash       ethereum.Hash
 	TransactionHash ethereum.Hash
 	Index           uint
 
@@ -612,6 +634,7 @@ func (self SetCatLog) TxHash() ethereum.Hash { return self.TransactionHash }
 type TradeLog struct {
 	Timestamp       uint64
 	BlockNumber     uint64
+	BlockHash       ethereum.Hash
 	TransactionHash ethereum.Hash
 	Index           uint
 
@@ -787,6 +810,15 @@ type TradeLogGeoInfoResp struct {
 	} `json:"data"`
 }
 
+// GeoRetry is a trade log of which geo enrichment failed and is retried.
+type GeoRetry struct {
+	TxHash   ethereum.Hash `json:"tx_hash"`
+	Attempts int           `json:"attempts"`
+	// NextRetry is the time in milliseconds from which the enrichment is
+	// retried.
+	NextRetry uint64 `json:"next_retry"`
+}
+
 type HeatmapType struct {
 	TotalETHValue        float64 `json:"total_eth_value"`
 	TotalFiatValue       float64 `json:"total_fiat_value"`
@@ -994,6 +1026,25 @@ type StepFunctionResponse struct {
 	ImbalanceStepResponse ImbalanceStepFunction `json:"imbalance_step_function"`
 }
 
+// TokenControlInfo is the imbalance control of a token in pricing contract.
+type TokenControlInfo struct {
+	MinimalRecordResolution *big.Int `json:"minimal_record_resolution"`
+	MaxPerBlockImbalance    *big.Int `json:"max_per_block_imbalance"`
+	MaxTotalImbalance       *big.Int `json:"max_total_imbalance"`
+}
+
+// TokenPricingConfig is the proposed pricing contract configuration of a
+// token, the unset parts are unchanged.
+type TokenPricingConfig struct {
+	QuantityStepFunction  *QuantityStepFunction  `json:"quantity_step_function,omitempty"`
+	ImbalanceStepFunction *ImbalanceStepFunction `json:"imbalance_step_function,omitempty"`
+	TokenControlInfo      *TokenControlInfo      `json:"token_control_info,omitempty"`
+}
+
+// TokenPricingConfigRequest is the proposed pricing contract configuration
+// by token ID.
+type TokenPricingConfigRequest map[string]TokenPricingConfig
+
 type ExportedReserverRateRecord struct {
 	ReserveAddress string
 	Rate           ReserveRates
@@ -14,4 +14,21 @@ const (
 	ActionTrade             = "trade"
 	ActionWithdraw          = "withdraw"
 	ActionSetrate           = "set_rates"
+
+	ActionSetQtyStepFunction       = "set_qty_step_function"
+	ActionSetImbalanceStepFunction = "set_imbalance_step_function"
+	ActionSetTokenControlInfo      = "set_token_control_info"
+	ActionAddToken                 = "add_token"
+	ActionEnableTokenTrade         = "enable_token_trade"
+	ActionDisableTokenTrade        = "disable_token_trade"
+	// ActionSetBaseRate sets the base rates of a token without changing
+	// the compact rates of other tokens.
+	ActionSetBaseRate = "set_base_rate"
+
+	// ActionDelistToken is the composite activity of delisting a token, the
+	// progress of its steps is recorded in its result.
+	ActionDelistToken = "delist_token"
+	// ActionListToken is the composite activity of listing tokens in
+	// pricing contract on token update confirmation.
+	ActionListToken = "list_token"
 )
@@ -14,7 +14,8 @@ type ActivityStorage interface {
 		result map[string]interface{},
 		estatus string,
 		mstatus string,
-		timepoint uint64) error
+		timepoint uint64,
+		keyID string) error
 	HasPendingDeposit(
 		token common.Token, exchange common.Exchange) (bool, error)
 
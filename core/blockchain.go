@@ -24,4 +24,25 @@ type Blockchain interface {
 		nonce *big.Int,
 		gasPrice *big.Int) (*types.Transaction, error)
 	SetRateMinedNonce() (uint64, error)
+	SetQtyStepFunction(
+		token ethereum.Address,
+		xBuy []*big.Int,
+		yBuy []*big.Int,
+		xSell []*big.Int,
+		ySell []*big.Int) (*types.Transaction, error)
+	SetImbalanceStepFunction(
+		token ethereum.Address,
+		xBuy []*big.Int,
+		yBuy []*big.Int,
+		xSell []*big.Int,
+		ySell []*big.Int) (*types.Transaction, error)
+	SetTokenControlInfo(token ethereum.Address, info common.TokenControlInfo) (*types.Transaction, error)
+	AddToken(token ethereum.Address) (*types.Transaction, error)
+	EnableTokenTrade(token ethereum.Address) (*types.Transaction, error)
+	DisableTokenTrade(token ethereum.Address) (*types.Transaction, error)
+	SetBaseRate(token ethereum.Address, buy, sell *big.Int) (*types.Transaction, error)
+	GetTokenBasicData(token ethereum.Address) (bool, bool, error)
+	GetStepFunctions(token ethereum.Address, atBlock uint64) (common.StepFunctionResponse, error)
+	CheckTokenContract(token common.Token) error
+	TxStatus(tx ethereum.Hash) (string, uint64, error)
 }
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// PriceStorage is the storage of the exchange order books.
+type PriceStorage interface {
+	CurrentPriceVersion(timepoint uint64) (common.Version, error)
+	GetAllPrices(common.Version) (common.AllPriceEntry, error)
+}
+
+// RateGuardStorage is the storage of the set rate guard configuration.
+type RateGuardStorage interface {
+	GetSetRateGuard() (common.SetRateGuard, error)
+}
+
+// EnableRateGuard enables checking the set rates against the order books of
+// prices with the configuration in guard.
+func (self *ReserveCore) EnableRateGuard(prices PriceStorage, guard RateGuardStorage) {
+	self.prices = prices
+	self.guard = guard
+}
+
+// bookMidPrice returns the average of the best bid and the best ask of the
+// token-ETH order books returned in maxAge before timepoint, 0 if there is
+// none. The exchanges which order books are too old are returned.
+func bookMidPrice(prices common.AllPriceEntry, token common.Token, timepoint, maxAge uint64) (float64, []string) {
+	var (
+		bestBid, bestAsk = 0.0, math.Inf(1)
+		stale            []string
+	)
+	for exchange, exPrice := range prices.Data[common.NewTokenPairID(token.ID, "ETH")] {
+		if !exPrice.Valid {
+			continue
+		}
+		returnTime, err := strconv.ParseUint(string(exPrice.ReturnTime), 10, 64)
+		if err != nil || returnTime+maxAge < timepoint {
+			stale = append(stale, string(exchange))
+			continue
+		}
+		for _, bid := range exPrice.Bids {
+			bestBid = math.Max(bestBid, bid.Rate)
+		}
+		for _, ask := range exPrice.Asks {
+			if ask.Rate > 0 {
+				bestAsk = math.Min(bestAsk, ask.Rate)
+			}
+		}
+	}
+	if bestBid == 0 || math.IsInf(bestAsk, 1) {
+		return 0, stale
+	}
+	return (bestBid + bestAsk) / 2, stale
+}
+
+// priceToRate converts a price to the 18 decimals integer of set rates,
+// common.FloatToBigInt keeps only 6 decimals which is not enough for the
+// price of a token in ETH.
+func priceToRate(price float64) *big.Int {
+	result, _ := new(big.Float).Mul(big.NewFloat(price), big.NewFloat(1e18)).Int(nil)
+	return result
+}
+
+// guardRates checks the prices of the rates against the mid price of the
+// token-ETH order books and the afp mid price of each token guarded by the
+// set rate guard. A price deviating from a mid price more than the max
+// deviation of the token is clamped or rejected, clamping keeps the ask above
+// the bid and the afp mid. A token without fresh order book is rejected
+// unless missing prices are allowed. All zero rates are not checked.
+//
+// The rates to set are returned with the reasons of the changes to be
+// recorded. With force, the rates are returned as is and the violations are
+// only returned as reasons.
+func (self ReserveCore) guardRates(tokens []common.Token, buys, sells, afpMids []*big.Int, force bool) ([]*big.Int, []*big.Int, []string, error) {
+	reasons := []string{}
+	// mismatched rates are rejected by GetSetRateResult
+	if self.guard == nil || self.prices == nil ||
+		len(buys) != len(tokens) || len(sells) != len(tokens) || len(afpMids) != len(tokens) {
+		return buys, sells, reasons, nil
+	}
+	config, err := self.guard.GetSetRateGuard()
+	if err != nil {
+		return buys, sells, reasons, fmt.Errorf("Couldn't get set rate guard: %s", err)
+	}
+	var (
+		timepoint     = common.GetTimepoint()
+		prices        common.AllPriceEntry
+		priceErr      error
+		guardedBuys   = make([]*big.Int, len(buys))
+		guardedSells  = make([]*big.Int, len(sells))
+		rejected      []string
+		pricesFetched bool
+	)
+	for i, token := range tokens {
+		guardedBuys[i], guardedSells[i] = buys[i], sells[i]
+		deviation := config.MaxDeviationOf(token.ID)
+		if deviation <= 0 || (buys[i].Sign() == 0 && sells[i].Sign() == 0) {
+			continue
+		}
+		if !pricesFetched {
+			pricesFetched = true
+			var version common.Version
+			if version, priceErr = self.prices.CurrentPriceVersion(timepoint); priceErr == nil {
+				prices, priceErr = self.prices.GetAllPrices(version)
+			}
+			if priceErr != nil {
+				log.Printf("Set rate guard couldn't get order books: %s", priceErr)
+			}
+		}
+
+		low, high := 0.0, math.Inf(1)
+		mid, stale := bookMidPrice(prices, token, timepoint, config.MaxPriceAge)
+		if len(stale) != 0 {
+			reasons = append(reasons, fmt.Sprintf("%s: order books of %v are older than %d ms", token.ID, stale, config.MaxPriceAge))
+		}
+		if mid == 0 {
+			reason := fmt.Sprintf("%s: no fresh order book", token.ID)
+			if priceErr != nil {
+				reason = fmt.Sprintf("%s (%s)", reason, priceErr)
+			}
+			if !config.AllowMissingPrice {
+				rejected = append(rejected, reason)
+				continue
+			}
+			reasons = append(reasons, reason+", checked against afp mid only")
+		} else {
+			low, high = math.Max(low, mid*(1-deviation)), math.Min(high, mid*(1+deviation))
+		}
+		afpMid := common.BigToFloat(afpMids[i], 18)
+		if afpMid > 0 {
+			low, high = math.Max(low, afpMid*(1-deviation)), math.Min(high, afpMid*(1+deviation))
+		}
+		if math.IsInf(high, 1) {
+			continue
+		}
+		if low > high {
+			rejected = append(rejected, fmt.Sprintf("%s: order book mid %g and afp mid %g deviate more than %g",
+				token.ID, mid, common.BigToFloat(afpMids[i], 18), deviation))
+			continue
+		}
+
+		// clamp returns the price clamped to the max deviation and whether
+		// it is changed, it is not changed if out of range but rejected.
+		clamp := func(side string, price float64) (float64, bool) {
+			if price >= low && price <= high {
+				return price, false
+			}
+			reason := fmt.Sprintf("%s: %s price %g is out of [%g, %g]", token.ID, side, price, low, high)
+			if !config.Clamp || force {
+				rejected = append(rejected, reason)
+				return price, false
+			}
+			clamped := math.Min(math.Max(price, low), high)
+			reasons = append(reasons, fmt.Sprintf("%s, clamped to %g", reason, clamped))
+			return clamped, true
+		}
+		// the reserve sells token at 1/buy and buys token at sell ETH
+		var (
+			ask, bid               float64
+			askClamped, bidClamped bool
+		)
+		if buys[i].Sign() > 0 {
+			ask, askClamped = clamp("ask", 1/common.BigToFloat(buys[i], 18))
+		}
+		if sells[i].Sign() > 0 {
+			bid, bidClamped = clamp("bid", common.BigToFloat(sells[i], 18))
+		}
+		// sanityCheck requires the ask above the bid and the afp mid, the
+		// clamped prices keep this order by moving the ask up or the bid
+		// down, which only widens the spread.
+		if askClamped && afpMid > 0 && ask <= afpMid {
+			ask, askClamped = 1/common.BigToFloat(buys[i], 18), false
+			reasons = append(reasons, fmt.Sprintf("%s: ask is not clamped to keep it above afp mid %g", token.ID, afpMid))
+		}
+		if (askClamped || bidClamped) && ask > 0 && bid >= ask {
+			origAsk, origBid := 1/common.BigToFloat(buys[i], 18), common.BigToFloat(sells[i], 18)
+			if origBid < origAsk {
+				bid, bidClamped = ask*origBid/origAsk, true
+				reasons = append(reasons, fmt.Sprintf("%s: bid is lowered to %g to keep it below ask %g", token.ID, bid, ask))
+			}
+		}
+		if askClamped {
+			guardedBuys[i] = priceToRate(1 / ask)
+		}
+		if bidClamped {
+			guardedSells[i] = priceToRate(bid)
+		}
+	}
+	if force {
+		for _, reason := range rejected {
+			reasons = append(reasons, reason+", forced")
+		}
+		return buys, sells, reasons, nil
+	}
+	if len(rejected) != 0 {
+		reasons = append(reasons, rejected...)
+		return buys, sells, reasons, fmt.Errorf("Set rate guard rejected rates: %s", strings.Join(rejected, "; "))
+	}
+	return guardedBuys, guardedSells, reasons, nil
+}
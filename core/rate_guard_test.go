@@ -0,0 +1,152 @@
+package core
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type testPriceStorage struct {
+	prices common.AllPriceEntry
+}
+
+func (self testPriceStorage) CurrentPriceVersion(timepoint uint64) (common.Version, error) {
+	return 1, nil
+}
+
+func (self testPriceStorage) GetAllPrices(version common.Version) (common.AllPriceEntry, error) {
+	return self.prices, nil
+}
+
+type testRateGuardStorage struct {
+	guard common.SetRateGuard
+}
+
+func (self testRateGuardStorage) GetSetRateGuard() (common.SetRateGuard, error) {
+	return self.guard, nil
+}
+
+func TestGuardRates(t *testing.T) {
+	var (
+		token = common.NewToken("KNC", "KyberNetwork", "0xdd974d5c2e2928dea5f71b9825b8b646686bd200", 18, true, true, 0)
+		now   = common.GetTimepoint()
+		// mid price of the order book is 0.002 ETH
+		book = func(returnTime uint64) common.AllPriceEntry {
+			return common.AllPriceEntry{Data: map[common.TokenPairID]common.OnePrice{
+				common.NewTokenPairID("KNC", "ETH"): {
+					"binance": common.ExchangePrice{
+						Valid:      true,
+						Bids:       []common.PriceEntry{common.NewPriceEntry(100, 0.0019)},
+						Asks:       []common.PriceEntry{common.NewPriceEntry(100, 0.0021)},
+						ReturnTime: common.Timestamp(strconv.FormatUint(returnTime, 10)),
+					},
+				},
+			}}
+		}
+		guard = common.SetRateGuard{MaxDeviation: 0.1, MaxPriceAge: 60000}
+	)
+
+	var tests = []struct {
+		msg       string
+		guard     common.SetRateGuard
+		prices    common.AllPriceEntry
+		ask, bid  float64
+		afpMid    float64
+		force     bool
+		expectErr bool
+		// expected ask and bid prices set, 0 means unchanged
+		expectAsk, expectBid float64
+		expectReason         string
+	}{
+		{msg: "rates in range", guard: guard, prices: book(now), ask: 0.0021, bid: 0.0019},
+		{msg: "guard disabled", prices: book(now), ask: 0.003, bid: 0.0019},
+		{
+			msg:    "guard disabled for token",
+			guard:  common.SetRateGuard{MaxDeviation: 0.1, TokenMaxDeviation: map[string]float64{"KNC": 0}, MaxPriceAge: 60000},
+			prices: book(now), ask: 0.003, bid: 0.0019,
+		},
+		{msg: "zero rates", guard: guard, prices: book(now)},
+		{msg: "ask out of range", guard: guard, prices: book(now), ask: 0.003, bid: 0.0019, expectErr: true, expectReason: "ask price"},
+		{msg: "bid out of range", guard: guard, prices: book(now), ask: 0.0021, bid: 0.001, expectErr: true, expectReason: "bid price"},
+		{
+			msg:    "ask out of range of token max deviation",
+			guard:  common.SetRateGuard{MaxDeviation: 0.5, TokenMaxDeviation: map[string]float64{"KNC": 0.01}, MaxPriceAge: 60000},
+			prices: book(now), ask: 0.0021, bid: 0.0019, expectErr: true, expectReason: "ask price",
+		},
+		{
+			msg:    "ask clamped",
+			guard:  common.SetRateGuard{MaxDeviation: 0.1, MaxPriceAge: 60000, Clamp: true},
+			prices: book(now), ask: 0.003, bid: 0.0019, expectAsk: 0.0022, expectReason: "clamped",
+		},
+		{
+			msg:    "stale order book",
+			guard:  guard,
+			prices: book(now - 120000), ask: 0.0021, bid: 0.0019, expectErr: true, expectReason: "no fresh order book",
+		},
+		{msg: "missing order book", guard: guard, ask: 0.0021, bid: 0.0019, expectErr: true, expectReason: "no fresh order book"},
+		{
+			msg:    "missing order book allowed",
+			guard:  common.SetRateGuard{MaxDeviation: 0.1, MaxPriceAge: 60000, AllowMissingPrice: true},
+			prices: book(now - 120000), ask: 0.0021, bid: 0.0019, expectReason: "checked against afp mid only",
+		},
+		{
+			// both clamped to 0.0022, the bid keeps its spread below the ask
+			msg:    "ask and bid clamped keep their order",
+			guard:  common.SetRateGuard{MaxDeviation: 0.1, MaxPriceAge: 60000, Clamp: true},
+			prices: book(now), ask: 0.003, bid: 0.0025, expectAsk: 0.0022, expectBid: 0.0022 * 0.0025 / 0.003,
+			expectReason: "bid is lowered",
+		},
+		{
+			// the range is [0.00207, 0.0022], below the afp mid
+			msg:    "ask not clamped below afp mid",
+			guard:  common.SetRateGuard{MaxDeviation: 0.1, MaxPriceAge: 60000, Clamp: true},
+			prices: book(now), ask: 0.003, bid: 0.0021, afpMid: 0.0023, expectReason: "above afp mid",
+		},
+		{msg: "forced", guard: guard, prices: book(now), ask: 0.003, bid: 0.0019, force: true, expectReason: "forced"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) {
+			rc := NewReserveCore(testBlockchain{}, testActivityStorage{}, nil)
+			rc.EnableRateGuard(testPriceStorage{prices: tc.prices}, testRateGuardStorage{guard: tc.guard})
+			buy, sell := big.NewInt(0), big.NewInt(0)
+			if tc.ask > 0 {
+				buy = priceToRate(1 / tc.ask)
+			}
+			if tc.bid > 0 {
+				sell = priceToRate(tc.bid)
+			}
+			afpMid := priceToRate(0.002)
+			if tc.afpMid > 0 {
+				afpMid = priceToRate(tc.afpMid)
+			}
+			buys, sells, reasons, err := rc.guardRates(
+				[]common.Token{token}, []*big.Int{buy}, []*big.Int{sell}, []*big.Int{afpMid}, tc.force)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+			}
+			if tc.expectReason != "" && !strings.Contains(strings.Join(reasons, "; "), tc.expectReason) {
+				t.Errorf("expected reason %q, got %v", tc.expectReason, reasons)
+			}
+			expectBuy, expectSell := buy, sell
+			if tc.expectAsk > 0 {
+				expectBuy = priceToRate(1 / tc.expectAsk)
+			}
+			if tc.expectBid > 0 {
+				expectSell = priceToRate(tc.expectBid)
+			}
+			if buys[0].Cmp(expectBuy) != 0 || sells[0].Cmp(expectSell) != 0 {
+				t.Errorf("expected buy %s sell %s, got buy %s sell %s", expectBuy, expectSell, buys[0], sells[0])
+			}
+			// the guarded rates must pass the sanity check of set rates
+			if err == nil && !tc.force {
+				if sErr := sanityCheck(buys, []*big.Int{afpMid}, sells); sErr != nil {
+					t.Errorf("guarded rates failed sanity check: %s", sErr)
+				}
+			}
+		})
+	}
+}
@@ -16,9 +16,13 @@ import (
 )
 
 const (
-	// highBoundGasPrice is the price we will try to use to get higher priority
-	// than trade tx to avoid price front running from users.
-	highBoundGasPrice float64 = 100.1
+	// DefaultMaxGasPrice is the default price in gwei we will try to use to
+	// get higher priority than trade tx to avoid price front running from
+	// users.
+	DefaultMaxGasPrice float64 = 100.1
+	// replacementBumpPercent is the least gas price increase in percent of
+	// a replacement tx, nodes reject replacements with a lower bump.
+	replacementBumpPercent = 10
 
 	statusFailed    = "failed"
 	statusSubmitted = "submitted"
@@ -29,6 +33,14 @@ type ReserveCore struct {
 	blockchain      Blockchain
 	activityStorage ActivityStorage
 	setting         Setting
+
+	// prices and guard are set by EnableRateGuard, rates are not guarded
+	// without them.
+	prices PriceStorage
+	guard  RateGuardStorage
+
+	// maxGasPrice is the highest gas price in gwei of set rate txs.
+	maxGasPrice float64
 }
 
 func NewReserveCore(
@@ -36,12 +48,19 @@ func NewReserveCore(
 	storage ActivityStorage,
 	setting Setting) *ReserveCore {
 	return &ReserveCore{
-		blockchain,
-		storage,
-		setting,
+		blockchain:      blockchain,
+		activityStorage: storage,
+		setting:         setting,
+		maxGasPrice:     DefaultMaxGasPrice,
 	}
 }
 
+// SetMaxGasPrice sets the highest gas price in gwei set rate txs and their
+// replacements are submitted with.
+func (self *ReserveCore) SetMaxGasPrice(maxGasPrice float64) {
+	self.maxGasPrice = maxGasPrice
+}
+
 func timebasedID(id string) common.ActivityID {
 	return common.NewActivityID(uint64(time.Now().UnixNano()), id)
 }
@@ -73,7 +92,8 @@ func (self ReserveCore) Trade(
 	quote common.Token,
 	rate float64,
 	amount float64,
-	timepoint uint64) (common.ActivityID, float64, float64, bool, error) {
+	timepoint uint64,
+	keyID string) (common.ActivityID, float64, float64, bool, error) {
 	var err error
 
 	recordActivity := func(id, status string, done, remaining float64, finished bool, err error) error {
@@ -111,6 +131,7 @@ func (self ReserveCore) Trade(
 			status,
 			"",
 			timepoint,
+			keyID,
 		)
 	}
 
@@ -145,7 +166,8 @@ func (self ReserveCore) Deposit(
 	exchange common.Exchange,
 	token common.Token,
 	amount *big.Int,
-	timepoint uint64) (common.ActivityID, error) {
+	timepoint uint64,
+	keyID string) (common.ActivityID, error) {
 	address, supported := exchange.Address(token)
 	var (
 		err         error
@@ -182,6 +204,7 @@ func (self ReserveCore) Deposit(
 			"",
 			status,
 			timepoint,
+			keyID,
 		)
 	}
 
@@ -232,7 +255,7 @@ func (self ReserveCore) Deposit(
 
 func (self ReserveCore) Withdraw(
 	exchange common.Exchange, token common.Token,
-	amount *big.Int, timepoint uint64) (common.ActivityID, error) {
+	amount *big.Int, timepoint uint64, keyID string) (common.ActivityID, error) {
 	var err error
 
 	activityRecord := func(id, status string, err error) error {
@@ -260,6 +283,7 @@ func (self ReserveCore) Withdraw(
 			status,
 			"",
 			timepoint,
+			keyID,
 		)
 	}
 
@@ -292,23 +316,40 @@ func (self ReserveCore) Withdraw(
 	return timebasedID(id), err
 }
 
-func calculateNewGasPrice(initPrice *big.Int, count uint64) *big.Int {
-	// in this case after 5 tries the tx is still not mined.
-	// at this point, 100.1 gwei is not enough but it doesn't matter
-	// if the tx is mined or not because users' tx is not mined neither
-	// so we can just increase the gas price a tiny amount (1 gwei) to make
-	// the node accept tx with up to date price
-	if count > 4 {
-		return big.NewInt(0).Add(
-			common.GweiToWei(highBoundGasPrice),
-			common.GweiToWei(float64(count)-4.0))
-	} else {
-		// new = initPrice * (high bound / initPrice)^(step / 4)
-		initPrice := common.BigToFloat(initPrice, 9) // convert Gwei int to float
-		base := highBoundGasPrice / initPrice
-		newPrice := initPrice * math.Pow(base, float64(count)/4.0)
-		return common.FloatToBigInt(newPrice, 9)
+// minReplacementGasPrice returns the lowest gas price a node accepts to
+// replace a pending tx of price.
+func minReplacementGasPrice(price *big.Int) *big.Int {
+	result := new(big.Int).Mul(price, big.NewInt(100+replacementBumpPercent))
+	result.Add(result, big.NewInt(99))
+	return result.Div(result, big.NewInt(100))
+}
+
+// calculateNewGasPrice returns the gas price of the count-th replacement of
+// a set rate tx first submitted with initPrice. The price escalates toward
+// maxGasPrice (gwei) in 4 replacements, every replacement pays at least
+// replacementBumpPercent more than the previous one so the node accepts it.
+// Once maxGasPrice is reached the pending tx can't be replaced anymore and
+// an error is returned.
+func calculateNewGasPrice(initPrice *big.Int, count uint64, maxGasPrice float64) (*big.Int, error) {
+	maxPrice := common.GweiToWei(maxGasPrice)
+	price := new(big.Int).Set(initPrice)
+	initGwei := common.BigToFloat(initPrice, 9) // convert Gwei int to float
+	for step := uint64(1); step <= count; step++ {
+		minPrice := minReplacementGasPrice(price)
+		// new = initPrice * (max / initPrice)^(step / 4)
+		if step >= 4 || initGwei <= 0 || initGwei >= maxGasPrice {
+			price = new(big.Int).Set(maxPrice)
+		} else {
+			price = common.FloatToBigInt(initGwei*math.Pow(maxGasPrice/initGwei, float64(step)/4.0), 9)
+		}
+		if price.Cmp(minPrice) < 0 {
+			price = minPrice
+		}
+		if price.Cmp(maxPrice) > 0 {
+			return nil, fmt.Errorf("Replacing the set rate tx requires gas price %s, higher than max gas price %s", price, maxPrice)
+		}
 	}
+	return price, nil
 }
 
 // return: old nonce, init price, step, error
@@ -382,7 +423,11 @@ func (self ReserveCore) GetSetRateResult(tokens []common.Token,
 		return tx, fmt.Errorf("Couldn't check pending set rate tx pool (%s). Please try later", err.Error())
 	}
 	if oldNonce != nil {
-		newPrice := calculateNewGasPrice(initPrice, count)
+		var newPrice *big.Int
+		newPrice, err = calculateNewGasPrice(initPrice, count, self.maxGasPrice)
+		if err != nil {
+			return tx, fmt.Errorf("Couldn't replace pending set rate tx of nonce %s (%s)", oldNonce, err)
+		}
 		tx, err = self.blockchain.SetRates(
 			tokenAddrs, buys, sells, block,
 			oldNonce,
@@ -401,8 +446,8 @@ func (self ReserveCore) GetSetRateResult(tokens []common.Token,
 	} else {
 		recommendedPrice := self.blockchain.StandardGasPrice()
 		var initPrice *big.Int
-		if recommendedPrice == 0 || recommendedPrice > highBoundGasPrice {
-			initPrice = common.GweiToWei(10)
+		if recommendedPrice == 0 || recommendedPrice > self.maxGasPrice {
+			initPrice = common.GweiToWei(math.Min(10, self.maxGasPrice))
 		} else {
 			initPrice = common.GweiToWei(recommendedPrice)
 		}
@@ -416,13 +461,41 @@ func (self ReserveCore) GetSetRateResult(tokens []common.Token,
 	return tx, err
 }
 
+// SetRates sets the rates of tokens after checking them with the set rate
+// guard.
 func (self ReserveCore) SetRates(
 	tokens []common.Token,
 	buys []*big.Int,
 	sells []*big.Int,
 	block *big.Int,
 	afpMids []*big.Int,
-	additionalMsgs []string) (common.ActivityID, error) {
+	additionalMsgs []string,
+	keyID string) (common.ActivityID, error) {
+	return self.setRates(tokens, buys, sells, block, afpMids, additionalMsgs, keyID, false)
+}
+
+// ForceSetRates sets the rates of tokens as is, the violations of the set
+// rate guard are only recorded in the activity.
+func (self ReserveCore) ForceSetRates(
+	tokens []common.Token,
+	buys []*big.Int,
+	sells []*big.Int,
+	block *big.Int,
+	afpMids []*big.Int,
+	additionalMsgs []string,
+	keyID string) (common.ActivityID, error) {
+	return self.setRates(tokens, buys, sells, block, afpMids, additionalMsgs, keyID, true)
+}
+
+func (self ReserveCore) setRates(
+	tokens []common.Token,
+	buys []*big.Int,
+	sells []*big.Int,
+	block *big.Int,
+	afpMids []*big.Int,
+	additionalMsgs []string,
+	keyID string,
+	force bool) (common.ActivityID, error) {
 
 	var (
 		tx           *types.Transaction
@@ -431,9 +504,13 @@ func (self ReserveCore) SetRates(
 		txprice      string = "0"
 		err          error
 		miningStatus string
+		guardMsgs    []string
 	)
 
-	tx, err = self.GetSetRateResult(tokens, buys, sells, afpMids, block)
+	buys, sells, guardMsgs, err = self.guardRates(tokens, buys, sells, afpMids, force)
+	if err == nil {
+		tx, err = self.GetSetRateResult(tokens, buys, sells, afpMids, block)
+	}
 	if err != nil {
 		miningStatus = common.MiningStatusFailed
 	} else {
@@ -443,7 +520,7 @@ func (self ReserveCore) SetRates(
 		txprice = tx.GasPrice().Text(10)
 	}
 	uid := timebasedID(txhex)
-	err = self.activityStorage.Record(
+	rErr := self.activityStorage.Record(
 		common.ActionSetrate,
 		uid,
 		"blockchain",
@@ -454,6 +531,8 @@ func (self ReserveCore) SetRates(
 			"block":  block,
 			"afpMid": afpMids,
 			"msgs":   additionalMsgs,
+			"guard":  guardMsgs,
+			"force":  force,
 		}, map[string]interface{}{
 			"tx":       txhex,
 			"nonce":    txnonce,
@@ -463,14 +542,110 @@ func (self ReserveCore) SetRates(
 		"",
 		miningStatus,
 		common.GetTimepoint(),
+		keyID,
 	)
 	log.Printf(
 		"Core ----------> Set rates: ==> Result: tx: %s, nonce: %s, price: %s, error: %s",
 		txhex, txnonce, txprice, common.ErrorToString(err),
 	)
+	// a failed set rates is still recorded, its error is returned first
+	if err == nil {
+		err = rErr
+	}
 	return uid, err
 }
 
+// recordPricingTx records the activity of a pricing contract configuration
+// transaction of token, its mining status is followed by the fetcher.
+func (self ReserveCore) recordPricingTx(
+	action string,
+	token common.Token,
+	params map[string]interface{},
+	tx *types.Transaction,
+	txErr error,
+	keyID string) (common.ActivityID, error) {
+	var (
+		txhex        = ethereum.Hash{}.Hex()
+		txnonce      = "0"
+		txprice      = "0"
+		miningStatus = common.MiningStatusFailed
+	)
+	if txErr == nil {
+		miningStatus = common.MiningStatusSubmitted
+		txhex = tx.Hash().Hex()
+		txnonce = strconv.FormatUint(tx.Nonce(), 10)
+		txprice = tx.GasPrice().Text(10)
+	}
+	params["token"] = token
+	uid := timebasedID(txhex)
+	err := self.activityStorage.Record(
+		action,
+		uid,
+		"blockchain",
+		params,
+		map[string]interface{}{
+			"tx":       txhex,
+			"nonce":    txnonce,
+			"gasPrice": txprice,
+			"error":    common.ErrorToString(txErr),
+		},
+		"",
+		miningStatus,
+		common.GetTimepoint(),
+		keyID,
+	)
+	log.Printf(
+		"Core ----------> %s of %s: ==> Result: tx: %s, nonce: %s, price: %s, error: %s",
+		action, token.ID, txhex, txnonce, txprice, common.ErrorToString(txErr),
+	)
+	if txErr != nil {
+		return uid, txErr
+	}
+	return uid, err
+}
+
+// SetQtyStepFunction submits the quantity step function of token to pricing
+// contract.
+func (self ReserveCore) SetQtyStepFunction(token common.Token, stepFunction common.QuantityStepFunction, keyID string) (common.ActivityID, error) {
+	tx, err := self.blockchain.SetQtyStepFunction(
+		ethereum.HexToAddress(token.Address),
+		stepFunction.XBuy, stepFunction.YBuy, stepFunction.XSell, stepFunction.YSell,
+	)
+	return self.recordPricingTx(
+		common.ActionSetQtyStepFunction,
+		token,
+		map[string]interface{}{"step_function": stepFunction},
+		tx, err, keyID,
+	)
+}
+
+// SetImbalanceStepFunction submits the imbalance step function of token to
+// pricing contract.
+func (self ReserveCore) SetImbalanceStepFunction(token common.Token, stepFunction common.ImbalanceStepFunction, keyID string) (common.ActivityID, error) {
+	tx, err := self.blockchain.SetImbalanceStepFunction(
+		ethereum.HexToAddress(token.Address),
+		stepFunction.XBuy, stepFunction.YBuy, stepFunction.XSell, stepFunction.YSell,
+	)
+	return self.recordPricingTx(
+		common.ActionSetImbalanceStepFunction,
+		token,
+		map[string]interface{}{"step_function": stepFunction},
+		tx, err, keyID,
+	)
+}
+
+// SetTokenControlInfo submits the imbalance control of token to pricing
+// contract.
+func (self ReserveCore) SetTokenControlInfo(token common.Token, info common.TokenControlInfo, keyID string) (common.ActivityID, error) {
+	tx, err := self.blockchain.SetTokenControlInfo(ethereum.HexToAddress(token.Address), info)
+	return self.recordPricingTx(
+		common.ActionSetTokenControlInfo,
+		token,
+		map[string]interface{}{"token_control_info": info},
+		tx, err, keyID,
+	)
+}
+
 func sanityCheck(buys, afpMid, sells []*big.Int) error {
 	eth := big.NewFloat(0).SetInt(common.EthToWei(1))
 	for i, s := range sells {
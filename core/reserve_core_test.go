@@ -97,6 +97,50 @@ func (self testBlockchain) SetRates(
 	return tx, nil
 }
 
+func (self testBlockchain) SetQtyStepFunction(token ethereum.Address, xBuy, yBuy, xSell, ySell []*big.Int) (*types.Transaction, error) {
+	return self.SetRates(nil, nil, nil, nil, nil, nil)
+}
+
+func (self testBlockchain) SetImbalanceStepFunction(token ethereum.Address, xBuy, yBuy, xSell, ySell []*big.Int) (*types.Transaction, error) {
+	return self.SetRates(nil, nil, nil, nil, nil, nil)
+}
+
+func (self testBlockchain) SetTokenControlInfo(token ethereum.Address, info common.TokenControlInfo) (*types.Transaction, error) {
+	return self.SetRates(nil, nil, nil, nil, nil, nil)
+}
+
+func (self testBlockchain) AddToken(token ethereum.Address) (*types.Transaction, error) {
+	return self.SetRates(nil, nil, nil, nil, nil, nil)
+}
+
+func (self testBlockchain) EnableTokenTrade(token ethereum.Address) (*types.Transaction, error) {
+	return self.SetRates(nil, nil, nil, nil, nil, nil)
+}
+
+func (self testBlockchain) DisableTokenTrade(token ethereum.Address) (*types.Transaction, error) {
+	return self.SetRates(nil, nil, nil, nil, nil, nil)
+}
+
+func (self testBlockchain) SetBaseRate(token ethereum.Address, buy, sell *big.Int) (*types.Transaction, error) {
+	return self.SetRates(nil, nil, nil, nil, nil, nil)
+}
+
+func (self testBlockchain) GetTokenBasicData(token ethereum.Address) (bool, bool, error) {
+	return false, false, nil
+}
+
+func (self testBlockchain) GetStepFunctions(token ethereum.Address, atBlock uint64) (common.StepFunctionResponse, error) {
+	return common.StepFunctionResponse{}, nil
+}
+
+func (self testBlockchain) CheckTokenContract(token common.Token) error {
+	return nil
+}
+
+func (self testBlockchain) TxStatus(tx ethereum.Hash) (string, uint64, error) {
+	return common.MiningStatusMined, 0, nil
+}
+
 func (self testBlockchain) StandardGasPrice() float64 {
 	return 0
 }
@@ -117,7 +161,8 @@ func (self testActivityStorage) Record(
 	result map[string]interface{},
 	estatus string,
 	mstatus string,
-	timepoint uint64) error {
+	timepoint uint64,
+	keyID string) error {
 	return nil
 }
 
@@ -174,6 +219,7 @@ func TestNotAllowDeposit(t *testing.T) {
 		common.NewToken("OMG", "omise-go", "0x1111111111111111111111111111111111111111", 18, true, true, 0),
 		big.NewInt(10),
 		common.GetTimepoint(),
+		"",
 	)
 	if err == nil {
 		t.Fatalf("Expected to return an error protecting user from deposit when there is another pending deposit")
@@ -183,6 +229,7 @@ func TestNotAllowDeposit(t *testing.T) {
 		common.NewToken("KNC", "Kyber-coin", "0x1111111111111111111111111111111111111111", 18, true, true, 0),
 		big.NewInt(10),
 		common.GetTimepoint(),
+		"",
 	)
 	if err != nil {
 		t.Fatalf("Expected to be able to deposit different token")
@@ -191,20 +238,53 @@ func TestNotAllowDeposit(t *testing.T) {
 
 func TestCalculateNewGasPrice(t *testing.T) {
 	initPrice := common.GweiToWei(1)
-	newPrice := calculateNewGasPrice(initPrice, 0)
-	if newPrice.Cmp(newPrice) != 0 {
+	maxPrice := common.GweiToWei(DefaultMaxGasPrice)
+	newPrice, err := calculateNewGasPrice(initPrice, 0, DefaultMaxGasPrice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newPrice.Cmp(initPrice) != 0 {
 		t.Errorf("new price is not equal to initial price with count == 0")
 	}
 
 	prevPrice := initPrice
-	for count := uint64(1); count < 10; count++ {
-		newPrice = calculateNewGasPrice(initPrice, count)
-		if newPrice.Cmp(prevPrice) != 1 {
-			t.Errorf("new price %s is not higher than previous price %s",
+	for count := uint64(1); count <= 4; count++ {
+		newPrice, err = calculateNewGasPrice(initPrice, count, DefaultMaxGasPrice)
+		if err != nil {
+			t.Fatalf("count %d: %s", count, err)
+		}
+		if newPrice.Cmp(minReplacementGasPrice(prevPrice)) < 0 {
+			t.Errorf("new price %s is not %d%% higher than previous price %s",
 				newPrice.String(),
+				replacementBumpPercent,
 				prevPrice.String())
 		}
+		if newPrice.Cmp(maxPrice) > 0 {
+			t.Errorf("new price %s is higher than max price %s", newPrice, maxPrice)
+		}
 		t.Logf("new price: %s", newPrice.String())
 		prevPrice = newPrice
 	}
+	if prevPrice.Cmp(maxPrice) != 0 {
+		t.Errorf("expected price %s after 4 replacements, got %s", maxPrice, prevPrice)
+	}
+	if newPrice, err = calculateNewGasPrice(initPrice, 5, DefaultMaxGasPrice); err == nil {
+		t.Errorf("expected error replacing tx at max price, got price %s", newPrice)
+	}
+}
+
+func TestCalculateNewGasPriceMinimumBump(t *testing.T) {
+	// escalating from 95 gwei toward 100 gwei is less than 10% per step,
+	// the replacements must still be accepted by the node
+	initPrice := common.GweiToWei(95)
+	newPrice, err := calculateNewGasPrice(initPrice, 1, 110)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := minReplacementGasPrice(initPrice); newPrice.Cmp(expected) != 0 {
+		t.Errorf("expected replacement price %s, got %s", expected, newPrice)
+	}
+	if newPrice, err = calculateNewGasPrice(initPrice, 2, 110); err == nil {
+		t.Errorf("expected error replacing tx above max price, got price %s", newPrice)
+	}
 }
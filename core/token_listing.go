@@ -0,0 +1,226 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// txMinedTimeout is the longest time to wait for a listing transaction
+	// to be mined.
+	txMinedTimeout = 10 * time.Minute
+	// txPollInterval is the interval of polling the status of a listing
+	// transaction.
+	txPollInterval = 5 * time.Second
+)
+
+// waitTxMined polls the status of tx until it is mined, failed or
+// txMinedTimeout passed. A lost tx might be not propagated yet, it is polled
+// until timeout.
+func (self ReserveCore) waitTxMined(tx ethereum.Hash) (string, error) {
+	deadline := time.Now().Add(txMinedTimeout)
+	for {
+		status, _, err := self.blockchain.TxStatus(tx)
+		if err != nil {
+			log.Printf("Getting status of tx %s failed: %s", tx.Hex(), err.Error())
+		}
+		switch status {
+		case common.MiningStatusMined:
+			return status, nil
+		case common.MiningStatusFailed:
+			return status, fmt.Errorf("Tx %s failed", tx.Hex())
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("Tx %s is not mined after %s", tx.Hex(), txMinedTimeout)
+		}
+		time.Sleep(txPollInterval)
+	}
+}
+
+// listingStep submits a transaction of listing token, records its activity
+// and waits for it to be mined.
+func (self ReserveCore) listingStep(
+	listing *common.TokenListing,
+	action string,
+	token common.Token,
+	params map[string]interface{},
+	submit func() (*types.Transaction, error),
+	keyID string) error {
+	tx, err := submit()
+	step := common.TokenListingStep{Action: action}
+	step.ActivityID, err = self.recordPricingTx(action, token, params, tx, err, keyID)
+	if tx != nil {
+		step.Tx = tx.Hash().Hex()
+	}
+	if err == nil {
+		step.MiningStatus, err = self.waitTxMined(tx.Hash())
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	listing.Steps = append(listing.Steps, step)
+	return err
+}
+
+// ListToken lists token in pricing contract by the pricing operator: adds
+// token, sets its control info and step functions then enables its trade.
+// The steps already done on chain are skipped so a failed listing can be
+// retried. A step function not in pricing is set to a flat one if the token
+// doesn't have it yet. The listing stops at the first failed step and is
+// returned incomplete, the token is only enabled in the last step.
+func (self ReserveCore) ListToken(token common.Token, pricing common.TokenPricingConfig, keyID string) (common.TokenListing, error) {
+	var (
+		address = ethereum.HexToAddress(token.Address)
+		listing = common.TokenListing{Token: token.ID, Steps: []common.TokenListingStep{}}
+	)
+	if pricing.TokenControlInfo == nil {
+		return listing, fmt.Errorf("Token control info of %s is required", token.ID)
+	}
+	if err := self.blockchain.CheckTokenContract(token); err != nil {
+		return listing, err
+	}
+	listed, enabled, err := self.blockchain.GetTokenBasicData(address)
+	if err != nil {
+		return listing, err
+	}
+	current, err := self.blockchain.GetStepFunctions(address, 0)
+	if err != nil {
+		return listing, err
+	}
+
+	if !listed {
+		if err = self.listingStep(&listing, common.ActionAddToken, token, map[string]interface{}{},
+			func() (*types.Transaction, error) { return self.blockchain.AddToken(address) }, keyID); err != nil {
+			return listing, err
+		}
+	}
+	info := *pricing.TokenControlInfo
+	if err = self.listingStep(&listing, common.ActionSetTokenControlInfo, token,
+		map[string]interface{}{"token_control_info": info},
+		func() (*types.Transaction, error) { return self.blockchain.SetTokenControlInfo(address, info) }, keyID); err != nil {
+		return listing, err
+	}
+	qty := pricing.QuantityStepFunction
+	if qty == nil && len(current.QuantityStepResponse.YBuy) == 0 {
+		qty = &common.QuantityStepFunction{
+			XBuy: []*big.Int{big.NewInt(0)}, YBuy: []*big.Int{big.NewInt(0)},
+			XSell: []*big.Int{big.NewInt(0)}, YSell: []*big.Int{big.NewInt(0)},
+		}
+	}
+	if qty != nil {
+		if err = self.listingStep(&listing, common.ActionSetQtyStepFunction, token,
+			map[string]interface{}{"step_function": *qty},
+			func() (*types.Transaction, error) {
+				return self.blockchain.SetQtyStepFunction(address, qty.XBuy, qty.YBuy, qty.XSell, qty.YSell)
+			}, keyID); err != nil {
+			return listing, err
+		}
+	}
+	imbalance := pricing.ImbalanceStepFunction
+	if imbalance == nil && len(current.ImbalanceStepResponse.YBuy) == 0 {
+		imbalance = &common.ImbalanceStepFunction{
+			XBuy: []*big.Int{big.NewInt(0)}, YBuy: []*big.Int{big.NewInt(0)},
+			XSell: []*big.Int{big.NewInt(0)}, YSell: []*big.Int{big.NewInt(0)},
+		}
+	}
+	if imbalance != nil {
+		if err = self.listingStep(&listing, common.ActionSetImbalanceStepFunction, token,
+			map[string]interface{}{"step_function": *imbalance},
+			func() (*types.Transaction, error) {
+				return self.blockchain.SetImbalanceStepFunction(address, imbalance.XBuy, imbalance.YBuy, imbalance.XSell, imbalance.YSell)
+			}, keyID); err != nil {
+			return listing, err
+		}
+	}
+	if !enabled {
+		if err = self.listingStep(&listing, common.ActionEnableTokenTrade, token, map[string]interface{}{},
+			func() (*types.Transaction, error) { return self.blockchain.EnableTokenTrade(address) }, keyID); err != nil {
+			return listing, err
+		}
+	}
+	listing.Complete = true
+	return listing, nil
+}
+
+// DisableTokenTrade disables the trade of token in pricing contract.
+func (self ReserveCore) DisableTokenTrade(token common.Token, keyID string) (common.ActivityID, error) {
+	tx, err := self.blockchain.DisableTokenTrade(ethereum.HexToAddress(token.Address))
+	return self.recordPricingTx(common.ActionDisableTokenTrade, token, map[string]interface{}{}, tx, err, keyID)
+}
+
+// WaitTxMined waits for the transaction of pricing activity id to be mined,
+// it returns the mining status.
+func (self ReserveCore) WaitTxMined(id common.ActivityID) (string, error) {
+	return self.waitTxMined(ethereum.HexToHash(id.EID))
+}
+
+// RecordTokenListing records the progress of listing tokens as the composite
+// activity id with status, a new id is created if id is empty.
+func (self ReserveCore) RecordTokenListing(
+	id common.ActivityID,
+	job common.TokenListingJob,
+	status string,
+	keyID string) (common.ActivityID, error) {
+	if id == (common.ActivityID{}) {
+		id = timebasedID(strings.Join(job.Tokens, "-"))
+	}
+	return id, self.activityStorage.Record(
+		common.ActionListToken,
+		id,
+		"core",
+		map[string]interface{}{"tokens": job.Tokens},
+		map[string]interface{}{
+			"listings":  job.Listings,
+			"rollbacks": job.Rollbacks,
+			"error":     job.Error,
+			"complete":  job.Complete,
+		},
+		status,
+		"",
+		common.GetTimepoint(),
+		keyID,
+	)
+}
+
+// ZeroTokenRates sets the base rates of token to zero, the rates of other
+// tokens are not changed.
+func (self ReserveCore) ZeroTokenRates(token common.Token, keyID string) (common.ActivityID, error) {
+	tx, err := self.blockchain.SetBaseRate(ethereum.HexToAddress(token.Address), big.NewInt(0), big.NewInt(0))
+	return self.recordPricingTx(common.ActionSetBaseRate, token,
+		map[string]interface{}{"buy": big.NewInt(0), "sell": big.NewInt(0)}, tx, err, keyID)
+}
+
+// RecordTokenDelisting records the progress of delisting token as the
+// composite activity id with status, a new id is created if id is empty.
+// It is recorded again after each step of the delisting.
+func (self ReserveCore) RecordTokenDelisting(
+	id common.ActivityID,
+	token common.Token,
+	delisting common.TokenDelisting,
+	status string,
+	keyID string) (common.ActivityID, error) {
+	if id == (common.ActivityID{}) {
+		id = timebasedID(token.ID)
+	}
+	return id, self.activityStorage.Record(
+		common.ActionDelistToken,
+		id,
+		"core",
+		map[string]interface{}{"token": token},
+		map[string]interface{}{
+			"steps":    delisting.Steps,
+			"complete": delisting.Complete,
+		},
+		status,
+		"",
+		common.GetTimepoint(),
+		keyID,
+	)
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// testListingBlockchain records the listing transactions submitted to
+// pricing contract.
+type testListingBlockchain struct {
+	testBlockchain
+	listed      bool
+	actions     *[]string
+	failAction  string
+	minedStatus string
+}
+
+func (self testListingBlockchain) submit(action string) (*types.Transaction, error) {
+	*self.actions = append(*self.actions, action)
+	if action == self.failAction {
+		return nil, errors.New("submit failed")
+	}
+	return self.SetRates(nil, nil, nil, nil, nil, nil)
+}
+
+func (self testListingBlockchain) AddToken(token ethereum.Address) (*types.Transaction, error) {
+	return self.submit(common.ActionAddToken)
+}
+
+func (self testListingBlockchain) EnableTokenTrade(token ethereum.Address) (*types.Transaction, error) {
+	return self.submit(common.ActionEnableTokenTrade)
+}
+
+func (self testListingBlockchain) SetTokenControlInfo(token ethereum.Address, info common.TokenControlInfo) (*types.Transaction, error) {
+	return self.submit(common.ActionSetTokenControlInfo)
+}
+
+func (self testListingBlockchain) SetQtyStepFunction(token ethereum.Address, xBuy, yBuy, xSell, ySell []*big.Int) (*types.Transaction, error) {
+	return self.submit(common.ActionSetQtyStepFunction)
+}
+
+func (self testListingBlockchain) SetImbalanceStepFunction(token ethereum.Address, xBuy, yBuy, xSell, ySell []*big.Int) (*types.Transaction, error) {
+	return self.submit(common.ActionSetImbalanceStepFunction)
+}
+
+func (self testListingBlockchain) GetTokenBasicData(token ethereum.Address) (bool, bool, error) {
+	return self.listed, self.listed, nil
+}
+
+func (self testListingBlockchain) GetStepFunctions(token ethereum.Address, atBlock uint64) (common.StepFunctionResponse, error) {
+	if !self.listed {
+		return common.StepFunctionResponse{}, nil
+	}
+	return common.StepFunctionResponse{
+		QuantityStepResponse:  common.QuantityStepFunction{YBuy: []*big.Int{big.NewInt(0)}},
+		ImbalanceStepResponse: common.ImbalanceStepFunction{YBuy: []*big.Int{big.NewInt(0)}},
+	}, nil
+}
+
+func (self testListingBlockchain) TxStatus(tx ethereum.Hash) (string, uint64, error) {
+	return self.minedStatus, 0, nil
+}
+
+func TestListToken(t *testing.T) {
+	txPollInterval = time.Millisecond
+	token := common.NewToken("KNC", "KyberNetwork", "0xdd974d5c2e2928dea5f71b9825b8b646686bd200", 18, true, true, 0)
+	pricing := common.TokenPricingConfig{
+		ImbalanceStepFunction: &common.ImbalanceStepFunction{
+			XBuy: []*big.Int{big.NewInt(100)}, YBuy: []*big.Int{big.NewInt(-10)},
+			XSell: []*big.Int{big.NewInt(-100)}, YSell: []*big.Int{big.NewInt(-10)},
+		},
+		TokenControlInfo: &common.TokenControlInfo{
+			MinimalRecordResolution: big.NewInt(1000),
+			MaxPerBlockImbalance:    big.NewInt(100000),
+			MaxTotalImbalance:       big.NewInt(1000000),
+		},
+	}
+
+	var tests = []struct {
+		msg        string
+		blockchain testListingBlockchain
+		actions    []string
+		complete   bool
+	}{
+		{
+			msg:        "list a new token",
+			blockchain: testListingBlockchain{minedStatus: common.MiningStatusMined},
+			actions: []string{
+				common.ActionAddToken, common.ActionSetTokenControlInfo, common.ActionSetQtyStepFunction,
+				common.ActionSetImbalanceStepFunction, common.ActionEnableTokenTrade,
+			},
+			complete: true,
+		},
+		{
+			msg:        "list an enabled token",
+			blockchain: testListingBlockchain{listed: true, minedStatus: common.MiningStatusMined},
+			actions:    []string{common.ActionSetTokenControlInfo, common.ActionSetImbalanceStepFunction},
+			complete:   true,
+		},
+		{
+			msg:        "failed to submit a step",
+			blockchain: testListingBlockchain{failAction: common.ActionSetQtyStepFunction, minedStatus: common.MiningStatusMined},
+			actions:    []string{common.ActionAddToken, common.ActionSetTokenControlInfo, common.ActionSetQtyStepFunction},
+		},
+		{
+			msg:        "failed to mine a step",
+			blockchain: testListingBlockchain{minedStatus: common.MiningStatusFailed},
+			actions:    []string{common.ActionAddToken},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) {
+			tc.blockchain.actions = &[]string{}
+			core := NewReserveCore(tc.blockchain, testActivityStorage{}, nil)
+			listing, err := core.ListToken(token, pricing, "")
+			if !reflect.DeepEqual(*tc.blockchain.actions, tc.actions) {
+				t.Errorf("Expected actions %v, got %v", tc.actions, *tc.blockchain.actions)
+			}
+			if listing.Complete != tc.complete || (err == nil) != tc.complete {
+				t.Fatalf("Expected complete %v, got %+v, error %v", tc.complete, listing, err)
+			}
+			if len(listing.Steps) != len(tc.actions) {
+				t.Fatalf("Expected %d steps, got %+v", len(tc.actions), listing.Steps)
+			}
+			if last := listing.Steps[len(listing.Steps)-1]; !tc.complete && last.Error == "" {
+				t.Errorf("Expected error of the failed step, got %+v", last)
+			}
+		})
+	}
+}
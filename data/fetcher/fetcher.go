@@ -311,10 +311,12 @@ func (self *Fetcher) newNonceValidator() func(common.ActivityRecord) bool {
 	}
 
 	return func(act common.ActivityRecord) bool {
-		// this check only works with set rate transaction as:
+		// this check only works with pricing operator transactions as:
 		//   - account nonce is record in result field of activity
 		//   - the SetRateMinedNonce method is available
-		if act.Action != common.ActionSetrate {
+		// disabling token trade is sent by the alerter, which nonce
+		// is not comparable with the mined nonce of pricing operator.
+		if !common.IsPricingAction(act.Action) || act.Action == common.ActionDisableTokenTrade {
 			return false
 		}
 
@@ -337,7 +339,7 @@ func (self *Fetcher) FetchStatusFromBlockchain(pendings []common.ActivityRecord)
 	nonceValidator := self.newNonceValidator()
 
 	for _, activity := range pendings {
-		if activity.IsBlockchainPending() && (activity.Action == common.ActionSetrate || activity.Action == common.ActionDeposit || activity.Action == common.ActionWithdraw) {
+		if activity.IsBlockchainPending() && (common.IsPricingAction(activity.Action) || activity.Action == common.ActionDeposit || activity.Action == common.ActionWithdraw) {
 			var blockNum uint64
 			var status string
 			var err error
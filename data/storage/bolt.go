@@ -56,8 +56,33 @@ const (
 	pendingRebalanceQuadratic = "pending_rebalance_quadratic"
 	// rebalanceQuadratic stores rebalance quadratic equation
 	rebalanceQuadratic = "rebalance_quadratic"
+	// apiKeyBucket stores the API keys managed via admin endpoints, keyed by key ID
+	apiKeyBucket = "api_keys"
+	// configHistoryBucket stores the configuration proposals, keyed by proposal ID
+	configHistoryBucket = "config_history"
+	// configVersionBucket stores the confirmed versions of configurations in
+	// a sub bucket per configuration type, keyed by version number
+	configVersionBucket = "config_versions"
+	// setRateGuardBucket stores the set rate guard configuration
+	setRateGuardBucket = "set_rate_guard"
 )
 
+// versionedConfigs are the configuration types which confirmed versions are kept.
+var versionedConfigs = []string{
+	common.ConfigTargetQtyV2,
+	common.ConfigPWIEquationV2,
+	common.ConfigRebalanceQuadratic,
+}
+
+func checkVersionedConfig(configType string) error {
+	for _, t := range versionedConfigs {
+		if t == configType {
+			return nil
+		}
+	}
+	return fmt.Errorf("Configuration %s is not versioned", configType)
+}
+
 // BoltStorage is the storage implementation of data.Storage interface
 // that uses BoltDB as its storage engine.
 type BoltStorage struct {
@@ -116,6 +141,9 @@ func NewBoltStorage(path string) (*BoltStorage, error) {
 		if _, cErr := tx.CreateBucketIfNotExists([]byte(setrateControl)); cErr != nil {
 			return cErr
 		}
+		if _, cErr := tx.CreateBucketIfNotExists([]byte(setRateGuardBucket)); cErr != nil {
+			return cErr
+		}
 		if _, cErr := tx.CreateBucketIfNotExists([]byte(pwiEquation)); cErr != nil {
 			return cErr
 		}
@@ -153,6 +181,21 @@ func NewBoltStorage(path string) (*BoltStorage, error) {
 		if _, cErr := tx.CreateBucketIfNotExists([]byte(rebalanceQuadratic)); cErr != nil {
 			return cErr
 		}
+		if _, cErr := tx.CreateBucketIfNotExists([]byte(apiKeyBucket)); cErr != nil {
+			return cErr
+		}
+		if _, cErr := tx.CreateBucketIfNotExists([]byte(configHistoryBucket)); cErr != nil {
+			return cErr
+		}
+		vb, cErr := tx.CreateBucketIfNotExists([]byte(configVersionBucket))
+		if cErr != nil {
+			return cErr
+		}
+		for _, configType := range versionedConfigs {
+			if _, cErr = vb.CreateBucketIfNotExists([]byte(configType)); cErr != nil {
+				return cErr
+			}
+		}
 		return nil
 	})
 	if err != nil {
@@ -208,7 +251,7 @@ func (self *BoltStorage) GetGoldInfo(version common.Version) (common.GoldData, e
 		b := tx.Bucket([]byte(goldBucket))
 		data := b.Get(boltutil.Uint64ToBytes(uint64(version)))
 		if data == nil {
-			err = fmt.Errorf("version %s doesn't exist", string(version))
+			err = fmt.Errorf("version %d doesn't exist", version)
 		} else {
 			err = json.Unmarshal(data, &result)
 		}
@@ -317,7 +360,7 @@ func (self *BoltStorage) GetBTCInfo(version common.Version) (common.BTCData, err
 		b := tx.Bucket([]byte(btcBucket))
 		data := b.Get(boltutil.Uint64ToBytes(uint64(version)))
 		if data == nil {
-			return fmt.Errorf("version %s doesn't exist", string(version))
+			return fmt.Errorf("version %d doesn't exist", version)
 		}
 		return json.Unmarshal(data, &result)
 	})
@@ -459,7 +502,7 @@ func (self *BoltStorage) GetAllPrices(version common.Version) (common.AllPriceEn
 		b := tx.Bucket([]byte(priceBucket))
 		data := b.Get(boltutil.Uint64ToBytes(uint64(version)))
 		if data == nil {
-			err = fmt.Errorf("version %s doesn't exist", string(version))
+			err = fmt.Errorf("version %d doesn't exist", version)
 		} else {
 			err = json.Unmarshal(data, &result)
 		}
@@ -475,7 +518,7 @@ func (self *BoltStorage) GetOnePrice(pair common.TokenPairID, version common.Ver
 		b := tx.Bucket([]byte(priceBucket))
 		data := b.Get(boltutil.Uint64ToBytes(uint64(version)))
 		if data == nil {
-			err = fmt.Errorf("version %s doesn't exist", string(version))
+			err = fmt.Errorf("version %d doesn't exist", version)
 		} else {
 			err = json.Unmarshal(data, &result)
 		}
@@ -534,7 +577,7 @@ func (self *BoltStorage) GetAuthData(version common.Version) (common.AuthDataSna
 		b := tx.Bucket([]byte(authDataBucket))
 		data := b.Get(boltutil.Uint64ToBytes(uint64(version)))
 		if data == nil {
-			err = fmt.Errorf("version %s doesn't exist", string(version))
+			err = fmt.Errorf("version %d doesn't exist", version)
 		} else {
 			err = json.Unmarshal(data, &result)
 		}
@@ -588,7 +631,7 @@ func (self *BoltStorage) GetRate(version common.Version) (common.AllRateEntry, e
 		b := tx.Bucket([]byte(rateBucket))
 		data := b.Get(boltutil.Uint64ToBytes(uint64(version)))
 		if data == nil {
-			err = fmt.Errorf("version %s doesn't exist", string(version))
+			err = fmt.Errorf("version %d doesn't exist", version)
 		} else {
 			err = json.Unmarshal(data, &result)
 		}
@@ -659,7 +702,8 @@ func (self *BoltStorage) Record(
 	params map[string]interface{}, result map[string]interface{},
 	estatus string,
 	mstatus string,
-	timepoint uint64) error {
+	timepoint uint64,
+	keyID string) error {
 
 	var err error
 	err = self.db.Update(func(tx *bolt.Tx) error {
@@ -674,6 +718,7 @@ func (self *BoltStorage) Record(
 			estatus,
 			mstatus,
 			common.Timestamp(strconv.FormatUint(timepoint, 10)),
+			keyID,
 		)
 		dataJSON, err = json.Marshal(record)
 		if err != nil {
@@ -1017,20 +1062,29 @@ func (self *BoltStorage) GetTokenTargetQty() (common.TokenTargetQty, error) {
 }
 
 func (self *BoltStorage) GetRebalanceControl() (common.RebalanceControl, error) {
-	var err error
-	var result common.RebalanceControl
+	var (
+		err    error
+		result common.RebalanceControl
+		found  = true
+	)
 	err = self.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(enableRebalance))
 		_, data := b.Cursor().First()
 		if data == nil {
-			result = common.RebalanceControl{
-				Status: false,
-			}
-			return self.StoreRebalanceControl(false)
+			found = false
+			return nil
 		}
 		return json.Unmarshal(data, &result)
 	})
-	return result, err
+	if err != nil || found {
+		return result, err
+	}
+	// store the default value outside of the read transaction
+	// as bolt does not allow opening a write transaction inside it.
+	result = common.RebalanceControl{
+		Status: false,
+	}
+	return result, self.StoreRebalanceControl(false)
 }
 
 func (self *BoltStorage) StoreRebalanceControl(status bool) error {
@@ -1064,20 +1118,29 @@ func (self *BoltStorage) StoreRebalanceControl(status bool) error {
 }
 
 func (self *BoltStorage) GetSetrateControl() (common.SetrateControl, error) {
-	var err error
-	var result common.SetrateControl
+	var (
+		err    error
+		result common.SetrateControl
+		found  = true
+	)
 	err = self.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(setrateControl))
 		_, data := b.Cursor().First()
 		if data == nil {
-			result = common.SetrateControl{
-				Status: false,
-			}
-			return self.StoreSetrateControl(false)
+			found = false
+			return nil
 		}
 		return json.Unmarshal(data, &result)
 	})
-	return result, err
+	if err != nil || found {
+		return result, err
+	}
+	// store the default value outside of the read transaction
+	// as bolt does not allow opening a write transaction inside it.
+	result = common.SetrateControl{
+		Status: false,
+	}
+	return result, self.StoreSetrateControl(false)
 }
 
 func (self *BoltStorage) StoreSetrateControl(status bool) error {
@@ -1111,6 +1174,31 @@ func (self *BoltStorage) StoreSetrateControl(status bool) error {
 	return err
 }
 
+// StoreSetRateGuard replaces the configuration of set rate guard.
+func (self *BoltStorage) StoreSetRateGuard(guard common.SetRateGuard) error {
+	dataJSON, err := json.Marshal(guard)
+	if err != nil {
+		return err
+	}
+	return self.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(setRateGuardBucket)).Put([]byte(setRateGuardBucket), dataJSON)
+	})
+}
+
+// GetSetRateGuard returns the configuration of set rate guard, the zero
+// value is returned if it was never stored.
+func (self *BoltStorage) GetSetRateGuard() (common.SetRateGuard, error) {
+	var result common.SetRateGuard
+	err := self.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(setRateGuardBucket)).Get([]byte(setRateGuardBucket))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &result)
+	})
+	return result, err
+}
+
 func (self *BoltStorage) GetPWIEquation() (common.PWIEquation, error) {
 	var err error
 	var result common.PWIEquation
@@ -1381,6 +1469,9 @@ func (self *BoltStorage) ConfirmTargetQtyV2(value []byte) error {
 		if uErr := b.Put(targetKey, value); uErr != nil {
 			return uErr
 		}
+		if uErr := storeConfigVersion(tx, common.ConfigTargetQtyV2, common.GetTimepoint(), value); uErr != nil {
+			return uErr
+		}
 		pendingBk := tx.Bucket([]byte(pendingTargetQuantityV2))
 		pendingKey := []byte("current_pending_target_qty")
 		return pendingBk.Delete(pendingKey)
@@ -1534,8 +1625,11 @@ func (self *BoltStorage) StorePWIEquationV2(data string) error {
 		if eq := reflect.DeepEqual(currentData, confirmData); !eq {
 			return errors.New("Confirm data does not match pending data")
 		}
-		id := boltutil.Uint64ToBytes(common.GetTimepoint())
-		if uErr := tx.Bucket([]byte(pwiEquationV2)).Put(id, v); uErr != nil {
+		timepoint := common.GetTimepoint()
+		if uErr := tx.Bucket([]byte(pwiEquationV2)).Put(boltutil.Uint64ToBytes(timepoint), v); uErr != nil {
+			return uErr
+		}
+		if uErr := storeConfigVersion(tx, common.ConfigPWIEquationV2, timepoint, v); uErr != nil {
 			return uErr
 		}
 		// remove pending PWI equations request
@@ -1665,8 +1759,11 @@ func (self *BoltStorage) ConfirmRebalanceQuadratic(data []byte) error {
 		if eq := reflect.DeepEqual(currentData, confirmData); !eq {
 			return errors.New("confirm data does not match rebalance quadratic pending data")
 		}
-		id := boltutil.Uint64ToBytes(common.GetTimepoint())
-		if uErr := tx.Bucket([]byte(rebalanceQuadratic)).Put(id, v); uErr != nil {
+		timepoint := common.GetTimepoint()
+		if uErr := tx.Bucket([]byte(rebalanceQuadratic)).Put(boltutil.Uint64ToBytes(timepoint), v); uErr != nil {
+			return uErr
+		}
+		if uErr := storeConfigVersion(tx, common.ConfigRebalanceQuadratic, timepoint, v); uErr != nil {
 			return uErr
 		}
 		// remove pending rebalance quadratic equation
@@ -1783,7 +1880,8 @@ func (self *BoltStorage) RemovePendingTokenUpdateInfo() error {
 }
 
 func (self *BoltStorage) ConfirmTokenUpdateInfo(tarQty common.TokenTargetQtyV2, pwi common.PWIEquationRequestV2, quadEq common.RebalanceQuadraticRequest) error {
-	timeStampKey := boltutil.Uint64ToBytes(common.GetTimepoint())
+	timepoint := common.GetTimepoint()
+	timeStampKey := boltutil.Uint64ToBytes(timepoint)
 	err := self.db.Update(func(tx *bolt.Tx) error {
 		dataJSON, uErr := json.Marshal(tarQty)
 		if uErr != nil {
@@ -1792,16 +1890,192 @@ func (self *BoltStorage) ConfirmTokenUpdateInfo(tarQty common.TokenTargetQtyV2,
 		if uErr = self.storeJSONByteArray(tx, targetQuantityV2, []byte("current_target_qty"), dataJSON); uErr != nil {
 			return uErr
 		}
+		if uErr = storeConfigVersion(tx, common.ConfigTargetQtyV2, timepoint, dataJSON); uErr != nil {
+			return uErr
+		}
 		if dataJSON, uErr = json.Marshal(pwi); uErr != nil {
 			return uErr
 		}
 		if uErr = self.storeJSONByteArray(tx, pwiEquationV2, timeStampKey, dataJSON); uErr != nil {
 			return uErr
 		}
+		if uErr = storeConfigVersion(tx, common.ConfigPWIEquationV2, timepoint, dataJSON); uErr != nil {
+			return uErr
+		}
 		if dataJSON, uErr = json.Marshal(quadEq); uErr != nil {
 			return uErr
 		}
-		return self.storeJSONByteArray(tx, rebalanceQuadratic, timeStampKey, dataJSON)
+		if uErr = self.storeJSONByteArray(tx, rebalanceQuadratic, timeStampKey, dataJSON); uErr != nil {
+			return uErr
+		}
+		return storeConfigVersion(tx, common.ConfigRebalanceQuadratic, timepoint, dataJSON)
 	})
 	return err
 }
+
+// StoreAPIKey creates or replaces the API key with the same ID.
+func (self *BoltStorage) StoreAPIKey(key common.APIKey) error {
+	dataJSON, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return self.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(apiKeyBucket)).Put([]byte(key.ID), dataJSON)
+	})
+}
+
+// GetAPIKeys returns all stored API keys.
+func (self *BoltStorage) GetAPIKeys() ([]common.APIKey, error) {
+	var result []common.APIKey
+	err := self.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(apiKeyBucket)).ForEach(func(_, v []byte) error {
+			var key common.APIKey
+			if vErr := json.Unmarshal(v, &key); vErr != nil {
+				return vErr
+			}
+			result = append(result, key)
+			return nil
+		})
+	})
+	return result, err
+}
+
+// RemoveAPIKey removes the API key of given ID.
+func (self *BoltStorage) RemoveAPIKey(keyID string) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(apiKeyBucket)).Delete([]byte(keyID))
+	})
+}
+
+// StoreConfigProposal creates or replaces the configuration proposal with the same ID.
+func (self *BoltStorage) StoreConfigProposal(proposal common.ConfigProposal) error {
+	dataJSON, err := json.Marshal(proposal)
+	if err != nil {
+		return err
+	}
+	return self.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(configHistoryBucket)).Put(boltutil.Uint64ToBytes(proposal.ID), dataJSON)
+	})
+}
+
+// GetConfigProposals returns the configuration proposals created in [fromTime, toTime].
+func (self *BoltStorage) GetConfigProposals(fromTime, toTime uint64) ([]common.ConfigProposal, error) {
+	result := []common.ConfigProposal{}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(configHistoryBucket)).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var proposal common.ConfigProposal
+			if vErr := json.Unmarshal(v, &proposal); vErr != nil {
+				return vErr
+			}
+			if proposal.Timestamp >= fromTime && proposal.Timestamp <= toTime {
+				result = append(result, proposal)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// GetPendingConfigProposals returns the pending proposals of given configuration type.
+func (self *BoltStorage) GetPendingConfigProposals(configType string) ([]common.ConfigProposal, error) {
+	result := []common.ConfigProposal{}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(configHistoryBucket)).ForEach(func(_, v []byte) error {
+			var proposal common.ConfigProposal
+			if vErr := json.Unmarshal(v, &proposal); vErr != nil {
+				return vErr
+			}
+			if proposal.Type == configType && proposal.Status == common.ConfigProposalPending {
+				result = append(result, proposal)
+			}
+			return nil
+		})
+	})
+	return result, err
+}
+
+// storeConfigVersion stores data as the next version of the configuration type.
+func storeConfigVersion(tx *bolt.Tx, configType string, timepoint uint64, data []byte) error {
+	b := tx.Bucket([]byte(configVersionBucket)).Bucket([]byte(configType))
+	if b == nil {
+		return fmt.Errorf("Configuration %s is not versioned", configType)
+	}
+	version, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	dataJSON, err := json.Marshal(common.ConfigVersion{Version: version, Timestamp: timepoint, Data: data})
+	if err != nil {
+		return err
+	}
+	return b.Put(boltutil.Uint64ToBytes(version), dataJSON)
+}
+
+// configVersions calls fn on the versions of the configuration type from the
+// latest to the oldest until fn returns false.
+func (self *BoltStorage) configVersions(configType string, fn func(common.ConfigVersion) bool) error {
+	return self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(configVersionBucket)).Bucket([]byte(configType))
+		if b == nil {
+			return fmt.Errorf("Configuration %s is not versioned", configType)
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var version common.ConfigVersion
+			if err := json.Unmarshal(v, &version); err != nil {
+				return err
+			}
+			if !fn(version) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// GetConfigVersions returns all confirmed versions of the configuration type, latest first.
+func (self *BoltStorage) GetConfigVersions(configType string) ([]common.ConfigVersion, error) {
+	result := []common.ConfigVersion{}
+	err := self.configVersions(configType, func(version common.ConfigVersion) bool {
+		result = append(result, version)
+		return true
+	})
+	return result, err
+}
+
+// GetConfigVersion returns the given version of the configuration type.
+func (self *BoltStorage) GetConfigVersion(configType string, version uint64) (common.ConfigVersion, error) {
+	var result common.ConfigVersion
+	err := self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(configVersionBucket)).Bucket([]byte(configType))
+		if b == nil {
+			return fmt.Errorf("Configuration %s is not versioned", configType)
+		}
+		v := b.Get(boltutil.Uint64ToBytes(version))
+		if v == nil {
+			return fmt.Errorf("Version %d of %s doesn't exist", version, configType)
+		}
+		return json.Unmarshal(v, &result)
+	})
+	return result, err
+}
+
+// GetConfigVersionAt returns the version of the configuration type which was
+// active at timestamp.
+func (self *BoltStorage) GetConfigVersionAt(configType string, timestamp uint64) (common.ConfigVersion, error) {
+	var (
+		result common.ConfigVersion
+		found  bool
+	)
+	err := self.configVersions(configType, func(v common.ConfigVersion) bool {
+		if v.Timestamp <= timestamp {
+			result, found = v, true
+		}
+		return !found
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("There is no version of %s before timestamp %d", configType, timestamp)
+	}
+	return result, err
+}
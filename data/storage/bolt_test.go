@@ -47,7 +47,8 @@ func TestHasPendingDepositBoltStorage(t *testing.T) {
 		},
 		"",
 		"submitted",
-		common.GetTimepoint())
+		common.GetTimepoint(),
+		"")
 	if err != nil {
 		t.Fatalf("Store activity error: %s", err.Error())
 	}
@@ -100,3 +101,19 @@ func TestConvertTargetQtyV1toV2(t *testing.T) {
 		}
 	}
 }
+
+func TestStorageBoltImplementation(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test_bolt_storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage, err := NewBoltStorage(filepath.Join(tmpDir, "test_bolt.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.NewStorageTestSuite(t, storage, storage, storage, storage, storage).Run()
+
+	if err = os.RemoveAll(tmpDir); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/KyberNetwork/reserve-data/boltutil"
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/boltdb/bolt"
+)
+
+// MigrateBoltToPostgres copies all records of the given bolt storage to the
+// postgres storage. Existing records in postgres with the same keys are
+// overwritten, so it is safe to run the migration multiple times.
+func MigrateBoltToPostgres(src *BoltStorage, dst *PostgresStorage) error {
+	return src.db.View(func(tx *bolt.Tx) error {
+		for _, table := range versionedTables {
+			if err := migrateVersionedBucket(tx, dst, table); err != nil {
+				return err
+			}
+		}
+		if err := migrateRates(tx, dst); err != nil {
+			return err
+		}
+		if err := migrateActivities(tx, dst); err != nil {
+			return err
+		}
+		if err := migrateConfigurations(tx, dst); err != nil {
+			return err
+		}
+		if err := migrateAPIKeys(tx, dst); err != nil {
+			return err
+		}
+		if err := migrateConfigHistory(tx, dst); err != nil {
+			return err
+		}
+		if err := migrateConfigVersions(tx, dst); err != nil {
+			return err
+		}
+		return migrateDisabledFeeds(tx, dst)
+	})
+}
+
+func migrateVersionedBucket(tx *bolt.Tx, dst *PostgresStorage, bucket string) error {
+	var count int
+	err := tx.Bucket([]byte(bucket)).ForEach(func(k, v []byte) error {
+		count++
+		return putVersion(dst.db, bucket, boltutil.BytesToUint64(k), v)
+	})
+	log.Printf("MIGRATION: copied %d records of %s", count, bucket)
+	return err
+}
+
+func migrateRates(tx *bolt.Tx, dst *PostgresStorage) error {
+	var count int
+	err := tx.Bucket([]byte(rateBucket)).ForEach(func(k, v []byte) error {
+		var entry common.AllRateEntry
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return err
+		}
+		count++
+		_, err := dst.db.Exec(`INSERT INTO `+rateBucket+` (timepoint, block_number, data) VALUES ($1, $2, $3)
+			ON CONFLICT (timepoint) DO UPDATE SET block_number = EXCLUDED.block_number, data = EXCLUDED.data`,
+			boltutil.BytesToUint64(k), entry.BlockNumber, string(v))
+		return err
+	})
+	log.Printf("MIGRATION: copied %d records of %s", count, rateBucket)
+	return err
+}
+
+func migrateActivities(tx *bolt.Tx, dst *PostgresStorage) error {
+	var count int
+	pb := tx.Bucket([]byte(pendingActivityBucket))
+	err := tx.Bucket([]byte(activityBucket)).ForEach(func(k, v []byte) error {
+		var record common.ActivityRecord
+		if err := json.Unmarshal(v, &record); err != nil {
+			return err
+		}
+		count++
+		return putActivity(dst.db, record, pb.Get(k) != nil)
+	})
+	log.Printf("MIGRATION: copied %d records of %s", count, activityBucket)
+	return err
+}
+
+func migrateConfigurations(tx *bolt.Tx, dst *PostgresStorage) error {
+	// bucket name -> key of the record in the bucket, nil means the first record
+	configurations := map[string][]byte{
+		enableRebalance:                 nil,
+		setrateControl:                  nil,
+		stableTokenParamsBucket:         boltutil.Uint64ToBytes(1),
+		pendingStatbleTokenParamsBucket: boltutil.Uint64ToBytes(1),
+		pendingTargetQuantityV2:         []byte(pendingTargetQtyKey),
+		targetQuantityV2:                []byte(targetQtyKey),
+		pendingPWIEquationV2:            nil,
+		pendingRebalanceQuadratic:       nil,
+		setRateGuardBucket:              []byte(setRateGuardBucket),
+	}
+	for bucket, key := range configurations {
+		b := tx.Bucket([]byte(bucket))
+		var v []byte
+		if key == nil {
+			_, v = b.Cursor().First()
+		} else {
+			v = b.Get(key)
+		}
+		if v == nil {
+			continue
+		}
+		if err := putConfiguration(dst.db, bucket, v); err != nil {
+			return err
+		}
+		log.Printf("MIGRATION: copied %s", bucket)
+	}
+	return nil
+}
+
+func migrateDisabledFeeds(tx *bolt.Tx, dst *PostgresStorage) error {
+	return tx.Bucket([]byte(disabledFeedsBucket)).ForEach(func(k, _ []byte) error {
+		_, err := dst.db.Exec(`INSERT INTO `+disabledFeedsBucket+` (name) VALUES ($1) ON CONFLICT DO NOTHING`, string(k))
+		return err
+	})
+}
+
+func migrateAPIKeys(tx *bolt.Tx, dst *PostgresStorage) error {
+	var count int
+	err := tx.Bucket([]byte(apiKeyBucket)).ForEach(func(_, v []byte) error {
+		var key common.APIKey
+		if err := json.Unmarshal(v, &key); err != nil {
+			return err
+		}
+		count++
+		return dst.StoreAPIKey(key)
+	})
+	log.Printf("MIGRATION: copied %d records of %s", count, apiKeyBucket)
+	return err
+}
+
+func migrateConfigHistory(tx *bolt.Tx, dst *PostgresStorage) error {
+	var count int
+	err := tx.Bucket([]byte(configHistoryBucket)).ForEach(func(_, v []byte) error {
+		var proposal common.ConfigProposal
+		if err := json.Unmarshal(v, &proposal); err != nil {
+			return err
+		}
+		count++
+		return dst.StoreConfigProposal(proposal)
+	})
+	log.Printf("MIGRATION: copied %d records of %s", count, configHistoryBucket)
+	return err
+}
+
+func migrateConfigVersions(tx *bolt.Tx, dst *PostgresStorage) error {
+	vb := tx.Bucket([]byte(configVersionBucket))
+	for _, configType := range versionedConfigs {
+		var count int
+		err := vb.Bucket([]byte(configType)).ForEach(func(_, v []byte) error {
+			var version common.ConfigVersion
+			if err := json.Unmarshal(v, &version); err != nil {
+				return err
+			}
+			count++
+			_, err := dst.db.Exec(`INSERT INTO `+configVersionBucket+` (type, version, timestamp, data) VALUES ($1, $2, $3, $4)
+				ON CONFLICT (type, version) DO UPDATE SET timestamp = EXCLUDED.timestamp, data = EXCLUDED.data`,
+				configType, version.Version, version.Timestamp, string(version.Data))
+			return err
+		})
+		log.Printf("MIGRATION: copied %d versions of %s", count, configType)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
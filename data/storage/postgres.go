@@ -0,0 +1,1348 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/world"
+	// register postgres driver for database/sql
+	_ "github.com/lib/pq"
+)
+
+const (
+	// configurationTable stores the single value records like rebalance
+	// control or pending requests, keyed by the same names as the bolt buckets.
+	configurationTable string = "configurations"
+
+	// pendingTargetQtyKey and targetQtyKey are the keys the target quantity v2
+	// records are stored with in bolt.
+	pendingTargetQtyKey string = "current_pending_target_qty"
+	targetQtyKey        string = "current_target_qty"
+)
+
+// versionedTables are tables that store JSON data indexed by timepoint, they
+// have the same layout and are named after the corresponding bolt buckets.
+var versionedTables = []string{
+	priceBucket,
+	authDataBucket,
+	metricBucket,
+	metricTargetQuantity,
+	pwiEquation,
+	pwiEquationV2,
+	rebalanceQuadratic,
+	goldBucket,
+	btcBucket,
+}
+
+// PostgresStorage is the storage implementation of data.Storage interface
+// that uses PostgreSQL as its storage engine.
+type PostgresStorage struct {
+	db *sql.DB
+}
+
+// NewPostgresStorage creates a new PostgresStorage instance connecting to the
+// database described by the given data source name. The schema is created if
+// it does not exist yet.
+func NewPostgresStorage(dsn string) (*PostgresStorage, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		return nil, err
+	}
+	storage := &PostgresStorage{db: db}
+	if err = storage.createSchema(); err != nil {
+		return nil, err
+	}
+	return storage, nil
+}
+
+func (self *PostgresStorage) createSchema() error {
+	var stmts []string
+	for _, table := range versionedTables {
+		stmts = append(stmts, fmt.Sprintf(
+			`CREATE TABLE IF NOT EXISTS %s (timepoint BIGINT PRIMARY KEY, data JSONB NOT NULL)`, table))
+	}
+	stmts = append(stmts,
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			timepoint BIGINT PRIMARY KEY,
+			block_number BIGINT NOT NULL,
+			data JSONB NOT NULL)`, rateBucket),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			timepoint BIGINT NOT NULL,
+			eid TEXT COLLATE "C" NOT NULL,
+			action TEXT NOT NULL,
+			destination TEXT NOT NULL,
+			is_pending BOOLEAN NOT NULL,
+			data JSONB NOT NULL,
+			PRIMARY KEY (timepoint, eid))`, activityBucket),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_pending_idx ON %[1]s (is_pending) WHERE is_pending`, activityBucket),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (name TEXT PRIMARY KEY, data JSONB NOT NULL)`, configurationTable),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (name TEXT PRIMARY KEY)`, disabledFeedsBucket),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (key_id TEXT PRIMARY KEY, data JSONB NOT NULL)`, apiKeyBucket),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id BIGINT PRIMARY KEY,
+			type TEXT NOT NULL,
+			status TEXT NOT NULL,
+			timestamp BIGINT NOT NULL,
+			data JSONB NOT NULL)`, configHistoryBucket),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			type TEXT NOT NULL,
+			version BIGINT NOT NULL,
+			timestamp BIGINT NOT NULL,
+			data JSONB NOT NULL,
+			PRIMARY KEY (type, version))`, configVersionBucket),
+	)
+	for _, stmt := range stmts {
+		if _, err := self.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the underlying database connections.
+func (self *PostgresStorage) Close() error {
+	return self.db.Close()
+}
+
+// queryer is the common interface of sql.DB and sql.Tx.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// withTx runs fn in a database transaction, the transaction is committed if fn
+// returns no error and rolled back otherwise.
+func (self *PostgresStorage) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := self.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		if rErr := tx.Rollback(); rErr != nil {
+			log.Printf("Rollback transaction error: %s", rErr.Error())
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// currentVersion returns the most recent time point to the given one in table.
+func currentVersion(q queryer, table string, timepoint uint64) (common.Version, error) {
+	var result uint64
+	err := q.QueryRow(fmt.Sprintf(
+		`SELECT timepoint FROM %s WHERE timepoint <= $1 ORDER BY timepoint DESC LIMIT 1`, table),
+		timepoint).Scan(&result)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("There is no data before timepoint %d", timepoint)
+	}
+	return common.Version(result), err
+}
+
+// getVersion unmarshals the data of given version in table to result.
+func getVersion(q queryer, table string, version common.Version, result interface{}) error {
+	var data []byte
+	err := q.QueryRow(fmt.Sprintf(`SELECT data FROM %s WHERE timepoint = $1`, table),
+		uint64(version)).Scan(&data)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("version %d doesn't exist", version)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+// getLastVersion returns the raw data of the most recent version in table,
+// nil is returned if the table is empty.
+func getLastVersion(q queryer, table string) ([]byte, error) {
+	var data []byte
+	err := q.QueryRow(fmt.Sprintf(`SELECT data FROM %s ORDER BY timepoint DESC LIMIT 1`, table)).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return data, err
+}
+
+// putVersion stores the given JSON data to table at timepoint.
+func putVersion(q queryer, table string, timepoint uint64, data []byte) error {
+	_, err := q.Exec(fmt.Sprintf(`INSERT INTO %s (timepoint, data) VALUES ($1, $2)
+		ON CONFLICT (timepoint) DO UPDATE SET data = EXCLUDED.data`, table),
+		timepoint, string(data))
+	return err
+}
+
+// getConfiguration returns the stored data of given name, nil is returned if
+// it does not exist.
+func getConfiguration(q queryer, name string) ([]byte, error) {
+	var data []byte
+	err := q.QueryRow(fmt.Sprintf(`SELECT data FROM %s WHERE name = $1`, configurationTable), name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return data, err
+}
+
+// putConfiguration stores data with given name, overwrites existing value.
+func putConfiguration(q queryer, name string, data []byte) error {
+	_, err := q.Exec(fmt.Sprintf(`INSERT INTO %s (name, data) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET data = EXCLUDED.data`, configurationTable),
+		name, string(data))
+	return err
+}
+
+// putPendingConfiguration stores data with given name, it returns an error if
+// there is already a record with the same name.
+func putPendingConfiguration(q queryer, name string, data []byte) error {
+	res, err := q.Exec(fmt.Sprintf(`INSERT INTO %s (name, data) VALUES ($1, $2)
+		ON CONFLICT (name) DO NOTHING`, configurationTable),
+		name, string(data))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("Bucket %s has a pending record", name)
+	}
+	return nil
+}
+
+// deleteConfiguration removes the record with given name, it returns false if
+// the record does not exist.
+func deleteConfiguration(q queryer, name string) (bool, error) {
+	res, err := q.Exec(fmt.Sprintf(`DELETE FROM %s WHERE name = $1`, configurationTable), name)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CurrentGoldInfoVersion returns the most recent time point of gold info record.
+// It implements data.GlobalStorage interface.
+func (self *PostgresStorage) CurrentGoldInfoVersion(timepoint uint64) (common.Version, error) {
+	return currentVersion(self.db, goldBucket, timepoint)
+}
+
+// GetGoldInfo returns gold info at given time point. It implements data.GlobalStorage interface.
+func (self *PostgresStorage) GetGoldInfo(version common.Version) (common.GoldData, error) {
+	result := common.GoldData{}
+	err := getVersion(self.db, goldBucket, version, &result)
+	return result, err
+}
+
+// StoreGoldInfo stores the given gold information to database. It implements fetcher.GlobalStorage interface.
+func (self *PostgresStorage) StoreGoldInfo(data common.GoldData) error {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return putVersion(self.db, goldBucket, data.Timestamp, dataJSON)
+}
+
+// CurrentBTCInfoVersion returns the most recent time point of gold info record.
+// It implements data.GlobalStorage interface.
+func (self *PostgresStorage) CurrentBTCInfoVersion(timepoint uint64) (common.Version, error) {
+	return currentVersion(self.db, btcBucket, timepoint)
+}
+
+// GetBTCInfo returns BTC info at given time point. It implements data.GlobalStorage interface.
+func (self *PostgresStorage) GetBTCInfo(version common.Version) (common.BTCData, error) {
+	result := common.BTCData{}
+	err := getVersion(self.db, btcBucket, version, &result)
+	return result, err
+}
+
+// StoreBTCInfo stores the given BTC information to database. It implements fetcher.GlobalStorage interface.
+func (self *PostgresStorage) StoreBTCInfo(data common.BTCData) error {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return putVersion(self.db, btcBucket, data.Timestamp, dataJSON)
+}
+
+func (self *PostgresStorage) UpdateFeedConfiguration(name string, enabled bool) error {
+	var (
+		allFeeds = world.AllFeeds()
+		exists   = false
+	)
+
+	for _, feed := range allFeeds {
+		if strings.ToLower(name) == strings.ToLower(feed) {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		return fmt.Errorf("unknown feed: %q", name)
+	}
+
+	var err error
+	if enabled {
+		_, err = self.db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE name = $1`, disabledFeedsBucket), name)
+	} else {
+		_, err = self.db.Exec(fmt.Sprintf(`INSERT INTO %s (name) VALUES ($1) ON CONFLICT DO NOTHING`, disabledFeedsBucket), name)
+	}
+	return err
+}
+
+func (self *PostgresStorage) GetFeedConfiguration() ([]common.FeedConfiguration, error) {
+	var (
+		allFeeds = world.AllFeeds()
+		results  []common.FeedConfiguration
+	)
+
+	for _, feed := range allFeeds {
+		results = append(results, common.FeedConfiguration{Name: feed, Enabled: true})
+	}
+
+	rows, err := self.db.Query(fmt.Sprintf(`SELECT name FROM %s`, disabledFeedsBucket))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		for i := range results {
+			if strings.ToLower(results[i].Name) == strings.ToLower(name) {
+				results[i].Enabled = false
+				break
+			}
+		}
+	}
+	return results, rows.Err()
+}
+
+func (self *PostgresStorage) ExportExpiredAuthData(currentTime uint64, fileName string) (nRecord uint64, err error) {
+	expiredTimestamp := currentTime - authDataExpiredDuration
+	outFile, err := os.Create(fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cErr := outFile.Close(); cErr != nil {
+			log.Printf("Close file error: %s", cErr.Error())
+		}
+	}()
+
+	rows, err := self.db.Query(fmt.Sprintf(
+		`SELECT timepoint, data FROM %s WHERE timepoint <= $1 ORDER BY timepoint`, authDataBucket),
+		expiredTimestamp)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			timestamp uint64
+			data      []byte
+		)
+		if err = rows.Scan(&timestamp, &data); err != nil {
+			return nRecord, err
+		}
+		temp := common.AuthDataSnapshot{}
+		if err = json.Unmarshal(data, &temp); err != nil {
+			return nRecord, err
+		}
+		record := common.NewAuthDataRecord(
+			common.Timestamp(strconv.FormatUint(timestamp, 10)),
+			temp,
+		)
+		var output []byte
+		if output, err = json.Marshal(record); err != nil {
+			return nRecord, err
+		}
+		if _, err = outFile.WriteString(string(output) + "\n"); err != nil {
+			return nRecord, err
+		}
+		nRecord++
+	}
+	return nRecord, rows.Err()
+}
+
+func (self *PostgresStorage) PruneExpiredAuthData(currentTime uint64) (uint64, error) {
+	expiredTimestamp := currentTime - authDataExpiredDuration
+	res, err := self.db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE timepoint <= $1`, authDataBucket), expiredTimestamp)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return uint64(n), err
+}
+
+func (self *PostgresStorage) CurrentPriceVersion(timepoint uint64) (common.Version, error) {
+	return currentVersion(self.db, priceBucket, timepoint)
+}
+
+//GetAllPrices returns the corresponding AllPriceEntry to a particular Version
+func (self *PostgresStorage) GetAllPrices(version common.Version) (common.AllPriceEntry, error) {
+	result := common.AllPriceEntry{}
+	err := getVersion(self.db, priceBucket, version, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) GetOnePrice(pair common.TokenPairID, version common.Version) (common.OnePrice, error) {
+	result, err := self.GetAllPrices(version)
+	if err != nil {
+		return common.OnePrice{}, err
+	}
+	dataPair, exist := result.Data[pair]
+	if exist {
+		return dataPair, nil
+	}
+	return common.OnePrice{}, errors.New("Pair of token is not supported")
+}
+
+// StorePrice stores the given prices and removes the outdated versions, only
+// maxNumberVersion most recent versions are kept.
+func (self *PostgresStorage) StorePrice(data common.AllPriceEntry, timepoint uint64) error {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return self.withTx(func(tx *sql.Tx) error {
+		if _, uErr := tx.Exec(fmt.Sprintf(`DELETE FROM %[1]s WHERE timepoint NOT IN
+			(SELECT timepoint FROM %[1]s ORDER BY timepoint DESC LIMIT $1)`, priceBucket),
+			maxNumberVersion-1); uErr != nil {
+			return uErr
+		}
+		return putVersion(tx, priceBucket, timepoint, dataJSON)
+	})
+}
+
+func (self *PostgresStorage) CurrentAuthDataVersion(timepoint uint64) (common.Version, error) {
+	return currentVersion(self.db, authDataBucket, timepoint)
+}
+
+func (self *PostgresStorage) GetAuthData(version common.Version) (common.AuthDataSnapshot, error) {
+	result := common.AuthDataSnapshot{}
+	err := getVersion(self.db, authDataBucket, version, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) StoreAuthSnapshot(data *common.AuthDataSnapshot, timepoint uint64) error {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return putVersion(self.db, authDataBucket, timepoint, dataJSON)
+}
+
+//CurrentRateVersion return current rate version
+func (self *PostgresStorage) CurrentRateVersion(timepoint uint64) (common.Version, error) {
+	return currentVersion(self.db, rateBucket, timepoint)
+}
+
+//GetRates return rates history
+func (self *PostgresStorage) GetRates(fromTime, toTime uint64) ([]common.AllRateEntry, error) {
+	result := []common.AllRateEntry{}
+	if toTime-fromTime > maxGetRatesPeriod {
+		return result, fmt.Errorf("Time range is too broad, it must be smaller or equal to %d miliseconds", maxGetRatesPeriod)
+	}
+	rows, err := self.db.Query(fmt.Sprintf(
+		`SELECT data FROM %s WHERE timepoint >= $1 AND timepoint <= $2 ORDER BY timepoint DESC`, rateBucket),
+		fromTime, toTime)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dataJSON []byte
+		if err = rows.Scan(&dataJSON); err != nil {
+			return result, err
+		}
+		data := common.AllRateEntry{}
+		if err = json.Unmarshal(dataJSON, &data); err != nil {
+			return result, err
+		}
+		result = append(result, data)
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) GetRate(version common.Version) (common.AllRateEntry, error) {
+	result := common.AllRateEntry{}
+	err := getVersion(self.db, rateBucket, version, &result)
+	return result, err
+}
+
+//StoreRate store rate history
+func (self *PostgresStorage) StoreRate(data common.AllRateEntry, timepoint uint64) error {
+	log.Printf("Storing rate data to postgres: data(%v), timespoint(%v)", data, timepoint)
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return self.withTx(func(tx *sql.Tx) error {
+		var lastBlock uint64
+		uErr := tx.QueryRow(fmt.Sprintf(
+			`SELECT block_number FROM %s ORDER BY timepoint DESC LIMIT 1 FOR UPDATE`, rateBucket)).Scan(&lastBlock)
+		switch {
+		case uErr == sql.ErrNoRows:
+			log.Printf("Table %s is empty", rateBucket)
+		case uErr != nil:
+			return uErr
+		}
+		// we still update when blocknumber is not changed because we want
+		// to update the version and timestamp so api users will get
+		// the newest data even it is identical to the old one.
+		if lastBlock > data.BlockNumber {
+			return fmt.Errorf("rejected storing rates with smaller block number: %d, stored: %d",
+				data.BlockNumber,
+				lastBlock)
+		}
+		_, uErr = tx.Exec(fmt.Sprintf(`INSERT INTO %s (timepoint, block_number, data) VALUES ($1, $2, $3)
+			ON CONFLICT (timepoint) DO UPDATE SET block_number = EXCLUDED.block_number, data = EXCLUDED.data`, rateBucket),
+			timepoint, data.BlockNumber, string(dataJSON))
+		return uErr
+	})
+}
+
+//Record save activity
+func (self *PostgresStorage) Record(
+	action string,
+	id common.ActivityID,
+	destination string,
+	params map[string]interface{}, result map[string]interface{},
+	estatus string,
+	mstatus string,
+	timepoint uint64,
+	keyID string) error {
+	record := common.NewActivityRecord(
+		action,
+		id,
+		destination,
+		params,
+		result,
+		estatus,
+		mstatus,
+		common.Timestamp(strconv.FormatUint(timepoint, 10)),
+		keyID,
+	)
+	return putActivity(self.db, record, record.IsPending())
+}
+
+// putActivity inserts or replaces the given activity record.
+func putActivity(q queryer, record common.ActivityRecord, isPending bool) error {
+	dataJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(fmt.Sprintf(`INSERT INTO %s (timepoint, eid, action, destination, is_pending, data)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (timepoint, eid) DO UPDATE SET action = EXCLUDED.action, destination = EXCLUDED.destination,
+		is_pending = EXCLUDED.is_pending, data = EXCLUDED.data`, activityBucket),
+		record.ID.Timepoint, record.ID.EID, record.Action, record.Destination, isPending, string(dataJSON))
+	return err
+}
+
+// queryActivities returns the activity records of given query, ordered by the query.
+func queryActivities(q queryer, query string, args ...interface{}) ([]common.ActivityRecord, error) {
+	result := []common.ActivityRecord{}
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dataJSON []byte
+		if err = rows.Scan(&dataJSON); err != nil {
+			return result, err
+		}
+		record := common.ActivityRecord{}
+		if err = json.Unmarshal(dataJSON, &record); err != nil {
+			return result, err
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+//GetActivity get activity
+func (self *PostgresStorage) GetActivity(id common.ActivityID) (common.ActivityRecord, error) {
+	result := common.ActivityRecord{}
+	var dataJSON []byte
+	err := self.db.QueryRow(fmt.Sprintf(`SELECT data FROM %s WHERE timepoint = $1 AND eid = $2`, activityBucket),
+		id.Timepoint, id.EID).Scan(&dataJSON)
+	if err == sql.ErrNoRows {
+		return result, errors.New("Cannot find that activity")
+	}
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(dataJSON, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) GetAllRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error) {
+	if (toTime-fromTime)/1000000 > maxGetRatesPeriod {
+		return []common.ActivityRecord{}, fmt.Errorf("Time range is too broad, it must be smaller or equal to %d miliseconds", maxGetRatesPeriod)
+	}
+	if toTime == 0 {
+		return queryActivities(self.db, fmt.Sprintf(
+			`SELECT data FROM %s WHERE timepoint >= $1 ORDER BY timepoint DESC, eid DESC`, activityBucket),
+			fromTime)
+	}
+	return queryActivities(self.db, fmt.Sprintf(
+		`SELECT data FROM %s WHERE timepoint >= $1 AND (timepoint < $2 OR (timepoint = $2 AND eid = ''))
+		ORDER BY timepoint DESC, eid DESC`, activityBucket),
+		fromTime, toTime)
+}
+
+//PendingSetrate return pending set rate activity
+func (self *PostgresStorage) PendingSetrate(minedNonce uint64) (*common.ActivityRecord, uint64, error) {
+	pendings, err := self.GetPendingActivities()
+	if err != nil {
+		return nil, 0, err
+	}
+	return getFirstAndCountPendingSetrate(pendings, minedNonce)
+}
+
+//GetPendingActivities return pending activities
+func (self *PostgresStorage) GetPendingActivities() ([]common.ActivityRecord, error) {
+	return queryActivities(self.db, fmt.Sprintf(
+		`SELECT data FROM %s WHERE is_pending ORDER BY timepoint DESC, eid DESC`, activityBucket))
+}
+
+//UpdateActivity update activity info
+func (self *PostgresStorage) UpdateActivity(id common.ActivityID, activity common.ActivityRecord) error {
+	return self.withTx(func(tx *sql.Tx) error {
+		var wasPending bool
+		err := tx.QueryRow(fmt.Sprintf(`SELECT is_pending FROM %s WHERE timepoint = $1 AND eid = $2 FOR UPDATE`, activityBucket),
+			id.Timepoint, id.EID).Scan(&wasPending)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		// only keep it pending when it is pending already because
+		// it might be removed if it is replaced by another activity
+		activity.ID = id
+		return putActivity(tx, activity, wasPending && activity.IsPending())
+	})
+}
+
+//HasPendingDeposit check if a deposit is pending
+func (self *PostgresStorage) HasPendingDeposit(token common.Token, exchange common.Exchange) (bool, error) {
+	var count int
+	err := self.db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s
+		WHERE is_pending AND action = $1 AND destination = $2 AND data->'Params'->>'token' = $3`, activityBucket),
+		common.ActionDeposit, string(exchange.ID()), token.ID).Scan(&count)
+	return count > 0, err
+}
+
+//StoreMetric store metric info
+func (self *PostgresStorage) StoreMetric(data *common.MetricEntry, timepoint uint64) error {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return putVersion(self.db, metricBucket, data.Timestamp, dataJSON)
+}
+
+//GetMetric return metric data
+func (self *PostgresStorage) GetMetric(tokens []common.Token, fromTime, toTime uint64) (map[string]common.MetricList, error) {
+	result := map[string]common.MetricList{}
+	for _, tok := range tokens {
+		result[tok.ID] = common.MetricList{}
+	}
+
+	rows, err := self.db.Query(fmt.Sprintf(
+		`SELECT data FROM %s WHERE timepoint >= $1 AND timepoint <= $2 ORDER BY timepoint`, metricBucket),
+		fromTime, toTime)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dataJSON []byte
+		if err = rows.Scan(&dataJSON); err != nil {
+			return result, err
+		}
+		data := common.MetricEntry{}
+		if err = json.Unmarshal(dataJSON, &data); err != nil {
+			return result, err
+		}
+		for tok, m := range data.Data {
+			metricList, found := result[tok]
+			if found {
+				result[tok] = append(metricList, common.TokenMetricResponse{
+					Timestamp: data.Timestamp,
+					AfpMid:    m.AfpMid,
+					Spread:    m.Spread,
+				})
+			}
+		}
+	}
+	return result, rows.Err()
+}
+
+//GetTokenTargetQty get target quantity
+func (self *PostgresStorage) GetTokenTargetQty() (common.TokenTargetQty, error) {
+	tokenTargetQty := common.TokenTargetQty{}
+	version, err := currentVersion(self.db, metricTargetQuantity, common.GetTimepoint())
+	if err != nil {
+		return tokenTargetQty, err
+	}
+	err = getVersion(self.db, metricTargetQuantity, version, &tokenTargetQty)
+	return tokenTargetQty, err
+}
+
+func (self *PostgresStorage) GetRebalanceControl() (common.RebalanceControl, error) {
+	var result common.RebalanceControl
+	data, err := getConfiguration(self.db, enableRebalance)
+	if err != nil {
+		return result, err
+	}
+	if data == nil {
+		result = common.RebalanceControl{
+			Status: false,
+		}
+		return result, self.StoreRebalanceControl(false)
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) StoreRebalanceControl(status bool) error {
+	dataJSON, err := json.Marshal(common.RebalanceControl{
+		Status: status,
+	})
+	if err != nil {
+		return err
+	}
+	return putConfiguration(self.db, enableRebalance, dataJSON)
+}
+
+func (self *PostgresStorage) GetSetrateControl() (common.SetrateControl, error) {
+	var result common.SetrateControl
+	data, err := getConfiguration(self.db, setrateControl)
+	if err != nil {
+		return result, err
+	}
+	if data == nil {
+		result = common.SetrateControl{
+			Status: false,
+		}
+		return result, self.StoreSetrateControl(false)
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) StoreSetrateControl(status bool) error {
+	dataJSON, err := json.Marshal(common.SetrateControl{
+		Status: status,
+	})
+	if err != nil {
+		return err
+	}
+	return putConfiguration(self.db, setrateControl, dataJSON)
+}
+
+// StoreSetRateGuard replaces the configuration of set rate guard.
+func (self *PostgresStorage) StoreSetRateGuard(guard common.SetRateGuard) error {
+	dataJSON, err := json.Marshal(guard)
+	if err != nil {
+		return err
+	}
+	return putConfiguration(self.db, setRateGuardBucket, dataJSON)
+}
+
+// GetSetRateGuard returns the configuration of set rate guard, the zero
+// value is returned if it was never stored.
+func (self *PostgresStorage) GetSetRateGuard() (common.SetRateGuard, error) {
+	var result common.SetRateGuard
+	data, err := getConfiguration(self.db, setRateGuardBucket)
+	if err != nil || data == nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) GetPWIEquation() (common.PWIEquation, error) {
+	var result common.PWIEquation
+	data, err := getLastVersion(self.db, pwiEquation)
+	if err != nil {
+		return result, err
+	}
+	if data == nil {
+		return result, errors.New("There is no equation")
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) SetStableTokenParams(value []byte) error {
+	temp := make(map[string]interface{})
+	if err := json.Unmarshal(value, &temp); err != nil {
+		return fmt.Errorf("Rejected: Data could not be unmarshalled to defined format: %s", err)
+	}
+	if err := putPendingConfiguration(self.db, pendingStatbleTokenParamsBucket, value); err != nil {
+		return errors.New("Currently there is a pending record")
+	}
+	return nil
+}
+
+func (self *PostgresStorage) ConfirmStableTokenParams(value []byte) error {
+	temp := make(map[string]interface{})
+	if err := json.Unmarshal(value, &temp); err != nil {
+		return fmt.Errorf("Rejected: Data could not be unmarshalled to defined format: %s", err)
+	}
+	return self.withTx(func(tx *sql.Tx) error {
+		pendingData, err := getConfiguration(tx, pendingStatbleTokenParamsBucket)
+		if err != nil {
+			return err
+		}
+		pending := make(map[string]interface{})
+		if pendingData != nil {
+			if err = json.Unmarshal(pendingData, &pending); err != nil {
+				return err
+			}
+		}
+		if eq := reflect.DeepEqual(pending, temp); !eq {
+			return errors.New("Rejected: confiming data isn't consistent")
+		}
+		if err = putConfiguration(tx, stableTokenParamsBucket, value); err != nil {
+			return err
+		}
+		_, err = deleteConfiguration(tx, pendingStatbleTokenParamsBucket)
+		return err
+	})
+}
+
+func (self *PostgresStorage) getJSONMapConfiguration(name string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	data, err := getConfiguration(self.db, name)
+	if err != nil || data == nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) GetStableTokenParams() (map[string]interface{}, error) {
+	return self.getJSONMapConfiguration(stableTokenParamsBucket)
+}
+
+func (self *PostgresStorage) GetPendingStableTokenParams() (map[string]interface{}, error) {
+	return self.getJSONMapConfiguration(pendingStatbleTokenParamsBucket)
+}
+
+func (self *PostgresStorage) RemovePendingStableTokenParams() error {
+	found, err := deleteConfiguration(self.db, pendingStatbleTokenParamsBucket)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("Bucket is empty")
+	}
+	return nil
+}
+
+//StorePendingTargetQtyV2 store value into pending target qty v2 bucket
+func (self *PostgresStorage) StorePendingTargetQtyV2(value []byte) error {
+	var pendingData common.TokenTargetQtyV2
+	if err := json.Unmarshal(value, &pendingData); err != nil {
+		return fmt.Errorf("Rejected: Data could not be unmarshalled to defined format: %s", err.Error())
+	}
+	if err := putPendingConfiguration(self.db, pendingTargetQuantityV2, value); err != nil {
+		return fmt.Errorf("Currently there is a pending record")
+	}
+	return nil
+}
+
+//GetPendingTargetQtyV2 return current pending target quantity
+func (self *PostgresStorage) GetPendingTargetQtyV2() (common.TokenTargetQtyV2, error) {
+	result := common.TokenTargetQtyV2{}
+	data, err := getConfiguration(self.db, pendingTargetQuantityV2)
+	if err != nil {
+		return result, err
+	}
+	if data == nil {
+		return result, errors.New("There is no pending target qty")
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+//ConfirmTargetQtyV2 check if confirm data match pending data and save it to confirm bucket
+//remove pending data from pending bucket
+func (self *PostgresStorage) ConfirmTargetQtyV2(value []byte) error {
+	confirmTargetQty := common.TokenTargetQtyV2{}
+	if err := json.Unmarshal(value, &confirmTargetQty); err != nil {
+		return fmt.Errorf("Rejected: Data could not be unmarshalled to defined format: %s", err)
+	}
+	pending, err := self.GetPendingTargetQtyV2()
+	if err != nil {
+		return err
+	}
+	if eq := reflect.DeepEqual(pending, confirmTargetQty); !eq {
+		return fmt.Errorf("Rejected: confiming data isn't consistent")
+	}
+	return self.withTx(func(tx *sql.Tx) error {
+		if uErr := putConfiguration(tx, targetQuantityV2, value); uErr != nil {
+			return uErr
+		}
+		if uErr := putConfigVersion(tx, common.ConfigTargetQtyV2, common.GetTimepoint(), value); uErr != nil {
+			return uErr
+		}
+		_, uErr := deleteConfiguration(tx, pendingTargetQuantityV2)
+		return uErr
+	})
+}
+
+// RemovePendingTargetQtyV2 remove pending data from db
+func (self *PostgresStorage) RemovePendingTargetQtyV2() error {
+	_, err := deleteConfiguration(self.db, pendingTargetQuantityV2)
+	return err
+}
+
+// GetTargetQtyV2 return the current target quantity
+func (self *PostgresStorage) GetTargetQtyV2() (common.TokenTargetQtyV2, error) {
+	result := common.TokenTargetQtyV2{}
+	data, err := getConfiguration(self.db, targetQuantityV2)
+	if err != nil {
+		return result, err
+	}
+	if data != nil {
+		if err = json.Unmarshal(data, &result); err != nil {
+			return result, err
+		}
+	}
+
+	// backward compatible for api v1, see BoltStorage.GetTargetQtyV2
+	if len(result) == 0 {
+		var targetQty common.TokenTargetQty
+		targetQty, err = self.GetTokenTargetQty()
+		if err != nil {
+			return result, err
+		}
+		result = convertTargetQtyV1toV2(targetQty)
+	}
+	return result, nil
+}
+
+// StorePendingPWIEquationV2 stores the given PWIs equation data for later approval.
+// Return error if occur or there is no pending PWIEquation
+func (self *PostgresStorage) StorePendingPWIEquationV2(data []byte) error {
+	if err := putPendingConfiguration(self.db, pendingPWIEquationV2, data); err != nil {
+		return errors.New("pending PWI equation exists")
+	}
+	return nil
+}
+
+// GetPendingPWIEquationV2 returns the stored PWIEquationRequestV2 in database.
+func (self *PostgresStorage) GetPendingPWIEquationV2() (common.PWIEquationRequestV2, error) {
+	var result common.PWIEquationRequestV2
+	data, err := getConfiguration(self.db, pendingPWIEquationV2)
+	if err != nil {
+		return result, err
+	}
+	if data == nil {
+		return result, errors.New("There is no pending equation")
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// RemovePendingPWIEquationV2 deletes the pending equation request.
+func (self *PostgresStorage) RemovePendingPWIEquationV2() error {
+	found, err := deleteConfiguration(self.db, pendingPWIEquationV2)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("There is no pending data")
+	}
+	return nil
+}
+
+// StorePWIEquationV2 moved the pending equation request to
+// pwi_equation_v2 table and remove it from pending if the
+// given data matched what stored.
+func (self *PostgresStorage) StorePWIEquationV2(data string) error {
+	return self.withTx(func(tx *sql.Tx) error {
+		v, err := getConfiguration(tx, pendingPWIEquationV2)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			return errors.New("There is no pending equation")
+		}
+		confirmData := common.PWIEquationRequestV2{}
+		if err = json.Unmarshal([]byte(data), &confirmData); err != nil {
+			return err
+		}
+		currentData := common.PWIEquationRequestV2{}
+		if err = json.Unmarshal(v, &currentData); err != nil {
+			return err
+		}
+		if eq := reflect.DeepEqual(currentData, confirmData); !eq {
+			return errors.New("Confirm data does not match pending data")
+		}
+		timepoint := common.GetTimepoint()
+		if err = putVersion(tx, pwiEquationV2, timepoint, v); err != nil {
+			return err
+		}
+		if err = putConfigVersion(tx, common.ConfigPWIEquationV2, timepoint, v); err != nil {
+			return err
+		}
+		// remove pending PWI equations request
+		_, err = deleteConfiguration(tx, pendingPWIEquationV2)
+		return err
+	})
+}
+
+// GetPWIEquationV2 returns the current PWI equations from database.
+func (self *PostgresStorage) GetPWIEquationV2() (common.PWIEquationRequestV2, error) {
+	var result common.PWIEquationRequestV2
+	v, err := getLastVersion(self.db, pwiEquationV2)
+	if err != nil {
+		return result, err
+	}
+	if v == nil {
+		log.Println("there no equation in pwi_equation_v2, getting from pwi_equation")
+		var eqv1 common.PWIEquation
+		if eqv1, err = self.GetPWIEquation(); err != nil {
+			return nil, err
+		}
+		return convertPWIEquationV1toV2(eqv1.Data)
+	}
+	err = json.Unmarshal(v, &result)
+	return result, err
+}
+
+//StorePendingRebalanceQuadratic store pending data (stand for rebalance quadratic equation) to db
+//data byte for json {"KNC": {"a": 0.9, "b": 1.2, "c": 1.4}}
+func (self *PostgresStorage) StorePendingRebalanceQuadratic(data []byte) error {
+	if err := putPendingConfiguration(self.db, pendingRebalanceQuadratic, data); err != nil {
+		return errors.New("pending rebalance quadratic equation exists")
+	}
+	return nil
+}
+
+//GetPendingRebalanceQuadratic return pending rebalance quadratic equation
+//Return err if occur, or if the DB is empty
+func (self *PostgresStorage) GetPendingRebalanceQuadratic() (common.RebalanceQuadraticRequest, error) {
+	var result common.RebalanceQuadraticRequest
+	data, err := getConfiguration(self.db, pendingRebalanceQuadratic)
+	if err != nil {
+		return result, err
+	}
+	if data == nil {
+		return result, errors.New("there is no pending rebalance quadratic equation")
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+//ConfirmRebalanceQuadratic confirm pending equation save it to confirmed table
+//and remove pending equation
+func (self *PostgresStorage) ConfirmRebalanceQuadratic(data []byte) error {
+	return self.withTx(func(tx *sql.Tx) error {
+		v, err := getConfiguration(tx, pendingRebalanceQuadratic)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			return errors.New("there is no pending rebalance quadratic equation")
+		}
+		confirmData := common.RebalanceQuadraticRequest{}
+		if err = json.Unmarshal(data, &confirmData); err != nil {
+			return err
+		}
+		currentData := common.RebalanceQuadraticRequest{}
+		if err = json.Unmarshal(v, &currentData); err != nil {
+			return err
+		}
+		if eq := reflect.DeepEqual(currentData, confirmData); !eq {
+			return errors.New("confirm data does not match rebalance quadratic pending data")
+		}
+		timepoint := common.GetTimepoint()
+		if err = putVersion(tx, rebalanceQuadratic, timepoint, v); err != nil {
+			return err
+		}
+		if err = putConfigVersion(tx, common.ConfigRebalanceQuadratic, timepoint, v); err != nil {
+			return err
+		}
+		// remove pending rebalance quadratic equation
+		_, err = deleteConfiguration(tx, pendingRebalanceQuadratic)
+		return err
+	})
+}
+
+//RemovePendingRebalanceQuadratic remove pending rebalance quadratic equation
+//use when admin want to reject a config for rebalance quadratic equation
+func (self *PostgresStorage) RemovePendingRebalanceQuadratic() error {
+	found, err := deleteConfiguration(self.db, pendingRebalanceQuadratic)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("there no pending rebalance quadratic equation to delete")
+	}
+	return nil
+}
+
+//GetRebalanceQuadratic return current confirm rebalance quadratic equation
+func (self *PostgresStorage) GetRebalanceQuadratic() (common.RebalanceQuadraticRequest, error) {
+	var result common.RebalanceQuadraticRequest
+	v, err := getLastVersion(self.db, rebalanceQuadratic)
+	if err != nil {
+		return result, err
+	}
+	if v == nil {
+		return result, errors.New("there is no rebalance quadratic equation")
+	}
+	err = json.Unmarshal(v, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) StorePendingTokenUpdateInfo(tarQty common.TokenTargetQtyV2, pwi common.PWIEquationRequestV2, quadEq common.RebalanceQuadraticRequest) error {
+	return self.withTx(func(tx *sql.Tx) error {
+		dataJSON, uErr := json.Marshal(tarQty)
+		if uErr != nil {
+			return uErr
+		}
+		if uErr = putPendingConfiguration(tx, pendingTargetQuantityV2, dataJSON); uErr != nil {
+			return uErr
+		}
+		if dataJSON, uErr = json.Marshal(pwi); uErr != nil {
+			return uErr
+		}
+		if uErr = putPendingConfiguration(tx, pendingPWIEquationV2, dataJSON); uErr != nil {
+			return uErr
+		}
+		if dataJSON, uErr = json.Marshal(quadEq); uErr != nil {
+			return uErr
+		}
+		return putPendingConfiguration(tx, pendingRebalanceQuadratic, dataJSON)
+	})
+}
+
+func (self *PostgresStorage) RemovePendingTokenUpdateInfo() error {
+	return self.withTx(func(tx *sql.Tx) error {
+		for _, name := range []string{pendingTargetQuantityV2, pendingPWIEquationV2, pendingRebalanceQuadratic} {
+			found, uErr := deleteConfiguration(tx, name)
+			if uErr != nil {
+				return uErr
+			}
+			if !found {
+				return fmt.Errorf("Bucket %s is empty", name)
+			}
+		}
+		return nil
+	})
+}
+
+func (self *PostgresStorage) ConfirmTokenUpdateInfo(tarQty common.TokenTargetQtyV2, pwi common.PWIEquationRequestV2, quadEq common.RebalanceQuadraticRequest) error {
+	timepoint := common.GetTimepoint()
+	return self.withTx(func(tx *sql.Tx) error {
+		dataJSON, uErr := json.Marshal(tarQty)
+		if uErr != nil {
+			return uErr
+		}
+		if uErr = putConfiguration(tx, targetQuantityV2, dataJSON); uErr != nil {
+			return uErr
+		}
+		if uErr = putConfigVersion(tx, common.ConfigTargetQtyV2, timepoint, dataJSON); uErr != nil {
+			return uErr
+		}
+		if dataJSON, uErr = json.Marshal(pwi); uErr != nil {
+			return uErr
+		}
+		if uErr = putVersion(tx, pwiEquationV2, timepoint, dataJSON); uErr != nil {
+			return uErr
+		}
+		if uErr = putConfigVersion(tx, common.ConfigPWIEquationV2, timepoint, dataJSON); uErr != nil {
+			return uErr
+		}
+		if dataJSON, uErr = json.Marshal(quadEq); uErr != nil {
+			return uErr
+		}
+		if uErr = putVersion(tx, rebalanceQuadratic, timepoint, dataJSON); uErr != nil {
+			return uErr
+		}
+		return putConfigVersion(tx, common.ConfigRebalanceQuadratic, timepoint, dataJSON)
+	})
+}
+
+// StoreAPIKey creates or replaces the API key with the same ID.
+func (self *PostgresStorage) StoreAPIKey(key common.APIKey) error {
+	dataJSON, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(fmt.Sprintf(`INSERT INTO %s (key_id, data) VALUES ($1, $2)
+		ON CONFLICT (key_id) DO UPDATE SET data = EXCLUDED.data`, apiKeyBucket),
+		key.ID, string(dataJSON))
+	return err
+}
+
+// GetAPIKeys returns all stored API keys.
+func (self *PostgresStorage) GetAPIKeys() ([]common.APIKey, error) {
+	rows, err := self.db.Query(fmt.Sprintf(`SELECT data FROM %s ORDER BY key_id`, apiKeyBucket))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []common.APIKey
+	for rows.Next() {
+		var (
+			data []byte
+			key  common.APIKey
+		)
+		if err = rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, &key); err != nil {
+			return nil, err
+		}
+		result = append(result, key)
+	}
+	return result, rows.Err()
+}
+
+// RemoveAPIKey removes the API key of given ID.
+func (self *PostgresStorage) RemoveAPIKey(keyID string) error {
+	_, err := self.db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE key_id = $1`, apiKeyBucket), keyID)
+	return err
+}
+
+// StoreConfigProposal creates or replaces the configuration proposal with the same ID.
+func (self *PostgresStorage) StoreConfigProposal(proposal common.ConfigProposal) error {
+	dataJSON, err := json.Marshal(proposal)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(fmt.Sprintf(`INSERT INTO %s (id, type, status, timestamp, data) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, data = EXCLUDED.data`, configHistoryBucket),
+		proposal.ID, proposal.Type, proposal.Status, proposal.Timestamp, string(dataJSON))
+	return err
+}
+
+func queryConfigProposals(q queryer, query string, args ...interface{}) ([]common.ConfigProposal, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []common.ConfigProposal{}
+	for rows.Next() {
+		var (
+			data     []byte
+			proposal common.ConfigProposal
+		)
+		if err = rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, &proposal); err != nil {
+			return nil, err
+		}
+		result = append(result, proposal)
+	}
+	return result, rows.Err()
+}
+
+// GetConfigProposals returns the configuration proposals created in [fromTime, toTime].
+func (self *PostgresStorage) GetConfigProposals(fromTime, toTime uint64) ([]common.ConfigProposal, error) {
+	return queryConfigProposals(self.db, fmt.Sprintf(
+		`SELECT data FROM %s WHERE timestamp >= $1 AND timestamp <= $2 ORDER BY id`, configHistoryBucket),
+		fromTime, toTime)
+}
+
+// GetPendingConfigProposals returns the pending proposals of given configuration type.
+func (self *PostgresStorage) GetPendingConfigProposals(configType string) ([]common.ConfigProposal, error) {
+	return queryConfigProposals(self.db, fmt.Sprintf(
+		`SELECT data FROM %s WHERE type = $1 AND status = $2 ORDER BY id`, configHistoryBucket),
+		configType, common.ConfigProposalPending)
+}
+
+// putConfigVersion stores data as the next version of the configuration type.
+func putConfigVersion(q queryer, configType string, timepoint uint64, data []byte) error {
+	if err := checkVersionedConfig(configType); err != nil {
+		return err
+	}
+	_, err := q.Exec(fmt.Sprintf(`INSERT INTO %[1]s (type, version, timestamp, data)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3 FROM %[1]s WHERE type = $1`, configVersionBucket),
+		configType, timepoint, string(data))
+	return err
+}
+
+func queryConfigVersions(q queryer, configType, query string, args ...interface{}) ([]common.ConfigVersion, error) {
+	if err := checkVersionedConfig(configType); err != nil {
+		return nil, err
+	}
+	rows, err := q.Query(query, append([]interface{}{configType}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []common.ConfigVersion{}
+	for rows.Next() {
+		var (
+			data    []byte
+			version common.ConfigVersion
+		)
+		if err = rows.Scan(&version.Version, &version.Timestamp, &data); err != nil {
+			return nil, err
+		}
+		version.Data = json.RawMessage(data)
+		result = append(result, version)
+	}
+	return result, rows.Err()
+}
+
+// GetConfigVersions returns all confirmed versions of the configuration type, latest first.
+func (self *PostgresStorage) GetConfigVersions(configType string) ([]common.ConfigVersion, error) {
+	return queryConfigVersions(self.db, configType, fmt.Sprintf(
+		`SELECT version, timestamp, data FROM %s WHERE type = $1 ORDER BY version DESC`, configVersionBucket))
+}
+
+// GetConfigVersion returns the given version of the configuration type.
+func (self *PostgresStorage) GetConfigVersion(configType string, version uint64) (common.ConfigVersion, error) {
+	versions, err := queryConfigVersions(self.db, configType, fmt.Sprintf(
+		`SELECT version, timestamp, data FROM %s WHERE type = $1 AND version = $2`, configVersionBucket),
+		version)
+	if err != nil {
+		return common.ConfigVersion{}, err
+	}
+	if len(versions) == 0 {
+		return common.ConfigVersion{}, fmt.Errorf("Version %d of %s doesn't exist", version, configType)
+	}
+	return versions[0], nil
+}
+
+// GetConfigVersionAt returns the version of the configuration type which was
+// active at timestamp.
+func (self *PostgresStorage) GetConfigVersionAt(configType string, timestamp uint64) (common.ConfigVersion, error) {
+	versions, err := queryConfigVersions(self.db, configType, fmt.Sprintf(
+		`SELECT version, timestamp, data FROM %s WHERE type = $1 AND timestamp <= $2
+		ORDER BY version DESC LIMIT 1`, configVersionBucket),
+		timestamp)
+	if err != nil {
+		return common.ConfigVersion{}, err
+	}
+	if len(versions) == 0 {
+		return common.ConfigVersion{}, fmt.Errorf("There is no version of %s before timestamp %d", configType, timestamp)
+	}
+	return versions[0], nil
+}
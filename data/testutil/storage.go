@@ -0,0 +1,542 @@
+package testutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/core"
+	"github.com/KyberNetwork/reserve-data/data"
+	"github.com/KyberNetwork/reserve-data/data/fetcher"
+	"github.com/KyberNetwork/reserve-data/http"
+	"github.com/KyberNetwork/reserve-data/metric"
+)
+
+// StorageTestSuite is a test helper for the data storage implementations, it
+// is run against every implementation to make sure they behave the same.
+type StorageTestSuite struct {
+	t  *testing.T
+	ds data.Storage
+	fs fetcher.Storage
+	as core.ActivityStorage
+	ms metric.MetricStorage
+	ks http.APIKeyStorage
+}
+
+// NewStorageTestSuite creates a new test suite with given implementations of data.Storage, fetcher.Storage,
+// core.ActivityStorage, metric.MetricStorage and http.APIKeyStorage.
+func NewStorageTestSuite(t *testing.T, ds data.Storage, fs fetcher.Storage, as core.ActivityStorage,
+	ms metric.MetricStorage, ks http.APIKeyStorage) *StorageTestSuite {
+	t.Helper()
+	return &StorageTestSuite{
+		t:  t,
+		ds: ds,
+		fs: fs,
+		as: as,
+		ms: ms,
+		ks: ks,
+	}
+}
+
+// Run executes test suite.
+func (ts *StorageTestSuite) Run() {
+	ts.t.Helper()
+	ts.testPrices()
+	ts.testRates()
+	ts.testAuthData()
+	ts.testActivities()
+	ts.testControls()
+	ts.testTargetQtyV2()
+	ts.testPWIEquationV2()
+	ts.testRebalanceQuadratic()
+	ts.testTokenUpdateInfo()
+	ts.testConfigVersions()
+	ts.testAPIKeys()
+	ts.testConfigHistory()
+}
+
+func (ts *StorageTestSuite) testPrices() {
+	ts.t.Helper()
+	pair := common.NewTokenPairID("KNC", "ETH")
+	for i, timepoint := range []uint64{1000, 2000} {
+		entry := common.AllPriceEntry{
+			Block: uint64(i),
+			Data: map[common.TokenPairID]common.OnePrice{
+				pair: {"binance": common.ExchangePrice{Valid: true, Bids: []common.PriceEntry{{Quantity: 1, Rate: float64(i)}}}},
+			},
+		}
+		if err := ts.fs.StorePrice(entry, timepoint); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+	version, err := ts.ds.CurrentPriceVersion(1500)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if version != 1000 {
+		ts.t.Errorf("expected price version 1000, got %d", version)
+	}
+	onePrice, err := ts.ds.GetOnePrice(pair, 2000)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if onePrice["binance"].Bids[0].Rate != 1 {
+		ts.t.Errorf("getting wrong price: %+v", onePrice)
+	}
+	if _, err = ts.ds.CurrentPriceVersion(999); err == nil {
+		ts.t.Error("expected error getting price version before first record")
+	}
+}
+
+func (ts *StorageTestSuite) testRates() {
+	ts.t.Helper()
+	for _, block := range []uint64{10, 11} {
+		if err := ts.fs.StoreRate(common.AllRateEntry{BlockNumber: block}, block*100); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+	if err := ts.fs.StoreRate(common.AllRateEntry{BlockNumber: 9}, 1200); err == nil {
+		ts.t.Error("expected rates with smaller block number to be rejected")
+	}
+	rates, err := ts.ds.GetRates(0, 2000)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if len(rates) != 2 || rates[0].BlockNumber != 11 {
+		ts.t.Errorf("expected 2 rates with latest first, got %+v", rates)
+	}
+	version, err := ts.ds.CurrentRateVersion(2000)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	rate, err := ts.ds.GetRate(version)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if rate.BlockNumber != 11 {
+		ts.t.Errorf("expected latest rate block number 11, got %d", rate.BlockNumber)
+	}
+}
+
+func (ts *StorageTestSuite) testAuthData() {
+	ts.t.Helper()
+	snapshot := &common.AuthDataSnapshot{Valid: true, Block: 100}
+	if err := ts.fs.StoreAuthSnapshot(snapshot, 5000); err != nil {
+		ts.t.Fatal(err)
+	}
+	version, err := ts.fs.CurrentAuthDataVersion(6000)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	result, err := ts.ds.GetAuthData(version)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if result.Block != snapshot.Block {
+		ts.t.Errorf("expected auth data block %d, got %d", snapshot.Block, result.Block)
+	}
+}
+
+func (ts *StorageTestSuite) testActivities() {
+	ts.t.Helper()
+	token := common.NewToken("OMG", "Omise-go", "0x1111111111111111111111111111111111111111", 18, true, true, 0)
+	exchange := common.TestExchange{}
+	depositID := common.NewActivityID(1, "deposit")
+	err := ts.as.Record(
+		common.ActionDeposit,
+		depositID,
+		string(exchange.ID()),
+		map[string]interface{}{"exchange": exchange, "token": token, "amount": "1.0"},
+		map[string]interface{}{"tx": "", "error": nil},
+		"",
+		common.MiningStatusSubmitted,
+		common.GetTimepoint(),
+		"operator")
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	setRateID := common.NewActivityID(2, "setrate")
+	err = ts.as.Record(
+		common.ActionSetrate,
+		setRateID,
+		"blockchain",
+		map[string]interface{}{},
+		map[string]interface{}{"nonce": "5", "gasPrice": "100"},
+		"",
+		common.MiningStatusSubmitted,
+		common.GetTimepoint(),
+		"")
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+
+	pending, err := ts.as.HasPendingDeposit(token, exchange)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if !pending {
+		ts.t.Error("expected pending deposit")
+	}
+
+	pendings, err := ts.fs.GetPendingActivities()
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if len(pendings) != 2 || pendings[0].ID != setRateID {
+		ts.t.Errorf("expected 2 pending activities with latest first, got %+v", pendings)
+	}
+
+	setRate, count, err := ts.as.PendingSetrate(5)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if setRate == nil || setRate.ID != setRateID || count != 1 {
+		ts.t.Errorf("expected one pending set rate, got %+v, count %d", setRate, count)
+	}
+
+	record, err := ts.as.GetActivity(depositID)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if record.KeyID != "operator" {
+		ts.t.Errorf("expected activity key id operator, got %s", record.KeyID)
+	}
+	record.MiningStatus = common.MiningStatusMined
+	record.ExchangeStatus = common.ExchangeStatusDone
+	if err = ts.fs.UpdateActivity(depositID, record); err != nil {
+		ts.t.Fatal(err)
+	}
+	if pending, err = ts.as.HasPendingDeposit(token, exchange); err != nil {
+		ts.t.Fatal(err)
+	}
+	if pending {
+		ts.t.Error("expected no pending deposit after it is done")
+	}
+	if record, err = ts.as.GetActivity(depositID); err != nil {
+		ts.t.Fatal(err)
+	}
+	if record.ExchangeStatus != common.ExchangeStatusDone {
+		ts.t.Errorf("expected updated activity, got %+v", record)
+	}
+
+	records, err := ts.ds.GetAllRecords(0, 3)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if len(records) != 2 {
+		ts.t.Errorf("expected 2 activities, got %d", len(records))
+	}
+	if _, err = ts.as.GetActivity(common.NewActivityID(3, "unknown")); err == nil {
+		ts.t.Error("expected error getting unknown activity")
+	}
+}
+
+func (ts *StorageTestSuite) testControls() {
+	ts.t.Helper()
+	rebalance, err := ts.ms.GetRebalanceControl()
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if rebalance.Status {
+		ts.t.Error("expected rebalance to be disabled by default")
+	}
+	if err = ts.ms.StoreRebalanceControl(true); err != nil {
+		ts.t.Fatal(err)
+	}
+	if rebalance, err = ts.ms.GetRebalanceControl(); err != nil {
+		ts.t.Fatal(err)
+	}
+	if !rebalance.Status {
+		ts.t.Error("expected rebalance to be enabled")
+	}
+	if err = ts.ms.StoreSetrateControl(true); err != nil {
+		ts.t.Fatal(err)
+	}
+	setrate, err := ts.ms.GetSetrateControl()
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if !setrate.Status {
+		ts.t.Error("expected set rate to be enabled")
+	}
+	guard, err := ts.ms.GetSetRateGuard()
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if guard.MaxDeviationOf("KNC") != 0 {
+		ts.t.Errorf("expected set rate guard to be disabled by default, got %+v", guard)
+	}
+	guard = common.SetRateGuard{MaxDeviation: 0.1, TokenMaxDeviation: map[string]float64{"KNC": 0.05}, MaxPriceAge: 60000}
+	if err = ts.ms.StoreSetRateGuard(guard); err != nil {
+		ts.t.Fatal(err)
+	}
+	if guard, err = ts.ms.GetSetRateGuard(); err != nil {
+		ts.t.Fatal(err)
+	}
+	if guard.MaxDeviationOf("KNC") != 0.05 || guard.MaxDeviationOf("OMG") != 0.1 || guard.MaxPriceAge != 60000 {
+		ts.t.Errorf("unexpected set rate guard %+v", guard)
+	}
+
+	params := []byte(`{"DGX":{"AskSpread":34,"BidSpread":23}}`)
+	if err = ts.ms.SetStableTokenParams(params); err != nil {
+		ts.t.Fatal(err)
+	}
+	if err = ts.ms.SetStableTokenParams(params); err == nil {
+		ts.t.Error("expected error storing stable token params when there is a pending one")
+	}
+	if err = ts.ms.ConfirmStableTokenParams(params); err != nil {
+		ts.t.Fatal(err)
+	}
+	stable, err := ts.ms.GetStableTokenParams()
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if _, ok := stable["DGX"]; !ok {
+		ts.t.Errorf("expected confirmed stable token params, got %+v", stable)
+	}
+}
+
+func (ts *StorageTestSuite) testTargetQtyV2() {
+	ts.t.Helper()
+	target := common.TokenTargetQtyV2{
+		"KNC": common.TargetQtyV2{SetTarget: common.TargetQtySet{TotalTarget: 100, ReserveTarget: 50}},
+	}
+	data, err := json.Marshal(target)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if err = ts.ms.StorePendingTargetQtyV2(data); err != nil {
+		ts.t.Fatal(err)
+	}
+	if err = ts.ms.StorePendingTargetQtyV2(data); err == nil {
+		ts.t.Error("expected error storing target quantity when there is a pending one")
+	}
+	pending, err := ts.ms.GetPendingTargetQtyV2()
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pending, target) {
+		ts.t.Errorf("expected pending target quantity %+v, got %+v", target, pending)
+	}
+	if err = ts.ms.ConfirmTargetQtyV2(data); err != nil {
+		ts.t.Fatal(err)
+	}
+	if _, err = ts.ms.GetPendingTargetQtyV2(); err == nil {
+		ts.t.Error("expected no pending target quantity after confirmed")
+	}
+	current, err := ts.ms.GetTargetQtyV2()
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if !reflect.DeepEqual(current, target) {
+		ts.t.Errorf("expected target quantity %+v, got %+v", target, current)
+	}
+}
+
+func (ts *StorageTestSuite) testPWIEquationV2() {
+	ts.t.Helper()
+	eq := common.PWIEquationRequestV2{
+		"KNC": common.PWIEquationTokenV2{
+			"bid": common.PWIEquationV2{A: 750, B: 500},
+			"ask": common.PWIEquationV2{A: 800, B: 600},
+		},
+	}
+	data, err := json.Marshal(eq)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if err = ts.ms.StorePendingPWIEquationV2(data); err != nil {
+		ts.t.Fatal(err)
+	}
+	if err = ts.ms.StorePWIEquationV2(`{"KNC":{"bid":{"a":1},"ask":{"a":1}}}`); err == nil {
+		ts.t.Error("expected error confirming PWI equation not matching pending one")
+	}
+	if err = ts.ms.StorePWIEquationV2(string(data)); err != nil {
+		ts.t.Fatal(err)
+	}
+	current, err := ts.ms.GetPWIEquationV2()
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if !reflect.DeepEqual(current, eq) {
+		ts.t.Errorf("expected PWI equation %+v, got %+v", eq, current)
+	}
+	if err = ts.ms.RemovePendingPWIEquationV2(); err == nil {
+		ts.t.Error("expected error removing pending PWI equation when there is none")
+	}
+}
+
+func (ts *StorageTestSuite) testRebalanceQuadratic() {
+	ts.t.Helper()
+	data := []byte(`{"KNC":{"rebalance_quadratic":{"a":0.9,"b":1.2,"c":1.4}}}`)
+	if err := ts.ms.StorePendingRebalanceQuadratic(data); err != nil {
+		ts.t.Fatal(err)
+	}
+	if err := ts.ms.RemovePendingRebalanceQuadratic(); err != nil {
+		ts.t.Fatal(err)
+	}
+	if err := ts.ms.StorePendingRebalanceQuadratic(data); err != nil {
+		ts.t.Fatal(err)
+	}
+	if err := ts.ms.ConfirmRebalanceQuadratic(data); err != nil {
+		ts.t.Fatal(err)
+	}
+	current, err := ts.ms.GetRebalanceQuadratic()
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if current["KNC"].RebalanceQuadratic.B != 1.2 {
+		ts.t.Errorf("getting wrong rebalance quadratic equation: %+v", current)
+	}
+}
+
+func (ts *StorageTestSuite) testTokenUpdateInfo() {
+	ts.t.Helper()
+	target := common.TokenTargetQtyV2{"OMG": common.TargetQtyV2{SetTarget: common.TargetQtySet{TotalTarget: 10}}}
+	pwi := common.PWIEquationRequestV2{"OMG": common.PWIEquationTokenV2{"bid": {A: 1}, "ask": {A: 2}}}
+	quad := common.RebalanceQuadraticRequest{}
+	if err := ts.ms.StorePendingTokenUpdateInfo(target, pwi, quad); err != nil {
+		ts.t.Fatal(err)
+	}
+	if err := ts.ms.StorePendingTokenUpdateInfo(target, pwi, quad); err == nil {
+		ts.t.Error("expected error storing token update info when there is a pending one")
+	}
+	if err := ts.ms.RemovePendingTokenUpdateInfo(); err != nil {
+		ts.t.Fatal(err)
+	}
+	if err := ts.ms.ConfirmTokenUpdateInfo(target, pwi, quad); err != nil {
+		ts.t.Fatal(err)
+	}
+	current, err := ts.ms.GetTargetQtyV2()
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if !reflect.DeepEqual(current, target) {
+		ts.t.Errorf("expected target quantity %+v, got %+v", target, current)
+	}
+}
+
+// testConfigVersions checks the versions confirmed by testTargetQtyV2,
+// testPWIEquationV2, testRebalanceQuadratic and testTokenUpdateInfo.
+func (ts *StorageTestSuite) testConfigVersions() {
+	ts.t.Helper()
+	for _, configType := range []string{common.ConfigTargetQtyV2, common.ConfigPWIEquationV2, common.ConfigRebalanceQuadratic} {
+		versions, err := ts.ms.GetConfigVersions(configType)
+		if err != nil {
+			ts.t.Fatal(err)
+		}
+		if len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 1 {
+			ts.t.Errorf("expected 2 versions of %s with latest first, got %+v", configType, versions)
+		}
+	}
+
+	first, err := ts.ms.GetConfigVersion(common.ConfigTargetQtyV2, 1)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	var target common.TokenTargetQtyV2
+	if err = json.Unmarshal(first.Data, &target); err != nil {
+		ts.t.Fatal(err)
+	}
+	if target["KNC"].SetTarget.TotalTarget != 100 {
+		ts.t.Errorf("getting wrong first version of target quantity: %+v", target)
+	}
+	if _, err = ts.ms.GetConfigVersion(common.ConfigTargetQtyV2, 3); err == nil {
+		ts.t.Error("expected error getting version not confirmed yet")
+	}
+
+	latest, err := ts.ms.GetConfigVersionAt(common.ConfigTargetQtyV2, common.GetTimepoint())
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if latest.Version != 2 {
+		ts.t.Errorf("expected latest version of target quantity to be 2, got %+v", latest)
+	}
+	if _, err = ts.ms.GetConfigVersionAt(common.ConfigTargetQtyV2, first.Timestamp-1); err == nil {
+		ts.t.Error("expected error getting version before the first one")
+	}
+	if _, err = ts.ms.GetConfigVersions(common.ConfigStableTokenParams); err == nil {
+		ts.t.Error("expected error getting versions of configuration which is not versioned")
+	}
+}
+
+func (ts *StorageTestSuite) testAPIKeys() {
+	ts.t.Helper()
+	key := common.APIKey{
+		ID:          "operator",
+		Secret:      "secret",
+		Permissions: []string{"rebalance"},
+		Exchanges:   []string{"binance"},
+	}
+	if err := ts.ks.StoreAPIKey(key); err != nil {
+		ts.t.Fatal(err)
+	}
+	key.Tokens = []string{"KNC"}
+	if err := ts.ks.StoreAPIKey(key); err != nil {
+		ts.t.Fatal(err)
+	}
+	keys, err := ts.ks.GetAPIKeys()
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if len(keys) != 1 || !reflect.DeepEqual(keys[0], key) {
+		ts.t.Errorf("expected API keys %+v, got %+v", []common.APIKey{key}, keys)
+	}
+	if err = ts.ks.RemoveAPIKey(key.ID); err != nil {
+		ts.t.Fatal(err)
+	}
+	if keys, err = ts.ks.GetAPIKeys(); err != nil {
+		ts.t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		ts.t.Errorf("expected no API key after removing, got %+v", keys)
+	}
+}
+
+func (ts *StorageTestSuite) testConfigHistory() {
+	ts.t.Helper()
+	proposals := []common.ConfigProposal{
+		{ID: 1000, Type: common.ConfigTargetQtyV2, Proposer: "alice", Timestamp: 1, Data: json.RawMessage(`{}`),
+			Diff: []common.ConfigChange{}, Status: common.ConfigProposalPending},
+		{ID: 2000, Type: common.ConfigPWIEquationV2, Proposer: "alice", Timestamp: 2, Data: json.RawMessage(`{}`),
+			Diff: []common.ConfigChange{}, Status: common.ConfigProposalPending},
+	}
+	for _, proposal := range proposals {
+		if err := ts.ms.StoreConfigProposal(proposal); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+	pending, err := ts.ms.GetPendingConfigProposals(common.ConfigTargetQtyV2)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != 1000 {
+		ts.t.Errorf("expected pending target quantity proposal, got %+v", pending)
+	}
+	proposals[0].Status = common.ConfigProposalConfirmed
+	proposals[0].Resolver = "bob"
+	proposals[0].ResolvedAt = 3
+	if err = ts.ms.StoreConfigProposal(proposals[0]); err != nil {
+		ts.t.Fatal(err)
+	}
+	if pending, err = ts.ms.GetPendingConfigProposals(common.ConfigTargetQtyV2); err != nil {
+		ts.t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		ts.t.Errorf("expected no pending target quantity proposal after confirming, got %+v", pending)
+	}
+	history, err := ts.ms.GetConfigProposals(0, 10)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if !reflect.DeepEqual(history, proposals) {
+		ts.t.Errorf("expected config history %+v, got %+v", proposals, history)
+	}
+	if history, err = ts.ms.GetConfigProposals(2, 10); err != nil {
+		ts.t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].ID != 2000 {
+		ts.t.Errorf("expected one proposal from timestamp 2, got %+v", history)
+	}
+}
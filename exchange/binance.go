@@ -25,6 +25,21 @@ type Binance struct {
 	interf  BinanceInterface
 	storage BinanceStorage
 	setting Setting
+	// depthStream is the source of order books if set, REST otherwise.
+	depthStream BinanceDepthStream
+}
+
+// SetDepthStream makes Binance read order books from the local books of
+// stream instead of polling them.
+func (self *Binance) SetDepthStream(stream BinanceDepthStream) {
+	self.depthStream = stream
+}
+
+func (self *Binance) getDepthOnePair(pair common.TokenPair) (Binaresp, error) {
+	if self.depthStream != nil {
+		return self.depthStream.GetDepthOnePair(pair)
+	}
+	return self.interf.GetDepthOnePair(pair)
 }
 
 func (self *Binance) TokenAddresses() (map[string]ethereum.Address, error) {
@@ -282,7 +297,7 @@ func (self *Binance) FetchOnePairData(
 	timestamp := common.Timestamp(fmt.Sprintf("%d", timepoint))
 	result.Timestamp = timestamp
 	result.Valid = true
-	resp_data, err := self.interf.GetDepthOnePair(pair)
+	resp_data, err := self.getDepthOnePair(pair)
 	returnTime := common.GetTimestamp()
 	result.ReturnTime = returnTime
 	if err != nil {
@@ -327,6 +342,9 @@ func (self *Binance) FetchPriceData(timepoint uint64) (map[common.TokenPairID]co
 	if err != nil {
 		return nil, err
 	}
+	if self.depthStream != nil {
+		self.depthStream.Subscribe(pairs)
+	}
 	var i int = 0
 	var x int = 0
 	for i < len(pairs) {
@@ -591,9 +609,9 @@ func NewBinance(
 	storage BinanceStorage,
 	setting Setting) (*Binance, error) {
 	binance := &Binance{
-		interf,
-		storage,
-		setting,
+		interf:  interf,
+		storage: storage,
+		setting: setting,
 	}
 	binance.FetchTradeHistory()
 	return binance, nil
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math/big"
 	"math/rand"
@@ -15,7 +14,9 @@ import (
 	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
 	"github.com/KyberNetwork/reserve-data/exchange"
+	"github.com/KyberNetwork/reserve-data/exchange/ratelimit"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
@@ -23,10 +24,13 @@ import (
 // including signer for api call authentication,
 // interf for calling api in different env
 // timedelta to make sure calling api in time
+// limiter to keep requests in Binance weight limit
 type BinanceEndpoint struct {
 	signer    Signer
 	interf    Interface
 	timeDelta int64
+	limiter   *ratelimit.Limiter
+	client    *httpclient.Client
 }
 
 func (self *BinanceEndpoint) fillRequest(req *http.Request, signNeeded bool, timepoint uint64) {
@@ -56,9 +60,6 @@ func (self *BinanceEndpoint) GetResponse(
 		err      error
 		respBody []byte
 	)
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
-	}
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, err
@@ -70,24 +71,38 @@ func (self *BinanceEndpoint) GetResponse(
 		q.Add(k, v)
 	}
 	req.URL.RawQuery = q.Encode()
+	if self.limiter != nil {
+		start := time.Now()
+		if err = self.limiter.Wait(requestPriority(method, req.URL.Path), requestWeight(req.URL.Path, params)); err != nil {
+			return nil, err
+		}
+		// signed requests must be sent in the receive window of timepoint
+		timepoint += uint64(time.Since(start) / time.Millisecond)
+	}
 	self.fillRequest(req, signNeeded, timepoint)
 
 	log.Printf("request to binance: %s\n", req.URL)
-	resp, err := client.Do(req)
+	resp, err := self.client.Do(req)
 	if err != nil {
 		return respBody, err
 	}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("Response body close error: %s", cErr.Error())
+	if self.limiter != nil {
+		if used, pErr := strconv.ParseFloat(resp.Header.Get(binanceUsedWeightHeader), 64); pErr == nil {
+			self.limiter.SetUsed(used)
 		}
-	}()
+	}
 	switch resp.StatusCode {
 	case 429:
 		err = errors.New("breaking binance request rate limit")
+		if self.limiter != nil {
+			self.limiter.Backoff(ratelimit.RetryAfter(resp.Header, binanceRateLimitBackoff))
+		}
 		break
 	case 418:
 		err = errors.New("ip has been auto-banned by binance for continuing to send requests after receiving 429 codes")
+		if self.limiter != nil {
+			self.limiter.Backoff(ratelimit.RetryAfter(resp.Header, binanceBanBackoff))
+		}
 		break
 	case 500:
 		err = errors.New("500 from Binance, its fault")
@@ -96,11 +111,11 @@ func (self *BinanceEndpoint) GetResponse(
 		err = errors.New("binance api key not valid")
 		break
 	case 200:
-		respBody, err = ioutil.ReadAll(resp.Body)
+		respBody = resp.Body
 		break
 	default:
 		var response exchange.Binaresp
-		if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		if err = json.Unmarshal(resp.Body, &response); err != nil {
 			break
 		}
 		err = fmt.Errorf("Binance return with code: %d - %s", resp.StatusCode, response.Msg)
@@ -112,12 +127,21 @@ func (self *BinanceEndpoint) GetResponse(
 }
 
 func (self *BinanceEndpoint) GetDepthOnePair(pair common.TokenPair) (exchange.Binaresp, error) {
+	return self.getDepth(pair, 100)
+}
+
+// GetDepthSnapshot returns the order book of pair with 1000 levels of each
+// side, it is the snapshot of local order books of depth streams.
+func (self *BinanceEndpoint) GetDepthSnapshot(pair common.TokenPair) (exchange.Binaresp, error) {
+	return self.getDepth(pair, 1000)
+}
 
+func (self *BinanceEndpoint) getDepth(pair common.TokenPair, limit int) (exchange.Binaresp, error) {
 	respBody, err := self.GetResponse(
 		"GET", self.interf.PublicEndpoint()+"/api/v1/depth",
 		map[string]string{
 			"symbol": fmt.Sprintf("%s%s", pair.Base.ID, pair.Quote.ID),
-			"limit":  "100",
+			"limit":  strconv.Itoa(limit),
 		},
 		false,
 		common.GetTimepoint(),
@@ -449,9 +473,11 @@ func (self *BinanceEndpoint) UpdateTimeDelta() error {
 	return nil
 }
 
-//NewBinanceEndpoint return new endpoint instance for using binance
-func NewBinanceEndpoint(signer Signer, interf Interface) *BinanceEndpoint {
-	endpoint := &BinanceEndpoint{signer, interf, 0}
+//NewBinanceEndpoint return new endpoint instance for using binance,
+//its requests are throttled by limiter if it is not nil
+func NewBinanceEndpoint(signer Signer, interf Interface, limiter *ratelimit.Limiter) *BinanceEndpoint {
+	client := httpclient.NewClient("binance", httpclient.NoRetryConfig(30*time.Second))
+	endpoint := &BinanceEndpoint{signer, interf, 0, limiter, client}
 	switch interf.(type) {
 	case *SimulatedInterface:
 		log.Println("Simulate environment, no updateTime called...")
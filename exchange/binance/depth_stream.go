@@ -0,0 +1,364 @@
+package binance
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/exchange"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// depthStreamLimit is the number of levels of each side returned from
+	// local order books, as the limit of REST depth requests.
+	depthStreamLimit = 100
+	// depthStreamReadTimeout is the longest time without message before the
+	// stream is considered broken and reconnected.
+	depthStreamReadTimeout       = time.Minute
+	depthStreamDialTimeout       = 30 * time.Second
+	depthStreamReconnectDelay    = time.Second
+	depthStreamMaxReconnectDelay = time.Minute
+)
+
+// depthEvent is a diff of an order book from Binance depth stream, eg:
+//
+//	{"e":"depthUpdate","E":123456789,"s":"KNCETH","U":157,"u":160,"b":[["0.0024","10"]],"a":[["0.0026","100"]]}
+type depthEvent struct {
+	Symbol        string               `json:"s"`
+	FirstUpdateID int64                `json:"U"`
+	FinalUpdateID int64                `json:"u"`
+	Bids          []exchange.Binaprice `json:"b"`
+	Asks          []exchange.Binaprice `json:"a"`
+}
+
+// depthMessage is a message of Binance combined streams.
+type depthMessage struct {
+	Stream string     `json:"stream"`
+	Data   depthEvent `json:"data"`
+}
+
+// orderBook is a local order book, it is synced from a REST snapshot and the
+// events buffered while the snapshot is requested.
+type orderBook struct {
+	pair         common.TokenPair
+	synced       bool
+	syncing      bool
+	lastUpdateID int64
+	// bids and asks are quantities by price, a zero quantity removes a level
+	bids    map[string]string
+	asks    map[string]string
+	buffer  []depthEvent
+	updated time.Time
+}
+
+func newOrderBook(pair common.TokenPair) *orderBook {
+	return &orderBook{
+		pair: pair,
+		bids: map[string]string{},
+		asks: map[string]string{},
+	}
+}
+
+func (self *orderBook) init(snapshot exchange.Binaresp) {
+	self.lastUpdateID = snapshot.LastUpdatedId
+	for _, bid := range snapshot.Bids {
+		self.bids[bid.Rate] = bid.Quantity
+	}
+	for _, ask := range snapshot.Asks {
+		self.asks[ask.Rate] = ask.Quantity
+	}
+	self.updated = time.Now()
+}
+
+// apply updates the book with event, it returns error if events between the
+// book and event are missed.
+func (self *orderBook) apply(event depthEvent) error {
+	if event.FinalUpdateID <= self.lastUpdateID {
+		// the event is already in the book
+		return nil
+	}
+	if event.FirstUpdateID > self.lastUpdateID+1 {
+		return fmt.Errorf("Events of %s from %d to %d are missed", event.Symbol, self.lastUpdateID+1, event.FirstUpdateID-1)
+	}
+	updateLevels(self.bids, event.Bids)
+	updateLevels(self.asks, event.Asks)
+	self.lastUpdateID = event.FinalUpdateID
+	self.updated = time.Now()
+	return nil
+}
+
+func updateLevels(levels map[string]string, updates []exchange.Binaprice) {
+	for _, update := range updates {
+		quantity, err := strconv.ParseFloat(update.Quantity, 64)
+		if err == nil && quantity == 0 {
+			delete(levels, update.Rate)
+			continue
+		}
+		levels[update.Rate] = update.Quantity
+	}
+}
+
+// sortedLevels returns at most limit levels sorted by price, descending for
+// bids and ascending for asks.
+func sortedLevels(levels map[string]string, descending bool, limit int) []exchange.Binaprice {
+	type level struct {
+		price float64
+		entry exchange.Binaprice
+	}
+	sorted := make([]level, 0, len(levels))
+	for rate, quantity := range levels {
+		price, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			continue
+		}
+		sorted = append(sorted, level{price, exchange.Binaprice{Quantity: quantity, Rate: rate}})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if descending {
+			return sorted[i].price > sorted[j].price
+		}
+		return sorted[i].price < sorted[j].price
+	})
+	if len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	result := make([]exchange.Binaprice, 0, len(sorted))
+	for _, l := range sorted {
+		result = append(result, l.entry)
+	}
+	return result
+}
+
+// DepthStream maintains local order books of Binance pairs from the depth
+// websocket stream, it reconnects when the stream is broken and resyncs a
+// book from a REST snapshot when its events are missed.
+type DepthStream struct {
+	url      string
+	snapshot func(pair common.TokenPair) (exchange.Binaresp, error)
+	maxAge   time.Duration
+
+	reconnectDelay time.Duration
+
+	mu      sync.Mutex
+	books   map[string]*orderBook
+	conn    *websocket.Conn
+	started bool
+	closed  bool
+}
+
+// NewDepthStream creates a depth stream of Binance websocket endpoint url,
+// books are synced from snapshot and are stale if they are not updated
+// within maxAge.
+func NewDepthStream(url string, snapshot func(pair common.TokenPair) (exchange.Binaresp, error), maxAge time.Duration) *DepthStream {
+	return &DepthStream{
+		url:            strings.TrimRight(url, "/"),
+		snapshot:       snapshot,
+		maxAge:         maxAge,
+		reconnectDelay: depthStreamReconnectDelay,
+		books:          map[string]*orderBook{},
+	}
+}
+
+func pairSymbol(pair common.TokenPair) string {
+	return strings.ToUpper(pair.Base.ID + pair.Quote.ID)
+}
+
+// Subscribe makes the stream maintain the order books of pairs, the books of
+// other pairs are dropped. The stream is reconnected if pairs are changed.
+func (self *DepthStream) Subscribe(pairs []common.TokenPair) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	books := map[string]*orderBook{}
+	for _, pair := range pairs {
+		books[pairSymbol(pair)] = newOrderBook(pair)
+	}
+	changed := len(books) != len(self.books)
+	for symbol := range books {
+		if _, ok := self.books[symbol]; !ok {
+			changed = true
+		}
+	}
+	if !changed || self.closed {
+		return
+	}
+	self.books = books
+	if !self.started {
+		self.started = true
+		go self.run()
+		return
+	}
+	if self.conn != nil {
+		// the stream is reconnected with the new pairs
+		if err := self.conn.Close(); err != nil {
+			log.Printf("Binance depth stream close error: %s", err.Error())
+		}
+	}
+}
+
+// Close stops the stream.
+func (self *DepthStream) Close() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.closed = true
+	if self.conn != nil {
+		if err := self.conn.Close(); err != nil {
+			log.Printf("Binance depth stream close error: %s", err.Error())
+		}
+	}
+}
+
+// GetDepthOnePair returns the local order book of pair, it returns error if
+// the book is not synced yet or stale.
+func (self *DepthStream) GetDepthOnePair(pair common.TokenPair) (exchange.Binaresp, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	symbol := pairSymbol(pair)
+	book, ok := self.books[symbol]
+	if !ok {
+		return exchange.Binaresp{}, fmt.Errorf("Binance depth stream is not subscribed to %s", symbol)
+	}
+	if !book.synced {
+		return exchange.Binaresp{}, fmt.Errorf("Binance order book of %s is not synced", symbol)
+	}
+	if age := time.Since(book.updated); age > self.maxAge {
+		return exchange.Binaresp{}, fmt.Errorf("Binance order book of %s is stale, it is not updated for %s", symbol, age)
+	}
+	return exchange.Binaresp{
+		LastUpdatedId: book.lastUpdateID,
+		Bids:          sortedLevels(book.bids, true, depthStreamLimit),
+		Asks:          sortedLevels(book.asks, false, depthStreamLimit),
+	}, nil
+}
+
+func (self *DepthStream) run() {
+	delay := self.reconnectDelay
+	for {
+		connected, err := self.stream()
+		self.mu.Lock()
+		closed := self.closed
+		// events are missed while disconnected, all books must be resynced
+		for symbol, book := range self.books {
+			self.books[symbol] = newOrderBook(book.pair)
+		}
+		self.conn = nil
+		self.mu.Unlock()
+		if closed {
+			return
+		}
+		if connected {
+			delay = self.reconnectDelay
+		}
+		log.Printf("Binance depth stream is disconnected (%s), reconnect in %s", common.ErrorToString(err), delay)
+		time.Sleep(delay)
+		if !connected {
+			delay *= 2
+			if delay > depthStreamMaxReconnectDelay {
+				delay = depthStreamMaxReconnectDelay
+			}
+		}
+	}
+}
+
+// stream connects to the depth streams of the subscribed pairs and handles
+// their events until the connection is broken, it returns true if the
+// connection was established.
+func (self *DepthStream) stream() (bool, error) {
+	self.mu.Lock()
+	streams := make([]string, 0, len(self.books))
+	for symbol := range self.books {
+		streams = append(streams, strings.ToLower(symbol)+"@depth")
+	}
+	self.mu.Unlock()
+	if len(streams) == 0 {
+		return false, nil
+	}
+	sort.Strings(streams)
+	config, err := websocket.NewConfig(self.url+"/stream?streams="+strings.Join(streams, "/"), "http://localhost/")
+	if err != nil {
+		return false, err
+	}
+	config.Dialer = &net.Dialer{Timeout: depthStreamDialTimeout}
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		return false, err
+	}
+	self.mu.Lock()
+	if self.closed {
+		self.mu.Unlock()
+		return true, conn.Close()
+	}
+	self.conn = conn
+	self.mu.Unlock()
+	log.Printf("Binance depth stream is connected to %d pairs", len(streams))
+	for {
+		if err = conn.SetReadDeadline(time.Now().Add(depthStreamReadTimeout)); err != nil {
+			return true, err
+		}
+		var msg depthMessage
+		if err = websocket.JSON.Receive(conn, &msg); err != nil {
+			// the connection may be closed already by Subscribe or Close
+			_ = conn.Close()
+			return true, err
+		}
+		self.handle(msg.Data)
+	}
+}
+
+func (self *DepthStream) handle(event depthEvent) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	symbol := strings.ToUpper(event.Symbol)
+	book, ok := self.books[symbol]
+	if !ok {
+		return
+	}
+	if book.synced {
+		err := book.apply(event)
+		if err == nil {
+			return
+		}
+		log.Printf("Binance order book of %s is out of sync (%s), resync it", symbol, err.Error())
+		book = newOrderBook(book.pair)
+		self.books[symbol] = book
+	}
+	book.buffer = append(book.buffer, event)
+	if !book.syncing {
+		book.syncing = true
+		go self.sync(symbol, book)
+	}
+}
+
+// sync initializes book from a snapshot and applies the buffered events.
+func (self *DepthStream) sync(symbol string, book *orderBook) {
+	snapshot, err := self.snapshot(book.pair)
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.books[symbol] != book {
+		// the book is dropped or resynced meanwhile
+		return
+	}
+	if err != nil {
+		log.Printf("Binance can't get order book snapshot of %s (%s), retry at next event", symbol, err.Error())
+		self.books[symbol] = newOrderBook(book.pair)
+		return
+	}
+	book.init(snapshot)
+	for _, event := range book.buffer {
+		if err = book.apply(event); err != nil {
+			// the snapshot is older than the buffered events
+			log.Printf("Binance order book snapshot of %s is outdated (%s), retry at next event", symbol, err.Error())
+			self.books[symbol] = newOrderBook(book.pair)
+			return
+		}
+	}
+	book.buffer = nil
+	book.syncing = false
+	book.synced = true
+}
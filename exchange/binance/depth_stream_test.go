@@ -0,0 +1,238 @@
+package binance
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/exchange"
+	"golang.org/x/net/websocket"
+)
+
+// recorded messages of KNCETH depth stream, the snapshot of the book is at
+// update 100.
+const (
+	kncSnapshot     = `{"lastUpdateId":100,"bids":[["0.00200000","10.00000000"],["0.00190000","5.00000000"]],"asks":[["0.00210000","3.00000000"]]}`
+	kncOldEvent     = `{"stream":"knceth@depth","data":{"e":"depthUpdate","E":1540000000000,"s":"KNCETH","U":95,"u":99,"b":[["0.00180000","1.00000000"]],"a":[]}}`
+	kncFirstEvent   = `{"stream":"knceth@depth","data":{"e":"depthUpdate","E":1540000001000,"s":"KNCETH","U":100,"u":102,"b":[["0.00200000","0.00000000"],["0.00205000","7.00000000"]],"a":[]}}`
+	kncSecondEvent  = `{"stream":"knceth@depth","data":{"e":"depthUpdate","E":1540000002000,"s":"KNCETH","U":103,"u":104,"b":[],"a":[["0.00220000","1.00000000"]]}}`
+	kncGapEvent     = `{"stream":"knceth@depth","data":{"e":"depthUpdate","E":1540000003000,"s":"KNCETH","U":110,"u":111,"b":[["0.00201000","2.00000000"]],"a":[]}}`
+	kncResyncedBook = `{"lastUpdateId":111,"bids":[["0.00201000","2.00000000"]],"asks":[["0.00210000","3.00000000"]]}`
+)
+
+var kncPair = common.TokenPair{Base: common.Token{ID: "KNC"}, Quote: common.Token{ID: "ETH"}}
+
+// testStreamServer is a websocket server replaying the messages sent to its
+// connections, a connection is closed when its channel is closed.
+type testStreamServer struct {
+	*httptest.Server
+	conns chan chan string
+	done  chan struct{}
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func newTestStreamServer() *testStreamServer {
+	server := &testStreamServer{conns: make(chan chan string, 10), done: make(chan struct{})}
+	server.Server = httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		server.mu.Lock()
+		server.queries = append(server.queries, conn.Request().URL.RawQuery)
+		server.mu.Unlock()
+		messages := make(chan string)
+		server.conns <- messages
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				if err := websocket.Message.Send(conn, msg); err != nil {
+					return
+				}
+			case <-server.done:
+				return
+			}
+		}
+	}))
+	return server
+}
+
+// accept returns the channel of the next connection.
+func (self *testStreamServer) accept(t *testing.T) chan string {
+	select {
+	case messages := <-self.conns:
+		return messages
+	case <-time.After(5 * time.Second):
+		t.Fatal("Depth stream is not connected")
+	}
+	return nil
+}
+
+func (self *testStreamServer) close() {
+	close(self.done)
+	self.Close()
+}
+
+func (self *testStreamServer) wsURL() string {
+	return strings.Replace(self.URL, "http://", "ws://", 1)
+}
+
+func (self *testStreamServer) lastQuery() string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.queries[len(self.queries)-1]
+}
+
+// testSnapshots returns the recorded snapshots in order, the last one is
+// repeated.
+type testSnapshots struct {
+	mu        sync.Mutex
+	snapshots []string
+	calls     int
+}
+
+func (self *testSnapshots) get(pair common.TokenPair) (exchange.Binaresp, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	snapshot := self.snapshots[len(self.snapshots)-1]
+	if self.calls < len(self.snapshots) {
+		snapshot = self.snapshots[self.calls]
+	}
+	self.calls++
+	result := exchange.Binaresp{}
+	err := json.Unmarshal([]byte(snapshot), &result)
+	return result, err
+}
+
+func (self *testSnapshots) count() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.calls
+}
+
+func waitForDepth(t *testing.T, stream *DepthStream, lastUpdateID int64) exchange.Binaresp {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		depth, err := stream.GetDepthOnePair(kncPair)
+		if err == nil && depth.LastUpdatedId == lastUpdateID {
+			return depth
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Order book is not updated to %d: %+v, %v", lastUpdateID, depth, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func prices(entries ...string) []exchange.Binaprice {
+	result := []exchange.Binaprice{}
+	for i := 0; i < len(entries); i += 2 {
+		result = append(result, exchange.Binaprice{Rate: entries[i], Quantity: entries[i+1]})
+	}
+	return result
+}
+
+func newTestDepthStream(server *testStreamServer, snapshots *testSnapshots, maxAge time.Duration) *DepthStream {
+	stream := NewDepthStream(server.wsURL(), snapshots.get, maxAge)
+	stream.reconnectDelay = 10 * time.Millisecond
+	stream.Subscribe([]common.TokenPair{kncPair})
+	return stream
+}
+
+func TestDepthStreamSync(t *testing.T) {
+	server := newTestStreamServer()
+	defer server.close()
+	snapshots := &testSnapshots{snapshots: []string{kncSnapshot, kncResyncedBook}}
+	stream := newTestDepthStream(server, snapshots, time.Minute)
+	defer stream.Close()
+
+	if _, err := stream.GetDepthOnePair(kncPair); err == nil {
+		t.Fatal("Expected error for not synced book, got nil")
+	}
+	conn := server.accept(t)
+	if query := server.lastQuery(); query != "streams=knceth@depth" {
+		t.Fatalf("Unexpected stream query %s", query)
+	}
+	conn <- kncOldEvent
+	conn <- kncFirstEvent
+	conn <- kncSecondEvent
+	depth := waitForDepth(t, stream, 104)
+	if expected := prices("0.00205000", "7.00000000", "0.00190000", "5.00000000"); !reflect.DeepEqual(depth.Bids, expected) {
+		t.Fatalf("Expected bids %+v, got %+v", expected, depth.Bids)
+	}
+	if expected := prices("0.00210000", "3.00000000", "0.00220000", "1.00000000"); !reflect.DeepEqual(depth.Asks, expected) {
+		t.Fatalf("Expected asks %+v, got %+v", expected, depth.Asks)
+	}
+
+	// missed events resync the book from a new snapshot
+	conn <- kncGapEvent
+	depth = waitForDepth(t, stream, 111)
+	if snapshots.count() != 2 {
+		t.Fatalf("Expected 2 snapshots, got %d", snapshots.count())
+	}
+	if expected := prices("0.00201000", "2.00000000"); !reflect.DeepEqual(depth.Bids, expected) {
+		t.Fatalf("Expected bids %+v, got %+v", expected, depth.Bids)
+	}
+}
+
+func TestDepthStreamStale(t *testing.T) {
+	server := newTestStreamServer()
+	defer server.close()
+	snapshots := &testSnapshots{snapshots: []string{kncSnapshot}}
+	stream := newTestDepthStream(server, snapshots, 200*time.Millisecond)
+	defer stream.Close()
+
+	conn := server.accept(t)
+	conn <- kncFirstEvent
+	waitForDepth(t, stream, 102)
+	time.Sleep(300 * time.Millisecond)
+	_, err := stream.GetDepthOnePair(kncPair)
+	if err == nil || !strings.Contains(err.Error(), "stale") {
+		t.Fatalf("Expected stale order book error, got %v", err)
+	}
+	conn <- kncSecondEvent
+	waitForDepth(t, stream, 104)
+}
+
+func TestDepthStreamReconnect(t *testing.T) {
+	server := newTestStreamServer()
+	defer server.close()
+	snapshots := &testSnapshots{snapshots: []string{kncSnapshot}}
+	stream := newTestDepthStream(server, snapshots, time.Minute)
+	defer stream.Close()
+
+	conn := server.accept(t)
+	conn <- kncFirstEvent
+	waitForDepth(t, stream, 102)
+	close(conn)
+
+	// the book is resynced after reconnecting
+	conn = server.accept(t)
+	if _, err := stream.GetDepthOnePair(kncPair); err == nil {
+		t.Fatal("Expected error for not synced book after reconnecting, got nil")
+	}
+	conn <- kncFirstEvent
+	waitForDepth(t, stream, 102)
+	if snapshots.count() != 2 {
+		t.Fatalf("Expected 2 snapshots, got %d", snapshots.count())
+	}
+
+	// subscribing other pairs reconnects with their streams
+	omgPair := common.TokenPair{Base: common.Token{ID: "OMG"}, Quote: common.Token{ID: "ETH"}}
+	stream.Subscribe([]common.TokenPair{kncPair, omgPair})
+	conn = server.accept(t)
+	if query := server.lastQuery(); query != "streams=knceth@depth/omgeth@depth" {
+		t.Fatalf("Unexpected stream query %s", query)
+	}
+	if _, err := stream.GetDepthOnePair(omgPair); err == nil {
+		t.Fatal("Expected error for not synced book, got nil")
+	}
+	conn <- kncFirstEvent
+	waitForDepth(t, stream, 102)
+}
@@ -2,7 +2,10 @@ package binance
 
 import "fmt"
 
-const binanceAPIEndpoint = "https://api.binance.com"
+const (
+	binanceAPIEndpoint    = "https://api.binance.com"
+	binanceStreamEndpoint = "wss://stream.binance.com:9443"
+)
 
 // Interface is Binance exchange API endpoints interface.
 type Interface interface {
@@ -11,6 +14,9 @@ type Interface interface {
 	// AuthenticatedEndpoint returns the endpoint that requires authentication.
 	// In simulation mode, authenticated endpoint is the Binance mock server.
 	AuthenticatedEndpoint() string
+	// StreamEndpoint returns the websocket endpoint of market streams, order
+	// books are fetched by REST if it is empty.
+	StreamEndpoint() string
 }
 
 type RealInterface struct{}
@@ -33,6 +39,10 @@ func (self *RealInterface) AuthenticatedEndpoint() string {
 	return binanceAPIEndpoint
 }
 
+func (self *RealInterface) StreamEndpoint() string {
+	return binanceStreamEndpoint
+}
+
 func NewRealInterface() *RealInterface {
 	return &RealInterface{}
 }
@@ -49,6 +59,11 @@ func (self *SimulatedInterface) AuthenticatedEndpoint() string {
 	return getSimulationURL(self.baseURL)
 }
 
+// StreamEndpoint is empty as the simulated Binance has no streams.
+func (self *SimulatedInterface) StreamEndpoint() string {
+	return ""
+}
+
 func NewSimulatedInterface(flagVariable string) *SimulatedInterface {
 	return &SimulatedInterface{baseURL: flagVariable}
 }
@@ -65,6 +80,10 @@ func (self *RopstenInterface) AuthenticatedEndpoint() string {
 	return getSimulationURL(self.baseURL)
 }
 
+func (self *RopstenInterface) StreamEndpoint() string {
+	return binanceStreamEndpoint
+}
+
 func NewRopstenInterface(flagVariable string) *RopstenInterface {
 	return &RopstenInterface{baseURL: flagVariable}
 }
@@ -81,6 +100,10 @@ func (self *KovanInterface) AuthenticatedEndpoint() string {
 	return getSimulationURL(self.baseURL)
 }
 
+func (self *KovanInterface) StreamEndpoint() string {
+	return binanceStreamEndpoint
+}
+
 func NewKovanInterface(flagVariable string) *KovanInterface {
 	return &KovanInterface{baseURL: flagVariable}
 }
@@ -95,6 +118,10 @@ func (self *DevInterface) AuthenticatedEndpoint() string {
 	return binanceAPIEndpoint
 }
 
+func (self *DevInterface) StreamEndpoint() string {
+	return binanceStreamEndpoint
+}
+
 func NewDevInterface() *DevInterface {
 	return &DevInterface{}
 }
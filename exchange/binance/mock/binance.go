@@ -33,6 +33,16 @@ func (bte *BinanceTestExchange) Trade(tradeType string, base common.Token, quote
 func (bte *BinanceTestExchange) CancelOrder(id, base, quote string) error {
 	return nil
 }
+
+// FetchEBalanceData returns the balances of the mock account, part of the
+// orders of KNC are traded.
+func (bte *BinanceTestExchange) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, error) {
+	return common.EBalanceEntry{
+		Valid:            true,
+		AvailableBalance: map[string]float64{"KNC": 12, "OMG": 20},
+		LockedBalance:    map[string]float64{},
+	}, nil
+}
 func (bte *BinanceTestExchange) MarshalText() (text []byte, err error) {
 	return []byte("binance"), nil
 }
@@ -0,0 +1,64 @@
+package binance
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/exchange/ratelimit"
+)
+
+const (
+	// Binance allows 1200 request weight per minute for an IP, some of it is
+	// reserved for trade and cancel requests.
+	binanceWeightLimit    = 1200
+	binanceWeightInterval = time.Minute
+	binanceReservedWeight = 100
+	// binanceRateLimitBackoff and binanceBanBackoff are the time requests are
+	// blocked after 429 and 418 responses without Retry-After header.
+	binanceRateLimitBackoff = time.Minute
+	binanceBanBackoff       = 2 * time.Minute
+	// binanceUsedWeightHeader is the header of the weight used in current
+	// interval by the IP.
+	binanceUsedWeightHeader = "X-MBX-USED-WEIGHT"
+)
+
+// NewRateLimiter returns a limiter of Binance request weight limit, it is
+// shared by all requests of an endpoint.
+func NewRateLimiter() *ratelimit.Limiter {
+	return ratelimit.NewLimiter("binance", binanceWeightLimit, binanceWeightInterval, binanceReservedWeight)
+}
+
+// requestWeight returns the weight of a request published by Binance.
+func requestWeight(path string, params map[string]string) float64 {
+	switch {
+	case strings.HasSuffix(path, "/api/v1/depth"):
+		limit, _ := strconv.Atoi(params["limit"])
+		switch {
+		case limit <= 100:
+			return 1
+		case limit <= 500:
+			return 5
+		default:
+			return 10
+		}
+	case strings.HasSuffix(path, "/api/v3/account"), strings.HasSuffix(path, "/api/v3/myTrades"):
+		return 5
+	case strings.HasSuffix(path, "/api/v3/openOrders"):
+		if params["symbol"] == "" {
+			return 40
+		}
+		return 1
+	default:
+		return 1
+	}
+}
+
+// requestPriority returns trade priority for placing and cancelling orders.
+func requestPriority(method, path string) ratelimit.Priority {
+	if method != http.MethodGet && strings.HasSuffix(path, "/api/v3/order") {
+		return ratelimit.TradePriority
+	}
+	return ratelimit.DataPriority
+}
@@ -39,3 +39,13 @@ type BinanceInterface interface {
 
 	OrderStatus(symbol string, id uint64) (Binaorder, error)
 }
+
+// BinanceDepthStream maintains local order books of Binance from its depth
+// stream.
+type BinanceDepthStream interface {
+	// Subscribe makes the stream maintain the order books of pairs.
+	Subscribe(pairs []common.TokenPair)
+	// GetDepthOnePair returns the local order book of pair, it returns error
+	// if the book is not synced or stale.
+	GetDepthOnePair(pair common.TokenPair) (Binaresp, error)
+}
@@ -316,7 +316,7 @@ func (self *Bittrex) WithdrawStatus(id, currency string, amount float64, timepoi
 			return common.ExchangeStatusDone, withdraw.TxId, nil
 		}
 	}
-	log.Printf("Withdraw with uuid " + id + " of currency " + currency + " is not found on bittrex")
+	log.Printf("Withdraw with uuid %s of currency %s is not found on bittrex", id, currency)
 	return "", "", nil
 }
 
@@ -2,8 +2,8 @@ package bittrex
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math/big"
 	"net/http"
@@ -13,13 +13,17 @@ import (
 	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
 	"github.com/KyberNetwork/reserve-data/exchange"
+	"github.com/KyberNetwork/reserve-data/exchange/ratelimit"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 type BittrexEndpoint struct {
-	signer Signer
-	interf Interface
+	signer  Signer
+	interf  Interface
+	limiter *ratelimit.Limiter
+	client  *httpclient.Client
 }
 
 func nonce() string {
@@ -53,9 +57,6 @@ func (self *BittrexEndpoint) fillRequest(req *http.Request, signNeeded bool) {
 
 func (self *BittrexEndpoint) GetResponse(
 	url string, params map[string]string, signNeeded bool) ([]byte, error) {
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
-	}
 	req, newHTTPErr := http.NewRequest("GET", url, nil)
 	if newHTTPErr != nil {
 		return nil, newHTTPErr
@@ -67,20 +68,26 @@ func (self *BittrexEndpoint) GetResponse(
 		q.Add(k, v)
 	}
 	req.URL.RawQuery = q.Encode()
-	self.fillRequest(req, signNeeded)
 	var err error
 	var respBody []byte
+	if self.limiter != nil {
+		if err = self.limiter.Wait(requestPriority(req.URL.Path), 1); err != nil {
+			return nil, err
+		}
+	}
+	self.fillRequest(req, signNeeded)
 	log.Printf("request to bittrex: %s\n", req.URL)
-	resp, err := client.Do(req)
+	resp, err := self.client.Do(req)
 	if err != nil {
 		return respBody, err
 	}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("Unmarshal response error: %s", cErr.Error())
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if self.limiter != nil {
+			self.limiter.Backoff(ratelimit.RetryAfter(resp.Header, bittrexRateLimitBackoff))
 		}
-	}()
-	respBody, err = ioutil.ReadAll(resp.Body)
+		return respBody, errors.New("breaking Bittrex request rate limit")
+	}
+	respBody = resp.Body
 	log.Printf("request to %s, got response from bittrex: %s\n", req.URL, common.TruncStr(respBody))
 	return respBody, err
 }
@@ -277,6 +284,9 @@ func (self *BittrexEndpoint) GetAccountTradeHistory(base, quote common.Token) (e
 	return result, err
 }
 
-func NewBittrexEndpoint(signer Signer, interf Interface) *BittrexEndpoint {
-	return &BittrexEndpoint{signer, interf}
+// NewBittrexEndpoint returns a Bittrex endpoint, its requests are throttled
+// by limiter if it is not nil.
+func NewBittrexEndpoint(signer Signer, interf Interface, limiter *ratelimit.Limiter) *BittrexEndpoint {
+	client := httpclient.NewClient("bittrex", httpclient.NoRetryConfig(30*time.Second))
+	return &BittrexEndpoint{signer, interf, limiter, client}
 }
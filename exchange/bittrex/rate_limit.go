@@ -0,0 +1,35 @@
+package bittrex
+
+import (
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/exchange/ratelimit"
+)
+
+const (
+	// Bittrex allows 60 requests per minute, some of them are reserved for
+	// trade and cancel requests.
+	bittrexRequestLimit    = 60
+	bittrexRequestInterval = time.Minute
+	bittrexReservedLimit   = 5
+	// bittrexRateLimitBackoff is the time requests are blocked after a 429
+	// response without Retry-After header.
+	bittrexRateLimitBackoff = time.Minute
+)
+
+// NewRateLimiter returns a limiter of Bittrex request limit, it is shared by
+// all requests of an endpoint.
+func NewRateLimiter() *ratelimit.Limiter {
+	return ratelimit.NewLimiter("bittrex", bittrexRequestLimit, bittrexRequestInterval, bittrexReservedLimit)
+}
+
+// requestPriority returns trade priority for placing and cancelling orders.
+func requestPriority(path string) ratelimit.Priority {
+	for _, market := range []string{"/buylimit", "/selllimit", "/cancel"} {
+		if strings.HasSuffix(path, market) {
+			return ratelimit.TradePriority
+		}
+	}
+	return ratelimit.DataPriority
+}
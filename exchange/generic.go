@@ -0,0 +1,367 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/settings"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+const genericEpsilon float64 = 0.0000001 // 10e-7
+
+// Generic is a centralized exchange whose API is described in a config file
+// instead of code, its settings are stored under a registered ExchangeName.
+type Generic struct {
+	id      string
+	name    settings.ExchangeName
+	interf  GenericInterface
+	storage GenericStorage
+	setting Setting
+}
+
+func (self *Generic) TokenAddresses() (map[string]ethereum.Address, error) {
+	addresses, err := self.setting.GetDepositAddresses(self.name)
+	if err != nil {
+		return nil, err
+	}
+	return addresses.GetData(), nil
+}
+
+func (self *Generic) MarshalText() (text []byte, err error) {
+	return []byte(self.ID()), nil
+}
+
+// Address returns the deposit address of a token on the exchange.
+// It will prioritize the live address from the exchange over the current
+// address in storage.
+func (self *Generic) Address(token common.Token) (ethereum.Address, bool) {
+	liveAddress, err := self.interf.GetDepositAddress(token.ID)
+	if err != nil || liveAddress == "" {
+		log.Printf("WARNING: Get %s live deposit address for token %s failed: err: (%v) or the address replied is empty. Use the currently available address instead", self.id, token.ID, err)
+		addrs, uErr := self.setting.GetDepositAddresses(self.name)
+		if uErr != nil {
+			log.Printf("WARNING: get address of token %s in %s exchange failed:(%s), it will be considered as not supported", token.ID, self.id, uErr.Error())
+			return ethereum.Address{}, false
+		}
+		return addrs.Get(token.ID)
+	}
+	addrs := common.NewExchangeAddresses()
+	addrs.Update(token.ID, ethereum.HexToAddress(liveAddress))
+	if err = self.setting.UpdateDepositAddress(self.name, *addrs, common.GetTimepoint()); err != nil {
+		log.Printf("WARNING: cannot update deposit address for token %s on %s: (%s)", token.ID, self.id, err.Error())
+	}
+	return ethereum.HexToAddress(liveAddress), true
+}
+
+func (self *Generic) UpdateDepositAddress(token common.Token, address string) error {
+	liveAddress, err := self.interf.GetDepositAddress(token.ID)
+	if err == nil && liveAddress != "" {
+		address = liveAddress
+	} else {
+		log.Printf("WARNING: Get %s live deposit address for token %s failed: err: (%v) or the address replied is empty. Use the given address instead", self.id, token.ID, err)
+	}
+	addrs := common.NewExchangeAddresses()
+	addrs.Update(token.ID, ethereum.HexToAddress(address))
+	return self.setting.UpdateDepositAddress(self.name, *addrs, common.GetTimepoint())
+}
+
+// GetLiveExchangeInfos queries the exchange for precisions and limits of the
+// pairs, it returns error if one of them is not listed.
+func (self *Generic) GetLiveExchangeInfos(tokenPairIDs []common.TokenPairID) (common.ExchangeInfo, error) {
+	result := make(common.ExchangeInfo)
+	pairsInfo, err := self.interf.GetPairsInfo(tokenPairIDs)
+	if err != nil {
+		return result, err
+	}
+	for _, pairID := range tokenPairIDs {
+		info, ok := pairsInfo[pairID]
+		if !ok {
+			return result, fmt.Errorf("%s Exchange Info reply doesn't contain token pair %s", self.id, string(pairID))
+		}
+		result[pairID] = info
+	}
+	return result, nil
+}
+
+func (self *Generic) UpdatePairsPrecision() error {
+	exInfo, err := self.GetInfo()
+	if err != nil {
+		return fmt.Errorf("Can't get Exchange Info for %s from persistent storage. (%s)", self.id, err)
+	}
+	if exInfo == nil {
+		return fmt.Errorf("Exchange info of %s is nil", self.id)
+	}
+	var pairIDs []common.TokenPairID
+	for pair := range exInfo.GetData() {
+		pairIDs = append(pairIDs, pair)
+	}
+	liveInfo, err := self.GetLiveExchangeInfos(pairIDs)
+	if err != nil {
+		return err
+	}
+	for pair, info := range liveInfo {
+		exInfo[pair] = info
+	}
+	return self.setting.UpdateExchangeInfo(self.name, exInfo, common.GetTimepoint())
+}
+
+func (self *Generic) GetInfo() (common.ExchangeInfo, error) {
+	return self.setting.GetExchangeInfo(self.name)
+}
+
+func (self *Generic) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
+	exInfo, err := self.setting.GetExchangeInfo(self.name)
+	if err != nil {
+		return common.ExchangePrecisionLimit{}, err
+	}
+	return exInfo.Get(pair)
+}
+
+func (self *Generic) GetFee() (common.ExchangeFees, error) {
+	return self.setting.GetFee(self.name)
+}
+
+func (self *Generic) GetMinDeposit() (common.ExchangesMinDeposit, error) {
+	return self.setting.GetMinDeposit(self.name)
+}
+
+// ID returns the name of the exchange in its config.
+func (self *Generic) ID() common.ExchangeID {
+	return common.ExchangeID(self.id)
+}
+
+func (self *Generic) Name() string {
+	return self.id
+}
+
+func (self *Generic) TokenPairs() ([]common.TokenPair, error) {
+	result := []common.TokenPair{}
+	exInfo, err := self.setting.GetExchangeInfo(self.name)
+	if err != nil {
+		return nil, err
+	}
+	for pair := range exInfo.GetData() {
+		pairIDs := strings.Split(string(pair), "-")
+		if len(pairIDs) != 2 {
+			return result, fmt.Errorf("%s PairID %s is malformed", self.id, string(pair))
+		}
+		base, uErr := self.setting.GetTokenByID(pairIDs[0])
+		if uErr != nil {
+			return result, fmt.Errorf("%s cant get Token %s, %s", self.id, pairIDs[0], uErr)
+		}
+		quote, uErr := self.setting.GetTokenByID(pairIDs[1])
+		if uErr != nil {
+			return result, fmt.Errorf("%s cant get Token %s, %s", self.id, pairIDs[1], uErr)
+		}
+		result = append(result, common.TokenPair{Base: base, Quote: quote})
+	}
+	return result, nil
+}
+
+func (self *Generic) Trade(tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
+	id, err = self.interf.Trade(tradeType, base, quote, rate, amount)
+	if err != nil {
+		return "", 0, 0, false, err
+	}
+	order, err := self.interf.OrderStatus(id, base.ID, quote.ID)
+	if err != nil {
+		return id, 0, 0, false, err
+	}
+	remaining = order.OrigQty - order.ExecutedQty
+	return id, order.ExecutedQty, remaining, !order.Open || remaining < genericEpsilon, nil
+}
+
+func (self *Generic) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
+	return self.interf.Withdraw(token, amount, address)
+}
+
+func (self *Generic) CancelOrder(id string, base, quote string) error {
+	return self.interf.CancelOrder(id, base, quote)
+}
+
+func (self *Generic) FetchOnePairData(pair common.TokenPair, timepoint uint64) common.ExchangePrice {
+	result := common.ExchangePrice{
+		Timestamp: common.Timestamp(fmt.Sprintf("%d", timepoint)),
+		Valid:     true,
+	}
+	depth, err := self.interf.GetDepthOnePair(pair)
+	result.ReturnTime = common.GetTimestamp()
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+		return result
+	}
+	result.Bids = depth.Bids
+	result.Asks = depth.Asks
+	return result
+}
+
+func (self *Generic) FetchPriceData(timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+	pairs, err := self.TokenPairs()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		wait   sync.WaitGroup
+		mu     sync.Mutex
+		result = map[common.TokenPairID]common.ExchangePrice{}
+	)
+	for i := 0; i < len(pairs); i += batchSize {
+		for x := i; x < len(pairs) && x < i+batchSize; x++ {
+			wait.Add(1)
+			go func(pair common.TokenPair) {
+				defer wait.Done()
+				price := self.FetchOnePairData(pair, timepoint)
+				mu.Lock()
+				result[pair.PairID()] = price
+				mu.Unlock()
+			}(pairs[x])
+		}
+		wait.Wait()
+	}
+	return result, nil
+}
+
+func (self *Generic) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, error) {
+	result := common.EBalanceEntry{
+		Timestamp: common.Timestamp(fmt.Sprintf("%d", timepoint)),
+		Valid:     true,
+	}
+	balances, err := self.interf.GetBalances()
+	result.ReturnTime = common.GetTimestamp()
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+		result.Status = false
+		return result, nil
+	}
+	result.AvailableBalance = map[string]float64{}
+	result.LockedBalance = map[string]float64{}
+	result.DepositBalance = map[string]float64{}
+	result.Status = true
+	for _, b := range balances {
+		tokenID := strings.ToUpper(b.Asset)
+		if _, uErr := self.setting.GetTokenByID(tokenID); uErr != nil {
+			continue
+		}
+		result.AvailableBalance[tokenID] = b.Free
+		result.LockedBalance[tokenID] = b.Locked
+		result.DepositBalance[tokenID] = 0
+	}
+	return result, nil
+}
+
+// FetchTradeHistoryOnce fetches the trade history of all pairs after the
+// last stored trade of each pair and stores it.
+func (self *Generic) FetchTradeHistoryOnce() error {
+	pairs, err := self.TokenPairs()
+	if err != nil {
+		return err
+	}
+	result := common.ExchangeTradeHistory{}
+	for _, pair := range pairs {
+		pairID := pair.PairID()
+		fromID, lErr := self.storage.GetLastIDTradeHistory(string(pairID))
+		if lErr != nil {
+			log.Printf("%s cannot get last ID trade history: %s", self.id, lErr.Error())
+		}
+		history, hErr := self.interf.GetAccountTradeHistory(pair.Base, pair.Quote, fromID)
+		if hErr != nil {
+			log.Printf("%s cannot fetch trade history for pair %s: %s", self.id, pairID, hErr.Error())
+			continue
+		}
+		result[pairID] = history
+	}
+	return self.storage.StoreTradeHistory(result)
+}
+
+// FetchTradeHistory fetches the trade history of all pairs periodically.
+func (self *Generic) FetchTradeHistory() {
+	t := time.NewTicker(10 * time.Minute)
+	go func() {
+		for {
+			if err := self.FetchTradeHistoryOnce(); err != nil {
+				log.Printf("%s fetch trade history failed: %s", self.id, err.Error())
+			}
+			<-t.C
+		}
+	}()
+}
+
+func (self *Generic) GetTradeHistory(fromTime, toTime uint64) (common.ExchangeTradeHistory, error) {
+	return self.storage.GetTradeHistory(fromTime, toTime)
+}
+
+func (self *Generic) DepositStatus(id common.ActivityID, txHash, currency string, amount float64, timepoint uint64) (string, error) {
+	deposits, err := self.interf.DepositHistory(timepoint-86400000, timepoint)
+	if err != nil {
+		return "", err
+	}
+	for _, deposit := range deposits {
+		if strings.EqualFold(deposit.TxID, txHash) {
+			if deposit.Done {
+				return common.ExchangeStatusDone, nil
+			}
+			return "", nil
+		}
+	}
+	log.Printf("%s deposit %s is not found in deposit list returned from the exchange", self.id, txHash)
+	return "", nil
+}
+
+func (self *Generic) WithdrawStatus(id, currency string, amount float64, timepoint uint64) (string, string, error) {
+	withdrawals, err := self.interf.WithdrawHistory(timepoint-86400000, timepoint)
+	if err != nil {
+		return "", "", err
+	}
+	for _, withdrawal := range withdrawals {
+		if withdrawal.ID == id {
+			if withdrawal.Done {
+				return common.ExchangeStatusDone, withdrawal.TxID, nil
+			}
+			return "", withdrawal.TxID, nil
+		}
+	}
+	log.Printf("%s withdrawal %s is not found in withdrawal list returned from the exchange", self.id, id)
+	return "", "", nil
+}
+
+func (self *Generic) OrderStatus(id string, base, quote string) (string, error) {
+	order, err := self.interf.OrderStatus(id, base, quote)
+	if err != nil {
+		return "", err
+	}
+	if order.Open {
+		return "", nil
+	}
+	return common.ExchangeStatusDone, nil
+}
+
+// NewGeneric creates a config driven exchange with id, its settings are
+// stored under name which must be registered with settings.RegisterExchange.
+func NewGeneric(
+	id string,
+	name settings.ExchangeName,
+	interf GenericInterface,
+	storage GenericStorage,
+	setting Setting) (*Generic, error) {
+	if id == "" {
+		return nil, errors.New("Exchange ID is empty")
+	}
+	generic := &Generic{
+		id:      id,
+		name:    name,
+		interf:  interf,
+		storage: storage,
+		setting: setting,
+	}
+	generic.FetchTradeHistory()
+	return generic, nil
+}
@@ -0,0 +1,189 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Names of the operations of a config driven exchange.
+const (
+	OrderBook       = "order_book"
+	Balances        = "balances"
+	PairsInfo       = "pairs_info"
+	DepositAddress  = "deposit_address"
+	Trade           = "trade"
+	OrderStatus     = "order_status"
+	CancelOrder     = "cancel_order"
+	Withdraw        = "withdraw"
+	DepositHistory  = "deposit_history"
+	WithdrawHistory = "withdraw_history"
+	TradeHistory    = "trade_history"
+)
+
+// requiredFields are the response fields which must be mapped for each
+// operation, an operation may map optional fields too:
+//   - pairs_info: price_precision, amount_precision, min_amount, max_amount,
+//     min_price, max_price, min_notional
+//   - withdraw_history: tx_id
+var requiredFields = map[string][]string{
+	OrderBook:       {"bids", "asks", "price", "quantity"},
+	Balances:        {"list", "asset", "free", "locked"},
+	PairsInfo:       {"list", "symbol"},
+	DepositAddress:  {"address"},
+	Trade:           {"order_id"},
+	OrderStatus:     {"status", "executed_qty", "orig_qty"},
+	CancelOrder:     {},
+	Withdraw:        {"id"},
+	DepositHistory:  {"list", "tx_id", "status"},
+	WithdrawHistory: {"list", "id", "status"},
+	TradeHistory:    {"list", "id", "price", "quantity", "side", "time"},
+}
+
+// SigningConfig describes how authenticated requests are signed with
+// HMAC-SHA256 of the encoded params.
+type SigningConfig struct {
+	// Location is where the signature is sent: "query" appends it as the
+	// last param, "header" sends it as a header.
+	Location string `json:"location"`
+	// Signature is the name of the signature param or header.
+	Signature string `json:"signature"`
+	// KeyHeader is the name of the header of the API key, KeyParam the name
+	// of its param, one of them must be set.
+	KeyHeader string `json:"key_header"`
+	KeyParam  string `json:"key_param"`
+	// Timestamp is the name of the param of the request time in
+	// milliseconds, it is omitted if empty.
+	Timestamp string `json:"timestamp"`
+	// Encoding of the signature, "hex" (default) or "base64".
+	Encoding string `json:"encoding"`
+}
+
+// EndpointConfig describes the request and response of an operation. Params
+// values and the path are templates of the variables of the operation,
+// eg: {symbol}, {side}, {price}, {amount}, {order_id}, {asset}, {address},
+// {from_id}, {start_time}, {end_time}. Params rendered empty are omitted.
+//
+// Fields are the paths of the response values, dot separated keys and array
+// indexes, eg: "data.balances". The paths of the fields of listed items, eg:
+// "asset" of balances, are relative to the items. Statuses are the statuses
+// of open orders for order_status, and of done transfers for histories.
+type EndpointConfig struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Signed   bool              `json:"signed"`
+	Params   map[string]string `json:"params"`
+	Fields   map[string]string `json:"fields"`
+	Statuses []string          `json:"statuses"`
+	// BuySide is the value of the side field of buy trades for
+	// trade_history, eg: "BUY" or "true".
+	BuySide string `json:"buy_side"`
+}
+
+// Config describes the API of an exchange, eg:
+//
+//	{
+//	  "name": "examplex",
+//	  "endpoint": "https://api.examplex.com",
+//	  "key": "...",
+//	  "secret": "...",
+//	  "signing": {"location": "query", "signature": "signature", "key_header": "X-API-KEY", "timestamp": "timestamp"},
+//	  "symbol": "{base}{quote}",
+//	  "sides": {"buy": "BUY", "sell": "SELL"},
+//	  "error": "msg",
+//	  "endpoints": {
+//	    "order_book": {"path": "/api/depth", "params": {"symbol": "{symbol}"}, "fields": {"bids": "bids", "asks": "asks", "price": "0", "quantity": "1"}},
+//	    ...
+//	  }
+//	}
+type Config struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint"`
+	Key      string `json:"key"`
+	Secret   string `json:"secret"`
+
+	Signing SigningConfig `json:"signing"`
+	// Symbol is the template of pairs symbol from {base} and {quote} token
+	// IDs, SymbolCase is "upper" (default) or "lower".
+	Symbol     string `json:"symbol"`
+	SymbolCase string `json:"symbol_case"`
+	// Sides maps "buy" and "sell" to the {side} of the exchange.
+	Sides map[string]string `json:"sides"`
+	// Error is the path of the error message in responses, a response with
+	// a non empty message is failed.
+	Error string `json:"error"`
+
+	Endpoints map[string]EndpointConfig `json:"endpoints"`
+}
+
+// Validate checks all the operations are described and their required
+// response fields are mapped.
+func (self Config) Validate() error {
+	if self.Name == "" {
+		return errors.New("Name of generic exchange is empty")
+	}
+	if _, err := url.ParseRequestURI(self.Endpoint); err != nil {
+		return fmt.Errorf("Endpoint of exchange %s is invalid: %s", self.Name, err)
+	}
+	if self.Symbol == "" {
+		return fmt.Errorf("Symbol of exchange %s is empty", self.Name)
+	}
+	for _, side := range []string{"buy", "sell"} {
+		if self.Sides[side] == "" {
+			return fmt.Errorf("Side %s of exchange %s is not mapped", side, self.Name)
+		}
+	}
+	switch self.Signing.Location {
+	case "query", "header":
+	default:
+		return fmt.Errorf("Signing location %q of exchange %s is invalid", self.Signing.Location, self.Name)
+	}
+	switch self.Signing.Encoding {
+	case "", "hex", "base64":
+	default:
+		return fmt.Errorf("Signing encoding %q of exchange %s is invalid", self.Signing.Encoding, self.Name)
+	}
+	if self.Signing.Signature == "" {
+		return fmt.Errorf("Signature name of exchange %s is empty", self.Name)
+	}
+	if self.Signing.KeyHeader == "" && self.Signing.KeyParam == "" {
+		return fmt.Errorf("Key header or key param of exchange %s must be set", self.Name)
+	}
+	for operation, fields := range requiredFields {
+		endpoint, ok := self.Endpoints[operation]
+		if !ok {
+			return fmt.Errorf("Operation %s of exchange %s is not described", operation, self.Name)
+		}
+		if endpoint.Path == "" {
+			return fmt.Errorf("Path of %s of exchange %s is empty", operation, self.Name)
+		}
+		for _, field := range fields {
+			if _, mapped := endpoint.Fields[field]; !mapped {
+				return fmt.Errorf("Field %s of %s of exchange %s is not mapped", field, operation, self.Name)
+			}
+		}
+	}
+	if self.Endpoints[TradeHistory].BuySide == "" {
+		return fmt.Errorf("Buy side of %s of exchange %s is empty", TradeHistory, self.Name)
+	}
+	return nil
+}
+
+// symbol returns the symbol of the pair of base and quote token IDs.
+func (self Config) symbol(base, quote string) string {
+	symbol := render(self.Symbol, map[string]string{"base": base, "quote": quote})
+	if self.SymbolCase == "lower" {
+		return strings.ToLower(symbol)
+	}
+	return strings.ToUpper(symbol)
+}
+
+// render replaces the {name} variables of template with their values.
+func render(template string, vars map[string]string) string {
+	pairs := make([]string, 0, 2*len(vars))
+	for name, value := range vars {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
@@ -0,0 +1,427 @@
+package generic
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
+	"github.com/KyberNetwork/reserve-data/exchange"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// GenericEndpoint calls the API of an exchange described by a Config.
+type GenericEndpoint struct {
+	config Config
+	client *httpclient.Client
+}
+
+// NewGenericEndpoint creates the endpoint of the exchange described by
+// config, it returns error if the config is invalid.
+func NewGenericEndpoint(config Config) (*GenericEndpoint, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &GenericEndpoint{
+		config: config,
+		client: httpclient.NewClient(config.Name, httpclient.NoRetryConfig(30*time.Second)),
+	}, nil
+}
+
+func (self *GenericEndpoint) sign(msg string) string {
+	mac := hmac.New(sha256.New, []byte(self.config.Secret))
+	if _, err := mac.Write([]byte(msg)); err != nil {
+		log.Printf("Encode message error: %s", err.Error())
+	}
+	if self.config.Signing.Encoding == "base64" {
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// request calls operation with vars and returns the decoded response.
+func (self *GenericEndpoint) request(operation string, vars map[string]string) (interface{}, error) {
+	endpoint := self.config.Endpoints[operation]
+	method := endpoint.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	params := url.Values{}
+	for name, template := range endpoint.Params {
+		if value := render(template, vars); value != "" {
+			params.Set(name, value)
+		}
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(self.config.Endpoint, "/")+render(endpoint.Path, vars), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	query := params.Encode()
+	if endpoint.Signed {
+		signing := self.config.Signing
+		if signing.KeyHeader != "" {
+			req.Header.Set(signing.KeyHeader, self.config.Key)
+		}
+		if signing.KeyParam != "" {
+			params.Set(signing.KeyParam, self.config.Key)
+		}
+		if signing.Timestamp != "" {
+			params.Set(signing.Timestamp, strconv.FormatUint(common.GetTimepoint(), 10))
+		}
+		query = params.Encode()
+		signature := self.sign(query)
+		if signing.Location == "header" {
+			req.Header.Set(signing.Signature, signature)
+		} else {
+			// the signature is the last param as it is not signed
+			query += "&" + url.Values{signing.Signature: {signature}}.Encode()
+		}
+	}
+	req.URL.RawQuery = query
+
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body := resp.Body
+	var result interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	dErr := decoder.Decode(&result)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s of %s returned with code: %d - %s", method, req.URL.Path, self.config.Name, resp.StatusCode, self.errorMessage(result, body))
+	}
+	if dErr != nil {
+		return nil, fmt.Errorf("Decoding %s response of %s failed: %s", operation, self.config.Name, dErr)
+	}
+	if self.config.Error != "" {
+		if msg, lErr := lookupString(result, self.config.Error); lErr == nil && msg != "" {
+			return nil, fmt.Errorf("%s of %s failed: %s", operation, self.config.Name, msg)
+		}
+	}
+	return result, nil
+}
+
+func (self *GenericEndpoint) errorMessage(result interface{}, body []byte) string {
+	if self.config.Error != "" {
+		if msg, err := lookupString(result, self.config.Error); err == nil && msg != "" {
+			return msg
+		}
+	}
+	return string(common.TruncStr(body))
+}
+
+func (self *GenericEndpoint) fields(operation string) map[string]string {
+	return self.config.Endpoints[operation].Fields
+}
+
+func (self *GenericEndpoint) symbolVars(base, quote string) map[string]string {
+	return map[string]string{
+		"base":   base,
+		"quote":  quote,
+		"symbol": self.config.symbol(base, quote),
+	}
+}
+
+func (self *GenericEndpoint) priceEntries(book interface{}, side string) ([]common.PriceEntry, error) {
+	fields := self.fields(OrderBook)
+	entries, err := lookupList(book, fields[side])
+	if err != nil {
+		return nil, err
+	}
+	result := make([]common.PriceEntry, 0, len(entries))
+	for _, entry := range entries {
+		price, pErr := lookupFloat(entry, fields["price"])
+		if pErr != nil {
+			return nil, pErr
+		}
+		quantity, qErr := lookupFloat(entry, fields["quantity"])
+		if qErr != nil {
+			return nil, qErr
+		}
+		result = append(result, common.NewPriceEntry(quantity, price))
+	}
+	return result, nil
+}
+
+func (self *GenericEndpoint) GetDepthOnePair(pair common.TokenPair) (exchange.GenericDepth, error) {
+	result := exchange.GenericDepth{}
+	book, err := self.request(OrderBook, self.symbolVars(pair.Base.ID, pair.Quote.ID))
+	if err != nil {
+		return result, err
+	}
+	if result.Bids, err = self.priceEntries(book, "bids"); err != nil {
+		return result, err
+	}
+	result.Asks, err = self.priceEntries(book, "asks")
+	return result, err
+}
+
+func (self *GenericEndpoint) GetBalances() ([]exchange.GenericBalance, error) {
+	resp, err := self.request(Balances, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	fields := self.fields(Balances)
+	items, err := lookupList(resp, fields["list"])
+	if err != nil {
+		return nil, err
+	}
+	var result []exchange.GenericBalance
+	for _, item := range items {
+		var (
+			balance exchange.GenericBalance
+			lErr    error
+		)
+		if balance.Asset, lErr = lookupString(item, fields["asset"]); lErr != nil {
+			return nil, lErr
+		}
+		if balance.Free, lErr = lookupFloat(item, fields["free"]); lErr != nil {
+			return nil, lErr
+		}
+		if balance.Locked, lErr = lookupFloat(item, fields["locked"]); lErr != nil {
+			return nil, lErr
+		}
+		result = append(result, balance)
+	}
+	return result, nil
+}
+
+func (self *GenericEndpoint) pairInfo(item interface{}) (common.ExchangePrecisionLimit, error) {
+	var (
+		result common.ExchangePrecisionLimit
+		fields = self.fields(PairsInfo)
+		err    error
+	)
+	for field, precision := range map[string]*int{
+		"price_precision":  &result.Precision.Price,
+		"amount_precision": &result.Precision.Amount,
+	} {
+		if path, ok := fields[field]; ok {
+			if *precision, err = lookupPrecision(item, path); err != nil {
+				return result, err
+			}
+		}
+	}
+	for field, limit := range map[string]*float64{
+		"min_amount":   &result.AmountLimit.Min,
+		"max_amount":   &result.AmountLimit.Max,
+		"min_price":    &result.PriceLimit.Min,
+		"max_price":    &result.PriceLimit.Max,
+		"min_notional": &result.MinNotional,
+	} {
+		if path, ok := fields[field]; ok {
+			if *limit, err = lookupFloat(item, path); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+func (self *GenericEndpoint) GetPairsInfo(pairIDs []common.TokenPairID) (common.ExchangeInfo, error) {
+	resp, err := self.request(PairsInfo, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	items, err := lookupList(resp, self.fields(PairsInfo)["list"])
+	if err != nil {
+		return nil, err
+	}
+	pairs := map[string]common.TokenPairID{}
+	for _, pairID := range pairIDs {
+		tokens := strings.Split(string(pairID), "-")
+		if len(tokens) != 2 {
+			return nil, fmt.Errorf("PairID %s is malformed", pairID)
+		}
+		pairs[strings.ToUpper(self.config.symbol(tokens[0], tokens[1]))] = pairID
+	}
+	result := common.ExchangeInfo{}
+	for _, item := range items {
+		symbol, lErr := lookupString(item, self.fields(PairsInfo)["symbol"])
+		if lErr != nil {
+			return nil, lErr
+		}
+		pairID, ok := pairs[strings.ToUpper(symbol)]
+		if !ok {
+			continue
+		}
+		info, iErr := self.pairInfo(item)
+		if iErr != nil {
+			return nil, fmt.Errorf("Parsing info of %s failed: %s", symbol, iErr)
+		}
+		result[pairID] = info
+	}
+	return result, nil
+}
+
+func (self *GenericEndpoint) GetDepositAddress(asset string) (string, error) {
+	resp, err := self.request(DepositAddress, map[string]string{"asset": asset})
+	if err != nil {
+		return "", err
+	}
+	return lookupString(resp, self.fields(DepositAddress)["address"])
+}
+
+func (self *GenericEndpoint) GetAccountTradeHistory(base, quote common.Token, fromID string) ([]common.TradeHistory, error) {
+	vars := self.symbolVars(base.ID, quote.ID)
+	vars["from_id"] = fromID
+	resp, err := self.request(TradeHistory, vars)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := self.config.Endpoints[TradeHistory]
+	fields := endpoint.Fields
+	items, err := lookupList(resp, fields["list"])
+	if err != nil {
+		return nil, err
+	}
+	var result []common.TradeHistory
+	for _, item := range items {
+		var (
+			trade           common.TradeHistory
+			side, tradeTime string
+			lErr            error
+		)
+		if trade.ID, lErr = lookupString(item, fields["id"]); lErr != nil {
+			return nil, lErr
+		}
+		if trade.Price, lErr = lookupFloat(item, fields["price"]); lErr != nil {
+			return nil, lErr
+		}
+		if trade.Qty, lErr = lookupFloat(item, fields["quantity"]); lErr != nil {
+			return nil, lErr
+		}
+		if side, lErr = lookupString(item, fields["side"]); lErr != nil {
+			return nil, lErr
+		}
+		if tradeTime, lErr = lookupString(item, fields["time"]); lErr != nil {
+			return nil, lErr
+		}
+		if trade.Timestamp, lErr = strconv.ParseUint(tradeTime, 10, 64); lErr != nil {
+			return nil, fmt.Errorf("Time %s of trade %s is invalid: %s", tradeTime, trade.ID, lErr)
+		}
+		trade.Type = "sell"
+		if strings.EqualFold(side, endpoint.BuySide) {
+			trade.Type = "buy"
+		}
+		result = append(result, trade)
+	}
+	return result, nil
+}
+
+func (self *GenericEndpoint) Withdraw(token common.Token, amount *big.Int, address ethereum.Address) (string, error) {
+	resp, err := self.request(Withdraw, map[string]string{
+		"asset":   token.ID,
+		"address": address.Hex(),
+		"amount":  strconv.FormatFloat(common.BigToFloat(amount, token.Decimals), 'f', -1, 64),
+	})
+	if err != nil {
+		return "", err
+	}
+	return lookupString(resp, self.fields(Withdraw)["id"])
+}
+
+func (self *GenericEndpoint) Trade(tradeType string, base, quote common.Token, rate, amount float64) (string, error) {
+	side, ok := self.config.Sides[strings.ToLower(tradeType)]
+	if !ok {
+		return "", fmt.Errorf("Trade type %s is invalid", tradeType)
+	}
+	vars := self.symbolVars(base.ID, quote.ID)
+	vars["side"] = side
+	vars["price"] = strconv.FormatFloat(rate, 'f', -1, 64)
+	vars["amount"] = strconv.FormatFloat(amount, 'f', -1, 64)
+	resp, err := self.request(Trade, vars)
+	if err != nil {
+		return "", err
+	}
+	return lookupString(resp, self.fields(Trade)["order_id"])
+}
+
+func (self *GenericEndpoint) CancelOrder(id, base, quote string) error {
+	vars := self.symbolVars(base, quote)
+	vars["order_id"] = id
+	_, err := self.request(CancelOrder, vars)
+	return err
+}
+
+func (self *GenericEndpoint) transfers(operation string, startTime, endTime uint64) ([]exchange.GenericTransfer, error) {
+	resp, err := self.request(operation, map[string]string{
+		"start_time": strconv.FormatUint(startTime, 10),
+		"end_time":   strconv.FormatUint(endTime, 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := self.config.Endpoints[operation]
+	items, err := lookupList(resp, endpoint.Fields["list"])
+	if err != nil {
+		return nil, err
+	}
+	var result []exchange.GenericTransfer
+	for _, item := range items {
+		var (
+			transfer exchange.GenericTransfer
+			status   string
+			lErr     error
+		)
+		if path, ok := endpoint.Fields["id"]; ok {
+			if transfer.ID, lErr = lookupString(item, path); lErr != nil {
+				return nil, lErr
+			}
+		}
+		if path, ok := endpoint.Fields["tx_id"]; ok {
+			if transfer.TxID, lErr = lookupString(item, path); lErr != nil {
+				return nil, lErr
+			}
+		}
+		if status, lErr = lookupString(item, endpoint.Fields["status"]); lErr != nil {
+			return nil, lErr
+		}
+		transfer.Done = contains(endpoint.Statuses, status)
+		result = append(result, transfer)
+	}
+	return result, nil
+}
+
+func (self *GenericEndpoint) DepositHistory(startTime, endTime uint64) ([]exchange.GenericTransfer, error) {
+	return self.transfers(DepositHistory, startTime, endTime)
+}
+
+func (self *GenericEndpoint) WithdrawHistory(startTime, endTime uint64) ([]exchange.GenericTransfer, error) {
+	return self.transfers(WithdrawHistory, startTime, endTime)
+}
+
+func (self *GenericEndpoint) OrderStatus(id, base, quote string) (exchange.GenericOrder, error) {
+	result := exchange.GenericOrder{}
+	vars := self.symbolVars(base, quote)
+	vars["order_id"] = id
+	resp, err := self.request(OrderStatus, vars)
+	if err != nil {
+		return result, err
+	}
+	endpoint := self.config.Endpoints[OrderStatus]
+	status, err := lookupString(resp, endpoint.Fields["status"])
+	if err != nil {
+		return result, err
+	}
+	result.Open = contains(endpoint.Statuses, status)
+	if result.ExecutedQty, err = lookupFloat(resp, endpoint.Fields["executed_qty"]); err != nil {
+		return result, err
+	}
+	result.OrigQty, err = lookupFloat(resp, endpoint.Fields["orig_qty"])
+	return result, err
+}
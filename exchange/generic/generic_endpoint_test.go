@@ -0,0 +1,278 @@
+package generic
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const (
+	testKey    = "test-key"
+	testSecret = "test-secret"
+)
+
+func testConfig(endpoint string) Config {
+	return Config{
+		Name:     "fakex",
+		Endpoint: endpoint,
+		Key:      testKey,
+		Secret:   testSecret,
+		Signing: SigningConfig{
+			Location:  "query",
+			Signature: "signature",
+			KeyHeader: "X-API-KEY",
+			Timestamp: "timestamp",
+		},
+		Symbol: "{base}-{quote}",
+		Sides:  map[string]string{"buy": "BUY", "sell": "SELL"},
+		Error:  "error",
+		Endpoints: map[string]EndpointConfig{
+			OrderBook: {
+				Path:   "/depth",
+				Params: map[string]string{"symbol": "{symbol}"},
+				Fields: map[string]string{"bids": "data.bids", "asks": "data.asks", "price": "0", "quantity": "1"},
+			},
+			Balances: {
+				Path:   "/account",
+				Signed: true,
+				Fields: map[string]string{"list": "balances", "asset": "asset", "free": "free", "locked": "locked"},
+			},
+			PairsInfo: {
+				Path:   "/pairs",
+				Fields: map[string]string{"list": "", "symbol": "symbol", "price_precision": "tick", "amount_precision": "decimals", "min_notional": "min_total"},
+			},
+			DepositAddress: {
+				Path:   "/deposit/address",
+				Signed: true,
+				Params: map[string]string{"asset": "{asset}"},
+				Fields: map[string]string{"address": "address"},
+			},
+			Trade: {
+				Method: http.MethodPost,
+				Path:   "/order",
+				Signed: true,
+				Params: map[string]string{"symbol": "{symbol}", "side": "{side}", "price": "{price}", "quantity": "{amount}"},
+				Fields: map[string]string{"order_id": "id"},
+			},
+			OrderStatus: {
+				Path:     "/order",
+				Signed:   true,
+				Params:   map[string]string{"symbol": "{symbol}", "id": "{order_id}"},
+				Fields:   map[string]string{"status": "status", "executed_qty": "executed", "orig_qty": "quantity"},
+				Statuses: []string{"NEW", "PARTIALLY_FILLED"},
+			},
+			CancelOrder: {
+				Method: http.MethodDelete,
+				Path:   "/order",
+				Signed: true,
+				Params: map[string]string{"symbol": "{symbol}", "id": "{order_id}"},
+			},
+			Withdraw: {
+				Method: http.MethodPost,
+				Path:   "/withdraw",
+				Signed: true,
+				Params: map[string]string{"asset": "{asset}", "address": "{address}", "amount": "{amount}"},
+				Fields: map[string]string{"id": "id"},
+			},
+			DepositHistory: {
+				Path:     "/deposits",
+				Signed:   true,
+				Params:   map[string]string{"from": "{start_time}", "to": "{end_time}"},
+				Fields:   map[string]string{"list": "", "tx_id": "tx", "status": "status"},
+				Statuses: []string{"success"},
+			},
+			WithdrawHistory: {
+				Path:     "/withdrawals",
+				Signed:   true,
+				Params:   map[string]string{"from": "{start_time}", "to": "{end_time}"},
+				Fields:   map[string]string{"list": "", "id": "id", "tx_id": "tx", "status": "status"},
+				Statuses: []string{"success"},
+			},
+			TradeHistory: {
+				Path:    "/trades",
+				Signed:  true,
+				Params:  map[string]string{"symbol": "{symbol}", "from_id": "{from_id}"},
+				Fields:  map[string]string{"list": "", "id": "id", "price": "price", "quantity": "qty", "side": "buyer", "time": "time"},
+				BuySide: "true",
+			},
+		},
+	}
+}
+
+// newFakeExchange returns a fake exchange which checks the signature of
+// signed requests and responds with the responses by path.
+func newFakeExchange(t *testing.T, responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if signature := r.URL.Query().Get("signature"); signature != "" {
+			if r.Header.Get("X-API-KEY") != testKey {
+				t.Errorf("Request %s has no API key", r.URL.Path)
+			}
+			query := r.URL.RawQuery[:strings.LastIndex(r.URL.RawQuery, "&signature=")]
+			mac := hmac.New(sha256.New, []byte(testSecret))
+			_, _ = mac.Write([]byte(query))
+			if expected := hex.EncodeToString(mac.Sum(nil)); signature != expected {
+				t.Errorf("Request %s signature is %s, expected %s", r.URL.Path, signature, expected)
+			}
+		}
+		response, ok := responses[r.Method+" "+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"error":"not found"}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, response)
+	}))
+}
+
+func newTestEndpoint(t *testing.T, responses map[string]string) (*GenericEndpoint, func()) {
+	server := newFakeExchange(t, responses)
+	endpoint, err := NewGenericEndpoint(testConfig(server.URL))
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return endpoint, server.Close
+}
+
+func TestConfigValidate(t *testing.T) {
+	config := testConfig("http://localhost")
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got error: %s", err)
+	}
+	book := config.Endpoints[OrderBook]
+	delete(book.Fields, "asks")
+	if err := config.Validate(); err == nil {
+		t.Fatal("Expected error for unmapped field, got nil")
+	}
+	config = testConfig("http://localhost")
+	delete(config.Endpoints, Withdraw)
+	if err := config.Validate(); err == nil {
+		t.Fatal("Expected error for undescribed operation, got nil")
+	}
+}
+
+func TestGetDepthOnePair(t *testing.T) {
+	endpoint, closeServer := newTestEndpoint(t, map[string]string{
+		"GET /depth": `{"data":{"bids":[["0.002","10"],["0.001","5"]],"asks":[[0.003,1.5]]}}`,
+	})
+	defer closeServer()
+	pair := common.TokenPair{Base: common.Token{ID: "KNC"}, Quote: common.Token{ID: "ETH"}}
+	depth, err := endpoint.GetDepthOnePair(pair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(depth.Bids) != 2 || depth.Bids[0] != common.NewPriceEntry(10, 0.002) {
+		t.Fatalf("Unexpected bids: %+v", depth.Bids)
+	}
+	if len(depth.Asks) != 1 || depth.Asks[0] != common.NewPriceEntry(1.5, 0.003) {
+		t.Fatalf("Unexpected asks: %+v", depth.Asks)
+	}
+}
+
+func TestGetBalancesAndPairsInfo(t *testing.T) {
+	endpoint, closeServer := newTestEndpoint(t, map[string]string{
+		"GET /account": `{"balances":[{"asset":"knc","free":"1.5","locked":"0.5"}]}`,
+		"GET /pairs":   `[{"symbol":"KNC-ETH","tick":"0.000001","decimals":2,"min_total":"0.01"},{"symbol":"OMG-BTC","tick":"0.1","decimals":1}]`,
+	})
+	defer closeServer()
+	balances, err := endpoint.GetBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 1 || balances[0].Asset != "knc" || balances[0].Free != 1.5 || balances[0].Locked != 0.5 {
+		t.Fatalf("Unexpected balances: %+v", balances)
+	}
+	info, err := endpoint.GetPairsInfo([]common.TokenPairID{"KNC-ETH"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info) != 1 {
+		t.Fatalf("Expected info of 1 pair, got %d", len(info))
+	}
+	limit := info["KNC-ETH"]
+	if limit.Precision.Price != 6 || limit.Precision.Amount != 2 || limit.MinNotional != 0.01 {
+		t.Fatalf("Unexpected pair info: %+v", limit)
+	}
+}
+
+func TestTradeAndOrderStatus(t *testing.T) {
+	endpoint, closeServer := newTestEndpoint(t, map[string]string{
+		"POST /order": `{"id":12345}`,
+		"GET /order":  `{"status":"PARTIALLY_FILLED","executed":"1","quantity":"3"}`,
+	})
+	defer closeServer()
+	id, err := endpoint.Trade("buy", common.Token{ID: "KNC"}, common.Token{ID: "ETH"}, 0.002, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "12345" {
+		t.Fatalf("Expected order id 12345, got %s", id)
+	}
+	order, err := endpoint.OrderStatus(id, "KNC", "ETH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !order.Open || order.ExecutedQty != 1 || order.OrigQty != 3 {
+		t.Fatalf("Unexpected order: %+v", order)
+	}
+	if err = endpoint.CancelOrder(id, "KNC", "ETH"); err == nil {
+		t.Fatal("Expected error for failed cancel, got nil")
+	}
+}
+
+func TestHistories(t *testing.T) {
+	endpoint, closeServer := newTestEndpoint(t, map[string]string{
+		"GET /deposits":    `[{"tx":"0xabc","status":"success"},{"tx":"0xdef","status":"pending"}]`,
+		"GET /withdrawals": `[{"id":"w1","tx":null,"status":"processing"}]`,
+		"GET /trades":      `[{"id":7,"price":"0.002","qty":"10","buyer":true,"time":1540000000000},{"id":8,"price":"0.003","qty":"2","buyer":false,"time":1540000001000}]`,
+	})
+	defer closeServer()
+	deposits, err := endpoint.DepositHistory(0, common.GetTimepoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deposits) != 2 || deposits[0].TxID != "0xabc" || !deposits[0].Done || deposits[1].Done {
+		t.Fatalf("Unexpected deposits: %+v", deposits)
+	}
+	withdrawals, err := endpoint.WithdrawHistory(0, common.GetTimepoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withdrawals) != 1 || withdrawals[0].ID != "w1" || withdrawals[0].TxID != "" || withdrawals[0].Done {
+		t.Fatalf("Unexpected withdrawals: %+v", withdrawals)
+	}
+	trades, err := endpoint.GetAccountTradeHistory(common.Token{ID: "KNC"}, common.Token{ID: "ETH"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []common.TradeHistory{
+		{ID: "7", Price: 0.002, Qty: 10, Type: "buy", Timestamp: 1540000000000},
+		{ID: "8", Price: 0.003, Qty: 2, Type: "sell", Timestamp: 1540000001000},
+	}
+	if len(trades) != len(expected) {
+		t.Fatalf("Expected %d trades, got %d", len(expected), len(trades))
+	}
+	for i := range expected {
+		if trades[i] != expected[i] {
+			t.Fatalf("Expected trade %+v, got %+v", expected[i], trades[i])
+		}
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	endpoint, closeServer := newTestEndpoint(t, map[string]string{
+		"GET /deposit/address": `{"error":"asset is suspended"}`,
+	})
+	defer closeServer()
+	_, err := endpoint.GetDepositAddress("KNC")
+	if err == nil || !strings.Contains(err.Error(), "asset is suspended") {
+		t.Fatalf("Expected error of the response, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package generic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// lookup returns the value at path in v, path is dot separated keys and
+// array indexes, the empty path is v itself.
+func lookup(v interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return v, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("Key %s of %s is not found", key, path)
+			}
+			v = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("Index %s of %s is invalid", key, path)
+			}
+			v = node[index]
+		default:
+			return nil, fmt.Errorf("Value at %s of %s is not an object or array", key, path)
+		}
+	}
+	return v, nil
+}
+
+func lookupList(v interface{}, path string) ([]interface{}, error) {
+	value, err := lookup(v, path)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Value at %s is not an array", path)
+	}
+	return list, nil
+}
+
+// lookupString returns the value at path as a string, numbers and booleans
+// are formatted.
+func lookupString(v interface{}, path string) (string, error) {
+	value, err := lookup(v, path)
+	if err != nil {
+		return "", err
+	}
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	default:
+		return "", fmt.Errorf("Value at %s is not a string, number or boolean", path)
+	}
+}
+
+// lookupFloat returns the value at path as a float, the value may be a
+// number or a string.
+func lookupFloat(v interface{}, path string) (float64, error) {
+	value, err := lookupString(v, path)
+	if err != nil {
+		return 0, err
+	}
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Value at %s is not a number: %s", path, err)
+	}
+	return result, nil
+}
+
+// lookupPrecision returns the precision at path, the value may be a number of
+// decimals or a step size, eg: 0.001 is a precision of 3.
+func lookupPrecision(v interface{}, path string) (int, error) {
+	value, err := lookupString(v, path)
+	if err != nil {
+		return 0, err
+	}
+	if !strings.Contains(value, ".") {
+		precision, aErr := strconv.Atoi(value)
+		if aErr != nil {
+			return 0, fmt.Errorf("Value at %s is not a precision: %s", path, aErr)
+		}
+		return precision, nil
+	}
+	parts := strings.Split(strings.TrimRight(value, "0"), ".")
+	return len(parts[1]), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
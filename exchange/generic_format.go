@@ -0,0 +1,31 @@
+package exchange
+
+import "github.com/KyberNetwork/reserve-data/common"
+
+// GenericDepth is the order book of a pair on a config driven exchange.
+type GenericDepth struct {
+	Bids []common.PriceEntry
+	Asks []common.PriceEntry
+}
+
+// GenericBalance is the balance of an asset on a config driven exchange.
+type GenericBalance struct {
+	Asset  string
+	Free   float64
+	Locked float64
+}
+
+// GenericOrder is the status of an order on a config driven exchange.
+type GenericOrder struct {
+	ExecutedQty float64
+	OrigQty     float64
+	// Open is true if the order may still be filled.
+	Open bool
+}
+
+// GenericTransfer is a deposit or a withdrawal on a config driven exchange.
+type GenericTransfer struct {
+	ID   string
+	TxID string
+	Done bool
+}
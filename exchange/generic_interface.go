@@ -0,0 +1,42 @@
+package exchange
+
+import (
+	"math/big"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// GenericInterface contains the methods to interact with a centralized
+// exchange whose API is described in a config file.
+type GenericInterface interface {
+	GetDepthOnePair(pair common.TokenPair) (GenericDepth, error)
+
+	GetBalances() ([]GenericBalance, error)
+
+	// GetPairsInfo returns the precisions and limits of the pairs listed by
+	// the exchange, the pairs which are not listed are omitted.
+	GetPairsInfo(pairIDs []common.TokenPairID) (common.ExchangeInfo, error)
+
+	GetDepositAddress(asset string) (string, error)
+
+	GetAccountTradeHistory(base, quote common.Token, fromID string) ([]common.TradeHistory, error)
+
+	Withdraw(
+		token common.Token,
+		amount *big.Int,
+		address ethereum.Address) (string, error)
+
+	Trade(
+		tradeType string,
+		base, quote common.Token,
+		rate, amount float64) (string, error)
+
+	CancelOrder(id, base, quote string) error
+
+	DepositHistory(startTime, endTime uint64) ([]GenericTransfer, error)
+
+	WithdrawHistory(startTime, endTime uint64) ([]GenericTransfer, error)
+
+	OrderStatus(id, base, quote string) (GenericOrder, error)
+}
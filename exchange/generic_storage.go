@@ -0,0 +1,12 @@
+package exchange
+
+import "github.com/KyberNetwork/reserve-data/common"
+
+// GenericStorage is the interface that wraps all database operation of a
+// config driven exchange.
+type GenericStorage interface {
+	StoreTradeHistory(data common.ExchangeTradeHistory) error
+
+	GetTradeHistory(fromTime, toTime uint64) (common.ExchangeTradeHistory, error)
+	GetLastIDTradeHistory(pair string) (string, error)
+}
@@ -15,14 +15,18 @@ import (
 	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
 	"github.com/KyberNetwork/reserve-data/exchange"
+	"github.com/KyberNetwork/reserve-data/exchange/ratelimit"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 //HuobiEndpoint endpoint object
 type HuobiEndpoint struct {
-	signer Signer
-	interf Interface
+	signer  Signer
+	interf  Interface
+	limiter *ratelimit.Limiter
+	client  *httpclient.Client
 }
 
 func (self *HuobiEndpoint) fillRequest(req *http.Request, signNeeded bool) {
@@ -49,9 +53,6 @@ func (self *HuobiEndpoint) GetResponse(
 	method string, reqURL string,
 	params map[string]string, signNeeded bool) ([]byte, error) {
 
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
-	}
 	reqBody, err := json.Marshal(params)
 	if err != nil {
 		return nil, err
@@ -64,6 +65,11 @@ func (self *HuobiEndpoint) GetResponse(
 		req.Body = ioutil.NopCloser(strings.NewReader(string(reqBody)))
 	}
 	req.Header.Add("Accept", "application/json")
+	if self.limiter != nil {
+		if err = self.limiter.Wait(requestPriority(method, req.URL.Path), 1); err != nil {
+			return nil, err
+		}
+	}
 
 	q := req.URL.Query()
 	if signNeeded {
@@ -85,24 +91,22 @@ func (self *HuobiEndpoint) GetResponse(
 	self.fillRequest(req, signNeeded)
 	var respBody []byte
 	//log.Printf("request to huobi: %s\n", req.URL)
-	resp, err := client.Do(req)
+	resp, err := self.client.Do(req)
 	if err != nil {
 		return respBody, err
 	}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("Response body close error: %s", cErr.Error())
-		}
-	}()
 	switch resp.StatusCode {
 	case 429:
 		err = errors.New("breaking Huobi request rate limit")
+		if self.limiter != nil {
+			self.limiter.Backoff(ratelimit.RetryAfter(resp.Header, huobiRateLimitBackoff))
+		}
 		break
 	case 500:
 		err = errors.New("500 from Huobi, its fault")
 		break
 	case 200:
-		respBody, err = ioutil.ReadAll(resp.Body)
+		respBody = resp.Body
 		break
 	}
 	return respBody, err
@@ -391,7 +395,9 @@ func (self *HuobiEndpoint) GetExchangeInfo() (exchange.HuobiExchangeInfo, error)
 	return result, err
 }
 
-//NewHuobiEndpoint return new endpoint instance
-func NewHuobiEndpoint(signer Signer, interf Interface) *HuobiEndpoint {
-	return &HuobiEndpoint{signer, interf}
+//NewHuobiEndpoint return new endpoint instance,
+//its requests are throttled by limiter if it is not nil
+func NewHuobiEndpoint(signer Signer, interf Interface, limiter *ratelimit.Limiter) *HuobiEndpoint {
+	client := httpclient.NewClient("huobi", httpclient.NoRetryConfig(30*time.Second))
+	return &HuobiEndpoint{signer, interf, limiter, client}
 }
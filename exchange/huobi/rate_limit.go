@@ -0,0 +1,34 @@
+package huobi
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/exchange/ratelimit"
+)
+
+const (
+	// Huobi allows 100 requests per 10 seconds for an API key, some of them
+	// are reserved for trade and cancel requests.
+	huobiRequestLimit    = 100
+	huobiRequestInterval = 10 * time.Second
+	huobiReservedLimit   = 10
+	// huobiRateLimitBackoff is the time requests are blocked after a 429
+	// response without Retry-After header.
+	huobiRateLimitBackoff = 10 * time.Second
+)
+
+// NewRateLimiter returns a limiter of Huobi request limit, it is shared by
+// all requests of an endpoint.
+func NewRateLimiter() *ratelimit.Limiter {
+	return ratelimit.NewLimiter("huobi", huobiRequestLimit, huobiRequestInterval, huobiReservedLimit)
+}
+
+// requestPriority returns trade priority for placing and cancelling orders.
+func requestPriority(method, path string) ratelimit.Priority {
+	if method == http.MethodPost && (strings.HasSuffix(path, "/v1/order/orders/place") || strings.HasSuffix(path, "/submitcancel")) {
+		return ratelimit.TradePriority
+	}
+	return ratelimit.DataPriority
+}
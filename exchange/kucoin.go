@@ -0,0 +1,544 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/settings"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	kucoinEpsilon float64 = 0.0000001 // 10e-7
+
+	// KuCoin accounts, deposits and withdrawals use the main account while
+	// orders use the trade account.
+	kucoinMainAccount  = "main"
+	kucoinTradeAccount = "trade"
+)
+
+type Kucoin struct {
+	interf  KucoinInterface
+	storage KucoinStorage
+	setting Setting
+}
+
+// kucoinSymbol returns the KuCoin symbol of a pair, eg: KNC-ETH.
+func kucoinSymbol(base, quote string) string {
+	return strings.ToUpper(fmt.Sprintf("%s-%s", base, quote))
+}
+
+func (self *Kucoin) TokenAddresses() (map[string]ethereum.Address, error) {
+	addresses, err := self.setting.GetDepositAddresses(settings.Kucoin)
+	if err != nil {
+		return nil, err
+	}
+	return addresses.GetData(), nil
+}
+
+func (self *Kucoin) MarshalText() (text []byte, err error) {
+	return []byte(self.ID()), nil
+}
+
+// Address returns the deposit address of a token on KuCoin.
+// It will prioritize the live adress from KuCoin over the current address in storage
+func (self *Kucoin) Address(token common.Token) (ethereum.Address, bool) {
+	liveAddress, err := self.interf.GetDepositAddress(token.ID)
+	if err != nil || liveAddress.Address == "" {
+		log.Printf("WARNING: Get KuCoin live deposit address for token %s failed: err: (%v) or the address repplied is empty . Use the currently available address instead", token.ID, err)
+		addrs, uErr := self.setting.GetDepositAddresses(settings.Kucoin)
+		if uErr != nil {
+			log.Printf("WARNING: get address of token %s in KuCoin exchange failed:(%s), it will be considered as not supported", token.ID, uErr.Error())
+			return ethereum.Address{}, false
+		}
+		return addrs.Get(token.ID)
+	}
+	log.Printf("Got KuCoin live deposit address for token %s, attempt to update it to current setting", token.ID)
+	addrs := common.NewExchangeAddresses()
+	addrs.Update(token.ID, ethereum.HexToAddress(liveAddress.Address))
+	if err = self.setting.UpdateDepositAddress(settings.Kucoin, *addrs, common.GetTimepoint()); err != nil {
+		log.Printf("WARNING: cannot update deposit address for token %s on KuCoin: (%s)", token.ID, err.Error())
+	}
+	return ethereum.HexToAddress(liveAddress.Address), true
+}
+
+func (self *Kucoin) UpdateDepositAddress(token common.Token, address string) error {
+	liveAddress, err := self.interf.GetDepositAddress(token.ID)
+	if err != nil || liveAddress.Address == "" {
+		log.Printf("WARNING: Get KuCoin live deposit address for token %s failed: err: (%v) or the address repplied is empty . Use the currently available address instead", token.ID, err)
+		addrs := common.NewExchangeAddresses()
+		addrs.Update(token.ID, ethereum.HexToAddress(address))
+		return self.setting.UpdateDepositAddress(settings.Kucoin, *addrs, common.GetTimepoint())
+	}
+	log.Printf("Got KuCoin live deposit address for token %s, attempt to update it to current setting", token.ID)
+	addrs := common.NewExchangeAddresses()
+	addrs.Update(token.ID, ethereum.HexToAddress(liveAddress.Address))
+	return self.setting.UpdateDepositAddress(settings.Kucoin, *addrs, common.GetTimepoint())
+}
+
+func (self *Kucoin) precisionFromIncrement(increment string) int {
+	re := regexp.MustCompile("0*$")
+	parts := strings.Split(re.ReplaceAllString(increment, ""), ".")
+	if len(parts) > 1 {
+		return len(parts[1])
+	}
+	return 0
+}
+
+// GetLiveExchangeInfos queries the Exchange Endpoint for exchange precision and limit of a certain pair ID
+// It return error if occurs.
+func (self *Kucoin) GetLiveExchangeInfos(tokenPairIDs []common.TokenPairID) (common.ExchangeInfo, error) {
+	result := make(common.ExchangeInfo)
+	symbols, err := self.interf.GetSymbols()
+	if err != nil {
+		return result, err
+	}
+	for _, pairID := range tokenPairIDs {
+		exchangePrecisionLimit, ok := self.getPrecisionLimitFromSymbols(pairID, symbols)
+		if !ok {
+			return result, fmt.Errorf("KuCoin symbols reply doesn't contain token pair %s", string(pairID))
+		}
+		result[pairID] = exchangePrecisionLimit
+	}
+	return result, nil
+}
+
+// getPrecisionLimitFromSymbols find the pairID amongs symbols from exchanges,
+// return ExchangePrecisionLimit of that pair and true if the pairID exist amongs symbols, false if otherwise
+func (self *Kucoin) getPrecisionLimitFromSymbols(pair common.TokenPairID, symbols KucoinSymbols) (common.ExchangePrecisionLimit, bool) {
+	var result common.ExchangePrecisionLimit
+	pairName := strings.ToUpper(string(pair))
+	for _, symbol := range symbols {
+		if strings.ToUpper(symbol.Symbol) != pairName {
+			continue
+		}
+		result.Precision.Amount = self.precisionFromIncrement(symbol.BaseIncrement)
+		result.Precision.Price = self.precisionFromIncrement(symbol.PriceIncrement)
+		result.AmountLimit.Min, _ = strconv.ParseFloat(symbol.BaseMinSize, 64)
+		result.AmountLimit.Max, _ = strconv.ParseFloat(symbol.BaseMaxSize, 64)
+		// KuCoin doesn't limit price, the increment is the minimum price
+		result.PriceLimit.Min, _ = strconv.ParseFloat(symbol.PriceIncrement, 64)
+		result.MinNotional, _ = strconv.ParseFloat(symbol.QuoteMinSize, 64)
+		return result, true
+	}
+	return result, false
+}
+
+func (self *Kucoin) UpdatePairsPrecision() error {
+	symbols, err := self.interf.GetSymbols()
+	if err != nil {
+		return err
+	}
+	exInfo, err := self.GetInfo()
+	if err != nil {
+		return fmt.Errorf("Can't get Exchange Info for KuCoin from persistent storage. (%s)", err)
+	}
+	if exInfo == nil {
+		return errors.New("Exchange info of KuCoin is nil")
+	}
+	for pair := range exInfo.GetData() {
+		exchangePrecisionLimit, exist := self.getPrecisionLimitFromSymbols(pair, symbols)
+		if !exist {
+			return fmt.Errorf("KuCoin symbols reply doesn't contain token pair %s", pair)
+		}
+		exInfo[pair] = exchangePrecisionLimit
+	}
+	return self.setting.UpdateExchangeInfo(settings.Kucoin, exInfo, common.GetTimepoint())
+}
+
+func (self *Kucoin) GetInfo() (common.ExchangeInfo, error) {
+	return self.setting.GetExchangeInfo(settings.Kucoin)
+}
+
+func (self *Kucoin) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
+	exInfo, err := self.setting.GetExchangeInfo(settings.Kucoin)
+	if err != nil {
+		return common.ExchangePrecisionLimit{}, err
+	}
+	return exInfo.Get(pair)
+}
+
+func (self *Kucoin) GetFee() (common.ExchangeFees, error) {
+	return self.setting.GetFee(settings.Kucoin)
+}
+
+func (self *Kucoin) GetMinDeposit() (common.ExchangesMinDeposit, error) {
+	return self.setting.GetMinDeposit(settings.Kucoin)
+}
+
+// ID must return the exact string or else simulation will fail
+func (self *Kucoin) ID() common.ExchangeID {
+	return common.ExchangeID(settings.Kucoin.String())
+}
+
+func (self *Kucoin) TokenPairs() ([]common.TokenPair, error) {
+	result := []common.TokenPair{}
+	exInfo, err := self.setting.GetExchangeInfo(settings.Kucoin)
+	if err != nil {
+		return nil, err
+	}
+	for pair := range exInfo.GetData() {
+		pairIDs := strings.Split(string(pair), "-")
+		if len(pairIDs) != 2 {
+			return result, fmt.Errorf("KuCoin PairID %s is malformed", string(pair))
+		}
+		tok1, uErr := self.setting.GetTokenByID(pairIDs[0])
+		if uErr != nil {
+			return result, fmt.Errorf("KuCoin cant get Token %s, %s", pairIDs[0], uErr)
+		}
+		tok2, uErr := self.setting.GetTokenByID(pairIDs[1])
+		if uErr != nil {
+			return result, fmt.Errorf("KuCoin cant get Token %s, %s", pairIDs[1], uErr)
+		}
+		tokPair := common.TokenPair{
+			Base:  tok1,
+			Quote: tok2,
+		}
+		result = append(result, tokPair)
+	}
+	return result, nil
+}
+
+func (self *Kucoin) Name() string {
+	return "kucoin"
+}
+
+func (self *Kucoin) QueryOrder(id string) (done float64, remaining float64, finished bool, err error) {
+	result, err := self.interf.OrderStatus(id)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	done, _ = strconv.ParseFloat(result.DealSize, 64)
+	total, _ := strconv.ParseFloat(result.Size, 64)
+	finished = !result.IsActive || total-done < kucoinEpsilon
+	return done, total - done, finished, nil
+}
+
+func (self *Kucoin) Trade(tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
+	result, err := self.interf.Trade(tradeType, base, quote, rate, amount)
+	if err != nil {
+		return "", 0, 0, false, err
+	}
+	done, remaining, finished, err = self.QueryOrder(result.OrderID)
+	if err != nil {
+		log.Printf("KuCoin Query order error: %s", err.Error())
+	}
+	return result.OrderID, done, remaining, finished, err
+}
+
+// Withdraw moves the amount from the trade account to the main account then
+// withdraws it.
+func (self *Kucoin) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
+	if _, err := self.interf.InnerTransfer(token.ID, common.BigToFloat(amount, token.Decimals), kucoinTradeAccount, kucoinMainAccount); err != nil {
+		return "", fmt.Errorf("KuCoin can't transfer %s to main account before withdrawing: %s", token.ID, err)
+	}
+	return self.interf.Withdraw(token, amount, address)
+}
+
+func (self *Kucoin) CancelOrder(id string, base, quote string) error {
+	result, err := self.interf.CancelOrder(id)
+	if err != nil {
+		return err
+	}
+	for _, cancelledID := range result.CancelledOrderIDs {
+		if cancelledID == id {
+			return nil
+		}
+	}
+	return errors.New("KuCoin Couldn't cancel order id " + id)
+}
+
+func (self *Kucoin) FetchOnePairData(
+	wg *sync.WaitGroup,
+	pair common.TokenPair,
+	data *sync.Map,
+	timepoint uint64) {
+
+	defer wg.Done()
+	result := common.ExchangePrice{}
+
+	timestamp := common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Timestamp = timestamp
+	result.Valid = true
+	respData, err := self.interf.GetDepthOnePair(pair)
+	returnTime := common.GetTimestamp()
+	result.ReturnTime = returnTime
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+	} else {
+		for _, buy := range respData.Bids {
+			quantity, _ := strconv.ParseFloat(buy.Quantity, 64)
+			rate, _ := strconv.ParseFloat(buy.Rate, 64)
+			result.Bids = append(result.Bids, common.NewPriceEntry(quantity, rate))
+		}
+		for _, sell := range respData.Asks {
+			quantity, _ := strconv.ParseFloat(sell.Quantity, 64)
+			rate, _ := strconv.ParseFloat(sell.Rate, 64)
+			result.Asks = append(result.Asks, common.NewPriceEntry(quantity, rate))
+		}
+	}
+	data.Store(pair.PairID(), result)
+}
+
+func (self *Kucoin) FetchPriceData(timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+	wait := sync.WaitGroup{}
+	data := sync.Map{}
+	pairs, err := self.TokenPairs()
+	if err != nil {
+		return nil, err
+	}
+	var i int
+	var x int
+	for i < len(pairs) {
+		for x = i; x < len(pairs) && x < i+batchSize; x++ {
+			wait.Add(1)
+			pair := pairs[x]
+			go self.FetchOnePairData(&wait, pair, &data, timepoint)
+		}
+		wait.Wait()
+		i = x
+	}
+	result := map[common.TokenPairID]common.ExchangePrice{}
+	data.Range(func(key, value interface{}) bool {
+		tokenPairID, ok := key.(common.TokenPairID)
+		if !ok {
+			err = fmt.Errorf("Key (%v) cannot be asserted to TokenPairID", key)
+			return false
+		}
+		exPrice, ok := value.(common.ExchangePrice)
+		if !ok {
+			err = fmt.Errorf("Value (%v) cannot be asserted to ExchangePrice", value)
+			return false
+		}
+		result[tokenPairID] = exPrice
+		return true
+	})
+	return result, err
+}
+
+// FetchEBalanceData returns the balances of the trade account, the balances
+// of the main account are deposits which are not transferred to the trade
+// account yet.
+func (self *Kucoin) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, error) {
+	result := common.EBalanceEntry{}
+	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Valid = true
+	result.Error = ""
+	accounts, err := self.interf.GetAccounts()
+	result.ReturnTime = common.GetTimestamp()
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+		result.Status = false
+		return result, nil
+	}
+	result.AvailableBalance = map[string]float64{}
+	result.LockedBalance = map[string]float64{}
+	result.DepositBalance = map[string]float64{}
+	result.Status = true
+	for _, account := range accounts {
+		tokenID := strings.ToUpper(account.Currency)
+		if _, uErr := self.setting.GetTokenByID(tokenID); uErr != nil {
+			continue
+		}
+		switch account.Type {
+		case kucoinTradeAccount:
+			available, _ := strconv.ParseFloat(account.Available, 64)
+			holds, _ := strconv.ParseFloat(account.Holds, 64)
+			result.AvailableBalance[tokenID] += available
+			result.LockedBalance[tokenID] += holds
+		case kucoinMainAccount:
+			balance, _ := strconv.ParseFloat(account.Balance, 64)
+			result.DepositBalance[tokenID] += balance
+		}
+	}
+	return result, nil
+}
+
+//FetchOnePairTradeHistory fetch trade history for one pair from exchange
+func (self *Kucoin) FetchOnePairTradeHistory(
+	wait *sync.WaitGroup,
+	data *sync.Map,
+	pair common.TokenPair) {
+
+	defer wait.Done()
+	result := []common.TradeHistory{}
+	tokenPair := fmt.Sprintf("%s-%s", pair.Base.ID, pair.Quote.ID)
+	lastTime, err := self.storage.GetLastTimeTradeHistory(tokenPair)
+	if err != nil {
+		log.Printf("Cannot get last time trade history: %s", err.Error())
+	}
+	var startTime uint64
+	if lastTime != 0 {
+		startTime = lastTime + 1
+	}
+	fills, err := self.interf.GetAccountTradeHistory(pair.Base, pair.Quote, startTime)
+	if err != nil {
+		log.Printf("KuCoin Cannot fetch data for pair %s: %s", tokenPair, err.Error())
+	}
+	for _, fill := range fills {
+		price, _ := strconv.ParseFloat(fill.Price, 64)
+		quantity, _ := strconv.ParseFloat(fill.Size, 64)
+		historyType := "sell"
+		if fill.Side == "buy" {
+			historyType = "buy"
+		}
+		tradeHistory := common.NewTradeHistory(
+			fill.TradeID,
+			price,
+			quantity,
+			historyType,
+			fill.CreatedAt,
+		)
+		result = append(result, tradeHistory)
+	}
+	data.Store(pair.PairID(), result)
+}
+
+//FetchTradeHistory get all trade history for all tokens in the exchange
+func (self *Kucoin) FetchTradeHistory() {
+	t := time.NewTicker(10 * time.Minute)
+	go func() {
+		for {
+			if err := self.fetchTradeHistoryOnce(); err != nil {
+				log.Printf("KuCoin fetch trade history failed (%s). Try again in 10 mins", err.Error())
+			}
+			<-t.C
+		}
+	}()
+}
+
+func (self *Kucoin) fetchTradeHistoryOnce() error {
+	result := common.ExchangeTradeHistory{}
+	data := sync.Map{}
+	pairs, err := self.TokenPairs()
+	if err != nil {
+		return err
+	}
+	wait := sync.WaitGroup{}
+	var i int
+	var x int
+	for i < len(pairs) {
+		for x = i; x < len(pairs) && x < i+batchSize; x++ {
+			wait.Add(1)
+			pair := pairs[x]
+			go self.FetchOnePairTradeHistory(&wait, &data, pair)
+		}
+		i = x
+		wait.Wait()
+	}
+	data.Range(func(key, value interface{}) bool {
+		tokenPairID, ok := key.(common.TokenPairID)
+		if !ok {
+			err = fmt.Errorf("Key (%v) cannot be asserted to TokenPairID", key)
+			return false
+		}
+		tradeHistories, ok := value.([]common.TradeHistory)
+		if !ok {
+			err = fmt.Errorf("Value (%v) cannot be asserted to []TradeHistory", value)
+			return false
+		}
+		result[tokenPairID] = tradeHistories
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return self.storage.StoreTradeHistory(result)
+}
+
+func (self *Kucoin) GetTradeHistory(fromTime, toTime uint64) (common.ExchangeTradeHistory, error) {
+	return self.storage.GetTradeHistory(fromTime, toTime)
+}
+
+// DepositStatus returns done once the deposit succeeded and is transferred
+// from the main account to the trade account.
+func (self *Kucoin) DepositStatus(id common.ActivityID, txHash, currency string, amount float64, timepoint uint64) (string, error) {
+	startTime := timepoint - 86400000
+	endTime := timepoint
+	deposits, err := self.interf.DepositHistory(startTime, endTime)
+	if err != nil {
+		return "", err
+	}
+	for _, deposit := range deposits {
+		// walletTxId of KuCoin may be suffixed by the index of the transfer
+		// in the transaction, eg: <txHash>@<index>
+		if !strings.HasPrefix(strings.ToLower(deposit.WalletTxID), strings.ToLower(txHash)) {
+			continue
+		}
+		switch deposit.Status {
+		case "SUCCESS":
+			depositAmount, pErr := strconv.ParseFloat(deposit.Amount, 64)
+			if pErr != nil {
+				return "", fmt.Errorf("KuCoin deposit amount %s is invalid: %s", deposit.Amount, pErr)
+			}
+			if _, tErr := self.interf.InnerTransfer(currency, depositAmount, kucoinMainAccount, kucoinTradeAccount); tErr != nil {
+				return "", fmt.Errorf("KuCoin can't transfer deposit %s to trade account: %s", txHash, tErr)
+			}
+			return common.ExchangeStatusDone, nil
+		case "FAILURE":
+			return common.ExchangeStatusFailed, nil
+		default:
+			return "", nil
+		}
+	}
+	log.Printf("KuCoin Deposit is not found in deposit list returned from KuCoin. This might cause by wrong start/end time, please check again.")
+	return "", nil
+}
+
+func (self *Kucoin) WithdrawStatus(id, currency string, amount float64, timepoint uint64) (string, string, error) {
+	startTime := timepoint - 86400000
+	endTime := timepoint
+	withdrawals, err := self.interf.WithdrawHistory(startTime, endTime)
+	if err != nil {
+		return "", "", err
+	}
+	for _, withdrawal := range withdrawals {
+		if withdrawal.ID != id {
+			continue
+		}
+		switch withdrawal.Status {
+		case "SUCCESS":
+			return common.ExchangeStatusDone, withdrawal.WalletTxID, nil
+		case "FAILURE":
+			return common.ExchangeStatusFailed, withdrawal.WalletTxID, nil
+		default:
+			return "", withdrawal.WalletTxID, nil
+		}
+	}
+	log.Printf("KuCoin Withdrawal doesn't exist. This shouldn't happen unless tx returned from withdrawal from KuCoin and activity ID are not consistently designed")
+	return "", "", nil
+}
+
+func (self *Kucoin) OrderStatus(id string, base, quote string) (string, error) {
+	order, err := self.interf.OrderStatus(id)
+	if err != nil {
+		return "", err
+	}
+	if order.IsActive {
+		return "", nil
+	}
+	return common.ExchangeStatusDone, nil
+}
+
+func NewKucoin(
+	interf KucoinInterface,
+	storage KucoinStorage,
+	setting Setting) (*Kucoin, error) {
+	kucoin := &Kucoin{
+		interf,
+		storage,
+		setting,
+	}
+	kucoin.FetchTradeHistory()
+	return kucoin, nil
+}
@@ -0,0 +1,69 @@
+package kucoin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+func TestKucoinStorage(t *testing.T) {
+	boltFile := "test_kucoin_bolt.db"
+	tmpDir, err := ioutil.TempDir("", "kucoin_storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if rErr := os.RemoveAll(tmpDir); rErr != nil {
+			t.Error(rErr)
+		}
+	}()
+
+	exchangeTradeHistory := common.ExchangeTradeHistory{
+		common.TokenPairID("OMG-ETH"): []common.TradeHistory{
+			{
+				ID:        "12342",
+				Price:     0.132131,
+				Qty:       12.3123,
+				Type:      "buy",
+				Timestamp: 1528949872000,
+			},
+		},
+	}
+
+	storage, err := NewBoltStorage(filepath.Join(tmpDir, boltFile))
+	if err != nil {
+		t.Fatalf("Could not init kucoin bolt storage: %s", err.Error())
+	}
+
+	// store trade history
+	err = storage.StoreTradeHistory(exchangeTradeHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// get trade history
+	var tradeHistory common.ExchangeTradeHistory
+	fromTime := uint64(1528934400000)
+	toTime := uint64(1529020800000)
+	tradeHistory, err = storage.GetTradeHistory(fromTime, toTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tradeHistory, exchangeTradeHistory) {
+		t.Fatal("Get wrong trade history")
+	}
+
+	// get last trade history time
+	var lastHistoryTime uint64
+	lastHistoryTime, err = storage.GetLastTimeTradeHistory("OMG-ETH")
+	if err != nil {
+		t.Fatalf("Get last trade history time error: %s", err.Error())
+	}
+	if lastHistoryTime != 1528949872000 {
+		t.Fatalf("Get last trade history wrong")
+	}
+}
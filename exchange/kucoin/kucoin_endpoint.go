@@ -0,0 +1,418 @@
+package kucoin
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
+	"github.com/KyberNetwork/reserve-data/exchange"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	kucoinSuccessCode = "200000"
+	// kucoinPageSize is the maximum page size of KuCoin list APIs.
+	kucoinPageSize = 500
+	kucoinTimeout  = 30 * time.Second
+)
+
+// KucoinEndpoint object stand for KuCoin endpoint
+// including signer for api call authentication,
+// interf for calling api in different env
+// timedelta to make sure calling api in time
+type KucoinEndpoint struct {
+	signer    Signer
+	interf    Interface
+	timeDelta int64
+	client    *httpclient.Client
+}
+
+func newClient() *httpclient.Client {
+	return httpclient.NewClient("kucoin", httpclient.NoRetryConfig(kucoinTimeout))
+}
+
+// newClientOid returns a unique id of requests which require a client id,
+// eg: orders and inner transfers.
+func newClientOid() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatUint(common.GetTimepoint(), 10)
+	}
+	return hex.EncodeToString(id)
+}
+
+func (self *KucoinEndpoint) fillRequest(req *http.Request, body []byte, signNeeded bool, timepoint uint64) {
+	req.Header.Add("Accept", "application/json")
+	if len(body) > 0 {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	if signNeeded {
+		timestamp := fmt.Sprintf("%d", int64(timepoint)+self.timeDelta)
+		path := req.URL.Path
+		if req.URL.RawQuery != "" {
+			path += "?" + req.URL.RawQuery
+		}
+		req.Header.Set("KC-API-KEY", self.signer.GetKey())
+		req.Header.Set("KC-API-PASSPHRASE", self.signer.GetPassphrase())
+		req.Header.Set("KC-API-TIMESTAMP", timestamp)
+		req.Header.Set("KC-API-SIGN", self.signer.Sign(timestamp+req.Method+path+string(body)))
+	}
+}
+
+// GetResponse calls the KuCoin API and returns the data of the response.
+// Params are sent in query for GET and DELETE requests, as JSON body
+// otherwise.
+func (self *KucoinEndpoint) GetResponse(
+	method string, url string,
+	params map[string]string, signNeeded bool, timepoint uint64) ([]byte, error) {
+	var (
+		err    error
+		body   []byte
+		reader io.Reader
+	)
+	if method == http.MethodPost || method == http.MethodPut {
+		if body, err = json.Marshal(params); err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if reader == nil {
+		q := req.URL.Query()
+		for k, v := range params {
+			q.Add(k, v)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	self.fillRequest(req, body, signNeeded, timepoint)
+
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody := resp.Body
+	switch resp.StatusCode {
+	case 429:
+		return nil, errors.New("breaking kucoin request rate limit")
+	case 401:
+		return nil, errors.New("kucoin api key not valid")
+	}
+	var response exchange.KucoinResponse
+	if err = json.Unmarshal(respBody, &response); err != nil {
+		log.Printf("request to %s, got invalid response from kucoin: %s", req.URL, common.TruncStr(respBody))
+		return nil, fmt.Errorf("KuCoin return with code: %d - %s", resp.StatusCode, err)
+	}
+	if response.Code != kucoinSuccessCode {
+		return nil, fmt.Errorf("KuCoin return with code: %d - %s (%s)", resp.StatusCode, response.Code, response.Msg)
+	}
+	return response.Data, nil
+}
+
+func (self *KucoinEndpoint) GetDepthOnePair(pair common.TokenPair) (exchange.KucoinOrderBook, error) {
+	result := exchange.KucoinOrderBook{}
+	respBody, err := self.GetResponse(
+		http.MethodGet, self.interf.PublicEndpoint()+"/api/v1/market/orderbook/level2_100",
+		map[string]string{
+			"symbol": symbol(pair.Base.ID, pair.Quote.ID),
+		},
+		false,
+		common.GetTimepoint(),
+	)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(respBody, &result)
+	return result, err
+}
+
+func symbol(base, quote string) string {
+	return strings.ToUpper(fmt.Sprintf("%s-%s", base, quote))
+}
+
+// Trade places a LIMIT order which is active until it's explicitly canceled.
+func (self *KucoinEndpoint) Trade(tradeType string, base, quote common.Token, rate, amount float64) (exchange.KucoinTrade, error) {
+	result := exchange.KucoinTrade{}
+	respBody, err := self.GetResponse(
+		http.MethodPost,
+		self.interf.AuthenticatedEndpoint()+"/api/v1/orders",
+		map[string]string{
+			"clientOid": newClientOid(),
+			"side":      strings.ToLower(tradeType),
+			"symbol":    symbol(base.ID, quote.ID),
+			"type":      "limit",
+			"price":     strconv.FormatFloat(rate, 'f', -1, 64),
+			"size":      strconv.FormatFloat(amount, 'f', -1, 64),
+		},
+		true,
+		common.GetTimepoint(),
+	)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(respBody, &result)
+	return result, err
+}
+
+// getPages calls a paginated list API until the last page, page decodes the
+// data of a page and returns the total pages.
+func (self *KucoinEndpoint) getPages(url string, params map[string]string, page func(data []byte) (int, error)) error {
+	params["pageSize"] = strconv.Itoa(kucoinPageSize)
+	for current := 1; ; current++ {
+		params["currentPage"] = strconv.Itoa(current)
+		respBody, err := self.GetResponse(http.MethodGet, url, params, true, common.GetTimepoint())
+		if err != nil {
+			return err
+		}
+		totalPage, err := page(respBody)
+		if err != nil {
+			return err
+		}
+		if current >= totalPage {
+			return nil
+		}
+	}
+}
+
+func (self *KucoinEndpoint) GetAccountTradeHistory(
+	base, quote common.Token,
+	startTime uint64) ([]exchange.KucoinFill, error) {
+	var result []exchange.KucoinFill
+	params := map[string]string{
+		"symbol": symbol(base.ID, quote.ID),
+	}
+	if startTime != 0 {
+		params["startAt"] = strconv.FormatUint(startTime, 10)
+	}
+	err := self.getPages(self.interf.AuthenticatedEndpoint()+"/api/v1/fills", params, func(data []byte) (int, error) {
+		fills := exchange.KucoinFills{}
+		if err := json.Unmarshal(data, &fills); err != nil {
+			return 0, err
+		}
+		result = append(result, fills.Items...)
+		return fills.TotalPage, nil
+	})
+	return result, err
+}
+
+func (self *KucoinEndpoint) WithdrawHistory(startTime, endTime uint64) ([]exchange.KucoinWithdrawal, error) {
+	var result []exchange.KucoinWithdrawal
+	params := map[string]string{
+		"startAt": strconv.FormatUint(startTime, 10),
+		"endAt":   strconv.FormatUint(endTime, 10),
+	}
+	err := self.getPages(self.interf.AuthenticatedEndpoint()+"/api/v1/withdrawals", params, func(data []byte) (int, error) {
+		withdrawals := exchange.KucoinWithdrawals{}
+		if err := json.Unmarshal(data, &withdrawals); err != nil {
+			return 0, err
+		}
+		result = append(result, withdrawals.Items...)
+		return withdrawals.TotalPage, nil
+	})
+	return result, err
+}
+
+func (self *KucoinEndpoint) DepositHistory(startTime, endTime uint64) ([]exchange.KucoinDeposit, error) {
+	var result []exchange.KucoinDeposit
+	params := map[string]string{
+		"startAt": strconv.FormatUint(startTime, 10),
+		"endAt":   strconv.FormatUint(endTime, 10),
+	}
+	err := self.getPages(self.interf.AuthenticatedEndpoint()+"/api/v1/deposits", params, func(data []byte) (int, error) {
+		deposits := exchange.KucoinDeposits{}
+		if err := json.Unmarshal(data, &deposits); err != nil {
+			return 0, err
+		}
+		result = append(result, deposits.Items...)
+		return deposits.TotalPage, nil
+	})
+	return result, err
+}
+
+func (self *KucoinEndpoint) CancelOrder(id string) (exchange.KucoinCancel, error) {
+	result := exchange.KucoinCancel{}
+	respBody, err := self.GetResponse(
+		http.MethodDelete,
+		self.interf.AuthenticatedEndpoint()+"/api/v1/orders/"+url.PathEscape(id),
+		map[string]string{},
+		true,
+		common.GetTimepoint(),
+	)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(respBody, &result)
+	return result, err
+}
+
+func (self *KucoinEndpoint) OrderStatus(id string) (exchange.KucoinOrder, error) {
+	result := exchange.KucoinOrder{}
+	respBody, err := self.GetResponse(
+		http.MethodGet,
+		self.interf.AuthenticatedEndpoint()+"/api/v1/orders/"+url.PathEscape(id),
+		map[string]string{},
+		true,
+		common.GetTimepoint(),
+	)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(respBody, &result)
+	return result, err
+}
+
+func (self *KucoinEndpoint) Withdraw(token common.Token, amount *big.Int, address ethereum.Address) (string, error) {
+	result := exchange.KucoinWithdraw{}
+	respBody, err := self.GetResponse(
+		http.MethodPost,
+		self.interf.AuthenticatedEndpoint()+"/api/v1/withdrawals",
+		map[string]string{
+			"currency": token.ID,
+			"address":  address.Hex(),
+			"amount":   strconv.FormatFloat(common.BigToFloat(amount, token.Decimals), 'f', -1, 64),
+		},
+		true,
+		common.GetTimepoint(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("withdraw rejected by KuCoin: %s", err.Error())
+	}
+	if err = json.Unmarshal(respBody, &result); err != nil {
+		return "", err
+	}
+	return result.WithdrawalID, nil
+}
+
+func (self *KucoinEndpoint) InnerTransfer(currency string, amount float64, from, to string) (exchange.KucoinInnerTransfer, error) {
+	result := exchange.KucoinInnerTransfer{}
+	respBody, err := self.GetResponse(
+		http.MethodPost,
+		self.interf.AuthenticatedEndpoint()+"/api/v2/accounts/inner-transfer",
+		map[string]string{
+			"clientOid": newClientOid(),
+			"currency":  currency,
+			"from":      from,
+			"to":        to,
+			"amount":    strconv.FormatFloat(amount, 'f', -1, 64),
+		},
+		true,
+		common.GetTimepoint(),
+	)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(respBody, &result)
+	return result, err
+}
+
+func (self *KucoinEndpoint) GetAccounts() (exchange.KucoinAccounts, error) {
+	result := exchange.KucoinAccounts{}
+	respBody, err := self.GetResponse(
+		http.MethodGet,
+		self.interf.AuthenticatedEndpoint()+"/api/v1/accounts",
+		map[string]string{},
+		true,
+		common.GetTimepoint(),
+	)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(respBody, &result)
+	return result, err
+}
+
+func (self *KucoinEndpoint) GetDepositAddress(currency string) (exchange.KucoinDepositAddress, error) {
+	result := exchange.KucoinDepositAddress{}
+	respBody, err := self.GetResponse(
+		http.MethodGet,
+		self.interf.AuthenticatedEndpoint()+"/api/v1/deposit-addresses",
+		map[string]string{
+			"currency": currency,
+		},
+		true,
+		common.GetTimepoint(),
+	)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(respBody, &result)
+	return result, err
+}
+
+func (self *KucoinEndpoint) GetSymbols() (exchange.KucoinSymbols, error) {
+	result := exchange.KucoinSymbols{}
+	respBody, err := self.GetResponse(
+		http.MethodGet,
+		self.interf.PublicEndpoint()+"/api/v1/symbols",
+		map[string]string{},
+		false,
+		common.GetTimepoint(),
+	)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(respBody, &result)
+	return result, err
+}
+
+func (self *KucoinEndpoint) getServerTime() (uint64, error) {
+	var result uint64
+	respBody, err := self.GetResponse(
+		http.MethodGet,
+		self.interf.PublicEndpoint()+"/api/v1/timestamp",
+		map[string]string{},
+		false,
+		common.GetTimepoint(),
+	)
+	if err == nil {
+		err = json.Unmarshal(respBody, &result)
+	}
+	return result, err
+}
+
+func (self *KucoinEndpoint) UpdateTimeDelta() error {
+	currentTime := common.GetTimepoint()
+	serverTime, err := self.getServerTime()
+	responseTime := common.GetTimepoint()
+	if err != nil {
+		return err
+	}
+	log.Printf("KuCoin current time: %d", currentTime)
+	log.Printf("KuCoin server time: %d", serverTime)
+	log.Printf("KuCoin response time: %d", responseTime)
+	roundtripTime := (int64(responseTime) - int64(currentTime)) / 2
+	self.timeDelta = int64(serverTime) - int64(currentTime) - roundtripTime
+
+	log.Printf("Time delta: %d", self.timeDelta)
+	return nil
+}
+
+//NewKucoinEndpoint return new endpoint instance for using kucoin
+func NewKucoinEndpoint(signer Signer, interf Interface) *KucoinEndpoint {
+	endpoint := &KucoinEndpoint{signer, interf, 0, newClient()}
+	switch interf.(type) {
+	case *SimulatedInterface:
+		log.Println("Simulate environment, no updateTime called...")
+	default:
+		err := endpoint.UpdateTimeDelta()
+		if err != nil {
+			panic(err)
+		}
+	}
+	return endpoint
+}
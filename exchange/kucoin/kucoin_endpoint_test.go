@@ -0,0 +1,160 @@
+package kucoin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+type testInterface struct {
+	url string
+}
+
+func (self testInterface) PublicEndpoint() string {
+	return self.url
+}
+
+func (self testInterface) AuthenticatedEndpoint() string {
+	return self.url
+}
+
+// newMockServer returns a KuCoin mock server which checks the signature of
+// authenticated requests and responds with the data of responses by method
+// and path, the data of a paginated response is selected by its page.
+func newMockServer(t *testing.T, signer Signer, responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if sign := r.Header.Get("KC-API-SIGN"); sign != "" {
+			msg := r.Header.Get("KC-API-TIMESTAMP") + r.Method + r.URL.RequestURI() + string(body)
+			if expected := signer.Sign(msg); sign != expected {
+				t.Errorf("Request %s signature is %s, expected %s", r.URL.Path, sign, expected)
+			}
+			if r.Header.Get("KC-API-KEY") != signer.Key || r.Header.Get("KC-API-PASSPHRASE") != signer.Passphrase {
+				t.Errorf("Request %s has wrong key or passphrase", r.URL.Path)
+			}
+		}
+		key := r.Method + " " + r.URL.Path
+		if page := r.URL.Query().Get("currentPage"); page != "" {
+			key += "#" + page
+		}
+		data, ok := responses[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"code":"404000","msg":"Url Not Found"}`)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"code":"200000","data":%s}`, data)
+	}))
+}
+
+func newTestEndpoint(t *testing.T, responses map[string]string) (*KucoinEndpoint, func()) {
+	signer := NewSigner("key", "secret", "passphrase")
+	server := newMockServer(t, *signer, responses)
+	return &KucoinEndpoint{*signer, testInterface{server.URL}, 0, newClient()}, server.Close
+}
+
+func TestGetDepthOnePair(t *testing.T) {
+	endpoint, closeServer := newTestEndpoint(t, map[string]string{
+		"GET /api/v1/market/orderbook/level2_100": `{"sequence":"3262786978","bids":[["0.0021","15.5"]],"asks":[["0.0022","7"],["0.0023","1"]]}`,
+	})
+	defer closeServer()
+	pair := common.TokenPair{Base: common.Token{ID: "KNC"}, Quote: common.Token{ID: "ETH"}}
+	book, err := endpoint.GetDepthOnePair(pair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.Bids) != 1 || book.Bids[0].Rate != "0.0021" || book.Bids[0].Quantity != "15.5" {
+		t.Fatalf("Unexpected bids: %+v", book.Bids)
+	}
+	if len(book.Asks) != 2 {
+		t.Fatalf("Expected 2 asks, got %d", len(book.Asks))
+	}
+}
+
+func TestTradeAndWithdrawSigned(t *testing.T) {
+	endpoint, closeServer := newTestEndpoint(t, map[string]string{
+		"POST /api/v1/orders":                         `{"orderId":"5bd6e9286d99522a52e458de"}`,
+		"POST /api/v1/withdrawals":                    `{"withdrawalId":"5bffb63303aa675e8bbe18f9"}`,
+		"GET /api/v1/orders/5bd6e9286d99522a52e458de": `{"id":"5bd6e9286d99522a52e458de","symbol":"KNC-ETH","side":"buy","size":"10","dealSize":"4","isActive":true}`,
+	})
+	defer closeServer()
+	knc := common.Token{ID: "KNC", Decimals: 18}
+	trade, err := endpoint.Trade("buy", knc, common.Token{ID: "ETH"}, 0.0021, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trade.OrderID != "5bd6e9286d99522a52e458de" {
+		t.Fatalf("Unexpected order ID %s", trade.OrderID)
+	}
+	order, err := endpoint.OrderStatus(trade.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !order.IsActive || order.DealSize != "4" {
+		t.Fatalf("Unexpected order %+v", order)
+	}
+	amount := big.NewInt(0).Mul(big.NewInt(5), big.NewInt(1000000000000000000))
+	id, err := endpoint.Withdraw(knc, amount, ethereum.HexToAddress("0x63825c174ab367968ec60f061753d3bbd36a0d8f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "5bffb63303aa675e8bbe18f9" {
+		t.Fatalf("Unexpected withdrawal ID %s", id)
+	}
+}
+
+func TestDepositHistoryPages(t *testing.T) {
+	endpoint, closeServer := newTestEndpoint(t, map[string]string{
+		"GET /api/v1/deposits#1": `{"currentPage":1,"pageSize":500,"totalNum":2,"totalPage":2,"items":[{"currency":"KNC","amount":"1","walletTxId":"0xaa@0","status":"SUCCESS"}]}`,
+		"GET /api/v1/deposits#2": `{"currentPage":2,"pageSize":500,"totalNum":2,"totalPage":2,"items":[{"currency":"OMG","amount":"2","walletTxId":"0xbb@0","status":"PROCESSING"}]}`,
+	})
+	defer closeServer()
+	deposits, err := endpoint.DepositHistory(0, common.GetTimepoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deposits) != 2 || deposits[0].Currency != "KNC" || deposits[1].Status != "PROCESSING" {
+		t.Fatalf("Unexpected deposits: %+v", deposits)
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	endpoint, closeServer := newTestEndpoint(t, map[string]string{})
+	defer closeServer()
+	_, err := endpoint.GetAccounts()
+	if err == nil || !strings.Contains(err.Error(), "Url Not Found") {
+		t.Fatalf("Expected error of the response, got %v", err)
+	}
+}
+
+func TestInnerTransferBody(t *testing.T) {
+	var body map[string]string
+	signer := NewSigner("key", "secret", "passphrase")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"code":"200000","data":{"orderId":"5bd6e9286d99522a52e458de"}}`)
+	}))
+	defer server.Close()
+	endpoint := &KucoinEndpoint{*signer, testInterface{server.URL}, 0, newClient()}
+	if _, err := endpoint.InnerTransfer("KNC", 1.5, "main", "trade"); err != nil {
+		t.Fatal(err)
+	}
+	if body["currency"] != "KNC" || body["amount"] != "1.5" || body["from"] != "main" || body["to"] != "trade" || body["clientOid"] == "" {
+		t.Fatalf("Unexpected inner transfer body: %v", body)
+	}
+}
@@ -0,0 +1,51 @@
+package kucoin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+)
+
+type Signer struct {
+	Key        string `json:"kucoin_key"`
+	Secret     string `json:"kucoin_secret"`
+	Passphrase string `json:"kucoin_passphrase"`
+}
+
+func (self Signer) GetKey() string {
+	return self.Key
+}
+
+func (self Signer) GetPassphrase() string {
+	return self.Passphrase
+}
+
+// Sign returns the base64 encoded HMAC-SHA256 of msg, KuCoin signs the
+// concatenation of timestamp, method, request path with query and body.
+func (self Signer) Sign(msg string) string {
+	mac := hmac.New(sha256.New, []byte(self.Secret))
+	if _, err := mac.Write([]byte(msg)); err != nil {
+		log.Printf("Encode message error: %s", err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func NewSigner(key, secret, passphrase string) *Signer {
+	return &Signer{key, secret, passphrase}
+}
+
+func NewSignerFromFile(path string) Signer {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	signer := Signer{}
+	err = json.Unmarshal(raw, &signer)
+	if err != nil {
+		panic(err)
+	}
+	return signer
+}
@@ -0,0 +1,174 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// KucoinResponse is the envelope of all KuCoin responses, Code is
+// "200000" for successful requests.
+type KucoinResponse struct {
+	Code string          `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+type KucoinPrice struct {
+	Quantity string
+	Rate     string
+}
+
+func (self *KucoinPrice) UnmarshalJSON(text []byte) error {
+	temp := []string{}
+	if err := json.Unmarshal(text, &temp); err != nil {
+		return err
+	}
+	if len(temp) < 2 {
+		return fmt.Errorf("Unmarshal err: price entry %s is malformed", string(text))
+	}
+	self.Rate = temp[0]
+	self.Quantity = temp[1]
+	return nil
+}
+
+type KucoinOrderBook struct {
+	Sequence string        `json:"sequence"`
+	Bids     []KucoinPrice `json:"bids"`
+	Asks     []KucoinPrice `json:"asks"`
+}
+
+// KucoinAccount is the balance of a currency in an account of type main
+// (deposit and withdraw) or trade.
+type KucoinAccount struct {
+	ID        string `json:"id"`
+	Currency  string `json:"currency"`
+	Type      string `json:"type"`
+	Balance   string `json:"balance"`
+	Available string `json:"available"`
+	Holds     string `json:"holds"`
+}
+
+type KucoinAccounts []KucoinAccount
+
+type KucoinSymbol struct {
+	Symbol         string `json:"symbol"`
+	BaseCurrency   string `json:"baseCurrency"`
+	QuoteCurrency  string `json:"quoteCurrency"`
+	BaseMinSize    string `json:"baseMinSize"`
+	BaseMaxSize    string `json:"baseMaxSize"`
+	QuoteMinSize   string `json:"quoteMinSize"`
+	BaseIncrement  string `json:"baseIncrement"`
+	PriceIncrement string `json:"priceIncrement"`
+	EnableTrading  bool   `json:"enableTrading"`
+}
+
+type KucoinSymbols []KucoinSymbol
+
+type KucoinDepositAddress struct {
+	Address string `json:"address"`
+	Memo    string `json:"memo"`
+}
+
+type KucoinTrade struct {
+	OrderID string `json:"orderId"`
+}
+
+type KucoinOrder struct {
+	ID          string `json:"id"`
+	Symbol      string `json:"symbol"`
+	Side        string `json:"side"`
+	Price       string `json:"price"`
+	Size        string `json:"size"`
+	DealSize    string `json:"dealSize"`
+	IsActive    bool   `json:"isActive"`
+	CancelExist bool   `json:"cancelExist"`
+	CreatedAt   uint64 `json:"createdAt"`
+}
+
+type KucoinCancel struct {
+	CancelledOrderIDs []string `json:"cancelledOrderIds"`
+}
+
+type KucoinWithdraw struct {
+	WithdrawalID string `json:"withdrawalId"`
+}
+
+type KucoinInnerTransfer struct {
+	OrderID string `json:"orderId"`
+}
+
+// KucoinPage is the pagination of KuCoin list responses.
+type KucoinPage struct {
+	CurrentPage int `json:"currentPage"`
+	PageSize    int `json:"pageSize"`
+	TotalNum    int `json:"totalNum"`
+	TotalPage   int `json:"totalPage"`
+}
+
+// {
+// 	"currentPage": 1,
+// 	"pageSize": 5,
+// 	"totalNum": 2,
+// 	"totalPage": 1,
+// 	"items": [
+// 		{
+// 			"address": "0x5f047b29041bcfdbf0e4478cdfa753a336ba6989",
+// 			"memo": "",
+// 			"amount": "1",
+// 			"fee": "0.0001",
+// 			"currency": "KNC",
+// 			"isInner": false,
+// 			"walletTxId": "0x5bedb060ad7e35f5f1d20d7eb2c8a9e2b2ec1e25b7b0b5f4d0e4c3f8b4f0d3c1",
+// 			"status": "SUCCESS",
+// 			"createdAt": 1544178843000
+// 		}
+// 	]
+// }
+type KucoinDeposits struct {
+	KucoinPage
+	Items []KucoinDeposit `json:"items"`
+}
+
+type KucoinDeposit struct {
+	Address    string `json:"address"`
+	Amount     string `json:"amount"`
+	Fee        string `json:"fee"`
+	Currency   string `json:"currency"`
+	IsInner    bool   `json:"isInner"`
+	WalletTxID string `json:"walletTxId"`
+	Status     string `json:"status"`
+	CreatedAt  uint64 `json:"createdAt"`
+}
+
+type KucoinWithdrawals struct {
+	KucoinPage
+	Items []KucoinWithdrawal `json:"items"`
+}
+
+type KucoinWithdrawal struct {
+	ID         string `json:"id"`
+	Address    string `json:"address"`
+	Amount     string `json:"amount"`
+	Fee        string `json:"fee"`
+	Currency   string `json:"currency"`
+	IsInner    bool   `json:"isInner"`
+	WalletTxID string `json:"walletTxId"`
+	Status     string `json:"status"`
+	CreatedAt  uint64 `json:"createdAt"`
+}
+
+type KucoinFills struct {
+	KucoinPage
+	Items []KucoinFill `json:"items"`
+}
+
+type KucoinFill struct {
+	Symbol    string `json:"symbol"`
+	TradeID   string `json:"tradeId"`
+	OrderID   string `json:"orderId"`
+	Side      string `json:"side"`
+	Price     string `json:"price"`
+	Size      string `json:"size"`
+	Fee       string `json:"fee"`
+	CreatedAt uint64 `json:"createdAt"`
+}
@@ -0,0 +1,45 @@
+package exchange
+
+import (
+	"math/big"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// KucoinInterface contains the methods to interact with KuCoin centralized exchange.
+type KucoinInterface interface {
+	GetDepthOnePair(pair common.TokenPair) (KucoinOrderBook, error)
+
+	GetAccounts() (KucoinAccounts, error)
+
+	GetSymbols() (KucoinSymbols, error)
+
+	GetDepositAddress(currency string) (KucoinDepositAddress, error)
+
+	// GetAccountTradeHistory returns the fills of the pair from startTime
+	// (millisecond), the recent fills if startTime is 0.
+	GetAccountTradeHistory(base, quote common.Token, startTime uint64) ([]KucoinFill, error)
+
+	Withdraw(
+		token common.Token,
+		amount *big.Int,
+		address ethereum.Address) (string, error)
+
+	// InnerTransfer moves amount of currency between the main and trade
+	// accounts, deposits and withdrawals use the main account.
+	InnerTransfer(currency string, amount float64, from, to string) (KucoinInnerTransfer, error)
+
+	Trade(
+		tradeType string,
+		base, quote common.Token,
+		rate, amount float64) (KucoinTrade, error)
+
+	CancelOrder(id string) (KucoinCancel, error)
+
+	DepositHistory(startTime, endTime uint64) ([]KucoinDeposit, error)
+
+	WithdrawHistory(startTime, endTime uint64) ([]KucoinWithdrawal, error)
+
+	OrderStatus(id string) (KucoinOrder, error)
+}
@@ -0,0 +1,13 @@
+package exchange
+
+import "github.com/KyberNetwork/reserve-data/common"
+
+// KucoinStorage is the interface that wraps all database operation of KuCoin exchange.
+type KucoinStorage interface {
+	StoreTradeHistory(data common.ExchangeTradeHistory) error
+
+	GetTradeHistory(fromTime, toTime uint64) (common.ExchangeTradeHistory, error)
+	// GetLastTimeTradeHistory returns the time of the last stored trade of
+	// pair, it is the cursor of KuCoin fills which are not ordered by ID.
+	GetLastTimeTradeHistory(pair string) (uint64, error)
+}
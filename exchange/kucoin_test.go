@@ -0,0 +1,128 @@
+package exchange
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+type innerTransfer struct {
+	currency string
+	amount   float64
+	from, to string
+}
+
+type testKucoinInterface struct {
+	deposits    []KucoinDeposit
+	withdrawals []KucoinWithdrawal
+	transferErr error
+	transfers   []innerTransfer
+}
+
+func (self *testKucoinInterface) GetDepthOnePair(pair common.TokenPair) (KucoinOrderBook, error) {
+	return KucoinOrderBook{}, nil
+}
+
+func (self *testKucoinInterface) GetAccounts() (KucoinAccounts, error) {
+	return KucoinAccounts{}, nil
+}
+
+func (self *testKucoinInterface) GetSymbols() (KucoinSymbols, error) {
+	return KucoinSymbols{}, nil
+}
+
+func (self *testKucoinInterface) GetDepositAddress(currency string) (KucoinDepositAddress, error) {
+	return KucoinDepositAddress{}, nil
+}
+
+func (self *testKucoinInterface) GetAccountTradeHistory(base, quote common.Token, startTime uint64) ([]KucoinFill, error) {
+	return nil, nil
+}
+
+func (self *testKucoinInterface) Withdraw(token common.Token, amount *big.Int, address ethereum.Address) (string, error) {
+	return "withdrawal", nil
+}
+
+func (self *testKucoinInterface) InnerTransfer(currency string, amount float64, from, to string) (KucoinInnerTransfer, error) {
+	if self.transferErr != nil {
+		return KucoinInnerTransfer{}, self.transferErr
+	}
+	self.transfers = append(self.transfers, innerTransfer{currency, amount, from, to})
+	return KucoinInnerTransfer{OrderID: "transfer"}, nil
+}
+
+func (self *testKucoinInterface) Trade(tradeType string, base, quote common.Token, rate, amount float64) (KucoinTrade, error) {
+	return KucoinTrade{}, nil
+}
+
+func (self *testKucoinInterface) CancelOrder(id string) (KucoinCancel, error) {
+	return KucoinCancel{}, nil
+}
+
+func (self *testKucoinInterface) DepositHistory(startTime, endTime uint64) ([]KucoinDeposit, error) {
+	return self.deposits, nil
+}
+
+func (self *testKucoinInterface) WithdrawHistory(startTime, endTime uint64) ([]KucoinWithdrawal, error) {
+	return self.withdrawals, nil
+}
+
+func (self *testKucoinInterface) OrderStatus(id string) (KucoinOrder, error) {
+	return KucoinOrder{}, nil
+}
+
+func TestKucoinDepositStatus(t *testing.T) {
+	txHash := "0x15ccaab008f161efeee0febc3e32242846cea1fc93995e5abc6fb88d94ae7d21"
+	activityID := common.NewActivityID(1513328774800747341, txHash+"|KNC|5")
+	interf := &testKucoinInterface{
+		deposits: []KucoinDeposit{
+			{Currency: "KNC", Amount: "4.9", WalletTxID: "0x15CCAAB008F161EFEEE0FEBC3E32242846CEA1FC93995E5ABC6FB88D94AE7D21@0", Status: "PROCESSING"},
+		},
+	}
+	kucoin := &Kucoin{interf: interf}
+	out, err := kucoin.DepositStatus(activityID, txHash, "KNC", 5, common.GetTimepoint())
+	if err != nil || out != "" {
+		t.Fatalf("Expected pending deposit, got %q, %v", out, err)
+	}
+
+	interf.deposits[0].Status = "SUCCESS"
+	interf.transferErr = errors.New("transfer failed")
+	if out, err = kucoin.DepositStatus(activityID, txHash, "KNC", 5, common.GetTimepoint()); err == nil || out != "" {
+		t.Fatalf("Expected error for failed transfer, got %q, %v", out, err)
+	}
+
+	interf.transferErr = nil
+	out, err = kucoin.DepositStatus(activityID, txHash, "KNC", 5, common.GetTimepoint())
+	if err != nil || out != common.ExchangeStatusDone {
+		t.Fatalf("Expected done deposit, got %q, %v", out, err)
+	}
+	expected := innerTransfer{"KNC", 4.9, kucoinMainAccount, kucoinTradeAccount}
+	if len(interf.transfers) != 1 || interf.transfers[0] != expected {
+		t.Fatalf("Expected transfer %+v, got %+v", expected, interf.transfers)
+	}
+}
+
+func TestKucoinWithdraw(t *testing.T) {
+	interf := &testKucoinInterface{
+		withdrawals: []KucoinWithdrawal{
+			{ID: "withdrawal", WalletTxID: "0xabc", Status: "SUCCESS"},
+		},
+	}
+	kucoin := &Kucoin{interf: interf}
+	amount := big.NewInt(0).Mul(big.NewInt(3), big.NewInt(1000000000000000000))
+	id, err := kucoin.Withdraw(common.Token{ID: "KNC", Decimals: 18}, amount, ethereum.Address{}, common.GetTimepoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := innerTransfer{"KNC", 3, kucoinTradeAccount, kucoinMainAccount}
+	if len(interf.transfers) != 1 || interf.transfers[0] != expected {
+		t.Fatalf("Expected transfer %+v, got %+v", expected, interf.transfers)
+	}
+	status, tx, err := kucoin.WithdrawStatus(id, "KNC", 3, common.GetTimepoint())
+	if err != nil || status != common.ExchangeStatusDone || tx != "0xabc" {
+		t.Fatalf("Expected done withdrawal with tx 0xabc, got %q, %q, %v", status, tx, err)
+	}
+}
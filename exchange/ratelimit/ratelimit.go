@@ -0,0 +1,187 @@
+// Package ratelimit throttles the requests of an exchange API key, which are
+// shared by the fetchers and core, to the request weight limits published by
+// the exchange.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// minWaitDelay is the shortest time a request waits before trying to take
+// tokens again.
+const minWaitDelay = 10 * time.Millisecond
+
+// Priority is the priority of a request.
+type Priority int
+
+const (
+	// DataPriority is the priority of requests fetching data.
+	DataPriority Priority = iota
+	// TradePriority is the priority of trade and cancel requests, they are
+	// served before waiting data requests and can use the reserved weight.
+	TradePriority
+)
+
+// Usage is the current usage of a limiter.
+type Usage struct {
+	Name     string  `json:"name"`
+	Capacity float64 `json:"capacity"`
+	// Interval is the time in seconds to refill the whole capacity.
+	Interval float64 `json:"interval"`
+	Reserved float64 `json:"reserved"`
+	Used     float64 `json:"used"`
+	// WaitingTrade and WaitingData are the numbers of requests waiting for
+	// weight.
+	WaitingTrade int `json:"waiting_trade"`
+	WaitingData  int `json:"waiting_data"`
+	// BlockedUntil is the time in millisecond until requests are blocked
+	// after the exchange responded with rate limit errors, 0 if not blocked.
+	BlockedUntil uint64 `json:"blocked_until"`
+	// Throttled is the number of rate limit errors responded by the exchange.
+	Throttled uint64 `json:"throttled"`
+}
+
+// Limiter is a token bucket of request weights, its capacity is refilled in
+// each interval. The reserved part of the capacity is only used by trade
+// requests so data fetchers can't delay trading.
+type Limiter struct {
+	name     string
+	capacity float64
+	interval time.Duration
+	reserved float64
+	// rate is the weight refilled per second
+	rate float64
+
+	mu           sync.Mutex
+	tokens       float64
+	updated      time.Time
+	blockedUntil time.Time
+	waiting      map[Priority]int
+	throttled    uint64
+}
+
+// NewLimiter returns a limiter of exchange name which allows capacity weight
+// per interval, reserved weight of it is only used by trade requests.
+func NewLimiter(name string, capacity float64, interval time.Duration, reserved float64) *Limiter {
+	return &Limiter{
+		name:     name,
+		capacity: capacity,
+		interval: interval,
+		reserved: reserved,
+		rate:     capacity / interval.Seconds(),
+		tokens:   capacity,
+		updated:  time.Now(),
+		waiting:  map[Priority]int{},
+	}
+}
+
+func (self *Limiter) refill(now time.Time) {
+	self.tokens = math.Min(self.capacity, self.tokens+now.Sub(self.updated).Seconds()*self.rate)
+	self.updated = now
+}
+
+// Wait blocks until weight is available for a request of priority and takes
+// it. It returns error without waiting if the requests are blocked by the
+// exchange, sending requests while blocked extends the block.
+func (self *Limiter) Wait(priority Priority, weight float64) error {
+	self.mu.Lock()
+	self.waiting[priority]++
+	defer func() {
+		self.waiting[priority]--
+		self.mu.Unlock()
+	}()
+	limit := self.capacity
+	if priority == DataPriority {
+		limit -= self.reserved
+	}
+	weight = math.Min(weight, limit)
+	for {
+		now := time.Now()
+		if now.Before(self.blockedUntil) {
+			return fmt.Errorf("Requests to %s are blocked until %s for exceeding rate limit", self.name, self.blockedUntil.Format(time.RFC3339))
+		}
+		self.refill(now)
+		available := self.tokens
+		if priority == DataPriority {
+			available -= self.reserved
+		}
+		if available >= weight && (priority == TradePriority || self.waiting[TradePriority] == 0) {
+			self.tokens -= weight
+			return nil
+		}
+		delay := time.Duration((weight - available) / self.rate * float64(time.Second))
+		if delay < minWaitDelay {
+			delay = minWaitDelay
+		}
+		self.mu.Unlock()
+		time.Sleep(delay)
+		self.mu.Lock()
+	}
+}
+
+// SetUsed updates the used weight from the usage reported by the exchange,
+// it counts the requests of other clients of the API key.
+func (self *Limiter) SetUsed(used float64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.refill(time.Now())
+	self.tokens = math.Min(self.tokens, self.capacity-used)
+}
+
+// Backoff blocks the requests for d after the exchange responded with a rate
+// limit error.
+func (self *Limiter) Backoff(d time.Duration) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.throttled++
+	self.tokens = 0
+	self.updated = time.Now()
+	if until := self.updated.Add(d); until.After(self.blockedUntil) {
+		self.blockedUntil = until
+	}
+}
+
+// Usage returns the current usage of the limiter.
+func (self *Limiter) Usage() Usage {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	now := time.Now()
+	self.refill(now)
+	usage := Usage{
+		Name:         self.name,
+		Capacity:     self.capacity,
+		Interval:     self.interval.Seconds(),
+		Reserved:     self.reserved,
+		Used:         self.capacity - self.tokens,
+		WaitingTrade: self.waiting[TradePriority],
+		WaitingData:  self.waiting[DataPriority],
+		Throttled:    self.throttled,
+	}
+	if now.Before(self.blockedUntil) {
+		usage.BlockedUntil = common.TimeToTimepoint(self.blockedUntil)
+	}
+	return usage
+}
+
+// RetryAfter returns the time to wait from the Retry-After header of a rate
+// limit response, in seconds or HTTP date, or fallback if it is missing.
+func RetryAfter(header http.Header, fallback time.Duration) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return fallback
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return fallback
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiterWait(t *testing.T) {
+	// 10 weight per 100 milliseconds, 2 of them reserved
+	limiter := NewLimiter("test", 10, 100*time.Millisecond, 2)
+	start := time.Now()
+	if err := limiter.Wait(DataPriority, 8); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Millisecond {
+		t.Fatalf("Expected no wait for available weight, waited %s", elapsed)
+	}
+	// the reserved weight is only available to trade requests
+	if err := limiter.Wait(TradePriority, 2); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Millisecond {
+		t.Fatalf("Expected no wait for reserved weight, waited %s", elapsed)
+	}
+	if usage := limiter.Usage(); usage.Used < 9.5 {
+		t.Fatalf("Expected all weight used, got %+v", usage)
+	}
+	if err := limiter.Wait(DataPriority, 5); err != nil {
+		t.Fatal(err)
+	}
+	// 7 weight for the data request to be refilled in 70 milliseconds
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Fatalf("Expected waiting for weight refilled, waited %s", elapsed)
+	}
+}
+
+func TestLimiterTradePriority(t *testing.T) {
+	limiter := NewLimiter("test", 10, 200*time.Millisecond, 0)
+	if err := limiter.Wait(DataPriority, 10); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan Priority, 2)
+	go func() {
+		if err := limiter.Wait(DataPriority, 5); err != nil {
+			t.Error(err)
+		}
+		done <- DataPriority
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		if err := limiter.Wait(TradePriority, 5); err != nil {
+			t.Error(err)
+		}
+		done <- TradePriority
+	}()
+	if first := <-done; first != TradePriority {
+		t.Fatal("Expected trade request served before waiting data request")
+	}
+	<-done
+}
+
+func TestLimiterBackoff(t *testing.T) {
+	limiter := NewLimiter("test", 10, time.Second, 0)
+	limiter.Backoff(100 * time.Millisecond)
+	err := limiter.Wait(TradePriority, 1)
+	if err == nil || !strings.Contains(err.Error(), "blocked") {
+		t.Fatalf("Expected blocked error, got %v", err)
+	}
+	usage := limiter.Usage()
+	if usage.BlockedUntil == 0 || usage.Throttled != 1 {
+		t.Fatalf("Expected blocked usage, got %+v", usage)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err = limiter.Wait(TradePriority, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := http.Header{}
+	if d := RetryAfter(header, time.Minute); d != time.Minute {
+		t.Fatalf("Expected fallback, got %s", d)
+	}
+	header.Set("Retry-After", "120")
+	if d := RetryAfter(header, time.Minute); d != 2*time.Minute {
+		t.Fatalf("Expected 2 minutes, got %s", d)
+	}
+	header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if d := RetryAfter(header, time.Minute); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("Expected about 1 hour, got %s", d)
+	}
+}
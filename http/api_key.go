@@ -0,0 +1,318 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+// keyIDContextKey is the key of authenticated API key ID in gin context.
+const keyIDContextKey = "key_id"
+
+var (
+	// errNoAPIKeyStorage is returned when updating API keys without a storage
+	// to persist them.
+	errNoAPIKeyStorage = errors.New("API key storage is not configured")
+)
+
+// APIKeyStorage is the interface of the storage of API keys managed via
+// admin endpoints.
+type APIKeyStorage interface {
+	StoreAPIKey(key common.APIKey) error
+	GetAPIKeys() ([]common.APIKey, error)
+	RemoveAPIKey(keyID string) error
+}
+
+// keyRegistry holds the named API keys. Keys loaded from config file are read
+// only, other keys are managed via admin endpoints and persisted to storage.
+type keyRegistry struct {
+	mu      sync.RWMutex
+	static  map[string]common.APIKey
+	managed map[string]common.APIKey
+	storage APIKeyStorage
+}
+
+func newKeyRegistry(keys []common.APIKey) (*keyRegistry, error) {
+	result := &keyRegistry{
+		static:  make(map[string]common.APIKey),
+		managed: make(map[string]common.APIKey),
+	}
+	for _, key := range keys {
+		if err := validateAPIKey(key); err != nil {
+			return nil, err
+		}
+		if _, exist := result.static[key.ID]; exist {
+			return nil, fmt.Errorf("duplicated API key %s", key.ID)
+		}
+		result.static[key.ID] = key
+	}
+	return result, nil
+}
+
+// setStorage loads managed keys from storage and persists later changes to it.
+func (self *keyRegistry) setStorage(storage APIKeyStorage) error {
+	keys, err := storage.GetAPIKeys()
+	if err != nil {
+		return err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, key := range keys {
+		if _, exist := self.static[key.ID]; exist {
+			return fmt.Errorf("stored API key %s collides with the key loaded from config file", key.ID)
+		}
+	}
+	self.storage = storage
+	for _, key := range keys {
+		self.managed[key.ID] = key
+	}
+	return nil
+}
+
+func (self *keyRegistry) all() []common.APIKey {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	var result []common.APIKey
+	for _, key := range self.static {
+		result = append(result, key)
+	}
+	for _, key := range self.managed {
+		result = append(result, key)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+func (self *keyRegistry) update(key common.APIKey) error {
+	if err := validateAPIKey(key); err != nil {
+		return err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if _, exist := self.static[key.ID]; exist {
+		return fmt.Errorf("API key %s is loaded from config file and cannot be updated", key.ID)
+	}
+	if containsString(key.Permissions, "admin") {
+		return fmt.Errorf("API key %s cannot be granted admin permission, only keys loaded from config file can have it", key.ID)
+	}
+	if self.storage == nil {
+		return errNoAPIKeyStorage
+	}
+	if err := self.storage.StoreAPIKey(key); err != nil {
+		return err
+	}
+	self.managed[key.ID] = key
+	return nil
+}
+
+func (self *keyRegistry) remove(keyID string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if _, exist := self.static[keyID]; exist {
+		return fmt.Errorf("API key %s is loaded from config file and cannot be removed", keyID)
+	}
+	if _, exist := self.managed[keyID]; !exist {
+		return fmt.Errorf("API key %s does not exist", keyID)
+	}
+	if self.storage == nil {
+		return errNoAPIKeyStorage
+	}
+	if err := self.storage.RemoveAPIKey(keyID); err != nil {
+		return err
+	}
+	delete(self.managed, keyID)
+	return nil
+}
+
+func validateAPIKey(key common.APIKey) error {
+	if key.ID == "" {
+		return errors.New("API key ID is required")
+	}
+	if key.Secret == "" {
+		return fmt.Errorf("secret of API key %s is required", key.ID)
+	}
+	if len(key.Permissions) == 0 {
+		return fmt.Errorf("API key %s has no permission", key.ID)
+	}
+	if _, err := permissionsFromNames(key.Permissions); err != nil {
+		return err
+	}
+	for _, ip := range key.IPAllowlist {
+		if _, _, err := net.ParseCIDR(ip); err == nil {
+			continue
+		}
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid IP address or range %s of API key %s", ip, key.ID)
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// requestTokens returns the IDs of tokens a request acts on.
+func requestTokens(params url.Values) []string {
+	var result []string
+	for _, name := range []string{"token", "base", "quote"} {
+		if token := params.Get(name); token != "" {
+			result = append(result, token)
+		}
+	}
+	if tokens := params.Get("tokens"); tokens != "" {
+		result = append(result, strings.Split(tokens, "-")...)
+	}
+	return result
+}
+
+func allowedEndpoint(endpoints []string, path string) bool {
+	for _, endpoint := range endpoints {
+		if path == endpoint || strings.HasPrefix(path, strings.TrimSuffix(endpoint, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func allowedIP(allowlist []string, ip string) bool {
+	clientIP := net.ParseIP(ip)
+	if clientIP == nil {
+		return false
+	}
+	for _, entry := range allowlist {
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			if ipNet.Contains(clientIP) {
+				return true
+			}
+			continue
+		}
+		if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(clientIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize returns an error if the key is not allowed to make the request
+// which requires one of given permissions.
+func authorize(c *gin.Context, params url.Values, key common.APIKey, perms []Permission) error {
+	keyPerms, err := permissionsFromNames(key.Permissions)
+	if err != nil {
+		return err
+	}
+	if !eligible(keyPerms, perms) {
+		return errors.New("You don't have permission to proceed")
+	}
+	path := c.Request.URL.Path
+	if len(key.Endpoints) != 0 && !allowedEndpoint(key.Endpoints, path) {
+		return fmt.Errorf("Key %s is not allowed to access %s", key.ID, path)
+	}
+	if len(key.IPAllowlist) != 0 && !allowedIP(key.IPAllowlist, c.ClientIP()) {
+		return fmt.Errorf("Key %s is not allowed from %s", key.ID, c.ClientIP())
+	}
+	if len(key.Exchanges) != 0 {
+		for _, exchange := range []string{c.Param("exchangeid"), params.Get("exchange")} {
+			if exchange != "" && !containsString(key.Exchanges, exchange) {
+				return fmt.Errorf("Key %s is not allowed to act on exchange %s", key.ID, exchange)
+			}
+		}
+	}
+	if len(key.Tokens) != 0 {
+		tokens := requestTokens(params)
+		for _, token := range tokens {
+			if !containsString(key.Tokens, token) {
+				return fmt.Errorf("Key %s is not allowed to act on token %s", key.ID, token)
+			}
+		}
+		// the tokens of a configuration posted as JSON data or acting on
+		// all tokens are not known from the params
+		if configures(perms) && (len(tokens) == 0 || params.Get("data") != "" || params.Get("value") != "") {
+			return fmt.Errorf("Key %s is restricted to tokens %v and is not allowed to configure at %s", key.ID, key.Tokens, path)
+		}
+	}
+	return nil
+}
+
+// configures returns true if perms are the permissions of a configuration
+// endpoint.
+func configures(perms []Permission) bool {
+	for _, perm := range perms {
+		if perm == ConfigurePermission || perm == ConfirmConfPermission {
+			return true
+		}
+	}
+	return false
+}
+
+// getKeyID returns the ID of the API key that signed the request, it is empty
+// if authentication is disabled.
+func getKeyID(c *gin.Context) string {
+	return c.GetString(keyIDContextKey)
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// GetAPIKeys returns all API keys without their secrets.
+func (self *HTTPServer) GetAPIKeys(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{AdminPermission})
+	if !ok {
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(self.auth.GetAPIKeys()))
+}
+
+// SetAPIKey creates or updates a managed API key. List params are separated
+// by comma. Managing keys requires the admin permission which cannot be
+// granted to managed keys, so a managed key can't escalate its permissions.
+func (self *HTTPServer) SetAPIKey(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"key_id", "secret", "permissions"}, []Permission{AdminPermission})
+	if !ok {
+		return
+	}
+	key := common.APIKey{
+		ID:          postForm.Get("key_id"),
+		Secret:      postForm.Get("secret"),
+		Permissions: splitList(postForm.Get("permissions")),
+		Endpoints:   splitList(postForm.Get("endpoints")),
+		Exchanges:   splitList(postForm.Get("exchanges")),
+		Tokens:      splitList(postForm.Get("tokens")),
+		IPAllowlist: splitList(postForm.Get("ip_allowlist")),
+	}
+	if err := self.auth.UpdateAPIKey(key); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c)
+}
+
+// RemoveAPIKey removes a managed API key.
+func (self *HTTPServer) RemoveAPIKey(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"key_id"}, []Permission{AdminPermission})
+	if !ok {
+		return
+	}
+	if err := self.auth.RemoveAPIKey(postForm.Get("key_id")); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c)
+}
@@ -0,0 +1,383 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+type testAPIKeyStorage struct {
+	keys map[string]common.APIKey
+}
+
+func (self *testAPIKeyStorage) StoreAPIKey(key common.APIKey) error {
+	self.keys[key.ID] = key
+	return nil
+}
+
+func (self *testAPIKeyStorage) GetAPIKeys() ([]common.APIKey, error) {
+	var result []common.APIKey
+	for _, key := range self.keys {
+		result = append(result, key)
+	}
+	return result, nil
+}
+
+func (self *testAPIKeyStorage) RemoveAPIKey(keyID string) error {
+	delete(self.keys, keyID)
+	return nil
+}
+
+func newTestAPIKeyServer(t *testing.T) *HTTPServer {
+	keys, err := newKeyRegistry([]common.APIKey{
+		{
+			ID:          "withdrawer",
+			Secret:      "withdrawer_secret",
+			Permissions: []string{"rebalance"},
+			Endpoints:   []string{"/withdraw"},
+			Exchanges:   []string{"binance"},
+			Tokens:      []string{"KNC"},
+		},
+		{
+			ID:          "office",
+			Secret:      "office_secret",
+			Permissions: []string{"rebalance"},
+			IPAllowlist: []string{"10.0.0.0/8"},
+		},
+		{
+			ID:          "oncall",
+			Secret:      "oncall_secret",
+			Permissions: []string{"emergency"},
+		},
+		{
+			ID:          "admin",
+			Secret:      "admin_secret",
+			Permissions: []string{"admin"},
+		},
+		{
+			ID:          "knc_config",
+			Secret:      "knc_config_secret",
+			Permissions: []string{"configure"},
+			Tokens:      []string{"KNC"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = keys.setStorage(&testAPIKeyStorage{keys: make(map[string]common.APIKey)}); err != nil {
+		t.Fatal(err)
+	}
+	s := &HTTPServer{
+		authEnabled: true,
+		auth: KNAuthentication{
+			KNSecret:      "rebalance_secret",
+			KNReadOnly:    "readonly_secret",
+			KNConfirmConf: "confirm_secret",
+			usedNonces:    newNonceStore(uint64(maxNonceDifference)),
+			keys:          keys,
+		},
+		r: gin.Default(),
+	}
+	handler := func(c *gin.Context) {
+		if _, ok := s.Authenticated(c, []string{}, []Permission{RebalancePermission}); !ok {
+			return
+		}
+		httputil.ResponseSuccess(c, httputil.WithField("key_id", getKeyID(c)))
+	}
+	s.r.POST("/withdraw/:exchangeid", handler)
+	s.r.POST("/deposit/:exchangeid", handler)
+	s.r.POST("/setrates", s.SetRate)
+	s.r.POST("/emergency-stop", func(c *gin.Context) {
+		if _, ok := s.Authenticated(c, []string{}, []Permission{EmergencyPermission}); !ok {
+			return
+		}
+		httputil.ResponseSuccess(c, httputil.WithField("key_id", getKeyID(c)))
+	})
+	s.r.POST("/configure", func(c *gin.Context) {
+		if _, ok := s.Authenticated(c, []string{}, []Permission{ConfigurePermission}); !ok {
+			return
+		}
+		httputil.ResponseSuccess(c, httputil.WithField("key_id", getKeyID(c)))
+	})
+	s.r.GET("/api-keys", s.GetAPIKeys)
+	s.r.POST("/set-api-key", s.SetAPIKey)
+	s.r.POST("/remove-api-key", s.RemoveAPIKey)
+	return s
+}
+
+func newKeySignedRequest(t *testing.T, method, secret, path, remoteAddr string, form url.Values) *http.Request {
+	t.Helper()
+	form.Set("nonce", strconv.FormatUint(common.GetTimepoint(), 10))
+	var (
+		req *http.Request
+		err error
+	)
+	if method == http.MethodGet {
+		req, err = http.NewRequest(method, path+"?"+form.Encode(), nil)
+	} else {
+		req, err = http.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("signed", sign(secret, form.Encode()))
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func expectKeyID(keyID string) func(*testing.T, *httptest.ResponseRecorder) {
+	return func(t *testing.T, resp *httptest.ResponseRecorder) {
+		t.Helper()
+		var decoded struct {
+			Success bool   `json:"success"`
+			KeyID   string `json:"key_id"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Success || decoded.KeyID != keyID {
+			t.Errorf("expected success response of key %s, got %+v", keyID, decoded)
+		}
+	}
+}
+
+func TestAPIKeyPermissions(t *testing.T) {
+	s := newTestAPIKeyServer(t)
+	const remoteAddr = "192.168.1.1:1234"
+
+	var tests = []struct {
+		msg    string
+		req    *http.Request
+		assert func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			msg:    "built-in key",
+			req:    newKeySignedRequest(t, http.MethodPost, "rebalance_secret", "/deposit/huobi", remoteAddr, url.Values{"token": {"OMG"}}),
+			assert: expectKeyID("kn_secret"),
+		},
+		{
+			msg:    "built-in key without permission",
+			req:    newKeySignedRequest(t, http.MethodPost, "readonly_secret", "/deposit/huobi", remoteAddr, url.Values{}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "named key on allowed endpoint, exchange and token",
+			req:    newKeySignedRequest(t, http.MethodPost, "withdrawer_secret", "/withdraw/binance", remoteAddr, url.Values{"token": {"KNC"}}),
+			assert: expectKeyID("withdrawer"),
+		},
+		{
+			msg:    "named key on not allowed endpoint",
+			req:    newKeySignedRequest(t, http.MethodPost, "withdrawer_secret", "/deposit/binance", remoteAddr, url.Values{"token": {"KNC"}}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "named key on not allowed exchange",
+			req:    newKeySignedRequest(t, http.MethodPost, "withdrawer_secret", "/withdraw/huobi", remoteAddr, url.Values{"token": {"KNC"}}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "named key on not allowed token",
+			req:    newKeySignedRequest(t, http.MethodPost, "withdrawer_secret", "/withdraw/binance", remoteAddr, url.Values{"token": {"OMG"}}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "named key from allowed IP",
+			req:    newKeySignedRequest(t, http.MethodPost, "office_secret", "/deposit/binance", "10.1.2.3:1234", url.Values{}),
+			assert: expectKeyID("office"),
+		},
+		{
+			msg:    "named key from not allowed IP",
+			req:    newKeySignedRequest(t, http.MethodPost, "office_secret", "/deposit/binance", remoteAddr, url.Values{}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg: "built-in key forcing set rates",
+			req: newKeySignedRequest(t, http.MethodPost, "rebalance_secret", "/setrates", remoteAddr, url.Values{
+				"tokens": {"KNC"}, "buys": {"0x1"}, "sells": {"0x1"}, "block": {"1"}, "afp_mid": {"0x1"}, "msgs": {"test"}, "force": {"true"},
+			}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "named key with emergency permission stopping",
+			req:    newKeySignedRequest(t, http.MethodPost, "oncall_secret", "/emergency-stop", remoteAddr, url.Values{"action": {"zero_rates"}}),
+			assert: expectKeyID("oncall"),
+		},
+		{
+			msg:    "built-in key with rebalance permission stopping",
+			req:    newKeySignedRequest(t, http.MethodPost, "rebalance_secret", "/emergency-stop", remoteAddr, url.Values{"action": {"zero_rates"}}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "named key with emergency permission depositing",
+			req:    newKeySignedRequest(t, http.MethodPost, "oncall_secret", "/deposit/binance", remoteAddr, url.Values{}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "token restricted key configuring its token",
+			req:    newKeySignedRequest(t, http.MethodPost, "knc_config_secret", "/configure", remoteAddr, url.Values{"token": {"KNC"}}),
+			assert: expectKeyID("knc_config"),
+		},
+		{
+			msg: "token restricted key configuring tokens in JSON data",
+			req: newKeySignedRequest(t, http.MethodPost, "knc_config_secret", "/configure", remoteAddr, url.Values{
+				"token": {"KNC"}, "value": {`{"OMG": {}}`},
+			}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "token restricted key configuring all tokens",
+			req:    newKeySignedRequest(t, http.MethodPost, "knc_config_secret", "/configure", remoteAddr, url.Values{}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "unknown key",
+			req:    newKeySignedRequest(t, http.MethodPost, "unknown_secret", "/deposit/binance", remoteAddr, url.Values{}),
+			assert: httputil.ExpectFailure,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			s.r.ServeHTTP(resp, tc.req)
+			tc.assert(t, resp)
+		})
+	}
+}
+
+func TestManageAPIKeys(t *testing.T) {
+	s := newTestAPIKeyServer(t)
+	const remoteAddr = "192.168.1.1:1234"
+	newKey := url.Values{
+		"key_id":      {"operator"},
+		"secret":      {"operator_secret"},
+		"permissions": {"rebalance"},
+		"exchanges":   {"binance,huobi"},
+	}
+
+	var tests = []struct {
+		msg    string
+		req    *http.Request
+		assert func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			msg:    "set API key without permission",
+			req:    newKeySignedRequest(t, http.MethodPost, "rebalance_secret", "/set-api-key", remoteAddr, newKey),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "set API key by confirm configuration key",
+			req:    newKeySignedRequest(t, http.MethodPost, "confirm_secret", "/set-api-key", remoteAddr, newKey),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg: "grant admin permission",
+			req: newKeySignedRequest(t, http.MethodPost, "admin_secret", "/set-api-key", remoteAddr, url.Values{
+				"key_id":      {"operator"},
+				"secret":      {"operator_secret"},
+				"permissions": {"rebalance,admin"},
+			}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg: "set API key with unknown permission",
+			req: newKeySignedRequest(t, http.MethodPost, "admin_secret", "/set-api-key", remoteAddr, url.Values{
+				"key_id":      {"operator"},
+				"secret":      {"operator_secret"},
+				"permissions": {"superuser"},
+			}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg: "override built-in key",
+			req: newKeySignedRequest(t, http.MethodPost, "admin_secret", "/set-api-key", remoteAddr, url.Values{
+				"key_id":      {"kn_secret"},
+				"secret":      {"operator_secret"},
+				"permissions": {"rebalance"},
+			}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "set API key",
+			req:    newKeySignedRequest(t, http.MethodPost, "admin_secret", "/set-api-key", remoteAddr, newKey),
+			assert: httputil.ExpectSuccess,
+		},
+		{
+			msg:    "use new API key",
+			req:    newKeySignedRequest(t, http.MethodPost, "operator_secret", "/deposit/huobi", remoteAddr, url.Values{}),
+			assert: expectKeyID("operator"),
+		},
+		{
+			msg: "list API keys",
+			req: newKeySignedRequest(t, http.MethodGet, "admin_secret", "/api-keys", remoteAddr, url.Values{}),
+			assert: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				var decoded struct {
+					Success bool            `json:"success"`
+					Data    []common.APIKey `json:"data"`
+				}
+				if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+					t.Fatal(err)
+				}
+				// 3 configured built-in keys and 6 named keys
+				if !decoded.Success || len(decoded.Data) != 9 {
+					t.Fatalf("expected 9 API keys, got %+v", decoded)
+				}
+				for _, key := range decoded.Data {
+					if key.Secret != "" {
+						t.Errorf("expected secret of key %s to be hidden", key.ID)
+					}
+				}
+			},
+		},
+		{
+			msg:    "remove API key loaded from config file",
+			req:    newKeySignedRequest(t, http.MethodPost, "admin_secret", "/remove-api-key", remoteAddr, url.Values{"key_id": {"office"}}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "remove API key",
+			req:    newKeySignedRequest(t, http.MethodPost, "admin_secret", "/remove-api-key", remoteAddr, url.Values{"key_id": {"operator"}}),
+			assert: httputil.ExpectSuccess,
+		},
+		{
+			msg:    "use removed API key",
+			req:    newKeySignedRequest(t, http.MethodPost, "operator_secret", "/deposit/huobi", remoteAddr, url.Values{}),
+			assert: httputil.ExpectFailure,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			s.r.ServeHTTP(resp, tc.req)
+			tc.assert(t, resp)
+		})
+	}
+}
+
+func TestAPIKeyStorageCollision(t *testing.T) {
+	keys, err := newKeyRegistry([]common.APIKey{
+		{ID: "admin", Secret: "admin_secret", Permissions: []string{"admin"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := &testAPIKeyStorage{keys: map[string]common.APIKey{
+		"admin": {ID: "admin", Secret: "stored_secret", Permissions: []string{"rebalance"}},
+	}}
+	if err = keys.setStorage(storage); err == nil {
+		t.Fatal("expected error loading a stored key colliding with a key from config file")
+	}
+	for _, key := range keys.all() {
+		if key.Secret != "admin_secret" {
+			t.Errorf("expected the stored key not loaded, got %+v", key)
+		}
+	}
+}
@@ -4,16 +4,30 @@ import (
 	"crypto/hmac"
 	"crypto/sha512"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
 
+	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 // Authentication is the authentication layer of HTTP APIs.
 type Authentication interface {
 	KNSign(message string) string
-	GetPermission(signed string, message string) []Permission
+	// RequestMessage returns the message to be signed of a request with
+	// given HTTP method, path and url encoded params.
+	RequestMessage(method, path, params string) string
+	// GetKeys returns the API keys which signature of the message is signed.
+	GetKeys(signed string, message string) []common.APIKey
+	// UseNonce records the nonce as used by the key. It returns an error if
+	// the key has already used the nonce.
+	UseNonce(keyID, nonce string) error
+
+	// GetAPIKeys returns all API keys without their secrets.
+	GetAPIKeys() []common.APIKey
+	UpdateAPIKey(key common.APIKey) error
+	RemoveAPIKey(keyID string) error
 }
 
 type KNAuthentication struct {
@@ -21,6 +35,17 @@ type KNAuthentication struct {
 	KNReadOnly      string `json:"kn_readonly"`
 	KNConfiguration string `json:"kn_configuration"`
 	KNConfirmConf   string `json:"kn_confirm_configuration"`
+
+	// ReplayProtection requires the HTTP method and path to be signed and
+	// rejects the requests which nonce is already used by the same key.
+	ReplayProtection bool `json:"kn_replay_protection"`
+
+	// APIKeys are the named keys in addition to the built-in keys of the
+	// four secrets above.
+	APIKeys []common.APIKey `json:"kn_api_keys"`
+
+	usedNonces *nonceStore
+	keys       *keyRegistry
 }
 
 func NewKNAuthenticationFromFile(path string) KNAuthentication {
@@ -32,58 +57,122 @@ func NewKNAuthenticationFromFile(path string) KNAuthentication {
 	if err = json.Unmarshal(raw, &result); err != nil {
 		panic(err)
 	}
+	result.usedNonces = newNonceStore(uint64(maxNonceDifference))
+	if result.keys, err = newKeyRegistry(result.APIKeys); err != nil {
+		panic(err)
+	}
+	for _, key := range result.APIKeys {
+		if _, builtin := result.builtinKeys()[key.ID]; builtin {
+			panic(fmt.Sprintf("API key ID %s is reserved for built-in key", key.ID))
+		}
+	}
 	return result
 }
 
-func (self KNAuthentication) KNSign(msg string) string {
-	mac := hmac.New(sha512.New, []byte(self.KNSecret))
-	if _, err := mac.Write([]byte(msg)); err != nil {
-		log.Printf("Encode message error: %s", err.Error())
-	}
-	return ethereum.Bytes2Hex(mac.Sum(nil))
+// SetAPIKeyStorage loads the API keys managed via admin endpoints from the
+// storage and uses it to persist later changes.
+func (self KNAuthentication) SetAPIKeyStorage(storage APIKeyStorage) error {
+	return self.keys.setStorage(storage)
 }
 
-func (self KNAuthentication) knReadonlySign(msg string) string {
-	mac := hmac.New(sha512.New, []byte(self.KNReadOnly))
+func sign(secret, msg string) string {
+	mac := hmac.New(sha512.New, []byte(secret))
 	if _, err := mac.Write([]byte(msg)); err != nil {
 		log.Printf("Encode message error: %s", err.Error())
 	}
 	return ethereum.Bytes2Hex(mac.Sum(nil))
 }
 
-func (self KNAuthentication) knConfigurationSign(msg string) string {
-	mac := hmac.New(sha512.New, []byte(self.KNConfiguration))
-	if _, err := mac.Write([]byte(msg)); err != nil {
-		log.Printf("Encode message error: %s", err.Error())
+func (self KNAuthentication) KNSign(msg string) string {
+	return sign(self.KNSecret, msg)
+}
+
+// builtinKeyIDs are the IDs of built-in keys by the order they are checked.
+var builtinKeyIDs = []string{"kn_secret", "kn_readonly", "kn_configuration", "kn_confirm_configuration"}
+
+// builtinKeys returns the keys of the four secrets, the ID of each key is
+// the config name of its secret.
+func (self KNAuthentication) builtinKeys() map[string]common.APIKey {
+	return map[string]common.APIKey{
+		"kn_secret":                {ID: "kn_secret", Secret: self.KNSecret, Permissions: []string{"rebalance"}},
+		"kn_readonly":              {ID: "kn_readonly", Secret: self.KNReadOnly, Permissions: []string{"read_only"}},
+		"kn_configuration":         {ID: "kn_configuration", Secret: self.KNConfiguration, Permissions: []string{"configure"}},
+		"kn_confirm_configuration": {ID: "kn_confirm_configuration", Secret: self.KNConfirmConf, Permissions: []string{"confirm_configuration"}},
 	}
-	return ethereum.Bytes2Hex(mac.Sum(nil))
 }
 
-func (self KNAuthentication) knConfirmConfSign(msg string) string {
-	mac := hmac.New(sha512.New, []byte(self.KNConfirmConf))
-	if _, err := mac.Write([]byte(msg)); err != nil {
-		log.Printf("Encode message error: %s", err.Error())
+// allKeys returns the built-in keys which secret is configured and the named keys.
+func (self KNAuthentication) allKeys() []common.APIKey {
+	var result []common.APIKey
+	builtins := self.builtinKeys()
+	for _, id := range builtinKeyIDs {
+		if key := builtins[id]; key.Secret != "" {
+			result = append(result, key)
+		}
 	}
-	return ethereum.Bytes2Hex(mac.Sum(nil))
+	if self.keys != nil {
+		result = append(result, self.keys.all()...)
+	}
+	return result
 }
 
-func (self KNAuthentication) GetPermission(signed string, message string) []Permission {
-	result := []Permission{}
-	rebalanceSigned := self.KNSign(message)
-	if signed == rebalanceSigned {
-		result = append(result, RebalancePermission)
+// RequestMessage returns the message to be signed of a request. The message
+// is the url encoded params, prefixed by HTTP method and path in new lines if
+// replay protection is enabled, for example:
+// POST
+// /withdraw/binance
+// amount=0xde0b6b3a7640000&nonce=1514554594528&token=KNC
+func (self KNAuthentication) RequestMessage(method, path, params string) string {
+	if !self.ReplayProtection {
+		return params
 	}
-	readonlySigned := self.knReadonlySign(message)
-	if signed == readonlySigned {
-		result = append(result, ReadOnlyPermission)
+	return method + "\n" + path + "\n" + params
+}
+
+// UseNonce records the nonce as used by the key, it does nothing if replay
+// protection is disabled.
+func (self KNAuthentication) UseNonce(keyID, nonce string) error {
+	if !self.ReplayProtection {
+		return nil
 	}
-	configureSigned := self.knConfigurationSign(message)
-	if signed == configureSigned {
-		result = append(result, ConfigurePermission)
+	return self.usedNonces.use(keyID, nonce, common.GetTimepoint())
+}
+
+func (self KNAuthentication) GetKeys(signed string, message string) []common.APIKey {
+	var result []common.APIKey
+	for _, key := range self.allKeys() {
+		if hmac.Equal([]byte(sign(key.Secret, message)), []byte(signed)) {
+			result = append(result, key)
+		}
 	}
-	confirmConfSigned := self.knConfirmConfSign(message)
-	if signed == confirmConfSigned {
-		result = append(result, ConfirmConfPermission)
+	return result
+}
+
+func (self KNAuthentication) GetAPIKeys() []common.APIKey {
+	result := []common.APIKey{}
+	for _, key := range self.allKeys() {
+		key.Secret = ""
+		result = append(result, key)
 	}
 	return result
 }
+
+func (self KNAuthentication) UpdateAPIKey(key common.APIKey) error {
+	if _, builtin := self.builtinKeys()[key.ID]; builtin {
+		return fmt.Errorf("API key %s is a built-in key and cannot be updated", key.ID)
+	}
+	if self.keys == nil {
+		return errNoAPIKeyStorage
+	}
+	return self.keys.update(key)
+}
+
+func (self KNAuthentication) RemoveAPIKey(keyID string) error {
+	if _, builtin := self.builtinKeys()[keyID]; builtin {
+		return fmt.Errorf("API key %s is a built-in key and cannot be removed", keyID)
+	}
+	if self.keys == nil {
+		return errNoAPIKeyStorage
+	}
+	return self.keys.remove(keyID)
+}
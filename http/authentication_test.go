@@ -0,0 +1,129 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+func newTestAuthServer(replayProtection bool) *HTTPServer {
+	auth := KNAuthentication{
+		KNSecret:         "rebalance_secret",
+		KNReadOnly:       "readonly_secret",
+		ReplayProtection: replayProtection,
+		usedNonces:       newNonceStore(uint64(maxNonceDifference)),
+	}
+	s := &HTTPServer{
+		authEnabled: true,
+		auth:        auth,
+		r:           gin.Default(),
+	}
+	handler := func(c *gin.Context) {
+		if _, ok := s.Authenticated(c, []string{}, []Permission{RebalancePermission}); !ok {
+			return
+		}
+		httputil.ResponseSuccess(c)
+	}
+	s.r.POST("/withdraw/:exchangeid", handler)
+	s.r.POST("/deposit/:exchangeid", handler)
+	return s
+}
+
+// signedRequest creates a request to path with given form, signed for signedPath.
+func signedRequest(t *testing.T, auth KNAuthentication, path, signedPath string, form url.Values) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("signed", auth.KNSign(auth.RequestMessage(http.MethodPost, signedPath, form.Encode())))
+	return req
+}
+
+func TestReplayProtection(t *testing.T) {
+	s := newTestAuthServer(true)
+	auth := s.auth.(KNAuthentication)
+	form := url.Values{}
+	form.Add("nonce", strconv.FormatUint(common.GetTimepoint(), 10))
+	form.Add("amount", "0xde0b6b3a7640000")
+	form.Add("token", "KNC")
+
+	var tests = []struct {
+		msg    string
+		req    *http.Request
+		assert func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			msg:    "valid signed request",
+			req:    signedRequest(t, auth, "/withdraw/binance", "/withdraw/binance", form),
+			assert: httputil.ExpectSuccess,
+		},
+		{
+			msg:    "replayed request",
+			req:    signedRequest(t, auth, "/withdraw/binance", "/withdraw/binance", form),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "signature of other endpoint",
+			req:    signedRequest(t, auth, "/deposit/binance", "/withdraw/binance", form),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "signature without method and path",
+			req:    signedRequest(t, KNAuthentication{KNSecret: auth.KNSecret}, "/deposit/binance", "", form),
+			assert: httputil.ExpectFailure,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			s.r.ServeHTTP(resp, tc.req)
+			tc.assert(t, resp)
+		})
+	}
+}
+
+func TestReplayProtectionDisabled(t *testing.T) {
+	s := newTestAuthServer(false)
+	auth := s.auth.(KNAuthentication)
+	form := url.Values{}
+	form.Add("nonce", strconv.FormatUint(common.GetTimepoint(), 10))
+
+	// without replay protection, the same signed request is accepted multiple
+	// times and on different endpoints for backward compatibility.
+	for _, path := range []string{"/withdraw/binance", "/withdraw/binance", "/deposit/binance"} {
+		resp := httptest.NewRecorder()
+		s.r.ServeHTTP(resp, signedRequest(t, auth, path, "/withdraw/binance", form))
+		httputil.ExpectSuccess(t, resp)
+	}
+}
+
+func TestNonceStore(t *testing.T) {
+	store := newNonceStore(100)
+	if err := store.use("key1", "1000", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.use("key1", "1000", 1050); err == nil {
+		t.Error("expected error reusing nonce of the same key")
+	}
+	if err := store.use("key2", "1000", 1050); err != nil {
+		t.Errorf("expected nonce to be tracked per key, got error: %s", err)
+	}
+	if err := store.use("key1", "1001", 1200); err != nil {
+		t.Fatal(err)
+	}
+	if _, exist := store.nonces["key1"][1000]; exist {
+		t.Error("expected expired nonce to be pruned")
+	}
+	if err := store.use("key1", "invalid", 1200); err == nil {
+		t.Error("expected error using invalid nonce")
+	}
+}
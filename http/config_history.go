@@ -0,0 +1,95 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+// proposeConfig records a pending proposal of the configuration type by the
+// key of the request. Unless merge is true, the new proposal replaces the
+// pending proposals of the same type.
+func (self *HTTPServer) proposeConfig(c *gin.Context, configType string, data []byte, active, proposed interface{}, merge bool) error {
+	proposal, err := common.NewConfigProposal(configType, getKeyID(c), data, active, proposed)
+	if err != nil {
+		return err
+	}
+	if !merge {
+		if err = self.resolveConfig(c, configType, common.ConfigProposalSuperseded); err != nil {
+			return err
+		}
+	}
+	return self.metric.StoreConfigProposal(proposal)
+}
+
+// checkConfirmer returns an error if there is no pending proposal of the
+// configuration type, pending data without proposal was not recorded as
+// proposed and can't be confirmed, or if the key of the request proposed one
+// of them. Proposals made with authentication disabled have no proposer and
+// can be confirmed by anyone.
+func (self *HTTPServer) checkConfirmer(c *gin.Context, configType string) error {
+	proposals, err := self.metric.GetPendingConfigProposals(configType)
+	if err != nil {
+		return err
+	}
+	if len(proposals) == 0 {
+		return fmt.Errorf("There is no pending proposal of %s to confirm", configType)
+	}
+	keyID := getKeyID(c)
+	for _, proposal := range proposals {
+		if proposal.Proposer != "" && proposal.Proposer == keyID {
+			return fmt.Errorf("Proposal %d of %s was made by key %s, it must be confirmed by another key", proposal.ID, configType, keyID)
+		}
+	}
+	return nil
+}
+
+// resolveConfig marks the pending proposals of the configuration type as
+// resolved with given status by the key of the request.
+func (self *HTTPServer) resolveConfig(c *gin.Context, configType, status string) error {
+	proposals, err := self.metric.GetPendingConfigProposals(configType)
+	if err != nil {
+		return err
+	}
+	for _, proposal := range proposals {
+		proposal.Status = status
+		proposal.Resolver = getKeyID(c)
+		proposal.ResolvedAt = common.GetTimepoint()
+		if err = self.metric.StoreConfigProposal(proposal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logResolveConfig resolves the pending proposals after the configuration
+// change is applied, failing to record the outcome does not fail the request.
+func (self *HTTPServer) logResolveConfig(c *gin.Context, configType, status string) {
+	if err := self.resolveConfig(c, configType, status); err != nil {
+		log.Printf("failed to record %s proposals as %s: %s", configType, status, err)
+	}
+}
+
+// GetConfigHistory returns all configuration proposals with their outcome.
+// fromTime and toTime are optional, default to all the proposals.
+func (self *HTTPServer) GetConfigHistory(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	fromTime, _ := strconv.ParseUint(c.Query("fromTime"), 10, 64)
+	toTime, _ := strconv.ParseUint(c.Query("toTime"), 10, 64)
+	if toTime == 0 {
+		toTime = common.GetTimepoint()
+	}
+	data, err := self.metric.GetConfigProposals(fromTime, toTime)
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(data))
+}
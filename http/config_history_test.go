@@ -0,0 +1,156 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/data/storage"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/KyberNetwork/reserve-data/settings"
+	settingsstorage "github.com/KyberNetwork/reserve-data/settings/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func TestFourEyesConfirmation(t *testing.T) {
+	const (
+		remoteAddr = "192.168.1.1:1234"
+		testData   = `{"OMG": {"set_target": {"total_target": 750, "reserve_target": 500, "rebalance_threshold": 0.25, "transfer_threshold": 0.343}}}`
+	)
+
+	tmpDir, err := ioutil.TempDir("", "test_four_eyes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if rErr := os.RemoveAll(tmpDir); rErr != nil {
+			t.Error(rErr)
+		}
+	}()
+
+	st, err := storage.NewBoltStorage(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	boltSettingStorage, err := settingsstorage.NewBoltSettingStorage(filepath.Join(tmpDir, "setting.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenSetting, err := settings.NewTokenSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchangeSetting, err := settings.NewExchangeSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setting, err := settings.NewSetting(tokenSetting, &settings.AddressSetting{}, exchangeSetting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setting.UpdateToken(common.Token{ID: "OMG", Address: "xxx", Internal: true, Active: true}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := newKeyRegistry([]common.APIKey{
+		{ID: "alice", Secret: "alice_secret", Permissions: []string{"configure", "confirm_configuration"}},
+		{ID: "bob", Secret: "bob_secret", Permissions: []string{"confirm_configuration"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := HTTPServer{
+		metric:      st,
+		authEnabled: true,
+		auth:        KNAuthentication{usedNonces: newNonceStore(uint64(maxNonceDifference)), keys: keys},
+		r:           gin.Default(),
+		setting:     setting,
+	}
+	s.r.POST("/v2/settargetqty", s.SetTargetQtyV2)
+	s.r.POST("/v2/confirmtargetqty", s.ConfirmTargetQtyV2)
+	s.r.POST("/v2/canceltargetqty", s.CancelTargetQtyV2)
+	s.r.GET("/config-history", s.GetConfigHistory)
+
+	var tests = []struct {
+		msg    string
+		req    *http.Request
+		assert func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			msg:    "propose target quantity",
+			req:    newKeySignedRequest(t, http.MethodPost, "alice_secret", "/v2/settargetqty", remoteAddr, url.Values{"value": {testData}}),
+			assert: httputil.ExpectSuccess,
+		},
+		{
+			msg:    "confirm by proposer",
+			req:    newKeySignedRequest(t, http.MethodPost, "alice_secret", "/v2/confirmtargetqty", remoteAddr, url.Values{"value": {testData}}),
+			assert: httputil.ExpectFailure,
+		},
+		{
+			msg:    "confirm by another key",
+			req:    newKeySignedRequest(t, http.MethodPost, "bob_secret", "/v2/confirmtargetqty", remoteAddr, url.Values{"value": {testData}}),
+			assert: httputil.ExpectSuccess,
+		},
+		{
+			msg:    "propose target quantity to reject",
+			req:    newKeySignedRequest(t, http.MethodPost, "alice_secret", "/v2/settargetqty", remoteAddr, url.Values{"value": {testData}}),
+			assert: httputil.ExpectSuccess,
+		},
+		{
+			msg:    "reject by proposer",
+			req:    newKeySignedRequest(t, http.MethodPost, "alice_secret", "/v2/canceltargetqty", remoteAddr, url.Values{}),
+			assert: httputil.ExpectSuccess,
+		},
+		{
+			msg: "get config history",
+			req: newKeySignedRequest(t, http.MethodGet, "bob_secret", "/config-history", remoteAddr, url.Values{}),
+			assert: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				var decoded struct {
+					Success bool                    `json:"success"`
+					Data    []common.ConfigProposal `json:"data"`
+				}
+				if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+					t.Fatal(err)
+				}
+				if !decoded.Success || len(decoded.Data) != 2 {
+					t.Fatalf("expected 2 proposals, got %+v", decoded)
+				}
+				confirmed, rejected := decoded.Data[0], decoded.Data[1]
+				if confirmed.Type != common.ConfigTargetQtyV2 || confirmed.Proposer != "alice" ||
+					confirmed.Status != common.ConfigProposalConfirmed || confirmed.Resolver != "bob" {
+					t.Errorf("unexpected confirmed proposal %+v", confirmed)
+				}
+				if len(confirmed.Diff) != 4 || confirmed.Diff[0].Path != "OMG.set_target.rebalance_threshold" || confirmed.Diff[0].Old != nil {
+					t.Errorf("expected new OMG target fields in diff of first proposal, got %+v", confirmed.Diff)
+				}
+				if rejected.Status != common.ConfigProposalRejected || rejected.Resolver != "alice" {
+					t.Errorf("unexpected rejected proposal %+v", rejected)
+				}
+				if len(rejected.Diff) != 0 {
+					t.Errorf("expected no diff against confirmed target quantity, got %+v", rejected.Diff)
+				}
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			s.r.ServeHTTP(resp, tc.req)
+			tc.assert(t, resp)
+		})
+	}
+
+	// pending data stored without a proposal can't be confirmed
+	if err = st.StorePendingTargetQtyV2([]byte(testData)); err != nil {
+		t.Fatal(err)
+	}
+	resp := httptest.NewRecorder()
+	s.r.ServeHTTP(resp, newKeySignedRequest(t, http.MethodPost, "bob_secret", "/v2/confirmtargetqty", remoteAddr, url.Values{"value": {testData}}))
+	httputil.ExpectFailure(t, resp)
+}
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+// GetConfigVersions returns all confirmed versions of a configuration type,
+// latest first.
+func (self *HTTPServer) GetConfigVersions(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{"type"}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	data, err := self.metric.GetConfigVersions(c.Query("type"))
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(data))
+}
+
+// GetConfigVersion returns the version of a configuration type which was
+// active at the given timestamp, or the given version number if version is set.
+func (self *HTTPServer) GetConfigVersion(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{"type"}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	var (
+		configType = c.Query("type")
+		data       common.ConfigVersion
+		err        error
+	)
+	if c.Query("version") != "" {
+		var version uint64
+		if version, err = strconv.ParseUint(c.Query("version"), 10, 64); err != nil {
+			httputil.ResponseFailure(c, httputil.WithError(err))
+			return
+		}
+		data, err = self.metric.GetConfigVersion(configType, version)
+	} else {
+		timestamp := common.GetTimepoint()
+		if c.Query("timestamp") != "" {
+			if timestamp, err = strconv.ParseUint(c.Query("timestamp"), 10, 64); err != nil {
+				httputil.ResponseFailure(c, httputil.WithError(err))
+				return
+			}
+		}
+		data, err = self.metric.GetConfigVersionAt(configType, timestamp)
+	}
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(data))
+}
+
+// RollbackConfig stores a previous version of a configuration as pending, it
+// is applied once confirmed with the confirm API of the configuration type.
+func (self *HTTPServer) RollbackConfig(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"type", "version"}, []Permission{ConfigurePermission})
+	if !ok {
+		return
+	}
+	configType := postForm.Get("type")
+	version, err := strconv.ParseUint(postForm.Get("version"), 10, 64)
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	previous, err := self.metric.GetConfigVersion(configType, version)
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	active, proposed, removePending, err := self.storePendingConfig(configType, previous.Data)
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err = self.proposeConfig(c, configType, previous.Data, active, proposed, false); err != nil {
+		if rErr := removePending(); rErr != nil {
+			log.Printf("failed to remove pending %s: %s", configType, rErr)
+		}
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(proposed))
+}
+
+// storePendingConfig validates and stores data as the pending configuration
+// of configType. It returns the active and the proposed configurations and
+// the function to remove the stored pending configuration.
+func (self *HTTPServer) storePendingConfig(configType string, data []byte) (interface{}, interface{}, func() error, error) {
+	var (
+		active, proposed interface{}
+		tokenIDs         []string
+		storePending     func([]byte) error
+		removePending    func() error
+		err              error
+	)
+	switch configType {
+	case common.ConfigTargetQtyV2:
+		var targetQty common.TokenTargetQtyV2
+		if err = json.Unmarshal(data, &targetQty); err != nil {
+			return nil, nil, nil, err
+		}
+		for tokenID := range targetQty {
+			tokenIDs = append(tokenIDs, tokenID)
+		}
+		proposed = targetQty
+		if active, err = self.metric.GetTargetQtyV2(); err != nil {
+			log.Printf("WARNING: There is no current target quantity in database (%s)", err)
+		}
+		storePending, removePending = self.metric.StorePendingTargetQtyV2, self.metric.RemovePendingTargetQtyV2
+	case common.ConfigPWIEquationV2:
+		var pwi common.PWIEquationRequestV2
+		if err = json.Unmarshal(data, &pwi); err != nil {
+			return nil, nil, nil, err
+		}
+		for tokenID := range pwi {
+			tokenIDs = append(tokenIDs, tokenID)
+		}
+		proposed = pwi
+		if active, err = self.metric.GetPWIEquationV2(); err != nil {
+			log.Printf("WARNING: There is no current PWI equations in database (%s)", err)
+		}
+		storePending, removePending = self.metric.StorePendingPWIEquationV2, self.metric.RemovePendingPWIEquationV2
+	case common.ConfigRebalanceQuadratic:
+		var quadratic common.RebalanceQuadraticRequest
+		if err = json.Unmarshal(data, &quadratic); err != nil {
+			return nil, nil, nil, err
+		}
+		for tokenID := range quadratic {
+			tokenIDs = append(tokenIDs, tokenID)
+		}
+		proposed = quadratic
+		if active, err = self.metric.GetRebalanceQuadratic(); err != nil {
+			log.Printf("WARNING: There is no current quadratic equation in database (%s)", err)
+		}
+		storePending, removePending = self.metric.StorePendingRebalanceQuadratic, self.metric.RemovePendingRebalanceQuadratic
+	default:
+		return nil, nil, nil, fmt.Errorf("Configuration %s is not versioned", configType)
+	}
+	for _, tokenID := range tokenIDs {
+		if _, err = self.setting.GetInternalTokenByID(tokenID); err != nil {
+			return nil, nil, nil, fmt.Errorf("TokenID: %s, error: %s", tokenID, err)
+		}
+	}
+	if err = storePending(data); err != nil {
+		return nil, nil, nil, err
+	}
+	return active, proposed, removePending, nil
+}
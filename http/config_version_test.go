@@ -0,0 +1,225 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/data/storage"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/KyberNetwork/reserve-data/settings"
+	settingsstorage "github.com/KyberNetwork/reserve-data/settings/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func TestConfigVersionRollback(t *testing.T) {
+	const (
+		firstTarget  = `{"OMG": {"set_target": {"total_target": 750, "reserve_target": 500}}}`
+		secondTarget = `{"OMG": {"set_target": {"total_target": 900, "reserve_target": 600}}}`
+	)
+
+	tmpDir, err := ioutil.TempDir("", "test_config_version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if rErr := os.RemoveAll(tmpDir); rErr != nil {
+			t.Error(rErr)
+		}
+	}()
+
+	st, err := storage.NewBoltStorage(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	boltSettingStorage, err := settingsstorage.NewBoltSettingStorage(filepath.Join(tmpDir, "setting.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenSetting, err := settings.NewTokenSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchangeSetting, err := settings.NewExchangeSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setting, err := settings.NewSetting(tokenSetting, &settings.AddressSetting{}, exchangeSetting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setting.UpdateToken(common.Token{ID: "OMG", Address: "xxx", Internal: true, Active: true}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	s := HTTPServer{
+		metric:      st,
+		authEnabled: false,
+		r:           gin.Default(),
+		setting:     setting,
+	}
+	s.r.POST("/v2/settargetqty", s.SetTargetQtyV2)
+	s.r.GET("/v2/pendingtargetqty", s.GetPendingTargetQtyV2)
+	s.r.POST("/v2/confirmtargetqty", s.ConfirmTargetQtyV2)
+	s.r.GET("/config-versions", s.GetConfigVersions)
+	s.r.GET("/config-version", s.GetConfigVersion)
+	s.r.POST("/rollback-config", s.RollbackConfig)
+
+	expectVersions := func(expected ...uint64) assertFn {
+		return func(t *testing.T, resp *httptest.ResponseRecorder) {
+			t.Helper()
+			var decoded struct {
+				Success bool                   `json:"success"`
+				Data    []common.ConfigVersion `json:"data"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !decoded.Success || len(decoded.Data) != len(expected) {
+				t.Fatalf("expected versions %v, got %+v", expected, decoded)
+			}
+			for i, version := range expected {
+				if decoded.Data[i].Version != version {
+					t.Errorf("expected versions %v, got %+v", expected, decoded.Data)
+				}
+			}
+		}
+	}
+	expectTotalTarget := func(expected float64) assertFn {
+		return func(t *testing.T, resp *httptest.ResponseRecorder) {
+			t.Helper()
+			var decoded struct {
+				Success bool                    `json:"success"`
+				Data    common.TokenTargetQtyV2 `json:"data"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !decoded.Success || decoded.Data["OMG"].SetTarget.TotalTarget != expected {
+				t.Errorf("expected total target %f, got %+v", expected, decoded)
+			}
+		}
+	}
+	expectVersionTotalTarget := func(version uint64, expected float64) assertFn {
+		return func(t *testing.T, resp *httptest.ResponseRecorder) {
+			t.Helper()
+			var decoded struct {
+				Success bool                 `json:"success"`
+				Data    common.ConfigVersion `json:"data"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+				t.Fatal(err)
+			}
+			var target common.TokenTargetQtyV2
+			if err := json.Unmarshal(decoded.Data.Data, &target); err != nil {
+				t.Fatal(err)
+			}
+			if !decoded.Success || decoded.Data.Version != version || target["OMG"].SetTarget.TotalTarget != expected {
+				t.Errorf("expected version %d with total target %f, got %+v", version, expected, decoded)
+			}
+		}
+	}
+
+	var tests = []testCase{
+		{
+			msg:      "set first target quantity",
+			endpoint: "/v2/settargetqty",
+			method:   http.MethodPost,
+			data:     map[string]string{"value": firstTarget},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "confirm first target quantity",
+			endpoint: "/v2/confirmtargetqty",
+			method:   http.MethodPost,
+			data:     map[string]string{"value": firstTarget},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "set second target quantity",
+			endpoint: "/v2/settargetqty",
+			method:   http.MethodPost,
+			data:     map[string]string{"value": secondTarget},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "confirm second target quantity",
+			endpoint: "/v2/confirmtargetqty",
+			method:   http.MethodPost,
+			data:     map[string]string{"value": secondTarget},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "list target quantity versions",
+			endpoint: "/config-versions?type=target_qty_v2",
+			method:   http.MethodGet,
+			assert:   expectVersions(2, 1),
+		},
+		{
+			msg:      "get first version",
+			endpoint: "/config-version?type=target_qty_v2&version=1",
+			method:   http.MethodGet,
+			assert:   expectVersionTotalTarget(1, 750),
+		},
+		{
+			msg:      "get current version",
+			endpoint: "/config-version?type=target_qty_v2",
+			method:   http.MethodGet,
+			assert:   expectVersionTotalTarget(2, 900),
+		},
+		{
+			msg:      "get version before the first one",
+			endpoint: "/config-version?type=target_qty_v2&timestamp=1",
+			method:   http.MethodGet,
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "rollback configuration which is not versioned",
+			endpoint: "/rollback-config",
+			method:   http.MethodPost,
+			data:     map[string]string{"type": common.ConfigStableTokenParams, "version": "1"},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "rollback to version not exists",
+			endpoint: "/rollback-config",
+			method:   http.MethodPost,
+			data:     map[string]string{"type": common.ConfigTargetQtyV2, "version": "3"},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "rollback to first version",
+			endpoint: "/rollback-config",
+			method:   http.MethodPost,
+			data:     map[string]string{"type": common.ConfigTargetQtyV2, "version": "1"},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "rollback is pending",
+			endpoint: "/v2/pendingtargetqty",
+			method:   http.MethodGet,
+			assert:   expectTotalTarget(750),
+		},
+		{
+			msg:      "confirm rollback",
+			endpoint: "/v2/confirmtargetqty",
+			method:   http.MethodPost,
+			data:     map[string]string{"value": firstTarget},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "rollback is a new version",
+			endpoint: "/config-version?type=target_qty_v2",
+			method:   http.MethodGet,
+			assert:   expectVersionTotalTarget(3, 750),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) { testHTTPRequest(t, tc, s.r) })
+	}
+}
@@ -1,15 +1,23 @@
 package http
 
 import (
+	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 // Blockchain is used in http server as the caller to blockchain for information.
-// Currently it is used for smart contract token's indice query.
+// Currently it is used for smart contract token's indice query, reading
+// the pricing configuration of tokens and verifying token contracts.
 type Blockchain interface {
 	LoadAndSetTokenIndices([]ethereum.Address) error
 	CheckTokenIndices(ethereum.Address) error
 	GetPricingOPAddress() ethereum.Address
 	GetDepositOPAddress() ethereum.Address
 	GetIntermediatorOPAddress() ethereum.Address
+	CurrentBlock() (uint64, error)
+	GetStepFunctions(token ethereum.Address, atBlock uint64) (common.StepFunctionResponse, error)
+	GetTokenControlInfo(token ethereum.Address, atBlock uint64) (common.TokenControlInfo, error)
+	GetPricingAdmin() (ethereum.Address, error)
+	CheckTokenContract(token common.Token) error
+	CheckAlerter() error
 }
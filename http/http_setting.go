@@ -12,6 +12,7 @@ type Setting interface {
 	GetTokenByID(tokenID string) (common.Token, error)
 	GetInternalTokens() ([]common.Token, error)
 	GetAllTokens() ([]common.Token, error)
+	UpdateToken(t common.Token, timestamp uint64) error
 	NewTokenPairFromID(base, quote string) (common.TokenPair, error)
 	GetFee(ex settings.ExchangeName) (common.ExchangeFees, error)
 	UpdateFee(ex settings.ExchangeName, data common.ExchangeFees, timestamp uint64) error
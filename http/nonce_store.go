@@ -0,0 +1,52 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// nonceStore keeps track of the nonces used by every signing key. A used nonce
+// is kept until it is out of the accepted time window of IsIntime, requests
+// with an older nonce are rejected anyway.
+type nonceStore struct {
+	mu     sync.Mutex
+	window uint64
+	// nonces maps a signing key to its used nonces and the time point they expire.
+	nonces map[string]map[uint64]uint64
+}
+
+func newNonceStore(window uint64) *nonceStore {
+	return &nonceStore{
+		window: window,
+		nonces: make(map[string]map[uint64]uint64),
+	}
+}
+
+// use records the nonce as used by the given key at timepoint, it returns an
+// error if the key has already used the nonce.
+func (self *nonceStore) use(key string, nonce string, timepoint uint64) error {
+	nonceInt, err := strconv.ParseUint(nonce, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid nonce %s: %s", nonce, err.Error())
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	used, ok := self.nonces[key]
+	if !ok {
+		used = make(map[uint64]uint64)
+		self.nonces[key] = used
+	}
+	// prune expired nonces of the key
+	for n, expiry := range used {
+		if expiry < timepoint {
+			delete(used, n)
+		}
+	}
+	if _, exist := used[nonceInt]; exist {
+		return fmt.Errorf("nonce %d has already been used", nonceInt)
+	}
+	used[nonceInt] = nonceInt + self.window
+	return nil
+}
@@ -0,0 +1,17 @@
+package http
+
+import (
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+// GetOutboundStats returns the latency and error metrics of outbound HTTP
+// requests by client and host.
+func (self *HTTPServer) GetOutboundStats(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(httpclient.Stats()))
+}
@@ -1,10 +1,39 @@
 package http
 
+import "fmt"
+
 type Permission int
 
 const (
-	ReadOnlyPermission    Permission = iota // can only read data
-	RebalancePermission                     // can do everything except configure setting
-	ConfigurePermission                     // can read data and configure setting, cannot set rates, deposit, withdraw, trade, cancel activities
-	ConfirmConfPermission                   // can read data and confirm configuration proposal
+	ReadOnlyPermission     Permission = iota // can only read data
+	RebalancePermission                      // can do everything except configure setting
+	ConfigurePermission                      // can read data and configure setting, cannot set rates, deposit, withdraw, trade, cancel activities
+	ConfirmConfPermission                    // can read data and confirm configuration proposal
+	ForceSetRatePermission                   // can set rates overriding the set rate guard
+	EmergencyPermission                      // can zero rates and disable the trade of tokens in emergency
+	AdminPermission                          // can manage API keys, only keys loaded from config file can have it
 )
+
+// permissionNames maps the names used in API key configuration to permissions.
+var permissionNames = map[string]Permission{
+	"read_only":             ReadOnlyPermission,
+	"rebalance":             RebalancePermission,
+	"configure":             ConfigurePermission,
+	"confirm_configuration": ConfirmConfPermission,
+	"force_set_rates":       ForceSetRatePermission,
+	"emergency":             EmergencyPermission,
+	"admin":                 AdminPermission,
+}
+
+// permissionsFromNames returns the permissions of given names.
+func permissionsFromNames(names []string) ([]Permission, error) {
+	var result []Permission
+	for _, name := range names {
+		perm, ok := permissionNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown permission %s", name)
+		}
+		result = append(result, perm)
+	}
+	return result, nil
+}
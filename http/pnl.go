@@ -0,0 +1,44 @@
+package http
+
+import (
+	"strconv"
+
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/KyberNetwork/reserve-data/pnl"
+	"github.com/gin-gonic/gin"
+)
+
+// PnL computes the profit and loss of the reserve, it is implemented by
+// pnl.Calculator.
+type PnL interface {
+	Compute(fromTime, toTime uint64, timezone int64) (pnl.Report, error)
+}
+
+// SetPnL enables the P&L API, it must be called before Run.
+func (self *HTTPServer) SetPnL(calculator PnL) {
+	self.pnl = calculator
+}
+
+// GetPnL returns the P&L of the reserve by tokens in daily buckets of the
+// timeZone param.
+func (self *HTTPServer) GetPnL(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	fromTime, toTime, ok := self.ValidateTimeInput(c)
+	if !ok {
+		return
+	}
+	tzparam, _ := strconv.ParseInt(c.Query("timeZone"), 10, 64)
+	if (tzparam < startTimezone) || (tzparam > endTimezone) {
+		httputil.ResponseFailure(c, httputil.WithReason("Timezone is not supported"))
+		return
+	}
+	report, err := self.pnl.Compute(fromTime, toTime, tzparam)
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(report))
+}
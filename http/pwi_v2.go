@@ -3,6 +3,7 @@ package http
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/http/httputil"
@@ -46,10 +47,22 @@ func (self *HTTPServer) SetPWIEquationV2(c *gin.Context) {
 	for tokenID := range input {
 		if _, err := self.setting.GetInternalTokenByID(tokenID); err != nil {
 			httputil.ResponseFailure(c, httputil.WithReason(fmt.Sprintf("Token %s is unsupported", tokenID)))
+			return
 		}
 	}
 
-	if err := self.metric.StorePendingPWIEquationV2(data); err != nil {
+	active, err := self.metric.GetPWIEquationV2()
+	if err != nil {
+		log.Printf("WARNING: There is no current PWI equations in database (%s)", err)
+	}
+	if err = self.metric.StorePendingPWIEquationV2(data); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err = self.proposeConfig(c, common.ConfigPWIEquationV2, data, active, input, false); err != nil {
+		if rErr := self.metric.RemovePendingPWIEquationV2(); rErr != nil {
+			log.Printf("failed to remove pending PWI equations: %s", rErr)
+		}
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
@@ -79,11 +92,20 @@ func (self *HTTPServer) ConfirmPWIEquationV2(c *gin.Context) {
 		return
 	}
 	postData := postForm.Get(dataPostFormKey)
+	if err := self.checkTokenChangeInProgress(); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err := self.checkConfirmer(c, common.ConfigPWIEquationV2); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
 	err := self.metric.StorePWIEquationV2(postData)
 	if err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
+	self.logResolveConfig(c, common.ConfigPWIEquationV2, common.ConfigProposalConfirmed)
 	httputil.ResponseSuccess(c)
 }
 
@@ -99,5 +121,6 @@ func (self *HTTPServer) RejectPWIEquationV2(c *gin.Context) {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
+	self.logResolveConfig(c, common.ConfigPWIEquationV2, common.ConfigProposalRejected)
 	httputil.ResponseSuccess(c)
 }
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/KyberNetwork/reserve-data/rateengine"
+	"github.com/gin-gonic/gin"
+)
+
+// RateEngine computes rates from order books, balances and PWI equations,
+// it is implemented by rateengine.Engine.
+type RateEngine interface {
+	Compute(timepoint uint64) (rateengine.Rates, error)
+	Submit(rates rateengine.Rates, keyID string) (common.ActivityID, error)
+}
+
+// SetRateEngine enables the rate engine APIs, it must be called before Run.
+func (self *HTTPServer) SetRateEngine(engine RateEngine) {
+	self.rateEngine = engine
+}
+
+// GetRateEngineRates returns the rates computed from the latest data without
+// setting them.
+func (self *HTTPServer) GetRateEngineRates(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	rates, err := self.rateEngine.Compute(common.GetTimepoint())
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(rates))
+}
+
+// SetRateEngineRates computes the rates from the latest data and sets them
+// on the reserve contract.
+func (self *HTTPServer) SetRateEngineRates(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{RebalancePermission})
+	if !ok {
+		return
+	}
+	rates, err := self.rateEngine.Compute(common.GetTimepoint())
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	id, err := self.rateEngine.Submit(rates, getKeyID(c))
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithField("id", id), httputil.WithField("rates", rates))
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+// checkRateGuard returns error if a max deviation is not in [0, 1), a
+// token is not an internal token or the guard is enabled without max price
+// age.
+func (self *HTTPServer) checkRateGuard(guard common.SetRateGuard) error {
+	enabled := guard.MaxDeviation > 0
+	if guard.MaxDeviation < 0 || guard.MaxDeviation >= 1 {
+		return fmt.Errorf("Max deviation %f is not in [0, 1)", guard.MaxDeviation)
+	}
+	for tokenID, deviation := range guard.TokenMaxDeviation {
+		if _, err := self.setting.GetInternalTokenByID(tokenID); err != nil {
+			return fmt.Errorf("Getting token %s got err %s", tokenID, err.Error())
+		}
+		if deviation < 0 || deviation >= 1 {
+			return fmt.Errorf("Max deviation %f of %s is not in [0, 1)", deviation, tokenID)
+		}
+		enabled = enabled || deviation > 0
+	}
+	if enabled && guard.MaxPriceAge == 0 {
+		return errors.New("Max price age is required")
+	}
+	return nil
+}
+
+// pendingRateGuard returns the configuration of the pending set rate
+// guard proposal.
+func (self *HTTPServer) pendingRateGuard() (common.SetRateGuard, error) {
+	var guard common.SetRateGuard
+	proposals, err := self.metric.GetPendingConfigProposals(common.ConfigSetRateGuard)
+	if err != nil {
+		return guard, err
+	}
+	if len(proposals) == 0 {
+		return guard, errors.New("There is no pending set rate guard")
+	}
+	// a new proposal supersedes the pending one, take the latest in case
+	proposal := proposals[0]
+	for _, p := range proposals[1:] {
+		if p.ID > proposal.ID {
+			proposal = p
+		}
+	}
+	err = json.Unmarshal(proposal.Data, &guard)
+	return guard, err
+}
+
+// GetRateGuard returns the current set rate guard.
+func (self *HTTPServer) GetRateGuard(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, ConfigurePermission, ConfirmConfPermission, RebalancePermission})
+	if !ok {
+		return
+	}
+	data, err := self.metric.GetSetRateGuard()
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(data))
+}
+
+// SetRateGuard proposes a new set rate guard, it replaces the current one
+// after confirmed.
+// input data follow json:
+// {"max_deviation": 0.1, "token_max_deviation": {"KNC": 0.05}, "max_price_age": 60000, "clamp": false, "allow_missing_price": false}
+func (self *HTTPServer) SetRateGuard(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"value"}, []Permission{ConfigurePermission})
+	if !ok {
+		return
+	}
+	value := []byte(postForm.Get("value"))
+	if len(value) > maxDataSize {
+		httputil.ResponseFailure(c, httputil.WithReason(errDataSizeExceed.Error()))
+		return
+	}
+	var guard common.SetRateGuard
+	if err := json.Unmarshal(value, &guard); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err := self.checkRateGuard(guard); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	active, err := self.metric.GetSetRateGuard()
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err = self.proposeConfig(c, common.ConfigSetRateGuard, value, active, guard, false); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c)
+}
+
+// GetPendingRateGuard returns the pending set rate guard.
+func (self *HTTPServer) GetPendingRateGuard(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, ConfigurePermission, ConfirmConfPermission, RebalancePermission})
+	if !ok {
+		return
+	}
+	data, err := self.pendingRateGuard()
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(data))
+}
+
+// ConfirmRateGuard confirms the pending set rate guard, the value must be
+// the same as the pending one.
+func (self *HTTPServer) ConfirmRateGuard(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"value"}, []Permission{ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	value := []byte(postForm.Get("value"))
+	if len(value) > maxDataSize {
+		httputil.ResponseFailure(c, httputil.WithReason(errDataSizeExceed.Error()))
+		return
+	}
+	pending, err := self.pendingRateGuard()
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	var confirm common.SetRateGuard
+	if err = json.Unmarshal(value, &confirm); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if !reflect.DeepEqual(pending, confirm) {
+		httputil.ResponseFailure(c, httputil.WithReason("confirm data does not match set rate guard pending data"))
+		return
+	}
+	if err = self.checkConfirmer(c, common.ConfigSetRateGuard); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err = self.metric.StoreSetRateGuard(pending); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	self.logResolveConfig(c, common.ConfigSetRateGuard, common.ConfigProposalConfirmed)
+	httputil.ResponseSuccess(c)
+}
+
+// RejectRateGuard rejects the pending set rate guard.
+func (self *HTTPServer) RejectRateGuard(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	if _, err := self.pendingRateGuard(); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err := self.resolveConfig(c, common.ConfigSetRateGuard, common.ConfigProposalRejected); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c)
+}
@@ -0,0 +1,231 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/core"
+	"github.com/KyberNetwork/reserve-data/data"
+	"github.com/KyberNetwork/reserve-data/data/storage"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/KyberNetwork/reserve-data/settings"
+	settingstorage "github.com/KyberNetwork/reserve-data/settings/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHTTPServerRateGuard(t *testing.T) {
+	const (
+		getRateGuard        = "/rate-guard"
+		getPendingRateGuard = "/pending-rate-guard"
+		setRateGuard        = "/set-rate-guard"
+		confirmRateGuard    = "/confirm-rate-guard"
+		rejectRateGuard     = "/reject-rate-guard"
+		setRates            = "/setrates"
+		testData            = `{"max_deviation": 0.1, "token_max_deviation": {"KNC": 0.05}, "max_price_age": 60000}`
+		testDataNoPriceAge  = `{"max_deviation": 0.1}`
+		testDataUnsupported = `{"token_max_deviation": {"OMG": 0.05}, "max_price_age": 60000}`
+		testDataDeviation   = `{"max_deviation": 1.5, "max_price_age": 60000}`
+	)
+
+	tmpDir, err := ioutil.TempDir("", "test_rate_guard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if rErr := os.RemoveAll(tmpDir); rErr != nil {
+			t.Error(rErr)
+		}
+	}()
+	boltSettingStorage, err := settingstorage.NewBoltSettingStorage(filepath.Join(tmpDir, "setting.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenSetting, err := settings.NewTokenSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchangeSetting, err := settings.NewExchangeSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setting, err := settings.NewSetting(tokenSetting, &settings.AddressSetting{}, exchangeSetting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setting.UpdateToken(common.NewToken("KNC", "KyberNetwork", "0xdd974d5c2e2928dea5f71b9825b8b646686bd200", 18, true, true, 0), 0); err != nil {
+		t.Fatal(err)
+	}
+	testStorage, err := storage.NewBoltStorage(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nonce uint64
+	rCore := core.NewReserveCore(testPricingBlockchain{nonce: &nonce}, testStorage, setting)
+	rCore.EnableRateGuard(testStorage, testStorage)
+	s := HTTPServer{
+		app:         data.NewReserveData(testStorage, nil, nil, nil, nil, nil, setting),
+		core:        rCore,
+		metric:      testStorage,
+		authEnabled: false,
+		r:           gin.Default(),
+		blockchain:  testHTTPBlockchain{},
+		setting:     setting,
+	}
+	s.register()
+
+	// rates of KNC at 0.002 ETH without order books
+	rates := map[string]string{
+		"tokens":  "KNC",
+		"buys":    "0x19d0786b2145710000",
+		"sells":   "0x6c00a3912c000",
+		"block":   "1",
+		"afp_mid": "0x71afd498d0000",
+		"msgs":    "test",
+	}
+	forcedRates := map[string]string{"force": "true"}
+	for k, v := range rates {
+		forcedRates[k] = v
+	}
+
+	assertGuard := func(t *testing.T, resp *httptest.ResponseRecorder) {
+		var result struct {
+			Success bool                `json:"success"`
+			Data    common.SetRateGuard `json:"data"`
+		}
+		if dErr := json.NewDecoder(resp.Body).Decode(&result); dErr != nil {
+			t.Fatal(dErr)
+		}
+		if !result.Success || result.Data.MaxDeviationOf("KNC") != 0.05 || result.Data.MaxPriceAge != 60000 {
+			t.Errorf("Unexpected response %+v", result)
+		}
+	}
+
+	assertForced := func(t *testing.T, resp *httptest.ResponseRecorder) {
+		httputil.ExpectSuccess(t, resp)
+		pendings, pErr := testStorage.GetPendingActivities()
+		if pErr != nil {
+			t.Fatal(pErr)
+		}
+		var forced []common.ActivityRecord
+		for _, act := range pendings {
+			if act.Action == common.ActionSetrate && act.Params["force"] == true {
+				forced = append(forced, act)
+			}
+		}
+		if len(forced) != 1 {
+			t.Fatalf("Expected a forced set rates activity, got %+v", pendings)
+		}
+		if guard, ok := forced[0].Params["guard"].([]interface{}); !ok || len(guard) == 0 {
+			t.Errorf("Expected the guard violations recorded, got %v", forced[0].Params["guard"])
+		}
+	}
+
+	var tests = []testCase{
+		{
+			msg:      "set rates without guard",
+			endpoint: setRates,
+			method:   http.MethodPost,
+			data:     rates,
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "getting non exists pending rate guard",
+			endpoint: getPendingRateGuard,
+			method:   http.MethodGet,
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "missing max price age",
+			endpoint: setRateGuard,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testDataNoPriceAge},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "unsupported token",
+			endpoint: setRateGuard,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testDataUnsupported},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "invalid max deviation",
+			endpoint: setRateGuard,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testDataDeviation},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "valid post form to reject",
+			endpoint: setRateGuard,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testData},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "reject when there is pending rate guard",
+			endpoint: rejectRateGuard,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": "some random post form or this request will be unauthenticated"},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "valid post form",
+			endpoint: setRateGuard,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testData},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "getting exists pending rate guard",
+			endpoint: getPendingRateGuard,
+			method:   http.MethodGet,
+			assert:   assertGuard,
+		},
+		{
+			msg:      "confirm with wrong data",
+			endpoint: confirmRateGuard,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testDataNoPriceAge},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "confirm with correct data",
+			endpoint: confirmRateGuard,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testData},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "get confirmed rate guard",
+			endpoint: getRateGuard,
+			method:   http.MethodGet,
+			assert:   assertGuard,
+		},
+		{
+			msg:      "set rates without order book",
+			endpoint: setRates,
+			method:   http.MethodPost,
+			data:     rates,
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "force set rates without order book",
+			endpoint: setRates,
+			method:   http.MethodPost,
+			data:     forcedRates,
+			assert:   assertForced,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) { testHTTPRequest(t, tc, s.r) })
+	}
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"github.com/KyberNetwork/reserve-data/exchange/ratelimit"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter throttles the requests to an exchange, it is implemented by
+// ratelimit.Limiter.
+type RateLimiter interface {
+	Usage() ratelimit.Usage
+}
+
+// SetRateLimiters enables the rate limits API, it must be called before Run.
+func (self *HTTPServer) SetRateLimiters(limiters []RateLimiter) {
+	self.rateLimiters = limiters
+}
+
+// GetRateLimits returns the current request weight usage of exchanges.
+func (self *HTTPServer) GetRateLimits(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	result := []ratelimit.Usage{}
+	for _, limiter := range self.rateLimiters {
+		result = append(result, limiter.Usage())
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(result))
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/KyberNetwork/reserve-data/watchdog"
+	"github.com/gin-gonic/gin"
+)
+
+// Actions of the emergency stop.
+const (
+	emergencyZeroRates    = "zero_rates"
+	emergencyDisableTrade = "disable_trade"
+)
+
+// RateWatchdog provides the status of the rates in pricing contract.
+type RateWatchdog interface {
+	LastStatus() *watchdog.Status
+}
+
+// SetRateWatchdog enables the rate watchdog API, it must be called before
+// Run.
+func (self *HTTPServer) SetRateWatchdog(wd RateWatchdog) {
+	self.watchdog = wd
+}
+
+// GetRateWatchdog returns the status of the last check of the rate watchdog.
+func (self *HTTPServer) GetRateWatchdog(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	status := self.watchdog.LastStatus()
+	if status == nil {
+		httputil.ResponseFailure(c, httputil.WithReason("Rate watchdog has not checked yet"))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(status))
+}
+
+// emergencyResult is the activity of an emergency stop action.
+type emergencyResult struct {
+	Action string            `json:"action"`
+	Token  string            `json:"token,omitempty"`
+	ID     common.ActivityID `json:"id"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// zeroRates holds set rates so they are not set again then sets zero rates
+// for all internal tokens at the current block.
+func (self *HTTPServer) zeroRates(keyID string) ([]emergencyResult, error) {
+	holdErr := self.metric.StoreSetrateControl(false)
+	if holdErr != nil {
+		log.Printf("Emergency stop couldn't hold set rates: %s", holdErr)
+	}
+	internals, err := self.setting.GetInternalTokens()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		tokens            []common.Token
+		buys, sells, mids []*big.Int
+		msgs              []string
+	)
+	for _, token := range internals {
+		if token.IsETH() {
+			continue
+		}
+		tokens = append(tokens, token)
+		buys = append(buys, big.NewInt(0))
+		sells = append(sells, big.NewInt(0))
+		mids = append(mids, big.NewInt(0))
+		msgs = append(msgs, "emergency stop")
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("There is no internal token")
+	}
+	block, err := self.blockchain.CurrentBlock()
+	if err != nil {
+		return nil, err
+	}
+	id, err := self.core.SetRates(tokens, buys, sells, new(big.Int).SetUint64(block), mids, msgs, keyID)
+	result := []emergencyResult{{Action: common.ActionSetrate, ID: id, Error: common.ErrorToString(err)}}
+	if err != nil {
+		return result, err
+	}
+	if holdErr != nil {
+		return result, fmt.Errorf("Zero rates are set but set rates couldn't be held: %s", holdErr)
+	}
+	return result, nil
+}
+
+// disableTrade disables the trade of tokens, a failed token doesn't stop the
+// others.
+func (self *HTTPServer) disableTrade(tokenIDs []string, keyID string) ([]emergencyResult, error) {
+	var tokens []common.Token
+	for _, tokenID := range tokenIDs {
+		token, err := self.setting.GetInternalTokenByID(tokenID)
+		if err != nil {
+			return nil, fmt.Errorf("Getting token %s got err %s", tokenID, err.Error())
+		}
+		if token.IsETH() {
+			return nil, errors.New("ETH trade can't be disabled")
+		}
+		tokens = append(tokens, token)
+	}
+	var (
+		results []emergencyResult
+		failed  []string
+	)
+	for _, token := range tokens {
+		id, err := self.core.DisableTokenTrade(token, keyID)
+		results = append(results, emergencyResult{
+			Action: common.ActionDisableTokenTrade,
+			Token:  token.ID,
+			ID:     id,
+			Error:  common.ErrorToString(err),
+		})
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", token.ID, err))
+		}
+	}
+	if len(failed) != 0 {
+		return results, fmt.Errorf("Disabling trade failed for %s", strings.Join(failed, "; "))
+	}
+	return results, nil
+}
+
+// EmergencyStop stops the reserve from trading: zero_rates action holds set
+// rates and sets zero rates for all internal tokens, disable_trade action
+// disables the trade of the tokens in pricing contract by the alerter, it
+// fails if the alerter operator is not an alerter of pricing contract.
+func (self *HTTPServer) EmergencyStop(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"action"}, []Permission{EmergencyPermission})
+	if !ok {
+		return
+	}
+	var (
+		results []emergencyResult
+		err     error
+	)
+	switch action := postForm.Get("action"); action {
+	case emergencyZeroRates:
+		results, err = self.zeroRates(getKeyID(c))
+	case emergencyDisableTrade:
+		tokens := postForm.Get("tokens")
+		if tokens == "" {
+			httputil.ResponseFailure(c, httputil.WithReason("Tokens are required to disable trade"))
+			return
+		}
+		// the transactions by a signer not allowed would be reverted
+		if err = self.blockchain.CheckAlerter(); err != nil {
+			httputil.ResponseFailure(c, httputil.WithReason(fmt.Sprintf("Can not disable trade (%s)", err.Error())))
+			return
+		}
+		results, err = self.disableTrade(strings.Split(tokens, "-"), getKeyID(c))
+	default:
+		httputil.ResponseFailure(c, httputil.WithReason(fmt.Sprintf("Unknown emergency action %q", action)))
+		return
+	}
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err), httputil.WithData(results))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(results))
+}
@@ -0,0 +1,216 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/core"
+	"github.com/KyberNetwork/reserve-data/data"
+	"github.com/KyberNetwork/reserve-data/data/storage"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/KyberNetwork/reserve-data/settings"
+	settingstorage "github.com/KyberNetwork/reserve-data/settings/storage"
+	"github.com/KyberNetwork/reserve-data/watchdog"
+	"github.com/gin-gonic/gin"
+)
+
+type testRateWatchdog struct {
+	status *watchdog.Status
+}
+
+func (self testRateWatchdog) LastStatus() *watchdog.Status {
+	return self.status
+}
+
+// testAlerterBlockchain fails the alerter check with err.
+type testAlerterBlockchain struct {
+	testHTTPBlockchain
+	err *error
+}
+
+func (self testAlerterBlockchain) CheckAlerter() error {
+	return *self.err
+}
+
+func TestHTTPServerEmergencyStop(t *testing.T) {
+	const (
+		getRateWatchdog = "/rate-watchdog"
+		emergencyStop   = "/emergency-stop"
+	)
+
+	tmpDir, err := ioutil.TempDir("", "test_emergency_stop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if rErr := os.RemoveAll(tmpDir); rErr != nil {
+			t.Error(rErr)
+		}
+	}()
+	boltSettingStorage, err := settingstorage.NewBoltSettingStorage(filepath.Join(tmpDir, "setting.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenSetting, err := settings.NewTokenSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchangeSetting, err := settings.NewExchangeSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setting, err := settings.NewSetting(tokenSetting, &settings.AddressSetting{}, exchangeSetting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []common.Token{
+		common.NewToken("ETH", "Ethereum", "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", 18, true, true, 0),
+		common.NewToken("KNC", "KyberNetwork", "0xdd974d5c2e2928dea5f71b9825b8b646686bd200", 18, true, true, 0),
+		common.NewToken("OMG", "OmiseGO", "0xd26114cd6ee289accf82350c8d8487fedb8a0c07", 18, true, true, 0),
+	} {
+		if err = setting.UpdateToken(token, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testStorage, err := storage.NewBoltStorage(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = testStorage.StoreSetrateControl(true); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		nonce      uint64
+		alerterErr = errors.New("no alerter")
+	)
+	s := HTTPServer{
+		app:         data.NewReserveData(testStorage, nil, nil, nil, nil, nil, setting),
+		core:        core.NewReserveCore(testPricingBlockchain{nonce: &nonce}, testStorage, setting),
+		metric:      testStorage,
+		authEnabled: false,
+		r:           gin.Default(),
+		blockchain:  testAlerterBlockchain{err: &alerterErr},
+		setting:     setting,
+	}
+	s.SetRateWatchdog(testRateWatchdog{status: &watchdog.Status{CurrentBlock: 1000, Tokens: []watchdog.TokenStatus{
+		{Token: "KNC", RateUpdateBlock: 905, ExpiryBlock: 1005, BlocksLeft: 5},
+	}}})
+	s.register()
+
+	// pendingActivities returns the pending activities of action.
+	pendingActivities := func(t *testing.T, action string) []common.ActivityRecord {
+		pendings, pErr := testStorage.GetPendingActivities()
+		if pErr != nil {
+			t.Fatal(pErr)
+		}
+		var result []common.ActivityRecord
+		for _, act := range pendings {
+			if act.Action == action {
+				result = append(result, act)
+			}
+		}
+		return result
+	}
+
+	testHTTPRequest(t, testCase{
+		msg:      "disable trade without alerter",
+		endpoint: emergencyStop,
+		method:   http.MethodPost,
+		data:     map[string]string{"action": "disable_trade", "tokens": "KNC"},
+		assert: func(t *testing.T, resp *httptest.ResponseRecorder) {
+			httputil.ExpectFailure(t, resp)
+			if disabled := pendingActivities(t, common.ActionDisableTokenTrade); len(disabled) != 0 {
+				t.Errorf("Expected no disable token trade activity, got %+v", disabled)
+			}
+		},
+	}, s.r)
+	alerterErr = nil
+
+	var tests = []testCase{
+		{
+			msg:      "get rate watchdog status",
+			endpoint: getRateWatchdog,
+			method:   http.MethodGet,
+			assert: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				var result struct {
+					Success bool            `json:"success"`
+					Data    watchdog.Status `json:"data"`
+				}
+				if dErr := json.NewDecoder(resp.Body).Decode(&result); dErr != nil {
+					t.Fatal(dErr)
+				}
+				if !result.Success || len(result.Data.Tokens) != 1 || result.Data.Tokens[0].BlocksLeft != 5 {
+					t.Errorf("Unexpected response %+v", result)
+				}
+			},
+		},
+		{
+			msg:      "unknown action",
+			endpoint: emergencyStop,
+			method:   http.MethodPost,
+			data:     map[string]string{"action": "stop"},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "disable trade without tokens",
+			endpoint: emergencyStop,
+			method:   http.MethodPost,
+			data:     map[string]string{"action": "disable_trade"},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "disable trade of ETH",
+			endpoint: emergencyStop,
+			method:   http.MethodPost,
+			data:     map[string]string{"action": "disable_trade", "tokens": "KNC-ETH"},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "disable trade",
+			endpoint: emergencyStop,
+			method:   http.MethodPost,
+			data:     map[string]string{"action": "disable_trade", "tokens": "KNC-OMG"},
+			assert: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				httputil.ExpectSuccess(t, resp)
+				if disabled := pendingActivities(t, common.ActionDisableTokenTrade); len(disabled) != 2 {
+					t.Errorf("Expected 2 disable token trade activities, got %+v", disabled)
+				}
+			},
+		},
+		{
+			msg:      "zero rates",
+			endpoint: emergencyStop,
+			method:   http.MethodPost,
+			data:     map[string]string{"action": "zero_rates"},
+			assert: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				httputil.ExpectSuccess(t, resp)
+				setRates := pendingActivities(t, common.ActionSetrate)
+				if len(setRates) != 1 {
+					t.Fatalf("Expected a set rates activity, got %+v", setRates)
+				}
+				if tokens, ok := setRates[0].Params["tokens"].([]interface{}); !ok || len(tokens) != 2 {
+					t.Errorf("Expected zero rates of KNC and OMG, got %v", setRates[0].Params["tokens"])
+				}
+				control, cErr := testStorage.GetSetrateControl()
+				if cErr != nil {
+					t.Fatal(cErr)
+				}
+				if control.Status {
+					t.Error("Expected set rates on hold")
+				}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) { testHTTPRequest(t, tc, s.r) })
+	}
+}
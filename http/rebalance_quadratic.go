@@ -3,6 +3,7 @@ package http
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/http/httputil"
@@ -41,7 +42,18 @@ func (h *HTTPServer) SetRebalanceQuadratic(c *gin.Context) {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
-	if err := h.metric.StorePendingRebalanceQuadratic(value); err != nil {
+	active, err := h.metric.GetRebalanceQuadratic()
+	if err != nil {
+		log.Printf("WARNING: There is no current quadratic equation in database (%s)", err)
+	}
+	if err = h.metric.StorePendingRebalanceQuadratic(value); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err = h.proposeConfig(c, common.ConfigRebalanceQuadratic, value, active, rq, false); err != nil {
+		if rErr := h.metric.RemovePendingRebalanceQuadratic(); rErr != nil {
+			log.Printf("failed to remove pending rebalance quadratic equation: %s", rErr)
+		}
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
@@ -75,11 +87,20 @@ func (h *HTTPServer) ConfirmRebalanceQuadratic(c *gin.Context) {
 		httputil.ResponseFailure(c, httputil.WithReason(errDataSizeExceed.Error()))
 		return
 	}
+	if err := h.checkTokenChangeInProgress(); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err := h.checkConfirmer(c, common.ConfigRebalanceQuadratic); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
 	err := h.metric.ConfirmRebalanceQuadratic(value)
 	if err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
+	h.logResolveConfig(c, common.ConfigRebalanceQuadratic, common.ConfigProposalConfirmed)
 	httputil.ResponseSuccess(c)
 }
 
@@ -93,6 +114,7 @@ func (h *HTTPServer) RejectRebalanceQuadratic(c *gin.Context) {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
+	h.logResolveConfig(c, common.ConfigRebalanceQuadratic, common.ConfigProposalRejected)
 	httputil.ResponseSuccess(c)
 }
 
@@ -29,6 +29,10 @@ const (
 	maxDataSize   int    = 1000000 //1 Megabyte in byte
 	startTimezone int64  = -11
 	endTimezone   int64  = 14
+
+	// maxNonceDifference is the maximum difference in millisecond between
+	// nonce of a signed request and server time.
+	maxNonceDifference int64 = 30000
 )
 
 var (
@@ -47,6 +51,20 @@ type HTTPServer struct {
 	r           *gin.Engine
 	blockchain  Blockchain
 	setting     Setting
+	rateEngine  RateEngine
+	watchdog    RateWatchdog
+	pnl         PnL
+	// rateLimiters are the request limiters of exchanges
+	rateLimiters []RateLimiter
+	// listingTokens is 1 while the tokens of a confirmed token update are
+	// being listed in pricing contract.
+	listingTokens int32
+	// delistingTokens is 1 while the tokens of a confirmed token delisting
+	// are being delisted.
+	delistingTokens int32
+	// confirmingTokenPricing is 1 while a confirmed token pricing
+	// configuration is being submitted to pricing contract.
+	confirmingTokenPricing int32
 }
 
 func getTimePoint(c *gin.Context, useDefault bool) uint64 {
@@ -81,7 +99,7 @@ func IsIntime(nonce string) bool {
 		return false
 	}
 	difference := nonceInt - int64(serverTime)
-	if difference < -30000 || difference > 30000 {
+	if difference < -maxNonceDifference || difference > maxNonceDifference {
 		log.Printf("IsIntime returns false, nonce: %d, serverTime: %d, difference: %d", nonceInt, int64(serverTime), difference)
 		return false
 	}
@@ -99,10 +117,26 @@ func eligible(ups, allowedPerms []Permission) bool {
 	return false
 }
 
+// redactedParams returns a copy of params with the secrets hidden for logging.
+func redactedParams(params url.Values) url.Values {
+	result := url.Values{}
+	for name, values := range params {
+		if name == "secret" {
+			values = []string{"<redacted>"}
+		}
+		result[name] = values
+	}
+	return result
+}
+
 // Authenticated signed message (message = url encoded both query params and post params, keys are sorted) in "signed" header
 // using HMAC512
 // params must contain "nonce" which is the unixtime in millisecond. The nonce will be invalid
-// if it differs from server time more than 10s
+// if it differs from server time more than 30s.
+// If replay protection is enabled, HTTP method and path are included in signed message
+// and a nonce can only be used once by each key.
+// The request is accepted if one of the keys signing it is allowed to make the request,
+// the ID of the key is kept in the context for getKeyID.
 func (self *HTTPServer) Authenticated(c *gin.Context, requiredParams []string, perms []Permission) (url.Values, bool) {
 	err := c.Request.ParseForm()
 	if err != nil {
@@ -115,7 +149,7 @@ func (self *HTTPServer) Authenticated(c *gin.Context, requiredParams []string, p
 	}
 
 	params := c.Request.Form
-	log.Printf("Form params: %s\n", params)
+	log.Printf("Form params: %s\n", redactedParams(params))
 	if !IsIntime(params.Get("nonce")) {
 		httputil.ResponseFailure(c, httputil.WithReason("Your nonce is invalid"))
 		return c.Request.Form, false
@@ -129,18 +163,26 @@ func (self *HTTPServer) Authenticated(c *gin.Context, requiredParams []string, p
 	}
 
 	signed := c.GetHeader("signed")
-	message := c.Request.Form.Encode()
-	userPerms := self.auth.GetPermission(signed, message)
-	if eligible(userPerms, perms) {
-		return params, true
-	} else {
-		if len(userPerms) == 0 {
-			httputil.ResponseFailure(c, httputil.WithReason("Invalid signed token"))
-		} else {
-			httputil.ResponseFailure(c, httputil.WithReason("You don't have permission to proceed"))
-		}
+	message := self.auth.RequestMessage(c.Request.Method, c.Request.URL.Path, c.Request.Form.Encode())
+	keys := self.auth.GetKeys(signed, message)
+	if len(keys) == 0 {
+		httputil.ResponseFailure(c, httputil.WithReason("Invalid signed token"))
 		return params, false
 	}
+	for _, key := range keys {
+		if err = authorize(c, params, key, perms); err != nil {
+			continue
+		}
+		if err = self.auth.UseNonce(key.ID, params.Get("nonce")); err != nil {
+			httputil.ResponseFailure(c, httputil.WithError(err))
+			return params, false
+		}
+		log.Printf("Request is signed by key %s", key.ID)
+		c.Set(keyIDContextKey, key.ID)
+		return params, true
+	}
+	httputil.ResponseFailure(c, httputil.WithError(err))
+	return params, false
 }
 
 func (self *HTTPServer) AllPricesVersion(c *gin.Context) {
@@ -252,8 +294,15 @@ func (self *HTTPServer) GetRate(c *gin.Context) {
 	}
 }
 
+// SetRate sets rates checked by the set rate guard of core, with force=true
+// the guard is overridden and the key needs the force set rates permission.
 func (self *HTTPServer) SetRate(c *gin.Context) {
-	postForm, ok := self.Authenticated(c, []string{"tokens", "buys", "sells", "block", "afp_mid", "msgs"}, []Permission{RebalancePermission})
+	perms := []Permission{RebalancePermission}
+	force := c.PostForm("force") == "true"
+	if force {
+		perms = []Permission{ForceSetRatePermission}
+	}
+	postForm, ok := self.Authenticated(c, []string{"tokens", "buys", "sells", "block", "afp_mid", "msgs"}, perms)
 	if !ok {
 		return
 	}
@@ -304,7 +353,11 @@ func (self *HTTPServer) SetRate(c *gin.Context) {
 		}
 		bigAfpMid = append(bigAfpMid, r)
 	}
-	id, err := self.core.SetRates(tokens, bigBuys, bigSells, big.NewInt(intBlock), bigAfpMid, msgs)
+	setRates := self.core.SetRates
+	if force {
+		setRates = self.core.ForceSetRates
+	}
+	id, err := setRates(tokens, bigBuys, bigSells, big.NewInt(intBlock), bigAfpMid, msgs, getKeyID(c))
 	if err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
@@ -356,7 +409,7 @@ func (self *HTTPServer) Trade(c *gin.Context) {
 		return
 	}
 	id, done, remaining, finished, err := self.core.Trade(
-		exchange, typeParam, base, quote, rate, amount, getTimePoint(c, false))
+		exchange, typeParam, base, quote, rate, amount, getTimePoint(c, false), getKeyID(c))
 	if err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
@@ -423,7 +476,7 @@ func (self *HTTPServer) Withdraw(c *gin.Context) {
 		return
 	}
 	log.Printf("Withdraw %s %s from %s\n", amount.Text(10), token.ID, exchange.ID())
-	id, err := self.core.Withdraw(exchange, token, amount, getTimePoint(c, false))
+	id, err := self.core.Withdraw(exchange, token, amount, getTimePoint(c, false), getKeyID(c))
 	if err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
@@ -457,7 +510,7 @@ func (self *HTTPServer) Deposit(c *gin.Context) {
 		return
 	}
 	log.Printf("Depositing %s %s to %s\n", amount.Text(10), token.ID, exchange.ID())
-	id, err := self.core.Deposit(exchange, token, amount, getTimePoint(c, false))
+	id, err := self.core.Deposit(exchange, token, amount, getTimePoint(c, false), getKeyID(c))
 	if err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
@@ -737,6 +790,7 @@ func (self *HTTPServer) GetTradeHistory(c *gin.Context) {
 	data, err := self.app.GetTradeHistory(fromTime, toTime)
 	if err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
 	}
 	httputil.ResponseSuccess(c, httputil.WithData(data))
 }
@@ -1257,11 +1311,22 @@ func (self *HTTPServer) SetStableTokenParams(c *gin.Context) {
 		httputil.ResponseFailure(c, httputil.WithReason(errDataSizeExceed.Error()))
 		return
 	}
-	err := self.metric.SetStableTokenParams(value)
+	active, err := self.metric.GetStableTokenParams()
+	if err != nil {
+		log.Printf("WARNING: There is no current stable token params in database (%s)", err)
+	}
+	err = self.metric.SetStableTokenParams(value)
 	if err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
+	if err = self.proposeConfig(c, common.ConfigStableTokenParams, value, active, json.RawMessage(value), false); err != nil {
+		if rErr := self.metric.RemovePendingStableTokenParams(); rErr != nil {
+			log.Printf("failed to remove pending stable token params: %s", rErr)
+		}
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
 	httputil.ResponseSuccess(c)
 }
 
@@ -1275,11 +1340,16 @@ func (self *HTTPServer) ConfirmStableTokenParams(c *gin.Context) {
 		httputil.ResponseFailure(c, httputil.WithReason(errDataSizeExceed.Error()))
 		return
 	}
+	if err := self.checkConfirmer(c, common.ConfigStableTokenParams); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
 	err := self.metric.ConfirmStableTokenParams(value)
 	if err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
+	self.logResolveConfig(c, common.ConfigStableTokenParams, common.ConfigProposalConfirmed)
 	httputil.ResponseSuccess(c)
 }
 
@@ -1293,6 +1363,7 @@ func (self *HTTPServer) RejectStableTokenParams(c *gin.Context) {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
+	self.logResolveConfig(c, common.ConfigStableTokenParams, common.ConfigProposalRejected)
 	httputil.ResponseSuccess(c)
 }
 
@@ -1369,11 +1440,22 @@ func (self *HTTPServer) SetTargetQtyV2(c *gin.Context) {
 		}
 	}
 
-	err := self.metric.StorePendingTargetQtyV2(value)
+	active, err := self.metric.GetTargetQtyV2()
+	if err != nil {
+		log.Printf("WARNING: There is no current target quantity in database (%s)", err)
+	}
+	err = self.metric.StorePendingTargetQtyV2(value)
 	if err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
+	if err = self.proposeConfig(c, common.ConfigTargetQtyV2, value, active, tokenTargetQty, false); err != nil {
+		if rErr := self.metric.RemovePendingTargetQtyV2(); rErr != nil {
+			log.Printf("failed to remove pending target quantity: %s", rErr)
+		}
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
 	httputil.ResponseSuccess(c)
 }
 
@@ -1401,10 +1483,20 @@ func (self *HTTPServer) ConfirmTargetQtyV2(c *gin.Context) {
 		httputil.ResponseFailure(c, httputil.WithReason(errDataSizeExceed.Error()))
 		return
 	}
+	if err := self.checkTokenChangeInProgress(); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err := self.checkConfirmer(c, common.ConfigTargetQtyV2); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
 	err := self.metric.ConfirmTargetQtyV2(value)
 	if err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
 	}
+	self.logResolveConfig(c, common.ConfigTargetQtyV2, common.ConfigProposalConfirmed)
 	httputil.ResponseSuccess(c)
 }
 
@@ -1418,6 +1510,7 @@ func (self *HTTPServer) CancelTargetQtyV2(c *gin.Context) {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
+	self.logResolveConfig(c, common.ConfigTargetQtyV2, common.ConfigProposalRejected)
 	httputil.ResponseSuccess(c)
 }
 
@@ -1449,6 +1542,10 @@ func (self *HTTPServer) GetFeeSetRateByDay(c *gin.Context) {
 }
 
 func (self *HTTPServer) register() {
+	self.r.GET("/api-keys", self.GetAPIKeys)
+	self.r.POST("/set-api-key", self.SetAPIKey)
+	self.r.POST("/remove-api-key", self.RemoveAPIKey)
+	self.r.GET("/outbound-stats", self.GetOutboundStats)
 
 	if self.core != nil && self.app != nil {
 		stt := self.r.Group("/setting")
@@ -1509,6 +1606,12 @@ func (self *HTTPServer) register() {
 		self.r.GET("/setratestatus", self.GetSetrateStatus)
 		self.r.POST("/holdsetrate", self.HoldSetrate)
 		self.r.POST("/enablesetrate", self.EnableSetrate)
+		self.r.POST("/emergency-stop", self.EmergencyStop)
+		self.r.GET("/rate-guard", self.GetRateGuard)
+		self.r.GET("/pending-rate-guard", self.GetPendingRateGuard)
+		self.r.POST("/set-rate-guard", self.SetRateGuard)
+		self.r.POST("/confirm-rate-guard", self.ConfirmRateGuard)
+		self.r.POST("/reject-rate-guard", self.RejectRateGuard)
 
 		v2.GET("/pwis-equation", self.GetPWIEquationV2)
 		v2.GET("/pending-pwis-equation", self.GetPendingPWIEquationV2)
@@ -1516,6 +1619,17 @@ func (self *HTTPServer) register() {
 		v2.POST("/confirm-pwis-equation", self.ConfirmPWIEquationV2)
 		v2.POST("/reject-pwis-equation", self.RejectPWIEquationV2)
 
+		self.r.GET("/get-step-function-data", self.GetStepFunctionData)
+		self.r.GET("/get-token-control-info", self.GetTokenControlInfo)
+		self.r.GET("/pending-token-pricing", self.GetPendingTokenPricing)
+		self.r.POST("/set-token-pricing", self.SetTokenPricing)
+		self.r.POST("/confirm-token-pricing", self.ConfirmTokenPricing)
+		self.r.POST("/reject-token-pricing", self.RejectTokenPricing)
+		self.r.GET("/pending-token-delisting", self.GetPendingTokenDelisting)
+		self.r.POST("/set-token-delisting", self.SetTokenDelisting)
+		self.r.POST("/confirm-token-delisting", self.ConfirmTokenDelisting)
+		self.r.POST("/reject-token-delisting", self.RejectTokenDelisting)
+
 		self.r.GET("/rebalance-quadratic", self.GetRebalanceQuadratic)
 		self.r.GET("/pending-rebalance-quadratic", self.GetPendingRebalanceQuadratic)
 		self.r.POST("/set-rebalance-quadratic", self.SetRebalanceQuadratic)
@@ -1538,6 +1652,22 @@ func (self *HTTPServer) register() {
 		self.r.GET("/btc-feed", self.GetBTCData)
 		self.r.POST("/set-feed-configuration", self.UpdateFeedConfiguration)
 		self.r.GET("/get-feed-configuration", self.GetFeedConfiguration)
+
+		self.r.GET("/config-history", self.GetConfigHistory)
+		self.r.GET("/config-versions", self.GetConfigVersions)
+		self.r.GET("/config-version", self.GetConfigVersion)
+		self.r.POST("/rollback-config", self.RollbackConfig)
+
+		if self.rateEngine != nil {
+			self.r.GET("/rate-engine/rates", self.GetRateEngineRates)
+			self.r.POST("/rate-engine/set-rates", self.SetRateEngineRates)
+		}
+		if self.watchdog != nil {
+			self.r.GET("/rate-watchdog", self.GetRateWatchdog)
+		}
+		if self.rateLimiters != nil {
+			self.r.GET("/rate-limits", self.GetRateLimits)
+		}
 	}
 
 	if self.stat != nil {
@@ -1566,6 +1696,10 @@ func (self *HTTPServer) register() {
 		self.r.GET("/get-user-list", self.GetUserList)
 		self.r.GET("/get-token-heatmap", self.GetTokenHeatmap)
 		self.r.GET("/get-fee-setrate", self.GetFeeSetRateByDay)
+
+		if self.pnl != nil {
+			self.r.GET("/get-pnl", self.GetPnL)
+		}
 	}
 }
 
@@ -1608,6 +1742,6 @@ func NewHTTPServer(
 	r.Use(cors.New(corsConfig))
 
 	return &HTTPServer{
-		app, core, stat, metric, host, enableAuth, authEngine, r, bc, setting,
+		app, core, stat, metric, host, enableAuth, authEngine, r, bc, setting, nil, nil, nil, nil, 0, 0, 0,
 	}
 }
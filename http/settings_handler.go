@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"sort"
 	"strconv"
+	"sync/atomic"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/http/httputil"
@@ -22,6 +24,28 @@ const (
 	validAddressLength        = 42
 )
 
+var (
+	// errTokenListingInProgress is returned when the token update is changed
+	// while its tokens are being listed in pricing contract.
+	errTokenListingInProgress = errors.New("Token listing is in progress, check its activity")
+	// errTokenDelistingInProgress is returned when a token delisting is
+	// started or metrics are changed while tokens are being delisted.
+	errTokenDelistingInProgress = errors.New("Token delisting is in progress, check its activities")
+)
+
+// checkTokenChangeInProgress returns error if tokens are being listed or
+// delisted, they apply metrics of their tokens when they finish so metrics
+// can't be confirmed in the meantime.
+func (self *HTTPServer) checkTokenChangeInProgress() error {
+	if atomic.LoadInt32(&self.listingTokens) != 0 {
+		return errTokenListingInProgress
+	}
+	if atomic.LoadInt32(&self.delistingTokens) != 0 {
+		return errTokenDelistingInProgress
+	}
+	return nil
+}
+
 func (self *HTTPServer) updateInternalTokensIndices(tokenUpdates map[string]common.TokenUpdate) error {
 	tokens, err := self.setting.GetInternalTokens()
 	if err != nil {
@@ -129,6 +153,10 @@ func (self *HTTPServer) SetTokenUpdate(c *gin.Context) {
 	if !ok {
 		return
 	}
+	if atomic.LoadInt32(&self.listingTokens) != 0 {
+		httputil.ResponseFailure(c, httputil.WithReason(errTokenListingInProgress.Error()))
+		return
+	}
 	data := []byte(postForm.Get("data"))
 	tokenUpdates := make(map[string]common.TokenUpdate)
 	if err := json.Unmarshal(data, &tokenUpdates); err != nil {
@@ -191,17 +219,50 @@ func (self *HTTPServer) SetTokenUpdate(c *gin.Context) {
 				tokenUpdate.Exchanges[ex] = tokExSett
 			}
 		}
+		if tokenUpdate.ListOnChain {
+			if lErr := self.ensureListOnChain(token, tokenUpdate.Pricing); lErr != nil {
+				httputil.ResponseFailure(c, httputil.WithReason(fmt.Sprintf("Token %s can't be listed on chain (%s)", token.ID, lErr.Error())))
+				return
+			}
+		}
 		tokenUpdate.Token = token
 		tokenUpdates[tokenID] = tokenUpdate
 	}
 
+	active := self.currentTokenUpdates(tokenUpdates)
+	// the token updates are merged into the pending ones, which are restored
+	// if the proposal can't be recorded
+	previous, err := self.setting.GetPendingTokenUpdates()
+	if err != nil {
+		log.Printf("WARNING: There is no pending token updates in database (%s)", err)
+		previous = nil
+	}
 	if err = self.setting.UpdatePendingTokenUpdates(tokenUpdates); err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
+	if err = self.proposeConfig(c, common.ConfigTokenUpdate, data, active, tokenUpdates, true); err != nil {
+		if rErr := self.restorePendingTokenUpdates(previous); rErr != nil {
+			log.Printf("failed to restore pending token updates: %s", rErr)
+		}
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
 	httputil.ResponseSuccess(c)
 }
 
+// restorePendingTokenUpdates replaces the pending token updates with
+// previous.
+func (self *HTTPServer) restorePendingTokenUpdates(previous map[string]common.TokenUpdate) error {
+	if err := self.setting.RemovePendingTokenUpdates(); err != nil {
+		return err
+	}
+	if len(previous) == 0 {
+		return nil
+	}
+	return self.setting.UpdatePendingTokenUpdates(previous)
+}
+
 func (self *HTTPServer) GetPendingTokenUpdates(c *gin.Context) {
 	_, ok := self.Authenticated(c, []string{}, []Permission{RebalancePermission, ConfigurePermission, ReadOnlyPermission, ConfirmConfPermission})
 	if !ok {
@@ -221,6 +282,14 @@ func (self *HTTPServer) ConfirmTokenUpdate(c *gin.Context) {
 	if !ok {
 		return
 	}
+	if atomic.LoadInt32(&self.listingTokens) != 0 {
+		httputil.ResponseFailure(c, httputil.WithReason(errTokenListingInProgress.Error()))
+		return
+	}
+	if err := self.checkConfirmer(c, common.ConfigTokenUpdate); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
 	data := []byte(postForm.Get("data"))
 	//no need to handle error here, if timestamp==0 the program will use UNIX timestamp instead
 	timestamp, _ := strconv.ParseUint(postForm.Get("timestamp"), 10, 64)
@@ -229,34 +298,10 @@ func (self *HTTPServer) ConfirmTokenUpdate(c *gin.Context) {
 		httputil.ResponseFailure(c, httputil.WithReason(fmt.Sprintf("cant not unmarshall token request %s", err.Error())))
 		return
 	}
-	var (
-		pws    common.PWIEquationRequestV2
-		tarQty common.TokenTargetQtyV2
-		quadEq common.RebalanceQuadraticRequest
-		err    error
-	)
 	hasInternal := thereIsInternal(tokenUpdates)
-	//If there is internal token in the listing, query for related information.
-	if hasInternal {
-		pws, err = self.metric.GetPWIEquationV2()
-		if err != nil {
-			log.Printf("WARNING: There is no current PWS equation in database, creating new instance...")
-			pws = make(common.PWIEquationRequestV2)
-		}
-		tarQty, err = self.metric.GetTargetQtyV2()
-		if err != nil {
-			log.Printf("WARNING: There is no current target quantity in database, creating new instance...")
-			tarQty = make(common.TokenTargetQtyV2)
-		}
-		quadEq, err = self.metric.GetRebalanceQuadratic()
-		if err != nil {
-			log.Printf("WARNING: There is no current quadratic equation in database, creating new instance...")
-			quadEq = make(common.RebalanceQuadraticRequest)
-		}
-		if self.hasMetricPending() {
-			httputil.ResponseFailure(c, httputil.WithReason("There is currently pending action on metrics. Clean it first"))
-			return
-		}
+	if hasInternal && self.hasMetricPending() {
+		httputil.ResponseFailure(c, httputil.WithReason("There is currently pending action on metrics. Clean it first"))
+		return
 	}
 	pendingTLs, err := self.setting.GetPendingTokenUpdates()
 	if err != nil {
@@ -271,13 +316,6 @@ func (self *HTTPServer) ConfirmTokenUpdate(c *gin.Context) {
 		token := tokenUpdate.Token
 		token.LastActivationChange = common.GetTimepoint()
 		preparedToken = append(preparedToken, token)
-		if token.Internal {
-			//set metrics data for the token
-
-			pws[tokenID] = tokenUpdate.PWIEq
-			tarQty[tokenID] = tokenUpdate.TargetQty
-			quadEq[tokenID] = tokenUpdate.QuadraticEq
-		}
 		//check if the token is available in pending token listing and is deep equal to it.
 		pendingTL, avail := pendingTLs[tokenID]
 		if !avail {
@@ -293,24 +331,188 @@ func (self *HTTPServer) ConfirmTokenUpdate(c *gin.Context) {
 			return
 		}
 	}
-	//reload token indices and apply metric changes if the token is Internal
-	if hasInternal {
-		if err = self.updateInternalTokensIndices(tokenUpdates); err != nil {
-			httputil.ResponseFailure(c, httputil.WithReason(fmt.Sprintf("Can not update internal token indices (%s)", err.Error())))
-			return
+	// apply reloads token indices, applies metric changes if there is
+	// internal token and applies the token update to setting database.
+	apply := func() error {
+		if hasInternal {
+			if uErr := self.updateInternalTokensIndices(tokenUpdates); uErr != nil {
+				return fmt.Errorf("Can not update internal token indices (%s)", uErr.Error())
+			}
+			if uErr := self.updateTokenMetrics(tokenUpdates); uErr != nil {
+				return fmt.Errorf("Can not update metric data (%s)", uErr.Error())
+			}
+		}
+		if aErr := self.setting.ApplyTokenWithExchangeSetting(preparedToken, preparedExchangeSetting, timestamp); aErr != nil {
+			return fmt.Errorf("Can not apply token and exchange setting for token listing (%s). Metric data and token indices changes has to be manually revert", aErr.Error())
 		}
-		if err = self.metric.ConfirmTokenUpdateInfo(tarQty, pws, quadEq); err != nil {
-			httputil.ResponseFailure(c, httputil.WithReason(fmt.Sprintf("Can not update metric data (%s)", err.Error())))
+		return nil
+	}
+	listOnChain := tokensListOnChain(tokenUpdates)
+	if len(listOnChain) == 0 {
+		if err = apply(); err != nil {
+			httputil.ResponseFailure(c, httputil.WithError(err))
 			return
 		}
+		self.logResolveConfig(c, common.ConfigTokenUpdate, common.ConfigProposalConfirmed)
+		httputil.ResponseSuccess(c)
+		return
+	}
+
+	// listing in pricing contract waits for its transactions to be mined,
+	// it runs in background and its progress is recorded as an activity.
+	if !atomic.CompareAndSwapInt32(&self.listingTokens, 0, 1) {
+		httputil.ResponseFailure(c, httputil.WithReason(errTokenListingInProgress.Error()))
+		return
+	}
+	job := common.TokenListingJob{Tokens: listOnChain, Listings: []common.TokenListing{}}
+	id, err := self.core.RecordTokenListing(common.ActivityID{}, job, common.ExchangeStatusSubmitted, getKeyID(c))
+	if err != nil {
+		atomic.StoreInt32(&self.listingTokens, 0)
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	go self.runTokenListing(c.Copy(), id, job, tokenUpdates, apply)
+	httputil.ResponseSuccess(c, httputil.WithField("id", id))
+}
+
+// updateTokenMetrics sets the metrics of the internal tokens of tokenUpdates.
+// The current metrics are read at applying time, listing on chain takes a
+// while and metrics confirmed before it must not be overwritten.
+func (self *HTTPServer) updateTokenMetrics(tokenUpdates map[string]common.TokenUpdate) error {
+	pws, err := self.metric.GetPWIEquationV2()
+	if err != nil {
+		log.Printf("WARNING: There is no current PWS equation in database, creating new instance...")
+		pws = make(common.PWIEquationRequestV2)
+	}
+	tarQty, err := self.metric.GetTargetQtyV2()
+	if err != nil {
+		log.Printf("WARNING: There is no current target quantity in database, creating new instance...")
+		tarQty = make(common.TokenTargetQtyV2)
+	}
+	quadEq, err := self.metric.GetRebalanceQuadratic()
+	if err != nil {
+		log.Printf("WARNING: There is no current quadratic equation in database, creating new instance...")
+		quadEq = make(common.RebalanceQuadraticRequest)
+	}
+	for tokenID, tokenUpdate := range tokenUpdates {
+		if tokenUpdate.Token.Internal {
+			pws[tokenID] = tokenUpdate.PWIEq
+			tarQty[tokenID] = tokenUpdate.TargetQty
+			quadEq[tokenID] = tokenUpdate.QuadraticEq
+		}
 	}
-	// Apply the change into setting database
-	if err = self.setting.ApplyTokenWithExchangeSetting(preparedToken, preparedExchangeSetting, timestamp); err != nil {
-		httputil.ResponseFailure(c, httputil.WithReason(fmt.Sprintf("Can not apply token and exchange setting for token listing (%s). Metric data and token indices changes has to be manually revert", err.Error())))
+	return self.metric.ConfirmTokenUpdateInfo(tarQty, pws, quadEq)
+}
+
+// tokensListOnChain returns the IDs of the tokens of updates with ListOnChain
+// by token ID order.
+func tokensListOnChain(tokenUpdates map[string]common.TokenUpdate) []string {
+	var tokenIDs []string
+	for tokenID, tokenUpdate := range tokenUpdates {
+		if tokenUpdate.ListOnChain {
+			tokenIDs = append(tokenIDs, tokenID)
+		}
+	}
+	sort.Strings(tokenIDs)
+	return tokenIDs
+}
+
+// runTokenListing lists the tokens of job in pricing contract then applies
+// the token update, the enabled tokens are disabled if listing a token fails
+// or the token update can't be applied. The pending token update is kept for
+// retrying if it failed. The progress is recorded as the activity id.
+func (self *HTTPServer) runTokenListing(c *gin.Context, id common.ActivityID, job common.TokenListingJob,
+	tokenUpdates map[string]common.TokenUpdate, apply func() error) {
+	defer atomic.StoreInt32(&self.listingTokens, 0)
+	keyID := getKeyID(c)
+	record := func(status string) {
+		if _, rErr := self.core.RecordTokenListing(id, job, status, keyID); rErr != nil {
+			log.Printf("Recording listing of %v failed: %s", job.Tokens, rErr.Error())
+		}
+	}
+	err := self.listTokensOnChain(job.Tokens, tokenUpdates, keyID, func(listing common.TokenListing) {
+		job.Listings = append(job.Listings, listing)
+		record(common.ExchangeStatusSubmitted)
+	})
+	if err != nil {
+		err = fmt.Errorf("Can not list tokens on chain (%s)", err.Error())
+	} else {
+		err = apply()
+	}
+	if err != nil {
+		job.Rollbacks = self.rollbackListings(tokenUpdates, job.Listings, keyID)
+		job.Error = err.Error()
+		record(common.ExchangeStatusFailed)
 		return
 	}
+	self.logResolveConfig(c, common.ConfigTokenUpdate, common.ConfigProposalConfirmed)
+	job.Complete = true
+	record(common.ExchangeStatusDone)
+}
 
-	httputil.ResponseSuccess(c)
+// ensureListOnChain returns error if token can't be listed in pricing contract
+// with pricing configuration.
+func (self *HTTPServer) ensureListOnChain(token common.Token, pricing *common.TokenPricingConfig) error {
+	if !token.Internal || token.IsETH() {
+		return errors.New("Only internal token other than ETH is listed on chain")
+	}
+	if pricing == nil || pricing.TokenControlInfo == nil {
+		return errors.New("Token control info is required")
+	}
+	if err := self.checkTokenPricingConfig(token.ID, *pricing); err != nil {
+		return err
+	}
+	return self.blockchain.CheckTokenContract(token)
+}
+
+// listTokensOnChain lists tokens in pricing contract by the given order, it
+// stops at the first failed listing. done is called with each listing.
+func (self *HTTPServer) listTokensOnChain(tokenIDs []string, tokenUpdates map[string]common.TokenUpdate, keyID string,
+	done func(common.TokenListing)) error {
+	for _, tokenID := range tokenIDs {
+		tokenUpdate := tokenUpdates[tokenID]
+		if tokenUpdate.Pricing == nil {
+			return fmt.Errorf("There is no pricing configuration of token %s", tokenID)
+		}
+		listing, err := self.core.ListToken(tokenUpdate.Token, *tokenUpdate.Pricing, keyID)
+		done(listing)
+		if err != nil {
+			return fmt.Errorf("Listing %s failed: %s", tokenID, err.Error())
+		}
+	}
+	return nil
+}
+
+// rollbackListings disables the trade of the tokens enabled by listings when
+// listing fails or the token update can't be applied after listing. It waits for each
+// transaction to be mined, a failed rollback is returned with its error and
+// the token has to be manually disabled.
+func (self *HTTPServer) rollbackListings(tokenUpdates map[string]common.TokenUpdate, listings []common.TokenListing, keyID string) []common.TokenListingStep {
+	rollbacks := []common.TokenListingStep{}
+	for _, listing := range listings {
+		enabled := false
+		for _, step := range listing.Steps {
+			if step.Action == common.ActionEnableTokenTrade {
+				enabled = true
+			}
+		}
+		if !enabled {
+			continue
+		}
+		step := common.TokenListingStep{Action: common.ActionDisableTokenTrade}
+		var err error
+		step.ActivityID, err = self.core.DisableTokenTrade(tokenUpdates[listing.Token].Token, keyID)
+		if err == nil {
+			step.Tx = step.ActivityID.EID
+			step.MiningStatus, err = self.core.WaitTxMined(step.ActivityID)
+		}
+		if err != nil {
+			step.Error = err.Error()
+			log.Printf("WARNING: Disabling trade of %s failed, it has to be manually disabled: %s", listing.Token, err.Error())
+		}
+		rollbacks = append(rollbacks, step)
+	}
+	return rollbacks
 }
 
 func (self *HTTPServer) RejectTokenUpdate(c *gin.Context) {
@@ -318,6 +520,10 @@ func (self *HTTPServer) RejectTokenUpdate(c *gin.Context) {
 	if !ok {
 		return
 	}
+	if atomic.LoadInt32(&self.listingTokens) != 0 {
+		httputil.ResponseFailure(c, httputil.WithReason(errTokenListingInProgress.Error()))
+		return
+	}
 	listings, err := self.setting.GetPendingTokenUpdates()
 	if (err != nil) || len(listings) == 0 {
 		httputil.ResponseFailure(c, httputil.WithReason(fmt.Sprintf("there is no pending token listing (%v)", err)))
@@ -328,6 +534,7 @@ func (self *HTTPServer) RejectTokenUpdate(c *gin.Context) {
 		httputil.ResponseFailure(c, httputil.WithError(err))
 		return
 	}
+	self.logResolveConfig(c, common.ConfigTokenUpdate, common.ConfigProposalRejected)
 	httputil.ResponseSuccess(c)
 }
 
@@ -371,6 +578,29 @@ func (self *HTTPServer) getInfosFromExchangeEndPoint(tokenUpdates map[string]com
 	return result, nil
 }
 
+// currentTokenUpdates returns the current settings of the tokens in given token
+// updates, in the same form as token updates. New tokens are not included.
+func (self *HTTPServer) currentTokenUpdates(tokenUpdates map[string]common.TokenUpdate) map[string]common.TokenUpdate {
+	result := make(map[string]common.TokenUpdate)
+	// missing metric data are compared as empty
+	pws, _ := self.metric.GetPWIEquationV2()
+	tarQty, _ := self.metric.GetTargetQtyV2()
+	quadEq, _ := self.metric.GetRebalanceQuadratic()
+	for tokenID := range tokenUpdates {
+		token, err := self.setting.GetTokenByID(tokenID)
+		if err != nil {
+			continue
+		}
+		result[tokenID] = common.TokenUpdate{
+			Token:       token,
+			PWIEq:       pws[tokenID],
+			TargetQty:   tarQty[tokenID],
+			QuadraticEq: quadEq[tokenID],
+		}
+	}
+	return result
+}
+
 // hasPending return true if currently there is a pending request on Metric data
 // This is to ensure that token listing operations does not conflict with metric operations
 func (self *HTTPServer) hasMetricPending() bool {
@@ -504,7 +734,7 @@ func (self *HTTPServer) UpdateDepositAddress(c *gin.Context) {
 	for tokenID, addrStr := range exDepositAddressStr {
 		log.Printf("addrstr is %s", addrStr)
 		exDepositAddress[tokenID] = ethereum.HexToAddress(addrStr)
-		log.Printf(exDepositAddress[tokenID].Hex())
+		log.Printf("%s", exDepositAddress[tokenID].Hex())
 	}
 	if err := self.setting.UpdateDepositAddress(exName, exDepositAddress, timestamp); err != nil {
 		httputil.ResponseFailure(c, httputil.WithError(err))
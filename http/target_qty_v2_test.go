@@ -265,4 +265,29 @@ func TestHTTPServerTargetQtyV2(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.msg, func(t *testing.T) { testHTTPRequest(t, tc, s.r) })
 	}
+
+	// token delisting applies the metrics of its tokens when it finishes
+	testHTTPRequest(t, testCase{
+		msg:      "post a valid form to test delisting in progress",
+		endpoint: storePendingTargetQtyV2,
+		method:   http.MethodPost,
+		data:     map[string]string{"value": testData},
+		assert:   httputil.ExpectSuccess,
+	}, s.r)
+	s.delistingTokens = 1
+	testHTTPRequest(t, testCase{
+		msg:      "confirm when token delisting is in progress",
+		endpoint: confirmTargetQtyV2,
+		method:   http.MethodPost,
+		data:     map[string]string{"value": testData},
+		assert:   httputil.ExpectFailure,
+	}, s.r)
+	s.delistingTokens = 0
+	testHTTPRequest(t, testCase{
+		msg:      "confirm when token delisting finished",
+		endpoint: confirmTargetQtyV2,
+		method:   http.MethodPost,
+		data:     map[string]string{"value": testData},
+		assert:   httputil.ExpectSuccess,
+	}, s.r)
 }
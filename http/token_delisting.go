@@ -0,0 +1,363 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync/atomic"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+// Steps of delisting a token other than the actions of activities.
+const (
+	delistingCancelOrder     = "cancel_order"
+	delistingRemoveConfig    = "remove_token_config"
+	delistingDeactivateToken = "deactivate_token"
+)
+
+// checkTokenDelistingRequest returns error if a token of the request is not
+// an internal token.
+func (self *HTTPServer) checkTokenDelistingRequest(request []string) error {
+	if len(request) == 0 {
+		return errors.New("There is no token in the request")
+	}
+	seen := map[string]bool{}
+	for _, tokenID := range request {
+		if seen[tokenID] {
+			return fmt.Errorf("Token %s is duplicated", tokenID)
+		}
+		seen[tokenID] = true
+		token, err := self.setting.GetInternalTokenByID(tokenID)
+		if err != nil {
+			return fmt.Errorf("Getting token %s got err %s", tokenID, err.Error())
+		}
+		if token.IsETH() {
+			return errors.New("ETH can't be delisted")
+		}
+	}
+	return nil
+}
+
+// pendingTokenDelisting returns the tokens of the pending token delisting
+// proposal.
+func (self *HTTPServer) pendingTokenDelisting() ([]string, error) {
+	proposals, err := self.metric.GetPendingConfigProposals(common.ConfigTokenDelisting)
+	if err != nil {
+		return nil, err
+	}
+	if len(proposals) == 0 {
+		return nil, errors.New("There is no pending token delisting")
+	}
+	// a new proposal supersedes the pending one, take the latest in case
+	proposal := proposals[0]
+	for _, p := range proposals[1:] {
+		if p.ID > proposal.ID {
+			proposal = p
+		}
+	}
+	var request []string
+	if err = json.Unmarshal(proposal.Data, &request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// SetTokenDelisting proposes to delist internal tokens, they are delisted
+// after confirmed.
+// input data follow json: ["KNC", "OMG"]
+func (self *HTTPServer) SetTokenDelisting(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"value"}, []Permission{ConfigurePermission})
+	if !ok {
+		return
+	}
+	value := []byte(postForm.Get("value"))
+	if len(value) > maxDataSize {
+		httputil.ResponseFailure(c, httputil.WithReason(errDataSizeExceed.Error()))
+		return
+	}
+	var request []string
+	if err := json.Unmarshal(value, &request); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err := self.checkTokenDelistingRequest(request); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	active := map[string]common.Token{}
+	for _, tokenID := range request {
+		if token, err := self.setting.GetTokenByID(tokenID); err == nil {
+			active[tokenID] = token
+		}
+	}
+	if err := self.proposeConfig(c, common.ConfigTokenDelisting, value, active, request, false); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c)
+}
+
+// GetPendingTokenDelisting returns the tokens of pending token delisting.
+func (self *HTTPServer) GetPendingTokenDelisting(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, ConfigurePermission, ConfirmConfPermission, RebalancePermission})
+	if !ok {
+		return
+	}
+	data, err := self.pendingTokenDelisting()
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(data))
+}
+
+// ConfirmTokenDelisting confirms the pending token delisting and delists its
+// tokens by token ID order in background, it returns the delist_token
+// activity recording the progress of each token. The value must be the same
+// as the pending tokens.
+func (self *HTTPServer) ConfirmTokenDelisting(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"value"}, []Permission{ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	value := []byte(postForm.Get("value"))
+	if len(value) > maxDataSize {
+		httputil.ResponseFailure(c, httputil.WithReason(errDataSizeExceed.Error()))
+		return
+	}
+	pending, err := self.pendingTokenDelisting()
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	var confirm []string
+	if err = json.Unmarshal(value, &confirm); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if !reflect.DeepEqual(pending, confirm) {
+		httputil.ResponseFailure(c, httputil.WithReason("confirm data does not match token delisting pending data"))
+		return
+	}
+	if err = self.checkConfirmer(c, common.ConfigTokenDelisting); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err = self.checkTokenDelistingRequest(pending); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if self.hasMetricPending() {
+		httputil.ResponseFailure(c, httputil.WithReason("There is currently pending action on metrics. Clean it first"))
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&self.delistingTokens, 0, 1) {
+		httputil.ResponseFailure(c, httputil.WithReason(errTokenDelistingInProgress.Error()))
+		return
+	}
+	tokenIDs := append([]string{}, pending...)
+	sort.Strings(tokenIDs)
+	var (
+		keyID  = getKeyID(c)
+		ids    = []common.ActivityID{}
+		tokens = []common.Token{}
+	)
+	for _, tokenID := range tokenIDs {
+		token, tErr := self.setting.GetInternalTokenByID(tokenID)
+		if tErr != nil {
+			atomic.StoreInt32(&self.delistingTokens, 0)
+			httputil.ResponseFailure(c, httputil.WithError(tErr))
+			return
+		}
+		delisting := common.TokenDelisting{Token: token.ID, Steps: []common.TokenDelistingStep{}}
+		id, rErr := self.core.RecordTokenDelisting(common.ActivityID{}, token, delisting, common.ExchangeStatusSubmitted, keyID)
+		if rErr != nil {
+			atomic.StoreInt32(&self.delistingTokens, 0)
+			httputil.ResponseFailure(c, httputil.WithError(rErr))
+			return
+		}
+		ids = append(ids, id)
+		tokens = append(tokens, token)
+	}
+	// the proposal is confirmed even if a step fails, the outcome of each
+	// step is recorded in the delisting activity.
+	self.logResolveConfig(c, common.ConfigTokenDelisting, common.ConfigProposalConfirmed)
+	go func() {
+		defer atomic.StoreInt32(&self.delistingTokens, 0)
+		for i, token := range tokens {
+			if delisting := self.delistToken(ids[i], token, keyID); !delisting.Complete {
+				log.Printf("Delisting of %s is incomplete, check activity %s", token.ID, ids[i])
+			}
+		}
+	}()
+	httputil.ResponseSuccess(c, httputil.WithField("ids", ids))
+}
+
+// RejectTokenDelisting rejects the pending token delisting.
+func (self *HTTPServer) RejectTokenDelisting(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	if _, err := self.pendingTokenDelisting(); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err := self.resolveConfig(c, common.ConfigTokenDelisting, common.ConfigProposalRejected); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c)
+}
+
+// delistToken sets the base rates of token to zero, disables its trade in
+// pricing contract and waits for both transactions to be mined, cancels its
+// open orders and withdraws its balances on exchanges, removes it from the
+// metric configurations and deactivates it. The exchange balances are kept
+// if the token can't be stopped from trading on chain. The progress of the
+// delisting is recorded as the activity id.
+func (self *HTTPServer) delistToken(id common.ActivityID, token common.Token, keyID string) common.TokenDelisting {
+	var (
+		delisting = common.TokenDelisting{Token: token.ID, Steps: []common.TokenDelistingStep{}}
+		failed    = false
+	)
+	save := func(status string) {
+		if _, rErr := self.core.RecordTokenDelisting(id, token, delisting, status, keyID); rErr != nil {
+			log.Printf("Recording delisting of %s failed: %s", token.ID, rErr.Error())
+		}
+	}
+	record := func(step common.TokenDelistingStep, err error) {
+		if err != nil {
+			step.Error = err.Error()
+			failed = true
+		}
+		delisting.Steps = append(delisting.Steps, step)
+		save(common.ExchangeStatusSubmitted)
+	}
+	finish := func() common.TokenDelisting {
+		status := common.ExchangeStatusDone
+		if failed {
+			status = common.ExchangeStatusFailed
+		}
+		delisting.Complete = !failed
+		save(status)
+		return delisting
+	}
+
+	rateID, err := self.core.ZeroTokenRates(token, keyID)
+	record(common.TokenDelistingStep{Action: common.ActionSetBaseRate, ActivityID: rateID}, err)
+	disableID, err := self.core.DisableTokenTrade(token, keyID)
+	record(common.TokenDelistingStep{Action: common.ActionDisableTokenTrade, ActivityID: disableID}, err)
+	// the balances are only moved after the token can't be traded on chain
+	for i := range delisting.Steps {
+		if failed {
+			break
+		}
+		step := &delisting.Steps[i]
+		if step.MiningStatus, err = self.core.WaitTxMined(step.ActivityID); err != nil {
+			step.Error = err.Error()
+			failed = true
+		}
+		save(common.ExchangeStatusSubmitted)
+	}
+	if failed {
+		return finish()
+	}
+
+	self.withdrawDelistedToken(token, keyID, record)
+
+	err = self.removeTokenMetrics(token.ID)
+	record(common.TokenDelistingStep{Action: delistingRemoveConfig}, err)
+	token.Active = false
+	token.Internal = false
+	token.LastActivationChange = common.GetTimepoint()
+	err = self.setting.UpdateToken(token, token.LastActivationChange)
+	record(common.TokenDelistingStep{Action: delistingDeactivateToken}, err)
+	return finish()
+}
+
+// balanceFetcher is an exchange able to fetch its current balances.
+type balanceFetcher interface {
+	FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, error)
+}
+
+// withdrawDelistedToken cancels the open orders of token and withdraws its
+// balances on the exchanges listing the token-ETH pair. The balances are
+// fetched from the exchange after the cancels, the amount unlocked by them is
+// withdrawn and the amount the orders traded before being cancelled is not.
+func (self *HTTPServer) withdrawDelistedToken(token common.Token, keyID string, record func(common.TokenDelistingStep, error)) {
+	pendings, err := self.app.GetPendingActivities()
+	if err != nil {
+		record(common.TokenDelistingStep{Action: delistingCancelOrder}, fmt.Errorf("Getting pending activities failed: %s", err.Error()))
+		return
+	}
+	var exchangeIDs []string
+	for exID := range common.SupportedExchanges {
+		exchangeIDs = append(exchangeIDs, string(exID))
+	}
+	sort.Strings(exchangeIDs)
+	pairID := common.NewTokenPairID(token.ID, "ETH")
+	for _, exID := range exchangeIDs {
+		exchange := common.SupportedExchanges[common.ExchangeID(exID)]
+		if _, eErr := exchange.GetExchangeInfo(pairID); eErr != nil {
+			continue
+		}
+		for _, activity := range pendings {
+			if activity.Action != common.ActionTrade || activity.Destination != exID ||
+				(activity.Params["base"] != token.ID && activity.Params["quote"] != token.ID) {
+				continue
+			}
+			cErr := self.core.CancelOrder(activity.ID, exchange)
+			record(common.TokenDelistingStep{Action: delistingCancelOrder, Exchange: exID, ActivityID: activity.ID}, cErr)
+		}
+		fetcher, ok := exchange.(balanceFetcher)
+		if !ok {
+			record(common.TokenDelistingStep{Action: common.ActionWithdraw, Exchange: exID},
+				fmt.Errorf("Exchange %s can't fetch its balances", exID))
+			continue
+		}
+		balances, bErr := fetcher.FetchEBalanceData(common.GetTimepoint())
+		if bErr == nil && !balances.Valid {
+			bErr = errors.New(balances.Error)
+		}
+		if bErr != nil {
+			record(common.TokenDelistingStep{Action: common.ActionWithdraw, Exchange: exID},
+				fmt.Errorf("Getting balances failed: %s", bErr.Error()))
+			continue
+		}
+		amount := balances.AvailableBalance[token.ID]
+		if amount <= 0 {
+			continue
+		}
+		withdrawID, wErr := self.core.Withdraw(exchange, token, common.FloatToBigInt(amount, token.Decimals), common.GetTimepoint(), keyID)
+		record(common.TokenDelistingStep{Action: common.ActionWithdraw, Exchange: exID, ActivityID: withdrawID, Amount: amount}, wErr)
+	}
+}
+
+// removeTokenMetrics removes token from target quantity, PWI equations and
+// rebalance quadratic equations.
+func (self *HTTPServer) removeTokenMetrics(tokenID string) error {
+	// missing metric data are stored as empty
+	pws, err := self.metric.GetPWIEquationV2()
+	if err != nil {
+		pws = make(common.PWIEquationRequestV2)
+	}
+	tarQty, err := self.metric.GetTargetQtyV2()
+	if err != nil {
+		tarQty = make(common.TokenTargetQtyV2)
+	}
+	quadEq, err := self.metric.GetRebalanceQuadratic()
+	if err != nil {
+		quadEq = make(common.RebalanceQuadraticRequest)
+	}
+	delete(pws, tokenID)
+	delete(tarQty, tokenID)
+	delete(quadEq, tokenID)
+	return self.metric.ConfirmTokenUpdateInfo(tarQty, pws, quadEq)
+}
@@ -0,0 +1,248 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/core"
+	"github.com/KyberNetwork/reserve-data/data"
+	"github.com/KyberNetwork/reserve-data/data/storage"
+	_ "github.com/KyberNetwork/reserve-data/exchange/binance/mock"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/KyberNetwork/reserve-data/settings"
+	settingstorage "github.com/KyberNetwork/reserve-data/settings/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHTTPServerTokenDelisting(t *testing.T) {
+	const (
+		setTokenDelisting        = "/set-token-delisting"
+		getPendingTokenDelisting = "/pending-token-delisting"
+		confirmTokenDelisting    = "/confirm-token-delisting"
+		rejectTokenDelisting     = "/reject-token-delisting"
+		testData                 = `["KNC"]`
+	)
+
+	tmpDir, err := ioutil.TempDir("", "test_token_delisting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if rErr := os.RemoveAll(tmpDir); rErr != nil {
+			t.Error(rErr)
+		}
+	}()
+	boltSettingStorage, err := settingstorage.NewBoltSettingStorage(filepath.Join(tmpDir, "setting.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenSetting, err := settings.NewTokenSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchangeSetting, err := settings.NewExchangeSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setting, err := settings.NewSetting(tokenSetting, &settings.AddressSetting{}, exchangeSetting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []common.Token{
+		common.NewToken("KNC", "KyberNetwork", "0xdd974d5c2e2928dea5f71b9825b8b646686bd200", 18, true, true, 0),
+		common.NewToken("OMG", "OmiseGO", "0xd26114cd6EE289AccF82350c8d8487fedB8A0C07", 18, true, true, 0),
+	} {
+		if err = setting.UpdateToken(token, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testStorage, err := storage.NewBoltStorage(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = testStorage.ConfirmTokenUpdateInfo(
+		common.TokenTargetQtyV2{"KNC": common.TargetQtyV2{}, "OMG": common.TargetQtyV2{}},
+		common.PWIEquationRequestV2{"KNC": common.PWIEquationTokenV2{}, "OMG": common.PWIEquationTokenV2{}},
+		common.RebalanceQuadraticRequest{"KNC": common.RebalanceQuadraticEquation{}},
+	); err != nil {
+		t.Fatal(err)
+	}
+	timepoint := common.GetTimepoint()
+	if err = testStorage.StoreAuthSnapshot(&common.AuthDataSnapshot{
+		Valid: true,
+		ExchangeBalances: map[common.ExchangeID]common.EBalanceEntry{
+			"binance": {
+				Valid:            true,
+				AvailableBalance: map[string]float64{"KNC": 10, "OMG": 20},
+				LockedBalance:    map[string]float64{"KNC": 5},
+			},
+		},
+	}, timepoint); err != nil {
+		t.Fatal(err)
+	}
+	orderID := common.NewActivityID(timepoint, "order")
+	if err = testStorage.Record(common.ActionTrade, orderID, "binance",
+		map[string]interface{}{"base": "KNC", "quote": "ETH"}, map[string]interface{}{},
+		common.ExchangeStatusSubmitted, "", timepoint, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var nonce uint64
+	s := HTTPServer{
+		app:         data.NewReserveData(testStorage, nil, nil, nil, nil, nil, setting),
+		core:        core.NewReserveCore(testPricingBlockchain{nonce: &nonce}, testStorage, setting),
+		metric:      testStorage,
+		authEnabled: false,
+		r:           gin.Default(),
+		blockchain:  testHTTPBlockchain{},
+		setting:     setting,
+	}
+	s.register()
+
+	assertDelisted := func(t *testing.T, resp *httptest.ResponseRecorder) {
+		var result struct {
+			Success bool                `json:"success"`
+			IDs     []common.ActivityID `json:"ids"`
+		}
+		if dErr := json.NewDecoder(resp.Body).Decode(&result); dErr != nil {
+			t.Fatal(dErr)
+		}
+		if !result.Success || len(result.IDs) != 1 {
+			t.Fatalf("Unexpected response %+v", result)
+		}
+		// the delisting runs in background
+		var (
+			activity common.ActivityRecord
+			aErr     error
+		)
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			if activity, aErr = testStorage.GetActivity(result.IDs[0]); aErr != nil {
+				t.Fatal(aErr)
+			}
+			if activity.ExchangeStatus != common.ExchangeStatusSubmitted || time.Now().After(deadline) {
+				break
+			}
+		}
+		if activity.Action != common.ActionDelistToken || activity.ExchangeStatus != common.ExchangeStatusDone ||
+			activity.IsPending() || activity.Result["complete"] != true {
+			t.Fatalf("Unexpected delisting activity %+v", activity)
+		}
+		raw, mErr := json.Marshal(activity.Result["steps"])
+		if mErr != nil {
+			t.Fatal(mErr)
+		}
+		var steps []common.TokenDelistingStep
+		if uErr := json.Unmarshal(raw, &steps); uErr != nil {
+			t.Fatal(uErr)
+		}
+		var actions []string
+		for _, step := range steps {
+			actions = append(actions, step.Action)
+			// the balances are fetched from the exchange after the order
+			// is cancelled, not taken from the stored balances
+			if step.Action == common.ActionWithdraw && step.Amount != 12 {
+				t.Errorf("Expected balance after cancelling orders withdrawn, got %+v", step)
+			}
+			if step.Action == common.ActionDisableTokenTrade && step.MiningStatus != common.MiningStatusMined {
+				t.Errorf("Expected disable token trade mined before withdrawing, got %+v", step)
+			}
+		}
+		expected := []string{
+			common.ActionSetBaseRate, common.ActionDisableTokenTrade, delistingCancelOrder,
+			common.ActionWithdraw, delistingRemoveConfig, delistingDeactivateToken,
+		}
+		if !reflect.DeepEqual(actions, expected) {
+			t.Errorf("Expected steps %v, got %v", expected, actions)
+		}
+		if _, tErr := setting.GetInternalTokenByID("KNC"); tErr == nil {
+			t.Error("Expected KNC deactivated")
+		}
+		targets, tErr := testStorage.GetTargetQtyV2()
+		if tErr != nil {
+			t.Fatal(tErr)
+		}
+		if _, ok := targets["KNC"]; ok || len(targets) != 1 {
+			t.Errorf("Expected KNC removed from target quantity, got %+v", targets)
+		}
+	}
+
+	var tests = []testCase{
+		{
+			msg:      "getting non exists pending token delisting",
+			endpoint: getPendingTokenDelisting,
+			method:   http.MethodGet,
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "unsupported token",
+			endpoint: setTokenDelisting,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": `["KNC", "BAT"]`},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "valid post form to reject",
+			endpoint: setTokenDelisting,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": `["OMG"]`},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "reject when there is pending token delisting",
+			endpoint: rejectTokenDelisting,
+			method:   http.MethodPost,
+			data: map[string]string{
+				"value": "some random post form or this request will be unauthenticated",
+			},
+			assert: httputil.ExpectSuccess,
+		},
+		{
+			msg:      "valid post form",
+			endpoint: setTokenDelisting,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testData},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "getting exists pending token delisting",
+			endpoint: getPendingTokenDelisting,
+			method:   http.MethodGet,
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "confirm with wrong data",
+			endpoint: confirmTokenDelisting,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": `["OMG"]`},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "confirm with correct data",
+			endpoint: confirmTokenDelisting,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testData},
+			assert:   assertDelisted,
+		},
+		{
+			msg:      "reject when no pending token delisting exists",
+			endpoint: rejectTokenDelisting,
+			method:   http.MethodPost,
+			data: map[string]string{
+				"value": "some random post form or this request will be unauthenticated",
+			},
+			assert: httputil.ExpectFailure,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) { testHTTPRequest(t, tc, s.r) })
+	}
+}
@@ -0,0 +1,391 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"reflect"
+	"sort"
+	"sync/atomic"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+// minStepBps is the lowest y of a step function, a step can't lower the rate
+// by more than 100%.
+var minStepBps = big.NewInt(-10000)
+
+// tokenPricingResponse is the pricing configuration of tokens read from
+// blockchain at block number.
+type tokenPricingResponse struct {
+	BlockNumber uint64                 `json:"block_number"`
+	Tokens      map[string]interface{} `json:"tokens"`
+}
+
+// pricingTokens returns the token of token param or all internal tokens
+// except ETH which is not priced by the pricing contract.
+func (self *HTTPServer) pricingTokens(c *gin.Context) ([]common.Token, error) {
+	if tokenID := c.Query("token"); tokenID != "" {
+		token, err := self.setting.GetInternalTokenByID(tokenID)
+		if err != nil {
+			return nil, err
+		}
+		return []common.Token{token}, nil
+	}
+	tokens, err := self.setting.GetInternalTokens()
+	if err != nil {
+		return nil, err
+	}
+	var result []common.Token
+	for _, token := range tokens {
+		if !token.IsETH() {
+			result = append(result, token)
+		}
+	}
+	return result, nil
+}
+
+// readTokenPricing responds the configuration of the requested tokens read
+// by read at the current block.
+func (self *HTTPServer) readTokenPricing(c *gin.Context, read func(ethereum.Address, uint64) (interface{}, error)) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	tokens, err := self.pricingTokens(c)
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	block, err := self.blockchain.CurrentBlock()
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	result := tokenPricingResponse{BlockNumber: block, Tokens: map[string]interface{}{}}
+	for _, token := range tokens {
+		data, rErr := read(ethereum.HexToAddress(token.Address), block)
+		if rErr != nil {
+			httputil.ResponseFailure(c, httputil.WithReason(fmt.Sprintf("Reading %s failed: %s", token.ID, rErr.Error())))
+			return
+		}
+		result.Tokens[token.ID] = data
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(result))
+}
+
+// GetStepFunctionData returns the quantity and imbalance step functions of
+// token param or all tokens from pricing contract.
+func (self *HTTPServer) GetStepFunctionData(c *gin.Context) {
+	self.readTokenPricing(c, func(token ethereum.Address, block uint64) (interface{}, error) {
+		return self.blockchain.GetStepFunctions(token, block)
+	})
+}
+
+// GetTokenControlInfo returns the imbalance control of token param or all
+// tokens from pricing contract.
+func (self *HTTPServer) GetTokenControlInfo(c *gin.Context) {
+	self.readTokenPricing(c, func(token ethereum.Address, block uint64) (interface{}, error) {
+		return self.blockchain.GetTokenControlInfo(token, block)
+	})
+}
+
+func checkStepFunction(x, y []*big.Int, side string) error {
+	if len(x) != len(y) {
+		return fmt.Errorf("Length of x_%s (%d) is not equal to length of y_%s (%d)", side, len(x), side, len(y))
+	}
+	for i := range x {
+		if x[i] == nil || y[i] == nil {
+			return fmt.Errorf("Step %d of %s is missing", i, side)
+		}
+		if y[i].Cmp(minStepBps) < 0 {
+			return fmt.Errorf("y_%s %s is lower than %s bps", side, y[i].String(), minStepBps.String())
+		}
+	}
+	return nil
+}
+
+func isNonNegative(values ...*big.Int) bool {
+	for _, value := range values {
+		if value == nil || value.Sign() < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTokenPricingRequest returns error if a token of the request is not an
+// internal token or its configuration can't be set to the pricing contract.
+func (self *HTTPServer) checkTokenPricingRequest(request common.TokenPricingConfigRequest) error {
+	if len(request) == 0 {
+		return errors.New("There is no token in the request")
+	}
+	for tokenID, config := range request {
+		token, err := self.setting.GetInternalTokenByID(tokenID)
+		if err != nil {
+			return fmt.Errorf("Getting token %s got err %s", tokenID, err.Error())
+		}
+		if token.IsETH() {
+			return errors.New("ETH is not priced by the pricing contract")
+		}
+		if config.QuantityStepFunction == nil && config.ImbalanceStepFunction == nil && config.TokenControlInfo == nil {
+			return fmt.Errorf("There is no configuration of token %s", tokenID)
+		}
+		if err = self.checkTokenPricingConfig(tokenID, config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTokenPricingConfig returns error if the configuration of a token can't
+// be set to the pricing contract.
+func (self *HTTPServer) checkTokenPricingConfig(tokenID string, config common.TokenPricingConfig) error {
+	if qty := config.QuantityStepFunction; qty != nil {
+		if err := checkStepFunction(qty.XBuy, qty.YBuy, "buy"); err != nil {
+			return fmt.Errorf("Invalid quantity step function of %s: %s", tokenID, err.Error())
+		}
+		if err := checkStepFunction(qty.XSell, qty.YSell, "sell"); err != nil {
+			return fmt.Errorf("Invalid quantity step function of %s: %s", tokenID, err.Error())
+		}
+	}
+	if imbalance := config.ImbalanceStepFunction; imbalance != nil {
+		if err := checkStepFunction(imbalance.XBuy, imbalance.YBuy, "buy"); err != nil {
+			return fmt.Errorf("Invalid imbalance step function of %s: %s", tokenID, err.Error())
+		}
+		if err := checkStepFunction(imbalance.XSell, imbalance.YSell, "sell"); err != nil {
+			return fmt.Errorf("Invalid imbalance step function of %s: %s", tokenID, err.Error())
+		}
+	}
+	if info := config.TokenControlInfo; info != nil {
+		if !isNonNegative(info.MinimalRecordResolution, info.MaxPerBlockImbalance, info.MaxTotalImbalance) {
+			return fmt.Errorf("Token control info of %s must be set and not negative", tokenID)
+		}
+		// only the admin of pricing contract can set token control info
+		admin, err := self.blockchain.GetPricingAdmin()
+		if err != nil {
+			return err
+		}
+		if operator := self.blockchain.GetPricingOPAddress(); admin != operator {
+			return fmt.Errorf("Pricing operator %s is not the admin %s of pricing contract to set token control info", operator.Hex(), admin.Hex())
+		}
+	}
+	return nil
+}
+
+// activeTokenPricing returns the current configuration of the parts set by
+// the request from pricing contract.
+func (self *HTTPServer) activeTokenPricing(request common.TokenPricingConfigRequest) (common.TokenPricingConfigRequest, error) {
+	result := common.TokenPricingConfigRequest{}
+	for tokenID, config := range request {
+		token, err := self.setting.GetInternalTokenByID(tokenID)
+		if err != nil {
+			return nil, err
+		}
+		address := ethereum.HexToAddress(token.Address)
+		active := common.TokenPricingConfig{}
+		if config.QuantityStepFunction != nil || config.ImbalanceStepFunction != nil {
+			stepFunctions, sErr := self.blockchain.GetStepFunctions(address, 0)
+			if sErr != nil {
+				return nil, sErr
+			}
+			if config.QuantityStepFunction != nil {
+				active.QuantityStepFunction = &stepFunctions.QuantityStepResponse
+			}
+			if config.ImbalanceStepFunction != nil {
+				active.ImbalanceStepFunction = &stepFunctions.ImbalanceStepResponse
+			}
+		}
+		if config.TokenControlInfo != nil {
+			info, iErr := self.blockchain.GetTokenControlInfo(address, 0)
+			if iErr != nil {
+				return nil, iErr
+			}
+			active.TokenControlInfo = &info
+		}
+		result[tokenID] = active
+	}
+	return result, nil
+}
+
+// pendingTokenPricing returns the configuration of the pending token pricing
+// proposal.
+func (self *HTTPServer) pendingTokenPricing() (common.TokenPricingConfigRequest, error) {
+	proposals, err := self.metric.GetPendingConfigProposals(common.ConfigTokenPricing)
+	if err != nil {
+		return nil, err
+	}
+	if len(proposals) == 0 {
+		return nil, errors.New("There is no pending token pricing configuration")
+	}
+	// a new proposal supersedes the pending one, take the latest in case
+	proposal := proposals[0]
+	for _, p := range proposals[1:] {
+		if p.ID > proposal.ID {
+			proposal = p
+		}
+	}
+	var request common.TokenPricingConfigRequest
+	if err = json.Unmarshal(proposal.Data, &request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// SetTokenPricing proposes the step functions and token control info of
+// tokens, they are submitted to pricing contract after confirmed.
+// input data follow json: {"KNC": {"imbalance_step_function": {"x_buy": [...], "y_buy": [...], "x_sell": [...], "y_sell": [...]}}}
+func (self *HTTPServer) SetTokenPricing(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"value"}, []Permission{ConfigurePermission})
+	if !ok {
+		return
+	}
+	value := []byte(postForm.Get("value"))
+	if len(value) > maxDataSize {
+		httputil.ResponseFailure(c, httputil.WithReason(errDataSizeExceed.Error()))
+		return
+	}
+	var request common.TokenPricingConfigRequest
+	if err := json.Unmarshal(value, &request); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err := self.checkTokenPricingRequest(request); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	active, err := self.activeTokenPricing(request)
+	if err != nil {
+		log.Printf("WARNING: Reading current token pricing configuration failed (%s)", err)
+	}
+	if err = self.proposeConfig(c, common.ConfigTokenPricing, value, active, request, false); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c)
+}
+
+// GetPendingTokenPricing returns the pending token pricing configuration.
+func (self *HTTPServer) GetPendingTokenPricing(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, ConfigurePermission, ConfirmConfPermission, RebalancePermission})
+	if !ok {
+		return
+	}
+	data, err := self.pendingTokenPricing()
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithData(data))
+}
+
+// ConfirmTokenPricing confirms the pending token pricing configuration and
+// submits its transactions to pricing contract, the transactions are recorded
+// as activities. The value must be the same as the pending configuration.
+func (self *HTTPServer) ConfirmTokenPricing(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"value"}, []Permission{ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	value := []byte(postForm.Get("value"))
+	if len(value) > maxDataSize {
+		httputil.ResponseFailure(c, httputil.WithReason(errDataSizeExceed.Error()))
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&self.confirmingTokenPricing, 0, 1) {
+		httputil.ResponseFailure(c, httputil.WithReason("Token pricing confirmation is in progress, check its activities"))
+		return
+	}
+	defer atomic.StoreInt32(&self.confirmingTokenPricing, 0)
+	pending, err := self.pendingTokenPricing()
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	var confirm common.TokenPricingConfigRequest
+	if err = json.Unmarshal(value, &confirm); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if !reflect.DeepEqual(pending, confirm) {
+		httputil.ResponseFailure(c, httputil.WithReason("confirm data does not match token pricing pending data"))
+		return
+	}
+	if err = self.checkConfirmer(c, common.ConfigTokenPricing); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	// the proposal is confirmed before submitting so it can't be confirmed
+	// twice, it stays confirmed even if a transaction fails, the outcome of
+	// each transaction is recorded in its activity.
+	if err = self.resolveConfig(c, common.ConfigTokenPricing, common.ConfigProposalConfirmed); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	ids, err := self.submitTokenPricing(pending, getKeyID(c))
+	if err != nil {
+		httputil.ResponseFailure(c, httputil.WithReason(fmt.Sprintf("%s, submitted activities: %v", err.Error(), ids)))
+		return
+	}
+	httputil.ResponseSuccess(c, httputil.WithField("ids", ids))
+}
+
+// submitTokenPricing submits the transactions of request by token ID order,
+// it stops at the first failed transaction.
+func (self *HTTPServer) submitTokenPricing(request common.TokenPricingConfigRequest, keyID string) ([]common.ActivityID, error) {
+	var tokenIDs []string
+	for tokenID := range request {
+		tokenIDs = append(tokenIDs, tokenID)
+	}
+	sort.Strings(tokenIDs)
+	ids := []common.ActivityID{}
+	for _, tokenID := range tokenIDs {
+		token, err := self.setting.GetInternalTokenByID(tokenID)
+		if err != nil {
+			return ids, err
+		}
+		config := request[tokenID]
+		var id common.ActivityID
+		if config.QuantityStepFunction != nil {
+			if id, err = self.core.SetQtyStepFunction(token, *config.QuantityStepFunction, keyID); err != nil {
+				return ids, fmt.Errorf("Setting quantity step function of %s failed: %s", tokenID, err.Error())
+			}
+			ids = append(ids, id)
+		}
+		if config.ImbalanceStepFunction != nil {
+			if id, err = self.core.SetImbalanceStepFunction(token, *config.ImbalanceStepFunction, keyID); err != nil {
+				return ids, fmt.Errorf("Setting imbalance step function of %s failed: %s", tokenID, err.Error())
+			}
+			ids = append(ids, id)
+		}
+		if config.TokenControlInfo != nil {
+			if id, err = self.core.SetTokenControlInfo(token, *config.TokenControlInfo, keyID); err != nil {
+				return ids, fmt.Errorf("Setting token control info of %s failed: %s", tokenID, err.Error())
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// RejectTokenPricing rejects the pending token pricing configuration.
+func (self *HTTPServer) RejectTokenPricing(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	if _, err := self.pendingTokenPricing(); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	if err := self.resolveConfig(c, common.ConfigTokenPricing, common.ConfigProposalRejected); err != nil {
+		httputil.ResponseFailure(c, httputil.WithError(err))
+		return
+	}
+	httputil.ResponseSuccess(c)
+}
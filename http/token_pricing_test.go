@@ -0,0 +1,376 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/core"
+	"github.com/KyberNetwork/reserve-data/data"
+	"github.com/KyberNetwork/reserve-data/data/storage"
+	"github.com/KyberNetwork/reserve-data/http/httputil"
+	"github.com/KyberNetwork/reserve-data/settings"
+	settingstorage "github.com/KyberNetwork/reserve-data/settings/storage"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gin-gonic/gin"
+)
+
+// testPricingBlockchain submits the pricing transactions of core with
+// increasing nonces, enabling token trade fails if failEnable is set.
+type testPricingBlockchain struct {
+	nonce      *uint64
+	failEnable *bool
+}
+
+func (self testPricingBlockchain) newTx() (*types.Transaction, error) {
+	*self.nonce++
+	return types.NewTransaction(*self.nonce, ethereum.Address{}, big.NewInt(0), 300000, big.NewInt(1000000000), []byte{}), nil
+}
+
+func (self testPricingBlockchain) StandardGasPrice() float64 {
+	return 0
+}
+
+func (self testPricingBlockchain) Send(token common.Token, amount *big.Int, address ethereum.Address) (*types.Transaction, error) {
+	return self.newTx()
+}
+
+func (self testPricingBlockchain) SetRates(tokens []ethereum.Address, buys, sells []*big.Int, block, nonce, gasPrice *big.Int) (*types.Transaction, error) {
+	return self.newTx()
+}
+
+func (self testPricingBlockchain) SetRateMinedNonce() (uint64, error) {
+	return 0, nil
+}
+
+func (self testPricingBlockchain) SetQtyStepFunction(token ethereum.Address, xBuy, yBuy, xSell, ySell []*big.Int) (*types.Transaction, error) {
+	return self.newTx()
+}
+
+func (self testPricingBlockchain) SetImbalanceStepFunction(token ethereum.Address, xBuy, yBuy, xSell, ySell []*big.Int) (*types.Transaction, error) {
+	return self.newTx()
+}
+
+func (self testPricingBlockchain) SetTokenControlInfo(token ethereum.Address, info common.TokenControlInfo) (*types.Transaction, error) {
+	return self.newTx()
+}
+
+func (self testPricingBlockchain) AddToken(token ethereum.Address) (*types.Transaction, error) {
+	return self.newTx()
+}
+
+func (self testPricingBlockchain) EnableTokenTrade(token ethereum.Address) (*types.Transaction, error) {
+	if self.failEnable != nil && *self.failEnable {
+		return nil, errors.New("enable token trade failed")
+	}
+	return self.newTx()
+}
+
+func (self testPricingBlockchain) DisableTokenTrade(token ethereum.Address) (*types.Transaction, error) {
+	return self.newTx()
+}
+
+func (self testPricingBlockchain) SetBaseRate(token ethereum.Address, buy, sell *big.Int) (*types.Transaction, error) {
+	return self.newTx()
+}
+
+func (self testPricingBlockchain) GetTokenBasicData(token ethereum.Address) (bool, bool, error) {
+	return false, false, nil
+}
+
+func (self testPricingBlockchain) GetStepFunctions(token ethereum.Address, atBlock uint64) (common.StepFunctionResponse, error) {
+	return common.StepFunctionResponse{}, nil
+}
+
+func (self testPricingBlockchain) CheckTokenContract(token common.Token) error {
+	return nil
+}
+
+func (self testPricingBlockchain) TxStatus(tx ethereum.Hash) (string, uint64, error) {
+	return common.MiningStatusMined, 0, nil
+}
+
+func TestHTTPServerTokenPricing(t *testing.T) {
+	const (
+		getStepFunctionData    = "/get-step-function-data"
+		getTokenControlInfo    = "/get-token-control-info"
+		setTokenPricing        = "/set-token-pricing"
+		getPendingTokenPricing = "/pending-token-pricing"
+		confirmTokenPricing    = "/confirm-token-pricing"
+		rejectTokenPricing     = "/reject-token-pricing"
+		testData               = `{
+			"KNC": {
+				"imbalance_step_function": {
+					"x_buy": [100, 200],
+					"y_buy": [0, -20],
+					"x_sell": [-200, -100],
+					"y_sell": [-20, 0]
+				},
+				"token_control_info": {
+					"minimal_record_resolution": 1000,
+					"max_per_block_imbalance": 200000,
+					"max_total_imbalance": 2000000
+				}
+			}
+		}`
+		testWrongDataConfirmation = `{
+			"KNC": {
+				"imbalance_step_function": {
+					"x_buy": [100, 200],
+					"y_buy": [0, -30],
+					"x_sell": [-200, -100],
+					"y_sell": [-20, 0]
+				}
+			}
+		}`
+		testDataMismatchedSteps = `{
+			"KNC": {
+				"quantity_step_function": {
+					"x_buy": [100, 200],
+					"y_buy": [0],
+					"x_sell": [],
+					"y_sell": []
+				}
+			}
+		}`
+		testDataUnsupported = `{
+			"OMG": {
+				"token_control_info": {
+					"minimal_record_resolution": 1000,
+					"max_per_block_imbalance": 200000,
+					"max_total_imbalance": 2000000
+				}
+			}
+		}`
+	)
+
+	tmpDir, err := ioutil.TempDir("", "test_token_pricing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if rErr := os.RemoveAll(tmpDir); rErr != nil {
+			t.Error(rErr)
+		}
+	}()
+	boltSettingStorage, err := settingstorage.NewBoltSettingStorage(filepath.Join(tmpDir, "setting.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenSetting, err := settings.NewTokenSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchangeSetting, err := settings.NewExchangeSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setting, err := settings.NewSetting(tokenSetting, &settings.AddressSetting{}, exchangeSetting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setting.UpdateToken(common.NewToken("KNC", "KyberNetwork", "0xdd974d5c2e2928dea5f71b9825b8b646686bd200", 18, true, true, 0), 0); err != nil {
+		t.Fatal(err)
+	}
+	testStorage, err := storage.NewBoltStorage(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nonce uint64
+	s := HTTPServer{
+		app:         data.NewReserveData(testStorage, nil, nil, nil, nil, nil, setting),
+		core:        core.NewReserveCore(testPricingBlockchain{nonce: &nonce}, testStorage, setting),
+		metric:      testStorage,
+		authEnabled: false,
+		r:           gin.Default(),
+		blockchain:  testHTTPBlockchain{},
+		setting:     setting,
+	}
+	s.register()
+
+	assertPendingActivities := func(t *testing.T, resp *httptest.ResponseRecorder) {
+		httputil.ExpectSuccess(t, resp)
+		pendings, pErr := testStorage.GetPendingActivities()
+		if pErr != nil {
+			t.Fatal(pErr)
+		}
+		actions := map[string]bool{}
+		for _, act := range pendings {
+			if act.MiningStatus != common.MiningStatusSubmitted || act.Destination != "blockchain" {
+				t.Errorf("Unexpected activity %+v", act)
+			}
+			actions[act.Action] = true
+		}
+		if len(pendings) != 2 || !actions[common.ActionSetImbalanceStepFunction] || !actions[common.ActionSetTokenControlInfo] {
+			t.Errorf("Expected imbalance step function and token control info activities, got %+v", pendings)
+		}
+	}
+
+	var tests = []testCase{
+		{
+			msg:      "get step functions of all tokens",
+			endpoint: getStepFunctionData,
+			method:   http.MethodGet,
+			assert: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				var result struct {
+					Success bool `json:"success"`
+					Data    struct {
+						BlockNumber uint64                                 `json:"block_number"`
+						Tokens      map[string]common.StepFunctionResponse `json:"tokens"`
+					} `json:"data"`
+				}
+				if dErr := json.NewDecoder(resp.Body).Decode(&result); dErr != nil {
+					t.Fatal(dErr)
+				}
+				if !result.Success || result.Data.BlockNumber != 6268056 || len(result.Data.Tokens) != 1 {
+					t.Fatalf("Unexpected response %+v", result)
+				}
+				if y := result.Data.Tokens["KNC"].ImbalanceStepResponse.YBuy; len(y) != 1 || y[0].Int64() != -10 {
+					t.Errorf("Unexpected imbalance step function %v", y)
+				}
+			},
+		},
+		{
+			msg:      "get token control info of a token",
+			endpoint: getTokenControlInfo + "?token=KNC",
+			method:   http.MethodGet,
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "get token control info of an unsupported token",
+			endpoint: getTokenControlInfo + "?token=OMG",
+			method:   http.MethodGet,
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "getting non exists pending token pricing",
+			endpoint: getPendingTokenPricing,
+			method:   http.MethodGet,
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "unsupported token",
+			endpoint: setTokenPricing,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testDataUnsupported},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "mismatched step function",
+			endpoint: setTokenPricing,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testDataMismatchedSteps},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "confirm when no pending token pricing exists",
+			endpoint: confirmTokenPricing,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testData},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "valid post form",
+			endpoint: setTokenPricing,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testData},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "getting exists pending token pricing",
+			endpoint: getPendingTokenPricing,
+			method:   http.MethodGet,
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "confirm with wrong data",
+			endpoint: confirmTokenPricing,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testWrongDataConfirmation},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "confirm with correct data",
+			endpoint: confirmTokenPricing,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testData},
+			assert:   assertPendingActivities,
+		},
+		{
+			msg:      "confirm again after confirmed",
+			endpoint: confirmTokenPricing,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testData},
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "getting pending token pricing after confirmed",
+			endpoint: getPendingTokenPricing,
+			method:   http.MethodGet,
+			assert:   httputil.ExpectFailure,
+		},
+		{
+			msg:      "valid post form to reject",
+			endpoint: setTokenPricing,
+			method:   http.MethodPost,
+			data:     map[string]string{"value": testWrongDataConfirmation},
+			assert:   httputil.ExpectSuccess,
+		},
+		{
+			msg:      "reject when there is pending token pricing",
+			endpoint: rejectTokenPricing,
+			method:   http.MethodPost,
+			data: map[string]string{
+				"value": "some random post form or this request will be unauthenticated",
+			},
+			assert: httputil.ExpectSuccess,
+		},
+		{
+			msg:      "reject when no pending token pricing exists",
+			endpoint: rejectTokenPricing,
+			method:   http.MethodPost,
+			data: map[string]string{
+				"value": "some random post form or this request will be unauthenticated",
+			},
+			assert: httputil.ExpectFailure,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) { testHTTPRequest(t, tc, s.r) })
+	}
+
+	// confirmations are serialized
+	testHTTPRequest(t, testCase{
+		msg:      "propose while confirming",
+		endpoint: setTokenPricing,
+		method:   http.MethodPost,
+		data:     map[string]string{"value": testData},
+		assert:   httputil.ExpectSuccess,
+	}, s.r)
+	s.confirmingTokenPricing = 1
+	testHTTPRequest(t, testCase{
+		msg:      "confirm while another confirmation is in progress",
+		endpoint: confirmTokenPricing,
+		method:   http.MethodPost,
+		data:     map[string]string{"value": testData},
+		assert:   httputil.ExpectFailure,
+	}, s.r)
+	s.confirmingTokenPricing = 0
+	testHTTPRequest(t, testCase{
+		msg:      "confirm after the other confirmation",
+		endpoint: confirmTokenPricing,
+		method:   http.MethodPost,
+		data:     map[string]string{"value": testData},
+		assert:   httputil.ExpectSuccess,
+	}, s.r)
+}
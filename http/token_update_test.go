@@ -1,14 +1,19 @@
 package http
 
 import (
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/core"
 	"github.com/KyberNetwork/reserve-data/data"
 	"github.com/KyberNetwork/reserve-data/data/storage"
@@ -439,6 +444,223 @@ func TestHTTPServerUpdateToken(t *testing.T) {
 
 }
 
+func TestHTTPServerListTokenOnChain(t *testing.T) {
+	const (
+		setPendingTokenUpdateEndpoint = "/setting/set-token-update"
+		getPendingTokenUpdateEndpoint = "/setting/pending-token-update"
+		confirmTokenUpdateEndpoint    = "/setting/confirm-token-update"
+	)
+	tmpDir, err := ioutil.TempDir("", "test_list_token_on_chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if rErr := os.RemoveAll(tmpDir); rErr != nil {
+			t.Error(rErr)
+		}
+	}()
+	boltSettingStorage, err := settingsstorage.NewBoltSettingStorage(filepath.Join(tmpDir, "setting.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenSetting, err := settings.NewTokenSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchangeSetting, err := settings.NewExchangeSetting(boltSettingStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setting, err := settings.NewSetting(tokenSetting, &settings.AddressSetting{}, exchangeSetting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage, err := storage.NewBoltStorage(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		nonce      uint64
+		failEnable = true
+	)
+	testServer := HTTPServer{
+		app:         data.NewReserveData(nil, nil, nil, nil, nil, nil, setting),
+		core:        core.NewReserveCore(testPricingBlockchain{nonce: &nonce, failEnable: &failEnable}, testStorage, setting),
+		metric:      testStorage,
+		authEnabled: false,
+		r:           gin.Default(),
+		blockchain:  testHTTPBlockchain{},
+		setting:     setting,
+	}
+	testServer.register()
+
+	var tokenUpdates map[string]common.TokenUpdate
+	if err = json.Unmarshal([]byte(tokenRequestData), &tokenUpdates); err != nil {
+		t.Fatal(err)
+	}
+	knc := tokenUpdates["KNC"]
+	knc.ListOnChain = true
+	tokenUpdates["KNC"] = knc
+	withoutPricing, err := json.Marshal(tokenUpdates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knc.Pricing = &common.TokenPricingConfig{
+		TokenControlInfo: &common.TokenControlInfo{
+			MinimalRecordResolution: big.NewInt(1000),
+			MaxPerBlockImbalance:    big.NewInt(100000),
+			MaxTotalImbalance:       big.NewInt(1000000),
+		},
+	}
+	tokenUpdates["KNC"] = knc
+	withPricing, err := json.Marshal(tokenUpdates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the confirmation is the pending token update
+	var pending []byte
+	// assertListing waits for the listing activity to finish and checks
+	// its outcome.
+	assertListing := func(complete bool) assertFn {
+		return func(t *testing.T, resp *httptest.ResponseRecorder) {
+			var result struct {
+				Success bool              `json:"success"`
+				ID      common.ActivityID `json:"id"`
+			}
+			if dErr := json.NewDecoder(resp.Body).Decode(&result); dErr != nil {
+				t.Fatal(dErr)
+			}
+			if !result.Success {
+				t.Fatalf("Expected listing started, got %+v", result)
+			}
+			var (
+				activity common.ActivityRecord
+				aErr     error
+			)
+			for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+				if activity, aErr = testStorage.GetActivity(result.ID); aErr != nil {
+					t.Fatal(aErr)
+				}
+				if activity.ExchangeStatus != common.ExchangeStatusSubmitted || time.Now().After(deadline) {
+					break
+				}
+			}
+			if activity.Action != common.ActionListToken || activity.Result["complete"] != complete {
+				t.Fatalf("Expected listing complete %v, got %+v", complete, activity)
+			}
+			raw, mErr := json.Marshal(activity.Result["listings"])
+			if mErr != nil {
+				t.Fatal(mErr)
+			}
+			var listings []common.TokenListing
+			if uErr := json.Unmarshal(raw, &listings); uErr != nil {
+				t.Fatal(uErr)
+			}
+			if len(listings) != 1 || listings[0].Complete != complete {
+				t.Fatalf("Unexpected listings %+v", listings)
+			}
+			steps := listings[0].Steps
+			if last := steps[len(steps)-1]; last.Action != common.ActionEnableTokenTrade || (last.Error == "") != complete {
+				t.Errorf("Unexpected last step %+v", last)
+			}
+		}
+	}
+
+	testHTTPRequest(t, testCase{
+		msg:      "list on chain without token control info",
+		endpoint: setPendingTokenUpdateEndpoint,
+		method:   http.MethodPost,
+		data:     map[string]string{"data": string(withoutPricing)},
+		assert:   httputil.ExpectFailure,
+	}, testServer.r)
+	testHTTPRequest(t, testCase{
+		msg:      "list on chain with token control info",
+		endpoint: setPendingTokenUpdateEndpoint,
+		method:   http.MethodPost,
+		data:     map[string]string{"data": string(withPricing)},
+		assert:   httputil.ExpectSuccess,
+	}, testServer.r)
+	getPending := testCase{
+		msg:      "get pending token update",
+		endpoint: getPendingTokenUpdateEndpoint,
+		method:   http.MethodGet,
+		assert: func(t *testing.T, resp *httptest.ResponseRecorder) {
+			var result struct {
+				Success bool            `json:"success"`
+				Data    json.RawMessage `json:"data"`
+			}
+			if dErr := json.NewDecoder(resp.Body).Decode(&result); dErr != nil {
+				t.Fatal(dErr)
+			}
+			if !result.Success {
+				t.Fatalf("Expected pending token update, got %+v", result)
+			}
+			pending = result.Data
+		},
+	}
+	testHTTPRequest(t, getPending, testServer.r)
+	testHTTPRequest(t, testCase{
+		msg:      "confirm with failed listing",
+		endpoint: confirmTokenUpdateEndpoint,
+		method:   http.MethodPost,
+		data:     map[string]string{"data": string(pending)},
+		assert:   assertListing(false),
+	}, testServer.r)
+	if _, err = setting.GetInternalTokenByID("KNC"); err == nil {
+		t.Fatal("Expected KNC not listed after failed listing")
+	}
+	// the pending token update is kept to retry
+	testHTTPRequest(t, getPending, testServer.r)
+	failEnable = false
+	testHTTPRequest(t, testCase{
+		msg:      "confirm with successful listing",
+		endpoint: confirmTokenUpdateEndpoint,
+		method:   http.MethodPost,
+		data:     map[string]string{"data": string(pending)},
+		assert:   assertListing(true),
+	}, testServer.r)
+	if _, err = setting.GetInternalTokenByID("KNC"); err != nil {
+		t.Fatalf("Expected KNC listed, got %s", err)
+	}
+
+	// KNC is enabled before listing OMG fails, it must be disabled
+	tokenUpdates["OMG"] = common.TokenUpdate{ListOnChain: true}
+	job := common.TokenListingJob{Tokens: tokensListOnChain(tokenUpdates)}
+	id, err := testServer.core.RecordTokenListing(common.ActivityID{}, job, common.ExchangeStatusSubmitted, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := false
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	testServer.runTokenListing(c, id, job, tokenUpdates, func() error {
+		applied = true
+		return nil
+	})
+	if applied {
+		t.Error("Expected token update not applied after failed listing")
+	}
+	activity, err := testStorage.GetActivity(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activity.ExchangeStatus != common.ExchangeStatusFailed || activity.Result["complete"] != false {
+		t.Fatalf("Expected failed listing, got %+v", activity)
+	}
+	raw, err := json.Marshal(activity.Result["rollbacks"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rollbacks []common.TokenListingStep
+	if err = json.Unmarshal(raw, &rollbacks); err != nil {
+		t.Fatal(err)
+	}
+	if len(rollbacks) != 1 || rollbacks[0].Action != common.ActionDisableTokenTrade || rollbacks[0].Error != "" {
+		t.Errorf("Expected KNC disabled, got rollbacks %+v", rollbacks)
+	}
+}
+
 type testHTTPBlockchain struct{}
 
 func (tbc testHTTPBlockchain) CheckTokenIndices(addr ethereum.Address) error {
@@ -465,3 +687,40 @@ func (tbc testHTTPBlockchain) GetDepositOPAddress() ethereum.Address {
 func (tbc testHTTPBlockchain) GetIntermediatorOPAddress() ethereum.Address {
 	return ethereum.Address{}
 }
+
+func (tbc testHTTPBlockchain) CurrentBlock() (uint64, error) {
+	return 6268056, nil
+}
+
+func (tbc testHTTPBlockchain) GetStepFunctions(token ethereum.Address, atBlock uint64) (common.StepFunctionResponse, error) {
+	return common.StepFunctionResponse{
+		QuantityStepResponse: common.QuantityStepFunction{
+			XBuy: []*big.Int{big.NewInt(0)}, YBuy: []*big.Int{big.NewInt(0)},
+			XSell: []*big.Int{big.NewInt(0)}, YSell: []*big.Int{big.NewInt(0)},
+		},
+		ImbalanceStepResponse: common.ImbalanceStepFunction{
+			XBuy: []*big.Int{big.NewInt(100)}, YBuy: []*big.Int{big.NewInt(-10)},
+			XSell: []*big.Int{big.NewInt(-100)}, YSell: []*big.Int{big.NewInt(-10)},
+		},
+	}, nil
+}
+
+func (tbc testHTTPBlockchain) GetTokenControlInfo(token ethereum.Address, atBlock uint64) (common.TokenControlInfo, error) {
+	return common.TokenControlInfo{
+		MinimalRecordResolution: big.NewInt(1000),
+		MaxPerBlockImbalance:    big.NewInt(100000),
+		MaxTotalImbalance:       big.NewInt(1000000),
+	}, nil
+}
+
+func (tbc testHTTPBlockchain) CheckAlerter() error {
+	return nil
+}
+
+func (tbc testHTTPBlockchain) GetPricingAdmin() (ethereum.Address, error) {
+	return ethereum.Address{}, nil
+}
+
+func (tbc testHTTPBlockchain) CheckTokenContract(token common.Token) error {
+	return nil
+}
@@ -83,7 +83,8 @@ type ReserveData interface {
 }
 
 // ReserveCore is the interface that wrap around all interactions
-// with exchanges and blockchain.
+// with exchanges and blockchain. The keyID is the ID of the API key
+// requesting the action, it is recorded on the created activity.
 type ReserveCore interface {
 	// place order
 	Trade(
@@ -93,22 +94,42 @@ type ReserveCore interface {
 		quote common.Token,
 		rate float64,
 		amount float64,
-		timestamp uint64) (id common.ActivityID, done float64, remaining float64, finished bool, err error)
+		timestamp uint64,
+		keyID string) (id common.ActivityID, done float64, remaining float64, finished bool, err error)
 
 	Deposit(
 		exchange common.Exchange,
 		token common.Token,
 		amount *big.Int,
-		timestamp uint64) (common.ActivityID, error)
+		timestamp uint64,
+		keyID string) (common.ActivityID, error)
 
 	Withdraw(
 		exchange common.Exchange,
 		token common.Token,
 		amount *big.Int,
-		timestamp uint64) (common.ActivityID, error)
+		timestamp uint64,
+		keyID string) (common.ActivityID, error)
 
 	CancelOrder(id common.ActivityID, exchange common.Exchange) error
 
 	// blockchain related action
-	SetRates(tokens []common.Token, buys, sells []*big.Int, block *big.Int, afpMid []*big.Int, msgs []string) (common.ActivityID, error)
+	SetRates(tokens []common.Token, buys, sells []*big.Int, block *big.Int, afpMid []*big.Int, msgs []string, keyID string) (common.ActivityID, error)
+	// ForceSetRates sets rates without the set rate guard
+	ForceSetRates(tokens []common.Token, buys, sells []*big.Int, block *big.Int, afpMid []*big.Int, msgs []string, keyID string) (common.ActivityID, error)
+
+	// pricing contract configuration of a token
+	SetQtyStepFunction(token common.Token, stepFunction common.QuantityStepFunction, keyID string) (common.ActivityID, error)
+	SetImbalanceStepFunction(token common.Token, stepFunction common.ImbalanceStepFunction, keyID string) (common.ActivityID, error)
+	SetTokenControlInfo(token common.Token, info common.TokenControlInfo, keyID string) (common.ActivityID, error)
+
+	// token listing in pricing contract
+	ListToken(token common.Token, pricing common.TokenPricingConfig, keyID string) (common.TokenListing, error)
+	RecordTokenListing(id common.ActivityID, job common.TokenListingJob, status string, keyID string) (common.ActivityID, error)
+	// WaitTxMined waits for the transaction of a pricing activity to be mined
+	WaitTxMined(id common.ActivityID) (string, error)
+	DisableTokenTrade(token common.Token, keyID string) (common.ActivityID, error)
+	// ZeroTokenRates sets the base rates of token to zero
+	ZeroTokenRates(token common.Token, keyID string) (common.ActivityID, error)
+	RecordTokenDelisting(id common.ActivityID, token common.Token, delisting common.TokenDelisting, status string, keyID string) (common.ActivityID, error)
 }
@@ -15,6 +15,11 @@ type MetricStorage interface {
 	GetRebalanceControl() (common.RebalanceControl, error)
 	GetSetrateControl() (common.SetrateControl, error)
 	GetPWIEquation() (common.PWIEquation, error)
+	// StoreSetRateGuard replaces the configuration of set rate guard.
+	StoreSetRateGuard(guard common.SetRateGuard) error
+	// GetSetRateGuard returns the configuration of set rate guard, the guard
+	// is disabled if it was never stored.
+	GetSetRateGuard() (common.SetRateGuard, error)
 
 	SetStableTokenParams(value []byte) error
 	ConfirmStableTokenParams(value []byte) error
@@ -44,4 +49,18 @@ type MetricStorage interface {
 	StorePendingTokenUpdateInfo(common.TokenTargetQtyV2, common.PWIEquationRequestV2, common.RebalanceQuadraticRequest) error
 	ConfirmTokenUpdateInfo(common.TokenTargetQtyV2, common.PWIEquationRequestV2, common.RebalanceQuadraticRequest) error
 	RemovePendingTokenUpdateInfo() error
+
+	// StoreConfigProposal creates or replaces the configuration proposal with the same ID.
+	StoreConfigProposal(proposal common.ConfigProposal) error
+	// GetConfigProposals returns the configuration proposals created in [fromTime, toTime].
+	GetConfigProposals(fromTime, toTime uint64) ([]common.ConfigProposal, error)
+	// GetPendingConfigProposals returns the pending proposals of given configuration type.
+	GetPendingConfigProposals(configType string) ([]common.ConfigProposal, error)
+
+	// GetConfigVersions returns all confirmed versions of the configuration type, latest first.
+	GetConfigVersions(configType string) ([]common.ConfigVersion, error)
+	// GetConfigVersion returns the given version of the configuration type.
+	GetConfigVersion(configType string, version uint64) (common.ConfigVersion, error)
+	// GetConfigVersionAt returns the version of the configuration type which was active at timestamp.
+	GetConfigVersionAt(configType string, timestamp uint64) (common.ConfigVersion, error)
 }
@@ -0,0 +1,75 @@
+package pnl
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/settings"
+)
+
+const (
+	tradeHistoryEndpoint = "tradehistory"
+	activitiesEndpoint   = "activities"
+	exchangeFeesEndpoint = "exchangefees"
+)
+
+// CoreClient requests the exchange trades, activities, tokens and exchange
+// fees from core APIs, it lets a stat server running without core compute
+// the P&L.
+type CoreClient struct {
+	*settings.SettingClient
+}
+
+// NewCoreClient creates a CoreClient requesting core by client.
+func NewCoreClient(client *settings.SettingClient) *CoreClient {
+	return &CoreClient{SettingClient: client}
+}
+
+// GetTradeHistory returns the exchange trades from fromTime to toTime in
+// milliseconds.
+func (self *CoreClient) GetTradeHistory(fromTime, toTime uint64) (common.AllTradeHistory, error) {
+	var result common.AllTradeHistory
+	err := self.Get(tradeHistoryEndpoint, map[string]string{
+		"fromTime": strconv.FormatUint(fromTime, 10),
+		"toTime":   strconv.FormatUint(toTime, 10),
+	}, &result)
+	return result, err
+}
+
+// GetRecords returns the activities from fromTime to toTime in nanoseconds,
+// core API takes milliseconds so the range is widened then filtered.
+func (self *CoreClient) GetRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error) {
+	var records []common.ActivityRecord
+	err := self.Get(activitiesEndpoint, map[string]string{
+		"fromTime": strconv.FormatUint(fromTime/1000000, 10),
+		"toTime":   strconv.FormatUint((toTime+999999)/1000000, 10),
+	}, &records)
+	if err != nil {
+		return nil, err
+	}
+	var result []common.ActivityRecord
+	for _, record := range records {
+		if record.ID.Timepoint >= fromTime && record.ID.Timepoint <= toTime {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+// GetFee returns the fees of exchange ex.
+func (self *CoreClient) GetFee(ex settings.ExchangeName) (common.ExchangeFees, error) {
+	var fees map[string]common.ExchangeFees
+	if err := self.Get(exchangeFeesEndpoint, map[string]string{}, &fees); err != nil {
+		return common.ExchangeFees{}, err
+	}
+	for name, value := range settings.ExchangeTypeValues() {
+		if value != ex {
+			continue
+		}
+		if fee, ok := fees[name]; ok {
+			return fee, nil
+		}
+	}
+	return common.ExchangeFees{}, fmt.Errorf("Core has no fees of exchange %s", ex)
+}
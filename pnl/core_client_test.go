@@ -0,0 +1,90 @@
+package pnl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/settings"
+)
+
+type testAuthentication struct{}
+
+func (self testAuthentication) KNSign(message string) string {
+	return "signed"
+}
+
+func (self testAuthentication) RequestMessage(method, path, params string) string {
+	return method + path + params
+}
+
+func TestCoreClient(t *testing.T) {
+	var (
+		queries = map[string]string{}
+		from    = testDay * 1000000
+		to      = (testDay+hour)*1000000 - 1
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries[r.URL.Path] = r.URL.Query().Get("fromTime") + "-" + r.URL.Query().Get("toTime")
+		var data interface{}
+		switch r.URL.Path {
+		case "/activities":
+			data = []common.ActivityRecord{
+				{Action: common.ActionWithdraw, ID: common.NewActivityID(from, "1")},
+				// out of the range in nanoseconds
+				{Action: common.ActionWithdraw, ID: common.NewActivityID(to+1, "2")},
+			}
+		case "/exchangefees":
+			data = map[string]common.ExchangeFees{
+				"binance": {Funding: common.FundingFee{Withdraw: map[string]float64{"OMG": 1}}},
+			}
+		case "/tradehistory":
+			data = common.AllTradeHistory{Data: map[common.ExchangeID]common.ExchangeTradeHistory{
+				"binance": {"OMG-ETH": {{ID: "1", Qty: 100}}},
+			}}
+		default:
+			if err := json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "reason": "not found"}); err != nil {
+				t.Error(err)
+			}
+			return
+		}
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": data}); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+	client := NewCoreClient(settings.NewSettingClient(testAuthentication{}, time.Second, server.URL))
+
+	records, err := client.GetRecords(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID.EID != "1" {
+		t.Errorf("expected the activity in the range, got %+v", records)
+	}
+	if expected := "1500076800000-1500080400000"; queries["/activities"] != expected {
+		t.Errorf("expected activities requested in milliseconds %s, got %s", expected, queries["/activities"])
+	}
+
+	fees, err := client.GetFee(settings.Binance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees.Funding.Withdraw["OMG"] != 1 {
+		t.Errorf("unexpected binance fees %+v", fees)
+	}
+	if _, err = client.GetFee(settings.Huobi); err == nil {
+		t.Error("expected error getting fees of an exchange core doesn't have")
+	}
+
+	history, err := client.GetTradeHistory(testDay, testDay+hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trades := history.Data["binance"]["OMG-ETH"]; len(trades) != 1 || trades[0].Qty != 100 {
+		t.Errorf("unexpected trade history %+v", history)
+	}
+}
@@ -0,0 +1,289 @@
+package pnl
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/settings"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// MaxRange is the maximum time range of a report in milliseconds.
+	MaxRange uint64 = 90 * 86400000
+	// historyRange is the time range of trade history and activities
+	// requested at once in milliseconds, exchange storages limit it to 3 days.
+	historyRange uint64 = 86400000
+
+	dayMs  = uint64(24 * time.Hour / time.Millisecond)
+	hourMs = int64(time.Hour / time.Millisecond)
+
+	ethID             = "ETH"
+	kncID             = "KNC"
+	ethDecimals int64 = 18
+	// feeDecimals is the decimals of burn fees and wallet fees in KNC.
+	feeDecimals int64 = 18
+	// takerFee is the key of taker fee in exchanges trading fees, hedge
+	// trades are assumed to be taker orders.
+	takerFee = "taker"
+)
+
+// TradeLogStorage is the storage of trade logs in nanoseconds.
+type TradeLogStorage interface {
+	GetTradeLogs(fromTime uint64, toTime uint64) ([]common.TradeLog, error)
+	MaxRange() uint64
+}
+
+// FeeSetRateStorage is the storage of set rates transactions gas.
+type FeeSetRateStorage interface {
+	GetFeeSetRateByDay(fromTime uint64, toTime uint64) ([]common.FeeSetRate, error)
+}
+
+// ReserveData provides the exchange trades and withdrawals of the reserve,
+// it is implemented by data.ReserveData. The times of trade history are in
+// milliseconds, the ones of records in nanoseconds.
+type ReserveData interface {
+	GetTradeHistory(fromTime, toTime uint64) (common.AllTradeHistory, error)
+	GetRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error)
+}
+
+// Setting provides tokens and exchanges fees.
+type Setting interface {
+	GetTokenByAddress(addr ethereum.Address) (common.Token, error)
+	GetFee(ex settings.ExchangeName) (common.ExchangeFees, error)
+}
+
+// Calculator computes the profit and loss of a reserve from the on-chain
+// trades, the exchange hedge trades and the fees.
+type Calculator struct {
+	logStorage        TradeLogStorage
+	feeSetRateStorage FeeSetRateStorage
+	data              ReserveData
+	setting           Setting
+	reserve           ethereum.Address
+}
+
+// NewCalculator creates a Calculator of the reserve contract at reserve.
+func NewCalculator(logStorage TradeLogStorage, feeSetRateStorage FeeSetRateStorage, data ReserveData, setting Setting, reserve ethereum.Address) *Calculator {
+	return &Calculator{
+		logStorage:        logStorage,
+		feeSetRateStorage: feeSetRateStorage,
+		data:              data,
+		setting:           setting,
+		reserve:           reserve,
+	}
+}
+
+// Compute returns the P&L of the reserve from fromTime to toTime
+// (milliseconds) by days of timezone (UTC offset in hours).
+func (self *Calculator) Compute(fromTime, toTime uint64, timezone int64) (Report, error) {
+	if toTime <= fromTime {
+		return Report{}, errors.New("toTime must be after fromTime")
+	}
+	if toTime-fromTime > MaxRange {
+		return Report{}, fmt.Errorf("Time range is too broad, it must be smaller or equal to %d milliseconds", MaxRange)
+	}
+	report := newReport(timezone)
+	if err := self.addTradeLogs(report, fromTime, toTime); err != nil {
+		return Report{}, err
+	}
+	if err := self.addTradeHistory(report, fromTime, toTime); err != nil {
+		return Report{}, err
+	}
+	if err := self.addWithdrawals(report, fromTime, toTime); err != nil {
+		return Report{}, err
+	}
+	if err := self.addSetRateFees(report, fromTime, toTime); err != nil {
+		return Report{}, err
+	}
+	report.finalize()
+	return *report, nil
+}
+
+// addTradeLogs adds the trades of the reserve and the prices of all trades.
+func (self *Calculator) addTradeLogs(report *Report, fromTime, toTime uint64) error {
+	maxRange := self.logStorage.MaxRange()
+	// trade logs are in nanoseconds and the range is inclusive
+	for from := fromTime * 1000000; from < toTime*1000000; from += maxRange {
+		to := from + maxRange - 1
+		if to >= toTime*1000000 {
+			to = toTime*1000000 - 1
+		}
+		tradeLogs, err := self.logStorage.GetTradeLogs(from, to)
+		if err != nil {
+			return err
+		}
+		for _, l := range tradeLogs {
+			if err = self.addTradeLog(report, l); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (self *Calculator) addTradeLog(report *Report, l common.TradeLog) error {
+	src, err := self.setting.GetTokenByAddress(l.SrcAddress)
+	if err != nil {
+		log.Printf("PnL: skipping trade %s of unknown token %s", l.TransactionHash.Hex(), l.SrcAddress.Hex())
+		return nil
+	}
+	dest, err := self.setting.GetTokenByAddress(l.DestAddress)
+	if err != nil {
+		log.Printf("PnL: skipping trade %s of unknown token %s", l.TransactionHash.Hex(), l.DestAddress.Hex())
+		return nil
+	}
+	var (
+		timepoint  = l.Timestamp / 1000000
+		srcAmount  = common.BigToFloat(l.SrcAmount, src.Decimals)
+		destAmount = common.BigToFloat(l.DestAmount, dest.Decimals)
+		ethAmount  float64
+	)
+	switch {
+	case src.IsETH():
+		ethAmount = srcAmount
+	case dest.IsETH():
+		ethAmount = destAmount
+	case l.EtherReceivalAmount != nil:
+		ethAmount = common.BigToFloat(l.EtherReceivalAmount, ethDecimals)
+	default:
+		return nil
+	}
+	report.addPrice(timepoint, src.ID, srcAmount, ethAmount)
+	report.addPrice(timepoint, dest.ID, destAmount, ethAmount)
+
+	// the reserve of a trade sells the dest token for ETH or buys the src
+	// token with ETH, token to token trades go through two reserves and the
+	// one buying the src token is the ether receival sender.
+	var traded string
+	if l.ReserveAddress == self.reserve && !dest.IsETH() {
+		report.token(timepoint, dest.ID).addOnchain(false, destAmount, ethAmount)
+		traded = dest.ID
+	}
+	if (l.ReserveAddress == self.reserve && dest.IsETH()) || (l.EtherReceivalSender == self.reserve && !src.IsETH() && !dest.IsETH()) {
+		report.token(timepoint, src.ID).addOnchain(true, srcAmount, ethAmount)
+		if traded == "" {
+			traded = src.ID
+		}
+	}
+	// fees are charged to the reserve of the trade log
+	if traded == "" || l.ReserveAddress != self.reserve {
+		return nil
+	}
+	pnl := report.token(timepoint, traded)
+	if l.BurnFee != nil {
+		pnl.BurnFeeKNC += common.BigToFloat(l.BurnFee, feeDecimals)
+	}
+	if l.WalletFee != nil {
+		pnl.WalletFeeKNC += common.BigToFloat(l.WalletFee, feeDecimals)
+	}
+	return nil
+}
+
+// addTradeHistory adds the exchanges trades against ETH.
+func (self *Calculator) addTradeHistory(report *Report, fromTime, toTime uint64) error {
+	seen := map[string]bool{}
+	for from := fromTime; from < toTime; from += historyRange {
+		to := from + historyRange
+		if to > toTime {
+			to = toTime
+		}
+		history, err := self.data.GetTradeHistory(from, to)
+		if err != nil {
+			return err
+		}
+		for exchangeID, pairs := range history.Data {
+			fees, fErr := self.exchangeFees(string(exchangeID))
+			if fErr != nil {
+				return fErr
+			}
+			for pairID, trades := range pairs {
+				tokens := strings.Split(string(pairID), "-")
+				if len(tokens) != 2 || tokens[1] != ethID {
+					continue
+				}
+				for _, trade := range trades {
+					key := fmt.Sprintf("%s|%s|%s", exchangeID, pairID, trade.ID)
+					if seen[key] || trade.Timestamp < fromTime || trade.Timestamp >= toTime {
+						continue
+					}
+					seen[key] = true
+					ethAmount := trade.Qty * trade.Price
+					pnl := report.token(trade.Timestamp, tokens[0])
+					pnl.addExchange(trade.Type == "buy", trade.Qty, ethAmount)
+					pnl.ExchangeFee += ethAmount * fees.Trading[takerFee]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// addWithdrawals adds the fees of the withdrawals done by exchanges.
+func (self *Calculator) addWithdrawals(report *Report, fromTime, toTime uint64) error {
+	var records []common.ActivityRecord
+	for from := fromTime; from < toTime; from += historyRange {
+		to := from + historyRange
+		if to > toTime {
+			to = toTime
+		}
+		chunk, err := self.data.GetRecords(from*1000000, to*1000000-1)
+		if err != nil {
+			return err
+		}
+		records = append(records, chunk...)
+	}
+	for _, record := range records {
+		if record.Action != common.ActionWithdraw || record.ExchangeStatus != common.ExchangeStatusDone {
+			continue
+		}
+		timepoint := record.Timestamp.MustToUint64()
+		if timepoint < fromTime || timepoint >= toTime {
+			continue
+		}
+		tokenID, ok := record.Params["token"].(string)
+		if !ok {
+			continue
+		}
+		fees, fErr := self.exchangeFees(record.Destination)
+		if fErr != nil {
+			return fErr
+		}
+		report.token(timepoint, tokenID).WithdrawFeeQty += fees.Funding.Withdraw[tokenID]
+	}
+	return nil
+}
+
+// addSetRateFees adds the gas of set rates transactions, they are recorded
+// by UTC days.
+func (self *Calculator) addSetRateFees(report *Report, fromTime, toTime uint64) error {
+	fees, err := self.feeSetRateStorage.GetFeeSetRateByDay(fromTime, toTime)
+	if err != nil {
+		return err
+	}
+	for _, fee := range fees {
+		if fee.GasUsed == nil {
+			continue
+		}
+		// the timestamp is the beginning of the day in seconds
+		timepoint := fee.TimeStamp * 1000
+		if timepoint < fromTime || timepoint >= toTime {
+			continue
+		}
+		gas, _ := fee.GasUsed.Float64()
+		report.bucket(timepoint).SetRateFee += gas
+	}
+	return nil
+}
+
+func (self *Calculator) exchangeFees(exchangeID string) (common.ExchangeFees, error) {
+	name, ok := settings.ExchangeTypeValues()[exchangeID]
+	if !ok {
+		return common.ExchangeFees{}, fmt.Errorf("Unknown exchange %s", exchangeID)
+	}
+	return self.setting.GetFee(name)
+}
@@ -0,0 +1,228 @@
+package pnl
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/settings"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	testDay uint64 = 1500076800000 // 2017-07-15 00:00:00 UTC
+	hour           = uint64(time.Hour / time.Millisecond)
+)
+
+var (
+	testReserve = ethereum.HexToAddress("0x63825c174ab367968EC60f061753D3bbD36A0D8F")
+	testOther   = ethereum.HexToAddress("0x21433Dec9Cb634A23c6A4BbcCe08c83f5aC2EC18")
+	testTokens  = []common.Token{
+		{ID: "ETH", Address: "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", Decimals: 18},
+		{ID: "KNC", Address: "0xdd974d5c2e2928dea5f71b9825b8b646686bd200", Decimals: 18},
+		{ID: "OMG", Address: "0xd26114cd6ee289accf82350c8d8487fedb8a0c07", Decimals: 18},
+	}
+)
+
+type testLogStorage []common.TradeLog
+
+func (self testLogStorage) GetTradeLogs(fromTime, toTime uint64) ([]common.TradeLog, error) {
+	var result []common.TradeLog
+	for _, l := range self {
+		if l.Timestamp >= fromTime && l.Timestamp <= toTime {
+			result = append(result, l)
+		}
+	}
+	return result, nil
+}
+
+func (self testLogStorage) MaxRange() uint64 {
+	return uint64(24 * time.Hour)
+}
+
+type testFeeSetRateStorage []common.FeeSetRate
+
+func (self testFeeSetRateStorage) GetFeeSetRateByDay(fromTime, toTime uint64) ([]common.FeeSetRate, error) {
+	return self, nil
+}
+
+type testReserveData struct {
+	history common.AllTradeHistory
+	records []common.ActivityRecord
+}
+
+// GetTradeHistory returns the whole history regardless of the range, the
+// calculator must filter and deduplicate it.
+func (self testReserveData) GetTradeHistory(fromTime, toTime uint64) (common.AllTradeHistory, error) {
+	return self.history, nil
+}
+
+func (self testReserveData) GetRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error) {
+	var result []common.ActivityRecord
+	for _, record := range self.records {
+		if record.ID.Timepoint >= fromTime && record.ID.Timepoint <= toTime {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+type testSetting struct{}
+
+func (self testSetting) GetTokenByAddress(addr ethereum.Address) (common.Token, error) {
+	for _, token := range testTokens {
+		if ethereum.HexToAddress(token.Address) == addr {
+			return token, nil
+		}
+	}
+	return common.Token{}, errors.New("token not found")
+}
+
+func (self testSetting) GetFee(ex settings.ExchangeName) (common.ExchangeFees, error) {
+	return common.NewExchangeFee(
+		common.TradingFee{"taker": 0.001, "maker": 0.001},
+		common.FundingFee{Withdraw: map[string]float64{"OMG": 1}},
+	), nil
+}
+
+func tokenAddress(id string) ethereum.Address {
+	for _, token := range testTokens {
+		if token.ID == id {
+			return ethereum.HexToAddress(token.Address)
+		}
+	}
+	panic("unknown token " + id)
+}
+
+func wei(amount float64) *big.Int {
+	return common.FloatToBigInt(amount, 18)
+}
+
+func newTestCalculator() *Calculator {
+	tradeLogs := testLogStorage{
+		// the reserve sells 100 OMG for 1 ETH
+		{
+			Timestamp:      (testDay + hour) * 1000000,
+			SrcAddress:     tokenAddress("ETH"),
+			DestAddress:    tokenAddress("OMG"),
+			SrcAmount:      wei(1),
+			DestAmount:     wei(100),
+			ReserveAddress: testReserve,
+			BurnFee:        wei(2),
+		},
+		// another reserve sells 1000 KNC for 1 ETH
+		{
+			Timestamp:      (testDay + 2*hour) * 1000000,
+			SrcAddress:     tokenAddress("ETH"),
+			DestAddress:    tokenAddress("KNC"),
+			SrcAmount:      wei(1),
+			DestAmount:     wei(1000),
+			ReserveAddress: testOther,
+			BurnFee:        wei(3),
+		},
+	}
+	data := testReserveData{
+		history: common.AllTradeHistory{
+			Data: map[common.ExchangeID]common.ExchangeTradeHistory{
+				"binance": {
+					"OMG-ETH": {
+						{ID: "1", Price: 0.009, Qty: 100, Type: "buy", Timestamp: testDay + 3*hour},
+						// outside of the range
+						{ID: "2", Price: 0.009, Qty: 100, Type: "sell", Timestamp: testDay + 25*hour},
+					},
+					"OMG-BTC": {
+						{ID: "3", Price: 0.1, Qty: 100, Type: "buy", Timestamp: testDay + 3*hour},
+					},
+				},
+			},
+		},
+		records: []common.ActivityRecord{
+			{
+				Action:         common.ActionWithdraw,
+				ID:             common.NewActivityID((testDay+4*hour)*1000000, "withdraw1"),
+				Destination:    "binance",
+				Params:         map[string]interface{}{"token": "OMG", "amount": "50"},
+				ExchangeStatus: common.ExchangeStatusDone,
+				Timestamp:      common.Timestamp(strconv.FormatUint(testDay+4*hour, 10)),
+			},
+			{
+				Action:         common.ActionWithdraw,
+				ID:             common.NewActivityID((testDay+5*hour)*1000000, "withdraw2"),
+				Destination:    "binance",
+				Params:         map[string]interface{}{"token": "OMG", "amount": "50"},
+				ExchangeStatus: common.ExchangeStatusFailed,
+				Timestamp:      common.Timestamp(strconv.FormatUint(testDay+5*hour, 10)),
+			},
+		},
+	}
+	feeSetRates := testFeeSetRateStorage{
+		{TimeStamp: testDay / 1000, GasUsed: big.NewFloat(0.05)},
+	}
+	return NewCalculator(tradeLogs, feeSetRates, data, testSetting{}, testReserve)
+}
+
+func expectFloat(t *testing.T, name string, expected, actual float64) {
+	if math.Abs(expected-actual) > 1e-9 {
+		t.Errorf("expected %s %f, got %f", name, expected, actual)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	report, err := newTestCalculator().Compute(testDay, testDay+24*hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Buckets) != 1 {
+		t.Fatalf("expected 1 bucket, got %d", len(report.Buckets))
+	}
+	bucket := report.Buckets[testDay]
+	if bucket == nil {
+		t.Fatalf("expected bucket at %d, got %+v", testDay, report.Buckets)
+	}
+	if len(bucket.Tokens) != 1 {
+		t.Fatalf("expected P&L of OMG only, got %+v", bucket.Tokens)
+	}
+	omg := bucket.Tokens["OMG"]
+	expectFloat(t, "on-chain sell qty", 100, omg.OnchainSellQty)
+	expectFloat(t, "exchange buy qty", 100, omg.ExchangeBuyQty)
+	expectFloat(t, "realized P&L", 100*(0.01-0.009), omg.RealizedPnL)
+	// KNC is priced from the trade of the other reserve
+	expectFloat(t, "burn fee", 2*0.001, omg.BurnFee)
+	expectFloat(t, "exchange fee", 0.9*0.001, omg.ExchangeFee)
+	// OMG is priced from the on-chain trade
+	expectFloat(t, "withdraw fee", 0.01, omg.WithdrawFee)
+	tokenPnL := 0.1 - 0.002 - 0.0009 - 0.01
+	expectFloat(t, "token net P&L", tokenPnL, omg.NetPnL)
+	expectFloat(t, "set rate fee", 0.05, bucket.SetRateFee)
+	expectFloat(t, "bucket net P&L", tokenPnL-0.05, bucket.NetPnL)
+	expectFloat(t, "total net P&L", tokenPnL-0.05, report.Total.NetPnL)
+	expectFloat(t, "total realized P&L", 0.1, report.Total.Tokens["OMG"].RealizedPnL)
+}
+
+func TestComputeTimezone(t *testing.T) {
+	report, err := newTestCalculator().Compute(testDay-7*hour, testDay+17*hour, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// all the trades are in the day starting at 17:00 UTC the day before
+	bucket := report.Buckets[testDay-7*hour]
+	if len(report.Buckets) != 1 || bucket == nil {
+		t.Fatalf("expected 1 bucket at %d, got %+v", testDay-7*hour, report.Buckets)
+	}
+	expectFloat(t, "set rate fee", 0.05, bucket.SetRateFee)
+	expectFloat(t, "realized P&L", 0.1, bucket.Tokens["OMG"].RealizedPnL)
+}
+
+func TestComputeRange(t *testing.T) {
+	calculator := newTestCalculator()
+	if _, err := calculator.Compute(testDay, testDay, 0); err == nil {
+		t.Error("expected error on empty range")
+	}
+	if _, err := calculator.Compute(testDay, testDay+MaxRange+1, 0); err == nil {
+		t.Error("expected error on too broad range")
+	}
+}
@@ -0,0 +1,205 @@
+package pnl
+
+import "math"
+
+// TokenPnL is the P&L of a token, amounts of ETH and fees are in ETH,
+// quantities are in token.
+type TokenPnL struct {
+	OnchainBuyQty   float64 `json:"onchain_buy_qty"`
+	OnchainBuyETH   float64 `json:"onchain_buy_eth"`
+	OnchainSellQty  float64 `json:"onchain_sell_qty"`
+	OnchainSellETH  float64 `json:"onchain_sell_eth"`
+	ExchangeBuyQty  float64 `json:"exchange_buy_qty"`
+	ExchangeBuyETH  float64 `json:"exchange_buy_eth"`
+	ExchangeSellQty float64 `json:"exchange_sell_qty"`
+	ExchangeSellETH float64 `json:"exchange_sell_eth"`
+	// RealizedPnL is the P&L of the quantity both bought and sold, at the
+	// average buy and sell prices.
+	RealizedPnL    float64 `json:"realized_pnl"`
+	BurnFeeKNC     float64 `json:"burn_fee_knc"`
+	BurnFee        float64 `json:"burn_fee"`
+	WalletFeeKNC   float64 `json:"wallet_fee_knc"`
+	WalletFee      float64 `json:"wallet_fee"`
+	ExchangeFee    float64 `json:"exchange_fee"`
+	WithdrawFeeQty float64 `json:"withdraw_fee_qty"`
+	WithdrawFee    float64 `json:"withdraw_fee"`
+	NetPnL         float64 `json:"net_pnl"`
+}
+
+func (self *TokenPnL) addOnchain(buy bool, qty, eth float64) {
+	if buy {
+		self.OnchainBuyQty += qty
+		self.OnchainBuyETH += eth
+	} else {
+		self.OnchainSellQty += qty
+		self.OnchainSellETH += eth
+	}
+}
+
+func (self *TokenPnL) addExchange(buy bool, qty, eth float64) {
+	if buy {
+		self.ExchangeBuyQty += qty
+		self.ExchangeBuyETH += eth
+	} else {
+		self.ExchangeSellQty += qty
+		self.ExchangeSellETH += eth
+	}
+}
+
+func (self *TokenPnL) computeRealized() {
+	buyQty := self.OnchainBuyQty + self.ExchangeBuyQty
+	sellQty := self.OnchainSellQty + self.ExchangeSellQty
+	if buyQty == 0 || sellQty == 0 {
+		self.RealizedPnL = 0
+		return
+	}
+	avgBuy := (self.OnchainBuyETH + self.ExchangeBuyETH) / buyQty
+	avgSell := (self.OnchainSellETH + self.ExchangeSellETH) / sellQty
+	self.RealizedPnL = math.Min(buyQty, sellQty) * (avgSell - avgBuy)
+}
+
+func (self *TokenPnL) add(other *TokenPnL) {
+	self.OnchainBuyQty += other.OnchainBuyQty
+	self.OnchainBuyETH += other.OnchainBuyETH
+	self.OnchainSellQty += other.OnchainSellQty
+	self.OnchainSellETH += other.OnchainSellETH
+	self.ExchangeBuyQty += other.ExchangeBuyQty
+	self.ExchangeBuyETH += other.ExchangeBuyETH
+	self.ExchangeSellQty += other.ExchangeSellQty
+	self.ExchangeSellETH += other.ExchangeSellETH
+	self.RealizedPnL += other.RealizedPnL
+	self.BurnFeeKNC += other.BurnFeeKNC
+	self.BurnFee += other.BurnFee
+	self.WalletFeeKNC += other.WalletFeeKNC
+	self.WalletFee += other.WalletFee
+	self.ExchangeFee += other.ExchangeFee
+	self.WithdrawFeeQty += other.WithdrawFeeQty
+	self.WithdrawFee += other.WithdrawFee
+	self.NetPnL += other.NetPnL
+}
+
+// Bucket is the P&L of the reserve in a day, the gas of set rates
+// transactions is not attributed to tokens.
+type Bucket struct {
+	Tokens     map[string]*TokenPnL `json:"tokens"`
+	SetRateFee float64              `json:"set_rate_fee"`
+	NetPnL     float64              `json:"net_pnl"`
+}
+
+func newBucket() *Bucket {
+	return &Bucket{Tokens: map[string]*TokenPnL{}}
+}
+
+// Report is the P&L of the reserve by days, keyed by the beginning of the
+// days in milliseconds. Total is the sum of the days.
+type Report struct {
+	Timezone int64              `json:"timezone"`
+	Buckets  map[uint64]*Bucket `json:"buckets"`
+	Total    *Bucket            `json:"total"`
+
+	// volumes of all trades used to price fees in ETH, by day and for the
+	// whole range
+	volumes      map[uint64]map[string]*volume
+	rangeVolumes map[string]*volume
+}
+
+type volume struct {
+	qty float64
+	eth float64
+}
+
+func newReport(timezone int64) *Report {
+	return &Report{
+		Timezone:     timezone,
+		Buckets:      map[uint64]*Bucket{},
+		volumes:      map[uint64]map[string]*volume{},
+		rangeVolumes: map[string]*volume{},
+	}
+}
+
+// bucketTime returns the beginning of the day of timepoint (ms) in the
+// timezone of the report.
+func (self *Report) bucketTime(timepoint uint64) uint64 {
+	offset := self.Timezone * hourMs
+	return uint64((int64(timepoint)+offset)/int64(dayMs)*int64(dayMs) - offset)
+}
+
+func (self *Report) bucket(timepoint uint64) *Bucket {
+	key := self.bucketTime(timepoint)
+	bucket, ok := self.Buckets[key]
+	if !ok {
+		bucket = newBucket()
+		self.Buckets[key] = bucket
+	}
+	return bucket
+}
+
+func (self *Report) token(timepoint uint64, tokenID string) *TokenPnL {
+	bucket := self.bucket(timepoint)
+	pnl, ok := bucket.Tokens[tokenID]
+	if !ok {
+		pnl = &TokenPnL{}
+		bucket.Tokens[tokenID] = pnl
+	}
+	return pnl
+}
+
+func (self *Report) addPrice(timepoint uint64, tokenID string, qty, eth float64) {
+	if tokenID == ethID || qty == 0 {
+		return
+	}
+	key := self.bucketTime(timepoint)
+	volumes, ok := self.volumes[key]
+	if !ok {
+		volumes = map[string]*volume{}
+		self.volumes[key] = volumes
+	}
+	for _, v := range []map[string]*volume{volumes, self.rangeVolumes} {
+		if v[tokenID] == nil {
+			v[tokenID] = &volume{}
+		}
+		v[tokenID].qty += qty
+		v[tokenID].eth += eth
+	}
+}
+
+// price returns the average price in ETH of token in the day, or in the
+// whole range if it wasn't traded in the day.
+func (self *Report) price(bucketTime uint64, tokenID string) float64 {
+	if tokenID == ethID {
+		return 1
+	}
+	if v := self.volumes[bucketTime][tokenID]; v != nil {
+		return v.eth / v.qty
+	}
+	if v := self.rangeVolumes[tokenID]; v != nil {
+		return v.eth / v.qty
+	}
+	return 0
+}
+
+// finalize computes the realized P&L, the fees in ETH and the totals.
+func (self *Report) finalize() {
+	self.Total = newBucket()
+	for bucketTime, bucket := range self.Buckets {
+		kncPrice := self.price(bucketTime, kncID)
+		for tokenID, pnl := range bucket.Tokens {
+			pnl.computeRealized()
+			pnl.BurnFee = pnl.BurnFeeKNC * kncPrice
+			pnl.WalletFee = pnl.WalletFeeKNC * kncPrice
+			pnl.WithdrawFee = pnl.WithdrawFeeQty * self.price(bucketTime, tokenID)
+			pnl.NetPnL = pnl.RealizedPnL - pnl.BurnFee - pnl.WalletFee - pnl.ExchangeFee - pnl.WithdrawFee
+			bucket.NetPnL += pnl.NetPnL
+
+			total, ok := self.Total.Tokens[tokenID]
+			if !ok {
+				total = &TokenPnL{}
+				self.Total.Tokens[tokenID] = total
+			}
+			total.add(pnl)
+		}
+		bucket.NetPnL -= bucket.SetRateFee
+		self.Total.SetRateFee += bucket.SetRateFee
+		self.Total.NetPnL += bucket.NetPnL
+	}
+}
@@ -0,0 +1,191 @@
+package rateengine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const (
+	bidSide = "bid"
+	askSide = "ask"
+)
+
+// Snapshot is the data rates are computed from.
+type Snapshot struct {
+	Timestamp uint64                      `json:"timestamp"`
+	Tokens    []common.Token              `json:"tokens"`
+	Prices    common.AllPriceEntry        `json:"prices"`
+	AuthData  common.AuthDataSnapshot     `json:"auth_data"`
+	Targets   common.TokenTargetQtyV2     `json:"targets"`
+	Equations common.PWIEquationRequestV2 `json:"equations"`
+}
+
+// TokenRate is the computed rate of a token. Prices are in ETH per token,
+// Buy, Sell and AfpMid are in the format of set rates API.
+type TokenRate struct {
+	Token     common.Token `json:"-"`
+	TokenID   string       `json:"token"`
+	Balance   float64      `json:"balance"`
+	Target    float64      `json:"target"`
+	Imbalance float64      `json:"imbalance"`
+	MidPrice  float64      `json:"mid_price"`
+	BidSpread float64      `json:"bid_spread"`
+	AskSpread float64      `json:"ask_spread"`
+	BidPrice  float64      `json:"bid_price"`
+	AskPrice  float64      `json:"ask_price"`
+	Buy       *big.Int     `json:"buy"`
+	Sell      *big.Int     `json:"sell"`
+	AfpMid    *big.Int     `json:"afp_mid"`
+}
+
+// Rates is the result of computing rates of a snapshot. Tokens which rates
+// could not be computed are in Errors with the reason.
+type Rates struct {
+	Timestamp uint64            `json:"timestamp"`
+	Block     uint64            `json:"block"`
+	Tokens    []TokenRate       `json:"tokens"`
+	Errors    map[string]string `json:"errors"`
+}
+
+// Compute computes the rates of the tokens in the snapshot which have a PWI
+// equation, ETH and tokens without an equation are skipped.
+//
+// For each token:
+//   - the mid price is the average of the best bid and the best ask of the
+//     token-ETH order books of all valid exchanges
+//   - the imbalance is (balance - total target) / total target, where balance
+//     is the reserve balance plus the available and locked balances on
+//     exchanges, it is 0 if the token has no target
+//   - the spread of a side is a*x^2 + b*x + c of the side equation, at least
+//     min_min_spread, where x is the imbalance for bid and -imbalance for ask,
+//     so the reserve buys cheaper and sells cheaper when it holds too much
+//   - the price of a side is mid price * price_multiply_factor (if set)
+//     lowered by the bid spread or raised by the ask spread.
+//
+// The same snapshot always gives the same rates.
+func Compute(snapshot Snapshot) Rates {
+	rates := Rates{
+		Timestamp: snapshot.Timestamp,
+		Block:     snapshot.Prices.Block,
+		Tokens:    []TokenRate{},
+		Errors:    map[string]string{},
+	}
+	tokens := make([]common.Token, len(snapshot.Tokens))
+	copy(tokens, snapshot.Tokens)
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	for _, token := range tokens {
+		eq, ok := snapshot.Equations[token.ID]
+		if token.IsETH() || !ok {
+			continue
+		}
+		rate, err := computeTokenRate(snapshot, token, eq)
+		if err != nil {
+			rates.Errors[token.ID] = err.Error()
+			continue
+		}
+		rates.Tokens = append(rates.Tokens, rate)
+	}
+	return rates
+}
+
+func computeTokenRate(snapshot Snapshot, token common.Token, eq common.PWIEquationTokenV2) (TokenRate, error) {
+	bidEq, ok := eq[bidSide]
+	if !ok {
+		return TokenRate{}, errors.New("missing bid equation")
+	}
+	askEq, ok := eq[askSide]
+	if !ok {
+		return TokenRate{}, errors.New("missing ask equation")
+	}
+	mid, err := midPrice(snapshot.Prices, token)
+	if err != nil {
+		return TokenRate{}, err
+	}
+	balance := tokenBalance(snapshot.AuthData, token)
+	target := snapshot.Targets[token.ID].SetTarget.TotalTarget
+	var imbalance float64
+	if target > 0 {
+		imbalance = (balance - target) / target
+	}
+	rate := TokenRate{
+		Token:     token,
+		TokenID:   token.ID,
+		Balance:   balance,
+		Target:    target,
+		Imbalance: imbalance,
+		MidPrice:  mid,
+		BidSpread: spread(bidEq, imbalance),
+		AskSpread: spread(askEq, -imbalance),
+	}
+	rate.BidPrice = referencePrice(bidEq, mid) * (1 - rate.BidSpread)
+	rate.AskPrice = referencePrice(askEq, mid) * (1 + rate.AskSpread)
+	if rate.BidPrice <= 0 || rate.BidPrice >= mid || rate.AskPrice <= mid {
+		return TokenRate{}, fmt.Errorf("invalid prices: bid %f, mid %f, ask %f", rate.BidPrice, mid, rate.AskPrice)
+	}
+	rate.Buy = toRate(1 / rate.AskPrice)
+	rate.Sell = toRate(rate.BidPrice)
+	rate.AfpMid = toRate(mid)
+	return rate, nil
+}
+
+// midPrice returns the average of the best bid and best ask of the token
+// over all valid exchanges.
+func midPrice(prices common.AllPriceEntry, token common.Token) (float64, error) {
+	pair := common.NewTokenPairID(token.ID, "ETH")
+	bestBid, bestAsk := 0.0, math.Inf(1)
+	for _, exPrice := range prices.Data[pair] {
+		if !exPrice.Valid {
+			continue
+		}
+		for _, bid := range exPrice.Bids {
+			bestBid = math.Max(bestBid, bid.Rate)
+		}
+		for _, ask := range exPrice.Asks {
+			if ask.Rate > 0 {
+				bestAsk = math.Min(bestAsk, ask.Rate)
+			}
+		}
+	}
+	if bestBid == 0 || math.IsInf(bestAsk, 1) {
+		return 0, fmt.Errorf("no valid order book of %s", pair)
+	}
+	return (bestBid + bestAsk) / 2, nil
+}
+
+// tokenBalance returns the balance of the token in reserve and exchanges.
+func tokenBalance(authData common.AuthDataSnapshot, token common.Token) float64 {
+	var balance float64
+	if reserveBalance, ok := authData.ReserveBalances[token.ID]; ok && reserveBalance.Valid {
+		balance += reserveBalance.Balance.ToFloat(token.Decimals)
+	}
+	for _, exBalance := range authData.ExchangeBalances {
+		if !exBalance.Valid {
+			continue
+		}
+		balance += exBalance.AvailableBalance[token.ID] + exBalance.LockedBalance[token.ID]
+	}
+	return balance
+}
+
+func spread(eq common.PWIEquationV2, x float64) float64 {
+	return math.Max(eq.A*x*x+eq.B*x+eq.C, eq.MinMinSpread)
+}
+
+func referencePrice(eq common.PWIEquationV2, mid float64) float64 {
+	if eq.PriceMultiplyFactor > 0 {
+		return mid * eq.PriceMultiplyFactor
+	}
+	return mid
+}
+
+// toRate converts an ETH per token or token per ETH rate to the 18 decimals
+// integer of set rates API.
+func toRate(rate float64) *big.Int {
+	result, _ := new(big.Float).Mul(big.NewFloat(rate), big.NewFloat(1e18)).Int(nil)
+	return result
+}
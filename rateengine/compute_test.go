@@ -0,0 +1,150 @@
+package rateengine
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// testSnapshot is a recorded snapshot:
+//   - KNC: mid price 0.002 from binance and huobi, bittrex is invalid, holds
+//     1000 with a target of 800
+//   - OMG: mid price 0.01, no target
+//   - SNT: no PWI equation
+//   - ZRX: no order book
+const testSnapshot = `{
+  "timestamp": 1539248400000,
+  "tokens": [
+    {"id": "ZRX", "decimals": 18, "internal": true, "active": true},
+    {"id": "OMG", "decimals": 18, "internal": true, "active": true},
+    {"id": "ETH", "decimals": 18, "internal": true, "active": true},
+    {"id": "SNT", "decimals": 18, "internal": true, "active": true},
+    {"id": "KNC", "decimals": 18, "internal": true, "active": true}
+  ],
+  "prices": {
+    "Block": 6500000,
+    "Data": {
+      "KNC-ETH": {
+        "binance": {"Valid": true, "Bids": [{"Quantity": 100, "Rate": 0.0018}, {"Quantity": 100, "Rate": 0.0019}], "Asks": [{"Quantity": 100, "Rate": 0.0021}]},
+        "huobi": {"Valid": true, "Bids": [{"Quantity": 50, "Rate": 0.00195}], "Asks": [{"Quantity": 50, "Rate": 0.00205}]},
+        "bittrex": {"Valid": false, "Bids": [{"Quantity": 50, "Rate": 0.01}], "Asks": [{"Quantity": 50, "Rate": 0.011}]}
+      },
+      "OMG-ETH": {
+        "binance": {"Valid": true, "Bids": [{"Quantity": 10, "Rate": 0.0098}], "Asks": [{"Quantity": 10, "Rate": 0.0102}]}
+      },
+      "SNT-ETH": {
+        "binance": {"Valid": true, "Bids": [{"Quantity": 10, "Rate": 0.0001}], "Asks": [{"Quantity": 10, "Rate": 0.0002}]}
+      }
+    }
+  },
+  "auth_data": {
+    "Valid": true,
+    "ReserveBalances": {
+      "KNC": {"Valid": true, "Balance": 600000000000000000000},
+      "OMG": {"Valid": true, "Balance": 1000000000000000000000}
+    },
+    "ExchangeBalances": {
+      "binance": {"Valid": true, "AvailableBalance": {"KNC": 300}, "LockedBalance": {"KNC": 100}},
+      "huobi": {"Valid": false, "AvailableBalance": {"KNC": 1000}}
+    }
+  },
+  "targets": {
+    "KNC": {"set_target": {"total_target": 800, "reserve_target": 500}}
+  },
+  "equations": {
+    "KNC": {
+      "bid": {"a": 0.08, "b": 0.02, "c": 0.005, "min_min_spread": 0.004},
+      "ask": {"a": 0.08, "b": 0.02, "c": 0.005, "min_min_spread": 0.004}
+    },
+    "OMG": {
+      "bid": {"c": 0.01},
+      "ask": {"c": 0.01, "price_multiply_factor": 1.02}
+    },
+    "ZRX": {
+      "bid": {"c": 0.01},
+      "ask": {"c": 0.01}
+    }
+  }
+}`
+
+func loadTestSnapshot(t *testing.T) Snapshot {
+	t.Helper()
+	var snapshot Snapshot
+	if err := json.Unmarshal([]byte(testSnapshot), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func assertFloat(t *testing.T, name string, expected, actual float64) {
+	t.Helper()
+	if math.Abs(expected-actual) > 1e-12*math.Max(1, math.Abs(expected)) {
+		t.Errorf("expected %s %v, got %v", name, expected, actual)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	rates := Compute(loadTestSnapshot(t))
+
+	if rates.Block != 6500000 || rates.Timestamp != 1539248400000 {
+		t.Errorf("unexpected block or timestamp: %+v", rates)
+	}
+	if len(rates.Tokens) != 2 || rates.Tokens[0].TokenID != "KNC" || rates.Tokens[1].TokenID != "OMG" {
+		t.Fatalf("expected rates of KNC and OMG, got %+v", rates.Tokens)
+	}
+	if len(rates.Errors) != 1 || rates.Errors["ZRX"] == "" {
+		t.Errorf("expected only ZRX to fail, got %+v", rates.Errors)
+	}
+
+	knc := rates.Tokens[0]
+	assertFloat(t, "KNC balance", 1000, knc.Balance)
+	assertFloat(t, "KNC imbalance", 0.25, knc.Imbalance)
+	assertFloat(t, "KNC mid price", 0.002, knc.MidPrice)
+	// bid: 0.08 * 0.25^2 + 0.02 * 0.25 + 0.005, ask: 0.08 * 0.25^2 - 0.02 * 0.25 + 0.005
+	assertFloat(t, "KNC bid spread", 0.015, knc.BidSpread)
+	assertFloat(t, "KNC ask spread", 0.005, knc.AskSpread)
+	assertFloat(t, "KNC bid price", 0.00197, knc.BidPrice)
+	assertFloat(t, "KNC ask price", 0.00201, knc.AskPrice)
+	assertFloat(t, "KNC buy", 1/0.00201, common.BigToFloat(knc.Buy, 18))
+	assertFloat(t, "KNC sell", 0.00197, common.BigToFloat(knc.Sell, 18))
+	assertFloat(t, "KNC afp mid", 0.002, common.BigToFloat(knc.AfpMid, 18))
+
+	omg := rates.Tokens[1]
+	assertFloat(t, "OMG imbalance", 0, omg.Imbalance)
+	assertFloat(t, "OMG bid price", 0.0099, omg.BidPrice)
+	assertFloat(t, "OMG ask price", 0.010302, omg.AskPrice)
+}
+
+func TestComputeDeterministic(t *testing.T) {
+	snapshot := loadTestSnapshot(t)
+	expected := Compute(snapshot)
+
+	reversed := loadTestSnapshot(t)
+	for i, j := 0, len(reversed.Tokens)-1; i < j; i, j = i+1, j-1 {
+		reversed.Tokens[i], reversed.Tokens[j] = reversed.Tokens[j], reversed.Tokens[i]
+	}
+	for i := 0; i < 10; i++ {
+		if rates := Compute(reversed); !reflect.DeepEqual(rates, expected) {
+			t.Fatalf("expected same rates for same snapshot, got %+v and %+v", expected, rates)
+		}
+	}
+}
+
+func TestComputeInvalidSpread(t *testing.T) {
+	snapshot := loadTestSnapshot(t)
+	snapshot.Equations["OMG"]["bid"] = common.PWIEquationV2{C: 1.5}
+	delete(snapshot.Equations["KNC"], "ask")
+
+	rates := Compute(snapshot)
+	if len(rates.Tokens) != 0 {
+		t.Errorf("expected no valid rate, got %+v", rates.Tokens)
+	}
+	for _, tokenID := range []string{"KNC", "OMG", "ZRX"} {
+		if rates.Errors[tokenID] == "" {
+			t.Errorf("expected error computing rate of %s, got %+v", tokenID, rates.Errors)
+		}
+	}
+}
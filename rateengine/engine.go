@@ -0,0 +1,219 @@
+package rateengine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// engineKeyID is recorded as the key ID of the set rates activities
+// submitted by the engine.
+const engineKeyID = "rate_engine"
+
+// Storage is the storage of order books and balances.
+type Storage interface {
+	CurrentPriceVersion(timepoint uint64) (common.Version, error)
+	GetAllPrices(common.Version) (common.AllPriceEntry, error)
+	CurrentAuthDataVersion(timepoint uint64) (common.Version, error)
+	GetAuthData(common.Version) (common.AuthDataSnapshot, error)
+}
+
+// MetricStorage is the storage of confirmed target quantities, PWI equations
+// and the set rate control.
+type MetricStorage interface {
+	GetTargetQtyV2() (common.TokenTargetQtyV2, error)
+	GetPWIEquationV2() (common.PWIEquationRequestV2, error)
+	GetSetrateControl() (common.SetrateControl, error)
+}
+
+// Setting provides the tokens to compute rates for.
+type Setting interface {
+	GetInternalTokens() ([]common.Token, error)
+}
+
+// RateSetter submits rates to the reserve contract, it is implemented by
+// core.ReserveCore.
+type RateSetter interface {
+	SetRates(tokens []common.Token, buys, sells []*big.Int, block *big.Int, afpMid []*big.Int, msgs []string, keyID string) (common.ActivityID, error)
+}
+
+// Engine computes rates from the stored data and submits them periodically
+// if it has a runner.
+type Engine struct {
+	storage Storage
+	metric  MetricStorage
+	setting Setting
+	setter  RateSetter
+	runner  Runner
+	// maxDataAge is the max age of the order books and balances rates are
+	// computed from.
+	maxDataAge time.Duration
+
+	mu   sync.RWMutex
+	last *Rates
+}
+
+// NewEngine creates a new Engine. The runner is optional, without it rates
+// are only computed on request. Order books and balances older than
+// maxDataAge are rejected.
+func NewEngine(storage Storage, metric MetricStorage, setting Setting, setter RateSetter, runner Runner, maxDataAge time.Duration) *Engine {
+	return &Engine{
+		storage:    storage,
+		metric:     metric,
+		setting:    setting,
+		setter:     setter,
+		runner:     runner,
+		maxDataAge: maxDataAge,
+	}
+}
+
+// checkAge returns an error if the data of version, a timepoint, is older
+// than maxDataAge at timepoint.
+func (self *Engine) checkAge(name string, version common.Version, timepoint uint64) error {
+	maxAge := uint64(self.maxDataAge / time.Millisecond)
+	if uint64(version)+maxAge < timepoint {
+		return fmt.Errorf("%s of version %d is %s old, older than max age %s", name, version,
+			time.Duration(timepoint-uint64(version))*time.Millisecond, self.maxDataAge)
+	}
+	return nil
+}
+
+// Snapshot loads the latest data at timepoint, it fails if the order books or
+// balances are older than the max data age.
+func (self *Engine) Snapshot(timepoint uint64) (Snapshot, error) {
+	snapshot := Snapshot{Timestamp: timepoint}
+	tokens, err := self.setting.GetInternalTokens()
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.Tokens = tokens
+	priceVersion, err := self.storage.CurrentPriceVersion(timepoint)
+	if err != nil {
+		return snapshot, fmt.Errorf("Couldn't get price version: %s", err)
+	}
+	if err = self.checkAge("Price", priceVersion, timepoint); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Prices, err = self.storage.GetAllPrices(priceVersion); err != nil {
+		return snapshot, err
+	}
+	authVersion, err := self.storage.CurrentAuthDataVersion(timepoint)
+	if err != nil {
+		return snapshot, fmt.Errorf("Couldn't get auth data version: %s", err)
+	}
+	if err = self.checkAge("Auth data", authVersion, timepoint); err != nil {
+		return snapshot, err
+	}
+	if snapshot.AuthData, err = self.storage.GetAuthData(authVersion); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Targets, err = self.metric.GetTargetQtyV2(); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Equations, err = self.metric.GetPWIEquationV2(); err != nil {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+// Compute computes the rates from the latest data at timepoint.
+func (self *Engine) Compute(timepoint uint64) (Rates, error) {
+	snapshot, err := self.Snapshot(timepoint)
+	if err != nil {
+		return Rates{}, err
+	}
+	rates := Compute(snapshot)
+	self.mu.Lock()
+	self.last = &rates
+	self.mu.Unlock()
+	return rates, nil
+}
+
+// LastRates returns the last computed rates, nil if there is none.
+func (self *Engine) LastRates() *Rates {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.last
+}
+
+// Submit sets the given rates on the reserve contract, keyID is recorded as
+// the requester of the set rates activity.
+func (self *Engine) Submit(rates Rates, keyID string) (common.ActivityID, error) {
+	if len(rates.Tokens) == 0 {
+		return common.ActivityID{}, errors.New("There is no rate to submit")
+	}
+	var (
+		tokens            []common.Token
+		buys, sells, mids []*big.Int
+		msgs              []string
+	)
+	for _, rate := range rates.Tokens {
+		tokens = append(tokens, rate.Token)
+		buys = append(buys, rate.Buy)
+		sells = append(sells, rate.Sell)
+		mids = append(mids, rate.AfpMid)
+		msgs = append(msgs, fmt.Sprintf("rate engine imbalance %.4f", rate.Imbalance))
+	}
+	block := new(big.Int).SetUint64(rates.Block)
+	return self.setter.SetRates(tokens, buys, sells, block, mids, msgs, keyID)
+}
+
+// Run starts the runner and submits newly computed rates on every tick.
+func (self *Engine) Run() error {
+	if self.runner == nil {
+		return errors.New("Rate engine has no runner")
+	}
+	if err := self.runner.Start(); err != nil {
+		return err
+	}
+	go func() {
+		for t := range self.runner.GetRateTicker() {
+			self.computeAndSubmit(common.TimeToTimepoint(t))
+		}
+	}()
+	return nil
+}
+
+// Stop stops the runner.
+func (self *Engine) Stop() error {
+	if self.runner == nil {
+		return nil
+	}
+	return self.runner.Stop()
+}
+
+// computeAndSubmit computes the rates at timepoint and submits them, nothing
+// is submitted while set rate is on hold.
+func (self *Engine) computeAndSubmit(timepoint uint64) {
+	control, err := self.metric.GetSetrateControl()
+	if err != nil {
+		log.Printf("RATE ENGINE: failed to get set rate control: %s", err)
+		return
+	}
+	if !control.Status {
+		log.Printf("RATE ENGINE: set rate is on hold")
+		return
+	}
+	rates, err := self.Compute(timepoint)
+	if err != nil {
+		log.Printf("RATE ENGINE: failed to compute rates: %s", err)
+		return
+	}
+	for tokenID, reason := range rates.Errors {
+		log.Printf("RATE ENGINE: skipped %s: %s", tokenID, reason)
+	}
+	if len(rates.Tokens) == 0 {
+		return
+	}
+	id, err := self.Submit(rates, engineKeyID)
+	if err != nil {
+		log.Printf("RATE ENGINE: failed to set rates: %s", err)
+		return
+	}
+	log.Printf("RATE ENGINE: set rates of %d tokens, activity %s", len(rates.Tokens), id)
+}
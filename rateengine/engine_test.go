@@ -0,0 +1,136 @@
+package rateengine
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type testStorage struct {
+	snapshot Snapshot
+	setrate  bool
+	// priceAge and authAge are the ages of the latest data in milliseconds.
+	priceAge uint64
+	authAge  uint64
+}
+
+func (self testStorage) CurrentPriceVersion(timepoint uint64) (common.Version, error) {
+	return common.Version(timepoint - self.priceAge), nil
+}
+
+func (self testStorage) GetAllPrices(common.Version) (common.AllPriceEntry, error) {
+	return self.snapshot.Prices, nil
+}
+
+func (self testStorage) CurrentAuthDataVersion(timepoint uint64) (common.Version, error) {
+	return common.Version(timepoint - self.authAge), nil
+}
+
+func (self testStorage) GetAuthData(common.Version) (common.AuthDataSnapshot, error) {
+	return self.snapshot.AuthData, nil
+}
+
+func (self testStorage) GetTargetQtyV2() (common.TokenTargetQtyV2, error) {
+	return self.snapshot.Targets, nil
+}
+
+func (self testStorage) GetPWIEquationV2() (common.PWIEquationRequestV2, error) {
+	return self.snapshot.Equations, nil
+}
+
+func (self testStorage) GetSetrateControl() (common.SetrateControl, error) {
+	return common.SetrateControl{Status: self.setrate}, nil
+}
+
+func (self testStorage) GetInternalTokens() ([]common.Token, error) {
+	return self.snapshot.Tokens, nil
+}
+
+type testRateSetter struct {
+	tokens []common.Token
+	buys   []*big.Int
+	block  *big.Int
+	keyID  string
+}
+
+func (self *testRateSetter) SetRates(tokens []common.Token, buys, sells []*big.Int, block *big.Int, afpMid []*big.Int, msgs []string, keyID string) (common.ActivityID, error) {
+	self.tokens, self.buys, self.block, self.keyID = tokens, buys, block, keyID
+	return common.ActivityID{Timepoint: 1, EID: "set_rates"}, nil
+}
+
+func TestEngine(t *testing.T) {
+	snapshot := loadTestSnapshot(t)
+	st := testStorage{snapshot: snapshot}
+	setter := &testRateSetter{}
+	engine := NewEngine(st, st, st, setter, nil, time.Minute)
+
+	if engine.LastRates() != nil {
+		t.Error("expected no rates before computing")
+	}
+	rates, err := engine.Compute(snapshot.Timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := engine.LastRates(); last == nil || len(last.Tokens) != 2 {
+		t.Errorf("expected last computed rates, got %+v", last)
+	}
+
+	if _, err = engine.Submit(Rates{}, "operator"); err == nil {
+		t.Error("expected error submitting empty rates")
+	}
+	if _, err = engine.Submit(rates, "operator"); err != nil {
+		t.Fatal(err)
+	}
+	if len(setter.tokens) != 2 || setter.tokens[0].ID != "KNC" || setter.tokens[1].ID != "OMG" {
+		t.Errorf("expected rates of KNC and OMG to be set, got %+v", setter.tokens)
+	}
+	if setter.buys[0].Cmp(rates.Tokens[0].Buy) != 0 || setter.block.Uint64() != 6500000 || setter.keyID != "operator" {
+		t.Errorf("unexpected set rates: buys %v, block %v, key %s", setter.buys, setter.block, setter.keyID)
+	}
+
+	if err = engine.Run(); err == nil {
+		t.Error("expected error running engine without runner")
+	}
+}
+
+func TestEngineSetrateHold(t *testing.T) {
+	snapshot := loadTestSnapshot(t)
+	st := testStorage{snapshot: snapshot}
+	setter := &testRateSetter{}
+	NewEngine(st, st, st, setter, nil, time.Minute).computeAndSubmit(snapshot.Timestamp)
+	if setter.tokens != nil {
+		t.Errorf("expected no rates set while set rate is on hold, got %+v", setter.tokens)
+	}
+
+	st.setrate = true
+	NewEngine(st, st, st, setter, nil, time.Minute).computeAndSubmit(snapshot.Timestamp)
+	if len(setter.tokens) != 2 || setter.keyID != engineKeyID {
+		t.Errorf("expected rates of 2 tokens set by the engine, got %+v by %s", setter.tokens, setter.keyID)
+	}
+}
+
+func TestEngineStaleData(t *testing.T) {
+	snapshot := loadTestSnapshot(t)
+	setter := &testRateSetter{}
+	for _, st := range []testStorage{
+		{snapshot: snapshot, setrate: true, priceAge: 60001},
+		{snapshot: snapshot, setrate: true, authAge: 60001},
+	} {
+		engine := NewEngine(st, st, st, setter, nil, time.Minute)
+		if _, err := engine.Compute(snapshot.Timestamp); err == nil {
+			t.Errorf("expected error computing rates from stale data, price age %d, auth data age %d", st.priceAge, st.authAge)
+		}
+		engine.computeAndSubmit(snapshot.Timestamp)
+		if setter.tokens != nil {
+			t.Errorf("expected no rates set from stale data, got %+v", setter.tokens)
+		}
+	}
+
+	st := testStorage{snapshot: snapshot, setrate: true, priceAge: 60000, authAge: 60000}
+	NewEngine(st, st, st, setter, nil, time.Minute).computeAndSubmit(snapshot.Timestamp)
+	if len(setter.tokens) != 2 {
+		t.Errorf("expected rates of 2 tokens set from data of max age, got %+v", setter.tokens)
+	}
+}
@@ -0,0 +1,284 @@
+package rebalancer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// Types of rebalance actions.
+const (
+	ActionDeposit  = common.ActionDeposit
+	ActionWithdraw = common.ActionWithdraw
+	ActionTrade    = common.ActionTrade
+)
+
+// Snapshot is the data a rebalance plan is made from.
+type Snapshot struct {
+	Timestamp         uint64                           `json:"timestamp"`
+	Tokens            []common.Token                   `json:"tokens"`
+	Exchanges         []common.ExchangeID              `json:"exchanges"`
+	AuthData          common.AuthDataSnapshot          `json:"auth_data"`
+	PendingActivities []common.ActivityRecord          `json:"pending_activities"`
+	Prices            common.AllPriceEntry             `json:"prices"`
+	Targets           common.TokenTargetQtyV2          `json:"targets"`
+	Quadratics        common.RebalanceQuadraticRequest `json:"quadratics"`
+}
+
+// Action is a deposit, withdraw or trade to execute. Amount is in token unit,
+// TradeType and Rate are only set for trades of Token against ETH.
+type Action struct {
+	Type      string            `json:"type"`
+	Exchange  common.ExchangeID `json:"exchange"`
+	Token     string            `json:"token"`
+	Amount    float64           `json:"amount"`
+	TradeType string            `json:"trade_type,omitempty"`
+	Rate      float64           `json:"rate,omitempty"`
+	Reason    string            `json:"reason"`
+}
+
+func (self Action) String() string {
+	if self.Type == ActionTrade {
+		return fmt.Sprintf("%s %f %s at %f on %s (%s)", self.TradeType, self.Amount, self.Token, self.Rate, self.Exchange, self.Reason)
+	}
+	return fmt.Sprintf("%s %f %s on %s (%s)", self.Type, self.Amount, self.Token, self.Exchange, self.Reason)
+}
+
+// Plan is the actions to move balances toward targets. Tokens which are not
+// rebalanced are in Skipped with the reason.
+type Plan struct {
+	Timestamp uint64            `json:"timestamp"`
+	Actions   []Action          `json:"actions"`
+	Skipped   map[string]string `json:"skipped"`
+}
+
+// MakePlan decides the actions to rebalance the tokens in the snapshot which
+// have a target quantity. ETH is not rebalanced. For each token, in order:
+//   - tokens having pending deposit, withdraw or trade activities are skipped
+//   - trade: if the total balance in reserve and exchanges differs from the
+//     total target by more than rebalance_threshold (ratio of the target),
+//     sell the excess at the best bid or buy the shortage at the best ask on
+//     the exchange with the most available token or ETH. With a rebalance
+//     quadratic equation the traded amount is (a*x^2 + b*x + c) * total
+//     target, at most the difference, where x is the difference ratio
+//   - transfer: if no trade is needed and the reserve balance differs from
+//     the reserve target by more than transfer_threshold (ratio of the
+//     target), deposit the excess to exchanges split by exchange_ratio (or to
+//     the exchange holding the least token) or withdraw the shortage from the
+//     exchanges holding the most token.
+//
+// The same snapshot always gives the same plan.
+func MakePlan(snapshot Snapshot) Plan {
+	plan := Plan{
+		Timestamp: snapshot.Timestamp,
+		Actions:   []Action{},
+		Skipped:   map[string]string{},
+	}
+	busy := pendingTokens(snapshot.PendingActivities)
+	tokens := make([]common.Token, len(snapshot.Tokens))
+	copy(tokens, snapshot.Tokens)
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	for _, token := range tokens {
+		target, ok := snapshot.Targets[token.ID]
+		if token.IsETH() || !ok || target.SetTarget.TotalTarget <= 0 {
+			continue
+		}
+		if busy[token.ID] {
+			plan.Skipped[token.ID] = "pending activities"
+			continue
+		}
+		actions, err := planToken(snapshot, token, target)
+		if err != nil {
+			plan.Skipped[token.ID] = err.Error()
+			continue
+		}
+		plan.Actions = append(plan.Actions, actions...)
+	}
+	return plan
+}
+
+// pendingTokens returns the tokens involved in pending deposit, withdraw or
+// trade activities.
+func pendingTokens(activities []common.ActivityRecord) map[string]bool {
+	result := map[string]bool{}
+	for _, activity := range activities {
+		switch activity.Action {
+		case common.ActionDeposit, common.ActionWithdraw, common.ActionTrade:
+		default:
+			continue
+		}
+		for _, param := range []string{"token", "base", "quote"} {
+			if tokenID, ok := activity.Params[param].(string); ok {
+				result[tokenID] = true
+			}
+		}
+	}
+	return result
+}
+
+// exchangeBalances returns the valid balances of the configured exchanges,
+// sorted by exchange ID.
+func exchangeBalances(snapshot Snapshot) ([]common.ExchangeID, map[common.ExchangeID]common.EBalanceEntry) {
+	var ids []common.ExchangeID
+	balances := map[common.ExchangeID]common.EBalanceEntry{}
+	for _, id := range snapshot.Exchanges {
+		balance, ok := snapshot.AuthData.ExchangeBalances[id]
+		if !ok || !balance.Valid {
+			continue
+		}
+		ids = append(ids, id)
+		balances[id] = balance
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, balances
+}
+
+func planToken(snapshot Snapshot, token common.Token, target common.TargetQtyV2) ([]Action, error) {
+	reserveBalance, ok := snapshot.AuthData.ReserveBalances[token.ID]
+	if !ok || !reserveBalance.Valid {
+		return nil, fmt.Errorf("no valid reserve balance")
+	}
+	reserve := reserveBalance.Balance.ToFloat(token.Decimals)
+	ids, balances := exchangeBalances(snapshot)
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no valid exchange balance")
+	}
+	total := reserve
+	for _, id := range ids {
+		total += balances[id].AvailableBalance[token.ID] + balances[id].LockedBalance[token.ID]
+	}
+
+	set := target.SetTarget
+	diff := total - set.TotalTarget
+	if set.RebalanceThreshold > 0 && math.Abs(diff)/set.TotalTarget > set.RebalanceThreshold {
+		return planTrade(snapshot, token, ids, balances, diff, set.TotalTarget)
+	}
+
+	diff = reserve - set.ReserveTarget
+	if set.ReserveTarget <= 0 || set.TransferThreshold <= 0 || math.Abs(diff)/set.ReserveTarget <= set.TransferThreshold {
+		return nil, nil
+	}
+	if diff > 0 {
+		return planDeposits(token, ids, balances, diff, target.ExchangeRatio), nil
+	}
+	return planWithdraws(token, ids, balances, -diff)
+}
+
+func planTrade(snapshot Snapshot, token common.Token, ids []common.ExchangeID, balances map[common.ExchangeID]common.EBalanceEntry, diff, totalTarget float64) ([]Action, error) {
+	amount := math.Abs(diff)
+	if eq, ok := snapshot.Quadratics[token.ID]; ok {
+		q := eq.RebalanceQuadratic
+		if q.A != 0 || q.B != 0 || q.C != 0 {
+			x := amount / totalTarget
+			amount = math.Min(amount, math.Max(0, q.A*x*x+q.B*x+q.C)*totalTarget)
+		}
+	}
+	if amount <= 0 {
+		return nil, nil
+	}
+	prices := snapshot.Prices.Data[common.NewTokenPairID(token.ID, "ETH")]
+	side := "buy"
+	if diff > 0 {
+		side = "sell"
+	}
+	var (
+		best     Action
+		capacity float64
+	)
+	for _, id := range ids {
+		price, ok := prices[id]
+		if !ok || !price.Valid {
+			continue
+		}
+		var rate, available float64
+		if side == "sell" {
+			rate, available = bestBid(price), balances[id].AvailableBalance[token.ID]
+		} else if rate = bestAsk(price); rate > 0 {
+			available = balances[id].AvailableBalance["ETH"] / rate
+		}
+		if rate <= 0 || available <= capacity {
+			continue
+		}
+		capacity = available
+		best = Action{Exchange: id, TradeType: side, Rate: rate}
+	}
+	if capacity == 0 {
+		return nil, fmt.Errorf("no exchange to %s %f", side, amount)
+	}
+	best.Type = ActionTrade
+	best.Token = token.ID
+	best.Amount = math.Min(amount, capacity)
+	best.Reason = fmt.Sprintf("total balance differs from target by %f", diff)
+	return []Action{best}, nil
+}
+
+func bestBid(price common.ExchangePrice) float64 {
+	var result float64
+	for _, bid := range price.Bids {
+		result = math.Max(result, bid.Rate)
+	}
+	return result
+}
+
+func bestAsk(price common.ExchangePrice) float64 {
+	var result float64
+	for _, ask := range price.Asks {
+		if ask.Rate > 0 && (result == 0 || ask.Rate < result) {
+			result = ask.Rate
+		}
+	}
+	return result
+}
+
+func planDeposits(token common.Token, ids []common.ExchangeID, balances map[common.ExchangeID]common.EBalanceEntry, amount float64, ratios map[string]float64) []Action {
+	reason := fmt.Sprintf("reserve balance exceeds target by %f", amount)
+	var sum float64
+	for _, id := range ids {
+		sum += ratios[string(id)]
+	}
+	if sum <= 0 {
+		// deposit all to the exchange holding the least token
+		least := ids[0]
+		for _, id := range ids[1:] {
+			if balances[id].AvailableBalance[token.ID] < balances[least].AvailableBalance[token.ID] {
+				least = id
+			}
+		}
+		return []Action{{Type: ActionDeposit, Exchange: least, Token: token.ID, Amount: amount, Reason: reason}}
+	}
+	var actions []Action
+	for _, id := range ids {
+		if ratio := ratios[string(id)]; ratio > 0 {
+			actions = append(actions, Action{Type: ActionDeposit, Exchange: id, Token: token.ID, Amount: amount * ratio / sum, Reason: reason})
+		}
+	}
+	return actions
+}
+
+func planWithdraws(token common.Token, ids []common.ExchangeID, balances map[common.ExchangeID]common.EBalanceEntry, amount float64) ([]Action, error) {
+	reason := fmt.Sprintf("reserve balance is below target by %f", amount)
+	sorted := make([]common.ExchangeID, len(ids))
+	copy(sorted, ids)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return balances[sorted[i]].AvailableBalance[token.ID] > balances[sorted[j]].AvailableBalance[token.ID]
+	})
+	var actions []Action
+	for _, id := range sorted {
+		if amount <= 0 {
+			break
+		}
+		available := balances[id].AvailableBalance[token.ID]
+		if available <= 0 {
+			break
+		}
+		withdraw := math.Min(amount, available)
+		actions = append(actions, Action{Type: ActionWithdraw, Exchange: id, Token: token.ID, Amount: withdraw, Reason: reason})
+		amount -= withdraw
+	}
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("no exchange balance to withdraw")
+	}
+	return actions, nil
+}
@@ -0,0 +1,135 @@
+package rebalancer
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// testSnapshot is a recorded snapshot:
+//   - KNC: holds 1100 with a total target of 800, sells at most 80 by the
+//     rebalance quadratic equation
+//   - OMG: reserve holds 1000 with a reserve target of 600, deposits the
+//     excess by exchange ratio
+//   - SNT: reserve holds 100 with a reserve target of 800, withdraws from
+//     binance first
+//   - ZRX: has a pending trade
+//   - MKR: no target
+const testSnapshot = `{
+  "timestamp": 1539248400000,
+  "tokens": [
+    {"id": "ZRX", "decimals": 18, "internal": true, "active": true},
+    {"id": "SNT", "decimals": 18, "internal": true, "active": true},
+    {"id": "OMG", "decimals": 18, "internal": true, "active": true},
+    {"id": "MKR", "decimals": 18, "internal": true, "active": true},
+    {"id": "KNC", "decimals": 18, "internal": true, "active": true},
+    {"id": "ETH", "decimals": 18, "internal": true, "active": true}
+  ],
+  "exchanges": ["huobi", "binance"],
+  "prices": {
+    "Block": 6500000,
+    "Data": {
+      "KNC-ETH": {
+        "binance": {"Valid": true, "Bids": [{"Quantity": 100, "Rate": 0.0018}, {"Quantity": 100, "Rate": 0.0019}], "Asks": [{"Quantity": 100, "Rate": 0.0021}]},
+        "huobi": {"Valid": true, "Bids": [{"Quantity": 50, "Rate": 0.00195}], "Asks": [{"Quantity": 50, "Rate": 0.00205}]}
+      }
+    }
+  },
+  "auth_data": {
+    "Valid": true,
+    "ReserveBalances": {
+      "ETH": {"Valid": true, "Balance": 100000000000000000000},
+      "KNC": {"Valid": true, "Balance": 600000000000000000000},
+      "OMG": {"Valid": true, "Balance": 1000000000000000000000},
+      "SNT": {"Valid": true, "Balance": 100000000000000000000},
+      "ZRX": {"Valid": true, "Balance": 100000000000000000000},
+      "MKR": {"Valid": true, "Balance": 100000000000000000000}
+    },
+    "ExchangeBalances": {
+      "binance": {"Valid": true, "AvailableBalance": {"ETH": 1, "KNC": 300, "SNT": 500}, "LockedBalance": {"KNC": 100}},
+      "huobi": {"Valid": true, "AvailableBalance": {"ETH": 2, "KNC": 100, "SNT": 300}},
+      "bittrex": {"Valid": true, "AvailableBalance": {"KNC": 10000}}
+    }
+  },
+  "pending_activities": [
+    {"Action": "trade", "Params": {"base": "ZRX", "quote": "ETH"}},
+    {"Action": "set_rates", "Params": {"tokens": ["SNT"]}}
+  ],
+  "targets": {
+    "KNC": {"set_target": {"total_target": 800, "reserve_target": 500, "rebalance_threshold": 0.2, "transfer_threshold": 0.1}},
+    "OMG": {"set_target": {"total_target": 1000, "reserve_target": 600, "rebalance_threshold": 0.2, "transfer_threshold": 0.1}, "exchange_ratio": {"binance": 0.75, "huobi": 0.25}},
+    "SNT": {"set_target": {"total_target": 900, "reserve_target": 800, "rebalance_threshold": 0.2, "transfer_threshold": 0.1}},
+    "ZRX": {"set_target": {"total_target": 1000, "reserve_target": 500, "rebalance_threshold": 0.2, "transfer_threshold": 0.1}}
+  },
+  "quadratics": {
+    "KNC": {"rebalance_quadratic": {"c": 0.1}}
+  }
+}`
+
+func loadTestSnapshot(t *testing.T) Snapshot {
+	t.Helper()
+	var snapshot Snapshot
+	if err := json.Unmarshal([]byte(testSnapshot), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func assertAction(t *testing.T, expected, actual Action) {
+	t.Helper()
+	if math.Abs(expected.Amount-actual.Amount) > 1e-9 || math.Abs(expected.Rate-actual.Rate) > 1e-12 {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+	expected.Amount, expected.Rate, expected.Reason = actual.Amount, actual.Rate, actual.Reason
+	if expected != actual {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestMakePlan(t *testing.T) {
+	plan := MakePlan(loadTestSnapshot(t))
+
+	expected := []Action{
+		{Type: ActionTrade, Exchange: "binance", Token: "KNC", Amount: 80, TradeType: "sell", Rate: 0.0019},
+		{Type: ActionDeposit, Exchange: "binance", Token: "OMG", Amount: 300},
+		{Type: ActionDeposit, Exchange: "huobi", Token: "OMG", Amount: 100},
+		{Type: ActionWithdraw, Exchange: "binance", Token: "SNT", Amount: 500},
+		{Type: ActionWithdraw, Exchange: "huobi", Token: "SNT", Amount: 200},
+	}
+	if len(plan.Actions) != len(expected) {
+		t.Fatalf("expected %d actions, got %+v", len(expected), plan.Actions)
+	}
+	for i := range expected {
+		assertAction(t, expected[i], plan.Actions[i])
+	}
+	if len(plan.Skipped) != 1 || plan.Skipped["ZRX"] == "" {
+		t.Errorf("expected only ZRX to be skipped, got %+v", plan.Skipped)
+	}
+}
+
+func TestMakePlanBuy(t *testing.T) {
+	snapshot := loadTestSnapshot(t)
+	target := snapshot.Targets["KNC"]
+	target.SetTarget.TotalTarget = 2000
+	snapshot.Targets["KNC"] = target
+	delete(snapshot.Quadratics, "KNC")
+
+	plan := MakePlan(snapshot)
+	// huobi has the most ETH to buy with: 2 / 0.00205 KNC
+	assertAction(t, Action{Type: ActionTrade, Exchange: "huobi", Token: "KNC", Amount: 900, TradeType: "buy", Rate: 0.00205}, plan.Actions[0])
+
+	target.SetTarget.TotalTarget = 3000
+	snapshot.Targets["KNC"] = target
+	plan = MakePlan(snapshot)
+	assertAction(t, Action{Type: ActionTrade, Exchange: "huobi", Token: "KNC", Amount: 2 / 0.00205, TradeType: "buy", Rate: 0.00205}, plan.Actions[0])
+}
+
+func TestMakePlanDeterministic(t *testing.T) {
+	expected := MakePlan(loadTestSnapshot(t))
+	for i := 0; i < 10; i++ {
+		if plan := MakePlan(loadTestSnapshot(t)); !reflect.DeepEqual(plan, expected) {
+			t.Fatalf("expected same plan for same snapshot, got %+v and %+v", expected, plan)
+		}
+	}
+}
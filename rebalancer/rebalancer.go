@@ -0,0 +1,250 @@
+package rebalancer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// rebalancerKeyID is recorded as the key ID of the activities executed by
+// the rebalancer.
+const rebalancerKeyID = "rebalancer"
+
+// Storage is the storage of order books and balances.
+type Storage interface {
+	CurrentPriceVersion(timepoint uint64) (common.Version, error)
+	GetAllPrices(common.Version) (common.AllPriceEntry, error)
+	CurrentAuthDataVersion(timepoint uint64) (common.Version, error)
+	GetAuthData(common.Version) (common.AuthDataSnapshot, error)
+}
+
+// MetricStorage is the storage of rebalance configurations.
+type MetricStorage interface {
+	GetRebalanceControl() (common.RebalanceControl, error)
+	GetTargetQtyV2() (common.TokenTargetQtyV2, error)
+	GetRebalanceQuadratic() (common.RebalanceQuadraticRequest, error)
+}
+
+// ActivityStorage provides the pending activities, the tokens having pending
+// activities are not rebalanced.
+type ActivityStorage interface {
+	GetPendingActivities() ([]common.ActivityRecord, error)
+}
+
+// Setting provides the tokens to rebalance.
+type Setting interface {
+	GetInternalTokens() ([]common.Token, error)
+}
+
+// Core executes the rebalance actions, it is implemented by core.ReserveCore.
+type Core interface {
+	Trade(exchange common.Exchange, tradeType string, base common.Token, quote common.Token, rate float64, amount float64,
+		timestamp uint64, keyID string) (id common.ActivityID, done float64, remaining float64, finished bool, err error)
+	Deposit(exchange common.Exchange, token common.Token, amount *big.Int, timestamp uint64, keyID string) (common.ActivityID, error)
+	Withdraw(exchange common.Exchange, token common.Token, amount *big.Int, timestamp uint64, keyID string) (common.ActivityID, error)
+}
+
+// Result is the outcome of executing an action.
+type Result struct {
+	Action Action            `json:"action"`
+	ID     common.ActivityID `json:"id"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// Rebalancer moves balances between reserve and exchanges and trades on
+// exchanges to reach the target quantities. In dry run mode it only logs the
+// plan.
+type Rebalancer struct {
+	storage    Storage
+	metric     MetricStorage
+	setting    Setting
+	activities ActivityStorage
+	core       Core
+	exchanges  map[common.ExchangeID]common.Exchange
+	runner     Runner
+	dryRun     bool
+	// maxDataAge is the max age of order books and balances to rebalance
+	// from.
+	maxDataAge time.Duration
+}
+
+// NewRebalancer creates a new Rebalancer acting on given exchanges, it
+// doesn't rebalance from order books or balances older than maxDataAge.
+func NewRebalancer(storage Storage, metric MetricStorage, setting Setting, activities ActivityStorage, core Core,
+	exchanges []common.Exchange, runner Runner, dryRun bool, maxDataAge time.Duration) *Rebalancer {
+	exchangeMap := make(map[common.ExchangeID]common.Exchange)
+	for _, exchange := range exchanges {
+		exchangeMap[exchange.ID()] = exchange
+	}
+	return &Rebalancer{
+		storage:    storage,
+		metric:     metric,
+		setting:    setting,
+		activities: activities,
+		core:       core,
+		exchanges:  exchangeMap,
+		runner:     runner,
+		dryRun:     dryRun,
+		maxDataAge: maxDataAge,
+	}
+}
+
+// checkAge returns an error if the data of version, a timepoint, is older
+// than maxDataAge at timepoint.
+func (self *Rebalancer) checkAge(name string, version common.Version, timepoint uint64) error {
+	maxAge := uint64(self.maxDataAge / time.Millisecond)
+	if uint64(version)+maxAge < timepoint {
+		return fmt.Errorf("%s of version %d is %s old, older than max age %s", name, version,
+			time.Duration(timepoint-uint64(version))*time.Millisecond, self.maxDataAge)
+	}
+	return nil
+}
+
+// Snapshot loads the latest data at timepoint, it fails if the order books or
+// balances are older than the max data age. The pending activities are read
+// from the activity storage as the balances may not reflect the activities
+// executed since they were fetched.
+func (self *Rebalancer) Snapshot(timepoint uint64) (Snapshot, error) {
+	snapshot := Snapshot{Timestamp: timepoint}
+	tokens, err := self.setting.GetInternalTokens()
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.Tokens = tokens
+	for id := range self.exchanges {
+		snapshot.Exchanges = append(snapshot.Exchanges, id)
+	}
+	authVersion, err := self.storage.CurrentAuthDataVersion(timepoint)
+	if err != nil {
+		return snapshot, fmt.Errorf("Couldn't get auth data version: %s", err)
+	}
+	if err = self.checkAge("Auth data", authVersion, timepoint); err != nil {
+		return snapshot, err
+	}
+	if snapshot.AuthData, err = self.storage.GetAuthData(authVersion); err != nil {
+		return snapshot, err
+	}
+	priceVersion, err := self.storage.CurrentPriceVersion(timepoint)
+	if err != nil {
+		return snapshot, fmt.Errorf("Couldn't get price version: %s", err)
+	}
+	if err = self.checkAge("Price", priceVersion, timepoint); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Prices, err = self.storage.GetAllPrices(priceVersion); err != nil {
+		return snapshot, err
+	}
+	if snapshot.PendingActivities, err = self.activities.GetPendingActivities(); err != nil {
+		return snapshot, fmt.Errorf("Couldn't get pending activities: %s", err)
+	}
+	if snapshot.Targets, err = self.metric.GetTargetQtyV2(); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Quadratics, err = self.metric.GetRebalanceQuadratic(); err != nil {
+		log.Printf("REBALANCER: no rebalance quadratic equation, trading the whole difference (%s)", err)
+	}
+	return snapshot, nil
+}
+
+// Rebalance makes a plan from the latest data at timepoint and executes it
+// unless in dry run mode. Nothing is done when rebalance is on hold.
+func (self *Rebalancer) Rebalance(timepoint uint64) (Plan, []Result, error) {
+	control, err := self.metric.GetRebalanceControl()
+	if err != nil {
+		return Plan{}, nil, err
+	}
+	if !control.Status {
+		return Plan{}, nil, errors.New("Rebalance is on hold")
+	}
+	snapshot, err := self.Snapshot(timepoint)
+	if err != nil {
+		return Plan{}, nil, err
+	}
+	plan := MakePlan(snapshot)
+	for tokenID, reason := range plan.Skipped {
+		log.Printf("REBALANCER: skipped %s: %s", tokenID, reason)
+	}
+	if self.dryRun {
+		for _, action := range plan.Actions {
+			log.Printf("REBALANCER: dry run: %s", action)
+		}
+		return plan, nil, nil
+	}
+	return plan, self.Execute(plan, snapshot.Tokens, timepoint), nil
+}
+
+// Execute executes the actions of the plan, a failed action does not stop
+// the others.
+func (self *Rebalancer) Execute(plan Plan, tokens []common.Token, timepoint uint64) []Result {
+	tokenMap := make(map[string]common.Token)
+	for _, token := range tokens {
+		tokenMap[token.ID] = token
+	}
+	var results []Result
+	for _, action := range plan.Actions {
+		id, err := self.execute(action, tokenMap, timepoint)
+		result := Result{Action: action, ID: id, Error: common.ErrorToString(err)}
+		if err != nil {
+			log.Printf("REBALANCER: failed to %s: %s", action, err)
+		} else {
+			log.Printf("REBALANCER: %s, activity %s", action, id)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (self *Rebalancer) execute(action Action, tokens map[string]common.Token, timepoint uint64) (common.ActivityID, error) {
+	exchange, ok := self.exchanges[action.Exchange]
+	if !ok {
+		return common.ActivityID{}, fmt.Errorf("Exchange %s is not supported", action.Exchange)
+	}
+	token, ok := tokens[action.Token]
+	if !ok {
+		return common.ActivityID{}, fmt.Errorf("Token %s is not supported", action.Token)
+	}
+	switch action.Type {
+	case ActionDeposit:
+		return self.core.Deposit(exchange, token, common.FloatToBigInt(action.Amount, token.Decimals), timepoint, rebalancerKeyID)
+	case ActionWithdraw:
+		return self.core.Withdraw(exchange, token, common.FloatToBigInt(action.Amount, token.Decimals), timepoint, rebalancerKeyID)
+	case ActionTrade:
+		eth, ok := tokens["ETH"]
+		if !ok {
+			return common.ActivityID{}, errors.New("Token ETH is not supported")
+		}
+		id, _, _, _, err := self.core.Trade(exchange, action.TradeType, token, eth, action.Rate, action.Amount, timepoint, rebalancerKeyID)
+		return id, err
+	}
+	return common.ActivityID{}, fmt.Errorf("Unknown action %s", action.Type)
+}
+
+// Run starts the runner and rebalances on every tick.
+func (self *Rebalancer) Run() error {
+	if self.runner == nil {
+		return errors.New("Rebalancer has no runner")
+	}
+	if err := self.runner.Start(); err != nil {
+		return err
+	}
+	go func() {
+		for t := range self.runner.GetRebalanceTicker() {
+			if _, _, err := self.Rebalance(common.TimeToTimepoint(t)); err != nil {
+				log.Printf("REBALANCER: %s", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops the runner.
+func (self *Rebalancer) Stop() error {
+	if self.runner == nil {
+		return nil
+	}
+	return self.runner.Stop()
+}
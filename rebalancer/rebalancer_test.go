@@ -0,0 +1,202 @@
+package rebalancer
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// testStorage returns the data of the snapshot, the version of the data is
+// the requested timepoint unless version is set.
+type testStorage struct {
+	snapshot Snapshot
+	control  common.RebalanceControl
+	version  common.Version
+}
+
+func (self *testStorage) currentVersion(timepoint uint64) common.Version {
+	if self.version != 0 {
+		return self.version
+	}
+	return common.Version(timepoint)
+}
+
+func (self *testStorage) CurrentPriceVersion(timepoint uint64) (common.Version, error) {
+	return self.currentVersion(timepoint), nil
+}
+
+func (self *testStorage) GetAllPrices(common.Version) (common.AllPriceEntry, error) {
+	return self.snapshot.Prices, nil
+}
+
+func (self *testStorage) CurrentAuthDataVersion(timepoint uint64) (common.Version, error) {
+	return self.currentVersion(timepoint), nil
+}
+
+func (self *testStorage) GetAuthData(common.Version) (common.AuthDataSnapshot, error) {
+	return self.snapshot.AuthData, nil
+}
+
+func (self *testStorage) GetRebalanceControl() (common.RebalanceControl, error) {
+	return self.control, nil
+}
+
+func (self *testStorage) GetTargetQtyV2() (common.TokenTargetQtyV2, error) {
+	return self.snapshot.Targets, nil
+}
+
+func (self *testStorage) GetRebalanceQuadratic() (common.RebalanceQuadraticRequest, error) {
+	return self.snapshot.Quadratics, nil
+}
+
+func (self *testStorage) GetInternalTokens() ([]common.Token, error) {
+	return self.snapshot.Tokens, nil
+}
+
+func (self *testStorage) GetPendingActivities() ([]common.ActivityRecord, error) {
+	return self.snapshot.PendingActivities, nil
+}
+
+// testExchange only implements ID, the other methods are not called by the
+// rebalancer.
+type testExchange struct {
+	common.Exchange
+	id common.ExchangeID
+}
+
+func (self testExchange) ID() common.ExchangeID {
+	return self.id
+}
+
+// testCore records the executed actions, the activities are stored as
+// pending in storage.
+type testCore struct {
+	actions []string
+	storage *testStorage
+}
+
+func (self *testCore) record(action string, params map[string]interface{}) {
+	self.storage.snapshot.PendingActivities = append(self.storage.snapshot.PendingActivities,
+		common.ActivityRecord{Action: action, Params: params})
+}
+
+func (self *testCore) Trade(exchange common.Exchange, tradeType string, base common.Token, quote common.Token, rate float64, amount float64,
+	timestamp uint64, keyID string) (common.ActivityID, float64, float64, bool, error) {
+	self.actions = append(self.actions, tradeType+" "+base.ID+"-"+quote.ID+" "+string(exchange.ID())+" "+keyID)
+	self.record(common.ActionTrade, map[string]interface{}{"base": base.ID, "quote": quote.ID})
+	return common.ActivityID{Timepoint: timestamp, EID: "trade"}, 0, amount, false, nil
+}
+
+func (self *testCore) Deposit(exchange common.Exchange, token common.Token, amount *big.Int, timestamp uint64, keyID string) (common.ActivityID, error) {
+	self.actions = append(self.actions, "deposit "+amount.String()+" "+token.ID+" "+string(exchange.ID())+" "+keyID)
+	self.record(common.ActionDeposit, map[string]interface{}{"token": token.ID})
+	return common.ActivityID{Timepoint: timestamp, EID: "deposit"}, nil
+}
+
+func (self *testCore) Withdraw(exchange common.Exchange, token common.Token, amount *big.Int, timestamp uint64, keyID string) (common.ActivityID, error) {
+	if exchange.ID() == "huobi" {
+		return common.ActivityID{}, errors.New("withdraw is disabled")
+	}
+	self.actions = append(self.actions, "withdraw "+amount.String()+" "+token.ID+" "+string(exchange.ID())+" "+keyID)
+	self.record(common.ActionWithdraw, map[string]interface{}{"token": token.ID})
+	return common.ActivityID{Timepoint: timestamp, EID: "withdraw"}, nil
+}
+
+func newTestRebalancer(t *testing.T, dryRun bool) (*Rebalancer, *testStorage, *testCore) {
+	st := &testStorage{snapshot: loadTestSnapshot(t), control: common.RebalanceControl{Status: true}}
+	core := &testCore{storage: st}
+	exchanges := []common.Exchange{testExchange{id: "binance"}, testExchange{id: "huobi"}}
+	return NewRebalancer(st, st, st, st, core, exchanges, nil, dryRun, time.Minute), st, core
+}
+
+func TestRebalance(t *testing.T) {
+	rb, _, core := newTestRebalancer(t, false)
+	plan, results, err := rb.Rebalance(1539248400000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(plan.Actions) || len(results) != 5 {
+		t.Fatalf("expected a result for each of 5 actions, got %+v", results)
+	}
+	expected := []string{
+		"sell KNC-ETH binance rebalancer",
+		"deposit 300000000000000000000 OMG binance rebalancer",
+		"deposit 100000000000000000000 OMG huobi rebalancer",
+		"withdraw 500000000000000000000 SNT binance rebalancer",
+	}
+	if len(core.actions) != len(expected) {
+		t.Fatalf("expected actions %v, got %v", expected, core.actions)
+	}
+	for i := range expected {
+		if core.actions[i] != expected[i] {
+			t.Errorf("expected action %s, got %s", expected[i], core.actions[i])
+		}
+	}
+	if results[4].Error == "" || results[3].Error != "" || results[3].ID.EID != "withdraw" {
+		t.Errorf("expected only the withdraw from huobi to fail, got %+v", results)
+	}
+}
+
+func TestRebalanceDryRun(t *testing.T) {
+	rb, _, core := newTestRebalancer(t, true)
+	plan, results, err := rb.Rebalance(1539248400000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 5 || len(results) != 0 || len(core.actions) != 0 {
+		t.Errorf("expected plan without execution in dry run, got plan %+v, executed %v", plan, core.actions)
+	}
+}
+
+func TestRebalanceHold(t *testing.T) {
+	rb, st, core := newTestRebalancer(t, false)
+	st.control.Status = false
+	if _, _, err := rb.Rebalance(1539248400000); err == nil {
+		t.Error("expected error rebalancing on hold")
+	}
+	if len(core.actions) != 0 {
+		t.Errorf("expected no action on hold, got %v", core.actions)
+	}
+	if err := rb.Run(); err == nil {
+		t.Error("expected error running rebalancer without runner")
+	}
+}
+
+func TestRebalanceStaleData(t *testing.T) {
+	const timepoint = 1539248400000
+	rb, st, core := newTestRebalancer(t, false)
+	// the balances are not fetched again after the first tick
+	st.version = timepoint
+	if _, _, err := rb.Rebalance(timepoint); err != nil {
+		t.Fatal(err)
+	}
+	executed := len(core.actions)
+	if executed == 0 {
+		t.Fatal("expected actions executed on first tick")
+	}
+
+	plan, results, err := rb.Rebalance(timepoint + 30000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 0 || len(results) != 0 || len(core.actions) != executed {
+		t.Errorf("expected no action repeated from stale balances, got plan %+v, executed %v", plan, core.actions[executed:])
+	}
+	for _, token := range []string{"KNC", "OMG", "SNT"} {
+		if plan.Skipped[token] != "pending activities" {
+			t.Errorf("expected %s skipped for pending activities, got %q", token, plan.Skipped[token])
+		}
+	}
+
+	// the pending activities are done but the balances are still stale
+	st.snapshot.PendingActivities = nil
+	if _, _, err = rb.Rebalance(timepoint + 2*60000); err == nil {
+		t.Error("expected error rebalancing from balances older than max data age")
+	}
+	if len(core.actions) != executed {
+		t.Errorf("expected no action from balances older than max data age, got %v", core.actions[executed:])
+	}
+}
@@ -0,0 +1,40 @@
+package rebalancer
+
+import (
+	"time"
+)
+
+// Runner periodically triggers the rebalancer to rebalance.
+type Runner interface {
+	// Start initializes the ticker. It must be called before runner is usable.
+	Start() error
+	// Stop stops the ticker and free usage resources.
+	// It must only be called after runner is started.
+	Stop() error
+	GetRebalanceTicker() <-chan time.Time
+}
+
+// TickerRunner is an implementation of Runner that use simple time ticker.
+type TickerRunner struct {
+	duration time.Duration
+	clock    *time.Ticker
+}
+
+// NewTickerRunner creates a TickerRunner ticking every given duration.
+func NewTickerRunner(duration time.Duration) *TickerRunner {
+	return &TickerRunner{duration: duration}
+}
+
+func (self *TickerRunner) GetRebalanceTicker() <-chan time.Time {
+	return self.clock.C
+}
+
+func (self *TickerRunner) Start() error {
+	self.clock = time.NewTicker(self.duration)
+	return nil
+}
+
+func (self *TickerRunner) Stop() error {
+	self.clock.Stop()
+	return nil
+}
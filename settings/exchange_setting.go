@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io/ioutil"
 	"log"
 	"os"
@@ -22,6 +23,7 @@ const (
 	Bittrex                            //bittrex
 	Huobi                              //huobi
 	StableExchange                     //stable_exchange
+	Kucoin                             //kucoin
 )
 const exchangeEnv string = "KYBER_EXCHANGES"
 
@@ -36,6 +38,7 @@ var exchangeNameValue = map[string]ExchangeName{
 	"bittrex":         Bittrex,
 	"huobi":           Huobi,
 	"stable_exchange": StableExchange,
+	"kucoin":          Kucoin,
 }
 
 // Running Exchange get the exchangeEnvironment params and return the list of exchanges ID for the current run
@@ -55,6 +58,32 @@ func ExchangeTypeValues() map[string]ExchangeName {
 	return exchangeNameValue
 }
 
+// registeredExchangeBase is the lowest ExchangeName of registered exchanges,
+// it leaves room for the exchanges built in the current code deployment.
+const registeredExchangeBase ExchangeName = 1 << 16
+
+// RegisterExchange registers an exchange which is not built in the current
+// code deployment, eg: a config driven exchange. Its ExchangeName is derived
+// from the name so settings stored by ExchangeName survive restarts.
+// DO NOT CALL this once settings are loaded.
+func RegisterExchange(name string) (ExchangeName, error) {
+	if _, ok := exchangeNameValue[name]; ok {
+		return 0, fmt.Errorf("Exchange %s is already registered", name)
+	}
+	h := fnv.New32a()
+	if _, err := h.Write([]byte(name)); err != nil {
+		return 0, err
+	}
+	exName := registeredExchangeBase + ExchangeName(h.Sum32()&0x3fffffff)
+	for other, value := range exchangeNameValue {
+		if value == exName {
+			return 0, fmt.Errorf("Exchange %s collides with registered exchange %s, please rename it", name, other)
+		}
+	}
+	exchangeNameValue[name] = exName
+	return exName, nil
+}
+
 type ExchangeSetting struct {
 	Storage ExchangeStorage
 }
@@ -4,9 +4,9 @@ package settings
 
 import "strconv"
 
-const _ExchangeName_name = "binancebittrexhuobistable_exchange"
+const _ExchangeName_name = "binancebittrexhuobistable_exchangekucoin"
 
-var _ExchangeName_index = [...]uint8{0, 7, 14, 19, 34}
+var _ExchangeName_index = [...]uint8{0, 7, 14, 19, 34, 40}
 
 func (i ExchangeName) String() string {
 	if i < 0 || i >= ExchangeName(len(_ExchangeName_index)-1) {
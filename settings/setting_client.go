@@ -30,6 +30,7 @@ const (
 
 type clientAuthentication interface {
 	KNSign(message string) string
+	RequestMessage(method, path, params string) string
 }
 
 // SettingClient is a http Client used to query setting from core APIs
@@ -89,7 +90,7 @@ func (sc *SettingClient) newRequest(method, url string, params map[string]string
 	if !ok {
 		log.Printf("there was no nonce")
 	} else {
-		sc.sign(req, q.Encode(), nonce)
+		sc.sign(req, sc.authEngine.RequestMessage(method, req.URL.Path, q.Encode()), nonce)
 	}
 
 	return req, nil
@@ -251,6 +252,29 @@ func (sc *SettingClient) GetAddresses(setType AddressSetName) ([]ethereum.Addres
 	return addressesReply.Data, nil
 }
 
+// Get requests the endpoint of core with the signed params and decodes the
+// data of a successful response into data.
+func (sc *SettingClient) Get(endpoint string, params map[string]string, data interface{}) error {
+	url := fmt.Sprintf("%s/%s", sc.coreURL, endpoint)
+	params[nonceParamKey] = strconv.FormatUint(common.GetTimepoint(), 10)
+	response, err := sc.getReponse(http.MethodGet, url, params)
+	if err != nil {
+		return err
+	}
+	var reply struct {
+		Success bool
+		Reason  string
+		Data    json.RawMessage
+	}
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return err
+	}
+	if !reply.Success {
+		return fmt.Errorf("Request to %s failed: %s", endpoint, reply.Reason)
+	}
+	return json.Unmarshal(reply.Data, data)
+}
+
 // ReadyToServe is called prior to running stat functions to make sure core is up
 func (sc *SettingClient) ReadyToServe() error {
 	url := fmt.Sprintf("%s/%s", sc.coreURL, readyToServeEndpoint)
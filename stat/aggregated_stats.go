@@ -0,0 +1,182 @@
+package stat
+
+import (
+	"fmt"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// TradeLogAggregations are the aggregations of trade logs made by the trade
+// log processor.
+var TradeLogAggregations = []string{
+	BurnfeeAggregation,
+	VolumeStatAggregation,
+	TradeSummaryAggregation,
+	WalletAggregation,
+	CountryAggregation,
+	UserInfoAggregation,
+}
+
+// aggregatedStats holds the stats of trade logs for an aggregation, only the
+// map of the aggregation is set.
+type aggregatedStats struct {
+	aggregation string
+	burnFee     map[string]common.BurnFeeStatsTimeZone
+	volume      map[string]common.VolumeStatsTimeZone
+	metric      map[string]common.MetricStatsTimeZone
+	userInfo    map[string]common.UserInfoTimezone
+}
+
+// aggregateTrades aggregates tradeLogs the same way as the trade log
+// processor does for aggregation.
+func (self *Fetcher) aggregateTrades(aggregation string, tradeLogs []common.TradeLog,
+	allFirstTradeEver map[ethereum.Address]uint64, kycEdUsers map[string]uint64) (aggregatedStats, error) {
+	stats := aggregatedStats{aggregation: aggregation}
+	switch aggregation {
+	case BurnfeeAggregation:
+		stats.burnFee = map[string]common.BurnFeeStatsTimeZone{}
+		for _, trade := range tradeLogs {
+			if err := self.aggregateBurnFeeStats(trade, stats.burnFee); err != nil {
+				return stats, err
+			}
+		}
+	case VolumeStatAggregation:
+		stats.volume = map[string]common.VolumeStatsTimeZone{}
+		for _, trade := range tradeLogs {
+			if err := self.aggregateVolumeStats(trade, stats.volume); err != nil {
+				return stats, err
+			}
+		}
+	case TradeSummaryAggregation, WalletAggregation, CountryAggregation:
+		aggregate := self.aggregateTradeSumary
+		if aggregation == WalletAggregation {
+			aggregate = self.aggregateWalletStats
+		} else if aggregation == CountryAggregation {
+			aggregate = self.aggregateCountryStats
+		}
+		stats.metric = map[string]common.MetricStatsTimeZone{}
+		for _, trade := range tradeLogs {
+			if err := aggregate(trade, stats.metric, allFirstTradeEver, kycEdUsers); err != nil {
+				return stats, err
+			}
+		}
+	case UserInfoAggregation:
+		stats.userInfo = map[string]common.UserInfoTimezone{}
+		for _, trade := range tradeLogs {
+			if err := self.aggregateUserInfo(trade, stats.userInfo); err != nil {
+				return stats, err
+			}
+		}
+	default:
+		return stats, fmt.Errorf("Unknown aggregation %s", aggregation)
+	}
+	return stats, nil
+}
+
+// store adds the stats to the storage and sets last as the last processed
+// trade log timepoint of the aggregation.
+func (self aggregatedStats) store(storage StatStorage, last uint64) error {
+	switch self.aggregation {
+	case BurnfeeAggregation:
+		return storage.SetBurnFeeStat(self.burnFee, last)
+	case VolumeStatAggregation:
+		return storage.SetVolumeStat(self.volume, last)
+	case TradeSummaryAggregation:
+		return storage.SetTradeSummary(self.metric, last)
+	case WalletAggregation:
+		return storage.SetWalletStat(self.metric, last)
+	case CountryAggregation:
+		return storage.SetCountryStat(self.metric, last)
+	case UserInfoAggregation:
+		return storage.SetUserList(self.userInfo, last)
+	}
+	return fmt.Errorf("Unknown aggregation %s", self.aggregation)
+}
+
+// filter keeps only the stats of the time buckets starting from fromTime to
+// before toTime.
+func (self aggregatedStats) filter(fromTime, toTime uint64) {
+	inRange := func(timestamp uint64) bool {
+		return timestamp >= fromTime && timestamp < toTime
+	}
+	for _, freqs := range self.burnFee {
+		for _, data := range freqs {
+			for timestamp := range data {
+				if !inRange(timestamp) {
+					delete(data, timestamp)
+				}
+			}
+		}
+	}
+	for _, freqs := range self.volume {
+		for _, data := range freqs {
+			for timestamp := range data {
+				if !inRange(timestamp) {
+					delete(data, timestamp)
+				}
+			}
+		}
+	}
+	for _, timezones := range self.metric {
+		for _, data := range timezones {
+			for timestamp := range data {
+				if !inRange(timestamp) {
+					delete(data, timestamp)
+				}
+			}
+		}
+	}
+	for _, timezones := range self.userInfo {
+		for _, data := range timezones {
+			for timestamp := range data {
+				if !inRange(timestamp) {
+					delete(data, timestamp)
+				}
+			}
+		}
+	}
+}
+
+// negate turns the stats into their opposite, so storing them reverts the
+// aggregated trades.
+func (self aggregatedStats) negate() {
+	for _, freqs := range self.burnFee {
+		for _, data := range freqs {
+			for timestamp, stat := range data {
+				data[timestamp] = common.NewBurnFeeStats(-stat.TotalBurnFee)
+			}
+		}
+	}
+	for _, freqs := range self.volume {
+		for _, data := range freqs {
+			for timestamp, stat := range data {
+				data[timestamp] = common.NewVolumeStats(-stat.ETHVolume, -stat.USDAmount, -stat.Volume)
+			}
+		}
+	}
+	for _, timezones := range self.metric {
+		for _, data := range timezones {
+			for timestamp, stat := range data {
+				data[timestamp] = common.MetricStats{
+					ETHVolume:          -stat.ETHVolume,
+					USDVolume:          -stat.USDVolume,
+					BurnFee:            -stat.BurnFee,
+					TradeCount:         -stat.TradeCount,
+					UniqueAddr:         -stat.UniqueAddr,
+					KYCEd:              -stat.KYCEd,
+					NewUniqueAddresses: -stat.NewUniqueAddresses,
+				}
+			}
+		}
+	}
+	for _, timezones := range self.userInfo {
+		for _, data := range timezones {
+			for timestamp, info := range data {
+				info.ETHVolume = -info.ETHVolume
+				info.USDVolume = -info.USDVolume
+				data[timestamp] = info
+			}
+		}
+	}
+}
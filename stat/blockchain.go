@@ -11,6 +11,7 @@ import (
 // with blockchain.
 type Blockchain interface {
 	CurrentBlock() (uint64, error)
+	GetBlockHash(block uint64) (ethereum.Hash, error)
 	GetLogs(fromBlock uint64, toBlock uint64) ([]common.KNLog, error)
 	GetReserveRates(atBlock, currentBlock uint64, reserveAddress ethereum.Address, tokens []common.Token) (common.ReserveRates, error)
 	GetPricingMethod(inputData string) (*abi.Method, error)
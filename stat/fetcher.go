@@ -4,16 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math/big"
-	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
 	"github.com/KyberNetwork/reserve-data/settings"
 	statutil "github.com/KyberNetwork/reserve-data/stat/util"
 	ethereum "github.com/ethereum/go-ethereum/common"
@@ -39,8 +38,7 @@ const (
 	UserInfoAggregation     string = "user_info_aggregation"
 	TradeSummaryKey         string = "trade_summary"
 
-	etherScanAPIEndpoint      = "http://api.etherscan.io/api"
-	broadcastKyberAPIEndpoint = "https://broadcast.kyber.network"
+	etherScanAPIEndpoint = "http://api.etherscan.io/api"
 )
 
 type Fetcher struct {
@@ -61,6 +59,21 @@ type Fetcher struct {
 	ipLocator              *statutil.IPLocator
 	addressLookup          map[ethereum.Address]common.Token
 	mu                     sync.RWMutex
+	// aggregateMu prevents trade logs from being rolled back while they are
+	// aggregated.
+	aggregateMu   sync.Mutex
+	logSinks      []LogSink
+	logSinkNotify chan struct{}
+	// logSinkMu prevents sink cursors from being rewound while logs are
+	// delivered.
+	logSinkMu sync.Mutex
+	// geoSource looks up the geo of trade logs, they are not enriched if it
+	// is nil.
+	geoSource TxGeoSource
+	geoNotify chan struct{}
+	// geoMu prevents the geo cursor from being rewound while trade logs are
+	// enriched.
+	geoMu sync.Mutex
 }
 
 func NewFetcher(
@@ -90,6 +103,8 @@ func NewFetcher(
 		setting:           setting,
 		ipLocator:         iploc,
 		addressLookup:     make(map[ethereum.Address]common.Token),
+		logSinkNotify:     make(chan struct{}, 1),
+		geoNotify:         make(chan struct{}, 1),
 	}
 	lastBlockChecked, err := fetcher.feeSetRateStorage.GetLastBlockChecked()
 	if err != nil {
@@ -131,6 +146,12 @@ func (self *Fetcher) WaitForCoreAndRun() {
 	go self.RunTradeLogProcessor()
 	go self.RunCatLogProcessor()
 	go self.RunFeeSetrateFetcher()
+	if len(self.logSinks) > 0 {
+		go self.RunLogSinks()
+	}
+	if self.geoSource != nil {
+		go self.RunGeoEnrichment()
+	}
 }
 
 func (self *Fetcher) Run() error {
@@ -145,9 +166,7 @@ func (self *Fetcher) Run() error {
 }
 
 func (self *Fetcher) RunFeeSetrateFetcher() {
-	client := http.Client{
-		Timeout: 5 * time.Second,
-	}
+	client := httpclient.NewClient("etherscan", httpclient.DefaultConfig(5*time.Second))
 	for {
 		err := self.FetchTxs(client)
 		if err != nil {
@@ -162,7 +181,7 @@ type APIResponse struct {
 	Result  []common.SetRateTxInfo `json:"result"`
 }
 
-func (self *Fetcher) FetchTxs(client http.Client) error {
+func (self *Fetcher) FetchTxs(client *httpclient.Client) error {
 	fromBlock := self.blockNumMarker
 	toBlock := self.GetToBlock()
 	if toBlock == 0 {
@@ -178,17 +197,8 @@ func (self *Fetcher) FetchTxs(client http.Client) error {
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("cannot close response body: %s", cErr.Error())
-		}
-	}()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
 	apiResponse := APIResponse{}
-	err = json.Unmarshal(body, &apiResponse)
+	err = json.Unmarshal(resp.Body, &apiResponse)
 	if err != nil {
 		log.Printf("can't unmarshal data from etherscan: %s", err)
 		return err
@@ -356,6 +366,19 @@ func (self *Fetcher) RunCountryStatAggregation(t time.Time) {
 		return
 	}
 	fromTime, toTime := self.GetTradeLogTimeRange(fromTime, t)
+	// countries are known once trade logs are enriched
+	enrichedUntil, enriched, err := self.geoEnrichedUntil()
+	if err != nil {
+		log.Printf("get geo enrichment state failed: %v", err)
+		return
+	}
+	if enriched && toTime > enrichedUntil {
+		if enrichedUntil < fromTime {
+			log.Printf("STAT: waiting for geo enrichment of trade logs from %d", fromTime)
+			return
+		}
+		toTime = enrichedUntil
+	}
 	tradeLogs, err := self.logStorage.GetTradeLogs(fromTime, toTime)
 	if err != nil {
 		log.Printf("get trade log from db failed: %v", err)
@@ -653,6 +676,7 @@ func (self *Fetcher) RunTradeLogProcessor() {
 	for {
 		t := <-self.runner.GetTradeLogProcessorTicker()
 		// self.RunUserAggregation(t)
+		self.aggregateMu.Lock()
 		wg := sync.WaitGroup{}
 		wg.Add(1)
 		go runAggregationInParallel(&wg, t, self.RunBurnFeeAggregation)
@@ -667,6 +691,7 @@ func (self *Fetcher) RunTradeLogProcessor() {
 		wg.Add(1)
 		go runAggregationInParallel(&wg, t, self.RunUserInfoAggregation)
 		wg.Wait()
+		self.aggregateMu.Unlock()
 	}
 }
 
@@ -780,40 +805,75 @@ func (self *Fetcher) RunLogFetcher() {
 		t := <-self.runner.GetLogTicker()
 		timepoint := common.TimeToTimepoint(t)
 		log.Printf("LogFetcher - got signal in log channel with timestamp %d", timepoint)
-		lastBlock, err := self.logStorage.LastBlock()
-		if lastBlock == 0 {
-			lastBlock = self.deployBlock
+		self.fetchNewLogs(timepoint)
+	}
+}
+
+// fetchNewLogs fetches the logs after the last fetched block. If the fetched
+// blocks are not on the chain anymore, their logs are rolled back instead to
+// be fetched again on next call. Nothing is fetched until a pending rollback
+// is completed.
+func (self *Fetcher) fetchNewLogs(timepoint uint64) {
+	lastBlock, err := self.logStorage.LastBlock()
+	if lastBlock == 0 {
+		lastBlock = self.deployBlock
+	}
+	if err != nil {
+		log.Printf("LogFetcher - failed to get last fetched log block, err: %+v", err)
+		return
+	}
+	if resumed, rErr := self.resumeRollback(); rErr != nil || resumed {
+		if rErr != nil {
+			log.Printf("LogFetcher - completing pending rollback failed, err: %+v", rErr)
 		}
-		if err == nil {
-			toBlock := lastBlock + 1 + 1440 // 1440 is considered as 6 hours
-			if toBlock > self.currentBlock-reorgBlockSafe {
-				toBlock = self.currentBlock - reorgBlockSafe
-			}
-			if lastBlock+1 > toBlock {
-				continue
-			}
-			nextBlock, fErr := self.FetchLogs(lastBlock+1, toBlock, timepoint)
-			if fErr != nil {
-				// in case there is error, we roll back and try it again.
-				// dont have to do anything here. just continute with the loop.
-				log.Printf("LogFetcher - continue with the loop to try it again: %s", fErr)
-			} else {
-				if nextBlock == lastBlock && toBlock != 0 {
-					// in case that we are querying old blocks (6 hours in the past)
-					// and got no logs. we will still continue with next block
-					// It is not the case if toBlock == 0, means we are querying
-					// best window, we should keep querying it in order not to
-					// miss any logs due to node inconsistency
-					nextBlock = toBlock
-				}
-				log.Printf("LogFetcher - update log block: %d", nextBlock)
-				if err = self.logStorage.UpdateLogBlock(nextBlock, timepoint); err != nil {
-					log.Printf("Update log block: %s", err.Error())
-				}
-			}
-		} else {
-			log.Printf("LogFetcher - failed to get last fetched log block, err: %+v", err)
+		return
+	}
+	reorgBlock, err := self.checkReorg(lastBlock)
+	if err != nil {
+		log.Printf("LogFetcher - checking chain reorganization failed, err: %+v", err)
+		return
+	}
+	if reorgBlock != 0 {
+		log.Printf("LogFetcher - chain is reorganized from block %d, rolling back logs", reorgBlock)
+		if err = self.rollbackLogs(reorgBlock); err != nil {
+			log.Printf("LogFetcher - rolling back logs from block %d failed, err: %+v", reorgBlock, err)
 		}
+		return
+	}
+	toBlock := lastBlock + 1 + 1440 // 1440 is considered as 6 hours
+	if toBlock > self.currentBlock-reorgBlockSafe {
+		toBlock = self.currentBlock - reorgBlockSafe
+	}
+	if lastBlock+1 > toBlock {
+		return
+	}
+	// the hash is got before the logs, if the block is reorganized in
+	// between, it will be detected on next call.
+	toBlockHash, hErr := self.blockchain.GetBlockHash(toBlock)
+	if hErr != nil {
+		log.Printf("LogFetcher - getting hash of block %d failed: %s", toBlock, hErr)
+	}
+	nextBlock, fErr := self.FetchLogs(lastBlock+1, toBlock, timepoint)
+	if fErr != nil {
+		// in case there is error, we roll back and try it again.
+		// dont have to do anything here. just continute with the loop.
+		log.Printf("LogFetcher - continue with the loop to try it again: %s", fErr)
+		return
+	}
+	if nextBlock == lastBlock && toBlock != 0 {
+		// in case that we are querying old blocks (6 hours in the past)
+		// and got no logs. we will still continue with next block
+		// It is not the case if toBlock == 0, means we are querying
+		// best window, we should keep querying it in order not to
+		// miss any logs due to node inconsistency
+		nextBlock = toBlock
+	}
+	if nextBlock == toBlock && hErr == nil {
+		self.storeBlockHash(toBlock, toBlockHash)
+	}
+	log.Printf("LogFetcher - update log block: %d", nextBlock)
+	if err = self.logStorage.UpdateLogBlock(nextBlock, timepoint); err != nil {
+		log.Printf("Update log block: %s", err.Error())
 	}
 }
 
@@ -828,42 +888,6 @@ func (self *Fetcher) RunBlockFetcher() {
 	}
 }
 
-//GetTradeGeo get geo from trade log
-func GetTradeGeo(ipLocator *statutil.IPLocator, txHash string) (string, string, error) {
-	url := fmt.Sprintf("%s/get-tx-info/%s", broadcastKyberAPIEndpoint, txHash)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", "", err
-	}
-	response := common.TradeLogGeoInfoResp{}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("Response body close error: %s", cErr.Error())
-		}
-	}()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", "", err
-	}
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return "", "", err
-	}
-	if response.Success {
-		var country string
-		if response.Data.Country != "" {
-			return response.Data.IP, response.Data.Country, err
-		}
-		country, err = ipLocator.IPToCountry(response.Data.IP)
-		if err != nil {
-			return "", "", err
-		}
-		return response.Data.IP, country, err
-	}
-	return "", "", err
-}
-
 func enforceFromBlock(fromBlock uint64) uint64 {
 	if fromBlock == 0 {
 		return 0
@@ -876,17 +900,6 @@ func enforceFromBlock(fromBlock uint64) uint64 {
 // 	block, index,
 // }
 
-//SetCountryField set country field for tradelog
-func SetcountryFields(ipLocator *statutil.IPLocator, l *common.TradeLog) {
-	txHash := l.TxHash()
-	ip, country, err := GetTradeGeo(ipLocator, txHash.Hex())
-	if err != nil {
-		log.Printf("LogFetcher - Getting country failed: %s", err.Error())
-	}
-	l.IP = ip
-	l.Country = country
-}
-
 // CheckDupAndStoreTradeLog Check if the tradelog is duplicated, if it is not, manage to store it into DB
 // return error if db operation is not successful
 func (self *Fetcher) CheckDupAndStoreTradeLog(l common.TradeLog, timepoint uint64) error {
@@ -943,11 +956,11 @@ func (self *Fetcher) FetchLogs(fromBlock uint64, toBlock uint64, timepoint uint6
 					log.Printf("LogFetcher: ERROR cannot convert log (%v) to tradelog", il)
 					continue
 				}
-				SetcountryFields(self.ipLocator, &l)
 				if dbErr := self.CheckDupAndStoreTradeLog(l, timepoint); dbErr != nil {
 					log.Printf("LogFetcher - at block %d, storing trade log failed, stop at current block and wait till next ticker, err: %+v", l.BlockNo(), dbErr)
 					return maxBlock, dbErr
 				}
+				self.storeBlockHash(l.BlockNumber, l.BlockHash)
 			} else if il.Type() == "SetCatLog" {
 				l, ok := il.(common.SetCatLog)
 				if !ok {
@@ -958,6 +971,7 @@ func (self *Fetcher) FetchLogs(fromBlock uint64, toBlock uint64, timepoint uint6
 					log.Printf("LogFetcher - at block %d, storing cat log failed, stop at current block and wait till next ticker, err: %+v", l.BlockNo(), dbErr)
 					return maxBlock, dbErr
 				}
+				self.storeBlockHash(l.BlockNumber, l.BlockHash)
 			}
 			if il.BlockNo() > maxBlock {
 				maxBlock = il.BlockNo()
@@ -966,6 +980,8 @@ func (self *Fetcher) FetchLogs(fromBlock uint64, toBlock uint64, timepoint uint6
 				}
 			}
 		}
+		self.notifyLogSinks()
+		self.notifyGeoEnrichment()
 		return maxBlock, nil
 	}
 	return enforceFromBlock(fromBlock), nil
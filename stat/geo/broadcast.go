@@ -0,0 +1,52 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultBroadcastEndpoint is the Kyber broadcast API recording the IP from
+// which transactions are broadcasted.
+const DefaultBroadcastEndpoint = "https://broadcast.kyber.network"
+
+// BroadcastSource looks up the geo of transactions from the broadcast API.
+type BroadcastSource struct {
+	endpoint string
+	client   *httpclient.Client
+}
+
+// NewBroadcastSource creates a BroadcastSource requesting endpoint, requests
+// time out after timeout.
+func NewBroadcastSource(endpoint string, timeout time.Duration) *BroadcastSource {
+	return &BroadcastSource{
+		endpoint: endpoint,
+		client:   httpclient.NewClient("broadcast", httpclient.DefaultConfig(timeout)),
+	}
+}
+
+// GetTxGeo returns the IP and country of txHash, empty if the API doesn't
+// know the transaction.
+func (self *BroadcastSource) GetTxGeo(txHash ethereum.Hash) (string, string, error) {
+	url := fmt.Sprintf("%s/get-tx-info/%s", self.endpoint, txHash.Hex())
+	resp, err := self.client.Get(url)
+	if err != nil {
+		return "", "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("Broadcast API responded status %d for %s", resp.StatusCode, txHash.Hex())
+	}
+	response := common.TradeLogGeoInfoResp{}
+	if err = json.Unmarshal(resp.Body, &response); err != nil {
+		return "", "", err
+	}
+	if !response.Success {
+		return "", "", nil
+	}
+	return response.Data.IP, response.Data.Country, nil
+}
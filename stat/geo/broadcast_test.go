@@ -0,0 +1,55 @@
+package geo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+func TestBroadcastSource(t *testing.T) {
+	var (
+		known   = ethereum.HexToHash("0x01")
+		located = ethereum.HexToHash("0x02")
+		failing = ethereum.HexToHash("0x03")
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/get-tx-info/" + known.Hex():
+			fmt.Fprint(w, `{"success": true, "data": {"IP": "81.2.69.142", "Country": ""}}`)
+		case "/get-tx-info/" + located.Hex():
+			fmt.Fprint(w, `{"success": true, "data": {"IP": "14.177.12.126", "Country": "VN"}}`)
+		case "/get-tx-info/" + failing.Hex():
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			fmt.Fprint(w, `{"success": false}`)
+		}
+	}))
+	defer server.Close()
+
+	source := NewBroadcastSource(server.URL, time.Second)
+	var tests = []struct {
+		txHash  ethereum.Hash
+		ip      string
+		country string
+		err     bool
+	}{
+		{txHash: known, ip: "81.2.69.142"},
+		{txHash: located, ip: "14.177.12.126", country: "VN"},
+		{txHash: failing, err: true},
+		{txHash: ethereum.HexToHash("0x04")},
+	}
+	for _, test := range tests {
+		ip, country, err := source.GetTxGeo(test.txHash)
+		if (err != nil) != test.err {
+			t.Errorf("unexpected error %v for %s", err, test.txHash.Hex())
+			continue
+		}
+		if ip != test.ip || country != test.country {
+			t.Errorf("expected %q, %q for %s, got %q, %q", test.ip, test.country, test.txHash.Hex(), ip, country)
+		}
+	}
+}
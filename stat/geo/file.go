@@ -0,0 +1,67 @@
+package geo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// FileSource looks up the IP of transactions from a JSON file mapping
+// transaction hashes to IPs, eg: {"0xabc...": "81.2.69.142"}. The file is
+// loaded again when it is modified.
+type FileSource struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	ips     map[string]string
+}
+
+// NewFileSource creates a FileSource reading path, the file must exist.
+func NewFileSource(path string) (*FileSource, error) {
+	source := &FileSource{path: path}
+	if err := source.reload(); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+func (self *FileSource) reload() error {
+	info, err := os.Stat(self.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(self.modTime) && self.ips != nil {
+		return nil
+	}
+	raw, err := ioutil.ReadFile(self.path)
+	if err != nil {
+		return err
+	}
+	ips := map[string]string{}
+	if err = json.Unmarshal(raw, &ips); err != nil {
+		return err
+	}
+	self.ips = map[string]string{}
+	for txHash, ip := range ips {
+		self.ips[strings.ToLower(txHash)] = ip
+	}
+	self.modTime = info.ModTime()
+	return nil
+}
+
+// GetTxGeo returns the IP of txHash in the file, empty if it isn't in the
+// file. The country is left to be located from the IP.
+func (self *FileSource) GetTxGeo(txHash ethereum.Hash) (string, string, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if err := self.reload(); err != nil {
+		return "", "", err
+	}
+	return self.ips[strings.ToLower(txHash.Hex())], "", nil
+}
@@ -0,0 +1,57 @@
+package geo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+func TestFileSource(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test_geo_file_source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Error(err)
+		}
+	}()
+	var (
+		path   = filepath.Join(tmpDir, "tx_ips.json")
+		first  = ethereum.HexToHash("0xAB")
+		second = ethereum.HexToHash("0xCD")
+	)
+	if err = ioutil.WriteFile(path, []byte(`{"`+first.Hex()+`": "81.2.69.142"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewFileSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip, _, _ := source.GetTxGeo(first); ip != "81.2.69.142" {
+		t.Errorf("expected IP of first tx, got %q", ip)
+	}
+	if ip, _, _ := source.GetTxGeo(second); ip != "" {
+		t.Errorf("expected no IP of second tx, got %q", ip)
+	}
+
+	// the modified file is loaded again
+	if err = ioutil.WriteFile(path, []byte(`{"`+second.Hex()+`": "14.177.12.126"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err = os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if ip, _, _ := source.GetTxGeo(second); ip != "14.177.12.126" {
+		t.Errorf("expected IP of second tx after reload, got %q", ip)
+	}
+
+	if _, err = NewFileSource(filepath.Join(tmpDir, "missing.json")); err == nil {
+		t.Error("expected error on missing file")
+	}
+}
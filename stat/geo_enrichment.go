@@ -0,0 +1,224 @@
+package stat
+
+import (
+	"log"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// geoEnrichmentInterval is the interval to enrich trade logs if no new
+	// trade log is stored.
+	geoEnrichmentInterval = 10 * time.Second
+	// geoEnrichmentBatchSize is the number of trade logs read from storage
+	// at once.
+	geoEnrichmentBatchSize = 100
+	// geoRetryDelay is the delay before the first retry of a trade log, it
+	// doubles after each failure.
+	geoRetryDelay = time.Minute
+	// geoMaxAttempts is the number of lookups of a trade log before its geo
+	// is left unknown.
+	geoMaxAttempts = 8
+)
+
+// TxGeoSource looks up the IP from which a transaction was broadcasted, and
+// its country if known. An empty IP means the transaction is unknown to the
+// source, a lookup is retried only on error.
+type TxGeoSource interface {
+	GetTxGeo(txHash ethereum.Hash) (ip string, country string, err error)
+}
+
+// SetGeoSource sets the source of trade logs geo, trade logs are enriched
+// asynchronously after they are stored and country stats are aggregated
+// once they are enriched.
+func (self *Fetcher) SetGeoSource(source TxGeoSource) {
+	self.geoSource = source
+}
+
+// notifyGeoEnrichment wakes the enrichment up after trade logs are stored.
+func (self *Fetcher) notifyGeoEnrichment() {
+	select {
+	case self.geoNotify <- struct{}{}:
+	default:
+	}
+}
+
+// RunGeoEnrichment enriches the stored trade logs when new trade logs are
+// stored, failed lookups are retried periodically.
+func (self *Fetcher) RunGeoEnrichment() {
+	if err := self.initGeoCursor(); err != nil {
+		log.Printf("GeoEnrichment - initializing cursor failed: %s", err)
+	}
+	for {
+		self.EnrichTradeLogs(common.GetTimepoint())
+		select {
+		case <-self.geoNotify:
+		case <-time.After(geoEnrichmentInterval):
+		}
+	}
+}
+
+// initGeoCursor starts the enrichment from the last trade log if it never
+// ran, the trade logs stored before were enriched when fetched.
+func (self *Fetcher) initGeoCursor() error {
+	_, ok, err := self.logStorage.GetGeoCursor()
+	if err != nil || ok {
+		return err
+	}
+	var last uint64
+	if l, lErr := self.logStorage.GetLastTradeLog(); lErr == nil {
+		last = l.Timestamp
+	}
+	return self.logStorage.SetGeoCursor(last)
+}
+
+// EnrichTradeLogs retries the failed trade logs due at timepoint (ms) then
+// enriches the trade logs after the cursor. New trade logs are queued for
+// retry on failure and the enrichment stops until next round, as the source
+// is likely down.
+func (self *Fetcher) EnrichTradeLogs(timepoint uint64) {
+	self.geoMu.Lock()
+	defer self.geoMu.Unlock()
+	retries, err := self.logStorage.GetGeoRetries()
+	if err != nil {
+		log.Printf("GeoEnrichment - getting retries failed: %s", err)
+		return
+	}
+	for logTime, retry := range retries {
+		if retry.NextRetry > timepoint {
+			continue
+		}
+		if err = self.enrichTradeLog(logTime, retry.TxHash); err == nil || retry.Attempts+1 >= geoMaxAttempts {
+			if err != nil {
+				log.Printf("GeoEnrichment - giving up trade log %s after %d attempts: %s", retry.TxHash.Hex(), retry.Attempts+1, err)
+			}
+			if err = self.logStorage.DeleteGeoRetry(logTime); err != nil {
+				log.Printf("GeoEnrichment - deleting retry failed: %s", err)
+			}
+			continue
+		}
+		log.Printf("GeoEnrichment - retrying trade log %s failed: %s", retry.TxHash.Hex(), err)
+		if err = self.queueGeoRetry(logTime, retry.TxHash, retry.Attempts+1, timepoint); err != nil {
+			log.Printf("GeoEnrichment - queuing retry failed: %s", err)
+		}
+	}
+
+	cursor, _, err := self.logStorage.GetGeoCursor()
+	if err != nil {
+		log.Printf("GeoEnrichment - getting cursor failed: %s", err)
+		return
+	}
+	for {
+		tradeLogs, lErr := self.logStorage.GetTradeLogsFrom(cursor+1, geoEnrichmentBatchSize)
+		if lErr != nil {
+			log.Printf("GeoEnrichment - getting trade logs failed: %s", lErr)
+			return
+		}
+		for _, l := range tradeLogs {
+			eErr := self.enrichTradeLog(l.Timestamp, l.TransactionHash)
+			if eErr != nil {
+				log.Printf("GeoEnrichment - enriching trade log %s failed: %s", l.TransactionHash.Hex(), eErr)
+				if err = self.queueGeoRetry(l.Timestamp, l.TransactionHash, 1, timepoint); err != nil {
+					log.Printf("GeoEnrichment - queuing retry failed: %s", err)
+					return
+				}
+			}
+			cursor = l.Timestamp
+			if err = self.logStorage.SetGeoCursor(cursor); err != nil {
+				log.Printf("GeoEnrichment - setting cursor failed: %s", err)
+				return
+			}
+			if eErr != nil {
+				return
+			}
+		}
+		if len(tradeLogs) < geoEnrichmentBatchSize {
+			return
+		}
+	}
+}
+
+func (self *Fetcher) queueGeoRetry(logTime uint64, txHash ethereum.Hash, attempts int, timepoint uint64) error {
+	delay := geoRetryDelay * time.Duration(1<<uint(attempts-1))
+	return self.logStorage.SetGeoRetry(logTime, common.GeoRetry{
+		TxHash:    txHash,
+		Attempts:  attempts,
+		NextRetry: timepoint + uint64(delay/time.Millisecond),
+	})
+}
+
+// enrichTradeLog looks up the geo of the trade log at logTime and stores it,
+// the country is located from the IP if the source doesn't know it.
+func (self *Fetcher) enrichTradeLog(logTime uint64, txHash ethereum.Hash) error {
+	ip, country, err := self.geoSource.GetTxGeo(txHash)
+	if err != nil {
+		return err
+	}
+	if ip == "" && country == "" {
+		return nil
+	}
+	if country == "" && self.ipLocator != nil {
+		if country, err = self.ipLocator.IPToCountry(ip); err != nil {
+			// the IP is invalid, retrying doesn't help
+			log.Printf("GeoEnrichment - locating IP %s of %s failed: %s", ip, txHash.Hex(), err)
+		}
+	}
+	return self.logStorage.UpdateTradeLogGeo(logTime, ip, country)
+}
+
+// geoEnrichedUntil returns the timepoint until which all trade logs are
+// enriched, ok is false if trade logs are not enriched.
+func (self *Fetcher) geoEnrichedUntil() (timepoint uint64, ok bool, err error) {
+	if self.geoSource == nil {
+		return 0, false, nil
+	}
+	cursor, _, err := self.logStorage.GetGeoCursor()
+	if err != nil {
+		return 0, true, err
+	}
+	retries, err := self.logStorage.GetGeoRetries()
+	if err != nil {
+		return 0, true, err
+	}
+	for logTime := range retries {
+		if logTime <= cursor {
+			cursor = logTime - 1
+		}
+	}
+	return cursor, true, nil
+}
+
+// rewindGeoEnrichment moves the cursor back to the last remaining trade log
+// after trade logs are rolled back and drops the retries of deleted ones.
+func (self *Fetcher) rewindGeoEnrichment() error {
+	self.geoMu.Lock()
+	defer self.geoMu.Unlock()
+	var lastRemaining uint64
+	if l, lErr := self.logStorage.GetLastTradeLog(); lErr == nil {
+		lastRemaining = l.Timestamp
+	}
+	cursor, ok, err := self.logStorage.GetGeoCursor()
+	if err != nil {
+		return err
+	}
+	if ok && cursor > lastRemaining {
+		if err = self.logStorage.SetGeoCursor(lastRemaining); err != nil {
+			return err
+		}
+	}
+	retries, err := self.logStorage.GetGeoRetries()
+	if err != nil {
+		return err
+	}
+	for logTime := range retries {
+		if logTime <= lastRemaining {
+			continue
+		}
+		if err = self.logStorage.DeleteGeoRetry(logTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
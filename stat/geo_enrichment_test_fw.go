@@ -0,0 +1,138 @@
+package stat
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// geoTestSource knows the geo of transactions in geos, it fails to look up
+// the transactions in failing once.
+type geoTestSource struct {
+	geos    map[ethereum.Hash][2]string
+	failing map[ethereum.Hash]bool
+}
+
+func (self *geoTestSource) GetTxGeo(txHash ethereum.Hash) (string, string, error) {
+	if self.failing[txHash] {
+		delete(self.failing, txHash)
+		return "", "", errors.New("source is down")
+	}
+	geo := self.geos[txHash]
+	return geo[0], geo[1], nil
+}
+
+type GeoEnrichmentTest struct {
+	fetcher *Fetcher
+}
+
+func NewGeoEnrichmentTest(statStorage StatStorage, logStorage LogStorage, userStorage UserStorage) *GeoEnrichmentTest {
+	fetcher := NewReaggregator(statStorage, logStorage, userStorage, reorgTestSetting{}).fetcher
+	fetcher.geoNotify = make(chan struct{}, 1)
+	return &GeoEnrichmentTest{fetcher}
+}
+
+func (self *GeoEnrichmentTest) expectGeo(timestamp uint64, ip, country string) error {
+	tradeLogs, err := self.fetcher.logStorage.GetTradeLogs(timestamp, timestamp)
+	if err != nil {
+		return err
+	}
+	if len(tradeLogs) != 1 {
+		return fmt.Errorf("expected trade log at %d, got %d logs", timestamp, len(tradeLogs))
+	}
+	if tradeLogs[0].IP != ip || tradeLogs[0].Country != country {
+		return fmt.Errorf("expected geo %q, %q of trade log at %d, got %q, %q",
+			ip, country, timestamp, tradeLogs[0].IP, tradeLogs[0].Country)
+	}
+	return nil
+}
+
+func (self *GeoEnrichmentTest) countryTradeCount(country string, fromTime, toTime uint64) (int, error) {
+	ticks, err := self.fetcher.statStorage.GetCountryStats(fromTime, toTime, country, 0)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, tick := range ticks {
+		stat, ok := tick.(common.MetricStats)
+		if !ok {
+			return 0, fmt.Errorf("unexpected country stat %+v", tick)
+		}
+		count += stat.TradeCount
+	}
+	return count, nil
+}
+
+// TestEnrichment enriches two trade logs, the lookup of the first one fails
+// once, and checks the country stats wait for it.
+func (self *GeoEnrichmentTest) TestEnrichment() error {
+	const day = uint64(24 * time.Hour)
+	var (
+		fetcher = self.fetcher
+		trades  = []common.TradeLog{
+			newReorgTestTrade(100, reorgTestTime, reorgTestUser1, 1),
+			newReorgTestTrade(101, reorgTestTime+uint64(time.Minute), reorgTestUser2, 2),
+		}
+		source = &geoTestSource{geos: map[ethereum.Hash][2]string{}, failing: map[ethereum.Hash]bool{}}
+	)
+	trades[0].TransactionHash = ethereum.HexToHash("0x01")
+	trades[1].TransactionHash = ethereum.HexToHash("0x02")
+	source.geos[trades[0].TransactionHash] = [2]string{"81.2.69.142", "GB"}
+	source.geos[trades[1].TransactionHash] = [2]string{"14.177.12.126", "VN"}
+	source.failing[trades[0].TransactionHash] = true
+	fetcher.SetGeoSource(source)
+	if err := fetcher.initGeoCursor(); err != nil {
+		return err
+	}
+	for _, trade := range trades {
+		if err := fetcher.logStorage.StoreTradeLog(trade, trade.Timestamp); err != nil {
+			return err
+		}
+	}
+
+	// the first lookup fails and is queued for retry
+	now := common.GetTimepoint()
+	fetcher.EnrichTradeLogs(now)
+	if err := self.expectGeo(trades[0].Timestamp, "", ""); err != nil {
+		return err
+	}
+	fetcher.EnrichTradeLogs(now)
+	if err := self.expectGeo(trades[1].Timestamp, "14.177.12.126", "VN"); err != nil {
+		return err
+	}
+	until, _, err := fetcher.geoEnrichedUntil()
+	if err != nil {
+		return err
+	}
+	if until != trades[0].Timestamp-1 {
+		return fmt.Errorf("expected trade logs enriched until before the failed one, got %d", until)
+	}
+	fetcher.RunCountryStatAggregation(time.Now())
+	last, err := fetcher.statStorage.GetLastProcessedTradeLogTimepoint(CountryAggregation)
+	if err != nil {
+		return err
+	}
+	if last >= trades[0].Timestamp {
+		return fmt.Errorf("expected country aggregation waiting for enrichment, last processed %d", last)
+	}
+
+	// the retry is due after the retry delay
+	fetcher.EnrichTradeLogs(now + uint64(geoRetryDelay/time.Millisecond))
+	if err = self.expectGeo(trades[0].Timestamp, "81.2.69.142", "GB"); err != nil {
+		return err
+	}
+	fetcher.RunCountryStatAggregation(time.Now())
+	for country, expected := range map[string]int{"GB": 1, "VN": 1} {
+		count, cErr := self.countryTradeCount(country, reorgTestTime-day, reorgTestTime+day)
+		if cErr != nil {
+			return cErr
+		}
+		if count != expected {
+			return fmt.Errorf("expected %d trades of %s, got %d", expected, country, count)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package stat
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const (
+	// logSinkRetryInterval is the interval to retry delivering logs to
+	// sinks if no new log is stored.
+	logSinkRetryInterval = 10 * time.Second
+	// logSinkBatchSize is the number of logs read from storage at once.
+	logSinkBatchSize = 100
+
+	tradeLogCursorSuffix = "/trade_log"
+	catLogCursorSuffix   = "/cat_log"
+)
+
+// LogSink receives the trade logs and cat logs stored by the fetcher, eg: to
+// stream them to other systems. Logs are delivered at least once in order of
+// their timestamps, a log is sent again until Send succeeds.
+type LogSink interface {
+	// Name identifies the sink, its delivery cursors are persisted by name.
+	Name() string
+	Send(l common.KNLog) error
+}
+
+// AddLogSink registers sink to receive the logs stored from now on, or from
+// its last delivered logs if it was registered before.
+func (self *Fetcher) AddLogSink(sink LogSink) error {
+	cursors, err := self.logStorage.GetSinkCursors()
+	if err != nil {
+		return err
+	}
+	for _, added := range self.logSinks {
+		if added.Name() == sink.Name() {
+			return fmt.Errorf("Log sink %s is already added", sink.Name())
+		}
+	}
+	if _, ok := cursors[sink.Name()+tradeLogCursorSuffix]; !ok {
+		var last uint64
+		if l, lErr := self.logStorage.GetLastTradeLog(); lErr == nil {
+			last = l.Timestamp
+		}
+		if err = self.logStorage.SetSinkCursor(sink.Name()+tradeLogCursorSuffix, last); err != nil {
+			return err
+		}
+	}
+	if _, ok := cursors[sink.Name()+catLogCursorSuffix]; !ok {
+		var last uint64
+		if l, lErr := self.logStorage.GetLastCatLog(); lErr == nil {
+			last = l.Timestamp
+		}
+		if err = self.logStorage.SetSinkCursor(sink.Name()+catLogCursorSuffix, last); err != nil {
+			return err
+		}
+	}
+	self.logSinks = append(self.logSinks, sink)
+	return nil
+}
+
+// notifyLogSinks wakes the delivery to log sinks up after logs are stored.
+func (self *Fetcher) notifyLogSinks() {
+	select {
+	case self.logSinkNotify <- struct{}{}:
+	default:
+	}
+}
+
+// RunLogSinks delivers the stored logs to sinks when new logs are stored,
+// undelivered logs are retried periodically.
+func (self *Fetcher) RunLogSinks() {
+	for {
+		select {
+		case <-self.logSinkNotify:
+		case <-time.After(logSinkRetryInterval):
+		}
+		self.DeliverLogs()
+	}
+}
+
+// DeliverLogs sends the logs after the cursors of each sink, a sink stops at
+// the first log it fails to receive.
+func (self *Fetcher) DeliverLogs() {
+	self.logSinkMu.Lock()
+	defer self.logSinkMu.Unlock()
+	for _, sink := range self.logSinks {
+		if err := self.deliverTradeLogs(sink); err != nil {
+			log.Printf("LogSink - delivering trade logs to %s failed: %s", sink.Name(), err)
+		}
+		if err := self.deliverCatLogs(sink); err != nil {
+			log.Printf("LogSink - delivering cat logs to %s failed: %s", sink.Name(), err)
+		}
+	}
+}
+
+func (self *Fetcher) deliverTradeLogs(sink LogSink) error {
+	name := sink.Name() + tradeLogCursorSuffix
+	cursors, err := self.logStorage.GetSinkCursors()
+	if err != nil {
+		return err
+	}
+	cursor := cursors[name]
+	for {
+		logs, lErr := self.logStorage.GetTradeLogsFrom(cursor+1, logSinkBatchSize)
+		if lErr != nil {
+			return lErr
+		}
+		for _, l := range logs {
+			if err = sink.Send(l); err != nil {
+				return err
+			}
+			cursor = l.Timestamp
+			if err = self.logStorage.SetSinkCursor(name, cursor); err != nil {
+				return err
+			}
+		}
+		if len(logs) < logSinkBatchSize {
+			return nil
+		}
+	}
+}
+
+func (self *Fetcher) deliverCatLogs(sink LogSink) error {
+	name := sink.Name() + catLogCursorSuffix
+	cursors, err := self.logStorage.GetSinkCursors()
+	if err != nil {
+		return err
+	}
+	cursor := cursors[name]
+	for {
+		logs, lErr := self.logStorage.GetCatLogsFrom(cursor+1, logSinkBatchSize)
+		if lErr != nil {
+			return lErr
+		}
+		for _, l := range logs {
+			if err = sink.Send(l); err != nil {
+				return err
+			}
+			cursor = l.Timestamp
+			if err = self.logStorage.SetSinkCursor(name, cursor); err != nil {
+				return err
+			}
+		}
+		if len(logs) < logSinkBatchSize {
+			return nil
+		}
+	}
+}
+
+// rewindLogSinks moves the cursors of sinks back to the last remaining logs
+// after logs are rolled back, so the logs stored instead are delivered.
+func (self *Fetcher) rewindLogSinks() error {
+	self.logSinkMu.Lock()
+	defer self.logSinkMu.Unlock()
+	cursors, err := self.logStorage.GetSinkCursors()
+	if err != nil {
+		return err
+	}
+	var lastTradeLog, lastCatLog uint64
+	if l, lErr := self.logStorage.GetLastTradeLog(); lErr == nil {
+		lastTradeLog = l.Timestamp
+	}
+	if l, lErr := self.logStorage.GetLastCatLog(); lErr == nil {
+		lastCatLog = l.Timestamp
+	}
+	for _, sink := range self.logSinks {
+		for name, last := range map[string]uint64{
+			sink.Name() + tradeLogCursorSuffix: lastTradeLog,
+			sink.Name() + catLogCursorSuffix:   lastCatLog,
+		} {
+			if cursors[name] <= last {
+				continue
+			}
+			if err = self.logStorage.SetSinkCursor(name, last); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package stat
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// logSinkTestSink records the timestamps of received logs, it fails to
+// receive the logs of timestamps in failing once.
+type logSinkTestSink struct {
+	name     string
+	failing  map[uint64]bool
+	received []uint64
+}
+
+func (self *logSinkTestSink) Name() string {
+	return self.name
+}
+
+func (self *logSinkTestSink) Send(l common.KNLog) error {
+	var timestamp uint64
+	switch l := l.(type) {
+	case common.TradeLog:
+		timestamp = l.Timestamp
+	case common.SetCatLog:
+		timestamp = l.Timestamp
+	}
+	if self.failing[timestamp] {
+		delete(self.failing, timestamp)
+		return errors.New("sink is down")
+	}
+	self.received = append(self.received, timestamp)
+	return nil
+}
+
+type LogSinkTest struct {
+	logStorage LogStorage
+}
+
+func NewLogSinkTest(logStorage LogStorage) *LogSinkTest {
+	return &LogSinkTest{logStorage}
+}
+
+func (self *LogSinkTest) newFetcher() *Fetcher {
+	return &Fetcher{
+		logStorage:    self.logStorage,
+		logSinkNotify: make(chan struct{}, 1),
+	}
+}
+
+func expectReceived(sink *logSinkTestSink, expected ...uint64) error {
+	if fmt.Sprint(sink.received) != fmt.Sprint(expected) {
+		return fmt.Errorf("expected %s to receive %v, got %v", sink.name, expected, sink.received)
+	}
+	return nil
+}
+
+// TestDelivery checks the logs stored after a sink is added are delivered
+// in order, the failed ones are sent again and the cursors survive restarts.
+func (self *LogSinkTest) TestDelivery() error {
+	if err := self.logStorage.StoreTradeLog(common.TradeLog{Timestamp: 1}, 1); err != nil {
+		return err
+	}
+	fetcher := self.newFetcher()
+	sink := &logSinkTestSink{name: "test", failing: map[uint64]bool{3: true}}
+	if err := fetcher.AddLogSink(sink); err != nil {
+		return err
+	}
+	if err := fetcher.AddLogSink(sink); err == nil {
+		return errors.New("expected error adding a sink twice")
+	}
+	for _, timestamp := range []uint64{2, 3, 4} {
+		if err := self.logStorage.StoreTradeLog(common.TradeLog{Timestamp: timestamp}, timestamp); err != nil {
+			return err
+		}
+	}
+	if err := self.logStorage.StoreCatLog(common.SetCatLog{Timestamp: 5, BlockNumber: 5}); err != nil {
+		return err
+	}
+
+	fetcher.DeliverLogs()
+	if err := expectReceived(sink, 2, 5); err != nil {
+		return err
+	}
+	fetcher.DeliverLogs()
+	if err := expectReceived(sink, 2, 5, 3, 4); err != nil {
+		return err
+	}
+
+	// a restarted fetcher continues from the persisted cursors
+	fetcher = self.newFetcher()
+	sink = &logSinkTestSink{name: "test"}
+	if err := fetcher.AddLogSink(sink); err != nil {
+		return err
+	}
+	if err := self.logStorage.StoreTradeLog(common.TradeLog{Timestamp: 6}, 6); err != nil {
+		return err
+	}
+	fetcher.DeliverLogs()
+	return expectReceived(sink, 6)
+}
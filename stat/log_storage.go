@@ -2,6 +2,7 @@ package stat
 
 import (
 	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 // LogStorage is the common interface of stat's logging database operations.
@@ -21,4 +22,43 @@ type LogStorage interface {
 	LastBlock() (uint64, error)
 	LoadLastTradeLogIndex() (block uint64, index uint, err error)
 	LoadLastCatLogIndex() (block uint64, index uint, err error)
+
+	// StoreBlockHash records the hash of a fetched block to detect chain
+	// reorganizations.
+	StoreBlockHash(block uint64, hash ethereum.Hash) error
+	// GetBlockHashes returns the recorded hashes of blocks from fromBlock.
+	GetBlockHashes(fromBlock uint64) (map[uint64]ethereum.Hash, error)
+	// DeleteLogsFromBlock deletes the trade logs, cat logs and block hashes
+	// from block and returns the deleted trade logs. The last fetched block
+	// is set to the block before and the deleted trade logs are added to the
+	// pending rollback atomically with the deletion.
+	DeleteLogsFromBlock(block uint64) ([]common.TradeLog, error)
+	// GetPendingRollback returns the trade logs deleted by
+	// DeleteLogsFromBlock of which the rollback is not done, ok is false if
+	// there is none.
+	GetPendingRollback() (tradeLogs []common.TradeLog, ok bool, err error)
+	// DonePendingRollback removes the pending rollback.
+	DonePendingRollback() error
+
+	// GetTradeLogsFrom returns at most limit trade logs from fromTime.
+	GetTradeLogsFrom(fromTime uint64, limit int) ([]common.TradeLog, error)
+	// GetCatLogsFrom returns at most limit cat logs from fromTime.
+	GetCatLogsFrom(fromTime uint64, limit int) ([]common.SetCatLog, error)
+	// GetSinkCursors returns the timepoints of the last logs delivered to
+	// log sinks by cursor name.
+	GetSinkCursors() (map[string]uint64, error)
+	SetSinkCursor(name string, timepoint uint64) error
+
+	// UpdateTradeLogGeo sets the IP and country of the trade log at
+	// timepoint.
+	UpdateTradeLogGeo(timepoint uint64, ip, country string) error
+	// GetGeoCursor returns the timepoint of the last trade log of which geo
+	// is enriched or queued for retry, ok is false if it was never set.
+	GetGeoCursor() (timepoint uint64, ok bool, err error)
+	SetGeoCursor(timepoint uint64) error
+	// GetGeoRetries returns the trade logs waiting for geo enrichment retry
+	// by timepoint.
+	GetGeoRetries() (map[uint64]common.GeoRetry, error)
+	SetGeoRetry(timepoint uint64, retry common.GeoRetry) error
+	DeleteGeoRetry(timepoint uint64) error
 }
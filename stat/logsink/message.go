@@ -0,0 +1,40 @@
+package logsink
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const (
+	tradeLogType = "trade_log"
+	catLogType   = "cat_log"
+)
+
+// Message is the body of a log sent to sinks.
+type Message struct {
+	Type string       `json:"type"`
+	Log  common.KNLog `json:"log"`
+}
+
+// logType returns the type of a log in messages and subjects.
+func logType(l common.KNLog) (string, error) {
+	switch l.(type) {
+	case common.TradeLog:
+		return tradeLogType, nil
+	case common.SetCatLog:
+		return catLogType, nil
+	default:
+		return "", fmt.Errorf("Unsupported log type %s", l.Type())
+	}
+}
+
+func newMessage(l common.KNLog) (string, []byte, error) {
+	lType, err := logType(l)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := json.Marshal(Message{Type: lType, Log: l})
+	return lType, data, err
+}
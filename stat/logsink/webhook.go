@@ -0,0 +1,80 @@
+package logsink
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// WebhookSink posts each log as a JSON Message to an URL. The body is signed
+// by HMAC-SHA512 with the secret, the hex encoded signature is sent in the
+// "signed" header, like the requests to core.
+type WebhookSink struct {
+	name   string
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookSink creates a WebhookSink posting to url, requests time out
+// after timeout.
+func NewWebhookSink(name, url, secret string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		name:   name,
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Sign returns the signature of body with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha512.New, []byte(secret))
+	// hash.Hash never returns an error on Write
+	_, _ = mac.Write(body)
+	return ethereum.Bytes2Hex(mac.Sum(nil))
+}
+
+func (self *WebhookSink) Name() string {
+	return self.name
+}
+
+// Send posts l, a log is delivered if the response status is 2xx.
+func (self *WebhookSink) Send(l common.KNLog) error {
+	_, body, err := newMessage(l)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, self.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("signed", Sign(self.secret, body))
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// drain the body so the connection is reused
+		if _, cErr := io.Copy(ioutil.Discard, resp.Body); cErr != nil {
+			log.Printf("Response body read error: %s", cErr.Error())
+		}
+		if cErr := resp.Body.Close(); cErr != nil {
+			log.Printf("Response body close error: %s", cErr.Error())
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook %s responded status %d", self.name, resp.StatusCode)
+	}
+	return nil
+}
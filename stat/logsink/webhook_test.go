@@ -0,0 +1,58 @@
+package logsink
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+func TestWebhookSink(t *testing.T) {
+	const secret = "webhook secret"
+	var (
+		fail     = true
+		received []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if signed := r.Header.Get("signed"); signed != Sign(secret, body) {
+			t.Errorf("invalid signature %s", signed)
+		}
+		if fail {
+			fail = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var msg struct {
+			Type string          `json:"type"`
+			Log  common.TradeLog `json:"log"`
+		}
+		if err = json.Unmarshal(body, &msg); err != nil {
+			t.Error(err)
+		}
+		received = append(received, msg.Type)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink("webhook", server.URL, secret, time.Second)
+	tradeLog := common.TradeLog{Timestamp: 1, BlockNumber: 10}
+	if err := sink.Send(tradeLog); err == nil {
+		t.Error("expected error on status 503")
+	}
+	if err := sink.Send(tradeLog); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(common.SetCatLog{Timestamp: 2, BlockNumber: 11}); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || received[0] != tradeLogType || received[1] != catLogType {
+		t.Errorf("unexpected received logs %v", received)
+	}
+}
@@ -0,0 +1,111 @@
+package stat
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// Reaggregator rebuilds the aggregated stats of a time range from the stored
+// trade logs, eg: after fixing how trades are aggregated or adding a token.
+type Reaggregator struct {
+	fetcher *Fetcher
+}
+
+// NewReaggregator creates a Reaggregator, setting is used to look up the
+// tokens of trades.
+func NewReaggregator(statStorage StatStorage, logStorage LogStorage, userStorage UserStorage, setting Setting) *Reaggregator {
+	return &Reaggregator{
+		fetcher: &Fetcher{
+			statStorage:   statStorage,
+			logStorage:    logStorage,
+			userStorage:   userStorage,
+			setting:       setting,
+			addressLookup: make(map[ethereum.Address]common.Token),
+		},
+	}
+}
+
+// Reaggregate deletes the stats of aggregations in the time buckets starting
+// from fromTime to before toTime (nanoseconds), then aggregates them again
+// from the trade logs. Only the trade logs already processed by the trade log
+// processor are aggregated, the following ones are left to it.
+func (self *Reaggregator) Reaggregate(aggregations []string, fromTime, toTime uint64) error {
+	allFirstTradeEver, err := self.fetcher.statStorage.GetAllFirstTradeEver()
+	if err != nil {
+		return err
+	}
+	kycEdUsers, err := self.fetcher.userStorage.GetKycUsers()
+	if err != nil {
+		return err
+	}
+	for _, aggregation := range aggregations {
+		if err = self.reaggregate(aggregation, fromTime, toTime, allFirstTradeEver, kycEdUsers); err != nil {
+			return fmt.Errorf("Re-aggregating %s failed: %s", aggregation, err)
+		}
+	}
+	return nil
+}
+
+// forEachTradeLogs calls f with the trade logs from fromTime to before toTime
+// by chunks of the maximum range of the log storage.
+func (self *Reaggregator) forEachTradeLogs(fromTime, toTime uint64, f func(tradeLogs []common.TradeLog, to uint64) error) error {
+	maxRange := self.fetcher.logStorage.MaxRange()
+	for from := fromTime; from < toTime; from += maxRange {
+		to := from + maxRange - 1
+		if to >= toTime {
+			to = toTime - 1
+		}
+		tradeLogs, err := self.fetcher.logStorage.GetTradeLogs(from, to)
+		if err != nil {
+			return err
+		}
+		if err = f(tradeLogs, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *Reaggregator) reaggregate(aggregation string, fromTime, toTime uint64,
+	allFirstTradeEver map[ethereum.Address]uint64, kycEdUsers map[string]uint64) error {
+	last, err := self.fetcher.statStorage.GetLastProcessedTradeLogTimepoint(aggregation)
+	if err != nil {
+		return err
+	}
+	// a time bucket spans at most a day, the ones starting before toTime
+	// contain trades until a day after it.
+	logsTo := toTime + uint64(24*time.Hour)
+	if logsTo > last+1 {
+		logsTo = last + 1
+	}
+	if fromTime >= logsTo {
+		log.Printf("STAT: re-aggregating %s: no processed trade logs from %d", aggregation, fromTime)
+		return nil
+	}
+
+	// the stats of keys not in the trade logs anymore are deleted too
+	log.Printf("STAT: re-aggregating %s: deleting stats from %d to %d", aggregation, fromTime, toTime)
+	if err = self.fetcher.statStorage.DeleteStats(aggregation, fromTime, toTime); err != nil {
+		return err
+	}
+
+	var count int
+	return self.forEachTradeLogs(fromTime, logsTo, func(tradeLogs []common.TradeLog, to uint64) error {
+		stats, aErr := self.fetcher.aggregateTrades(aggregation, tradeLogs, allFirstTradeEver, kycEdUsers)
+		if aErr != nil {
+			return aErr
+		}
+		stats.filter(fromTime, toTime)
+		if aErr = stats.store(self.fetcher.statStorage, last); aErr != nil {
+			return aErr
+		}
+		count += len(tradeLogs)
+		log.Printf("STAT: re-aggregating %s: aggregated %d trade logs until %d (%.0f%%)",
+			aggregation, count, to, float64(to-fromTime+1)*100/float64(logsTo-fromTime))
+		return nil
+	})
+}
@@ -0,0 +1,170 @@
+package stat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+const reorgTestReserve = "0x63825c174ab367968ec60f061753d3bbd36a0d80"
+
+type ReaggregateTest struct {
+	reaggregator *Reaggregator
+}
+
+func NewReaggregateTest(statStorage StatStorage, logStorage LogStorage, userStorage UserStorage) *ReaggregateTest {
+	return &ReaggregateTest{NewReaggregator(statStorage, logStorage, userStorage, reorgTestSetting{})}
+}
+
+func (self *ReaggregateTest) userVolume(user string, fromTime, toTime uint64) (float64, error) {
+	ticks, err := self.reaggregator.fetcher.statStorage.GetUserVolume(fromTime, toTime, "D", ethereum.HexToAddress(user))
+	if err != nil {
+		return 0, err
+	}
+	var volume float64
+	for _, tick := range ticks {
+		stat, ok := tick.(common.VolumeStats)
+		if !ok {
+			return 0, fmt.Errorf("unexpected user volume %+v", tick)
+		}
+		volume += stat.ETHVolume
+	}
+	return volume, nil
+}
+
+func (self *ReaggregateTest) tradeCount(fromTime, toTime uint64) (int, error) {
+	ticks, err := self.reaggregator.fetcher.statStorage.GetTradeSummary(fromTime, toTime, 0)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, tick := range ticks {
+		stat, ok := tick.(common.MetricStats)
+		if !ok {
+			return 0, fmt.Errorf("unexpected trade summary %+v", tick)
+		}
+		count += stat.TradeCount
+	}
+	return count, nil
+}
+
+// TestReaggregate aggregates trades of three days, corrupts the stats of the
+// second day then rebuilds them. The volume of a user without trades is
+// deleted while the burn fee sharing its sub buckets is kept.
+func (self *ReaggregateTest) TestReaggregate() error {
+	const day = uint64(24 * time.Hour)
+	var (
+		fetcher  = self.reaggregator.fetcher
+		firstDay = reorgTestTime / day * day
+		trades   = []common.TradeLog{
+			newReorgTestTrade(100, reorgTestTime, reorgTestUser1, 1),
+			newReorgTestTrade(200, reorgTestTime+day, reorgTestUser2, 2),
+			newReorgTestTrade(300, reorgTestTime+2*day, reorgTestUser1, 3),
+		}
+	)
+	for _, trade := range trades {
+		if err := fetcher.logStorage.StoreTradeLog(trade, trade.Timestamp); err != nil {
+			return err
+		}
+	}
+	// each round aggregates a day at most
+	for i := 0; i < len(trades); i++ {
+		t := time.Now()
+		for _, run := range []func(time.Time){
+			fetcher.RunBurnFeeAggregation,
+			fetcher.RunVolumeStatAggregation,
+			fetcher.RunTradeSummaryAggregation,
+			fetcher.RunWalletStatAggregation,
+			fetcher.RunCountryStatAggregation,
+			fetcher.RunUserInfoAggregation,
+		} {
+			run(t)
+		}
+	}
+
+	// stats of the second day are wrongly aggregated
+	volumeLast, err := fetcher.statStorage.GetLastProcessedTradeLogTimepoint(VolumeStatAggregation)
+	if err != nil {
+		return err
+	}
+	if volumeLast != trades[2].Timestamp {
+		return fmt.Errorf("expected all trades processed, last processed %d", volumeLast)
+	}
+	wrongVolume := map[string]common.VolumeStatsTimeZone{
+		common.AddrToString(ethereum.HexToAddress(reorgTestUser2)): {
+			"D": {firstDay + day: common.NewVolumeStats(5, 0, 5)},
+		},
+		common.AddrToString(ethereum.HexToAddress(reorgTestUser3)): {
+			"D": {firstDay + day: common.NewVolumeStats(4, 0, 4)},
+		},
+	}
+	if err = fetcher.statStorage.SetVolumeStat(wrongVolume, volumeLast); err != nil {
+		return err
+	}
+	burnFee := map[string]common.BurnFeeStatsTimeZone{
+		reorgTestReserve: {"D": {firstDay + day: common.NewBurnFeeStats(1)}},
+	}
+	burnFeeLast, err := fetcher.statStorage.GetLastProcessedTradeLogTimepoint(BurnfeeAggregation)
+	if err != nil {
+		return err
+	}
+	if err = fetcher.statStorage.SetBurnFeeStat(burnFee, burnFeeLast); err != nil {
+		return err
+	}
+	wrongSummary := map[string]common.MetricStatsTimeZone{
+		TradeSummaryKey: {0: {firstDay + day: common.MetricStats{TradeCount: 1}}},
+	}
+	if err = fetcher.statStorage.SetTradeSummary(wrongSummary, trades[2].Timestamp); err != nil {
+		return err
+	}
+	if volume, _ := self.userVolume(reorgTestUser2, firstDay, firstDay+3*day); volume != 7 {
+		return fmt.Errorf("expected wrong volume 7 of user 2, got %f", volume)
+	}
+
+	err = self.reaggregator.Reaggregate([]string{VolumeStatAggregation, TradeSummaryAggregation}, firstDay+day, firstDay+2*day)
+	if err != nil {
+		return err
+	}
+	for _, tc := range []struct {
+		user     string
+		day      uint64
+		expected float64
+	}{
+		{reorgTestUser1, firstDay, 1},
+		{reorgTestUser2, firstDay + day, 2},
+		{reorgTestUser1, firstDay + 2*day, 3},
+		{reorgTestUser3, firstDay + day, 0},
+	} {
+		volume, vErr := self.userVolume(tc.user, tc.day, tc.day+day-1)
+		if vErr != nil {
+			return vErr
+		}
+		if volume != tc.expected {
+			return fmt.Errorf("expected volume %f of %s on day %d, got %f", tc.expected, tc.user, tc.day, volume)
+		}
+	}
+	ticks, err := fetcher.statStorage.GetBurnFee(firstDay, firstDay+3*day, "D", ethereum.HexToAddress(reorgTestReserve))
+	if err != nil {
+		return err
+	}
+	if len(ticks) != 1 {
+		return fmt.Errorf("expected burn fee kept after re-aggregating volume, got %+v", ticks)
+	}
+	count, err := self.tradeCount(firstDay, firstDay+3*day)
+	if err != nil {
+		return err
+	}
+	if count != 3 {
+		return fmt.Errorf("expected 3 trades after re-aggregation, got %d", count)
+	}
+	last, err := fetcher.statStorage.GetLastProcessedTradeLogTimepoint(VolumeStatAggregation)
+	if err != nil {
+		return err
+	}
+	if last != volumeLast {
+		return fmt.Errorf("expected last processed timepoint %d kept, got %d", volumeLast, last)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package stat
+
+import (
+	"log"
+	"sort"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// reorgCheckDepth is the number of fetched blocks to look back for a chain
+// reorganization.
+const reorgCheckDepth uint64 = 200
+
+// storeBlockHash records the hash of a fetched block, it is used to detect
+// chain reorganizations.
+func (self *Fetcher) storeBlockHash(block uint64, hash ethereum.Hash) {
+	if hash == (ethereum.Hash{}) {
+		return
+	}
+	if err := self.logStorage.StoreBlockHash(block, hash); err != nil {
+		log.Printf("LogFetcher - storing hash of block %d failed: %s", block, err)
+	}
+}
+
+// checkReorg compares the recorded hashes of the fetched blocks with the ones
+// of the node, from lastBlock backward. It returns the first block to fetch
+// again, or 0 if the fetched blocks are still on the chain.
+func (self *Fetcher) checkReorg(lastBlock uint64) (uint64, error) {
+	var fromBlock uint64
+	if lastBlock > reorgCheckDepth {
+		fromBlock = lastBlock - reorgCheckDepth
+	}
+	hashes, err := self.logStorage.GetBlockHashes(fromBlock)
+	if err != nil {
+		return 0, err
+	}
+	var blocks []uint64
+	for block := range hashes {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] > blocks[j] })
+
+	var reorged bool
+	for _, block := range blocks {
+		hash, err := self.blockchain.GetBlockHash(block)
+		if err != nil {
+			return 0, err
+		}
+		if hash == hashes[block] {
+			if reorged {
+				// logs of the blocks after the last valid one may be
+				// changed, even they had none before.
+				return block + 1, nil
+			}
+			return 0, nil
+		}
+		reorged = true
+	}
+	if reorged {
+		// the reorganization is deeper than the recorded blocks
+		return fromBlock, nil
+	}
+	return 0, nil
+}
+
+// rollbackLogs deletes the logs fetched from fromBlock and reverts the
+// contributions of the deleted trades to the aggregated stats, so they are
+// fetched and aggregated again on the new chain. The deleted trade logs are
+// recorded as a pending rollback with the deletion, if reverting fails it is
+// resumed by resumeRollback.
+func (self *Fetcher) rollbackLogs(fromBlock uint64) error {
+	self.aggregateMu.Lock()
+	defer self.aggregateMu.Unlock()
+
+	tradeLogs, err := self.logStorage.DeleteLogsFromBlock(fromBlock)
+	if err != nil {
+		return err
+	}
+	log.Printf("LogFetcher - deleted %d trade logs from block %d", len(tradeLogs), fromBlock)
+	return self.completeRollback()
+}
+
+// resumeRollback completes the pending rollback if any, the logs must not be
+// fetched before. It returns true if there was a pending rollback.
+func (self *Fetcher) resumeRollback() (bool, error) {
+	self.aggregateMu.Lock()
+	defer self.aggregateMu.Unlock()
+	_, ok, err := self.logStorage.GetPendingRollback()
+	if err != nil || !ok {
+		return false, err
+	}
+	log.Printf("LogFetcher - resuming pending rollback")
+	return true, self.completeRollback()
+}
+
+// completeRollback reverts the pending rollback trade logs and removes it
+// once all steps succeed. Every step can be run again after a failure: the
+// cursors are only moved back, an aggregation is reverted with its last
+// processed timepoint so the deleted trades are not processed anymore once
+// it is done, and the first trades are removed last.
+func (self *Fetcher) completeRollback() error {
+	tradeLogs, ok, err := self.logStorage.GetPendingRollback()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	if err = self.rewindLogSinks(); err != nil {
+		return err
+	}
+	if err = self.rewindGeoEnrichment(); err != nil {
+		return err
+	}
+	// the trade logs after the last remaining one must be aggregated again
+	var lastRemaining uint64
+	if l, lErr := self.logStorage.GetLastTradeLog(); lErr == nil {
+		lastRemaining = l.Timestamp
+	}
+	allFirstTradeEver, err := self.statStorage.GetAllFirstTradeEver()
+	if err != nil {
+		return err
+	}
+	kycEdUsers, err := self.userStorage.GetKycUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, aggregation := range TradeLogAggregations {
+		last, err := self.statStorage.GetLastProcessedTradeLogTimepoint(aggregation)
+		if err != nil {
+			return err
+		}
+		var processed []common.TradeLog
+		for _, trade := range tradeLogs {
+			if trade.Timestamp <= last {
+				processed = append(processed, trade)
+			}
+		}
+		if last > lastRemaining {
+			last = lastRemaining
+		}
+		if len(processed) == 0 {
+			if err = self.statStorage.SetLastProcessedTradeLogTimepoint(aggregation, last); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = self.revertAggregation(aggregation, processed, last, allFirstTradeEver, kycEdUsers); err != nil {
+			return err
+		}
+	}
+	if err = self.statStorage.RemoveFirstTrades(tradeLogs); err != nil {
+		return err
+	}
+	return self.logStorage.DonePendingRollback()
+}
+
+// revertAggregation aggregates the processed trades the same way as they
+// were, and subtracts the result from the stats of aggregation.
+func (self *Fetcher) revertAggregation(aggregation string, tradeLogs []common.TradeLog, last uint64,
+	allFirstTradeEver map[ethereum.Address]uint64, kycEdUsers map[string]uint64) error {
+	stats, err := self.aggregateTrades(aggregation, tradeLogs, allFirstTradeEver, kycEdUsers)
+	if err != nil {
+		return err
+	}
+	stats.negate()
+	return stats.store(self.statStorage, last)
+}
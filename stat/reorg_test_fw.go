@@ -0,0 +1,304 @@
+package stat
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/settings"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	reorgTestTokenAddr string = "0xdd974D5C2e2928deA5F71b9825b8b646686BD200"
+	reorgTestUser1     string = "0x8180a5ca4e3b94045e05a9313777955f7518d757"
+	reorgTestUser2     string = "0x2262d4f6312805851e3b27c40db2c7282e6e4a42"
+	reorgTestUser3     string = "0x63825c174ab367968EC60f061753D3bbD36A0D8F"
+	reorgTestTime      uint64 = 1539248400000000000
+)
+
+// reorgTestChain is a fake blockchain of which blocks from reorgBlock are
+// replaced by other ones once reorged is set.
+type reorgTestChain struct {
+	currentBlock uint64
+	reorgBlock   uint64
+	reorged      bool
+	logs         []common.TradeLog
+	reorgedLogs  []common.TradeLog
+}
+
+func (self *reorgTestChain) CurrentBlock() (uint64, error) {
+	return self.currentBlock, nil
+}
+
+func (self *reorgTestChain) GetBlockHash(block uint64) (ethereum.Hash, error) {
+	if block > self.currentBlock {
+		return ethereum.Hash{}, fmt.Errorf("Block %d is not found", block)
+	}
+	if self.reorged && block >= self.reorgBlock {
+		return ethereum.BigToHash(big.NewInt(int64(block*10 + 1))), nil
+	}
+	return ethereum.BigToHash(big.NewInt(int64(block * 10))), nil
+}
+
+func (self *reorgTestChain) GetLogs(fromBlock uint64, toBlock uint64) ([]common.KNLog, error) {
+	logs := self.logs
+	if self.reorged {
+		logs = self.reorgedLogs
+	}
+	var result []common.KNLog
+	for _, l := range logs {
+		if l.BlockNumber >= fromBlock && l.BlockNumber <= toBlock {
+			l.BlockHash, _ = self.GetBlockHash(l.BlockNumber)
+			result = append(result, l)
+		}
+	}
+	return result, nil
+}
+
+func (self *reorgTestChain) GetReserveRates(atBlock, currentBlock uint64, reserveAddress ethereum.Address, tokens []common.Token) (common.ReserveRates, error) {
+	return common.ReserveRates{}, errors.New("not supported")
+}
+
+func (self *reorgTestChain) GetPricingMethod(inputData string) (*abi.Method, error) {
+	return nil, errors.New("not supported")
+}
+
+func (self *reorgTestChain) GetAddress(addressType settings.AddressName) (ethereum.Address, error) {
+	return ethereum.Address{}, errors.New("not supported")
+}
+
+func (self *reorgTestChain) GetAddresses(setType settings.AddressSetName) ([]ethereum.Address, error) {
+	return nil, errors.New("not supported")
+}
+
+// reorgTestSetting knows ETH and a token of 18 decimals.
+type reorgTestSetting struct{}
+
+func (self reorgTestSetting) GetInternalTokens() ([]common.Token, error) {
+	return nil, nil
+}
+
+func (self reorgTestSetting) GetActiveTokens() ([]common.Token, error) {
+	return nil, nil
+}
+
+func (self reorgTestSetting) GetTokenByAddress(addr ethereum.Address) (common.Token, error) {
+	switch addr {
+	case ethereum.HexToAddress(ethAddress):
+		return common.NewToken("ETH", "Ethereum", ethAddress, 18, true, true, 0), nil
+	case ethereum.HexToAddress(reorgTestTokenAddr):
+		return common.NewToken("KNC", "Kyber Network", reorgTestTokenAddr, 18, true, true, 0), nil
+	}
+	return common.Token{}, fmt.Errorf("Token %s is not found", addr.Hex())
+}
+
+func (self reorgTestSetting) GetActiveTokenByID(id string) (common.Token, error) {
+	return common.Token{}, errors.New("not supported")
+}
+
+func (self reorgTestSetting) ReadyToServe() error {
+	return nil
+}
+
+// reorgFailingStatStorage fails storing wallet stats while fail is set, the
+// wallet aggregation is reverted after the burn fee, volume and trade summary
+// ones.
+type reorgFailingStatStorage struct {
+	StatStorage
+	fail bool
+}
+
+func (self *reorgFailingStatStorage) SetWalletStat(stats map[string]common.MetricStatsTimeZone, lastProcessTimePoint uint64) error {
+	if self.fail {
+		return errors.New("injected failure")
+	}
+	return self.StatStorage.SetWalletStat(stats, lastProcessTimePoint)
+}
+
+type ReorgTest struct {
+	fetcher *Fetcher
+	chain   *reorgTestChain
+}
+
+func NewReorgTest(statStorage StatStorage, logStorage LogStorage, rateStorage RateStorage,
+	userStorage UserStorage, feeSetRateStorage FeeSetRateStorage) *ReorgTest {
+	fetcher := NewFetcher(statStorage, logStorage, rateStorage, userStorage, feeSetRateStorage,
+		nil, 90, 0, "", reorgTestSetting{}, nil)
+	return &ReorgTest{fetcher: fetcher}
+}
+
+// newReorgTestTrade returns a trade of ethAmount ETH to the token by user.
+func newReorgTestTrade(block uint64, timestamp uint64, user string, ethAmount int64) common.TradeLog {
+	amount := new(big.Int).Mul(big.NewInt(ethAmount), big.NewInt(1000000000000000000))
+	return common.TradeLog{
+		Timestamp:       timestamp,
+		BlockNumber:     block,
+		TransactionHash: ethereum.BigToHash(big.NewInt(int64(timestamp))),
+		UserAddress:     ethereum.HexToAddress(user),
+		SrcAddress:      ethereum.HexToAddress(ethAddress),
+		DestAddress:     ethereum.HexToAddress(reorgTestTokenAddr),
+		SrcAmount:       amount,
+		DestAmount:      new(big.Int).Mul(amount, big.NewInt(500)),
+		BurnFee:         big.NewInt(0),
+	}
+}
+
+func (self *ReorgTest) aggregate() {
+	self.fetcher.aggregateMu.Lock()
+	defer self.fetcher.aggregateMu.Unlock()
+	t := time.Now()
+	self.fetcher.RunBurnFeeAggregation(t)
+	self.fetcher.RunVolumeStatAggregation(t)
+	self.fetcher.RunTradeSummaryAggregation(t)
+	self.fetcher.RunWalletStatAggregation(t)
+	self.fetcher.RunCountryStatAggregation(t)
+	self.fetcher.RunUserInfoAggregation(t)
+}
+
+func (self *ReorgTest) checkUserVolume(user string, expected float64) error {
+	ticks, err := self.fetcher.statStorage.GetUserVolume(reorgTestTime-uint64(24*time.Hour), reorgTestTime+uint64(24*time.Hour), "D", ethereum.HexToAddress(user))
+	if err != nil {
+		return err
+	}
+	var volume float64
+	for _, tick := range ticks {
+		stat, ok := tick.(common.VolumeStats)
+		if !ok {
+			return fmt.Errorf("unexpected user volume %+v", tick)
+		}
+		volume += stat.ETHVolume
+	}
+	if volume != expected {
+		return fmt.Errorf("expected volume %f of user %s, got %f", expected, user, volume)
+	}
+	return nil
+}
+
+func (self *ReorgTest) checkTradeSummary(tradeCount int, ethVolume float64) error {
+	ticks, err := self.fetcher.statStorage.GetTradeSummary(reorgTestTime-uint64(24*time.Hour), reorgTestTime+uint64(24*time.Hour), 0)
+	if err != nil {
+		return err
+	}
+	var (
+		count  int
+		volume float64
+	)
+	for _, tick := range ticks {
+		stat, ok := tick.(common.MetricStats)
+		if !ok {
+			return fmt.Errorf("unexpected trade summary %+v", tick)
+		}
+		count += stat.TradeCount
+		volume += stat.ETHVolume
+	}
+	if count != tradeCount || volume != ethVolume {
+		return fmt.Errorf("expected %d trades of %f ETH, got %d trades of %f ETH", tradeCount, ethVolume, count, volume)
+	}
+	return nil
+}
+
+// TestReorg fetches and aggregates two trades, then the chain is reorganized
+// from block 104: the second trade is dropped and a third one is included.
+func (self *ReorgTest) TestReorg() error {
+	return self.testReorg(false)
+}
+
+// TestReorgRollbackFailure is TestReorg with reverting the wallet
+// aggregation failing on the first rollback, the stats reverted before must
+// not be reverted twice when the rollback is resumed.
+func (self *ReorgTest) TestReorgRollbackFailure() error {
+	return self.testReorg(true)
+}
+
+func (self *ReorgTest) testReorg(failRollback bool) error {
+	t1 := newReorgTestTrade(100, reorgTestTime, reorgTestUser1, 1)
+	t2 := newReorgTestTrade(105, reorgTestTime+600*uint64(time.Second), reorgTestUser2, 2)
+	t3 := newReorgTestTrade(106, reorgTestTime+700*uint64(time.Second), reorgTestUser3, 3)
+	self.chain = &reorgTestChain{
+		currentBlock: 120,
+		reorgBlock:   104,
+		logs:         []common.TradeLog{t1, t2},
+		reorgedLogs:  []common.TradeLog{t1, t3},
+	}
+	self.fetcher.SetBlockchain(self.chain)
+
+	self.fetcher.fetchNewLogs(common.GetTimepoint())
+	self.aggregate()
+	if err := self.checkTradeSummary(2, 3); err != nil {
+		return err
+	}
+	if err := self.checkUserVolume(reorgTestUser2, 2); err != nil {
+		return err
+	}
+
+	self.chain.reorged = true
+	reorgBlock, err := self.fetcher.checkReorg(113)
+	if err != nil {
+		return err
+	}
+	if reorgBlock != 101 {
+		return fmt.Errorf("expected reorganization detected from block 101, got %d", reorgBlock)
+	}
+	if failRollback {
+		failing := &reorgFailingStatStorage{StatStorage: self.fetcher.statStorage, fail: true}
+		self.fetcher.statStorage = failing
+		// the rollback fails after reverting some aggregations
+		self.fetcher.fetchNewLogs(common.GetTimepoint())
+		if _, ok, pErr := self.fetcher.logStorage.GetPendingRollback(); pErr != nil || !ok {
+			return fmt.Errorf("expected pending rollback after failure, got %v (%v)", ok, pErr)
+		}
+		// nothing is fetched until the rollback is completed
+		self.fetcher.fetchNewLogs(common.GetTimepoint())
+		self.aggregate()
+		if _, ok, pErr := self.fetcher.logStorage.GetPendingRollback(); pErr != nil || !ok {
+			return fmt.Errorf("expected pending rollback while failing, got %v (%v)", ok, pErr)
+		}
+		failing.fail = false
+	}
+	// rolls back, then fetches the new chain
+	self.fetcher.fetchNewLogs(common.GetTimepoint())
+	lastBlock, err := self.fetcher.logStorage.LastBlock()
+	if err != nil {
+		return err
+	}
+	if lastBlock != 100 {
+		return fmt.Errorf("expected last block 100 after rolling back, got %d", lastBlock)
+	}
+	if _, ok, pErr := self.fetcher.logStorage.GetPendingRollback(); pErr != nil || ok {
+		return fmt.Errorf("expected no pending rollback once completed, got %v (%v)", ok, pErr)
+	}
+	self.fetcher.fetchNewLogs(common.GetTimepoint())
+	self.aggregate()
+
+	if reorgBlock, err = self.fetcher.checkReorg(113); err != nil || reorgBlock != 0 {
+		return fmt.Errorf("expected no reorganization after fetching new chain, got block %d (%v)", reorgBlock, err)
+	}
+	logs, err := self.fetcher.logStorage.GetTradeLogs(reorgTestTime, reorgTestTime+uint64(time.Hour))
+	if err != nil {
+		return err
+	}
+	if len(logs) != 2 || logs[0].TransactionHash != t1.TransactionHash || logs[1].TransactionHash != t3.TransactionHash {
+		return fmt.Errorf("expected trade logs %s and %s, got %+v", t1.TransactionHash.Hex(), t3.TransactionHash.Hex(), logs)
+	}
+	if err = self.checkTradeSummary(2, 4); err != nil {
+		return err
+	}
+	if err = self.checkUserVolume(reorgTestUser2, 0); err != nil {
+		return err
+	}
+	if err = self.checkUserVolume(reorgTestUser3, 3); err != nil {
+		return err
+	}
+	firstTrades, err := self.fetcher.statStorage.GetAllFirstTradeEver()
+	if err != nil {
+		return err
+	}
+	if _, ok := firstTrades[ethereum.HexToAddress(reorgTestUser2)]; ok {
+		return fmt.Errorf("expected first trade of %s removed", reorgTestUser2)
+	}
+	return nil
+}
@@ -42,10 +42,17 @@ type StatStorage interface {
 	GetAllFirstTradeEver() (map[ethereum.Address]uint64, error)
 	SetFirstTradeInDay(tradeLogs *[]common.TradeLog) error
 	GetFirstTradeInDay(userAddr ethereum.Address, timepoint uint64, timezone int64) (uint64, error)
+	// RemoveFirstTrades removes the first trade ever and first trade in day
+	// records made by the given trades.
+	RemoveFirstTrades(tradeLogs []common.TradeLog) error
 
 	SetUserList(userInfos map[string]common.UserInfoTimezone, lastProcessedTimepoint uint64) error
 	GetUserList(fromTime, toTime uint64, timezone int64) (map[string]common.UserInfo, error)
 
 	SetTradeSummary(stats map[string]common.MetricStatsTimeZone, lastProcessedTimepoint uint64) error
 	GetTradeSummary(fromTime, toTime uint64, timezone int64) (common.StatTicks, error)
+
+	// DeleteStats deletes the stats of aggregation of all keys in the time
+	// buckets starting from fromTime to before toTime.
+	DeleteStats(aggregation string, fromTime, toTime uint64) error
 }
@@ -10,12 +10,22 @@ import (
 	"github.com/KyberNetwork/reserve-data/boltutil"
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/boltdb/bolt"
+	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 const (
-	maxGetLogPeriod uint64 = 86400000000000 //1 days in nanosecond
-	tradelogBucket  string = "logs"
-	catlogBucket    string = "cat_logs"
+	maxGetLogPeriod  uint64 = 86400000000000 //1 days in nanosecond
+	tradelogBucket   string = "logs"
+	catlogBucket     string = "cat_logs"
+	blockHashBucket  string = "block_hashes"
+	sinkCursorBucket string = "sink_cursors"
+	geoCursorBucket  string = "geo_cursor"
+	geoRetryBucket   string = "geo_retries"
+	geoCursorKey     string = "cursor"
+	logBlockBucket   string = "log_block"
+	logBlockKey      string = "last_block"
+	rollbackBucket   string = "pending_rollback"
+	rollbackKey      string = "trade_logs"
 )
 
 type BoltLogStorage struct {
@@ -39,6 +49,24 @@ func NewBoltLogStorage(path string) (*BoltLogStorage, error) {
 		if _, uErr := tx.CreateBucketIfNotExists([]byte(tradelogBucket)); uErr != nil {
 			return uErr
 		}
+		if _, uErr := tx.CreateBucketIfNotExists([]byte(blockHashBucket)); uErr != nil {
+			return uErr
+		}
+		if _, uErr := tx.CreateBucketIfNotExists([]byte(sinkCursorBucket)); uErr != nil {
+			return uErr
+		}
+		if _, uErr := tx.CreateBucketIfNotExists([]byte(geoCursorBucket)); uErr != nil {
+			return uErr
+		}
+		if _, uErr := tx.CreateBucketIfNotExists([]byte(geoRetryBucket)); uErr != nil {
+			return uErr
+		}
+		if _, uErr := tx.CreateBucketIfNotExists([]byte(logBlockBucket)); uErr != nil {
+			return uErr
+		}
+		if _, uErr := tx.CreateBucketIfNotExists([]byte(rollbackBucket)); uErr != nil {
+			return uErr
+		}
 		_, uErr := tx.CreateBucketIfNotExists([]byte(catlogBucket))
 		return uErr
 	})
@@ -49,6 +77,12 @@ func NewBoltLogStorage(path string) (*BoltLogStorage, error) {
 
 	storage := &BoltLogStorage{sync.RWMutex{}, db, 0}
 	err = storage.db.View(func(tx *bolt.Tx) error {
+		// the last block is stored since it is rolled back on reorganizations,
+		// it is loaded from the last logs for the databases before
+		if v := tx.Bucket([]byte(logBlockBucket)).Get([]byte(logBlockKey)); v != nil {
+			storage.block = boltutil.BytesToUint64(v)
+			return nil
+		}
 		block, vErr := storage.LoadLastLogIndex(tx)
 		if vErr != nil {
 			return vErr
@@ -202,9 +236,20 @@ func (self *BoltLogStorage) StoreTradeLog(stat common.TradeLog, timepoint uint64
 	return err
 }
 
+// putLogBlock stores block as the last fetched block in tx.
+func putLogBlock(tx *bolt.Tx, block uint64) error {
+	return tx.Bucket([]byte(logBlockBucket)).Put([]byte(logBlockKey), boltutil.Uint64ToBytes(block))
+}
+
 func (self *BoltLogStorage) UpdateLogBlock(block uint64, timepoint uint64) error {
 	self.mu.Lock()
 	defer self.mu.Unlock()
+	err := self.db.Update(func(tx *bolt.Tx) error {
+		return putLogBlock(tx, block)
+	})
+	if err != nil {
+		return err
+	}
 	self.block = block
 	return nil
 }
@@ -334,3 +379,267 @@ func (self *BoltLogStorage) LastBlock() (uint64, error) {
 	defer self.mu.RUnlock()
 	return self.block, nil
 }
+
+// StoreBlockHash records the hash of a fetched block.
+func (self *BoltLogStorage) StoreBlockHash(block uint64, hash ethereum.Hash) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blockHashBucket))
+		return b.Put(boltutil.Uint64ToBytes(block), hash.Bytes())
+	})
+}
+
+// GetBlockHashes returns the recorded hashes of blocks from fromBlock.
+func (self *BoltLogStorage) GetBlockHashes(fromBlock uint64) (map[uint64]ethereum.Hash, error) {
+	result := map[uint64]ethereum.Hash{}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blockHashBucket))
+		c := b.Cursor()
+		for k, v := c.Seek(boltutil.Uint64ToBytes(fromBlock)); k != nil; k, v = c.Next() {
+			result[boltutil.BytesToUint64(k)] = ethereum.BytesToHash(v)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// deleteFromBlock deletes the logs of bucket from block, logs are keyed by
+// timestamp so the bucket is scanned backward until a log of an older block
+// is found. The deleted logs are passed to onDelete.
+func deleteFromBlock(b *bolt.Bucket, block uint64, onDelete func(v []byte) error) error {
+	var keys [][]byte
+	c := b.Cursor()
+	for k, v := c.Last(); k != nil; k, v = c.Prev() {
+		record := struct{ BlockNumber uint64 }{}
+		if err := json.Unmarshal(v, &record); err != nil {
+			return err
+		}
+		if record.BlockNumber < block {
+			break
+		}
+		if err := onDelete(v); err != nil {
+			return err
+		}
+		keys = append(keys, append([]byte{}, k...))
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getPendingRollback returns the trade logs of the pending rollback in tx.
+func getPendingRollback(tx *bolt.Tx) ([]common.TradeLog, bool, error) {
+	v := tx.Bucket([]byte(rollbackBucket)).Get([]byte(rollbackKey))
+	if v == nil {
+		return nil, false, nil
+	}
+	var result []common.TradeLog
+	if err := json.Unmarshal(v, &result); err != nil {
+		return nil, false, err
+	}
+	return result, true, nil
+}
+
+// DeleteLogsFromBlock deletes the trade logs, cat logs and block hashes from
+// block and returns the deleted trade logs. The last fetched block is set to
+// the block before and the deleted trade logs are added to the pending
+// rollback in the same transaction.
+func (self *BoltLogStorage) DeleteLogsFromBlock(block uint64) ([]common.TradeLog, error) {
+	var result []common.TradeLog
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	lastBlock := self.block
+	if block > 0 && lastBlock >= block {
+		lastBlock = block - 1
+	}
+	err := self.db.Update(func(tx *bolt.Tx) error {
+		err := deleteFromBlock(tx.Bucket([]byte(tradelogBucket)), block, func(v []byte) error {
+			record := common.TradeLog{}
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			result = append(result, record)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = deleteFromBlock(tx.Bucket([]byte(catlogBucket)), block, func(v []byte) error { return nil })
+		if err != nil {
+			return err
+		}
+		c := tx.Bucket([]byte(blockHashBucket)).Cursor()
+		for k, _ := c.Seek(boltutil.Uint64ToBytes(block)); k != nil; k, _ = c.Seek(boltutil.Uint64ToBytes(block)) {
+			if err = c.Delete(); err != nil {
+				return err
+			}
+		}
+		pending, _, err := getPendingRollback(tx)
+		if err != nil {
+			return err
+		}
+		dataJSON, err := json.Marshal(append(pending, result...))
+		if err != nil {
+			return err
+		}
+		if err = tx.Bucket([]byte(rollbackBucket)).Put([]byte(rollbackKey), dataJSON); err != nil {
+			return err
+		}
+		return putLogBlock(tx, lastBlock)
+	})
+	if err != nil {
+		return nil, err
+	}
+	self.block = lastBlock
+	return result, nil
+}
+
+// GetPendingRollback returns the trade logs deleted by DeleteLogsFromBlock
+// of which the rollback is not done, ok is false if there is none.
+func (self *BoltLogStorage) GetPendingRollback() (tradeLogs []common.TradeLog, ok bool, err error) {
+	err = self.db.View(func(tx *bolt.Tx) error {
+		var gErr error
+		tradeLogs, ok, gErr = getPendingRollback(tx)
+		return gErr
+	})
+	return tradeLogs, ok, err
+}
+
+// DonePendingRollback removes the pending rollback.
+func (self *BoltLogStorage) DonePendingRollback() error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(rollbackBucket)).Delete([]byte(rollbackKey))
+	})
+}
+
+// GetTradeLogsFrom returns at most limit trade logs from fromTime.
+func (self *BoltLogStorage) GetTradeLogsFrom(fromTime uint64, limit int) ([]common.TradeLog, error) {
+	var result []common.TradeLog
+	err := self.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(tradelogBucket)).Cursor()
+		for k, v := c.Seek(boltutil.Uint64ToBytes(fromTime)); k != nil && len(result) < limit; k, v = c.Next() {
+			record := common.TradeLog{}
+			if vErr := json.Unmarshal(v, &record); vErr != nil {
+				return vErr
+			}
+			result = append(result, record)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// GetCatLogsFrom returns at most limit cat logs from fromTime.
+func (self *BoltLogStorage) GetCatLogsFrom(fromTime uint64, limit int) ([]common.SetCatLog, error) {
+	var result []common.SetCatLog
+	err := self.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(catlogBucket)).Cursor()
+		for k, v := c.Seek(boltutil.Uint64ToBytes(fromTime)); k != nil && len(result) < limit; k, v = c.Next() {
+			record := common.SetCatLog{}
+			if vErr := json.Unmarshal(v, &record); vErr != nil {
+				return vErr
+			}
+			result = append(result, record)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// GetSinkCursors returns the timepoints of the last logs delivered to log
+// sinks by cursor name.
+func (self *BoltLogStorage) GetSinkCursors() (map[string]uint64, error) {
+	result := map[string]uint64{}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(sinkCursorBucket)).ForEach(func(k, v []byte) error {
+			result[string(k)] = boltutil.BytesToUint64(v)
+			return nil
+		})
+	})
+	return result, err
+}
+
+// SetSinkCursor stores the timepoint of the last log delivered by cursor name.
+func (self *BoltLogStorage) SetSinkCursor(name string, timepoint uint64) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(sinkCursorBucket)).Put([]byte(name), boltutil.Uint64ToBytes(timepoint))
+	})
+}
+
+// UpdateTradeLogGeo sets the IP and country of the trade log at timepoint.
+func (self *BoltLogStorage) UpdateTradeLogGeo(timepoint uint64, ip, country string) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(tradelogBucket))
+		key := boltutil.Uint64ToBytes(timepoint)
+		v := b.Get(key)
+		if v == nil {
+			return fmt.Errorf("Trade log at %d is not found", timepoint)
+		}
+		record := common.TradeLog{}
+		if err := json.Unmarshal(v, &record); err != nil {
+			return err
+		}
+		record.IP = ip
+		record.Country = country
+		dataJSON, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, dataJSON)
+	})
+}
+
+// GetGeoCursor returns the timepoint of the last trade log of which geo is
+// enriched or queued for retry, ok is false if it was never set.
+func (self *BoltLogStorage) GetGeoCursor() (timepoint uint64, ok bool, err error) {
+	err = self.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(geoCursorBucket)).Get([]byte(geoCursorKey))
+		if v != nil {
+			timepoint = boltutil.BytesToUint64(v)
+			ok = true
+		}
+		return nil
+	})
+	return timepoint, ok, err
+}
+
+func (self *BoltLogStorage) SetGeoCursor(timepoint uint64) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(geoCursorBucket)).Put([]byte(geoCursorKey), boltutil.Uint64ToBytes(timepoint))
+	})
+}
+
+// GetGeoRetries returns the trade logs waiting for geo enrichment retry by
+// timepoint.
+func (self *BoltLogStorage) GetGeoRetries() (map[uint64]common.GeoRetry, error) {
+	result := map[uint64]common.GeoRetry{}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(geoRetryBucket)).ForEach(func(k, v []byte) error {
+			var retry common.GeoRetry
+			if vErr := json.Unmarshal(v, &retry); vErr != nil {
+				return vErr
+			}
+			result[boltutil.BytesToUint64(k)] = retry
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (self *BoltLogStorage) SetGeoRetry(timepoint uint64, retry common.GeoRetry) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		dataJSON, err := json.Marshal(retry)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(geoRetryBucket)).Put(boltutil.Uint64ToBytes(timepoint), dataJSON)
+	})
+}
+
+func (self *BoltLogStorage) DeleteGeoRetry(timepoint uint64) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(geoRetryBucket)).Delete(boltutil.Uint64ToBytes(timepoint))
+	})
+}
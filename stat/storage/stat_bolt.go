@@ -293,6 +293,10 @@ func addMetricData(currentData, stat common.MetricStats) common.MetricStats {
 	if currentData.TradeCount > 0 {
 		currentData.ETHPerTrade = currentData.ETHVolume / float64(currentData.TradeCount)
 		currentData.USDPerTrade = currentData.USDVolume / float64(currentData.TradeCount)
+	} else {
+		// all trades are reverted
+		currentData.ETHPerTrade = 0
+		currentData.USDPerTrade = 0
 	}
 	return currentData
 }
@@ -622,6 +626,47 @@ func (self *BoltStatStorage) SetFirstTradeInDay(tradeLogs *[]common.TradeLog) er
 	return err
 }
 
+// RemoveFirstTrades removes the first trade ever and first trade in day
+// records made by the given trades, it is used when the trades are reverted
+// by a chain reorganization.
+func (self *BoltStatStorage) RemoveFirstTrades(tradeLogs []common.TradeLog) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		everBk := tx.Bucket([]byte(userFirstTradeEver))
+		if everBk == nil {
+			return fmt.Errorf("cannot find bucket %s", userFirstTradeEver)
+		}
+		userStatBk := tx.Bucket([]byte(userStatBucket))
+		if userStatBk == nil {
+			return fmt.Errorf("cannot find bucket %s", userStatBucket)
+		}
+		for _, trade := range tradeLogs {
+			userAddr := []byte(common.AddrToString(trade.UserAddress))
+			if v := everBk.Get(userAddr); v != nil && boltutil.BytesToUint64(v) == trade.Timestamp {
+				if err := everBk.Delete(userAddr); err != nil {
+					return err
+				}
+			}
+			for timezone := stat.StartTimezone; timezone <= stat.EndTimezone; timezone++ {
+				timezoneBk := userStatBk.Bucket(boltutil.Uint64ToBytes(uint64(timezone)))
+				if timezoneBk == nil {
+					continue
+				}
+				freq := fmt.Sprintf("%s%d", stat.TimezoneBucketPrefix, timezone)
+				userDailyBucket := timezoneBk.Bucket(getTimestampByFreq(trade.Timestamp, freq))
+				if userDailyBucket == nil {
+					continue
+				}
+				if v := userDailyBucket.Get(userAddr); v != nil && boltutil.BytesToUint64(v) == trade.Timestamp {
+					if err := userDailyBucket.Delete(userAddr); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
 func (self *BoltStatStorage) SetUserList(userInfos map[string]common.UserInfoTimezone, lastProcessTimePoint uint64) error {
 	err := self.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(userListBucket))
@@ -1040,3 +1085,103 @@ func (self *BoltStatStorage) GetTradeSummary(fromTime uint64, toTime uint64, tim
 
 	return result, err
 }
+
+// deleteTimeRange deletes the records of b with the timestamp keys from
+// fromTime to before toTime, nested buckets included.
+func deleteTimeRange(b *bolt.Bucket, fromTime, toTime uint64) error {
+	var keys, buckets [][]byte
+	c := b.Cursor()
+	max := boltutil.Uint64ToBytes(toTime)
+	for k, v := c.Seek(boltutil.Uint64ToBytes(fromTime)); k != nil && bytes.Compare(k, max) < 0; k, v = c.Next() {
+		if v == nil {
+			buckets = append(buckets, k)
+		} else {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	for _, k := range buckets {
+		if err := b.DeleteBucket(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isStatBucketOf returns true if the top level bucket b named name holds the
+// stats of aggregation. A bucket might hold the stats of several aggregations,
+// eg: an address is both a wallet and a user. Volume and burn fee stats share
+// the key formats and sub buckets, they are told apart by their records.
+func isStatBucketOf(aggregation string, name []byte, b *bolt.Bucket) bool {
+	switch string(name) {
+	case tradeLogProcessorState, walletAddressBucket, reserveRates, countryBucket, userFirstTradeEver, userStatBucket:
+		return false
+	case userListBucket:
+		return aggregation == stat.UserInfoAggregation
+	case stat.TradeSummaryKey:
+		return aggregation == stat.TradeSummaryAggregation
+	}
+	switch aggregation {
+	case stat.WalletAggregation, stat.CountryAggregation:
+		if b.Bucket([]byte(fmt.Sprintf("%s%d", stat.TimezoneBucketPrefix, stat.StartTimezone))) == nil {
+			return false
+		}
+		return ethereum.IsHexAddress(string(name)) == (aggregation == stat.WalletAggregation)
+	case stat.BurnfeeAggregation, stat.VolumeStatAggregation:
+		for _, freq := range []string{minuteBucket, hourBucket, dayBucket} {
+			freqBk := b.Bucket([]byte(freq))
+			if freqBk == nil {
+				continue
+			}
+			if _, v := freqBk.Cursor().First(); v != nil {
+				isBurnFee := bytes.Contains(v, []byte(`"TotalBurnFee"`))
+				return isBurnFee == (aggregation == stat.BurnfeeAggregation)
+			}
+		}
+	}
+	return false
+}
+
+// DeleteStats deletes the stats of aggregation of all keys in the time buckets
+// starting from fromTime to before toTime.
+func (self *BoltStatStorage) DeleteStats(aggregation string, fromTime, toTime uint64) error {
+	var subBuckets []string
+	switch aggregation {
+	case stat.BurnfeeAggregation, stat.VolumeStatAggregation:
+		subBuckets = []string{minuteBucket, hourBucket, dayBucket}
+	case stat.WalletAggregation, stat.CountryAggregation, stat.TradeSummaryAggregation, stat.UserInfoAggregation:
+		for i := stat.StartTimezone; i <= stat.EndTimezone; i++ {
+			subBuckets = append(subBuckets, fmt.Sprintf("%s%d", stat.TimezoneBucketPrefix, i))
+		}
+	default:
+		return fmt.Errorf("Unknown aggregation %s", aggregation)
+	}
+	return self.db.Update(func(tx *bolt.Tx) error {
+		var buckets []*bolt.Bucket
+		err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if isStatBucketOf(aggregation, name, b) {
+				buckets = append(buckets, b)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, b := range buckets {
+			for _, subBucket := range subBuckets {
+				freqBk := b.Bucket([]byte(subBucket))
+				if freqBk == nil {
+					continue
+				}
+				if err = deleteTimeRange(freqBk, fromTime, toTime); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,77 @@
+package watchdog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
+	"github.com/KyberNetwork/reserve-data/stat/logsink"
+)
+
+// Alerter sends the alerts of the watchdog.
+type Alerter interface {
+	Name() string
+	Alert(alert Alert) error
+}
+
+// LogAlerter writes the alerts to the log.
+type LogAlerter struct{}
+
+func (self LogAlerter) Name() string {
+	return "log"
+}
+
+func (self LogAlerter) Alert(alert Alert) error {
+	log.Printf("WATCHDOG: %s %s alert of %q: %s", alert.Level, alert.Type, alert.Token, alert.Message)
+	return nil
+}
+
+// WebhookAlerter posts each alert as JSON to an URL. The body is signed like
+// the logs posted by logsink.WebhookSink.
+type WebhookAlerter struct {
+	name   string
+	url    string
+	secret string
+	client *httpclient.Client
+}
+
+// NewWebhookAlerter creates a WebhookAlerter posting to url, requests time
+// out after timeout.
+func NewWebhookAlerter(name, url, secret string, timeout time.Duration) *WebhookAlerter {
+	return &WebhookAlerter{
+		name:   name,
+		url:    url,
+		secret: secret,
+		client: httpclient.NewClient("watchdog", httpclient.NoRetryConfig(timeout)),
+	}
+}
+
+func (self *WebhookAlerter) Name() string {
+	return self.name
+}
+
+// Alert posts alert, it is delivered if the response status is 2xx.
+func (self *WebhookAlerter) Alert(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, self.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("signed", logsink.Sign(self.secret, body))
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook %s responded status %d", self.name, resp.StatusCode)
+	}
+	return nil
+}
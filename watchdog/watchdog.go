@@ -0,0 +1,299 @@
+package watchdog
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// Levels of alerts.
+const (
+	LevelWarning  = "warning"
+	LevelCritical = "critical"
+)
+
+// Types of alerts.
+const (
+	// TypeRateExpiry is raised when the rates of a token are about to expire
+	// or expired.
+	TypeRateExpiry = "rate_expiry"
+	// TypeSetRateStalled is raised when no set rates is mined recently.
+	TypeSetRateStalled = "set_rate_stalled"
+	// TypeError is raised when the watchdog couldn't check.
+	TypeError = "error"
+)
+
+// Blockchain reads the rates of tokens in pricing contract.
+type Blockchain interface {
+	CurrentBlock() (uint64, error)
+	ValidRateDurationInBlocks() (uint64, error)
+	GetRateUpdateBlock(token ethereum.Address) (uint64, error)
+}
+
+// ActivityStorage is the storage of the set rates activities.
+type ActivityStorage interface {
+	GetAllRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error)
+}
+
+// Setting provides the tokens to watch.
+type Setting interface {
+	GetInternalTokens() ([]common.Token, error)
+}
+
+// Config is the configuration of a Watchdog.
+type Config struct {
+	// AlertBlocks is the number of blocks before the rates of a token expire
+	// to raise a warning.
+	AlertBlocks uint64
+	// MaxSetRateAge is the longest time since the last mined set rates
+	// activity before raising a warning, at most a day.
+	MaxSetRateAge time.Duration
+}
+
+// Alert is raised when the rates of a token are about to expire or expired,
+// or set rates is stalled.
+type Alert struct {
+	Type  string `json:"type"`
+	Level string `json:"level"`
+	// Token is empty for the alerts not of a token.
+	Token     string `json:"token,omitempty"`
+	Message   string `json:"message"`
+	Timestamp uint64 `json:"timestamp"`
+}
+
+func (self Alert) key() string {
+	return self.Type + "|" + self.Token + "|" + self.Level
+}
+
+// TokenStatus is the validity of the rates of a token in pricing contract.
+type TokenStatus struct {
+	Token           string `json:"token"`
+	RateUpdateBlock uint64 `json:"rate_update_block"`
+	ExpiryBlock     uint64 `json:"expiry_block"`
+	// BlocksLeft is negative when the rates expired.
+	BlocksLeft int64  `json:"blocks_left"`
+	Expired    bool   `json:"expired"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SetRateStatus is the last mined set rates activity.
+type SetRateStatus struct {
+	ID common.ActivityID `json:"id"`
+	// Timestamp is the time of the activity in milliseconds.
+	Timestamp uint64 `json:"timestamp"`
+}
+
+// Status is the result of a check.
+type Status struct {
+	Timestamp         uint64         `json:"timestamp"`
+	CurrentBlock      uint64         `json:"current_block"`
+	ValidRateDuration uint64         `json:"valid_rate_duration"`
+	LastSetRate       *SetRateStatus `json:"last_set_rate"`
+	Tokens            []TokenStatus  `json:"tokens"`
+	Alerts            []Alert        `json:"alerts"`
+}
+
+// Watchdog periodically checks the validity of the rates set in pricing
+// contract and the last mined set rates activity, the alerts are sent to its
+// alerters. An alert is sent once until it is resolved or its level changes.
+type Watchdog struct {
+	blockchain Blockchain
+	storage    ActivityStorage
+	setting    Setting
+	alerters   []Alerter
+	runner     Runner
+	config     Config
+
+	mu          sync.RWMutex
+	last        *Status
+	lastSetRate *SetRateStatus
+	// active are the alerts sent and not resolved yet by their keys.
+	active map[string]Alert
+}
+
+// NewWatchdog creates a new Watchdog checking on every tick of runner.
+func NewWatchdog(blockchain Blockchain, storage ActivityStorage, setting Setting,
+	alerters []Alerter, runner Runner, config Config) (*Watchdog, error) {
+	if config.MaxSetRateAge <= 0 || config.MaxSetRateAge > 24*time.Hour {
+		return nil, fmt.Errorf("Max set rate age %s must be positive and at most 24h", config.MaxSetRateAge)
+	}
+	return &Watchdog{
+		blockchain: blockchain,
+		storage:    storage,
+		setting:    setting,
+		alerters:   alerters,
+		runner:     runner,
+		config:     config,
+		active:     make(map[string]Alert),
+	}, nil
+}
+
+// updateLastSetRate updates the last mined set rates activity from the
+// activities since MaxSetRateAge before timepoint.
+func (self *Watchdog) updateLastSetRate(timepoint uint64) error {
+	toTime := timepoint * uint64(time.Millisecond)
+	records, err := self.storage.GetAllRecords(toTime-uint64(self.config.MaxSetRateAge), toTime)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.Action != common.ActionSetrate || record.MiningStatus != common.MiningStatusMined {
+			continue
+		}
+		self.mu.Lock()
+		if self.lastSetRate == nil || self.lastSetRate.ID.Timepoint < record.ID.Timepoint {
+			self.lastSetRate = &SetRateStatus{
+				ID:        record.ID,
+				Timestamp: record.ID.Timepoint / uint64(time.Millisecond),
+			}
+		}
+		self.mu.Unlock()
+	}
+	return nil
+}
+
+// Check checks the rates of internal tokens at timepoint, returns the status
+// and sends the new alerts.
+func (self *Watchdog) Check(timepoint uint64) (Status, error) {
+	status := Status{Timestamp: timepoint, Tokens: []TokenStatus{}, Alerts: []Alert{}}
+	alert := func(alertType, level, token, format string, args ...interface{}) {
+		status.Alerts = append(status.Alerts, Alert{
+			Type:      alertType,
+			Level:     level,
+			Token:     token,
+			Message:   fmt.Sprintf(format, args...),
+			Timestamp: timepoint,
+		})
+	}
+
+	if err := self.updateLastSetRate(timepoint); err != nil {
+		alert(TypeError, LevelWarning, "", "Couldn't get set rates activities: %s", err)
+	}
+	self.mu.RLock()
+	status.LastSetRate = self.lastSetRate
+	self.mu.RUnlock()
+	maxAge := uint64(self.config.MaxSetRateAge / time.Millisecond)
+	switch {
+	case status.LastSetRate == nil:
+		alert(TypeSetRateStalled, LevelWarning, "", "No set rates mined in %s", self.config.MaxSetRateAge)
+	case status.LastSetRate.Timestamp+maxAge < timepoint:
+		alert(TypeSetRateStalled, LevelWarning, "", "Last set rates %s was mined %s ago", status.LastSetRate.ID,
+			time.Duration(timepoint-status.LastSetRate.Timestamp)*time.Millisecond)
+	}
+
+	err := self.checkTokens(&status, alert)
+	if err != nil {
+		alert(TypeError, LevelCritical, "", "Couldn't check rates: %s", err)
+	}
+	self.notify(status.Alerts)
+	self.mu.Lock()
+	self.last = &status
+	self.mu.Unlock()
+	return status, err
+}
+
+// checkTokens checks when the rates of each internal token expire.
+func (self *Watchdog) checkTokens(status *Status, alert func(alertType, level, token, format string, args ...interface{})) error {
+	var err error
+	if status.CurrentBlock, err = self.blockchain.CurrentBlock(); err != nil {
+		return err
+	}
+	if status.ValidRateDuration, err = self.blockchain.ValidRateDurationInBlocks(); err != nil {
+		return err
+	}
+	tokens, err := self.setting.GetInternalTokens()
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if token.IsETH() {
+			continue
+		}
+		tokenStatus := TokenStatus{Token: token.ID}
+		updateBlock, gErr := self.blockchain.GetRateUpdateBlock(ethereum.HexToAddress(token.Address))
+		if gErr != nil {
+			tokenStatus.Error = gErr.Error()
+			status.Tokens = append(status.Tokens, tokenStatus)
+			alert(TypeError, LevelWarning, token.ID, "Couldn't get rate update block of %s: %s", token.ID, gErr)
+			continue
+		}
+		// the rates are valid while current block < update block + duration
+		tokenStatus.RateUpdateBlock = updateBlock
+		tokenStatus.ExpiryBlock = updateBlock + status.ValidRateDuration
+		tokenStatus.BlocksLeft = int64(tokenStatus.ExpiryBlock) - int64(status.CurrentBlock)
+		tokenStatus.Expired = tokenStatus.BlocksLeft <= 0
+		status.Tokens = append(status.Tokens, tokenStatus)
+		switch {
+		case tokenStatus.Expired:
+			alert(TypeRateExpiry, LevelCritical, token.ID, "Rates of %s set at block %d expired at block %d, current block %d",
+				token.ID, updateBlock, tokenStatus.ExpiryBlock, status.CurrentBlock)
+		case uint64(tokenStatus.BlocksLeft) <= self.config.AlertBlocks:
+			alert(TypeRateExpiry, LevelWarning, token.ID, "Rates of %s set at block %d expire in %d blocks",
+				token.ID, updateBlock, tokenStatus.BlocksLeft)
+		}
+	}
+	return nil
+}
+
+// notify sends the alerts not sent yet, the alerts not raised anymore are
+// resolved.
+func (self *Watchdog) notify(alerts []Alert) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	active := make(map[string]Alert)
+	for _, alert := range alerts {
+		key := alert.key()
+		if _, sent := self.active[key]; !sent {
+			for _, alerter := range self.alerters {
+				if err := alerter.Alert(alert); err != nil {
+					log.Printf("WATCHDOG: failed to send alert to %s: %s", alerter.Name(), err)
+				}
+			}
+		}
+		active[key] = alert
+	}
+	for key, alert := range self.active {
+		if _, ok := active[key]; !ok {
+			log.Printf("WATCHDOG: resolved %s %s alert of %q: %s", alert.Level, alert.Type, alert.Token, alert.Message)
+		}
+	}
+	self.active = active
+}
+
+// LastStatus returns the status of the last check, nil if there is none.
+func (self *Watchdog) LastStatus() *Status {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.last
+}
+
+// Run starts the runner and checks on every tick.
+func (self *Watchdog) Run() error {
+	if self.runner == nil {
+		return errors.New("Watchdog has no runner")
+	}
+	if err := self.runner.Start(); err != nil {
+		return err
+	}
+	go func() {
+		for t := range self.runner.GetWatchdogTicker() {
+			if _, err := self.Check(common.TimeToTimepoint(t)); err != nil {
+				log.Printf("WATCHDOG: check failed: %s", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops the runner.
+func (self *Watchdog) Stop() error {
+	if self.runner == nil {
+		return nil
+	}
+	return self.runner.Stop()
+}
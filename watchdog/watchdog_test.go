@@ -0,0 +1,136 @@
+package watchdog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+type testBlockchain struct {
+	block        uint64
+	updateBlocks map[ethereum.Address]uint64
+}
+
+func (self *testBlockchain) CurrentBlock() (uint64, error) {
+	return self.block, nil
+}
+
+func (self *testBlockchain) ValidRateDurationInBlocks() (uint64, error) {
+	return 100, nil
+}
+
+func (self *testBlockchain) GetRateUpdateBlock(token ethereum.Address) (uint64, error) {
+	return self.updateBlocks[token], nil
+}
+
+type testStorage struct {
+	records []common.ActivityRecord
+}
+
+func (self *testStorage) GetAllRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error) {
+	var result []common.ActivityRecord
+	for _, record := range self.records {
+		if record.ID.Timepoint >= fromTime && record.ID.Timepoint <= toTime {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+type testSetting struct {
+	tokens []common.Token
+}
+
+func (self testSetting) GetInternalTokens() ([]common.Token, error) {
+	return self.tokens, nil
+}
+
+type testAlerter struct {
+	alerts []Alert
+}
+
+func (self *testAlerter) Name() string {
+	return "test"
+}
+
+func (self *testAlerter) Alert(alert Alert) error {
+	self.alerts = append(self.alerts, alert)
+	return nil
+}
+
+func TestWatchdog(t *testing.T) {
+	var (
+		eth  = common.NewToken("ETH", "Ethereum", "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", 18, true, true, 0)
+		knc  = common.NewToken("KNC", "KyberNetwork", "0xdd974d5c2e2928dea5f71b9825b8b646686bd200", 18, true, true, 0)
+		omg  = common.NewToken("OMG", "OmiseGO", "0xd26114cd6ee289accf82350c8d8487fedb8a0c07", 18, true, true, 0)
+		now  = uint64(1539248400000)
+		bc   = &testBlockchain{block: 1000, updateBlocks: map[ethereum.Address]uint64{}}
+		st   = &testStorage{}
+		alrt = &testAlerter{}
+	)
+	setRate := func(timepoint uint64) {
+		st.records = append(st.records, common.ActivityRecord{
+			Action:       common.ActionSetrate,
+			ID:           common.NewActivityID(timepoint*uint64(time.Millisecond), "0x1"),
+			MiningStatus: common.MiningStatusMined,
+		})
+	}
+	// KNC rates expire in 5 blocks, OMG rates expired
+	bc.updateBlocks[ethereum.HexToAddress(knc.Address)] = 905
+	bc.updateBlocks[ethereum.HexToAddress(omg.Address)] = 800
+	setRate(now - 60000)
+
+	wd, err := NewWatchdog(bc, st, testSetting{tokens: []common.Token{eth, knc, omg}}, []Alerter{alrt},
+		nil, Config{AlertBlocks: 10, MaxSetRateAge: 10 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewWatchdog(bc, st, testSetting{}, nil, nil, Config{MaxSetRateAge: 48 * time.Hour}); err == nil {
+		t.Error("expected error of too long max set rate age")
+	}
+	if wd.LastStatus() != nil {
+		t.Error("expected no status before checking")
+	}
+
+	status, err := wd.Check(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Tokens) != 2 || status.Tokens[0].BlocksLeft != 5 || status.Tokens[0].Expired || !status.Tokens[1].Expired {
+		t.Errorf("unexpected token statuses %+v", status.Tokens)
+	}
+	if status.LastSetRate == nil || status.LastSetRate.Timestamp != now-60000 {
+		t.Errorf("unexpected last set rate %+v", status.LastSetRate)
+	}
+	if len(alrt.alerts) != 2 ||
+		alrt.alerts[0].Token != "KNC" || alrt.alerts[0].Level != LevelWarning ||
+		alrt.alerts[1].Token != "OMG" || alrt.alerts[1].Level != LevelCritical {
+		t.Fatalf("expected warning of KNC and critical alert of OMG, got %+v", alrt.alerts)
+	}
+
+	// the alerts are not sent again
+	if _, err = wd.Check(now + 1000); err != nil {
+		t.Fatal(err)
+	}
+	if len(alrt.alerts) != 2 {
+		t.Errorf("expected no new alerts, got %+v", alrt.alerts[2:])
+	}
+
+	// KNC rates expired, OMG rates are set again but set rates is stalled
+	bc.block = 1010
+	bc.updateBlocks[ethereum.HexToAddress(omg.Address)] = 950
+	status, err = wd.Check(now + 20*60000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Alerts) != 2 || len(alrt.alerts) != 4 ||
+		alrt.alerts[2].Type != TypeSetRateStalled ||
+		alrt.alerts[3].Token != "KNC" || alrt.alerts[3].Level != LevelCritical {
+		t.Errorf("expected stalled set rates and critical alert of KNC, got %+v", alrt.alerts)
+	}
+	if last := wd.LastStatus(); last == nil || last.Timestamp != now+20*60000 {
+		t.Errorf("unexpected last status %+v", last)
+	}
+}
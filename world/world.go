@@ -3,106 +3,54 @@ package world
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/httpclient"
 )
 
+// feedTimeout is the timeout of requests to the feeds.
+const feedTimeout = 30 * time.Second
+
 //TheWorld is the concrete implementation of fetcher.TheWorld interface.
 type TheWorld struct {
 	endpoint Endpoint
+	client   *httpclient.Client
 }
 
-func (self *TheWorld) getOneForgeGoldUSDInfo() common.OneForgeGoldData {
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
-	}
-	url := self.endpoint.OneForgeGoldUSDDataEndpoint()
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Add("Accept", "application/json")
-	var err error
-	var respBody []byte
-	log.Printf("request to gold feed endpoint: %s", req.URL)
-	resp, err := client.Do(req)
+// getFeed requests the JSON data of a feed from url into result.
+func (self *TheWorld) getFeed(name, url string, result interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return common.OneForgeGoldData{
-			Error:   true,
-			Message: err.Error(),
-		}
-	} else {
-		defer func() {
-			if cErr := resp.Body.Close(); cErr != nil {
-				log.Printf("Close http body error: %s", cErr.Error())
-			}
-		}()
-		if resp.StatusCode != 200 {
-			err = fmt.Errorf("Gold feed returned with code: %d", resp.StatusCode)
-			return common.OneForgeGoldData{
-				Error:   true,
-				Message: err.Error(),
-			}
-		}
-		respBody, err = ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return common.OneForgeGoldData{
-				Error:   true,
-				Message: err.Error(),
-			}
-		}
-		log.Printf("request to %s, got response from gold feed %s", req.URL, respBody)
-		result := common.OneForgeGoldData{}
-		err = json.Unmarshal(respBody, &result)
-		if err != nil {
-			result.Error = true
-			result.Message = err.Error()
-		}
-		return result
+		return err
 	}
-}
-
-func (self *TheWorld) getOneForgeGoldETHInfo() common.OneForgeGoldData {
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
-	}
-	url := self.endpoint.OneForgeGoldETHDataEndpoint()
-	req, _ := http.NewRequest("GET", url, nil)
 	req.Header.Add("Accept", "application/json")
-	var err error
-	var respBody []byte
-	log.Printf("request to gold feed endpoint: %s", req.URL)
-	resp, err := client.Do(req)
+	log.Printf("request to %s endpoint: %s", name, req.URL)
+	resp, err := self.client.Do(req)
 	if err != nil {
-		return common.OneForgeGoldData{
-			Error:   true,
-			Message: err.Error(),
-		}
+		return err
 	}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("Response body close error: %s", cErr.Error())
-		}
-	}()
-	if resp.StatusCode != 200 {
-		err = fmt.Errorf("Gold feed returned with code: %d", resp.StatusCode)
-		return common.OneForgeGoldData{
-			Error:   true,
-			Message: err.Error(),
-		}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned with code: %d", name, resp.StatusCode)
 	}
-	respBody, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return common.OneForgeGoldData{
-			Error:   true,
-			Message: err.Error(),
-		}
+	log.Printf("request to %s, got response from %s %s", req.URL, name, common.TruncStr(resp.Body))
+	return json.Unmarshal(resp.Body, result)
+}
+
+func (self *TheWorld) getOneForgeGoldUSDInfo() common.OneForgeGoldData {
+	result := common.OneForgeGoldData{}
+	if err := self.getFeed("gold feed", self.endpoint.OneForgeGoldUSDDataEndpoint(), &result); err != nil {
+		result.Error = true
+		result.Message = err.Error()
 	}
-	log.Printf("request to %s, got response from gold feed %s", req.URL, respBody)
+	return result
+}
+
+func (self *TheWorld) getOneForgeGoldETHInfo() common.OneForgeGoldData {
 	result := common.OneForgeGoldData{}
-	err = json.Unmarshal(respBody, &result)
-	if err != nil {
+	if err := self.getFeed("gold feed", self.endpoint.OneForgeGoldETHDataEndpoint(), &result); err != nil {
 		result.Error = true
 		result.Message = err.Error()
 	}
@@ -110,194 +58,54 @@ func (self *TheWorld) getOneForgeGoldETHInfo() common.OneForgeGoldData {
 }
 
 func (self *TheWorld) getDGXGoldInfo() common.DGXGoldData {
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
-	}
-	url := self.endpoint.GoldDataEndpoint()
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Add("Accept", "application/json")
-	var err error
-	var respBody []byte
-	log.Printf("request to gold feed endpoint: %s", req.URL)
-	resp, err := client.Do(req)
-	if err != nil {
-		return common.DGXGoldData{
-			Valid: false,
-			Error: err.Error(),
-		}
-	}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("Close reponse body error: %s", cErr.Error())
-		}
-	}()
-	if resp.StatusCode != 200 {
-		err = fmt.Errorf("Gold feed returned with code: %d", resp.StatusCode)
-		return common.DGXGoldData{
-			Valid: false,
-			Error: err.Error(),
-		}
+	result := common.DGXGoldData{
+		Valid: true,
 	}
-	respBody, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
+	if err := self.getFeed("gold feed", self.endpoint.GoldDataEndpoint(), &result); err != nil {
 		return common.DGXGoldData{
 			Valid: false,
 			Error: err.Error(),
 		}
 	}
-	log.Printf("request to %s, got response from gold feed %s", req.URL, respBody)
-	result := common.DGXGoldData{
-		Valid: true,
-	}
-	err = json.Unmarshal(respBody, &result)
-	if err != nil {
-		result.Valid = false
-		result.Error = err.Error()
-	}
 	return result
 }
 
 func (self *TheWorld) getGDAXGoldInfo() common.GDAXGoldData {
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
-	}
-	url := self.endpoint.GDAXDataEndpoint()
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Add("Accept", "application/json")
-	var err error
-	var respBody []byte
-	log.Printf("request to gold feed endpoint: %s", req.URL)
-	resp, err := client.Do(req)
-	if err != nil {
-		return common.GDAXGoldData{
-			Valid: false,
-			Error: err.Error(),
-		}
-	}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("Response body close error: %s", cErr.Error())
-		}
-	}()
-	if resp.StatusCode != 200 {
-		err = fmt.Errorf("Gold feed returned with code: %d", resp.StatusCode)
-		return common.GDAXGoldData{
-			Valid: false,
-			Error: err.Error(),
-		}
+	result := common.GDAXGoldData{
+		Valid: true,
 	}
-	respBody, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
+	if err := self.getFeed("gold feed", self.endpoint.GDAXDataEndpoint(), &result); err != nil {
 		return common.GDAXGoldData{
 			Valid: false,
 			Error: err.Error(),
 		}
 	}
-	log.Printf("request to %s, got response from gold feed %s", req.URL, respBody)
-	result := common.GDAXGoldData{
-		Valid: true,
-	}
-	err = json.Unmarshal(respBody, &result)
-	if err != nil {
-		result.Valid = false
-		result.Error = err.Error()
-	}
 	return result
 }
 
 func (self *TheWorld) getKrakenGoldInfo() common.KrakenGoldData {
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
-	}
-	url := self.endpoint.KrakenDataEndpoint()
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Add("Accept", "application/json")
-	var err error
-	var respBody []byte
-	log.Printf("request to gold feed endpoint: %s", req.URL)
-	resp, err := client.Do(req)
-	if err != nil {
-		return common.KrakenGoldData{
-			Valid: false,
-			Error: err.Error(),
-		}
-	}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("Response body close error: %s", cErr.Error())
-		}
-	}()
-	if resp.StatusCode != 200 {
-		err = fmt.Errorf("Gold feed returned with code: %d", resp.StatusCode)
-		return common.KrakenGoldData{
-			Valid: false,
-			Error: err.Error(),
-		}
+	result := common.KrakenGoldData{
+		Valid: true,
 	}
-	respBody, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
+	if err := self.getFeed("gold feed", self.endpoint.KrakenDataEndpoint(), &result); err != nil {
 		return common.KrakenGoldData{
 			Valid: false,
 			Error: err.Error(),
 		}
 	}
-	log.Printf("request to %s, got response from gold feed %s", req.URL, respBody)
-	result := common.KrakenGoldData{
-		Valid: true,
-	}
-	err = json.Unmarshal(respBody, &result)
-	if err != nil {
-		result.Valid = false
-		result.Error = err.Error()
-	}
 	return result
 }
 
 func (self *TheWorld) getGeminiGoldInfo() common.GeminiGoldData {
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
-	}
-	url := self.endpoint.GeminiDataEndpoint()
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Add("Accept", "application/json")
-	var err error
-	var respBody []byte
-	log.Printf("request to gold feed endpoint: %s", req.URL)
-	resp, err := client.Do(req)
-	if err != nil {
-		return common.GeminiGoldData{
-			Valid: false,
-			Error: err.Error(),
-		}
-	}
-	defer func() {
-		if cErr := resp.Body.Close(); cErr != nil {
-			log.Printf("Response body close error: %s", cErr.Error())
-		}
-	}()
-	if resp.StatusCode != 200 {
-		err = fmt.Errorf("Gold feed returned with code: %d", resp.StatusCode)
-		return common.GeminiGoldData{
-			Valid: false,
-			Error: err.Error(),
-		}
+	result := common.GeminiGoldData{
+		Valid: true,
 	}
-	respBody, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
+	if err := self.getFeed("gold feed", self.endpoint.GeminiDataEndpoint(), &result); err != nil {
 		return common.GeminiGoldData{
 			Valid: false,
 			Error: err.Error(),
 		}
 	}
-	log.Printf("request to %s, got response from gold feed %s", req.URL, respBody)
-	result := common.GeminiGoldData{
-		Valid: true,
-	}
-	err = json.Unmarshal(respBody, &result)
-	if err != nil {
-		result.Valid = false
-		result.Error = err.Error()
-	}
 	return result
 }
 
@@ -320,9 +128,9 @@ func NewTheWorld(env string, keyfile string) (*TheWorld, error) {
 		if err != nil {
 			return nil, err
 		}
-		return &TheWorld{endpoint}, nil
+		return &TheWorld{endpoint, httpclient.NewClient("world", httpclient.DefaultConfig(feedTimeout))}, nil
 	case common.SimulationMode:
-		return &TheWorld{SimulatedEndpoint{}}, nil
+		return &TheWorld{SimulatedEndpoint{}, httpclient.NewClient("world", httpclient.DefaultConfig(feedTimeout))}, nil
 	}
 	panic("unsupported environment")
 }